@@ -0,0 +1,48 @@
+-- =====================================================================================
+-- Migration: 任务与代码关联 (task_links)
+-- Created: 2026-10-18 00:00:00
+-- Version: 002
+--
+-- Git网关上报提交、分支和合并请求事件，项目服务从提交信息、分支名和合并请求标题中
+-- 解析任务编号（项目键-任务序号，如 AX-123）并记录关联
+-- =====================================================================================
+
+BEGIN;
+
+CREATE TABLE task_links (
+    id            UUID         NOT NULL PRIMARY KEY DEFAULT uuid_generate_v7(),
+    tenant_id     UUID         NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    task_id       UUID         NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    repository_id UUID         NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    repository    VARCHAR(255) NOT NULL,
+    link_type     VARCHAR(20)  NOT NULL CHECK (link_type IN ('commit', 'branch', 'pull_request')),
+    reference     VARCHAR(255) NOT NULL,
+    branch        VARCHAR(255),
+    title         VARCHAR(512),
+    author_name   VARCHAR(255),
+    author_email  VARCHAR(255),
+    user_id       UUID         REFERENCES users(id) ON DELETE SET NULL,
+    state         VARCHAR(20)  NOT NULL DEFAULT 'open' CHECK (state IN ('open', 'merged', 'closed', 'deleted')),
+    closes        BOOLEAN      NOT NULL DEFAULT FALSE,
+    occurred_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    merged_at     TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT unique_task_link UNIQUE(task_id, repository_id, link_type, reference)
+);
+
+COMMENT ON TABLE task_links IS '任务与提交、分支、合并请求的关联表';
+COMMENT ON COLUMN task_links.reference IS '提交SHA、分支名或合并请求ID';
+COMMENT ON COLUMN task_links.closes IS '是否包含关闭关键字（如 fixes AX-123），合并后自动完成任务';
+
+CREATE INDEX idx_task_links_task ON task_links (task_id, link_type);
+CREATE INDEX idx_task_links_repository_branch ON task_links (repository_id, branch) WHERE closes;
+
+ALTER TABLE task_links ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation_policy ON task_links
+FOR ALL
+USING (tenant_id = current_setting('app.current_tenant_id', TRUE)::uuid);
+
+COMMIT;
@@ -47,7 +47,8 @@ func main() {
 
 	// 初始化服务
 	cacheService := services.NewCacheService(db, cfg)
-	pipelineService := services.NewPipelineService(db, cfg)
+	gitGatewayClient := services.NewGitGatewayClient(cfg)
+	pipelineService := services.NewPipelineService(db, cfg, gitGatewayClient)
 	
 	// 初始化Tekton服务（可能失败，不阻塞启动）
 	var tektonService services.TektonService
@@ -63,22 +64,73 @@ func main() {
 		log.Printf("✅ Tekton服务连接成功")
 	}
 	
-	pipelineRunService = services.NewPipelineRunService(db, cfg, tektonService)
-	
-	// 如果tekton服务是真实的，更新其pipelineRunService引用
-	if realTekton, ok := tektonService.(*services.tektonService); ok {
-		// 这里需要设置runService字段，但由于是私有字段，需要修改服务设计
-		_ = realTekton // 避免未使用变量警告
+	// 初始化对象存储、任务日志和构建产物服务
+	objectStorage, err := services.NewObjectStorage(cfg)
+	if err != nil {
+		log.Fatalf("❌ 对象存储初始化失败: %v", err)
+	}
+	logService := services.NewLogService(db, cfg, objectStorage)
+	tektonService.SetLogService(logService)
+	artifactService := services.NewArtifactService(db, cfg, objectStorage)
+
+	// 初始化密钥服务（信封加密）
+	kekProvider, err := services.NewKEKProvider(cfg)
+	if err != nil {
+		log.Fatalf("❌ 主密钥初始化失败: %v", err)
+	}
+	secretService := services.NewSecretService(db, cfg, kekProvider)
+
+	// 日志写入前替换密钥值和项目掩码规则匹配的内容
+	logMaskService := services.NewLogMaskService(db, cfg, secretService)
+	logService.SetMaskService(logMaskService)
+
+	// 初始化自托管执行器服务
+	runnerService := services.NewRunnerService(db, cfg, logService, artifactService, secretService)
+
+	// 初始化流水线执行器
+	executor, err := services.NewExecutor(cfg, tektonService, runnerService, logService, artifactService, secretService)
+	if err != nil {
+		log.Fatalf("❌ 执行器初始化失败: %v", err)
 	}
+	log.Printf("✅ 流水线执行器: %s", executor.Name())
+
+	pipelineRunService = services.NewPipelineRunService(db, cfg, executor, gitGatewayClient)
+	
+	// 注入运行服务，用于Tekton事件和执行器上报回写运行状态
+	tektonService.SetRunService(pipelineRunService)
+	runnerService.SetRunService(pipelineRunService)
+
+	// 初始化环境、部署审批和部署记录服务，部署到受保护环境的任务在审批通过后执行并记录部署
+	environmentService := services.NewEnvironmentService(db, cfg)
+	approvalService := services.NewApprovalService(db, cfg)
+	deploymentService := services.NewDeploymentService(db, cfg, approvalService, artifactService, pipelineRunService)
+	pipelineRunService.SetDeploymentGate(deploymentService)
+	runnerService.SetDeploymentGate(deploymentService)
+	approvalService.OnResolved(runnerService.ScheduleRun)
+
+	// 初始化定时触发服务
+	schedulerService := services.NewSchedulerService(db, cfg, pipelineRunService)
+
+	// 初始化Git事件服务，仓库推送和合并请求按流水线触发器创建运行
+	gitWebhookService := services.NewGitWebhookService(db, cfg, pipelineRunService)
 
 	// 初始化处理器
 	pipelineHandler := handlers.NewPipelineHandler(pipelineService)
 	pipelineRunHandler := handlers.NewPipelineRunHandler(pipelineRunService)
 	cacheHandler := handlers.NewCacheHandler(cacheService)
+	runnerHandler := handlers.NewRunnerHandler(runnerService)
+	logHandler := handlers.NewLogHandler(logService)
+	artifactHandler := handlers.NewArtifactHandler(artifactService)
+	secretHandler := handlers.NewSecretHandler(secretService)
+	logMaskHandler := handlers.NewLogMaskHandler(logMaskService)
+	gitWebhookHandler := handlers.NewGitWebhookHandler(gitWebhookService)
 	healthHandler := handlers.NewHealthHandler(db, tektonService)
+	environmentHandler := handlers.NewEnvironmentHandler(environmentService)
+	approvalHandler := handlers.NewApprovalHandler(approvalService)
+	deploymentHandler := handlers.NewDeploymentHandler(deploymentService)
 
 	// 设置路由
-	router := routes.SetupRoutes(db, cfg, pipelineHandler, pipelineRunHandler, cacheHandler, healthHandler)
+	router := routes.SetupRoutes(db, cfg, pipelineHandler, pipelineRunHandler, cacheHandler, runnerHandler, logHandler, artifactHandler, secretHandler, logMaskHandler, gitWebhookHandler, healthHandler, environmentHandler, approvalHandler, deploymentHandler)
 
 	// 创建HTTP服务器
 	srv := &http.Server{
@@ -112,6 +164,29 @@ func main() {
 	// 启动流水线运行清理定时任务  
 	go startPipelineRunCleanupRoutine(pipelineRunService, cfg)
 
+	// 启动执行器离线检测定时任务
+	go startRunnerMonitorRoutine(runnerService, cfg)
+
+	// 启动任务日志清理定时任务
+	go startLogCleanupRoutine(logService, cfg)
+
+	// 启动构建产物清理定时任务
+	go startArtifactCleanupRoutine(artifactService)
+
+	// 启动密钥重新加密定时任务，主密钥轮换后将数据密钥改用新主密钥加密
+	go startSecretRewrapRoutine(secretService)
+
+	// 启动部署审批检查定时任务，处理审批超时和等待时间
+	go startApprovalRoutine(approvalService, cfg)
+
+	// 启动运行队列检查定时任务，启动重启前和其他实例留下的排队运行
+	go startRunQueueRoutine(pipelineRunService, cfg)
+
+	// 启动流水线定时触发，多副本通过数据库条件更新避免重复触发
+	if cfg.Scheduler.Enabled {
+		go startSchedulerRoutine(schedulerService, cfg)
+	}
+
 	// 启动服务器
 	go func() {
 		log.Printf("🌟 CI/CD服务启动在端口 %s", cfg.Port)
@@ -172,16 +247,40 @@ func initDatabase(cfg *config.Config) (*gorm.DB, error) {
 
 // runMigrations 运行数据库迁移
 func runMigrations(db *gorm.DB) error {
-	return db.AutoMigrate(
+	if err := db.AutoMigrate(
 		&models.Pipeline{},
 		&models.Task{},
 		&models.PipelineRun{},
 		&models.TaskRun{},
+		&models.Artifact{},
 		&models.BuildCache{},
 		&models.Secret{},
+		&models.LogMaskPattern{},
+		&models.PipelineSchedule{},
+		&models.GitWebhookDelivery{},
 		&models.Environment{},
+		&models.DeploymentApproval{},
+		&models.ApprovalDecision{},
+		&models.Deployment{},
+		&models.Runner{},
+		&models.RunnerRegistrationToken{},
 		&models.Project{}, // 引用的项目模型
-	)
+	); err != nil {
+		return err
+	}
+
+	// 旧版按事件类型和载荷摘要去重，会拒绝内容相同的合法投递，改为只按投递ID去重
+	if db.Migrator().HasColumn(&models.GitWebhookDelivery{}, "payload_sha256") {
+		if err := db.Migrator().DropColumn(&models.GitWebhookDelivery{}, "payload_sha256"); err != nil {
+			return err
+		}
+	}
+
+	// 旧版 secrets.data 为明文JSON列（此前没有写入接口），密钥改为信封加密后删除
+	if db.Migrator().HasColumn(&models.Secret{}, "data") {
+		return db.Migrator().DropColumn(&models.Secret{}, "data")
+	}
+	return nil
 }
 
 // startCacheCleanupRoutine 启动缓存清理定时任务
@@ -217,6 +316,133 @@ func startPipelineRunCleanupRoutine(pipelineRunService services.PipelineRunServi
 	}
 }
 
+// startRunnerMonitorRoutine 启动执行器离线检测和运行超时检测定时任务
+func startRunnerMonitorRoutine(runnerService services.RunnerService, cfg *config.Config) {
+	interval := time.Duration(cfg.Runner.OfflineTimeout) * time.Second / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("⚡ 启动执行器离线检测定时任务，间隔: %s", interval)
+
+	for range ticker.C {
+		if err := runnerService.DetectOfflineRunners(); err != nil {
+			log.Printf("⚠️ 执行器离线检测失败: %v", err)
+		}
+		if err := runnerService.ExpireTimedOutRuns(); err != nil {
+			log.Printf("⚠️ 执行器运行超时检测失败: %v", err)
+		}
+	}
+}
+
+// startLogCleanupRoutine 启动任务日志清理定时任务，删除超过保留天数的日志
+func startLogCleanupRoutine(logService services.LogService, cfg *config.Config) {
+	ticker := time.NewTicker(6 * time.Hour)
+	defer ticker.Stop()
+
+	log.Printf("⚡ 启动任务日志清理定时任务，间隔: 6小时，保留: %d天", cfg.Storage.RetentionDays)
+
+	for range ticker.C {
+		cleaned, err := logService.CleanupExpired()
+		if err != nil {
+			log.Printf("⚠️ 任务日志清理失败: %v", err)
+		} else if cleaned > 0 {
+			log.Printf("🧹 任务日志清理完成，清理运行数: %d", cleaned)
+		}
+	}
+}
+
+// startArtifactCleanupRoutine 启动构建产物清理定时任务，删除过期产物
+func startArtifactCleanupRoutine(artifactService services.ArtifactService) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	log.Printf("⚡ 启动构建产物清理定时任务，间隔: 1小时")
+
+	for range ticker.C {
+		cleaned, err := artifactService.CleanupExpired()
+		if err != nil {
+			log.Printf("⚠️ 构建产物清理失败: %v", err)
+		} else if cleaned > 0 {
+			log.Printf("🧹 构建产物清理完成，清理文件数: %d", cleaned)
+		}
+	}
+}
+
+// startSecretRewrapRoutine 启动密钥重新加密定时任务
+func startSecretRewrapRoutine(secretService services.SecretService) {
+	rewrap := func() {
+		rewrapped, err := secretService.RewrapStaleKeys(context.Background())
+		if err != nil {
+			log.Printf("⚠️ 密钥重新加密失败: %v", err)
+		} else if rewrapped > 0 {
+			log.Printf("🔑 已使用当前主密钥重新加密 %d 个密钥", rewrapped)
+		}
+	}
+
+	rewrap()
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		rewrap()
+	}
+}
+
+// startSchedulerRoutine 启动流水线定时触发：每分钟同步触发器配置，按间隔触发到期的时间点
+func startSchedulerRoutine(schedulerService services.SchedulerService, cfg *config.Config) {
+	interval := time.Duration(cfg.Scheduler.Interval) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("⏰ 启动流水线定时触发，间隔: %s", interval)
+
+	var lastSync time.Time
+	for ; true; <-ticker.C {
+		if time.Since(lastSync) >= time.Minute {
+			if err := schedulerService.SyncSchedules(); err != nil {
+				log.Printf("⚠️ 同步定时触发失败: %v", err)
+			} else {
+				lastSync = time.Now()
+			}
+		}
+		created, err := schedulerService.RunDueSchedules()
+		if err != nil {
+			log.Printf("⚠️ 定时触发失败: %v", err)
+		} else if created > 0 {
+			log.Printf("⏰ 定时触发创建了 %d 个流水线运行", created)
+		}
+	}
+}
+
+// startApprovalRoutine 启动部署审批检查：审批超时、等待时间结束后唤醒等待的运行，取消已结束运行的审批
+func startApprovalRoutine(approvalService services.ApprovalService, cfg *config.Config) {
+	ticker := time.NewTicker(time.Duration(cfg.Approval.CheckInterval) * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		processed, err := approvalService.ProcessDue()
+		if err != nil {
+			log.Printf("⚠️ 处理部署审批失败: %v", err)
+		} else if processed > 0 {
+			log.Printf("✅ 处理了 %d 个到期的部署审批", processed)
+		}
+	}
+}
+
+// startRunQueueRoutine 启动运行队列检查：按并发上限启动排队中的运行
+func startRunQueueRoutine(pipelineRunService services.PipelineRunService, cfg *config.Config) {
+	ticker := time.NewTicker(time.Duration(cfg.Queue.DispatchInterval) * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		started, err := pipelineRunService.DispatchQueue()
+		if err != nil {
+			log.Printf("⚠️ 运行出队失败: %v", err)
+		} else if started > 0 {
+			log.Printf("✅ 从队列启动了 %d 个流水线运行", started)
+		}
+	}
+}
+
 // noOpTektonService 空操作Tekton服务实现（当Tekton不可用时使用）
 type noOpTektonService struct{}
 
@@ -260,18 +486,10 @@ func (s *noOpTektonService) WatchTaskRuns(ctx context.Context) error {
 	return fmt.Errorf("Tekton服务不可用")
 }
 
-func (s *noOpTektonService) CreateSecret(ctx context.Context, secret *models.Secret) error {
-	return fmt.Errorf("Tekton服务不可用")
-}
-
-func (s *noOpTektonService) UpdateSecret(ctx context.Context, secret *models.Secret) error {
+func (s *noOpTektonService) HealthCheck(ctx context.Context) error {
 	return fmt.Errorf("Tekton服务不可用")
 }
 
-func (s *noOpTektonService) DeleteSecret(ctx context.Context, secretID uuid.UUID) error {
-	return fmt.Errorf("Tekton服务不可用")
-}
+func (s *noOpTektonService) SetRunService(runService services.PipelineRunService) {}
 
-func (s *noOpTektonService) HealthCheck(ctx context.Context) error {
-	return fmt.Errorf("Tekton服务不可用")
-}
\ No newline at end of file
+func (s *noOpTektonService) SetLogService(logService services.LogService) {}
\ No newline at end of file
@@ -0,0 +1,885 @@
+// Command runner 是Axiom CI/CD自托管执行器的参考实现：
+// 注册后通过心跳保持在线，长轮询领取标签匹配的作业，在本机（或docker/podman容器中）执行，
+// 并分段上传日志和状态。作业声明的依赖产物在执行前下载，产出的产物在上报结束状态前上传。
+// 收到 SIGINT/SIGTERM 时进入排空，执行完当前作业后下线。
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const runnerVersion = "1.0.0"
+
+// credentials 注册后保存在本地的执行器凭据
+type credentials struct {
+	URL   string `json:"url"`
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Token string `json:"token"`
+}
+
+// apiResponse 服务端统一响应格式
+type apiResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Error   string          `json:"error"`
+}
+
+// job 服务端下发的作业
+type job struct {
+	ID            string  `json:"id"`
+	PipelineRunID string  `json:"pipeline_run_id"`
+	CommitSHA     *string `json:"commit_sha"`
+	Branch        *string `json:"branch"`
+	Spec          struct {
+		Name       string            `json:"name"`
+		Image      string            `json:"image"`
+		Command    []string          `json:"command"`
+		Args       []string          `json:"args"`
+		WorkingDir *string           `json:"working_dir"`
+		Env        map[string]string `json:"env"`
+		Timeout    int               `json:"timeout"`
+		Retries    int               `json:"retries"`
+		Artifacts  *struct {
+			Paths []string `json:"paths"`
+			When  string   `json:"when"`
+		} `json:"artifacts"`
+		ArtifactsFrom []struct {
+			Task string `json:"task"`
+		} `json:"artifacts_from"`
+	} `json:"spec"`
+}
+
+// jobArtifact 作业依赖的产物
+type jobArtifact struct {
+	ID     string `json:"id"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// runner 执行器进程状态
+type runner struct {
+	creds     credentials
+	client    *http.Client
+	workDir   string
+	runtime   string // 容器运行时，为空时以本地进程执行
+	slots     chan struct{}
+	heartbeat time.Duration
+
+	mu       sync.Mutex
+	draining bool
+	jobs     map[string]context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func main() {
+	serverURL := flag.String("url", os.Getenv("AXIOM_RUNNER_URL"), "CI/CD服务地址，如 http://cicd-service:8005")
+	registrationToken := flag.String("registration-token", os.Getenv("AXIOM_RUNNER_REGISTRATION_TOKEN"), "注册令牌（首次启动时使用）")
+	name := flag.String("name", "", "执行器名称，默认主机名")
+	tags := flag.String("tags", os.Getenv("AXIOM_RUNNER_TAGS"), "执行器标签，逗号分隔")
+	configPath := flag.String("config", "axiom-runner.json", "凭据文件路径")
+	workDir := flag.String("work-dir", "builds", "作业工作空间目录")
+	concurrency := flag.Int("concurrency", 1, "最大并发作业数")
+	containerRuntime := flag.String("container-runtime", "auto", "容器运行时: auto, docker, podman, none")
+	flag.Parse()
+
+	creds, err := loadCredentials(*configPath)
+	if err != nil {
+		if *serverURL == "" || *registrationToken == "" {
+			log.Fatalf("未找到凭据文件 %s，首次启动需要 -url 和 -registration-token", *configPath)
+		}
+		if *name == "" {
+			*name, _ = os.Hostname()
+		}
+		creds, err = register(*serverURL, *registrationToken, *name, splitTags(*tags), *concurrency)
+		if err != nil {
+			log.Fatalf("注册执行器失败: %v", err)
+		}
+		if err := saveCredentials(*configPath, creds); err != nil {
+			log.Fatalf("保存凭据失败: %v", err)
+		}
+		log.Printf("执行器 %s 注册成功，凭据已保存到 %s", creds.Name, *configPath)
+	}
+
+	r := &runner{
+		creds:     *creds,
+		client:    &http.Client{Timeout: 60 * time.Second},
+		workDir:   *workDir,
+		runtime:   detectRuntime(*containerRuntime),
+		slots:     make(chan struct{}, *concurrency),
+		heartbeat: 15 * time.Second,
+		jobs:      make(map[string]context.CancelFunc),
+	}
+	if r.runtime != "" {
+		log.Printf("使用容器运行时: %s", r.runtime)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		log.Printf("收到退出信号，进入排空：不再领取新作业，等待当前作业完成（再次发送信号强制终止）")
+		r.setDraining()
+		<-signals
+		log.Printf("强制终止正在执行的作业")
+		r.cancelAll()
+	}()
+
+	go r.heartbeatLoop(ctx)
+	r.pollLoop()
+
+	r.wg.Wait()
+	stop()
+	if err := r.call(http.MethodPost, "/shutdown", nil, nil); err != nil {
+		log.Printf("通知服务端下线失败: %v", err)
+	}
+	log.Printf("执行器已退出")
+}
+
+// register 使用注册令牌注册执行器
+func register(serverURL, registrationToken, name string, tags []string, concurrency int) (*credentials, error) {
+	r := &runner{creds: credentials{URL: strings.TrimRight(serverURL, "/")}, client: &http.Client{Timeout: 30 * time.Second}}
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	version := runnerVersion
+
+	var result struct {
+		Runner struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"runner"`
+		Token string `json:"token"`
+	}
+	err := r.call(http.MethodPost, "/register", map[string]interface{}{
+		"registration_token":  registrationToken,
+		"name":                name,
+		"tags":                tags,
+		"version":             version,
+		"platform":            platform,
+		"max_concurrent_jobs": concurrency,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &credentials{URL: r.creds.URL, ID: result.Runner.ID, Name: result.Runner.Name, Token: result.Token}, nil
+}
+
+// heartbeatLoop 定期发送心跳
+func (r *runner) heartbeatLoop(ctx context.Context) {
+	for {
+		r.sendHeartbeat()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.heartbeat):
+		}
+	}
+}
+
+// sendHeartbeat 发送心跳，处理排空和作业取消
+func (r *runner) sendHeartbeat() {
+	r.mu.Lock()
+	running := make([]string, 0, len(r.jobs))
+	for id := range r.jobs {
+		running = append(running, id)
+	}
+	draining := r.draining
+	r.mu.Unlock()
+
+	var response struct {
+		Draining          bool     `json:"draining"`
+		CancelledJobs     []string `json:"cancelled_jobs"`
+		HeartbeatInterval int      `json:"heartbeat_interval"`
+	}
+	version := runnerVersion
+	if err := r.call(http.MethodPost, "/heartbeat", map[string]interface{}{
+		"running_jobs": running,
+		"draining":     draining,
+		"version":      version,
+	}, &response); err != nil {
+		log.Printf("心跳失败: %v", err)
+		return
+	}
+
+	if response.HeartbeatInterval > 0 {
+		r.heartbeat = time.Duration(response.HeartbeatInterval) * time.Second
+	}
+	if response.Draining && !draining {
+		log.Printf("服务端要求排空，不再领取新作业")
+		r.setDraining()
+	}
+	for _, id := range response.CancelledJobs {
+		r.cancelJob(id, "作业已在服务端取消")
+	}
+}
+
+// pollLoop 长轮询领取作业，排空后返回
+func (r *runner) pollLoop() {
+	for !r.isDraining() {
+		r.slots <- struct{}{}
+		if r.isDraining() {
+			<-r.slots
+			return
+		}
+
+		var j job
+		err := r.call(http.MethodPost, "/jobs/request", nil, &j)
+		if err != nil || j.ID == "" {
+			<-r.slots
+			if err != nil {
+				log.Printf("领取作业失败: %v", err)
+				time.Sleep(5 * time.Second)
+			}
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		r.mu.Lock()
+		r.jobs[j.ID] = cancel
+		r.mu.Unlock()
+
+		r.wg.Add(1)
+		go func() {
+			defer func() {
+				cancel()
+				r.mu.Lock()
+				delete(r.jobs, j.ID)
+				r.mu.Unlock()
+				<-r.slots
+				r.wg.Done()
+			}()
+			r.runJob(ctx, &j)
+		}()
+	}
+}
+
+// runJob 执行作业（包含重试）并上报结果
+func (r *runner) runJob(ctx context.Context, j *job) {
+	log.Printf("开始执行作业 %s (%s)", j.Spec.Name, j.ID)
+	workspace := filepath.Join(r.workDir, j.ID)
+	if err := os.MkdirAll(workspace, 0o755); err != nil {
+		r.reportStatus(j.ID, "failed", nil, err.Error(), 0)
+		return
+	}
+	defer os.RemoveAll(workspace)
+
+	logs := &logUploader{runner: r, jobID: j.ID, cancel: func() { r.cancelJob(j.ID, "作业已在服务端取消") }}
+	stopFlush := logs.start()
+	defer stopFlush()
+
+	var exitCode *int
+	var message string
+	status := "failed"
+	if err := r.downloadArtifacts(ctx, j, workspace, logs); err != nil {
+		message = fmt.Sprintf("下载依赖产物失败: %v", err)
+		fmt.Fprintln(logs, message)
+	} else {
+		for attempt := 0; attempt <= j.Spec.Retries; attempt++ {
+			if attempt > 0 {
+				fmt.Fprintf(logs, "\n--- 第 %d 次重试 ---\n", attempt)
+				r.reportStatus(j.ID, "running", nil, "", attempt)
+			}
+
+			exitCode, message = r.execute(ctx, j, workspace, logs)
+			if message == "" {
+				status = "succeeded"
+				break
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		if ctx.Err() == nil {
+			if err := r.uploadArtifacts(ctx, j, workspace, status, logs); err != nil {
+				fmt.Fprintf(logs, "上传产物失败: %v\n", err)
+				if status == "succeeded" {
+					status, message = "failed", fmt.Sprintf("上传产物失败: %v", err)
+				}
+			}
+		}
+	}
+
+	stopFlush()
+	if ctx.Err() == context.Canceled {
+		log.Printf("作业 %s 已取消", j.ID)
+		return
+	}
+	r.reportStatus(j.ID, status, exitCode, message, -1)
+	log.Printf("作业 %s 结束: %s", j.ID, status)
+}
+
+// execute 执行一次作业命令，返回退出码和失败原因（成功时为空）
+func (r *runner) execute(ctx context.Context, j *job, workspace string, output io.Writer) (*int, string) {
+	if j.Spec.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(j.Spec.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	relDir := ""
+	if j.Spec.WorkingDir != nil {
+		relDir = strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(*j.Spec.WorkingDir, "/workspace")), "/")
+	}
+
+	env := make([]string, 0, len(j.Spec.Env)+3)
+	for key, value := range j.Spec.Env {
+		env = append(env, key+"="+value)
+	}
+	sort.Strings(env)
+
+	var cmd *exec.Cmd
+	if r.runtime != "" && j.Spec.Image != "" {
+		absWorkspace, _ := filepath.Abs(workspace)
+		args := []string{"run", "--rm", "--name", "axiom-" + j.ID, "-v", absWorkspace + ":/workspace", "-w", path.Join("/workspace", relDir)}
+		for _, kv := range env {
+			args = append(args, "-e", kv)
+		}
+		if len(j.Spec.Command) > 0 {
+			args = append(args, "--entrypoint", j.Spec.Command[0])
+		}
+		args = append(args, j.Spec.Image)
+		if len(j.Spec.Command) > 1 {
+			args = append(args, j.Spec.Command[1:]...)
+		}
+		args = append(args, j.Spec.Args...)
+		cmd = exec.CommandContext(ctx, r.runtime, args...)
+		defer func() {
+			if ctx.Err() != nil {
+				exec.Command(r.runtime, "rm", "-f", "axiom-"+j.ID).Run()
+			}
+		}()
+	} else {
+		if len(j.Spec.Command) == 0 {
+			return nil, "作业未配置命令，进程模式无法使用镜像默认入口"
+		}
+		dir := filepath.Join(workspace, filepath.FromSlash(relDir))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err.Error()
+		}
+		cmd = exec.CommandContext(ctx, j.Spec.Command[0], append(append([]string{}, j.Spec.Command[1:]...), j.Spec.Args...)...)
+		cmd.Dir = dir
+		cmd.Env = append([]string{"PATH=" + os.Getenv("PATH"), "HOME=" + workspace, "AXIOM_WORKSPACE=" + workspace}, env...)
+	}
+
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.WaitDelay = 10 * time.Second
+	err := cmd.Run()
+
+	var exitCode *int
+	if cmd.ProcessState != nil && cmd.ProcessState.ExitCode() >= 0 {
+		code := cmd.ProcessState.ExitCode()
+		exitCode = &code
+	}
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return exitCode, fmt.Sprintf("作业执行超时(%d秒)", j.Spec.Timeout)
+	case err != nil:
+		return exitCode, fmt.Sprintf("作业执行失败: %v", err)
+	}
+	return exitCode, ""
+}
+
+// downloadArtifacts 下载作业依赖的产物到工作空间，写入临时文件并校验SHA-256后再重命名
+func (r *runner) downloadArtifacts(ctx context.Context, j *job, workspace string, output io.Writer) error {
+	if len(j.Spec.ArtifactsFrom) == 0 {
+		return nil
+	}
+
+	var artifacts []jobArtifact
+	if err := r.call(http.MethodGet, "/jobs/"+j.ID+"/artifacts", nil, &artifacts); err != nil {
+		return err
+	}
+	for _, artifact := range artifacts {
+		if err := r.downloadArtifact(ctx, j, workspace, artifact); err != nil {
+			return fmt.Errorf("%s: %w", artifact.Path, err)
+		}
+	}
+	fmt.Fprintf(output, "已下载 %d 个依赖产物文件\n", len(artifacts))
+	return nil
+}
+
+func (r *runner) downloadArtifact(ctx context.Context, j *job, workspace string, artifact jobArtifact) error {
+	rel := path.Clean(artifact.Path)
+	if rel == "." || path.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, "../") {
+		return fmt.Errorf("无效的产物路径")
+	}
+	dest := filepath.Join(workspace, filepath.FromSlash(rel))
+
+	resp, err := r.transfer(ctx, http.MethodGet, "/jobs/"+j.ID+"/artifacts/"+artifact.ID, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(dest), ".artifact-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, hash), resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != artifact.SHA256 {
+		return fmt.Errorf("SHA-256校验失败")
+	}
+	os.Chmod(file.Name(), 0o644)
+	return os.Rename(file.Name(), dest)
+}
+
+// uploadArtifacts 按作业的产物声明上传工作空间中匹配的文件
+func (r *runner) uploadArtifacts(ctx context.Context, j *job, workspace, status string, output io.Writer) error {
+	spec := j.Spec.Artifacts
+	if spec == nil || len(spec.Paths) == 0 {
+		return nil
+	}
+	switch spec.When {
+	case "always":
+	case "on_failure":
+		if status != "failed" {
+			return nil
+		}
+	default:
+		if status != "succeeded" {
+			return nil
+		}
+	}
+
+	files, err := matchArtifactFiles(workspace, spec.Paths)
+	if err != nil {
+		return err
+	}
+	for _, rel := range files {
+		if err := r.uploadArtifact(ctx, j, workspace, rel); err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+	}
+	fmt.Fprintf(output, "已上传 %d 个产物文件\n", len(files))
+	return nil
+}
+
+func (r *runner) uploadArtifact(ctx context.Context, j *job, workspace, rel string) error {
+	file, err := os.Open(filepath.Join(workspace, filepath.FromSlash(rel)))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	headers := map[string]string{
+		"Content-Type":      "application/octet-stream",
+		"X-Checksum-SHA256": hex.EncodeToString(hash.Sum(nil)),
+	}
+	resp, err := r.transfer(ctx, http.MethodPost, "/jobs/"+j.ID+"/artifacts?path="+url.QueryEscape(rel), file, headers)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// matchArtifactFiles 返回工作空间中匹配产物路径声明的普通文件，规则与服务端一致：
+// 不含通配符的路径匹配文件或目录，** 匹配任意层目录，匹配到目录时收集其下的全部文件
+func matchArtifactFiles(workspace string, patterns []string) ([]string, error) {
+	matched := make(map[string]bool)
+	err := filepath.WalkDir(workspace, func(filePath string, entry os.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(workspace, filePath)
+		if err != nil {
+			return err
+		}
+		segments := strings.Split(filepath.ToSlash(rel), "/")
+		for _, pattern := range patterns {
+			pattern = path.Clean(strings.TrimSuffix(pattern, "/"))
+			patternSegments := strings.Split(pattern, "/")
+			if pattern == "." {
+				patternSegments = []string{"**"}
+			}
+			for i := 1; i <= len(segments); i++ {
+				if matchSegments(patternSegments, segments[:i]) {
+					matched[filepath.ToSlash(rel)] = true
+					return nil
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(matched))
+	for rel := range matched {
+		files = append(files, rel)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// matchSegments 按路径段匹配，** 匹配零个或多个路径段
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
+
+// reportStatus 上报作业状态，retryCount 小于0时不上报重试次数
+func (r *runner) reportStatus(jobID, status string, exitCode *int, message string, retryCount int) {
+	body := map[string]interface{}{"status": status}
+	if exitCode != nil {
+		body["exit_code"] = *exitCode
+	}
+	if message != "" {
+		body["error_message"] = message
+	}
+	if retryCount >= 0 {
+		body["retry_count"] = retryCount
+	}
+
+	err := r.call(http.MethodPost, "/jobs/"+jobID+"/status", body, nil)
+	var apiErr *statusError
+	if errors.As(err, &apiErr) && apiErr.code == http.StatusGone {
+		r.cancelJob(jobID, "作业已在服务端取消")
+		return
+	}
+	if err != nil {
+		log.Printf("上报作业 %s 状态失败: %v", jobID, err)
+	}
+}
+
+// logUploader 缓冲作业输出，定期按偏移量上传。
+// 服务端逐块脱敏，因此只上传完整的行；偏移量以服务端返回的（脱敏后的）长度为准
+type logUploader struct {
+	runner *runner
+	jobID  string
+	cancel func()
+
+	mu          sync.Mutex
+	pending     bytes.Buffer
+	offset      int64
+	unconfirmed int // 上次请求结果未知的分块长度
+}
+
+// Write 写入作业输出
+func (l *logUploader) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pending.Write(p)
+	return len(p), nil
+}
+
+// start 启动定期上传，返回的函数停止上传并完成最后一次上传（可重复调用）
+func (l *logUploader) start() func() {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				l.flush(true)
+				return
+			case <-ticker.C:
+				l.flush(false)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-finished
+	}
+}
+
+// flush 上传缓冲的日志，final 为 false 时未结束的行留到下次上传（超长的行除外）
+func (l *logUploader) flush(final bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for l.pending.Len() > 0 {
+		chunk := l.pending.Bytes()
+		if len(chunk) > 512<<10 {
+			chunk = chunk[:512<<10]
+		} else if !final {
+			end := bytes.LastIndexAny(chunk, "\r\n") + 1
+			if end == 0 && len(chunk) < 64<<10 {
+				return
+			}
+			if end > 0 {
+				chunk = chunk[:end]
+			}
+		}
+
+		var result struct {
+			Offset int64 `json:"offset"`
+		}
+		err := l.runner.callRaw(http.MethodPost, "/jobs/"+l.jobID+"/logs?offset="+strconv.FormatInt(l.offset, 10),
+			bytes.NewReader(chunk), &result)
+
+		var apiErr *statusError
+		switch {
+		case err == nil:
+			l.pending.Next(len(chunk))
+			l.offset = result.Offset
+			l.unconfirmed = 0
+		case errors.As(err, &apiErr) && apiErr.code == http.StatusConflict:
+			// 服务端已接收的长度与本地不一致：上次结果未知的分块已被接收则跳过，并从服务端长度继续
+			if apiErr.offset > l.offset && l.unconfirmed > 0 && l.unconfirmed <= l.pending.Len() {
+				l.pending.Next(l.unconfirmed)
+			}
+			l.offset = apiErr.offset
+			l.unconfirmed = 0
+		case errors.As(err, &apiErr) && apiErr.code == http.StatusGone:
+			l.pending.Reset()
+			go l.cancel()
+			return
+		default:
+			if apiErr == nil {
+				l.unconfirmed = len(chunk)
+			}
+			log.Printf("上传作业 %s 日志失败: %v", l.jobID, err)
+			return
+		}
+	}
+}
+
+// statusError 服务端返回的非成功响应
+type statusError struct {
+	code    int
+	message string
+	offset  int64
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.code, e.message)
+}
+
+// call 以JSON请求调用执行器协议接口
+func (r *runner) call(method, endpoint string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	return r.callRaw(method, endpoint, reader, result)
+}
+
+// callRaw 调用执行器协议接口并解析响应数据
+func (r *runner) callRaw(method, endpoint string, body io.Reader, result interface{}) error {
+	req, err := http.NewRequest(method, r.creds.URL+"/runner-api/v1"+endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Axiom-Runner/"+runnerVersion)
+	if r.creds.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.creds.Token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	var response apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("解析响应失败 (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode >= 300 {
+		apiErr := &statusError{code: resp.StatusCode, message: strings.TrimSpace(response.Message + " " + response.Error)}
+		var data struct {
+			Offset int64 `json:"offset"`
+		}
+		if json.Unmarshal(response.Data, &data) == nil {
+			apiErr.offset = data.Offset
+		}
+		return apiErr
+	}
+
+	if result != nil && len(response.Data) > 0 {
+		return json.Unmarshal(response.Data, result)
+	}
+	return nil
+}
+
+// transfer 发送产物上传下载请求，不受普通请求超时限制，非2xx响应转换为 statusError
+func (r *runner) transfer(ctx context.Context, method, endpoint string, body io.Reader, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, r.creds.URL+"/runner-api/v1"+endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Axiom-Runner/"+runnerVersion)
+	req.Header.Set("Authorization", "Bearer "+r.creds.Token)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := (&http.Client{Transport: r.client.Transport}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var response apiResponse
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&response)
+		return nil, &statusError{code: resp.StatusCode, message: strings.TrimSpace(response.Message + " " + response.Error)}
+	}
+	return resp, nil
+}
+
+func (r *runner) setDraining() {
+	r.mu.Lock()
+	r.draining = true
+	r.mu.Unlock()
+}
+
+func (r *runner) isDraining() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.draining
+}
+
+// cancelJob 终止正在执行的作业
+func (r *runner) cancelJob(id, reason string) {
+	r.mu.Lock()
+	cancel, ok := r.jobs[id]
+	r.mu.Unlock()
+	if ok {
+		log.Printf("终止作业 %s: %s", id, reason)
+		cancel()
+	}
+}
+
+// cancelAll 终止所有正在执行的作业
+func (r *runner) cancelAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, cancel := range r.jobs {
+		cancel()
+	}
+}
+
+// detectRuntime 查找可用的容器运行时
+func detectRuntime(setting string) string {
+	candidates := []string{"docker", "podman"}
+	switch setting {
+	case "none":
+		return ""
+	case "docker", "podman":
+		candidates = []string{setting}
+	}
+	for _, name := range candidates {
+		if runtimePath, err := exec.LookPath(name); err == nil {
+			return runtimePath
+		}
+	}
+	return ""
+}
+
+// splitTags 解析逗号分隔的标签
+func splitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// loadCredentials 读取本地凭据文件
+func loadCredentials(configPath string) (*credentials, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	var creds credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, err
+	}
+	if creds.URL == "" || creds.Token == "" {
+		return nil, fmt.Errorf("凭据文件不完整")
+	}
+	return &creds, nil
+}
+
+// saveCredentials 保存凭据文件，仅当前用户可读
+func saveCredentials(configPath string, creds *credentials) error {
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(configPath, data, 0o600)
+}
@@ -23,6 +23,13 @@ type Config struct {
 	Cache       CacheConfig       `mapstructure:"cache"`
 	Logging     LoggingConfig     `mapstructure:"logging"`
 	Notification NotificationConfig `mapstructure:"notification"`
+	GitGateway  GitGatewayConfig  `mapstructure:"git_gateway"`
+	Executor    ExecutorConfig    `mapstructure:"executor"`
+	Runner      RunnerConfig      `mapstructure:"runner"`
+	Secrets     SecretsConfig     `mapstructure:"secrets"`
+	Scheduler   SchedulerConfig   `mapstructure:"scheduler"`
+	Approval    ApprovalConfig    `mapstructure:"approval"`
+	Queue       QueueConfig       `mapstructure:"queue"`
 }
 
 // DatabaseConfig 数据库配置
@@ -63,7 +70,7 @@ type TektonConfig struct {
 	DefaultTimeout    int    `mapstructure:"default_timeout"`    // 默认超时时间(秒)
 	PipelineRunTTL    int    `mapstructure:"pipeline_run_ttl"`   // PipelineRun保留时间(小时)
 	TaskRunTTL        int    `mapstructure:"task_run_ttl"`       // TaskRun保留时间(小时)
-	MaxConcurrentRuns int    `mapstructure:"max_concurrent_runs"` // 最大并发运行数
+	MaxConcurrentRuns int    `mapstructure:"max_concurrent_runs"` // 全局同时执行的运行数上限，超出的运行排队，0 表示不限制
 	ResourceQuota     ResourceQuotaConfig `mapstructure:"resource_quota"`
 }
 
@@ -81,6 +88,8 @@ type StorageConfig struct {
 	LocalPath     string `mapstructure:"local_path"`     // 本地存储路径
 	S3Config      S3Config `mapstructure:"s3"`           // S3配置
 	RetentionDays int    `mapstructure:"retention_days"` // 日志保留天数
+	ArtifactRetentionDays int `mapstructure:"artifact_retention_days"` // 产物默认保留天数，0 表示不过期
+	ArtifactMaxSizeMB     int `mapstructure:"artifact_max_size_mb"`    // 单个产物文件大小上限(MB)
 }
 
 // S3Config S3存储配置
@@ -124,6 +133,59 @@ type NotificationConfig struct {
 	EnabledChannels []string `mapstructure:"enabled_channels"` // 启用的通知渠道
 }
 
+// GitGatewayConfig Git网关服务调用配置（读取仓库中的流水线定义文件）
+type GitGatewayConfig struct {
+	BaseURL        string `mapstructure:"base_url"`
+	Timeout        int    `mapstructure:"timeout"`         // 请求超时(秒)
+	DefinitionFile string `mapstructure:"definition_file"` // 默认流水线定义文件路径
+	WebhookSecret  string `mapstructure:"webhook_secret"`  // 校验git-gateway-service事件签名(X-Hub-Signature-256)的密钥
+}
+
+// ExecutorConfig 流水线执行器配置
+type ExecutorConfig struct {
+	Type             string `mapstructure:"type"`               // tekton, local, runner
+	WorkDir          string `mapstructure:"work_dir"`           // 本地执行器工作空间根目录
+	ContainerRuntime string `mapstructure:"container_runtime"`  // 本地执行器容器运行时: auto, docker, podman, none
+	AllowProcessMode bool   `mapstructure:"allow_process_mode"` // 允许没有容器运行时时以本地进程运行任务（仅限开发环境，任务与服务同权限）
+	MaxParallelTasks int    `mapstructure:"max_parallel_tasks"` // 本地执行器单次运行最大并行任务数
+	KeepWorkspace    bool   `mapstructure:"keep_workspace"`     // 运行结束后保留工作空间（调试用）
+}
+
+// RunnerConfig 自托管执行器配置
+type RunnerConfig struct {
+	HeartbeatInterval int `mapstructure:"heartbeat_interval"` // 执行器心跳间隔(秒)
+	OfflineTimeout    int `mapstructure:"offline_timeout"`    // 超过该时间未联系视为离线(秒)
+	LongPollTimeout   int `mapstructure:"long_poll_timeout"`  // 领取作业长轮询等待时间(秒)，需小于HTTP写超时
+	MaxReassignments  int `mapstructure:"max_reassignments"`  // 执行器离线后作业最多重新分配次数
+}
+
+// SecretsConfig 密钥加密配置
+type SecretsConfig struct {
+	KEKProvider  string `mapstructure:"kek_provider"`   // 主密钥(KEK)提供方: local
+	LocalKeyFile string `mapstructure:"local_key_file"` // local 提供方的主密钥文件，每行 "<密钥ID>:<base64密钥>"，最后一行为当前密钥
+}
+
+// SchedulerConfig 定时触发配置
+type SchedulerConfig struct {
+	Enabled      bool `mapstructure:"enabled"`       // 是否在本实例运行定时触发，多副本同时开启也不会重复触发
+	Interval     int  `mapstructure:"interval"`      // 检查到期定时触发的间隔(秒)
+	MisfireGrace int  `mapstructure:"misfire_grace"` // skip 策略下，延迟不超过该时间的触发仍然执行(秒)
+	MaxCatchUp   int  `mapstructure:"max_catch_up"`  // catch_up 策略下单次最多补跑的错过次数，更早的跳过
+}
+
+// ApprovalConfig 部署审批配置
+type ApprovalConfig struct {
+	DefaultTimeout int `mapstructure:"default_timeout"` // 环境未配置审批超时时的默认值(秒)
+	CheckInterval  int `mapstructure:"check_interval"`  // 检查审批超时和等待时间的间隔(秒)
+}
+
+// QueueConfig 运行队列配置，全局并发上限使用 tekton.max_concurrent_runs
+type QueueConfig struct {
+	MaxRunsPerTenant  int `mapstructure:"max_runs_per_tenant"`  // 每个租户同时执行的运行数上限，0 表示不限制
+	MaxRunsPerProject int `mapstructure:"max_runs_per_project"` // 每个项目同时执行的运行数上限，0 表示不限制
+	DispatchInterval  int `mapstructure:"dispatch_interval"`    // 定期检查队列的间隔(秒)，运行结束时也会立即出队
+}
+
 // SMTPConfig SMTP邮件配置
 type SMTPConfig struct {
 	Host     string `mapstructure:"host"`
@@ -219,6 +281,10 @@ func setDefaults() {
 	viper.SetDefault("storage.type", "local")
 	viper.SetDefault("storage.local_path", "/data/cicd")
 	viper.SetDefault("storage.retention_days", 30)
+	viper.SetDefault("storage.artifact_retention_days", 30)
+	viper.SetDefault("storage.artifact_max_size_mb", 1024)
+	viper.SetDefault("storage.s3.region", "us-east-1")
+	viper.SetDefault("storage.s3.use_ssl", true)
 
 	// 缓存设置
 	viper.SetDefault("cache.type", "local")
@@ -239,6 +305,45 @@ func setDefaults() {
 
 	// 通知设置
 	viper.SetDefault("notification.enabled_channels", []string{})
+
+	// Git网关设置
+	viper.SetDefault("git_gateway.base_url", "http://git-gateway-service:8004")
+	viper.SetDefault("git_gateway.timeout", 10)
+	viper.SetDefault("git_gateway.definition_file", ".axiom-ci.yml")
+	viper.SetDefault("git_gateway.webhook_secret", "")
+
+	// 执行器设置
+	viper.SetDefault("executor.type", "tekton")
+	viper.SetDefault("executor.work_dir", "/data/cicd/workspaces")
+	viper.SetDefault("executor.container_runtime", "auto")
+	viper.SetDefault("executor.allow_process_mode", false)
+	viper.SetDefault("executor.max_parallel_tasks", 4)
+	viper.SetDefault("executor.keep_workspace", false)
+
+	// 自托管执行器设置
+	viper.SetDefault("runner.heartbeat_interval", 15)
+	viper.SetDefault("runner.offline_timeout", 90)
+	viper.SetDefault("runner.long_poll_timeout", 25)
+	viper.SetDefault("runner.max_reassignments", 2)
+
+	// 密钥加密默认配置
+	viper.SetDefault("secrets.kek_provider", "local")
+	viper.SetDefault("secrets.local_key_file", "/data/cicd/secrets/kek.keys")
+
+	// 定时触发设置
+	viper.SetDefault("scheduler.enabled", true)
+	viper.SetDefault("scheduler.interval", 15)
+	viper.SetDefault("scheduler.misfire_grace", 300)
+	viper.SetDefault("scheduler.max_catch_up", 10)
+
+	// 部署审批设置
+	viper.SetDefault("approval.default_timeout", 86400)
+	viper.SetDefault("approval.check_interval", 30)
+
+	// 运行队列设置
+	viper.SetDefault("queue.max_runs_per_tenant", 0)
+	viper.SetDefault("queue.max_runs_per_project", 0)
+	viper.SetDefault("queue.dispatch_interval", 10)
 }
 
 // validateConfig 验证配置
@@ -259,10 +364,50 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("Kubernetes命名空间不能为空")
 	}
 
+	if config.Executor.Type != "tekton" && config.Executor.Type != "local" && config.Executor.Type != "runner" {
+		return fmt.Errorf("不支持的执行器类型: %s", config.Executor.Type)
+	}
+
+	if config.Executor.Type == "local" && config.Executor.WorkDir == "" {
+		return fmt.Errorf("本地执行器工作空间目录不能为空")
+	}
+
+	if config.Executor.AllowProcessMode && !config.IsDevelopment() {
+		return fmt.Errorf("本地进程模式只能在开发环境启用")
+	}
+
 	if config.Storage.Type == "local" && config.Storage.LocalPath == "" {
 		return fmt.Errorf("本地存储路径不能为空")
 	}
 
+	if config.Storage.Type == "s3" && (config.Storage.S3Config.Endpoint == "" || config.Storage.S3Config.Bucket == "") {
+		return fmt.Errorf("S3存储的endpoint和bucket不能为空")
+	}
+
+	if config.Secrets.KEKProvider != "local" {
+		return fmt.Errorf("不支持的主密钥提供方: %s", config.Secrets.KEKProvider)
+	}
+
+	if config.Secrets.KEKProvider == "local" && config.Secrets.LocalKeyFile == "" {
+		return fmt.Errorf("主密钥文件路径不能为空")
+	}
+
+	if config.Scheduler.Enabled && config.Scheduler.Interval <= 0 {
+		return fmt.Errorf("定时触发检查间隔必须大于0")
+	}
+
+	if config.Approval.DefaultTimeout <= 0 || config.Approval.CheckInterval <= 0 {
+		return fmt.Errorf("审批超时和检查间隔必须大于0")
+	}
+
+	if config.Queue.MaxRunsPerTenant < 0 || config.Queue.MaxRunsPerProject < 0 {
+		return fmt.Errorf("租户和项目并发运行上限不能为负数")
+	}
+
+	if config.Queue.DispatchInterval <= 0 {
+		return fmt.Errorf("运行队列检查间隔必须大于0")
+	}
+
 	return nil
 }
 
@@ -324,6 +469,16 @@ func LoadFromEnv() *Config {
 			Type:          getEnv("STORAGE_TYPE", "local"),
 			LocalPath:     getEnv("STORAGE_LOCAL_PATH", "/data/cicd"),
 			RetentionDays: getEnvAsInt("STORAGE_RETENTION_DAYS", 30),
+			ArtifactRetentionDays: getEnvAsInt("STORAGE_ARTIFACT_RETENTION_DAYS", 30),
+			ArtifactMaxSizeMB:     getEnvAsInt("STORAGE_ARTIFACT_MAX_SIZE_MB", 1024),
+			S3Config: S3Config{
+				Endpoint:        getEnv("S3_ENDPOINT", ""),
+				Region:          getEnv("S3_REGION", "us-east-1"),
+				Bucket:          getEnv("S3_BUCKET", ""),
+				AccessKeyID:     getEnv("S3_ACCESS_KEY_ID", ""),
+				SecretAccessKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
+				UseSSL:          getEnvAsBool("S3_USE_SSL", true),
+			},
 		},
 		Cache: CacheConfig{
 			Type:            getEnv("CACHE_TYPE", "local"),
@@ -332,6 +487,45 @@ func LoadFromEnv() *Config {
 			TTLHours:        getEnvAsInt("CACHE_TTL_HOURS", 168),
 			CleanupInterval: getEnvAsInt("CACHE_CLEANUP_INTERVAL", 60),
 		},
+		GitGateway: GitGatewayConfig{
+			BaseURL:        getEnv("GIT_GATEWAY_BASE_URL", "http://git-gateway-service:8004"),
+			Timeout:        getEnvAsInt("GIT_GATEWAY_TIMEOUT", 10),
+			DefinitionFile: getEnv("GIT_GATEWAY_DEFINITION_FILE", ".axiom-ci.yml"),
+			WebhookSecret:  getEnv("GIT_GATEWAY_WEBHOOK_SECRET", ""),
+		},
+		Executor: ExecutorConfig{
+			Type:             getEnv("EXECUTOR_TYPE", "tekton"),
+			WorkDir:          getEnv("EXECUTOR_WORK_DIR", "/data/cicd/workspaces"),
+			ContainerRuntime: getEnv("EXECUTOR_CONTAINER_RUNTIME", "auto"),
+			AllowProcessMode: getEnvAsBool("EXECUTOR_ALLOW_PROCESS_MODE", false),
+			MaxParallelTasks: getEnvAsInt("EXECUTOR_MAX_PARALLEL_TASKS", 4),
+			KeepWorkspace:    getEnvAsBool("EXECUTOR_KEEP_WORKSPACE", false),
+		},
+		Runner: RunnerConfig{
+			HeartbeatInterval: getEnvAsInt("RUNNER_HEARTBEAT_INTERVAL", 15),
+			OfflineTimeout:    getEnvAsInt("RUNNER_OFFLINE_TIMEOUT", 90),
+			LongPollTimeout:   getEnvAsInt("RUNNER_LONG_POLL_TIMEOUT", 25),
+			MaxReassignments:  getEnvAsInt("RUNNER_MAX_REASSIGNMENTS", 2),
+		},
+		Secrets: SecretsConfig{
+			KEKProvider:  getEnv("SECRETS_KEK_PROVIDER", "local"),
+			LocalKeyFile: getEnv("SECRETS_LOCAL_KEY_FILE", "/data/cicd/secrets/kek.keys"),
+		},
+		Scheduler: SchedulerConfig{
+			Enabled:      getEnvAsBool("SCHEDULER_ENABLED", true),
+			Interval:     getEnvAsInt("SCHEDULER_INTERVAL", 15),
+			MisfireGrace: getEnvAsInt("SCHEDULER_MISFIRE_GRACE", 300),
+			MaxCatchUp:   getEnvAsInt("SCHEDULER_MAX_CATCH_UP", 10),
+		},
+		Approval: ApprovalConfig{
+			DefaultTimeout: getEnvAsInt("APPROVAL_DEFAULT_TIMEOUT", 86400),
+			CheckInterval:  getEnvAsInt("APPROVAL_CHECK_INTERVAL", 30),
+		},
+		Queue: QueueConfig{
+			MaxRunsPerTenant:  getEnvAsInt("QUEUE_MAX_RUNS_PER_TENANT", 0),
+			MaxRunsPerProject: getEnvAsInt("QUEUE_MAX_RUNS_PER_PROJECT", 0),
+			DispatchInterval:  getEnvAsInt("QUEUE_DISPATCH_INTERVAL", 10),
+		},
 	}
 }
 
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"cicd-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ApprovalHandler struct {
+	approvalService services.ApprovalService
+}
+
+func NewApprovalHandler(approvalService services.ApprovalService) *ApprovalHandler {
+	return &ApprovalHandler{
+		approvalService: approvalService,
+	}
+}
+
+// ListPendingApprovals 获取当前用户的待审批列表
+// @Summary 获取待审批列表
+// @Description 获取当前用户（按用户ID或租户角色匹配审批人）可以审批的部署，不包含已审批过的
+// @Tags approvals
+// @Produce json
+// @Success 200 {object} APIResponse{data=[]models.DeploymentApproval}
+// @Failure 401 {object} APIResponse
+// @Router /api/v1/approvals [get]
+func (h *ApprovalHandler) ListPendingApprovals(c *gin.Context) {
+	tenantID, actor, ok := approvalActor(c)
+	if !ok {
+		return
+	}
+
+	approvals, err := h.approvalService.ListPending(tenantID, actor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Message: "获取待审批列表失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    approvals,
+	})
+}
+
+// GetApproval 获取审批详情
+// @Summary 获取审批详情
+// @Description 获取部署审批及审批记录
+// @Tags approvals
+// @Produce json
+// @Param id path string true "审批ID"
+// @Success 200 {object} APIResponse{data=models.DeploymentApproval}
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/approvals/{id} [get]
+func (h *ApprovalHandler) GetApproval(c *gin.Context) {
+	tenantID, ok := contextUUID(c, "tenant_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, APIResponse{
+			Success: false,
+			Message: "缺少租户信息",
+		})
+		return
+	}
+	id, ok := h.approvalID(c)
+	if !ok {
+		return
+	}
+
+	approval, err := h.approvalService.GetByID(tenantID, id)
+	if err != nil {
+		c.JSON(approvalErrorStatus(err, http.StatusInternalServerError), APIResponse{
+			Success: false,
+			Message: "获取审批失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    approval,
+	})
+}
+
+// ListRunApprovals 获取流水线运行的审批列表
+// @Summary 获取运行的审批列表
+// @Description 获取流水线运行中部署任务的审批及审批记录
+// @Tags approvals
+// @Produce json
+// @Param id path string true "运行ID"
+// @Success 200 {object} APIResponse{data=[]models.DeploymentApproval}
+// @Failure 400 {object} APIResponse
+// @Router /api/v1/pipeline-runs/{id}/approvals [get]
+func (h *ApprovalHandler) ListRunApprovals(c *gin.Context) {
+	tenantID, ok := contextUUID(c, "tenant_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, APIResponse{
+			Success: false,
+			Message: "缺少租户信息",
+		})
+		return
+	}
+	runID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的运行ID",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	approvals, err := h.approvalService.ListByRun(tenantID, runID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Message: "获取审批列表失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    approvals,
+	})
+}
+
+// ApproveDeployment 批准部署
+// @Summary 批准部署
+// @Description 批准部署到受保护环境，批准数达到要求后（及等待时间结束后）任务开始执行
+// @Tags approvals
+// @Accept json
+// @Produce json
+// @Param id path string true "审批ID"
+// @Param decision body services.ApprovalDecisionRequest false "审批意见"
+// @Success 200 {object} APIResponse{data=models.DeploymentApproval}
+// @Failure 403 {object} APIResponse
+// @Failure 409 {object} APIResponse
+// @Router /api/v1/approvals/{id}/approve [post]
+func (h *ApprovalHandler) ApproveDeployment(c *gin.Context) {
+	h.decide(c, "approved", "部署已批准")
+}
+
+// RejectDeployment 拒绝部署
+// @Summary 拒绝部署
+// @Description 拒绝部署到受保护环境，任务失败
+// @Tags approvals
+// @Accept json
+// @Produce json
+// @Param id path string true "审批ID"
+// @Param decision body services.ApprovalDecisionRequest false "审批意见"
+// @Success 200 {object} APIResponse{data=models.DeploymentApproval}
+// @Failure 403 {object} APIResponse
+// @Failure 409 {object} APIResponse
+// @Router /api/v1/approvals/{id}/reject [post]
+func (h *ApprovalHandler) RejectDeployment(c *gin.Context) {
+	h.decide(c, "rejected", "部署已拒绝")
+}
+
+// decide 记录当前用户的审批结果
+func (h *ApprovalHandler) decide(c *gin.Context, decision, message string) {
+	tenantID, actor, ok := approvalActor(c)
+	if !ok {
+		return
+	}
+	id, ok := h.approvalID(c)
+	if !ok {
+		return
+	}
+
+	var req services.ApprovalDecisionRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, APIResponse{
+				Success: false,
+				Message: "请求参数错误",
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
+	approval, err := h.approvalService.Decide(tenantID, id, actor, decision, req.Comment)
+	if err != nil {
+		c.JSON(approvalErrorStatus(err, http.StatusInternalServerError), APIResponse{
+			Success: false,
+			Message: "审批失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: message,
+		Data:    approval,
+	})
+}
+
+// approvalActor 读取租户ID和当前用户，失败时已写入响应
+func approvalActor(c *gin.Context) (uuid.UUID, services.ApprovalActor, bool) {
+	tenantID, ok := contextUUID(c, "tenant_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, APIResponse{
+			Success: false,
+			Message: "缺少租户信息",
+		})
+		return uuid.Nil, services.ApprovalActor{}, false
+	}
+	userID, ok := contextUUID(c, "user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, APIResponse{
+			Success: false,
+			Message: "缺少用户信息",
+		})
+		return uuid.Nil, services.ApprovalActor{}, false
+	}
+	return tenantID, services.ApprovalActor{UserID: userID, Role: c.GetString("role")}, true
+}
+
+// approvalID 读取路径中的审批ID，失败时已写入响应
+func (h *ApprovalHandler) approvalID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的审批ID",
+			Error:   err.Error(),
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// approvalErrorStatus 审批错误对应的HTTP状态码，其他错误使用 fallback
+func approvalErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, services.ErrApprovalNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrApprovalForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrApprovalClosed), errors.Is(err, services.ErrApprovalDecided):
+		return http.StatusConflict
+	default:
+		return fallback
+	}
+}
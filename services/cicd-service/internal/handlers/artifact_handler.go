@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+
+	"cicd-service/internal/models"
+	"cicd-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ArtifactHandler struct {
+	artifactService services.ArtifactService
+}
+
+func NewArtifactHandler(artifactService services.ArtifactService) *ArtifactHandler {
+	return &ArtifactHandler{
+		artifactService: artifactService,
+	}
+}
+
+// ListArtifacts 获取流水线运行的构建产物
+// @Summary 获取构建产物列表
+// @Description 列出流水线运行产出的全部产物文件及其SHA-256
+// @Tags pipeline-runs
+// @Produce json
+// @Param id path string true "运行ID"
+// @Success 200 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/pipeline-runs/{id}/artifacts [get]
+func (h *ArtifactHandler) ListArtifacts(c *gin.Context) {
+	tenantID, runID, ok := h.tenantAndID(c)
+	if !ok {
+		return
+	}
+
+	artifacts, err := h.artifactService.ListByRun(tenantID, runID)
+	if err != nil {
+		c.JSON(artifactErrorStatus(err), APIResponse{
+			Success: false,
+			Message: "获取构建产物失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    artifactListData(artifacts),
+	})
+}
+
+// DownloadArtifact 下载单个构建产物
+// @Summary 下载构建产物
+// @Description 下载产物文件，响应头 X-Checksum-SHA256 和 Digest 为内容哈希，ETag 为SHA-256
+// @Tags pipeline-runs
+// @Produce octet-stream
+// @Param id path string true "运行ID"
+// @Param artifact_id path string true "产物ID"
+// @Success 200 {file} file "产物内容"
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/pipeline-runs/{id}/artifacts/{artifact_id}/download [get]
+func (h *ArtifactHandler) DownloadArtifact(c *gin.Context) {
+	tenantID, runID, ok := h.tenantAndID(c)
+	if !ok {
+		return
+	}
+	artifactID, err := uuid.Parse(c.Param("artifact_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的产物ID",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	artifact, err := h.artifactService.GetByRun(tenantID, runID, artifactID)
+	if err != nil {
+		c.JSON(artifactErrorStatus(err), APIResponse{
+			Success: false,
+			Message: "获取构建产物失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	etag := `"` + artifact.SHA256 + `"`
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	reader, err := h.artifactService.Open(c.Request.Context(), artifact)
+	if err != nil {
+		c.JSON(artifactErrorStatus(err), APIResponse{
+			Success: false,
+			Message: "读取构建产物失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+	defer reader.Close()
+
+	clearWriteDeadline(c)
+	c.DataFromReader(http.StatusOK, artifact.Size, "application/octet-stream", reader, artifactHeaders(artifact))
+}
+
+// DownloadArtifactArchive 打包下载流水线运行的构建产物
+// @Summary 打包下载构建产物
+// @Description 以zip格式下载运行的产物，按任务名分目录，附带 SHA256SUMS 校验文件
+// @Tags pipeline-runs
+// @Produce application/zip
+// @Param id path string true "运行ID"
+// @Param task query string false "只下载该任务的产物"
+// @Param name query string false "只下载该名称的产物"
+// @Success 200 {file} file "zip文件"
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/pipeline-runs/{id}/artifacts/archive [get]
+func (h *ArtifactHandler) DownloadArtifactArchive(c *gin.Context) {
+	tenantID, runID, ok := h.tenantAndID(c)
+	if !ok {
+		return
+	}
+
+	artifacts, err := h.artifactService.ListByRun(tenantID, runID)
+	if err != nil {
+		c.JSON(artifactErrorStatus(err), APIResponse{
+			Success: false,
+			Message: "获取构建产物失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	task, name := c.Query("task"), c.Query("name")
+	selected := make([]models.Artifact, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		if (task == "" || artifact.TaskName == task) && (name == "" || artifact.Name == name) {
+			selected = append(selected, artifact)
+		}
+	}
+	if len(selected) == 0 {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Message: "没有可下载的构建产物",
+		})
+		return
+	}
+
+	clearWriteDeadline(c)
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="artifacts-%s.zip"`, runID.String()[:8]))
+	c.Status(http.StatusOK)
+
+	// 响应已开始写入，之后的错误只能中断输出并记录日志
+	archive := zip.NewWriter(c.Writer)
+	var checksums []byte
+	for i := range selected {
+		artifact := &selected[i]
+		entryName := path.Join(logFileName(artifact.TaskName), artifact.Path)
+		if err := h.writeArchiveEntry(c, archive, entryName, artifact); err != nil {
+			log.Printf("⚠️ 打包构建产物失败 %s: %v", artifact.ID, err)
+			return
+		}
+		checksums = append(checksums, fmt.Sprintf("%s  %s\n", artifact.SHA256, entryName)...)
+	}
+
+	entry, err := archive.Create("SHA256SUMS")
+	if err == nil {
+		_, err = entry.Write(checksums)
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		log.Printf("⚠️ 打包构建产物失败 %s: %v", runID, err)
+	}
+}
+
+// writeArchiveEntry 写入单个产物到zip
+func (h *ArtifactHandler) writeArchiveEntry(c *gin.Context, archive *zip.Writer, name string, artifact *models.Artifact) error {
+	reader, err := h.artifactService.Open(c.Request.Context(), artifact)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	writer, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: artifact.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, reader)
+	return err
+}
+
+// DeleteArtifacts 删除流水线运行的构建产物
+// @Summary 删除构建产物
+// @Description 删除已结束的流水线运行的全部产物
+// @Tags pipeline-runs
+// @Produce json
+// @Param id path string true "运行ID"
+// @Success 200 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 409 {object} APIResponse
+// @Router /api/v1/pipeline-runs/{id}/artifacts [delete]
+func (h *ArtifactHandler) DeleteArtifacts(c *gin.Context) {
+	tenantID, runID, ok := h.tenantAndID(c)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.artifactService.DeleteByRun(tenantID, runID)
+	if err != nil {
+		c.JSON(artifactErrorStatus(err), APIResponse{
+			Success: false,
+			Message: "删除构建产物失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "构建产物已删除",
+		Data:    gin.H{"deleted": deleted},
+	})
+}
+
+// GetLatestArtifacts 获取流水线最近一次成功运行的构建产物
+// @Summary 获取最新构建产物
+// @Description 获取流水线最近一次成功且产物未过期的运行的产物，可按分支和任务过滤
+// @Tags pipelines
+// @Produce json
+// @Param id path string true "流水线ID"
+// @Param branch query string false "分支"
+// @Param task query string false "任务名"
+// @Success 200 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/pipelines/{id}/artifacts/latest [get]
+func (h *ArtifactHandler) GetLatestArtifacts(c *gin.Context) {
+	tenantID, pipelineID, ok := h.tenantAndID(c)
+	if !ok {
+		return
+	}
+
+	run, artifacts, err := h.artifactService.LatestForPipeline(tenantID, pipelineID, c.Query("branch"), c.Query("task"))
+	if err != nil {
+		c.JSON(artifactErrorStatus(err), APIResponse{
+			Success: false,
+			Message: "获取构建产物失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	data := artifactListData(artifacts)
+	data["pipeline_run"] = gin.H{
+		"id":          run.ID,
+		"run_number":  run.RunNumber,
+		"branch":      run.Branch,
+		"commit_sha":  run.CommitSHA,
+		"finished_at": run.FinishedAt,
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// tenantAndID 读取租户ID和路径中的资源ID，失败时已写入响应
+func (h *ArtifactHandler) tenantAndID(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	tenantID, ok := contextUUID(c, "tenant_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, APIResponse{
+			Success: false,
+			Message: "缺少租户信息",
+		})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的ID",
+			Error:   err.Error(),
+		})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return tenantID, id, true
+}
+
+// artifactListData 产物列表响应数据
+func artifactListData(artifacts []models.Artifact) gin.H {
+	var totalSize int64
+	for _, artifact := range artifacts {
+		totalSize += artifact.Size
+	}
+	return gin.H{
+		"artifacts":  artifacts,
+		"total":      len(artifacts),
+		"total_size": totalSize,
+	}
+}
+
+// artifactHeaders 产物下载响应头
+func artifactHeaders(artifact *models.Artifact) map[string]string {
+	headers := map[string]string{
+		"Content-Disposition": fmt.Sprintf(`attachment; filename="%s"`, logFileName(path.Base(artifact.Path))),
+		"ETag":                `"` + artifact.SHA256 + `"`,
+		"X-Checksum-SHA256":   artifact.SHA256,
+		"X-Artifact-Size":     strconv.FormatInt(artifact.Size, 10),
+	}
+	if sum, err := hex.DecodeString(artifact.SHA256); err == nil {
+		headers["Digest"] = "sha-256=" + base64.StdEncoding.EncodeToString(sum)
+	}
+	return headers
+}
+
+// artifactErrorStatus 产物错误对应的HTTP状态码
+func artifactErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrArtifactNotFound), errors.Is(err, services.ErrJobArtifactNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrArtifactRunActive):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrArtifactTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, services.ErrArtifactChecksumMismatch), errors.Is(err, services.ErrJobArtifactNotDeclared):
+		return http.StatusUnprocessableEntity
+	default:
+		return jobErrorStatus(err)
+	}
+}
@@ -69,7 +69,7 @@ func (h *CacheHandler) StoreCache(c *gin.Context) {
 // @Param project_id query string true "项目ID"
 // @Success 200 {object} APIResponse{data=models.BuildCache}
 // @Failure 404 {object} APIResponse
-// @Router /api/v1/cache/{key} [get]
+// @Router /api/v1/cache/by-key/{key} [get]
 func (h *CacheHandler) RetrieveCache(c *gin.Context) {
 	key := c.Param("key")
 	if key == "" {
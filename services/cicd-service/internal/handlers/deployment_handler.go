@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"cicd-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type DeploymentHandler struct {
+	deploymentService services.DeploymentService
+}
+
+func NewDeploymentHandler(deploymentService services.DeploymentService) *DeploymentHandler {
+	return &DeploymentHandler{
+		deploymentService: deploymentService,
+	}
+}
+
+// ListEnvironmentDeployments 获取环境的部署历史
+// @Summary 获取部署历史
+// @Description 分页获取环境的部署记录（提交、产物、运行、触发人和状态），按开始时间倒序
+// @Tags deployments
+// @Produce json
+// @Param project_id path string true "项目ID"
+// @Param id path string true "环境ID"
+// @Param status query string false "状态筛选"
+// @Param page query int false "页码" default(1)
+// @Param limit query int false "每页数量" default(20)
+// @Success 200 {object} APIResponse{data=PagedResponse}
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/projects/{project_id}/environments/{id}/deployments [get]
+func (h *DeploymentHandler) ListEnvironmentDeployments(c *gin.Context) {
+	tenantID, projectID, ok := tenantAndProject(c)
+	if !ok {
+		return
+	}
+	environmentID, ok := h.pathID(c, "无效的环境ID")
+	if !ok {
+		return
+	}
+
+	req := &services.ListDeploymentsRequest{
+		Page:  1,
+		Limit: 20,
+	}
+	if status := c.Query("status"); status != "" {
+		req.Status = &status
+	}
+	if pageStr := c.Query("page"); pageStr != "" {
+		if page, err := strconv.Atoi(pageStr); err == nil && page > 0 {
+			req.Page = page
+		}
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 && limit <= 100 {
+			req.Limit = limit
+		}
+	}
+
+	deployments, total, err := h.deploymentService.ListByEnvironment(tenantID, projectID, environmentID, req)
+	if err != nil {
+		c.JSON(deploymentErrorStatus(err, http.StatusInternalServerError), APIResponse{
+			Success: false,
+			Message: "获取部署历史失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data: PagedResponse{
+			Items: deployments,
+			Pagination: Pagination{
+				Page:  req.Page,
+				Limit: req.Limit,
+				Total: total,
+			},
+		},
+	})
+}
+
+// GetCurrentDeployment 获取环境当前的部署
+// @Summary 获取环境当前部署
+// @Description 获取环境最近一次成功的部署，即当前运行的版本
+// @Tags deployments
+// @Produce json
+// @Param project_id path string true "项目ID"
+// @Param id path string true "环境ID"
+// @Success 200 {object} APIResponse{data=models.Deployment}
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/projects/{project_id}/environments/{id}/deployments/current [get]
+func (h *DeploymentHandler) GetCurrentDeployment(c *gin.Context) {
+	tenantID, projectID, ok := tenantAndProject(c)
+	if !ok {
+		return
+	}
+	environmentID, ok := h.pathID(c, "无效的环境ID")
+	if !ok {
+		return
+	}
+
+	deployment, err := h.deploymentService.Current(tenantID, projectID, environmentID)
+	if err != nil {
+		c.JSON(deploymentErrorStatus(err, http.StatusInternalServerError), APIResponse{
+			Success: false,
+			Message: "获取当前部署失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    deployment,
+	})
+}
+
+// ListCurrentDeployments 获取项目各环境当前的部署
+// @Summary 获取项目各环境当前部署
+// @Description 获取项目每个环境当前运行的版本，没有成功部署的环境 deployment 为空
+// @Tags deployments
+// @Produce json
+// @Param project_id path string true "项目ID"
+// @Success 200 {object} APIResponse{data=[]services.EnvironmentDeployment}
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/projects/{project_id}/deployments/current [get]
+func (h *DeploymentHandler) ListCurrentDeployments(c *gin.Context) {
+	tenantID, projectID, ok := tenantAndProject(c)
+	if !ok {
+		return
+	}
+
+	deployments, err := h.deploymentService.CurrentByProject(tenantID, projectID)
+	if err != nil {
+		c.JSON(deploymentErrorStatus(err, http.StatusInternalServerError), APIResponse{
+			Success: false,
+			Message: "获取当前部署失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    deployments,
+	})
+}
+
+// GetDeployment 获取部署详情
+// @Summary 获取部署详情
+// @Description 获取部署记录及其使用的产物
+// @Tags deployments
+// @Produce json
+// @Param id path string true "部署ID"
+// @Success 200 {object} APIResponse{data=models.Deployment}
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/deployments/{id} [get]
+func (h *DeploymentHandler) GetDeployment(c *gin.Context) {
+	tenantID, ok := contextUUID(c, "tenant_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, APIResponse{
+			Success: false,
+			Message: "缺少租户信息",
+		})
+		return
+	}
+	id, ok := h.pathID(c, "无效的部署ID")
+	if !ok {
+		return
+	}
+
+	deployment, err := h.deploymentService.GetByID(tenantID, id)
+	if err != nil {
+		c.JSON(deploymentErrorStatus(err, http.StatusInternalServerError), APIResponse{
+			Success: false,
+			Message: "获取部署记录失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    deployment,
+	})
+}
+
+// RollbackDeployment 回滚到指定部署
+// @Summary 回滚部署
+// @Description 以目标部署的提交创建流水线运行，只重新执行其部署任务并使用其产物；回滚同样需要满足环境保护规则
+// @Tags deployments
+// @Produce json
+// @Param id path string true "回滚到的部署ID"
+// @Success 201 {object} APIResponse{data=models.PipelineRun}
+// @Failure 404 {object} APIResponse
+// @Failure 409 {object} APIResponse
+// @Router /api/v1/deployments/{id}/rollback [post]
+func (h *DeploymentHandler) RollbackDeployment(c *gin.Context) {
+	tenantID, ok := contextUUID(c, "tenant_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, APIResponse{
+			Success: false,
+			Message: "缺少租户信息",
+		})
+		return
+	}
+	userID, ok := contextUUID(c, "user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, APIResponse{
+			Success: false,
+			Message: "缺少用户信息",
+		})
+		return
+	}
+	id, ok := h.pathID(c, "无效的部署ID")
+	if !ok {
+		return
+	}
+
+	run, err := h.deploymentService.Rollback(tenantID, id, userID)
+	if err != nil {
+		c.JSON(deploymentErrorStatus(err, http.StatusBadRequest), APIResponse{
+			Success: false,
+			Message: "回滚部署失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "回滚已开始",
+		Data:    run,
+	})
+}
+
+// pathID 读取路径中的ID，失败时已写入响应
+func (h *DeploymentHandler) pathID(c *gin.Context, message string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: message,
+			Error:   err.Error(),
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// deploymentErrorStatus 部署错误对应的HTTP状态码，其他错误使用 fallback
+func deploymentErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, services.ErrDeploymentNotFound), errors.Is(err, services.ErrNoCurrentDeployment),
+		errors.Is(err, services.ErrEnvironmentNotFound), errors.Is(err, services.ErrProjectNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrDeploymentNotRollbackable), errors.Is(err, services.ErrDeploymentArtifactsExpired):
+		return http.StatusConflict
+	default:
+		return fallback
+	}
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"cicd-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type EnvironmentHandler struct {
+	environmentService services.EnvironmentService
+}
+
+func NewEnvironmentHandler(environmentService services.EnvironmentService) *EnvironmentHandler {
+	return &EnvironmentHandler{
+		environmentService: environmentService,
+	}
+}
+
+// CreateEnvironment 创建部署环境
+// @Summary 创建环境
+// @Description 创建项目的部署环境，可配置审批人、等待时间、允许部署的分支等保护规则
+// @Tags environments
+// @Accept json
+// @Produce json
+// @Param project_id path string true "项目ID"
+// @Param environment body services.CreateEnvironmentRequest true "环境信息"
+// @Success 201 {object} APIResponse{data=models.Environment}
+// @Failure 400 {object} APIResponse
+// @Failure 409 {object} APIResponse
+// @Router /api/v1/projects/{project_id}/environments [post]
+func (h *EnvironmentHandler) CreateEnvironment(c *gin.Context) {
+	tenantID, projectID, ok := tenantAndProject(c)
+	if !ok {
+		return
+	}
+
+	var req services.CreateEnvironmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.ProjectID = projectID
+	req.CreatedBy, _ = contextUUID(c, "user_id")
+
+	environment, err := h.environmentService.Create(tenantID, &req)
+	if err != nil {
+		c.JSON(environmentErrorStatus(err, http.StatusBadRequest), APIResponse{
+			Success: false,
+			Message: "创建环境失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "环境创建成功",
+		Data:    environment,
+	})
+}
+
+// ListEnvironments 获取项目环境列表
+// @Summary 获取环境列表
+// @Description 获取项目的部署环境列表
+// @Tags environments
+// @Produce json
+// @Param project_id path string true "项目ID"
+// @Success 200 {object} APIResponse{data=[]models.Environment}
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/projects/{project_id}/environments [get]
+func (h *EnvironmentHandler) ListEnvironments(c *gin.Context) {
+	tenantID, projectID, ok := tenantAndProject(c)
+	if !ok {
+		return
+	}
+
+	environments, err := h.environmentService.List(tenantID, projectID)
+	if err != nil {
+		c.JSON(environmentErrorStatus(err, http.StatusInternalServerError), APIResponse{
+			Success: false,
+			Message: "获取环境列表失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    environments,
+	})
+}
+
+// GetEnvironment 获取环境详情
+// @Summary 获取环境详情
+// @Description 获取部署环境及其保护规则
+// @Tags environments
+// @Produce json
+// @Param project_id path string true "项目ID"
+// @Param id path string true "环境ID"
+// @Success 200 {object} APIResponse{data=models.Environment}
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/projects/{project_id}/environments/{id} [get]
+func (h *EnvironmentHandler) GetEnvironment(c *gin.Context) {
+	tenantID, projectID, ok := tenantAndProject(c)
+	if !ok {
+		return
+	}
+	id, ok := h.environmentID(c)
+	if !ok {
+		return
+	}
+
+	environment, err := h.environmentService.GetByID(tenantID, projectID, id)
+	if err != nil {
+		c.JSON(environmentErrorStatus(err, http.StatusInternalServerError), APIResponse{
+			Success: false,
+			Message: "获取环境失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    environment,
+	})
+}
+
+// UpdateEnvironment 更新环境
+// @Summary 更新环境
+// @Description 更新环境配置或整体替换保护规则，已创建的审批不受影响
+// @Tags environments
+// @Accept json
+// @Produce json
+// @Param project_id path string true "项目ID"
+// @Param id path string true "环境ID"
+// @Param environment body services.UpdateEnvironmentRequest true "更新内容"
+// @Success 200 {object} APIResponse{data=models.Environment}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/projects/{project_id}/environments/{id} [put]
+func (h *EnvironmentHandler) UpdateEnvironment(c *gin.Context) {
+	tenantID, projectID, ok := tenantAndProject(c)
+	if !ok {
+		return
+	}
+	id, ok := h.environmentID(c)
+	if !ok {
+		return
+	}
+
+	var req services.UpdateEnvironmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	environment, err := h.environmentService.Update(tenantID, projectID, id, &req)
+	if err != nil {
+		c.JSON(environmentErrorStatus(err, http.StatusBadRequest), APIResponse{
+			Success: false,
+			Message: "更新环境失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "环境更新成功",
+		Data:    environment,
+	})
+}
+
+// DeleteEnvironment 删除环境
+// @Summary 删除环境
+// @Description 删除部署环境，部署到该环境的任务运行时将失败
+// @Tags environments
+// @Produce json
+// @Param project_id path string true "项目ID"
+// @Param id path string true "环境ID"
+// @Success 200 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/projects/{project_id}/environments/{id} [delete]
+func (h *EnvironmentHandler) DeleteEnvironment(c *gin.Context) {
+	tenantID, projectID, ok := tenantAndProject(c)
+	if !ok {
+		return
+	}
+	id, ok := h.environmentID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.environmentService.Delete(tenantID, projectID, id); err != nil {
+		c.JSON(environmentErrorStatus(err, http.StatusInternalServerError), APIResponse{
+			Success: false,
+			Message: "删除环境失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "环境删除成功",
+	})
+}
+
+// environmentID 读取路径中的环境ID，失败时已写入响应
+func (h *EnvironmentHandler) environmentID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的环境ID",
+			Error:   err.Error(),
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// environmentErrorStatus 环境错误对应的HTTP状态码，其他错误使用 fallback
+func environmentErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, services.ErrEnvironmentNotFound), errors.Is(err, services.ErrProjectNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrEnvironmentExists):
+		return http.StatusConflict
+	default:
+		return fallback
+	}
+}
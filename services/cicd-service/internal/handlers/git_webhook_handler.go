@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"cicd-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxGitWebhookPayload Git事件载荷的最大字节数
+const maxGitWebhookPayload = 5 << 20
+
+type GitWebhookHandler struct {
+	gitWebhookService services.GitWebhookService
+}
+
+func NewGitWebhookHandler(gitWebhookService services.GitWebhookService) *GitWebhookHandler {
+	return &GitWebhookHandler{
+		gitWebhookService: gitWebhookService,
+	}
+}
+
+// HandleWebhook 接收仓库事件
+// @Summary 接收仓库事件
+// @Description 接收git-gateway-service投递的 push、pull_request 事件（X-Event-Type），校验 X-Hub-Signature-256 签名后
+// @Description 为触发器匹配的流水线创建运行。载荷中的 event、delivery_id 需与请求头一致。
+// @Description 单个流水线创建失败记录在结果中，不影响其他流水线
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param repository_id path string true "仓库ID"
+// @Param X-Event-Type header string true "事件类型"
+// @Param X-Delivery-ID header string true "投递ID，已处理的投递ID返回409"
+// @Param X-Hub-Signature-256 header string true "sha256=<HMAC-SHA256>"
+// @Success 200 {object} APIResponse{data=services.GitWebhookResult}
+// @Failure 400 {object} APIResponse
+// @Failure 401 {object} APIResponse
+// @Failure 409 {object} APIResponse
+// @Router /webhooks/git/{repository_id} [post]
+func (h *GitWebhookHandler) HandleWebhook(c *gin.Context) {
+	repositoryID, err := uuid.Parse(c.Param("repository_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的仓库ID",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxGitWebhookPayload+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "读取事件内容失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if len(body) > maxGitWebhookPayload {
+		c.JSON(http.StatusRequestEntityTooLarge, APIResponse{
+			Success: false,
+			Message: "事件内容过大",
+		})
+		return
+	}
+
+	// 签名覆盖原始请求体，必须在解析前校验
+	if err := h.gitWebhookService.VerifySignature(body, c.GetHeader("X-Hub-Signature-256")); err != nil {
+		status := http.StatusUnauthorized
+		if errors.Is(err, services.ErrWebhookSecretNotConfigured) {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, APIResponse{
+			Success: false,
+			Message: "事件签名校验失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	eventType := c.GetHeader("X-Event-Type")
+	if eventType == "" {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "缺少事件类型",
+		})
+		return
+	}
+
+	deliveryID := c.GetHeader("X-Delivery-ID")
+	if deliveryID == "" || len(deliveryID) > 64 {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "缺少或无效的投递ID",
+		})
+		return
+	}
+
+	result, err := h.gitWebhookService.HandleEvent(repositoryID, deliveryID, eventType, body)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrWebhookPayloadInvalid):
+			status = http.StatusBadRequest
+		case errors.Is(err, services.ErrWebhookReplayed):
+			status = http.StatusConflict
+		}
+		c.JSON(status, APIResponse{
+			Success: false,
+			Message: "处理事件失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "事件已处理",
+		Data:    result,
+	})
+}
@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cicd-service/internal/models"
+	"cicd-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// logStreamWriteTimeout 实时日志单次推送的写超时
+const logStreamWriteTimeout = 10 * time.Second
+
+// logStreamUpgrader WebSocket升级器，使用默认的同源检查
+var logStreamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 64 * 1024,
+}
+
+type LogHandler struct {
+	logService services.LogService
+}
+
+func NewLogHandler(logService services.LogService) *LogHandler {
+	return &LogHandler{
+		logService: logService,
+	}
+}
+
+// logStreamMessage WebSocket日志推送消息
+type logStreamMessage struct {
+	Type string `json:"type"` // log, reset, end, error
+	*services.LogChunk
+	Status string `json:"status,omitempty"` // 任务结束状态，type=end 时返回
+	Error  string `json:"error,omitempty"`
+}
+
+// GetTaskRunLogs 获取任务运行日志
+// @Summary 获取任务运行日志
+// @Description 从指定偏移量读取任务日志（纯文本），响应头 X-Log-Size 为当前日志长度，X-Log-Complete 表示任务是否已结束
+// @Tags pipeline-runs
+// @Produce plain
+// @Param id path string true "运行ID"
+// @Param task_run_id path string true "任务运行ID"
+// @Param offset query int false "起始偏移量" default(0)
+// @Param download query bool false "以附件形式下载"
+// @Success 200 {string} string "日志内容"
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/pipeline-runs/{id}/task-runs/{task_run_id}/logs [get]
+func (h *LogHandler) GetTaskRunLogs(c *gin.Context) {
+	taskRun, ok := h.taskRun(c)
+	if !ok {
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的日志偏移量",
+		})
+		return
+	}
+
+	size, err := h.logService.Size(taskRun.PipelineRunID, taskRun.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Message: "读取任务日志失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if offset > size {
+		offset = size
+	}
+
+	reader, err := h.logService.Open(c.Request.Context(), taskRun.PipelineRunID, taskRun.ID, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Message: "读取任务日志失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+	defer reader.Close()
+
+	headers := map[string]string{
+		"X-Log-Offset":   strconv.FormatInt(offset, 10),
+		"X-Log-Size":     strconv.FormatInt(size, 10),
+		"X-Log-Complete": strconv.FormatBool(services.IsTaskRunFinished(taskRun.Status)),
+	}
+	if c.Query("download") == "true" {
+		headers["Content-Disposition"] = fmt.Sprintf(`attachment; filename="%s-%s.log"`, logFileName(taskRun.Name), taskRun.ID.String()[:8])
+	}
+	clearWriteDeadline(c)
+	c.DataFromReader(http.StatusOK, size-offset, "text/plain; charset=utf-8", io.LimitReader(reader, size-offset), headers)
+}
+
+// DownloadPipelineRunLogs 下载流水线运行的完整日志
+// @Summary 下载流水线运行日志
+// @Description 按任务创建顺序合并运行中所有任务的日志
+// @Tags pipeline-runs
+// @Produce plain
+// @Param id path string true "运行ID"
+// @Success 200 {string} string "日志内容"
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/pipeline-runs/{id}/logs [get]
+func (h *LogHandler) DownloadPipelineRunLogs(c *gin.Context) {
+	tenantID, ok := contextUUID(c, "tenant_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, APIResponse{
+			Success: false,
+			Message: "缺少租户信息",
+		})
+		return
+	}
+	runID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的运行ID",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	run, err := h.logService.GetPipelineRun(tenantID, runID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Message: "流水线运行不存在",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	clearWriteDeadline(c)
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="run-%d-%s.log"`, run.RunNumber, run.ID.String()[:8]))
+	c.Status(http.StatusOK)
+
+	for _, taskRun := range run.TaskRuns {
+		fmt.Fprintf(c.Writer, "===== %s (%s) =====\n", taskRun.Name, taskRun.Status)
+		reader, err := h.logService.Open(c.Request.Context(), run.ID, taskRun.ID, 0)
+		if err != nil {
+			fmt.Fprintf(c.Writer, "读取日志失败: %v\n", err)
+			continue
+		}
+		_, err = io.Copy(c.Writer, reader)
+		reader.Close()
+		if err != nil {
+			return
+		}
+		fmt.Fprintln(c.Writer)
+	}
+}
+
+// StreamTaskRunLogs 实时跟踪任务运行日志
+// @Summary 实时跟踪任务运行日志
+// @Description 通过Server-Sent Events推送任务日志，请求头含 Upgrade: websocket 时使用WebSocket。
+// @Description SSE事件 id 为续读偏移量，断线重连时通过 Last-Event-ID 或 offset 参数从断点继续；
+// @Description 日志全部推送且任务结束后发送 end 事件
+// @Tags pipeline-runs
+// @Produce text/event-stream
+// @Param id path string true "运行ID"
+// @Param task_run_id path string true "任务运行ID"
+// @Param offset query int false "起始偏移量" default(0)
+// @Success 200 {string} string "日志事件流"
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/pipeline-runs/{id}/task-runs/{task_run_id}/logs/stream [get]
+func (h *LogHandler) StreamTaskRunLogs(c *gin.Context) {
+	taskRun, ok := h.taskRun(c)
+	if !ok {
+		return
+	}
+
+	offsetValue := c.DefaultQuery("offset", "0")
+	if lastEventID := c.GetHeader("Last-Event-ID"); lastEventID != "" {
+		offsetValue = lastEventID
+	}
+	offset, err := strconv.ParseInt(offsetValue, 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的日志偏移量",
+		})
+		return
+	}
+
+	if websocket.IsWebSocketUpgrade(c.Request) {
+		h.streamWebSocket(c, taskRun, offset)
+		return
+	}
+	h.streamSSE(c, taskRun, offset)
+}
+
+// streamSSE 以Server-Sent Events推送日志
+func (h *LogHandler) streamSSE(c *gin.Context, taskRun *models.TaskRun, offset int64) {
+	clearWriteDeadline(c)
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	writeEvent := func(event string, id *int64, data interface{}) error {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		var b strings.Builder
+		if id != nil {
+			fmt.Fprintf(&b, "id: %d\n", *id)
+		}
+		fmt.Fprintf(&b, "event: %s\ndata: %s\n\n", event, payload)
+		if _, err := io.WriteString(c.Writer, b.String()); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}
+
+	status, err := h.logService.Follow(c.Request.Context(), taskRun.PipelineRunID, taskRun.ID, offset, func(chunk *services.LogChunk) error {
+		switch {
+		case chunk.Reset:
+			return writeEvent("reset", &chunk.NextOffset, chunk)
+		case chunk.Content == "":
+			// 心跳注释，保持连接不被代理断开
+			if _, err := io.WriteString(c.Writer, ": heartbeat\n\n"); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+			return nil
+		default:
+			return writeEvent("log", &chunk.NextOffset, chunk)
+		}
+	})
+	if c.Request.Context().Err() != nil {
+		return
+	}
+	if err != nil {
+		writeEvent("error", nil, gin.H{"error": err.Error()})
+		return
+	}
+	writeEvent("end", nil, gin.H{"status": status})
+}
+
+// streamWebSocket 以WebSocket推送日志
+func (h *LogHandler) streamWebSocket(c *gin.Context, taskRun *models.TaskRun, offset int64) {
+	conn, err := logStreamUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// 升级失败时Upgrader已写入错误响应
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 读取客户端消息以处理控制帧，连接关闭时停止推送
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	write := func(message *logStreamMessage) error {
+		conn.SetWriteDeadline(time.Now().Add(logStreamWriteTimeout))
+		return conn.WriteJSON(message)
+	}
+
+	status, err := h.logService.Follow(ctx, taskRun.PipelineRunID, taskRun.ID, offset, func(chunk *services.LogChunk) error {
+		switch {
+		case chunk.Reset:
+			return write(&logStreamMessage{Type: "reset", LogChunk: chunk})
+		case chunk.Content == "":
+			return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(logStreamWriteTimeout))
+		default:
+			return write(&logStreamMessage{Type: "log", LogChunk: chunk})
+		}
+	})
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		write(&logStreamMessage{Type: "error", Error: err.Error()})
+	} else {
+		write(&logStreamMessage{Type: "end", Status: status})
+	}
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(logStreamWriteTimeout))
+}
+
+// taskRun 读取路径参数并获取租户下的任务运行，失败时已写入响应
+func (h *LogHandler) taskRun(c *gin.Context) (*models.TaskRun, bool) {
+	tenantID, ok := contextUUID(c, "tenant_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, APIResponse{
+			Success: false,
+			Message: "缺少租户信息",
+		})
+		return nil, false
+	}
+
+	runID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的运行ID",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	taskRunID, err := uuid.Parse(c.Param("task_run_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的任务运行ID",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+
+	taskRun, err := h.logService.GetTaskRun(tenantID, runID, taskRunID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Message: "任务运行不存在",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	return taskRun, true
+}
+
+// logFileName 将任务名转换为安全的文件名
+func logFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// clearWriteDeadline 取消服务器写超时，日志下载和实时跟踪可能持续较长时间
+func clearWriteDeadline(c *gin.Context) {
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+}
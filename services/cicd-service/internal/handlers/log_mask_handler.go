@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"cicd-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type LogMaskHandler struct {
+	logMaskService services.LogMaskService
+}
+
+func NewLogMaskHandler(logMaskService services.LogMaskService) *LogMaskHandler {
+	return &LogMaskHandler{
+		logMaskService: logMaskService,
+	}
+}
+
+// CreateLogMaskPattern 创建日志掩码规则
+// @Summary 创建日志掩码规则
+// @Description 为项目添加日志掩码规则（RE2正则），匹配的日志内容在存储前替换为 ***，只对之后写入的日志生效
+// @Tags log-masks
+// @Accept json
+// @Produce json
+// @Param project_id path string true "项目ID"
+// @Param pattern body services.CreateLogMaskPatternRequest true "掩码规则"
+// @Success 201 {object} APIResponse{data=models.LogMaskPattern}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/projects/{project_id}/log-masks [post]
+func (h *LogMaskHandler) CreateLogMaskPattern(c *gin.Context) {
+	tenantID, projectID, ok := tenantAndProject(c)
+	if !ok {
+		return
+	}
+
+	var req services.CreateLogMaskPatternRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.ProjectID = projectID
+	req.CreatedBy, _ = contextUUID(c, "user_id")
+
+	pattern, err := h.logMaskService.CreatePattern(tenantID, &req)
+	if err != nil {
+		c.JSON(logMaskErrorStatus(err, http.StatusBadRequest), APIResponse{
+			Success: false,
+			Message: "创建掩码规则失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "掩码规则创建成功",
+		Data:    pattern,
+	})
+}
+
+// ListLogMaskPatterns 获取日志掩码规则列表
+// @Summary 获取日志掩码规则列表
+// @Description 获取项目的日志掩码规则
+// @Tags log-masks
+// @Produce json
+// @Param project_id path string true "项目ID"
+// @Success 200 {object} APIResponse{data=[]models.LogMaskPattern}
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/projects/{project_id}/log-masks [get]
+func (h *LogMaskHandler) ListLogMaskPatterns(c *gin.Context) {
+	tenantID, projectID, ok := tenantAndProject(c)
+	if !ok {
+		return
+	}
+
+	patterns, err := h.logMaskService.ListPatterns(tenantID, projectID)
+	if err != nil {
+		c.JSON(logMaskErrorStatus(err, http.StatusInternalServerError), APIResponse{
+			Success: false,
+			Message: "获取掩码规则失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    patterns,
+	})
+}
+
+// DeleteLogMaskPattern 删除日志掩码规则
+// @Summary 删除日志掩码规则
+// @Description 删除项目的日志掩码规则，已写入的日志不受影响
+// @Tags log-masks
+// @Produce json
+// @Param project_id path string true "项目ID"
+// @Param id path string true "规则ID"
+// @Success 200 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/projects/{project_id}/log-masks/{id} [delete]
+func (h *LogMaskHandler) DeleteLogMaskPattern(c *gin.Context) {
+	tenantID, projectID, ok := tenantAndProject(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的规则ID",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := h.logMaskService.DeletePattern(tenantID, projectID, id); err != nil {
+		c.JSON(logMaskErrorStatus(err, http.StatusInternalServerError), APIResponse{
+			Success: false,
+			Message: "删除掩码规则失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "掩码规则删除成功",
+	})
+}
+
+// logMaskErrorStatus 掩码规则错误对应的HTTP状态码，其他错误使用 fallback
+func logMaskErrorStatus(err error, fallback int) int {
+	if errors.Is(err, services.ErrLogMaskPatternNotFound) || errors.Is(err, services.ErrProjectNotFound) {
+		return http.StatusNotFound
+	}
+	return fallback
+}
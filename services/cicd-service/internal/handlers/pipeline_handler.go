@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"encoding/json"
 	"net/http"
 	"strconv"
 	"time"
@@ -414,6 +413,163 @@ func (h *PipelineHandler) GetPipelineStatistics(c *gin.Context) {
 	})
 }
 
+// ImportPipeline 从仓库定义文件导入流水线
+// @Summary 从仓库定义文件导入流水线
+// @Description 读取仓库中的流水线定义文件（默认.axiom-ci.yml），创建或同步对应的流水线；之后每次运行使用构建提交中的定义
+// @Tags pipelines
+// @Accept json
+// @Produce json
+// @Param import_request body services.ImportPipelineRequest true "导入请求"
+// @Success 200 {object} APIResponse{data=models.Pipeline}
+// @Failure 400 {object} APIResponse
+// @Router /api/v1/pipelines/import [post]
+func (h *PipelineHandler) ImportPipeline(c *gin.Context) {
+	var req services.ImportPipelineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if userID, exists := c.Get("user_id"); exists {
+		if uid, ok := userID.(uuid.UUID); ok {
+			req.UserID = uid
+		}
+	}
+
+	pipeline, err := h.pipelineService.ImportFromRepository(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "导入流水线定义失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "流水线定义导入成功",
+		Data:    pipeline,
+	})
+}
+
+// ValidatePipelineDefinition 校验流水线定义
+// @Summary 校验流水线定义
+// @Description 校验YAML流水线定义内容，返回解析结果
+// @Tags pipelines
+// @Accept json
+// @Produce json
+// @Param definition body ValidateDefinitionRequest true "定义内容"
+// @Success 200 {object} APIResponse{data=services.PipelineDefinition}
+// @Failure 400 {object} APIResponse
+// @Router /api/v1/pipelines/validate-definition [post]
+func (h *PipelineHandler) ValidatePipelineDefinition(c *gin.Context) {
+	var req ValidateDefinitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	definition, err := services.ParsePipelineDefinition([]byte(req.Content))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "流水线定义无效",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "流水线定义有效",
+		Data:    definition,
+	})
+}
+
+// GetPipelinePlan 获取流水线执行计划
+// @Summary 获取流水线执行计划
+// @Description 按任务依赖计算执行阶段，并根据最近成功运行的任务时长估算关键路径和总时长
+// @Tags pipelines
+// @Produce json
+// @Param id path string true "流水线ID"
+// @Success 200 {object} APIResponse{data=services.ExecutionPlan}
+// @Failure 400 {object} APIResponse
+// @Router /api/v1/pipelines/{id}/plan [get]
+func (h *PipelineHandler) GetPipelinePlan(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的流水线ID",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	plan, err := h.pipelineService.Plan(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "计算执行计划失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    plan,
+	})
+}
+
+// DryRunPipeline 试运行流水线
+// @Summary 试运行流水线
+// @Description 按创建流水线的请求校验任务定义并返回执行计划，不创建流水线；
+// @Description 同项目已有同名流水线时参考其历史运行时长估算
+// @Tags pipelines
+// @Accept json
+// @Produce json
+// @Param pipeline body services.CreatePipelineRequest true "流水线信息"
+// @Success 200 {object} APIResponse{data=services.ExecutionPlan}
+// @Failure 400 {object} APIResponse
+// @Router /api/v1/pipelines/dry-run [post]
+func (h *PipelineHandler) DryRunPipeline(c *gin.Context) {
+	var req services.CreatePipelineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	plan, err := h.pipelineService.DryRun(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "流水线定义无效",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "流水线定义有效",
+		Data:    plan,
+	})
+}
+
 // GetPipelineByProject 获取项目的流水线列表
 // @Summary 获取项目的流水线列表
 // @Description 获取指定项目的所有流水线
@@ -455,6 +611,11 @@ type ClonePipelineRequest struct {
 	NewName string `json:"new_name" binding:"required" validate:"max=255"`
 }
 
+// ValidateDefinitionRequest 校验流水线定义请求
+type ValidateDefinitionRequest struct {
+	Content string `json:"content" binding:"required"` // YAML定义内容
+}
+
 // APIResponse 统一API响应格式
 type APIResponse struct {
 	Success   bool        `json:"success"`
@@ -298,12 +298,12 @@ func (h *PipelineRunHandler) RetryPipelineRun(c *gin.Context) {
 // @Description 获取指定流水线的运行历史记录
 // @Tags pipeline-runs
 // @Produce json
-// @Param pipeline_id path string true "流水线ID"
+// @Param id path string true "流水线ID"
 // @Param limit query int false "限制数量" default(10)
 // @Success 200 {object} APIResponse{data=[]models.PipelineRun}
-// @Router /api/v1/pipelines/{pipeline_id}/runs [get]
+// @Router /api/v1/pipelines/{id}/runs [get]
 func (h *PipelineRunHandler) GetPipelineRunsByPipeline(c *gin.Context) {
-	pipelineIDStr := c.Param("pipeline_id")
+	pipelineIDStr := c.Param("id")
 	pipelineID, err := uuid.Parse(pipelineIDStr)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, APIResponse{
@@ -402,12 +402,12 @@ func (h *PipelineRunHandler) GetPipelineRunStatistics(c *gin.Context) {
 // @Tags pipelines
 // @Accept json
 // @Produce json
-// @Param pipeline_id path string true "流水线ID"
+// @Param id path string true "流水线ID"
 // @Param trigger_request body TriggerPipelineRequest true "触发请求"
 // @Success 201 {object} APIResponse{data=models.PipelineRun}
-// @Router /api/v1/pipelines/{pipeline_id}/trigger [post]
+// @Router /api/v1/pipelines/{id}/trigger [post]
 func (h *PipelineRunHandler) TriggerPipelineByPipeline(c *gin.Context) {
-	pipelineIDStr := c.Param("pipeline_id")
+	pipelineIDStr := c.Param("id")
 	pipelineID, err := uuid.Parse(pipelineIDStr)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, APIResponse{
@@ -442,6 +442,16 @@ func (h *PipelineRunHandler) TriggerPipelineByPipeline(c *gin.Context) {
 		req.ScheduledAt = triggerReq.ScheduledAt
 	}
 
+	if triggerReq.CommitSHA != "" {
+		req.CommitSHA = &triggerReq.CommitSHA
+	}
+
+	if triggerReq.Branch != "" {
+		req.Branch = &triggerReq.Branch
+	}
+
+	req.Priority = triggerReq.Priority
+
 	// 从上下文获取用户ID
 	if userID, exists := c.Get("user_id"); exists {
 		if uid, ok := userID.(uuid.UUID); ok {
@@ -476,4 +486,7 @@ type TriggerPipelineRequest struct {
 	Parameters  map[string]interface{} `json:"parameters"`
 	Environment string                 `json:"environment"`
 	ScheduledAt *time.Time             `json:"scheduled_at"`
+	CommitSHA   string                 `json:"commit_sha"` // 构建的提交，定义文件维护的流水线从该提交读取定义
+	Branch      string                 `json:"branch"`
+	Priority    *int                   `json:"priority"` // 排队优先级(0-100)，缺省使用流水线配置
 }
\ No newline at end of file
@@ -0,0 +1,787 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cicd-service/internal/models"
+	"cicd-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxRunnerLogChunk 单次日志上传的最大字节数
+const maxRunnerLogChunk = 1 << 20
+
+type RunnerHandler struct {
+	runnerService services.RunnerService
+}
+
+func NewRunnerHandler(runnerService services.RunnerService) *RunnerHandler {
+	return &RunnerHandler{
+		runnerService: runnerService,
+	}
+}
+
+// CreateRegistrationToken 创建执行器注册令牌
+// @Summary 创建执行器注册令牌
+// @Description 创建租户级执行器注册令牌，明文令牌只返回一次
+// @Tags runners
+// @Accept json
+// @Produce json
+// @Param token body services.CreateRegistrationTokenRequest true "注册令牌请求"
+// @Success 201 {object} APIResponse{data=services.RegistrationTokenResult}
+// @Failure 400 {object} APIResponse
+// @Router /api/v1/runners/registration-tokens [post]
+func (h *RunnerHandler) CreateRegistrationToken(c *gin.Context) {
+	var req services.CreateRegistrationTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	tenantID, ok := contextUUID(c, "tenant_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, APIResponse{
+			Success: false,
+			Message: "缺少租户信息",
+		})
+		return
+	}
+	req.TenantID = tenantID
+	req.CreatedBy, _ = contextUUID(c, "user_id")
+
+	result, err := h.runnerService.CreateRegistrationToken(&req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Message: "创建注册令牌失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "注册令牌创建成功，请妥善保存，令牌不会再次显示",
+		Data:    result,
+	})
+}
+
+// ListRegistrationTokens 获取执行器注册令牌列表
+// @Summary 获取执行器注册令牌列表
+// @Tags runners
+// @Produce json
+// @Success 200 {object} APIResponse{data=[]models.RunnerRegistrationToken}
+// @Router /api/v1/runners/registration-tokens [get]
+func (h *RunnerHandler) ListRegistrationTokens(c *gin.Context) {
+	tenantID, ok := contextUUID(c, "tenant_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, APIResponse{
+			Success: false,
+			Message: "缺少租户信息",
+		})
+		return
+	}
+
+	tokens, err := h.runnerService.ListRegistrationTokens(tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Message: "获取注册令牌列表失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    tokens,
+	})
+}
+
+// RevokeRegistrationToken 吊销执行器注册令牌
+// @Summary 吊销执行器注册令牌
+// @Tags runners
+// @Produce json
+// @Param id path string true "注册令牌ID"
+// @Success 200 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/runners/registration-tokens/{id} [delete]
+func (h *RunnerHandler) RevokeRegistrationToken(c *gin.Context) {
+	tenantID, id, ok := h.tenantAndID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.runnerService.RevokeRegistrationToken(tenantID, id); err != nil {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Message: "吊销注册令牌失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "注册令牌已吊销",
+	})
+}
+
+// ListRunners 获取执行器列表
+// @Summary 获取执行器列表
+// @Tags runners
+// @Produce json
+// @Param status query string false "状态过滤(online/offline/disabled)"
+// @Success 200 {object} APIResponse{data=[]models.Runner}
+// @Router /api/v1/runners [get]
+func (h *RunnerHandler) ListRunners(c *gin.Context) {
+	tenantID, ok := contextUUID(c, "tenant_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, APIResponse{
+			Success: false,
+			Message: "缺少租户信息",
+		})
+		return
+	}
+
+	runners, err := h.runnerService.List(tenantID, c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Message: "获取执行器列表失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    runners,
+	})
+}
+
+// GetRunner 获取执行器详情
+// @Summary 获取执行器详情
+// @Tags runners
+// @Produce json
+// @Param id path string true "执行器ID"
+// @Success 200 {object} APIResponse{data=models.Runner}
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/runners/{id} [get]
+func (h *RunnerHandler) GetRunner(c *gin.Context) {
+	tenantID, id, ok := h.tenantAndID(c)
+	if !ok {
+		return
+	}
+
+	runner, err := h.runnerService.GetByID(tenantID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Message: "获取执行器失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    runner,
+	})
+}
+
+// UpdateRunner 更新执行器
+// @Summary 更新执行器
+// @Description 更新执行器名称、标签、并发数或禁用执行器
+// @Tags runners
+// @Accept json
+// @Produce json
+// @Param id path string true "执行器ID"
+// @Param runner body services.UpdateRunnerRequest true "更新请求"
+// @Success 200 {object} APIResponse{data=models.Runner}
+// @Failure 400 {object} APIResponse
+// @Router /api/v1/runners/{id} [put]
+func (h *RunnerHandler) UpdateRunner(c *gin.Context) {
+	tenantID, id, ok := h.tenantAndID(c)
+	if !ok {
+		return
+	}
+
+	var req services.UpdateRunnerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	runner, err := h.runnerService.Update(tenantID, id, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "更新执行器失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "执行器更新成功",
+		Data:    runner,
+	})
+}
+
+// DeleteRunner 删除执行器
+// @Summary 删除执行器
+// @Description 删除执行器，其正在执行的作业重新分配
+// @Tags runners
+// @Produce json
+// @Param id path string true "执行器ID"
+// @Success 200 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/runners/{id} [delete]
+func (h *RunnerHandler) DeleteRunner(c *gin.Context) {
+	tenantID, id, ok := h.tenantAndID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.runnerService.Delete(tenantID, id); err != nil {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Message: "删除执行器失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "执行器已删除",
+	})
+}
+
+// DrainRunner 排空执行器
+// @Summary 排空执行器
+// @Description 执行器不再领取新作业，当前作业执行完毕后可安全下线
+// @Tags runners
+// @Produce json
+// @Param id path string true "执行器ID"
+// @Success 200 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/runners/{id}/drain [post]
+func (h *RunnerHandler) DrainRunner(c *gin.Context) {
+	tenantID, id, ok := h.tenantAndID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.runnerService.Drain(tenantID, id); err != nil {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Message: "排空执行器失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "执行器排空中",
+	})
+}
+
+// ResumeRunner 恢复执行器
+// @Summary 恢复执行器
+// @Tags runners
+// @Produce json
+// @Param id path string true "执行器ID"
+// @Success 200 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/runners/{id}/resume [post]
+func (h *RunnerHandler) ResumeRunner(c *gin.Context) {
+	tenantID, id, ok := h.tenantAndID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.runnerService.Resume(tenantID, id); err != nil {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Message: "恢复执行器失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "执行器已恢复领取作业",
+	})
+}
+
+// Register 执行器注册
+// @Summary 执行器注册
+// @Description 使用注册令牌注册执行器，返回执行器认证令牌（只返回一次）
+// @Tags runner-api
+// @Accept json
+// @Produce json
+// @Param runner body services.RegisterRunnerRequest true "注册请求"
+// @Success 201 {object} APIResponse{data=services.RegisterRunnerResult}
+// @Failure 401 {object} APIResponse
+// @Router /runner-api/v1/register [post]
+func (h *RunnerHandler) Register(c *gin.Context) {
+	var req services.RegisterRunnerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+	clientIP := c.ClientIP()
+	req.IPAddress = &clientIP
+
+	result, err := h.runnerService.Register(&req)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, APIResponse{
+			Success: false,
+			Message: "执行器注册失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "执行器注册成功",
+		Data:    result,
+	})
+}
+
+// RunnerAuth 执行器令牌认证中间件
+func (h *RunnerHandler) RunnerAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		runner, err := h.runnerService.Authenticate(token)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, APIResponse{
+				Success: false,
+				Message: "执行器认证失败",
+				Error:   err.Error(),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("runner", runner)
+		c.Next()
+	}
+}
+
+// Heartbeat 执行器心跳
+// @Summary 执行器心跳
+// @Description 上报执行器存活和正在执行的作业，返回排空状态和需终止的作业
+// @Tags runner-api
+// @Accept json
+// @Produce json
+// @Param heartbeat body services.RunnerHeartbeatRequest true "心跳请求"
+// @Success 200 {object} APIResponse{data=services.RunnerHeartbeatResponse}
+// @Router /runner-api/v1/heartbeat [post]
+func (h *RunnerHandler) Heartbeat(c *gin.Context) {
+	var req services.RunnerHeartbeatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+	clientIP := c.ClientIP()
+	req.IPAddress = &clientIP
+
+	response, err := h.runnerService.Heartbeat(currentRunner(c), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Message: "心跳处理失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    response,
+	})
+}
+
+// Shutdown 执行器退出
+// @Summary 执行器退出
+// @Description 执行器正常退出，标记离线并释放未完成的作业
+// @Tags runner-api
+// @Produce json
+// @Success 200 {object} APIResponse
+// @Router /runner-api/v1/shutdown [post]
+func (h *RunnerHandler) Shutdown(c *gin.Context) {
+	if err := h.runnerService.Shutdown(currentRunner(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Message: "执行器退出处理失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "执行器已下线",
+	})
+}
+
+// RequestJob 领取作业
+// @Summary 领取作业
+// @Description 长轮询领取标签匹配的作业，等待超时无作业时返回204
+// @Tags runner-api
+// @Produce json
+// @Success 200 {object} APIResponse{data=services.RunnerJob}
+// @Success 204
+// @Router /runner-api/v1/jobs/request [post]
+func (h *RunnerHandler) RequestJob(c *gin.Context) {
+	job, err := h.runnerService.RequestJob(c.Request.Context(), currentRunner(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Message: "领取作业失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if job == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    job,
+	})
+}
+
+// AppendJobLog 上传作业日志
+// @Summary 上传作业日志
+// @Description 按偏移量追加日志，偏移量不一致时返回409及服务端已接收长度，作业已取消时返回410
+// @Tags runner-api
+// @Accept octet-stream
+// @Produce json
+// @Param id path string true "作业ID"
+// @Param offset query int true "本段日志的起始偏移量"
+// @Success 200 {object} APIResponse
+// @Failure 409 {object} APIResponse
+// @Failure 410 {object} APIResponse
+// @Router /runner-api/v1/jobs/{id}/logs [post]
+func (h *RunnerHandler) AppendJobLog(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的作业ID",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.Query("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的日志偏移量",
+		})
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxRunnerLogChunk+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "读取日志内容失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if len(data) > maxRunnerLogChunk {
+		c.JSON(http.StatusRequestEntityTooLarge, APIResponse{
+			Success: false,
+			Message: "单次上传的日志过大",
+		})
+		return
+	}
+
+	size, err := h.runnerService.AppendJobLog(currentRunner(c), jobID, offset, data)
+	if err != nil {
+		c.JSON(jobErrorStatus(err), APIResponse{
+			Success: false,
+			Message: "上传作业日志失败",
+			Data:    gin.H{"offset": size},
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    gin.H{"offset": size},
+	})
+}
+
+// ListJobArtifacts 获取作业依赖的产物
+// @Summary 获取作业依赖的产物
+// @Description 返回作业 artifacts_from 解析出的产物，执行器应在执行前下载到工作空间的 path 并校验SHA-256
+// @Tags runner-api
+// @Produce json
+// @Param id path string true "作业ID"
+// @Success 200 {object} APIResponse
+// @Failure 410 {object} APIResponse
+// @Router /runner-api/v1/jobs/{id}/artifacts [get]
+func (h *RunnerHandler) ListJobArtifacts(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的作业ID",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	artifacts, err := h.runnerService.ListJobArtifacts(currentRunner(c), jobID)
+	if err != nil {
+		c.JSON(artifactErrorStatus(err), APIResponse{
+			Success: false,
+			Message: "获取依赖产物失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    artifacts,
+	})
+}
+
+// DownloadJobArtifact 下载作业依赖的产物
+// @Summary 下载作业依赖的产物
+// @Description 只能下载作业依赖中的产物，响应头 X-Checksum-SHA256 为内容哈希
+// @Tags runner-api
+// @Produce octet-stream
+// @Param id path string true "作业ID"
+// @Param artifact_id path string true "产物ID"
+// @Success 200 {file} file "产物内容"
+// @Failure 404 {object} APIResponse
+// @Failure 410 {object} APIResponse
+// @Router /runner-api/v1/jobs/{id}/artifacts/{artifact_id} [get]
+func (h *RunnerHandler) DownloadJobArtifact(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的作业ID",
+			Error:   err.Error(),
+		})
+		return
+	}
+	artifactID, err := uuid.Parse(c.Param("artifact_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的产物ID",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	artifact, reader, err := h.runnerService.OpenJobArtifact(c.Request.Context(), currentRunner(c), jobID, artifactID)
+	if err != nil {
+		c.JSON(artifactErrorStatus(err), APIResponse{
+			Success: false,
+			Message: "下载依赖产物失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+	defer reader.Close()
+
+	clearWriteDeadline(c)
+	c.DataFromReader(http.StatusOK, artifact.Size, "application/octet-stream", reader, artifactHeaders(artifact))
+}
+
+// UploadJobArtifact 上传作业产物
+// @Summary 上传作业产物
+// @Description 上传单个产物文件，path 必须匹配作业声明的产物路径；可通过 X-Checksum-SHA256 头声明内容哈希，服务端校验不一致时返回422
+// @Tags runner-api
+// @Accept octet-stream
+// @Produce json
+// @Param id path string true "作业ID"
+// @Param path query string true "相对工作空间的文件路径"
+// @Success 201 {object} APIResponse
+// @Failure 410 {object} APIResponse
+// @Failure 413 {object} APIResponse
+// @Failure 422 {object} APIResponse
+// @Router /runner-api/v1/jobs/{id}/artifacts [post]
+func (h *RunnerHandler) UploadJobArtifact(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的作业ID",
+			Error:   err.Error(),
+		})
+		return
+	}
+	filePath := c.Query("path")
+	if filePath == "" {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "缺少产物路径",
+		})
+		return
+	}
+
+	// 产物可能较大，取消服务器读写超时
+	controller := http.NewResponseController(c.Writer)
+	controller.SetReadDeadline(time.Time{})
+	controller.SetWriteDeadline(time.Time{})
+
+	artifact, err := h.runnerService.UploadJobArtifact(c.Request.Context(), currentRunner(c), jobID,
+		filePath, c.GetHeader("X-Checksum-SHA256"), c.Request.Body)
+	if err != nil {
+		c.JSON(artifactErrorStatus(err), APIResponse{
+			Success: false,
+			Message: "上传产物失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Data:    artifact,
+	})
+}
+
+// UpdateJobStatus 上报作业状态
+// @Summary 上报作业状态
+// @Description 上报作业执行状态，作业已取消时返回410，执行器应终止执行
+// @Tags runner-api
+// @Accept json
+// @Produce json
+// @Param id path string true "作业ID"
+// @Param status body services.JobStatusRequest true "状态请求"
+// @Success 200 {object} APIResponse
+// @Failure 410 {object} APIResponse
+// @Router /runner-api/v1/jobs/{id}/status [post]
+func (h *RunnerHandler) UpdateJobStatus(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的作业ID",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	var req services.JobStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := h.runnerService.UpdateJobStatus(currentRunner(c), jobID, &req); err != nil {
+		c.JSON(jobErrorStatus(err), APIResponse{
+			Success: false,
+			Message: "上报作业状态失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "作业状态已更新",
+	})
+}
+
+// tenantAndID 读取租户ID和路径中的资源ID，失败时已写入响应
+func (h *RunnerHandler) tenantAndID(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	tenantID, ok := contextUUID(c, "tenant_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, APIResponse{
+			Success: false,
+			Message: "缺少租户信息",
+		})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的ID",
+			Error:   err.Error(),
+		})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return tenantID, id, true
+}
+
+// contextUUID 读取认证中间件写入上下文的UUID
+func contextUUID(c *gin.Context, key string) (uuid.UUID, bool) {
+	value, exists := c.Get(key)
+	if !exists {
+		return uuid.Nil, false
+	}
+	id, ok := value.(uuid.UUID)
+	return id, ok && id != uuid.Nil
+}
+
+// currentRunner 获取执行器认证中间件写入的执行器
+func currentRunner(c *gin.Context) *models.Runner {
+	runner, _ := c.MustGet("runner").(*models.Runner)
+	return runner
+}
+
+// jobErrorStatus 作业协议错误对应的HTTP状态码
+func jobErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrJobNotAssigned):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrJobCancelled):
+		return http.StatusGone
+	case errors.Is(err, services.ErrLogOffsetMismatch):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
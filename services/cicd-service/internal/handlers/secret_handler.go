@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"cicd-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type SecretHandler struct {
+	secretService services.SecretService
+}
+
+func NewSecretHandler(secretService services.SecretService) *SecretHandler {
+	return &SecretHandler{
+		secretService: secretService,
+	}
+}
+
+// CreateSecret 创建项目密钥
+// @Summary 创建密钥
+// @Description 创建项目密钥，数据加密存储，响应只包含元数据和数据键名
+// @Tags secrets
+// @Accept json
+// @Produce json
+// @Param project_id path string true "项目ID"
+// @Param secret body services.CreateSecretRequest true "密钥信息"
+// @Success 201 {object} APIResponse{data=models.Secret}
+// @Failure 400 {object} APIResponse
+// @Failure 409 {object} APIResponse
+// @Router /api/v1/projects/{project_id}/secrets [post]
+func (h *SecretHandler) CreateSecret(c *gin.Context) {
+	tenantID, projectID, ok := tenantAndProject(c)
+	if !ok {
+		return
+	}
+
+	var req services.CreateSecretRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.ProjectID = projectID
+	req.CreatedBy, _ = contextUUID(c, "user_id")
+
+	secret, err := h.secretService.Create(tenantID, &req)
+	if err != nil {
+		c.JSON(secretErrorStatus(err, http.StatusBadRequest), APIResponse{
+			Success: false,
+			Message: "创建密钥失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "密钥创建成功",
+		Data:    secret,
+	})
+}
+
+// ListSecrets 获取项目密钥列表
+// @Summary 获取密钥列表
+// @Description 获取项目的密钥元数据列表，不包含密钥值
+// @Tags secrets
+// @Produce json
+// @Param project_id path string true "项目ID"
+// @Success 200 {object} APIResponse{data=[]models.Secret}
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/projects/{project_id}/secrets [get]
+func (h *SecretHandler) ListSecrets(c *gin.Context) {
+	tenantID, projectID, ok := tenantAndProject(c)
+	if !ok {
+		return
+	}
+
+	secrets, err := h.secretService.List(tenantID, projectID)
+	if err != nil {
+		c.JSON(secretErrorStatus(err, http.StatusInternalServerError), APIResponse{
+			Success: false,
+			Message: "获取密钥列表失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    secrets,
+	})
+}
+
+// GetSecret 获取密钥元数据
+// @Summary 获取密钥详情
+// @Description 获取密钥元数据，不包含密钥值
+// @Tags secrets
+// @Produce json
+// @Param project_id path string true "项目ID"
+// @Param id path string true "密钥ID"
+// @Success 200 {object} APIResponse{data=models.Secret}
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/projects/{project_id}/secrets/{id} [get]
+func (h *SecretHandler) GetSecret(c *gin.Context) {
+	tenantID, projectID, ok := tenantAndProject(c)
+	if !ok {
+		return
+	}
+	id, ok := h.secretID(c)
+	if !ok {
+		return
+	}
+
+	secret, err := h.secretService.GetByID(tenantID, projectID, id)
+	if err != nil {
+		c.JSON(secretErrorStatus(err, http.StatusInternalServerError), APIResponse{
+			Success: false,
+			Message: "获取密钥失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    secret,
+	})
+}
+
+// UpdateSecret 更新密钥
+// @Summary 更新密钥
+// @Description 更新密钥描述，或整体替换密钥数据（使用新的数据密钥加密）
+// @Tags secrets
+// @Accept json
+// @Produce json
+// @Param project_id path string true "项目ID"
+// @Param id path string true "密钥ID"
+// @Param secret body services.UpdateSecretRequest true "更新内容"
+// @Success 200 {object} APIResponse{data=models.Secret}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/projects/{project_id}/secrets/{id} [put]
+func (h *SecretHandler) UpdateSecret(c *gin.Context) {
+	tenantID, projectID, ok := tenantAndProject(c)
+	if !ok {
+		return
+	}
+	id, ok := h.secretID(c)
+	if !ok {
+		return
+	}
+
+	var req services.UpdateSecretRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	secret, err := h.secretService.Update(tenantID, projectID, id, &req)
+	if err != nil {
+		c.JSON(secretErrorStatus(err, http.StatusBadRequest), APIResponse{
+			Success: false,
+			Message: "更新密钥失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "密钥更新成功",
+		Data:    secret,
+	})
+}
+
+// DeleteSecret 删除密钥
+// @Summary 删除密钥
+// @Description 删除项目密钥，引用该密钥的任务运行时将失败
+// @Tags secrets
+// @Produce json
+// @Param project_id path string true "项目ID"
+// @Param id path string true "密钥ID"
+// @Success 200 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Router /api/v1/projects/{project_id}/secrets/{id} [delete]
+func (h *SecretHandler) DeleteSecret(c *gin.Context) {
+	tenantID, projectID, ok := tenantAndProject(c)
+	if !ok {
+		return
+	}
+	id, ok := h.secretID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.secretService.Delete(tenantID, projectID, id); err != nil {
+		c.JSON(secretErrorStatus(err, http.StatusInternalServerError), APIResponse{
+			Success: false,
+			Message: "删除密钥失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "密钥删除成功",
+	})
+}
+
+// RotateSecretKeys 轮换租户的密钥加密密钥
+// @Summary 轮换密钥
+// @Description 为当前租户的全部密钥生成新的数据密钥，并使用当前主密钥加密
+// @Tags secrets
+// @Produce json
+// @Success 200 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Router /api/v1/secrets/rotate [post]
+func (h *SecretHandler) RotateSecretKeys(c *gin.Context) {
+	tenantID, ok := contextUUID(c, "tenant_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, APIResponse{
+			Success: false,
+			Message: "缺少租户信息",
+		})
+		return
+	}
+
+	rotated, err := h.secretService.RotateKeys(c.Request.Context(), tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Message: "轮换密钥失败",
+			Error:   err.Error(),
+			Data:    gin.H{"rotated": rotated},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "密钥轮换完成",
+		Data:    gin.H{"rotated": rotated},
+	})
+}
+
+// tenantAndProject 读取租户ID和路径中的项目ID，失败时已写入响应
+func tenantAndProject(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	tenantID, ok := contextUUID(c, "tenant_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, APIResponse{
+			Success: false,
+			Message: "缺少租户信息",
+		})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	projectID, err := uuid.Parse(c.Param("project_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的项目ID",
+			Error:   err.Error(),
+		})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return tenantID, projectID, true
+}
+
+// secretID 读取路径中的密钥ID，失败时已写入响应
+func (h *SecretHandler) secretID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "无效的密钥ID",
+			Error:   err.Error(),
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// secretErrorStatus 密钥错误对应的HTTP状态码，其他错误使用 fallback
+func secretErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, services.ErrSecretNotFound), errors.Is(err, services.ErrProjectNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrSecretExists):
+		return http.StatusConflict
+	default:
+		return fallback
+	}
+}
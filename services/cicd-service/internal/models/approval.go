@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// DeploymentApproval 部署审批：目标环境配置了审批人或等待时间的任务，在开始前为其任务运行创建一条审批
+type DeploymentApproval struct {
+	ID                uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
+	ProjectID         uuid.UUID      `json:"project_id" gorm:"type:uuid;not null;index"`
+	EnvironmentID     uuid.UUID      `json:"environment_id" gorm:"type:uuid;not null;index"`
+	EnvironmentName   string         `json:"environment_name" gorm:"size:255;not null"`
+	PipelineRunID     uuid.UUID      `json:"pipeline_run_id" gorm:"type:uuid;not null;index"`
+	TaskRunID         uuid.UUID      `json:"task_run_id" gorm:"type:uuid;not null;uniqueIndex"`
+	TaskName          string         `json:"task_name" gorm:"size:255;not null"`
+	Status            string         `json:"status" gorm:"size:20;not null;index"`     // pending, waiting, approved, rejected, timed_out, cancelled
+	Approvers         datatypes.JSON `json:"approvers" gorm:"type:jsonb;default:'[]'"` // 创建时的审批人快照
+	RequiredApprovals int            `json:"required_approvals" gorm:"not null"`       // 只配置等待时间时为0
+	PreventSelfReview bool           `json:"prevent_self_review" gorm:"default:false"`
+	RequestedBy       *uuid.UUID     `json:"requested_by" gorm:"type:uuid"`    // 运行触发人
+	WaitTimer         int            `json:"wait_timer" gorm:"default:0"`      // 批准后的等待时间(秒)
+	WaitUntil         *time.Time     `json:"wait_until"`                       // 等待结束时间，status=waiting 时有效
+	ExpiresAt         time.Time      `json:"expires_at" gorm:"not null;index"` // 审批截止时间
+	DecidedAt         *time.Time     `json:"decided_at"`
+	Reason            *string        `json:"reason" gorm:"type:text"` // 拒绝、超时或取消的原因
+	CreatedAt         time.Time      `json:"created_at" gorm:"not null"`
+	UpdatedAt         time.Time      `json:"updated_at" gorm:"not null"`
+
+	// 关联关系
+	Decisions []ApprovalDecision `json:"decisions,omitempty" gorm:"foreignKey:ApprovalID"`
+}
+
+// ApprovalDecision 审批人的批准或拒绝记录
+type ApprovalDecision struct {
+	ID         uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
+	ApprovalID uuid.UUID `json:"approval_id" gorm:"type:uuid;not null;uniqueIndex:idx_approval_decision_user"`
+	UserID     uuid.UUID `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_approval_decision_user"` // 每人只能审批一次
+	Role       string    `json:"role" gorm:"size:50"`                                                      // 审批时的租户角色
+	Decision   string    `json:"decision" gorm:"size:20;not null"`                                         // approved, rejected
+	Comment    *string   `json:"comment" gorm:"type:text"`
+	CreatedAt  time.Time `json:"created_at" gorm:"not null"`
+}
+
+// GORM钩子：创建前
+func (a *DeploymentApproval) BeforeCreate(tx *gorm.DB) (err error) {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return
+}
+
+func (d *ApprovalDecision) BeforeCreate(tx *gorm.DB) (err error) {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return
+}
+
+// 表名指定
+func (DeploymentApproval) TableName() string {
+	return "deployment_approvals"
+}
+
+func (ApprovalDecision) TableName() string {
+	return "approval_decisions"
+}
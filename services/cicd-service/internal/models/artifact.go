@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Artifact 构建产物模型，每个文件一条记录
+type Artifact struct {
+	ID            uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
+	PipelineID    uuid.UUID  `json:"pipeline_id" gorm:"type:uuid;not null;index"`
+	PipelineRunID uuid.UUID  `json:"pipeline_run_id" gorm:"type:uuid;not null;index"`
+	TaskRunID     uuid.UUID  `json:"task_run_id" gorm:"type:uuid;not null;index"`
+	TaskName      string     `json:"task_name" gorm:"size:255;not null"`
+	Name          string     `json:"name" gorm:"size:255;not null"`  // 产物名称，缺省为任务名
+	Path          string     `json:"path" gorm:"size:1024;not null"` // 相对工作空间的文件路径
+	StorageKey    string     `json:"-" gorm:"size:1024;not null"`    // 对象存储键
+	Size          int64      `json:"size" gorm:"not null"`           // 文件大小(字节)
+	SHA256        string     `json:"sha256" gorm:"size:64;not null"` // 内容SHA-256
+	ExpiresAt     *time.Time `json:"expires_at" gorm:"index"`        // 过期时间，为空表示不过期
+	CreatedAt     time.Time  `json:"created_at" gorm:"not null"`
+}
+
+// GORM钩子：创建前
+func (a *Artifact) BeforeCreate(tx *gorm.DB) (err error) {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return
+}
+
+// 表名指定
+func (Artifact) TableName() string {
+	return "artifacts"
+}
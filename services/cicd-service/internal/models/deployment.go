@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Deployment 部署记录：部署任务通过环境保护规则开始执行时为其任务运行创建一条，任务结束后更新状态
+type Deployment struct {
+	ID              uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
+	ProjectID       uuid.UUID      `json:"project_id" gorm:"type:uuid;not null;index"`
+	EnvironmentID   uuid.UUID      `json:"environment_id" gorm:"type:uuid;not null;index:idx_deployments_environment"`
+	EnvironmentName string         `json:"environment_name" gorm:"size:255;not null"`
+	PipelineID      uuid.UUID      `json:"pipeline_id" gorm:"type:uuid;not null;index"`
+	PipelineRunID   uuid.UUID      `json:"pipeline_run_id" gorm:"type:uuid;not null;index"`
+	TaskRunID       uuid.UUID      `json:"task_run_id" gorm:"type:uuid;not null;uniqueIndex"`
+	TaskName        string         `json:"task_name" gorm:"size:255;not null"`
+	CommitSHA       *string        `json:"commit_sha" gorm:"size:40"`                                        // 部署的提交
+	Branch          *string        `json:"branch" gorm:"size:255"`                                           // 部署的分支或标签
+	Artifacts       datatypes.JSON `json:"artifacts" gorm:"type:jsonb;default:'[]'"`                         // 部署任务使用的产物
+	Status          string         `json:"status" gorm:"size:20;not null;index:idx_deployments_environment"` // running, succeeded, failed, cancelled
+	DeployedBy      *uuid.UUID     `json:"deployed_by" gorm:"type:uuid"`                                     // 运行触发人
+	RollbackOf      *uuid.UUID     `json:"rollback_of" gorm:"type:uuid"`                                     // 回滚的目标部署，非回滚为空
+	ErrorMessage    *string        `json:"error_message" gorm:"type:text"`
+	StartedAt       time.Time      `json:"started_at" gorm:"not null"`
+	FinishedAt      *time.Time     `json:"finished_at"`
+	CreatedAt       time.Time      `json:"created_at" gorm:"not null"`
+	UpdatedAt       time.Time      `json:"updated_at" gorm:"not null"`
+}
+
+// GORM钩子：创建前
+func (d *Deployment) BeforeCreate(tx *gorm.DB) (err error) {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return
+}
+
+// 表名指定
+func (Deployment) TableName() string {
+	return "deployments"
+}
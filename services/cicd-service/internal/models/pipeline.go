@@ -12,6 +12,8 @@ import (
 type Pipeline struct {
 	ID           uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
 	ProjectID    uuid.UUID      `json:"project_id" gorm:"type:uuid;not null;index"`
+	RepositoryID *uuid.UUID     `json:"repository_id" gorm:"type:uuid;index"`           // 定义文件所在仓库
+	DefinitionFilePath *string  `json:"definition_file_path" gorm:"size:512"`          // 定义文件路径，设置后每次运行读取触发提交中的版本
 	Name         string         `json:"name" gorm:"size:255;not null"`
 	Description  *string        `json:"description" gorm:"type:text"`
 	Status       string         `json:"status" gorm:"size:20;not null;default:active"` // active, disabled, archived
@@ -41,6 +43,11 @@ type PipelineConfig struct {
 	EnableCache     bool     `json:"enable_cache" gorm:"default:true"`               // 启用缓存
 	CacheKeys       []string `json:"cache_keys" gorm:"type:text[]"`                  // 缓存键
 	NotificationChannels []string `json:"notification_channels" gorm:"type:text[]"` // 通知渠道
+	ArtifactExpireDays   int      `json:"artifact_expire_days" gorm:"default:0"`     // 产物保留天数，0 表示使用全局配置
+	KeepLatestArtifacts  bool     `json:"keep_latest_artifacts"`                     // 始终保留各分支最近一次成功运行的产物
+	Priority             int      `json:"priority" gorm:"default:0"`                 // 运行排队优先级，数值大的先启动
+	ConcurrencyGroup     string   `json:"concurrency_group" gorm:"size:255"`         // 并发组，同组同一分支同时只启动一个运行
+	CancelInProgress     bool     `json:"cancel_in_progress"`                        // 新运行取消同一并发组中更早的运行，而不是排队等待
 }
 
 // Task 任务模型
@@ -61,6 +68,12 @@ type Task struct {
 	Order        int          `json:"order" gorm:"not null;default:0"`        // 执行顺序
 	Timeout      int          `json:"timeout" gorm:"default:1800"`            // 任务超时(秒)
 	Retries      int          `json:"retries" gorm:"default:0"`               // 重试次数
+	RunnerTags   datatypes.JSON `json:"runner_tags" gorm:"type:jsonb;default:'[]'"` // 自托管执行器需具备的标签
+	Artifacts    datatypes.JSON `json:"artifacts" gorm:"type:jsonb"`              // 产出的构建产物
+	ArtifactsFrom datatypes.JSON `json:"artifacts_from" gorm:"type:jsonb;default:'[]'"` // 需要下载的构建产物
+	Secrets      datatypes.JSON `json:"secrets" gorm:"type:jsonb;default:'[]'"`   // 注入环境变量的项目密钥引用
+	Matrix       datatypes.JSON `json:"matrix,omitempty" gorm:"type:jsonb"`       // 矩阵配置，运行时按组合展开为多个任务运行
+	Environment  *string      `json:"environment" gorm:"size:255"`             // 部署的目标环境，环境配置保护规则时任务开始前需审批
 	CreatedAt    time.Time    `json:"created_at" gorm:"not null"`
 	UpdatedAt    time.Time    `json:"updated_at" gorm:"not null"`
 
@@ -74,15 +87,24 @@ type PipelineRun struct {
 	ID            uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
 	PipelineID    uuid.UUID      `json:"pipeline_id" gorm:"type:uuid;not null;index"`
 	RunNumber     int            `json:"run_number" gorm:"not null"`                     // 运行序号
-	Status        string         `json:"status" gorm:"size:20;not null;default:pending"` // pending, running, succeeded, failed, cancelled, timeout
-	TriggerType   string         `json:"trigger_type" gorm:"size:50;not null"`          // manual, webhook, schedule, api
+	Status        string         `json:"status" gorm:"size:20;not null;default:pending"` // queued, pending, running, succeeded, failed, cancelled, timeout
+	TriggerType   string         `json:"trigger_type" gorm:"size:50;not null"`          // manual, webhook, schedule, api, rollback
 	TriggerBy     *uuid.UUID     `json:"trigger_by" gorm:"type:uuid"`                   // 触发用户
 	TriggerData   datatypes.JSON `json:"trigger_data" gorm:"type:jsonb;default:'{}'"`   // 触发数据
+	CommitSHA     *string        `json:"commit_sha" gorm:"size:40"`                     // 构建的提交
+	Branch        *string        `json:"branch" gorm:"size:255"`                        // 构建的分支或标签
+	Definition    datatypes.JSON `json:"definition,omitempty" gorm:"type:jsonb"`        // 本次运行使用的流水线定义快照（来自提交中的定义文件）
+	RollbackOf    *uuid.UUID     `json:"rollback_of,omitempty" gorm:"type:uuid"`        // 回滚的部署，运行只执行该部署的任务
+	Parameters    datatypes.JSON `json:"parameters,omitempty" gorm:"type:jsonb"`        // 运行参数，出队启动时使用
+	Priority      int            `json:"priority" gorm:"not null;default:0"`            // 排队优先级，数值大的先启动
+	ConcurrencyGroup *string     `json:"concurrency_group" gorm:"type:text;index"`      // 并发组（项目/组名/分支），同组同时只启动一个运行
+	QueuedAt      *time.Time     `json:"queued_at"`                                      // 进入队列的时间
+	QueuePosition *int           `json:"queue_position,omitempty" gorm:"-"`              // 排队位置（从1开始），只在排队中时返回
 	StartedAt     *time.Time     `json:"started_at"`
 	FinishedAt    *time.Time     `json:"finished_at"`
 	Duration      *int           `json:"duration"`                                       // 执行时长(秒)
-	LogsPath      *string        `json:"logs_path" gorm:"size:512"`                     // 日志文件路径
-	ArtifactsPath *string        `json:"artifacts_path" gorm:"size:512"`                // 产物路径
+	LogsPath      *string        `json:"logs_path" gorm:"size:512"`                     // 日志存储路径前缀
+	ArtifactsPath *string        `json:"artifacts_path" gorm:"size:512"`                // 产物存储路径前缀
 	ResourceUsage datatypes.JSON `json:"resource_usage" gorm:"type:jsonb;default:'{}'"`// 资源使用统计
 	ErrorMessage  *string        `json:"error_message" gorm:"type:text"`                // 错误信息
 	CreatedAt     time.Time      `json:"created_at" gorm:"not null"`
@@ -104,12 +126,19 @@ type TaskRun struct {
 	FinishedAt    *time.Time     `json:"finished_at"`
 	Duration      *int           `json:"duration"`                                       // 执行时长(秒)
 	ExitCode      *int           `json:"exit_code"`                                      // 退出码
-	LogsPath      *string        `json:"logs_path" gorm:"size:512"`                     // 日志文件路径
+	LogsPath      *string        `json:"logs_path" gorm:"size:512"`                     // 日志存储路径前缀
 	PodName       *string        `json:"pod_name" gorm:"size:255"`                      // K8s Pod名称
 	NodeName      *string        `json:"node_name" gorm:"size:255"`                     // K8s Node名称
 	ResourceUsage datatypes.JSON `json:"resource_usage" gorm:"type:jsonb;default:'{}'"`// 资源使用统计
 	ErrorMessage  *string        `json:"error_message" gorm:"type:text"`                // 错误信息
 	RetryCount    int            `json:"retry_count" gorm:"default:0"`                  // 重试次数
+	RunnerID      *uuid.UUID     `json:"runner_id" gorm:"type:uuid;index"`              // 领取作业的自托管执行器
+	QueuedAt      *time.Time     `json:"queued_at"`                                      // 依赖满足、可被执行器领取的时间
+	AssignCount   int            `json:"assign_count" gorm:"default:0"`                 // 分配给执行器的次数（执行器离线后重新分配）
+	RunnerTags    datatypes.JSON `json:"runner_tags,omitempty" gorm:"type:jsonb"`       // 领取作业需要的执行器标签
+	JobSpec       datatypes.JSON `json:"job_spec,omitempty" gorm:"type:jsonb"`          // 下发给自托管执行器的作业定义快照
+	MatrixTask    *string        `json:"matrix_task,omitempty" gorm:"size:255"`         // 所属矩阵任务名，非矩阵任务为空
+	MatrixValues  datatypes.JSON `json:"matrix_values,omitempty" gorm:"type:jsonb"`     // 本次组合的矩阵取值
 	CreatedAt     time.Time      `json:"created_at" gorm:"not null"`
 	UpdatedAt     time.Time      `json:"updated_at" gorm:"not null"`
 
@@ -134,13 +163,19 @@ type BuildCache struct {
 	UpdatedAt   time.Time  `json:"updated_at" gorm:"not null"`
 }
 
-// Secret 密钥管理模型
+// Secret 密钥管理模型，采用信封加密：数据由随机数据密钥(DEK)加密，DEK再由主密钥(KEK)加密，明文不落库
 type Secret struct {
 	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
-	ProjectID uuid.UUID      `json:"project_id" gorm:"type:uuid;not null;index"`
-	Name      string         `json:"name" gorm:"size:255;not null"`
+	ProjectID uuid.UUID      `json:"project_id" gorm:"type:uuid;not null;uniqueIndex:idx_secrets_project_name"`
+	Name      string         `json:"name" gorm:"size:255;not null;uniqueIndex:idx_secrets_project_name"`
 	Type      string         `json:"type" gorm:"size:50;not null"`                     // generic, docker-registry, ssh-auth
-	Data      datatypes.JSON `json:"data" gorm:"type:jsonb;not null"`                  // 加密数据
+	Description *string      `json:"description" gorm:"type:text"`
+	Keys      datatypes.JSON `json:"keys" gorm:"type:jsonb;default:'[]'"`              // 数据键名（不含值）
+	ValueEncrypted []byte    `json:"-" gorm:"type:bytea;not null"`                     // DEK加密后的数据(nonce+密文)
+	KEKRef    string         `json:"kek_ref" gorm:"size:512;not null;index"`           // 加密DEK所用主密钥的引用
+	DEKEncrypted []byte      `json:"-" gorm:"type:bytea;not null"`                     // 被KEK加密后的DEK
+	Version   int            `json:"version" gorm:"not null;default:1"`                // 数据版本，每次更新值递增
+	RotatedAt *time.Time     `json:"rotated_at"`                                       // 最近一次密钥轮换时间
 	UsageCount int           `json:"usage_count" gorm:"default:0"`                     // 使用次数
 	LastUsedAt *time.Time    `json:"last_used_at"`                                     // 最后使用时间
 	CreatedBy  uuid.UUID     `json:"created_by" gorm:"type:uuid;not null"`
@@ -148,6 +183,45 @@ type Secret struct {
 	UpdatedAt  time.Time     `json:"updated_at" gorm:"not null"`
 }
 
+// LogMaskPattern 项目日志掩码规则，匹配的日志内容在存储前被替换
+type LogMaskPattern struct {
+	ID          uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
+	ProjectID   uuid.UUID `json:"project_id" gorm:"type:uuid;not null;index"`
+	Pattern     string    `json:"pattern" gorm:"size:1024;not null"` // RE2正则表达式
+	Description *string   `json:"description" gorm:"type:text"`
+	CreatedBy   uuid.UUID `json:"created_by" gorm:"type:uuid;not null"`
+	CreatedAt   time.Time `json:"created_at" gorm:"not null"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"not null"`
+}
+
+// PipelineSchedule 流水线定时触发状态，由定时器根据流水线的 schedule 触发器维护。
+// 各副本通过条件更新 next_run_at 认领到期的触发，同一时间点只会被一个副本执行
+type PipelineSchedule struct {
+	ID              uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
+	PipelineID      uuid.UUID  `json:"pipeline_id" gorm:"type:uuid;not null;uniqueIndex:idx_pipeline_schedule_trigger"`
+	TriggerIndex    int        `json:"trigger_index" gorm:"not null;uniqueIndex:idx_pipeline_schedule_trigger"` // 触发器在 triggers 中的位置
+	Cron            string     `json:"cron" gorm:"size:255;not null"`
+	Timezone        string     `json:"timezone" gorm:"size:64;not null"`
+	Branch          *string    `json:"branch" gorm:"size:255"`                // 构建的分支，为空时使用默认分支
+	MissedPolicy    string     `json:"missed_policy" gorm:"size:20;not null"` // skip, catch_up
+	NextRunAt       time.Time  `json:"next_run_at" gorm:"not null;index"`     // 下一个触发时间点
+	LastScheduledAt *time.Time `json:"last_scheduled_at"`                     // 最近一次执行的触发时间点
+	LastRunID       *uuid.UUID `json:"last_run_id" gorm:"type:uuid"`
+	LastError       *string    `json:"last_error" gorm:"type:text"` // 最近一次触发失败的原因
+	CreatedAt       time.Time  `json:"created_at" gorm:"not null"`
+	UpdatedAt       time.Time  `json:"updated_at" gorm:"not null"`
+}
+
+// GitWebhookDelivery 已处理的仓库事件投递，用于拒绝重放的事件。
+// delivery_id 取签名载荷中的 delivery_id（与 X-Delivery-ID 一致），相同内容的不同投递分别处理
+type GitWebhookDelivery struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
+	DeliveryID   string    `json:"delivery_id" gorm:"size:64;not null;uniqueIndex"`
+	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;index"`
+	EventType    string    `json:"event_type" gorm:"size:50;not null"`
+	CreatedAt    time.Time `json:"created_at" gorm:"not null"`
+}
+
 // Environment 环境管理模型
 type Environment struct {
 	ID           uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Runner 自托管执行器模型
+type Runner struct {
+	ID                uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
+	TenantID          uuid.UUID      `json:"tenant_id" gorm:"type:uuid;not null;index"`
+	Name              string         `json:"name" gorm:"size:255;not null"`
+	Description       *string        `json:"description" gorm:"type:text"`
+	Tags              datatypes.JSON `json:"tags" gorm:"type:jsonb;default:'[]'"`            // 标签，用于作业匹配
+	Status            string         `json:"status" gorm:"size:20;not null;default:offline"` // online, offline, disabled
+	Draining          bool           `json:"draining" gorm:"default:false"`                  // 排空中：不再领取新作业，执行完当前作业后下线
+	TokenHash         string         `json:"-" gorm:"size:64;not null;uniqueIndex"`          // 执行器认证令牌哈希
+	TokenPrefix       string         `json:"token_prefix" gorm:"size:16"`                    // 令牌前缀，便于识别
+	Version           *string        `json:"version" gorm:"size:50"`                         // 执行器版本
+	Platform          *string        `json:"platform" gorm:"size:50"`                        // 操作系统/架构，如 linux/amd64
+	IPAddress         *string        `json:"ip_address" gorm:"size:64"`                      // 最近一次连接的地址
+	MaxConcurrentJobs int            `json:"max_concurrent_jobs" gorm:"not null;default:1"`  // 最大并发作业数
+	RegisteredBy      *uuid.UUID     `json:"registered_by" gorm:"type:uuid"`                 // 注册令牌ID
+	LastContactAt     *time.Time     `json:"last_contact_at"`                                // 最近一次心跳时间
+	CreatedAt         time.Time      `json:"created_at" gorm:"not null"`
+	UpdatedAt         time.Time      `json:"updated_at" gorm:"not null"`
+	DeletedAt         *time.Time     `json:"deleted_at,omitempty" gorm:"index"`
+}
+
+// RunnerRegistrationToken 执行器注册令牌模型（租户级）
+type RunnerRegistrationToken struct {
+	ID          uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
+	TenantID    uuid.UUID      `json:"tenant_id" gorm:"type:uuid;not null;index"`
+	Description *string        `json:"description" gorm:"type:text"`
+	TokenHash   string         `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	TokenPrefix string         `json:"token_prefix" gorm:"size:16"`
+	Tags        datatypes.JSON `json:"tags" gorm:"type:jsonb;default:'[]'"` // 通过该令牌注册的执行器默认附带的标签
+	UsageCount  int            `json:"usage_count" gorm:"default:0"`
+	ExpiresAt   *time.Time     `json:"expires_at"`
+	RevokedAt   *time.Time     `json:"revoked_at"`
+	LastUsedAt  *time.Time     `json:"last_used_at"`
+	CreatedBy   uuid.UUID      `json:"created_by" gorm:"type:uuid;not null"`
+	CreatedAt   time.Time      `json:"created_at" gorm:"not null"`
+	UpdatedAt   time.Time      `json:"updated_at" gorm:"not null"`
+}
+
+// GORM钩子：创建前
+func (r *Runner) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return
+}
+
+func (t *RunnerRegistrationToken) BeforeCreate(tx *gorm.DB) (err error) {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return
+}
+
+// 表名指定
+func (Runner) TableName() string {
+	return "runners"
+}
+
+func (RunnerRegistrationToken) TableName() string {
+	return "runner_registration_tokens"
+}
@@ -17,7 +17,16 @@ func SetupRoutes(
 	pipelineHandler *handlers.PipelineHandler,
 	pipelineRunHandler *handlers.PipelineRunHandler,
 	cacheHandler *handlers.CacheHandler,
+	runnerHandler *handlers.RunnerHandler,
+	logHandler *handlers.LogHandler,
+	artifactHandler *handlers.ArtifactHandler,
+	secretHandler *handlers.SecretHandler,
+	logMaskHandler *handlers.LogMaskHandler,
+	gitWebhookHandler *handlers.GitWebhookHandler,
 	healthHandler *handlers.HealthHandler,
+	environmentHandler *handlers.EnvironmentHandler,
+	approvalHandler *handlers.ApprovalHandler,
+	deploymentHandler *handlers.DeploymentHandler,
 ) *gin.Engine {
 	// 根据环境设置Gin模式
 	if cfg.IsProduction() {
@@ -59,14 +68,19 @@ func SetupRoutes(
 			pipelines.POST("", pipelineHandler.CreatePipeline)
 			pipelines.GET("", pipelineHandler.ListPipelines)
 			pipelines.GET("/statistics", pipelineHandler.GetPipelineStatistics)
+			pipelines.POST("/import", pipelineHandler.ImportPipeline)
+			pipelines.POST("/validate-definition", pipelineHandler.ValidatePipelineDefinition)
+			pipelines.POST("/dry-run", pipelineHandler.DryRunPipeline)
 			pipelines.GET("/:id", pipelineHandler.GetPipeline)
 			pipelines.PUT("/:id", pipelineHandler.UpdatePipeline)
 			pipelines.DELETE("/:id", pipelineHandler.DeletePipeline)
 			pipelines.POST("/:id/enable", pipelineHandler.EnablePipeline)
 			pipelines.POST("/:id/disable", pipelineHandler.DisablePipeline)
 			pipelines.POST("/:id/clone", pipelineHandler.ClonePipeline)
+			pipelines.GET("/:id/plan", pipelineHandler.GetPipelinePlan)
 			pipelines.POST("/:id/trigger", pipelineRunHandler.TriggerPipelineByPipeline)
-			pipelines.GET("/:pipeline_id/runs", pipelineRunHandler.GetPipelineRunsByPipeline)
+			pipelines.GET("/:id/runs", pipelineRunHandler.GetPipelineRunsByPipeline)
+			pipelines.GET("/:id/artifacts/latest", artifactHandler.GetLatestArtifacts)
 		}
 
 		// 流水线运行相关路由
@@ -78,6 +92,14 @@ func SetupRoutes(
 			pipelineRuns.GET("/:id", pipelineRunHandler.GetPipelineRun)
 			pipelineRuns.POST("/:id/cancel", pipelineRunHandler.CancelPipelineRun)
 			pipelineRuns.POST("/:id/retry", pipelineRunHandler.RetryPipelineRun)
+			pipelineRuns.GET("/:id/logs", logHandler.DownloadPipelineRunLogs)
+			pipelineRuns.GET("/:id/task-runs/:task_run_id/logs", logHandler.GetTaskRunLogs)
+			pipelineRuns.GET("/:id/task-runs/:task_run_id/logs/stream", logHandler.StreamTaskRunLogs)
+			pipelineRuns.GET("/:id/artifacts", artifactHandler.ListArtifacts)
+			pipelineRuns.GET("/:id/artifacts/archive", artifactHandler.DownloadArtifactArchive)
+			pipelineRuns.DELETE("/:id/artifacts", artifactHandler.DeleteArtifacts)
+			pipelineRuns.GET("/:id/artifacts/:artifact_id/download", artifactHandler.DownloadArtifact)
+			pipelineRuns.GET("/:id/approvals", approvalHandler.ListRunApprovals)
 		}
 
 		// 构建缓存相关路由
@@ -87,7 +109,7 @@ func SetupRoutes(
 			cache.GET("", cacheHandler.ListCaches)
 			cache.GET("/statistics", cacheHandler.GetCacheStatistics)
 			cache.POST("/cleanup", cacheHandler.CleanupCaches)
-			cache.GET("/:key", cacheHandler.RetrieveCache)
+			cache.GET("/by-key/:key", cacheHandler.RetrieveCache)
 			cache.DELETE("/:id", cacheHandler.DeleteCache)
 			cache.DELETE("/by-key/:key", cacheHandler.DeleteCacheByKey)
 			cache.GET("/:id/validate", cacheHandler.ValidateCache)
@@ -95,19 +117,82 @@ func SetupRoutes(
 			cache.GET("/:id/checksum", cacheHandler.CalculateCacheChecksum)
 		}
 
-		// 项目相关的流水线路由
+		// 自托管执行器管理路由
+		runners := v1.Group("/runners")
+		{
+			runners.GET("", runnerHandler.ListRunners)
+			runners.POST("/registration-tokens", runnerHandler.CreateRegistrationToken)
+			runners.GET("/registration-tokens", runnerHandler.ListRegistrationTokens)
+			runners.DELETE("/registration-tokens/:id", runnerHandler.RevokeRegistrationToken)
+			runners.GET("/:id", runnerHandler.GetRunner)
+			runners.PUT("/:id", runnerHandler.UpdateRunner)
+			runners.DELETE("/:id", runnerHandler.DeleteRunner)
+			runners.POST("/:id/drain", runnerHandler.DrainRunner)
+			runners.POST("/:id/resume", runnerHandler.ResumeRunner)
+		}
+
+		// 密钥轮换路由
+		v1.POST("/secrets/rotate", secretHandler.RotateSecretKeys)
+
+		// 部署审批路由
+		approvals := v1.Group("/approvals")
+		{
+			approvals.GET("", approvalHandler.ListPendingApprovals)
+			approvals.GET("/:id", approvalHandler.GetApproval)
+			approvals.POST("/:id/approve", approvalHandler.ApproveDeployment)
+			approvals.POST("/:id/reject", approvalHandler.RejectDeployment)
+		}
+
+		// 部署记录和回滚路由
+		deployments := v1.Group("/deployments")
+		{
+			deployments.GET("/:id", deploymentHandler.GetDeployment)
+			deployments.POST("/:id/rollback", deploymentHandler.RollbackDeployment)
+		}
+
+		// 项目相关的流水线、密钥、日志掩码、环境和部署路由
 		projects := v1.Group("/projects")
 		{
 			projects.GET("/:project_id/pipelines", pipelineHandler.GetPipelinesByProject)
+			projects.POST("/:project_id/secrets", secretHandler.CreateSecret)
+			projects.GET("/:project_id/secrets", secretHandler.ListSecrets)
+			projects.GET("/:project_id/secrets/:id", secretHandler.GetSecret)
+			projects.PUT("/:project_id/secrets/:id", secretHandler.UpdateSecret)
+			projects.DELETE("/:project_id/secrets/:id", secretHandler.DeleteSecret)
+			projects.POST("/:project_id/log-masks", logMaskHandler.CreateLogMaskPattern)
+			projects.GET("/:project_id/log-masks", logMaskHandler.ListLogMaskPatterns)
+			projects.DELETE("/:project_id/log-masks/:id", logMaskHandler.DeleteLogMaskPattern)
+			projects.POST("/:project_id/environments", environmentHandler.CreateEnvironment)
+			projects.GET("/:project_id/environments", environmentHandler.ListEnvironments)
+			projects.GET("/:project_id/environments/:id", environmentHandler.GetEnvironment)
+			projects.PUT("/:project_id/environments/:id", environmentHandler.UpdateEnvironment)
+			projects.DELETE("/:project_id/environments/:id", environmentHandler.DeleteEnvironment)
+			projects.GET("/:project_id/environments/:id/deployments", deploymentHandler.ListEnvironmentDeployments)
+			projects.GET("/:project_id/environments/:id/deployments/current", deploymentHandler.GetCurrentDeployment)
+			projects.GET("/:project_id/deployments/current", deploymentHandler.ListCurrentDeployments)
 		}
 	}
 
-	// Webhook端点（可能需要不同的认证方式）
+	// 执行器协议端点（使用执行器令牌认证）
+	runnerAPI := router.Group("/runner-api/v1")
+	{
+		runnerAPI.POST("/register", runnerHandler.Register)
+
+		authenticated := runnerAPI.Group("", runnerHandler.RunnerAuth())
+		authenticated.POST("/heartbeat", runnerHandler.Heartbeat)
+		authenticated.POST("/shutdown", runnerHandler.Shutdown)
+		authenticated.POST("/jobs/request", runnerHandler.RequestJob)
+		authenticated.POST("/jobs/:id/logs", runnerHandler.AppendJobLog)
+		authenticated.GET("/jobs/:id/artifacts", runnerHandler.ListJobArtifacts)
+		authenticated.GET("/jobs/:id/artifacts/:artifact_id", runnerHandler.DownloadJobArtifact)
+		authenticated.POST("/jobs/:id/artifacts", runnerHandler.UploadJobArtifact)
+		authenticated.POST("/jobs/:id/status", runnerHandler.UpdateJobStatus)
+	}
+
+	// Webhook端点（使用 X-Hub-Signature-256 签名认证）
 	webhooks := router.Group("/webhooks")
 	{
-		// 这里可以添加Git仓库webhook处理
-		// 例如：webhooks.POST("/git/:repository_id", gitWebhookHandler.HandleWebhook)
-		// 暂时保留，后续可以扩展
+		webhooks.POST("/git/:repository_id", gitWebhookHandler.HandleWebhook)
 	}
 
 	return router
@@ -0,0 +1,486 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cicd-service/internal/config"
+	"cicd-service/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// approvalFallbackTimeout 服务配置缺少审批超时时使用的值(秒)
+const approvalFallbackTimeout = 24 * 3600
+
+var (
+	// ErrApprovalNotFound 审批不存在或不属于当前租户
+	ErrApprovalNotFound = errors.New("审批不存在")
+	// ErrApprovalClosed 审批已结束，不能再批准或拒绝
+	ErrApprovalClosed = errors.New("审批已结束")
+	// ErrApprovalForbidden 当前用户不能审批
+	ErrApprovalForbidden = errors.New("无权审批")
+	// ErrApprovalDecided 当前用户已经审批过
+	ErrApprovalDecided = errors.New("已经审批过该部署")
+)
+
+// ApprovalService 部署审批服务接口，同时作为执行器的部署审批检查
+type ApprovalService interface {
+	DeploymentGate
+
+	GetByID(tenantID, id uuid.UUID) (*models.DeploymentApproval, error)
+	ListByRun(tenantID, runID uuid.UUID) ([]models.DeploymentApproval, error)
+	// ListPending 获取当前用户可以审批的待审批列表
+	ListPending(tenantID uuid.UUID, actor ApprovalActor) ([]models.DeploymentApproval, error)
+	// Decide 批准或拒绝部署，decision 为 approved 或 rejected
+	Decide(tenantID, id uuid.UUID, actor ApprovalActor, decision string, comment *string) (*models.DeploymentApproval, error)
+
+	// ProcessDue 处理超时的审批和等待时间已到的审批，取消所属运行已结束的审批，返回处理数量
+	ProcessDue() (int, error)
+	// OnResolved 注册审批结束（通过、拒绝、超时）后的回调，用于唤醒等待审批的执行器
+	OnResolved(listener func(runID uuid.UUID))
+}
+
+type approvalService struct {
+	db     *gorm.DB
+	config *config.Config
+	client *http.Client
+
+	mu        sync.RWMutex
+	listeners []func(runID uuid.UUID)
+}
+
+// NewApprovalService 创建部署审批服务实例
+func NewApprovalService(db *gorm.DB, cfg *config.Config) ApprovalService {
+	return &approvalService{
+		db:     db,
+		config: cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// ApprovalActor 审批操作人
+type ApprovalActor struct {
+	UserID uuid.UUID
+	Role   string // 租户角色
+}
+
+// ApprovalDecisionRequest 批准或拒绝部署请求
+type ApprovalDecisionRequest struct {
+	Comment *string `json:"comment" binding:"omitempty,max=2000"`
+}
+
+// CheckDeployment 判断任务能否部署到目标环境。首次检查时按环境保护规则校验分支并创建审批，
+// 之后按审批状态判断；查询失败时继续等待，下次检查重试
+func (s *approvalService) CheckDeployment(req *DeploymentGateRequest) (bool, string) {
+	var approval models.DeploymentApproval
+	err := s.db.Where("task_run_id = ?", req.TaskRunID).First(&approval).Error
+	if err == nil {
+		return s.approvalState(&approval)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("⚠️ 查询部署审批失败 %s: %v", req.TaskRunID, err)
+		return false, ""
+	}
+
+	var environment models.Environment
+	if err := s.db.Where("project_id = ? AND name = ? AND deleted_at IS NULL", req.ProjectID, req.Environment).
+		First(&environment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, fmt.Sprintf("部署环境 %s 不存在", req.Environment)
+		}
+		log.Printf("⚠️ 查询部署环境失败 %s: %v", req.Environment, err)
+		return false, ""
+	}
+	if environment.Status != "active" {
+		return false, fmt.Sprintf("环境 %s 当前状态为 %s，不允许部署", environment.Name, environment.Status)
+	}
+	rules, err := environmentProtectionRules(&environment)
+	if err != nil {
+		return false, err.Error()
+	}
+
+	if len(rules.Branches) > 0 {
+		branch := ""
+		if req.Run.Branch != nil {
+			branch = strings.TrimPrefix(strings.TrimPrefix(*req.Run.Branch, "refs/heads/"), "refs/tags/")
+		}
+		if branch == "" || !matchRefPatterns(rules.Branches, branch) {
+			return false, fmt.Sprintf("分支 %s 不允许部署到环境 %s", strconv.Quote(branch), environment.Name)
+		}
+	}
+	if len(rules.RequiredApprovers) == 0 && rules.WaitTimer == 0 {
+		return true, ""
+	}
+
+	approvers, err := json.Marshal(rules.RequiredApprovers)
+	if err != nil {
+		log.Printf("⚠️ 序列化审批人失败 %s: %v", environment.Name, err)
+		return false, ""
+	}
+	now := time.Now()
+	approval = models.DeploymentApproval{
+		ID:                uuid.New(),
+		ProjectID:         req.ProjectID,
+		EnvironmentID:     environment.ID,
+		EnvironmentName:   environment.Name,
+		PipelineRunID:     req.Run.ID,
+		TaskRunID:         req.TaskRunID,
+		TaskName:          req.TaskName,
+		Status:            "pending",
+		Approvers:         datatypes.JSON(approvers),
+		RequiredApprovals: max(rules.RequiredApprovals, 1),
+		PreventSelfReview: rules.PreventSelfReview,
+		RequestedBy:       req.Run.TriggerBy,
+		WaitTimer:         rules.WaitTimer,
+	}
+	if len(rules.RequiredApprovers) == 0 {
+		// 只配置了等待时间，不需要审批人
+		waitUntil := now.Add(time.Duration(rules.WaitTimer) * time.Second)
+		approval.Status = "waiting"
+		approval.RequiredApprovals = 0
+		approval.WaitUntil = &waitUntil
+		approval.ExpiresAt = waitUntil
+	} else {
+		timeout := rules.ApprovalTimeout
+		if timeout <= 0 {
+			timeout = s.config.Approval.DefaultTimeout
+		}
+		if timeout <= 0 {
+			timeout = approvalFallbackTimeout
+		}
+		approval.ExpiresAt = now.Add(time.Duration(timeout) * time.Second)
+	}
+
+	// 同一任务运行只创建一条审批，多个实例同时检查时以先创建的为准
+	result := s.db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "task_run_id"}}, DoNothing: true}).Create(&approval)
+	if result.Error != nil {
+		log.Printf("⚠️ 创建部署审批失败 %s: %v", req.TaskRunID, result.Error)
+		return false, ""
+	}
+	if result.RowsAffected == 0 {
+		if err := s.db.Where("task_run_id = ?", req.TaskRunID).First(&approval).Error; err != nil {
+			log.Printf("⚠️ 查询部署审批失败 %s: %v", req.TaskRunID, err)
+			return false, ""
+		}
+		return s.approvalState(&approval)
+	}
+
+	if approval.Status == "pending" {
+		go s.notifyApprovers(approval)
+	}
+	return s.approvalState(&approval)
+}
+
+// approvalState 按审批状态判断任务能否开始，判断前推进已到期的审批
+func (s *approvalService) approvalState(approval *models.DeploymentApproval) (bool, string) {
+	if _, err := s.advance(approval, time.Now()); err != nil {
+		log.Printf("⚠️ 更新部署审批失败 %s: %v", approval.ID, err)
+		return false, ""
+	}
+
+	switch approval.Status {
+	case "approved":
+		return true, ""
+	case "pending", "waiting":
+		return false, ""
+	}
+	if approval.Reason != nil {
+		return false, *approval.Reason
+	}
+	return false, fmt.Sprintf("部署到环境 %s 的审批未通过(%s)", approval.EnvironmentName, approval.Status)
+}
+
+// advance 推进已到期的审批：待审批超时后为 timed_out，等待时间结束后为 approved。返回状态是否由本次修改
+func (s *approvalService) advance(approval *models.DeploymentApproval, now time.Time) (bool, error) {
+	switch {
+	case approval.Status == "pending" && !now.Before(approval.ExpiresAt):
+		reason := fmt.Sprintf("部署到环境 %s 的审批超时：%s 内未获得足够的批准",
+			approval.EnvironmentName, approval.ExpiresAt.Sub(approval.CreatedAt).Round(time.Second))
+		return s.transition(approval, map[string]interface{}{
+			"status":     "timed_out",
+			"reason":     reason,
+			"decided_at": now,
+		})
+	case approval.Status == "waiting" && approval.WaitUntil != nil && !now.Before(*approval.WaitUntil):
+		return s.transition(approval, map[string]interface{}{"status": "approved"})
+	}
+	return false, nil
+}
+
+// transition 以当前状态为条件更新审批并重新读取，状态已被其他请求或实例修改时以数据库中的为准
+func (s *approvalService) transition(approval *models.DeploymentApproval, updates map[string]interface{}) (bool, error) {
+	updates["updated_at"] = time.Now()
+	result := s.db.Model(&models.DeploymentApproval{}).
+		Where("id = ? AND status = ?", approval.ID, approval.Status).
+		Updates(updates)
+	if result.Error != nil {
+		return false, fmt.Errorf("更新部署审批失败: %w", result.Error)
+	}
+	if err := s.db.Where("id = ?", approval.ID).First(approval).Error; err != nil {
+		return false, fmt.Errorf("查询部署审批失败: %w", err)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetByID 获取审批及审批记录
+func (s *approvalService) GetByID(tenantID, id uuid.UUID) (*models.DeploymentApproval, error) {
+	var approval models.DeploymentApproval
+	if err := s.db.Preload("Decisions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	}).Where("id = ?", id).First(&approval).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrApprovalNotFound
+		}
+		return nil, fmt.Errorf("获取部署审批失败: %w", err)
+	}
+	if err := checkProjectTenant(s.db, tenantID, approval.ProjectID); err != nil {
+		if errors.Is(err, ErrProjectNotFound) {
+			return nil, ErrApprovalNotFound
+		}
+		return nil, err
+	}
+	return &approval, nil
+}
+
+// ListByRun 获取流水线运行的审批列表
+func (s *approvalService) ListByRun(tenantID, runID uuid.UUID) ([]models.DeploymentApproval, error) {
+	var approvals []models.DeploymentApproval
+	if err := s.db.Preload("Decisions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	}).Where("pipeline_run_id = ? AND project_id IN (?)", runID, s.tenantProjects(tenantID)).
+		Order("created_at ASC").
+		Find(&approvals).Error; err != nil {
+		return nil, fmt.Errorf("获取部署审批列表失败: %w", err)
+	}
+	return approvals, nil
+}
+
+// ListPending 获取当前用户可以审批的待审批列表，不包含已审批过的和禁止自审的
+func (s *approvalService) ListPending(tenantID uuid.UUID, actor ApprovalActor) ([]models.DeploymentApproval, error) {
+	var approvals []models.DeploymentApproval
+	if err := s.db.Preload("Decisions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	}).Where("status = ? AND expires_at > ? AND project_id IN (?)", "pending", time.Now(), s.tenantProjects(tenantID)).
+		Order("created_at ASC").
+		Find(&approvals).Error; err != nil {
+		return nil, fmt.Errorf("获取待审批列表失败: %w", err)
+	}
+
+	pending := make([]models.DeploymentApproval, 0, len(approvals))
+	for _, approval := range approvals {
+		if s.checkActor(&approval, actor) == nil {
+			pending = append(pending, approval)
+		}
+	}
+	return pending, nil
+}
+
+// Decide 批准或拒绝部署。任一审批人拒绝即拒绝；批准数达到要求后通过，配置了等待时间时先进入等待
+func (s *approvalService) Decide(tenantID, id uuid.UUID, actor ApprovalActor, decision string, comment *string) (*models.DeploymentApproval, error) {
+	if decision != "approved" && decision != "rejected" {
+		return nil, fmt.Errorf("不支持的审批结果: %s", decision)
+	}
+	approval, err := s.GetByID(tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if changed, err := s.advance(approval, time.Now()); err != nil {
+		return nil, err
+	} else if changed {
+		s.resolved(approval.PipelineRunID)
+	}
+	if approval.Status != "pending" {
+		return nil, fmt.Errorf("%w(%s)", ErrApprovalClosed, approval.Status)
+	}
+	if err := s.checkActor(approval, actor); err != nil {
+		return nil, err
+	}
+
+	record := &models.ApprovalDecision{
+		ApprovalID: approval.ID,
+		UserID:     actor.UserID,
+		Role:       actor.Role,
+		Decision:   decision,
+		Comment:    comment,
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		return nil, fmt.Errorf("记录审批结果失败: %w", err)
+	}
+
+	now := time.Now()
+	changed := false
+	if decision == "rejected" {
+		reason := fmt.Sprintf("部署到环境 %s 的审批被拒绝(%s)", approval.EnvironmentName, actor.UserID)
+		if comment != nil && strings.TrimSpace(*comment) != "" {
+			reason += ": " + strings.TrimSpace(*comment)
+		}
+		changed, err = s.transition(approval, map[string]interface{}{
+			"status":     "rejected",
+			"reason":     reason,
+			"decided_at": now,
+		})
+	} else {
+		var approvals int64
+		if err := s.db.Model(&models.ApprovalDecision{}).
+			Where("approval_id = ? AND decision = ?", approval.ID, "approved").
+			Count(&approvals).Error; err != nil {
+			return nil, fmt.Errorf("统计审批结果失败: %w", err)
+		}
+		if int(approvals) >= approval.RequiredApprovals {
+			updates := map[string]interface{}{
+				"status":     "approved",
+				"decided_at": now,
+			}
+			if approval.WaitTimer > 0 {
+				updates["status"] = "waiting"
+				updates["wait_until"] = now.Add(time.Duration(approval.WaitTimer) * time.Second)
+			}
+			changed, err = s.transition(approval, updates)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if changed && approval.Status != "waiting" {
+		s.resolved(approval.PipelineRunID)
+	}
+	return s.GetByID(tenantID, id)
+}
+
+// checkActor 检查用户能否审批：须匹配审批人，未审批过，且开启禁止自审时不是运行触发人
+func (s *approvalService) checkActor(approval *models.DeploymentApproval, actor ApprovalActor) error {
+	var approvers []EnvironmentApprover
+	if err := jsonUnmarshal(approval.Approvers, &approvers); err != nil {
+		return fmt.Errorf("解析审批人失败: %w", err)
+	}
+	matched := false
+	for _, approver := range approvers {
+		switch approver.Type {
+		case "user":
+			matched = approver.UserID != nil && *approver.UserID == actor.UserID
+		case "role":
+			matched = actor.Role != "" && strings.EqualFold(approver.Role, actor.Role)
+		}
+		if matched {
+			break
+		}
+	}
+	if !matched {
+		return fmt.Errorf("%w: 当前用户不是环境 %s 的审批人", ErrApprovalForbidden, approval.EnvironmentName)
+	}
+	if approval.PreventSelfReview && approval.RequestedBy != nil && *approval.RequestedBy == actor.UserID {
+		return fmt.Errorf("%w: 环境 %s 禁止审批自己触发的部署", ErrApprovalForbidden, approval.EnvironmentName)
+	}
+	for _, decision := range approval.Decisions {
+		if decision.UserID == actor.UserID {
+			return ErrApprovalDecided
+		}
+	}
+	return nil
+}
+
+// ProcessDue 取消所属运行已结束的审批，推进超时和等待时间已到的审批并唤醒对应的运行
+func (s *approvalService) ProcessDue() (int, error) {
+	now := time.Now()
+	result := s.db.Model(&models.DeploymentApproval{}).
+		Where("status IN ? AND pipeline_run_id IN (?)", []string{"pending", "waiting"},
+			s.db.Model(&models.PipelineRun{}).Select("id").Where("status NOT IN ?", []string{"pending", "running"})).
+		Updates(map[string]interface{}{
+			"status":     "cancelled",
+			"reason":     "流水线运行已结束",
+			"decided_at": now,
+			"updated_at": now,
+		})
+	if result.Error != nil {
+		return 0, fmt.Errorf("取消部署审批失败: %w", result.Error)
+	}
+	processed := int(result.RowsAffected)
+
+	var due []models.DeploymentApproval
+	if err := s.db.Where("(status = ? AND expires_at <= ?) OR (status = ? AND wait_until <= ?)",
+		"pending", now, "waiting", now).Find(&due).Error; err != nil {
+		return processed, fmt.Errorf("查询到期部署审批失败: %w", err)
+	}
+	for i := range due {
+		changed, err := s.advance(&due[i], now)
+		if err != nil {
+			return processed, err
+		}
+		if changed {
+			processed++
+			s.resolved(due[i].PipelineRunID)
+		}
+	}
+	return processed, nil
+}
+
+// OnResolved 注册审批结束后的回调
+func (s *approvalService) OnResolved(listener func(runID uuid.UUID)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// resolved 异步通知审批结束，回调可能需要等待调度锁
+func (s *approvalService) resolved(runID uuid.UUID) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, listener := range s.listeners {
+		go listener(runID)
+	}
+}
+
+// tenantProjects 租户项目ID子查询
+func (s *approvalService) tenantProjects(tenantID uuid.UUID) *gorm.DB {
+	return s.db.Model(&models.Project{}).Select("id").Where("tenant_id = ?", tenantID)
+}
+
+// notifyApprovers 通知审批人有待审批的部署：配置了 notification.webhook_url 时发送 approval.requested 事件
+func (s *approvalService) notifyApprovers(approval models.DeploymentApproval) {
+	log.Printf("🔔 任务 %s 部署到环境 %s 等待审批，截止 %s (审批ID: %s)",
+		approval.TaskName, approval.EnvironmentName, approval.ExpiresAt.Format(time.RFC3339), approval.ID)
+
+	webhookURL := s.config.Notification.WebhookURL
+	if webhookURL == "" {
+		return
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"event":    "approval.requested",
+		"approval": approval,
+	})
+	if err != nil {
+		log.Printf("⚠️ 序列化审批通知失败 %s: %v", approval.ID, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("⚠️ 创建审批通知请求失败: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		log.Printf("⚠️ 发送审批通知失败 %s: %v", approval.ID, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("⚠️ 审批通知返回状态 %d: %s", resp.StatusCode, approval.ID)
+	}
+}
@@ -0,0 +1,763 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"cicd-service/internal/config"
+	"cicd-service/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// artifactMaxFilesPerTask 单个任务最多收集的产物文件数
+	artifactMaxFilesPerTask = 10000
+	// artifactCleanupBatchSize 单次清理处理的过期产物数
+	artifactCleanupBatchSize = 1000
+)
+
+var (
+	// ErrArtifactTooLarge 产物文件超过大小上限
+	ErrArtifactTooLarge = errors.New("产物文件超过大小上限")
+	// ErrArtifactChecksumMismatch 产物内容与声明的SHA-256不一致
+	ErrArtifactChecksumMismatch = errors.New("产物校验和不匹配")
+	// ErrArtifactNotFound 产物或其所属的流水线运行不存在
+	ErrArtifactNotFound = errors.New("产物不存在")
+	// ErrArtifactRunActive 流水线运行尚未结束
+	ErrArtifactRunActive = errors.New("流水线运行尚未结束，不能删除产物")
+)
+
+// ArtifactService 构建产物服务。
+// 产物按文件存入对象存储：artifacts/<运行ID>/<任务运行ID>/<相对路径>，
+// 数据库记录文件大小、SHA-256和过期时间，过期产物由后台任务清理。
+type ArtifactService interface {
+	// Store 保存一个产物文件，同一任务运行的相同路径会被覆盖
+	Store(ctx context.Context, upload *ArtifactUpload, reader io.Reader) (*models.Artifact, error)
+	// CollectWorkspace 按产物声明从工作空间收集文件并保存
+	CollectWorkspace(ctx context.Context, upload *ArtifactUpload, spec *ArtifactSpec, workspace string) ([]models.Artifact, error)
+	// ResolveDependencies 解析任务依赖的产物
+	ResolveDependencies(pipeline *models.Pipeline, runID uuid.UUID, deps []ArtifactDependency) ([]ResolvedArtifact, error)
+	// FetchToWorkspace 将依赖的产物下载到工作空间并校验SHA-256
+	FetchToWorkspace(ctx context.Context, artifacts []ResolvedArtifact, workspace string) error
+	// Open 读取产物内容
+	Open(ctx context.Context, artifact *models.Artifact) (io.ReadCloser, error)
+	// ExpireDays 计算产物保留天数：任务声明 > 流水线配置 > 全局配置，0 表示不过期
+	ExpireDays(pipeline *models.Pipeline, spec *ArtifactSpec) int
+	// ListByRun 列出租户下流水线运行的产物
+	ListByRun(tenantID, runID uuid.UUID) ([]models.Artifact, error)
+	// GetByRun 获取租户下流水线运行的单个产物
+	GetByRun(tenantID, runID, artifactID uuid.UUID) (*models.Artifact, error)
+	// LatestForPipeline 获取流水线最近一次成功运行的产物，可按分支和任务过滤
+	LatestForPipeline(tenantID, pipelineID uuid.UUID, branch, task string) (*models.PipelineRun, []models.Artifact, error)
+	// DeleteByRun 删除租户下流水线运行的全部产物，返回删除的文件数
+	DeleteByRun(tenantID, runID uuid.UUID) (int, error)
+	// DeleteByTaskRun 删除任务运行的全部产物（如作业重新分配后从头执行）
+	DeleteByTaskRun(ctx context.Context, taskRunID uuid.UUID) error
+	// CleanupExpired 清理过期产物，返回删除的文件数
+	CleanupExpired() (int, error)
+}
+
+// ArtifactUpload 产物归属信息
+type ArtifactUpload struct {
+	PipelineID     uuid.UUID
+	PipelineRunID  uuid.UUID
+	TaskRunID      uuid.UUID
+	TaskName       string
+	Name           string // 产物名称，缺省为任务名
+	Path           string // 相对工作空间的文件路径
+	ExpireInDays   int    // 保留天数，0 表示不过期
+	ExpectedSHA256 string // 上传方声明的SHA-256，非空时校验
+}
+
+// ResolvedArtifact 解析后的依赖产物，下载到工作空间的 Target/Path
+type ResolvedArtifact struct {
+	models.Artifact
+	Target string
+}
+
+// DestinationPath 产物在工作空间中的相对路径
+func (a *ResolvedArtifact) DestinationPath() string {
+	return path.Join(a.Target, a.Path)
+}
+
+// artifactService 构建产物服务实现
+type artifactService struct {
+	db      *gorm.DB
+	config  *config.Config
+	storage ObjectStorage
+}
+
+// NewArtifactService 创建构建产物服务
+func NewArtifactService(db *gorm.DB, cfg *config.Config, storage ObjectStorage) ArtifactService {
+	return &artifactService{
+		db:      db,
+		config:  cfg,
+		storage: storage,
+	}
+}
+
+// runArtifactPrefix 运行产物的对象键前缀
+func runArtifactPrefix(runID uuid.UUID) string {
+	return "artifacts/" + runID.String() + "/"
+}
+
+// taskArtifactSpec 读取任务的产物声明
+func taskArtifactSpec(task *models.Task) *ArtifactSpec {
+	if len(task.Artifacts) == 0 || string(task.Artifacts) == "null" {
+		return nil
+	}
+	var spec ArtifactSpec
+	if err := jsonUnmarshal(task.Artifacts, &spec); err != nil || len(spec.Paths) == 0 {
+		return nil
+	}
+	return &spec
+}
+
+// taskArtifactDependencies 读取任务的产物依赖
+func taskArtifactDependencies(task *models.Task) []ArtifactDependency {
+	var deps []ArtifactDependency
+	if len(task.ArtifactsFrom) > 0 {
+		if err := jsonUnmarshal(task.ArtifactsFrom, &deps); err != nil {
+			return nil
+		}
+	}
+	return deps
+}
+
+// ArtifactWhenMatches 判断任务以 status 结束时是否收集产物
+func ArtifactWhenMatches(when, status string) bool {
+	switch when {
+	case ArtifactWhenAlways:
+		return status == "succeeded" || status == "failed"
+	case ArtifactWhenOnFailure:
+		return status == "failed"
+	default:
+		return status == "succeeded"
+	}
+}
+
+// maxArtifactSize 单个产物文件大小上限
+func (s *artifactService) maxArtifactSize() int64 {
+	if s.config.Storage.ArtifactMaxSizeMB <= 0 {
+		return 1024 << 20
+	}
+	return int64(s.config.Storage.ArtifactMaxSizeMB) << 20
+}
+
+// Store 先写入临时文件计算大小和SHA-256，校验通过后再写入对象存储
+func (s *artifactService) Store(ctx context.Context, upload *ArtifactUpload, reader io.Reader) (*models.Artifact, error) {
+	if !isRelativeWorkspacePath(upload.Path) || path.Clean(upload.Path) != upload.Path || upload.Path == "." {
+		return nil, fmt.Errorf("无效的产物路径: %s", upload.Path)
+	}
+
+	spool, err := os.CreateTemp("", "artifact-*")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+
+	maxSize := s.maxArtifactSize()
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, hash), io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("接收产物失败: %w", err)
+	}
+	if size > maxSize {
+		return nil, fmt.Errorf("%w: %s 超过 %dMB", ErrArtifactTooLarge, upload.Path, maxSize>>20)
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	if upload.ExpectedSHA256 != "" && !strings.EqualFold(upload.ExpectedSHA256, checksum) {
+		return nil, fmt.Errorf("%w: %s", ErrArtifactChecksumMismatch, upload.Path)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("读取临时文件失败: %w", err)
+	}
+
+	key := runArtifactPrefix(upload.PipelineRunID) + upload.TaskRunID.String() + "/" + upload.Path
+	if _, err := s.storage.Put(ctx, key, spool); err != nil {
+		return nil, fmt.Errorf("保存产物失败: %w", err)
+	}
+
+	name := upload.Name
+	if name == "" {
+		name = upload.TaskName
+	}
+	artifact := &models.Artifact{
+		PipelineID:    upload.PipelineID,
+		PipelineRunID: upload.PipelineRunID,
+		TaskRunID:     upload.TaskRunID,
+		TaskName:      upload.TaskName,
+		Name:          name,
+		Path:          upload.Path,
+		StorageKey:    key,
+		Size:          size,
+		SHA256:        checksum,
+	}
+	if upload.ExpireInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, upload.ExpireInDays)
+		artifact.ExpiresAt = &expiresAt
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("task_run_id = ? AND path = ?", upload.TaskRunID, upload.Path).
+			Delete(&models.Artifact{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(artifact).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.PipelineRun{}).
+			Where("id = ? AND artifacts_path IS NULL", upload.PipelineRunID).
+			Update("artifacts_path", runArtifactPrefix(upload.PipelineRunID)).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("保存产物记录失败: %w", err)
+	}
+	return artifact, nil
+}
+
+// CollectWorkspace 遍历工作空间收集匹配的普通文件，符号链接不会被跟随
+func (s *artifactService) CollectWorkspace(ctx context.Context, upload *ArtifactUpload, spec *ArtifactSpec, workspace string) ([]models.Artifact, error) {
+	files, err := MatchArtifactFiles(workspace, spec.Paths)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := make([]models.Artifact, 0, len(files))
+	for _, rel := range files {
+		if err := ctx.Err(); err != nil {
+			return artifacts, err
+		}
+
+		file, err := os.Open(filepath.Join(workspace, filepath.FromSlash(rel)))
+		if err != nil {
+			return artifacts, fmt.Errorf("读取产物文件失败: %w", err)
+		}
+		fileUpload := *upload
+		fileUpload.Name = spec.Name
+		fileUpload.Path = rel
+		artifact, err := s.Store(ctx, &fileUpload, file)
+		file.Close()
+		if err != nil {
+			return artifacts, err
+		}
+		artifacts = append(artifacts, *artifact)
+	}
+	return artifacts, nil
+}
+
+// MatchArtifactFiles 返回工作空间中匹配 patterns 的普通文件（相对路径，按路径排序）。
+// 不含通配符的路径匹配文件本身或目录下的全部文件；通配符按路径段匹配，** 匹配任意层目录，
+// 匹配到目录时同样收集其下的全部文件
+func MatchArtifactFiles(workspace string, patterns []string) ([]string, error) {
+	realWorkspace, err := filepath.EvalSymlinks(workspace)
+	if err != nil {
+		return nil, fmt.Errorf("读取工作空间失败: %w", err)
+	}
+
+	matched := make(map[string]bool)
+	for _, pattern := range patterns {
+		pattern = path.Clean(strings.TrimSuffix(pattern, "/"))
+		if !isRelativeWorkspacePath(pattern) {
+			return nil, fmt.Errorf("无效的产物路径: %s", pattern)
+		}
+		patternSegments := strings.Split(pattern, "/")
+		if pattern == "." {
+			patternSegments = []string{"**"}
+		}
+
+		// 从第一个含通配符的路径段之前的目录开始遍历
+		var base []string
+		for _, segment := range patternSegments {
+			if strings.ContainsAny(segment, "*?[") {
+				break
+			}
+			base = append(base, segment)
+		}
+		root := filepath.Join(workspace, filepath.FromSlash(path.Join(base...)))
+		// 起始目录经由符号链接指向工作空间之外时不收集
+		if realRoot, err := filepath.EvalSymlinks(root); err != nil {
+			continue
+		} else if rel, err := filepath.Rel(realWorkspace, realRoot); err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+
+		err := filepath.WalkDir(root, func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+
+			rel, err := filepath.Rel(workspace, filePath)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if matched[rel] || !matchArtifactPattern(patternSegments, strings.Split(rel, "/")) {
+				return nil
+			}
+			if len(matched) >= artifactMaxFilesPerTask {
+				return fmt.Errorf("产物文件数超过上限 %d", artifactMaxFilesPerTask)
+			}
+			matched[rel] = true
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("收集产物失败: %w", err)
+		}
+	}
+
+	files := make([]string, 0, len(matched))
+	for rel := range matched {
+		files = append(files, rel)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// ArtifactPathMatches 判断相对路径是否匹配任一产物路径声明
+func ArtifactPathMatches(patterns []string, rel string) bool {
+	if !isRelativeWorkspacePath(rel) || path.Clean(rel) != rel {
+		return false
+	}
+	segments := strings.Split(rel, "/")
+	for _, pattern := range patterns {
+		pattern = path.Clean(strings.TrimSuffix(pattern, "/"))
+		patternSegments := strings.Split(pattern, "/")
+		if pattern == "." {
+			patternSegments = []string{"**"}
+		}
+		if matchArtifactPattern(patternSegments, segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchArtifactPattern 判断文件路径或其所在的某级目录是否匹配模式
+func matchArtifactPattern(pattern, segments []string) bool {
+	for i := 1; i <= len(segments); i++ {
+		if matchPathSegments(pattern, segments[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPathSegments 按路径段匹配，** 匹配零个或多个路径段
+func matchPathSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchPathSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchPathSegments(pattern[1:], segments[1:])
+}
+
+// ResolveDependencies 解析产物依赖：指定 run_id 时取同项目中该运行的产物，未指定 pipeline 时取本次运行的产物，
+// 否则取同项目流水线最近一次成功且产物未过期的运行
+func (s *artifactService) ResolveDependencies(pipeline *models.Pipeline, runID uuid.UUID, deps []ArtifactDependency) ([]ResolvedArtifact, error) {
+	var resolved []ResolvedArtifact
+	for _, dep := range deps {
+		sourceRunID := runID
+		if dep.RunID != nil {
+			if err := s.checkProjectRun(pipeline.ProjectID, *dep.RunID); err != nil {
+				return nil, err
+			}
+			sourceRunID = *dep.RunID
+		} else if dep.Pipeline != "" {
+			source, err := s.findProjectPipeline(pipeline.ProjectID, dep.Pipeline)
+			if err != nil {
+				return nil, err
+			}
+			run, err := s.latestRunWithArtifacts(source.ID, dep.Branch, dep.Task)
+			if err != nil {
+				return nil, fmt.Errorf("流水线 %s 的任务 %s 没有可用的产物: %w", dep.Pipeline, dep.Task, err)
+			}
+			sourceRunID = run.ID
+		}
+
+		query := s.db.Where("pipeline_run_id = ? AND task_name = ?", sourceRunID, dep.Task).
+			Where("expires_at IS NULL OR expires_at > ?", time.Now())
+		if dep.Name != "" {
+			query = query.Where("name = ?", dep.Name)
+		}
+		var artifacts []models.Artifact
+		if err := query.Order("path ASC").Find(&artifacts).Error; err != nil {
+			return nil, fmt.Errorf("查询依赖产物失败: %w", err)
+		}
+		for _, artifact := range artifacts {
+			resolved = append(resolved, ResolvedArtifact{Artifact: artifact, Target: path.Clean("/" + dep.Target)[1:]})
+		}
+	}
+	return resolved, nil
+}
+
+// findProjectPipeline 按ID或名称查找同项目下的流水线
+func (s *artifactService) findProjectPipeline(projectID uuid.UUID, ref string) (*models.Pipeline, error) {
+	query := s.db.Where("project_id = ? AND deleted_at IS NULL", projectID)
+	if id, err := uuid.Parse(ref); err == nil {
+		query = query.Where("id = ?", id)
+	} else {
+		query = query.Where("name = ?", ref)
+	}
+
+	var pipeline models.Pipeline
+	if err := query.First(&pipeline).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("产物来源流水线 %s 不存在", ref)
+		}
+		return nil, fmt.Errorf("查询产物来源流水线失败: %w", err)
+	}
+	return &pipeline, nil
+}
+
+// checkProjectRun 检查产物来源运行属于同一项目
+func (s *artifactService) checkProjectRun(projectID, runID uuid.UUID) error {
+	var count int64
+	if err := s.db.Model(&models.PipelineRun{}).
+		Joins("JOIN pipelines ON pipelines.id = pipeline_runs.pipeline_id").
+		Where("pipeline_runs.id = ? AND pipelines.project_id = ?", runID, projectID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("查询产物来源运行失败: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("产物来源运行 %s 不存在", runID)
+	}
+	return nil
+}
+
+// latestRunWithArtifacts 查找流水线最近一次成功且有未过期产物的运行
+func (s *artifactService) latestRunWithArtifacts(pipelineID uuid.UUID, branch, task string) (*models.PipelineRun, error) {
+	exists := "EXISTS (SELECT 1 FROM artifacts WHERE artifacts.pipeline_run_id = pipeline_runs.id " +
+		"AND (artifacts.expires_at IS NULL OR artifacts.expires_at > ?)"
+	args := []interface{}{time.Now()}
+	if task != "" {
+		exists += " AND artifacts.task_name = ?"
+		args = append(args, task)
+	}
+	exists += ")"
+
+	query := s.db.Where("pipeline_id = ? AND status = ?", pipelineID, "succeeded").Where(exists, args...)
+	if branch != "" {
+		query = query.Where("branch = ?", branch)
+	}
+
+	var run models.PipelineRun
+	if err := query.Order("created_at DESC").First(&run).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("没有成功运行")
+		}
+		return nil, err
+	}
+	return &run, nil
+}
+
+// FetchToWorkspace 下载产物到工作空间，先写入临时文件，校验通过后再重命名
+func (s *artifactService) FetchToWorkspace(ctx context.Context, artifacts []ResolvedArtifact, workspace string) error {
+	for i := range artifacts {
+		artifact := &artifacts[i]
+		dest := artifact.DestinationPath()
+		if !isRelativeWorkspacePath(dest) {
+			return fmt.Errorf("无效的产物路径: %s", dest)
+		}
+		if err := s.fetchFile(ctx, artifact, filepath.Join(workspace, filepath.FromSlash(dest))); err != nil {
+			return fmt.Errorf("下载产物 %s 失败: %w", artifact.Path, err)
+		}
+	}
+	return nil
+}
+
+func (s *artifactService) fetchFile(ctx context.Context, artifact *ResolvedArtifact, dest string) error {
+	reader, err := s.storage.Get(ctx, artifact.StorageKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(dest), ".artifact-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, hash), reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != artifact.SHA256 {
+		return ErrArtifactChecksumMismatch
+	}
+	if err := os.Chmod(file.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), dest)
+}
+
+// Open 读取产物内容
+func (s *artifactService) Open(ctx context.Context, artifact *models.Artifact) (io.ReadCloser, error) {
+	reader, err := s.storage.Get(ctx, artifact.StorageKey)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			return nil, fmt.Errorf("%w: 产物内容已被删除", ErrArtifactNotFound)
+		}
+		return nil, fmt.Errorf("读取产物失败: %w", err)
+	}
+	return reader, nil
+}
+
+// ExpireDays 计算产物保留天数
+func (s *artifactService) ExpireDays(pipeline *models.Pipeline, spec *ArtifactSpec) int {
+	if spec != nil && spec.ExpireInDays > 0 {
+		return spec.ExpireInDays
+	}
+	if pipeline != nil && pipeline.Config.ArtifactExpireDays > 0 {
+		return pipeline.Config.ArtifactExpireDays
+	}
+	if s.config.Storage.ArtifactRetentionDays > 0 {
+		return s.config.Storage.ArtifactRetentionDays
+	}
+	return 0
+}
+
+// tenantRun 获取租户下的流水线运行
+func (s *artifactService) tenantRun(tenantID, runID uuid.UUID) (*models.PipelineRun, error) {
+	var run models.PipelineRun
+	if err := s.db.Model(&models.PipelineRun{}).
+		Joins("JOIN pipelines ON pipelines.id = pipeline_runs.pipeline_id").
+		Joins("JOIN projects ON projects.id = pipelines.project_id").
+		Where("pipeline_runs.id = ? AND projects.tenant_id = ?", runID, tenantID).
+		First(&run).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: 流水线运行不存在", ErrArtifactNotFound)
+		}
+		return nil, fmt.Errorf("获取流水线运行失败: %w", err)
+	}
+	return &run, nil
+}
+
+// ListByRun 列出流水线运行的产物
+func (s *artifactService) ListByRun(tenantID, runID uuid.UUID) ([]models.Artifact, error) {
+	if _, err := s.tenantRun(tenantID, runID); err != nil {
+		return nil, err
+	}
+
+	var artifacts []models.Artifact
+	if err := s.db.Where("pipeline_run_id = ?", runID).
+		Order("task_name ASC, path ASC").
+		Find(&artifacts).Error; err != nil {
+		return nil, fmt.Errorf("获取产物列表失败: %w", err)
+	}
+	return artifacts, nil
+}
+
+// GetByRun 获取流水线运行的单个产物
+func (s *artifactService) GetByRun(tenantID, runID, artifactID uuid.UUID) (*models.Artifact, error) {
+	if _, err := s.tenantRun(tenantID, runID); err != nil {
+		return nil, err
+	}
+
+	var artifact models.Artifact
+	if err := s.db.Where("id = ? AND pipeline_run_id = ?", artifactID, runID).First(&artifact).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrArtifactNotFound
+		}
+		return nil, fmt.Errorf("获取产物失败: %w", err)
+	}
+	return &artifact, nil
+}
+
+// LatestForPipeline 获取流水线最近一次成功运行的产物
+func (s *artifactService) LatestForPipeline(tenantID, pipelineID uuid.UUID, branch, task string) (*models.PipelineRun, []models.Artifact, error) {
+	var count int64
+	if err := s.db.Model(&models.Pipeline{}).
+		Joins("JOIN projects ON projects.id = pipelines.project_id").
+		Where("pipelines.id = ? AND pipelines.deleted_at IS NULL AND projects.tenant_id = ?", pipelineID, tenantID).
+		Count(&count).Error; err != nil {
+		return nil, nil, fmt.Errorf("获取流水线失败: %w", err)
+	}
+	if count == 0 {
+		return nil, nil, fmt.Errorf("%w: 流水线不存在", ErrArtifactNotFound)
+	}
+
+	run, err := s.latestRunWithArtifacts(pipelineID, branch, task)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: 没有可用的产物", ErrArtifactNotFound)
+	}
+
+	query := s.db.Where("pipeline_run_id = ?", run.ID).Where("expires_at IS NULL OR expires_at > ?", time.Now())
+	if task != "" {
+		query = query.Where("task_name = ?", task)
+	}
+	var artifacts []models.Artifact
+	if err := query.Order("task_name ASC, path ASC").Find(&artifacts).Error; err != nil {
+		return nil, nil, fmt.Errorf("获取产物列表失败: %w", err)
+	}
+	return run, artifacts, nil
+}
+
+// DeleteByRun 删除流水线运行的全部产物，运行中的流水线不允许删除
+func (s *artifactService) DeleteByRun(tenantID, runID uuid.UUID) (int, error) {
+	run, err := s.tenantRun(tenantID, runID)
+	if err != nil {
+		return 0, err
+	}
+	if run.Status == "pending" || run.Status == "running" {
+		return 0, ErrArtifactRunActive
+	}
+
+	if err := s.storage.DeletePrefix(context.Background(), runArtifactPrefix(runID)); err != nil {
+		return 0, fmt.Errorf("删除产物失败: %w", err)
+	}
+
+	result := s.db.Where("pipeline_run_id = ?", runID).Delete(&models.Artifact{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("删除产物记录失败: %w", result.Error)
+	}
+	if err := s.db.Model(&models.PipelineRun{}).Where("id = ?", runID).
+		Update("artifacts_path", nil).Error; err != nil {
+		return 0, fmt.Errorf("更新流水线运行失败: %w", err)
+	}
+	return int(result.RowsAffected), nil
+}
+
+// DeleteByTaskRun 删除任务运行的全部产物
+func (s *artifactService) DeleteByTaskRun(ctx context.Context, taskRunID uuid.UUID) error {
+	var artifacts []models.Artifact
+	if err := s.db.Where("task_run_id = ?", taskRunID).Find(&artifacts).Error; err != nil {
+		return fmt.Errorf("查询产物失败: %w", err)
+	}
+	if len(artifacts) == 0 {
+		return nil
+	}
+	return s.deleteArtifacts(ctx, artifacts)
+}
+
+// deleteArtifacts 删除产物对象和记录
+func (s *artifactService) deleteArtifacts(ctx context.Context, artifacts []models.Artifact) error {
+	ids := make([]uuid.UUID, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		if err := s.storage.Delete(ctx, artifact.StorageKey); err != nil {
+			return fmt.Errorf("删除产物失败: %w", err)
+		}
+		ids = append(ids, artifact.ID)
+	}
+	if err := s.db.Where("id IN ?", ids).Delete(&models.Artifact{}).Error; err != nil {
+		return fmt.Errorf("删除产物记录失败: %w", err)
+	}
+	return nil
+}
+
+// CleanupExpired 清理过期产物。流水线开启 keep_latest_artifacts 时，
+// 各分支最近一次成功运行的产物即使过期也会保留
+func (s *artifactService) CleanupExpired() (int, error) {
+	ctx := context.Background()
+	cleaned := 0
+	var skipped []uuid.UUID // 保留或删除失败的运行，后续批次不再查询
+	for {
+		query := s.db.Where("expires_at IS NOT NULL AND expires_at < ?", time.Now())
+		if len(skipped) > 0 {
+			query = query.Where("pipeline_run_id NOT IN ?", skipped)
+		}
+		var expired []models.Artifact
+		if err := query.Order("pipeline_run_id").Limit(artifactCleanupBatchSize).Find(&expired).Error; err != nil {
+			return cleaned, fmt.Errorf("查询过期产物失败: %w", err)
+		}
+		if len(expired) == 0 {
+			return cleaned, nil
+		}
+
+		byRun := make(map[uuid.UUID][]models.Artifact)
+		for _, artifact := range expired {
+			byRun[artifact.PipelineRunID] = append(byRun[artifact.PipelineRunID], artifact)
+		}
+
+		for runID, artifacts := range byRun {
+			if s.keepLatest(runID) {
+				skipped = append(skipped, runID)
+				continue
+			}
+			if err := s.deleteArtifacts(ctx, artifacts); err != nil {
+				log.Printf("⚠️ 删除过期产物失败 %s: %v", runID, err)
+				skipped = append(skipped, runID)
+				continue
+			}
+			cleaned += len(artifacts)
+
+			var remaining int64
+			s.db.Model(&models.Artifact{}).Where("pipeline_run_id = ?", runID).Count(&remaining)
+			if remaining == 0 {
+				if err := s.db.Model(&models.PipelineRun{}).Where("id = ?", runID).
+					Update("artifacts_path", nil).Error; err != nil {
+					log.Printf("⚠️ 更新运行产物路径失败 %s: %v", runID, err)
+				}
+			}
+		}
+	}
+}
+
+// keepLatest 判断运行是否为开启保留最新产物的流水线在其分支上最近一次成功的运行
+func (s *artifactService) keepLatest(runID uuid.UUID) bool {
+	var run models.PipelineRun
+	if err := s.db.Preload("Pipeline").Where("id = ?", runID).First(&run).Error; err != nil {
+		return false
+	}
+	if run.Status != "succeeded" || run.Pipeline == nil || !run.Pipeline.Config.KeepLatestArtifacts {
+		return false
+	}
+
+	query := s.db.Model(&models.PipelineRun{}).Select("id").
+		Where("pipeline_id = ? AND status = ?", run.PipelineID, "succeeded")
+	if run.Branch != nil {
+		query = query.Where("branch = ?", *run.Branch)
+	} else {
+		query = query.Where("branch IS NULL")
+	}
+
+	var latest models.PipelineRun
+	if err := query.Order("created_at DESC").First(&latest).Error; err != nil {
+		return false
+	}
+	return latest.ID == runID
+}
@@ -0,0 +1,499 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"cicd-service/internal/config"
+	"cicd-service/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrDeploymentNotFound 部署记录不存在或不属于当前租户
+	ErrDeploymentNotFound = errors.New("部署记录不存在")
+	// ErrNoCurrentDeployment 环境还没有成功的部署
+	ErrNoCurrentDeployment = errors.New("环境尚无成功的部署")
+	// ErrDeploymentNotRollbackable 只能回滚到成功的部署
+	ErrDeploymentNotRollbackable = errors.New("只能回滚到成功的部署")
+	// ErrDeploymentArtifactsExpired 部署使用的产物已过期或被删除，无法回滚
+	ErrDeploymentArtifactsExpired = errors.New("部署使用的产物已过期或已删除")
+)
+
+// DeploymentService 部署记录服务，同时作为执行器的部署检查：环境保护规则通过后记录部署，
+// 部署状态跟随任务运行；提供环境的部署历史、当前部署和回滚
+type DeploymentService interface {
+	DeploymentGate
+
+	GetByID(tenantID, id uuid.UUID) (*models.Deployment, error)
+	// ListByEnvironment 分页获取环境的部署历史，按开始时间倒序
+	ListByEnvironment(tenantID, projectID, environmentID uuid.UUID, req *ListDeploymentsRequest) ([]models.Deployment, int64, error)
+	// Current 获取环境当前的部署，即最近一次成功结束的部署
+	Current(tenantID, projectID, environmentID uuid.UUID) (*models.Deployment, error)
+	// CurrentByProject 获取项目各环境当前的部署
+	CurrentByProject(tenantID, projectID uuid.UUID) ([]EnvironmentDeployment, error)
+	// Rollback 使用目标部署的提交和产物重新执行其部署任务，返回新创建的流水线运行
+	Rollback(tenantID, id, userID uuid.UUID) (*models.PipelineRun, error)
+}
+
+type deploymentService struct {
+	db         *gorm.DB
+	config     *config.Config
+	approvals  DeploymentGate
+	artifacts  ArtifactService
+	runService PipelineRunService
+}
+
+// NewDeploymentService 创建部署记录服务实例，approvals 为环境保护规则检查，为空时部署不经审批
+func NewDeploymentService(db *gorm.DB, cfg *config.Config, approvals DeploymentGate, artifacts ArtifactService, runService PipelineRunService) DeploymentService {
+	return &deploymentService{
+		db:         db,
+		config:     cfg,
+		approvals:  approvals,
+		artifacts:  artifacts,
+		runService: runService,
+	}
+}
+
+// DeploymentArtifact 部署使用的产物
+type DeploymentArtifact struct {
+	ID            uuid.UUID `json:"id"`
+	PipelineRunID uuid.UUID `json:"pipeline_run_id"` // 产出产物的运行
+	TaskName      string    `json:"task_name"`
+	Name          string    `json:"name"`
+	Path          string    `json:"path"`
+	Size          int64     `json:"size"`
+	SHA256        string    `json:"sha256"`
+	Target        string    `json:"target,omitempty"` // 下载到工作空间中的目录
+}
+
+// ListDeploymentsRequest 部署历史查询请求
+type ListDeploymentsRequest struct {
+	Status *string `json:"status"`
+	Page   int     `json:"page"`
+	Limit  int     `json:"limit"`
+}
+
+// EnvironmentDeployment 环境及其当前的部署，没有成功部署时 deployment 为空
+type EnvironmentDeployment struct {
+	EnvironmentID   uuid.UUID          `json:"environment_id"`
+	EnvironmentName string             `json:"environment_name"`
+	EnvironmentType string             `json:"environment_type"`
+	Deployment      *models.Deployment `json:"deployment"`
+}
+
+// CheckDeployment 先按环境保护规则检查，允许部署时为任务运行记录部署；记录失败时继续等待，下次检查重试
+func (s *deploymentService) CheckDeployment(req *DeploymentGateRequest) (bool, string) {
+	if s.approvals != nil {
+		if ready, reason := s.approvals.CheckDeployment(req); !ready {
+			return false, reason
+		}
+	}
+	if err := s.record(req); err != nil {
+		log.Printf("⚠️ 记录部署失败 %s: %v", req.TaskRunID, err)
+		return false, ""
+	}
+	return true, ""
+}
+
+// record 为任务运行创建部署记录，同一任务运行只记录一次
+func (s *deploymentService) record(req *DeploymentGateRequest) error {
+	var count int64
+	if err := s.db.Model(&models.Deployment{}).Where("task_run_id = ?", req.TaskRunID).Count(&count).Error; err != nil {
+		return fmt.Errorf("查询部署记录失败: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	var environment models.Environment
+	if err := s.db.Where("project_id = ? AND name = ? AND deleted_at IS NULL", req.ProjectID, req.Environment).
+		First(&environment).Error; err != nil {
+		return fmt.Errorf("查询部署环境失败: %w", err)
+	}
+
+	artifacts := make([]DeploymentArtifact, 0)
+	if len(req.ArtifactsFrom) > 0 && s.artifacts != nil {
+		pipeline := &models.Pipeline{ID: req.Run.PipelineID, ProjectID: req.ProjectID}
+		resolved, err := s.artifacts.ResolveDependencies(pipeline, req.Run.ID, req.ArtifactsFrom)
+		if err != nil {
+			// 任务下载产物时同样会失败，部署随任务结束
+			log.Printf("⚠️ 解析部署产物失败 %s: %v", req.TaskRunID, err)
+		}
+		for _, artifact := range resolved {
+			artifacts = append(artifacts, DeploymentArtifact{
+				ID:            artifact.ID,
+				PipelineRunID: artifact.PipelineRunID,
+				TaskName:      artifact.TaskName,
+				Name:          artifact.Name,
+				Path:          artifact.Path,
+				Size:          artifact.Size,
+				SHA256:        artifact.SHA256,
+				Target:        artifact.Target,
+			})
+		}
+	}
+	artifactsJSON, err := jsonMarshal(artifacts)
+	if err != nil {
+		return fmt.Errorf("序列化部署产物失败: %w", err)
+	}
+
+	deployment := &models.Deployment{
+		ID:              uuid.New(),
+		ProjectID:       req.ProjectID,
+		EnvironmentID:   environment.ID,
+		EnvironmentName: environment.Name,
+		PipelineID:      req.Run.PipelineID,
+		PipelineRunID:   req.Run.ID,
+		TaskRunID:       req.TaskRunID,
+		TaskName:        req.TaskName,
+		CommitSHA:       req.Run.CommitSHA,
+		Branch:          req.Run.Branch,
+		Artifacts:       datatypes.JSON(artifactsJSON),
+		Status:          "running",
+		DeployedBy:      req.Run.TriggerBy,
+		RollbackOf:      req.Run.RollbackOf,
+		StartedAt:       time.Now(),
+	}
+	// 多个实例同时检查时以先创建的为准
+	if err := s.db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "task_run_id"}}, DoNothing: true}).
+		Create(deployment).Error; err != nil {
+		return fmt.Errorf("创建部署记录失败: %w", err)
+	}
+	return nil
+}
+
+// syncStatus 按任务运行的结束状态更新项目中进行中的部署，任务运行记录已被清理的部署标记为失败
+func (s *deploymentService) syncStatus(projectID uuid.UUID) error {
+	var rows []struct {
+		ID           uuid.UUID
+		TaskRunID    *uuid.UUID
+		Status       *string
+		FinishedAt   *time.Time
+		ErrorMessage *string
+	}
+	if err := s.db.Table("deployments").
+		Select("deployments.id, task_runs.id AS task_run_id, task_runs.status, task_runs.finished_at, task_runs.error_message").
+		Joins("LEFT JOIN task_runs ON task_runs.id = deployments.task_run_id").
+		Where("deployments.project_id = ? AND deployments.status = ?", projectID, "running").
+		Where("task_runs.id IS NULL OR task_runs.status IN ?", []string{"succeeded", "failed", "cancelled", "skipped"}).
+		Scan(&rows).Error; err != nil {
+		return fmt.Errorf("查询进行中的部署失败: %w", err)
+	}
+
+	now := time.Now()
+	for _, row := range rows {
+		updates := map[string]interface{}{
+			"finished_at": now,
+			"updated_at":  now,
+		}
+		switch {
+		case row.TaskRunID == nil || row.Status == nil:
+			updates["status"] = "failed"
+			updates["error_message"] = "任务运行记录已清理，部署结果未知"
+		case *row.Status == "skipped":
+			updates["status"] = "cancelled"
+		default:
+			updates["status"] = *row.Status
+		}
+		if row.FinishedAt != nil {
+			updates["finished_at"] = *row.FinishedAt
+		}
+		if row.ErrorMessage != nil {
+			updates["error_message"] = *row.ErrorMessage
+		}
+
+		if err := s.db.Model(&models.Deployment{}).
+			Where("id = ? AND status = ?", row.ID, "running").
+			Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新部署状态失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetByID 获取部署记录
+func (s *deploymentService) GetByID(tenantID, id uuid.UUID) (*models.Deployment, error) {
+	deployment, err := s.find(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkProjectTenant(s.db, tenantID, deployment.ProjectID); err != nil {
+		if errors.Is(err, ErrProjectNotFound) {
+			return nil, ErrDeploymentNotFound
+		}
+		return nil, err
+	}
+	if deployment.Status != "running" {
+		return deployment, nil
+	}
+
+	if err := s.syncStatus(deployment.ProjectID); err != nil {
+		return nil, err
+	}
+	return s.find(id)
+}
+
+// find 按ID查询部署记录
+func (s *deploymentService) find(id uuid.UUID) (*models.Deployment, error) {
+	var deployment models.Deployment
+	if err := s.db.Where("id = ?", id).First(&deployment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeploymentNotFound
+		}
+		return nil, fmt.Errorf("获取部署记录失败: %w", err)
+	}
+	return &deployment, nil
+}
+
+// ListByEnvironment 分页获取环境的部署历史
+func (s *deploymentService) ListByEnvironment(tenantID, projectID, environmentID uuid.UUID, req *ListDeploymentsRequest) ([]models.Deployment, int64, error) {
+	if _, err := s.environment(tenantID, projectID, environmentID); err != nil {
+		return nil, 0, err
+	}
+	if err := s.syncStatus(projectID); err != nil {
+		return nil, 0, err
+	}
+
+	query := s.db.Model(&models.Deployment{}).Where("environment_id = ?", environmentID)
+	if req.Status != nil {
+		query = query.Where("status = ?", *req.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计部署记录失败: %w", err)
+	}
+
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+
+	var deployments []models.Deployment
+	if err := query.Order("started_at DESC").
+		Offset((req.Page - 1) * req.Limit).
+		Limit(req.Limit).
+		Find(&deployments).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取部署历史失败: %w", err)
+	}
+	return deployments, total, nil
+}
+
+// Current 获取环境当前的部署
+func (s *deploymentService) Current(tenantID, projectID, environmentID uuid.UUID) (*models.Deployment, error) {
+	if _, err := s.environment(tenantID, projectID, environmentID); err != nil {
+		return nil, err
+	}
+	if err := s.syncStatus(projectID); err != nil {
+		return nil, err
+	}
+
+	deployment, err := s.current(environmentID)
+	if err != nil {
+		return nil, err
+	}
+	if deployment == nil {
+		return nil, ErrNoCurrentDeployment
+	}
+	return deployment, nil
+}
+
+// CurrentByProject 获取项目各环境当前的部署，按环境名称排序
+func (s *deploymentService) CurrentByProject(tenantID, projectID uuid.UUID) ([]EnvironmentDeployment, error) {
+	if err := checkProjectTenant(s.db, tenantID, projectID); err != nil {
+		return nil, err
+	}
+	if err := s.syncStatus(projectID); err != nil {
+		return nil, err
+	}
+
+	var environments []models.Environment
+	if err := s.db.Where("project_id = ? AND deleted_at IS NULL", projectID).
+		Order("name ASC").
+		Find(&environments).Error; err != nil {
+		return nil, fmt.Errorf("获取环境列表失败: %w", err)
+	}
+
+	result := make([]EnvironmentDeployment, 0, len(environments))
+	for _, environment := range environments {
+		deployment, err := s.current(environment.ID)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, EnvironmentDeployment{
+			EnvironmentID:   environment.ID,
+			EnvironmentName: environment.Name,
+			EnvironmentType: environment.Type,
+			Deployment:      deployment,
+		})
+	}
+	return result, nil
+}
+
+// current 查询环境最近一次成功结束的部署，没有时返回nil
+func (s *deploymentService) current(environmentID uuid.UUID) (*models.Deployment, error) {
+	var deployment models.Deployment
+	if err := s.db.Where("environment_id = ? AND status = ?", environmentID, "succeeded").
+		Order("finished_at DESC").
+		First(&deployment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("获取当前部署失败: %w", err)
+	}
+	return &deployment, nil
+}
+
+// environment 获取租户项目下的环境
+func (s *deploymentService) environment(tenantID, projectID, environmentID uuid.UUID) (*models.Environment, error) {
+	if err := checkProjectTenant(s.db, tenantID, projectID); err != nil {
+		return nil, err
+	}
+	var environment models.Environment
+	if err := s.db.Where("id = ? AND project_id = ? AND deleted_at IS NULL", environmentID, projectID).
+		First(&environment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEnvironmentNotFound
+		}
+		return nil, fmt.Errorf("获取环境失败: %w", err)
+	}
+	return &environment, nil
+}
+
+// Rollback 回滚到目标部署：以其提交创建流水线运行，只执行其部署任务并使用其产物。
+// 回滚运行同样经过环境保护规则，成功后成为环境当前的部署
+func (s *deploymentService) Rollback(tenantID, id, userID uuid.UUID) (*models.PipelineRun, error) {
+	target, err := s.GetByID(tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if target.Status != "succeeded" {
+		return nil, fmt.Errorf("%w(%s)", ErrDeploymentNotRollbackable, target.Status)
+	}
+
+	var count int64
+	if err := s.db.Model(&models.Environment{}).
+		Where("id = ? AND deleted_at IS NULL", target.EnvironmentID).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("获取环境失败: %w", err)
+	}
+	if count == 0 {
+		return nil, ErrEnvironmentNotFound
+	}
+	if err := s.checkArtifacts(target); err != nil {
+		return nil, err
+	}
+
+	return s.runService.Create(&CreatePipelineRunRequest{
+		PipelineID:  target.PipelineID,
+		TriggerType: "rollback",
+		TriggerBy:   &userID,
+		TriggerData: map[string]interface{}{
+			"rollback_of": target.ID.String(),
+			"environment": target.EnvironmentName,
+		},
+		CommitSHA:  target.CommitSHA,
+		Branch:     target.Branch,
+		RollbackOf: &target.ID,
+	})
+}
+
+// checkArtifacts 检查部署使用的产物仍然可以下载
+func (s *deploymentService) checkArtifacts(deployment *models.Deployment) error {
+	artifacts, err := deploymentArtifacts(deployment)
+	if err != nil {
+		return err
+	}
+	if len(artifacts) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		ids = append(ids, artifact.ID)
+	}
+	var count int64
+	if err := s.db.Model(&models.Artifact{}).
+		Where("id IN ? AND (expires_at IS NULL OR expires_at > ?)", ids, time.Now()).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("查询部署产物失败: %w", err)
+	}
+	if count < int64(len(ids)) {
+		return ErrDeploymentArtifactsExpired
+	}
+	return nil
+}
+
+// deploymentArtifacts 读取部署使用的产物
+func deploymentArtifacts(deployment *models.Deployment) ([]DeploymentArtifact, error) {
+	var artifacts []DeploymentArtifact
+	if err := jsonUnmarshal(deployment.Artifacts, &artifacts); err != nil {
+		return nil, fmt.Errorf("解析部署产物失败: %w", err)
+	}
+	return artifacts, nil
+}
+
+// rollbackPipeline 构造回滚运行的流水线：只保留原部署的任务，去掉依赖和执行条件（原部署时已满足），
+// 产物依赖固定为原部署使用的产物
+func rollbackPipeline(pipeline *models.Pipeline, deployment *models.Deployment) (*models.Pipeline, error) {
+	for _, task := range pipeline.Tasks {
+		if task.Name != deployment.TaskName {
+			continue
+		}
+		if task.Environment == nil || *task.Environment != deployment.EnvironmentName {
+			return nil, fmt.Errorf("任务 %s 不再部署到环境 %s，无法回滚", task.Name, deployment.EnvironmentName)
+		}
+
+		artifacts, err := deploymentArtifacts(deployment)
+		if err != nil {
+			return nil, err
+		}
+		depsJSON, err := jsonMarshal(pinnedArtifactDependencies(artifacts))
+		if err != nil {
+			return nil, fmt.Errorf("序列化任务产物依赖失败: %w", err)
+		}
+
+		task.DependsOn = nil
+		task.Condition = nil
+		task.ArtifactsFrom = depsJSON
+		rollback := *pipeline
+		rollback.Tasks = []models.Task{task}
+		return &rollback, nil
+	}
+	return nil, fmt.Errorf("流水线中不存在部署任务 %s，无法回滚", deployment.TaskName)
+}
+
+// pinnedArtifactDependencies 将部署使用的产物转换为固定来源运行的产物依赖，保持原有的下载目录
+func pinnedArtifactDependencies(artifacts []DeploymentArtifact) []ArtifactDependency {
+	type key struct {
+		run        uuid.UUID
+		task, name string
+		target     string
+	}
+	deps := make([]ArtifactDependency, 0)
+	seen := make(map[key]bool)
+	for _, artifact := range artifacts {
+		k := key{run: artifact.PipelineRunID, task: artifact.TaskName, name: artifact.Name, target: artifact.Target}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		runID := artifact.PipelineRunID
+		deps = append(deps, ArtifactDependency{
+			Task:   artifact.TaskName,
+			Name:   artifact.Name,
+			Target: artifact.Target,
+			RunID:  &runID,
+		})
+	}
+	return deps
+}
@@ -0,0 +1,328 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"cicd-service/internal/config"
+	"cicd-service/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	// environmentMaxApprovers 单个环境最多配置的审批人数量
+	environmentMaxApprovers = 20
+	// environmentMaxDuration 等待时间和审批超时的上限(秒)
+	environmentMaxDuration = 30 * 24 * 3600
+)
+
+var (
+	// ErrEnvironmentNotFound 环境不存在
+	ErrEnvironmentNotFound = errors.New("环境不存在")
+	// ErrEnvironmentExists 同一项目下环境名称已存在
+	ErrEnvironmentExists = errors.New("环境名称已存在")
+
+	environmentTypes    = []string{"development", "staging", "production"}
+	environmentStatuses = []string{"active", "inactive", "maintenance"}
+)
+
+// EnvironmentService 部署环境服务接口
+type EnvironmentService interface {
+	Create(tenantID uuid.UUID, req *CreateEnvironmentRequest) (*models.Environment, error)
+	List(tenantID, projectID uuid.UUID) ([]models.Environment, error)
+	GetByID(tenantID, projectID, id uuid.UUID) (*models.Environment, error)
+	Update(tenantID, projectID, id uuid.UUID, req *UpdateEnvironmentRequest) (*models.Environment, error)
+	Delete(tenantID, projectID, id uuid.UUID) error
+}
+
+type environmentService struct {
+	db     *gorm.DB
+	config *config.Config
+}
+
+// NewEnvironmentService 创建部署环境服务实例
+func NewEnvironmentService(db *gorm.DB, cfg *config.Config) EnvironmentService {
+	return &environmentService{
+		db:     db,
+		config: cfg,
+	}
+}
+
+// EnvironmentProtectionRules 环境保护规则，部署到该环境的任务开始前生效
+type EnvironmentProtectionRules struct {
+	RequiredApprovers []EnvironmentApprover `json:"required_approvers,omitempty"`  // 审批人，任一匹配的用户均可审批
+	RequiredApprovals int                   `json:"required_approvals,omitempty"`  // 需要的批准数，缺省为1
+	PreventSelfReview bool                  `json:"prevent_self_review,omitempty"` // 禁止运行触发人审批自己的部署
+	WaitTimer         int                   `json:"wait_timer,omitempty"`          // 批准后（无审批人时为任务就绪后）等待的时间(秒)
+	Branches          []string              `json:"branches,omitempty"`            // 允许部署的分支或标签通配符，** 可匹配多级
+	ApprovalTimeout   int                   `json:"approval_timeout,omitempty"`    // 审批超时(秒)，缺省使用服务配置
+}
+
+// EnvironmentApprover 环境审批人：指定用户或租户角色
+type EnvironmentApprover struct {
+	Type   string     `json:"type"`              // user, role
+	UserID *uuid.UUID `json:"user_id,omitempty"` // type=user 时有效
+	Role   string     `json:"role,omitempty"`    // type=role 时有效
+}
+
+// CreateEnvironmentRequest 创建环境请求
+type CreateEnvironmentRequest struct {
+	ProjectID       uuid.UUID                   `json:"project_id"`
+	Name            string                      `json:"name" binding:"required,max=255"`
+	Type            string                      `json:"type"`
+	Namespace       string                      `json:"namespace"`
+	Config          map[string]interface{}      `json:"config"`
+	Variables       map[string]string           `json:"variables"`
+	ProtectionRules *EnvironmentProtectionRules `json:"protection_rules"`
+	CreatedBy       uuid.UUID                   `json:"-"`
+}
+
+// UpdateEnvironmentRequest 更新环境请求，字段为空时不修改；protection_rules 整体替换
+type UpdateEnvironmentRequest struct {
+	Type            *string                     `json:"type"`
+	Status          *string                     `json:"status"`
+	Namespace       *string                     `json:"namespace"`
+	Config          map[string]interface{}      `json:"config"`
+	Variables       map[string]string           `json:"variables"`
+	ProtectionRules *EnvironmentProtectionRules `json:"protection_rules"`
+}
+
+// Create 创建环境
+func (s *environmentService) Create(tenantID uuid.UUID, req *CreateEnvironmentRequest) (*models.Environment, error) {
+	if err := checkProjectTenant(s.db, tenantID, req.ProjectID); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, fmt.Errorf("环境名称不能为空")
+	}
+	if req.Type == "" {
+		req.Type = "development"
+	}
+	if req.Namespace == "" {
+		req.Namespace = req.Name
+	}
+	if !containsString(environmentTypes, req.Type) {
+		return nil, fmt.Errorf("不支持的环境类型: %s", req.Type)
+	}
+	if err := validateProtectionRules(req.ProtectionRules); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Model(&models.Environment{}).
+		Where("project_id = ? AND name = ? AND deleted_at IS NULL", req.ProjectID, req.Name).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("检查环境名称失败: %w", err)
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrEnvironmentExists, req.Name)
+	}
+
+	environment := &models.Environment{
+		ID:        uuid.New(),
+		ProjectID: req.ProjectID,
+		Name:      req.Name,
+		Type:      req.Type,
+		Status:    "active",
+		Namespace: req.Namespace,
+		CreatedBy: req.CreatedBy,
+	}
+	var err error
+	if environment.Config, err = environmentJSON(req.Config); err != nil {
+		return nil, fmt.Errorf("序列化环境配置失败: %w", err)
+	}
+	if environment.Variables, err = environmentJSON(req.Variables); err != nil {
+		return nil, fmt.Errorf("序列化环境变量失败: %w", err)
+	}
+	if environment.ProtectionRules, err = environmentJSON(req.ProtectionRules); err != nil {
+		return nil, fmt.Errorf("序列化保护规则失败: %w", err)
+	}
+
+	if err := s.db.Create(environment).Error; err != nil {
+		return nil, fmt.Errorf("创建环境失败: %w", err)
+	}
+	return environment, nil
+}
+
+// List 获取项目的环境列表
+func (s *environmentService) List(tenantID, projectID uuid.UUID) ([]models.Environment, error) {
+	if err := checkProjectTenant(s.db, tenantID, projectID); err != nil {
+		return nil, err
+	}
+
+	var environments []models.Environment
+	if err := s.db.Where("project_id = ? AND deleted_at IS NULL", projectID).
+		Order("name ASC").Find(&environments).Error; err != nil {
+		return nil, fmt.Errorf("获取环境列表失败: %w", err)
+	}
+	return environments, nil
+}
+
+// GetByID 获取环境
+func (s *environmentService) GetByID(tenantID, projectID, id uuid.UUID) (*models.Environment, error) {
+	if err := checkProjectTenant(s.db, tenantID, projectID); err != nil {
+		return nil, err
+	}
+
+	var environment models.Environment
+	if err := s.db.Where("id = ? AND project_id = ? AND deleted_at IS NULL", id, projectID).
+		First(&environment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEnvironmentNotFound
+		}
+		return nil, fmt.Errorf("获取环境失败: %w", err)
+	}
+	return &environment, nil
+}
+
+// Update 更新环境，修改保护规则不影响已创建的审批
+func (s *environmentService) Update(tenantID, projectID, id uuid.UUID, req *UpdateEnvironmentRequest) (*models.Environment, error) {
+	environment, err := s.GetByID(tenantID, projectID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"updated_at": time.Now(),
+	}
+	if req.Type != nil {
+		if !containsString(environmentTypes, *req.Type) {
+			return nil, fmt.Errorf("不支持的环境类型: %s", *req.Type)
+		}
+		updates["type"] = *req.Type
+	}
+	if req.Status != nil {
+		if !containsString(environmentStatuses, *req.Status) {
+			return nil, fmt.Errorf("不支持的环境状态: %s", *req.Status)
+		}
+		updates["status"] = *req.Status
+	}
+	if req.Namespace != nil && *req.Namespace != "" {
+		updates["namespace"] = *req.Namespace
+	}
+	if req.Config != nil {
+		if updates["config"], err = environmentJSON(req.Config); err != nil {
+			return nil, fmt.Errorf("序列化环境配置失败: %w", err)
+		}
+	}
+	if req.Variables != nil {
+		if updates["variables"], err = environmentJSON(req.Variables); err != nil {
+			return nil, fmt.Errorf("序列化环境变量失败: %w", err)
+		}
+	}
+	if req.ProtectionRules != nil {
+		if err := validateProtectionRules(req.ProtectionRules); err != nil {
+			return nil, err
+		}
+		if updates["protection_rules"], err = environmentJSON(req.ProtectionRules); err != nil {
+			return nil, fmt.Errorf("序列化保护规则失败: %w", err)
+		}
+	}
+
+	if err := s.db.Model(&models.Environment{}).Where("id = ?", environment.ID).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("更新环境失败: %w", err)
+	}
+	return s.GetByID(tenantID, projectID, id)
+}
+
+// Delete 删除环境（软删除），部署到该环境的任务将因环境不存在而失败
+func (s *environmentService) Delete(tenantID, projectID, id uuid.UUID) error {
+	environment, err := s.GetByID(tenantID, projectID, id)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Model(&models.Environment{}).Where("id = ?", environment.ID).Updates(map[string]interface{}{
+		"deleted_at": time.Now(),
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		return fmt.Errorf("删除环境失败: %w", err)
+	}
+	return nil
+}
+
+// validateProtectionRules 校验环境保护规则
+func validateProtectionRules(rules *EnvironmentProtectionRules) error {
+	if rules == nil {
+		return nil
+	}
+
+	var problems []string
+	if len(rules.RequiredApprovers) > environmentMaxApprovers {
+		problems = append(problems, fmt.Sprintf("审批人不能超过%d个", environmentMaxApprovers))
+	}
+	users := make(map[uuid.UUID]bool)
+	hasRole := false
+	for i, approver := range rules.RequiredApprovers {
+		field := fmt.Sprintf("required_approvers[%d]", i)
+		switch approver.Type {
+		case "user":
+			if approver.UserID == nil || *approver.UserID == uuid.Nil {
+				problems = append(problems, field+" 需要 user_id")
+			} else {
+				users[*approver.UserID] = true
+			}
+		case "role":
+			if strings.TrimSpace(approver.Role) == "" {
+				problems = append(problems, field+" 需要 role")
+			}
+			hasRole = true
+		default:
+			problems = append(problems, fmt.Sprintf("%s 的 type 必须是 user 或 role", field))
+		}
+	}
+	switch {
+	case rules.RequiredApprovals < 0:
+		problems = append(problems, "required_approvals 不能小于0")
+	case rules.RequiredApprovals > 0 && len(rules.RequiredApprovers) == 0:
+		problems = append(problems, "配置 required_approvals 时需要 required_approvers")
+	case !hasRole && rules.RequiredApprovals > len(users):
+		// 只有指定用户时，批准数不能超过审批人数量，否则永远无法通过
+		problems = append(problems, fmt.Sprintf("required_approvals(%d) 超过审批人数量(%d)", rules.RequiredApprovals, len(users)))
+	}
+	if rules.WaitTimer < 0 || rules.WaitTimer > environmentMaxDuration {
+		problems = append(problems, fmt.Sprintf("wait_timer 必须在0到%d秒之间", environmentMaxDuration))
+	}
+	if rules.ApprovalTimeout < 0 || rules.ApprovalTimeout > environmentMaxDuration {
+		problems = append(problems, fmt.Sprintf("approval_timeout 必须在0到%d秒之间", environmentMaxDuration))
+	}
+	for i, branch := range rules.Branches {
+		if strings.TrimSpace(branch) == "" {
+			problems = append(problems, fmt.Sprintf("branches[%d] 不能为空", i))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("保护规则无效: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// environmentProtectionRules 解析环境的保护规则，未配置时返回空规则
+func environmentProtectionRules(environment *models.Environment) (*EnvironmentProtectionRules, error) {
+	rules := &EnvironmentProtectionRules{}
+	if len(environment.ProtectionRules) == 0 {
+		return rules, nil
+	}
+	if err := json.Unmarshal(environment.ProtectionRules, rules); err != nil {
+		return nil, fmt.Errorf("解析环境 %s 的保护规则失败: %w", environment.Name, err)
+	}
+	return rules, nil
+}
+
+// environmentJSON 序列化环境的JSON字段，空值保存为空对象
+func environmentJSON(value interface{}) (datatypes.JSON, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if string(data) == "null" {
+		return datatypes.JSON("{}"), nil
+	}
+	return datatypes.JSON(data), nil
+}
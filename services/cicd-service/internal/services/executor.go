@@ -0,0 +1,644 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cicd-service/internal/config"
+	"cicd-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Executor 流水线执行器接口，负责实际执行一次流水线运行
+type Executor interface {
+	// Name 执行器名称
+	Name() string
+	// Execute 提交流水线运行，执行过程中的状态通过 ExecutionRequest.Reporter 回写
+	Execute(ctx context.Context, req *ExecutionRequest) error
+	// Cancel 取消正在执行的流水线运行
+	Cancel(ctx context.Context, runID uuid.UUID) error
+}
+
+// RunStatusReporter 运行状态回写接口，由 PipelineRunService 实现
+type RunStatusReporter interface {
+	UpdateStatus(id uuid.UUID, status string, message *string) error
+	UpdateTaskRunStatus(id uuid.UUID, update *TaskRunStatusUpdate) error
+}
+
+// ExecutionRequest 流水线执行请求
+type ExecutionRequest struct {
+	Run        *models.PipelineRun
+	Pipeline   *models.Pipeline
+	Parameters map[string]interface{}
+	TaskRunIDs map[string]uuid.UUID          // 任务名 -> 任务运行记录ID
+	Matrix     map[string]*MatrixCombination // 矩阵组合任务名 -> 组合信息，Pipeline.Tasks 中已按组合展开
+	Reporter   RunStatusReporter
+	Gate       DeploymentGate // 部署审批检查，为空时部署任务不经审批直接执行
+}
+
+// DeploymentGate 部署审批检查接口，由 DeploymentService 实现（审批由 ApprovalService 处理）
+type DeploymentGate interface {
+	// CheckDeployment 判断任务能否部署到目标环境；ready 为 false 且 reason 为空表示等待审批，reason 非空表示任务失败
+	CheckDeployment(req *DeploymentGateRequest) (ready bool, reason string)
+}
+
+// DeploymentGateRequest 部署审批检查请求
+type DeploymentGateRequest struct {
+	ProjectID     uuid.UUID
+	Run           *models.PipelineRun
+	TaskRunID     uuid.UUID
+	TaskName      string
+	Environment   string
+	ArtifactsFrom []ArtifactDependency // 任务依赖的产物，记录部署使用的产物
+}
+
+// deploymentPollInterval 执行器重新检查等待审批任务的间隔
+const deploymentPollInterval = 5 * time.Second
+
+// deploymentGateRequest 构造任务的部署审批检查请求，任务未指定环境或未配置审批检查时返回nil
+func deploymentGateRequest(req *ExecutionRequest, task *models.Task) *DeploymentGateRequest {
+	if req.Gate == nil || task.Environment == nil || *task.Environment == "" {
+		return nil
+	}
+	taskRunID, ok := req.TaskRunIDs[task.Name]
+	if !ok {
+		return nil
+	}
+	return &DeploymentGateRequest{
+		ProjectID:     req.Pipeline.ProjectID,
+		Run:           req.Run,
+		TaskRunID:     taskRunID,
+		TaskName:      task.Name,
+		Environment:   *task.Environment,
+		ArtifactsFrom: taskArtifactDependencies(task),
+	}
+}
+
+// NewExecutor 根据配置创建流水线执行器
+func NewExecutor(cfg *config.Config, tektonSvc TektonService, runnerSvc RunnerService, logService LogService, artifactService ArtifactService, secretService SecretService) (Executor, error) {
+	switch cfg.Executor.Type {
+	case "", "tekton":
+		return NewTektonExecutor(tektonSvc, secretService), nil
+	case "local":
+		return NewLocalExecutor(cfg, logService, artifactService, secretService)
+	case "runner":
+		return runnerSvc, nil
+	default:
+		return nil, fmt.Errorf("不支持的执行器类型: %s", cfg.Executor.Type)
+	}
+}
+
+// tektonExecutor 基于Tekton的执行器，状态由 TektonService 的事件监听回写
+type tektonExecutor struct {
+	tektonService TektonService
+	secrets       SecretService
+
+	mu       sync.Mutex
+	awaiting map[uuid.UUID]context.CancelFunc // 提交前等待部署审批的运行
+}
+
+// NewTektonExecutor 创建Tekton执行器
+func NewTektonExecutor(tektonSvc TektonService, secretService SecretService) Executor {
+	return &tektonExecutor{
+		tektonService: tektonSvc,
+		secrets:       secretService,
+		awaiting:      make(map[uuid.UUID]context.CancelFunc),
+	}
+}
+
+// Name 执行器名称
+func (e *tektonExecutor) Name() string {
+	return "tekton"
+}
+
+// Execute 提交Tekton PipelineRun
+func (e *tektonExecutor) Execute(ctx context.Context, req *ExecutionRequest) error {
+	run := req.Run
+	evaluated, skipped, err := tektonEvaluateTasks(req)
+	if err != nil {
+		return err
+	}
+	for name, reason := range skipped {
+		if taskRunID, ok := req.TaskRunIDs[name]; ok {
+			reason := reason
+			if err := req.Reporter.UpdateTaskRunStatus(taskRunID, &TaskRunStatusUpdate{Status: "skipped", ErrorMessage: &reason}); err != nil {
+				log.Printf("⚠️ 回写任务运行状态失败 %s: %v", taskRunID, err)
+			}
+		}
+	}
+	if len(evaluated.Pipeline.Tasks) == 0 {
+		// 全部任务被跳过，不需要提交到集群
+		return req.Reporter.UpdateStatus(run.ID, "succeeded", nil)
+	}
+
+	// Tekton在集群中调度任务，无法在单个任务开始前暂停，部署审批在提交整个运行前完成
+	if err := e.awaitDeployments(ctx, evaluated); err != nil {
+		if errors.Is(err, context.Canceled) {
+			// 等待审批期间运行被取消，最终状态已由取消方回写
+			return nil
+		}
+		return err
+	}
+
+	pipeline, taskRunIDs := tektonMatrixPipeline(evaluated)
+	tektonRun := &TektonPipelineRunRequest{
+		Name:           fmt.Sprintf("run-%s-%d", run.ID.String()[:8], run.RunNumber),
+		PipelineID:     pipeline.ID,
+		RunID:          run.ID,
+		Parameters:     req.Parameters,
+		Timeout:        pipeline.Config.Timeout,
+		Workspace:      pipeline.Config.Workspace,
+		ServiceAccount: pipeline.Config.ServiceAccount,
+		Pipeline:       pipeline,
+		TaskRunIDs:     taskRunIDs,
+	}
+
+	// Tekton任务在集群内的工作空间中执行，服务端无法访问，产物声明只能由本地执行器和自托管执行器处理
+	for _, task := range pipeline.Tasks {
+		if taskArtifactSpec(&task) != nil || len(taskArtifactDependencies(&task)) > 0 {
+			log.Printf("⚠️ Tekton执行器不支持构建产物，任务 %s 的 artifacts/artifacts_from 将被忽略", task.Name)
+		}
+
+		refs := taskSecretReferences(&task)
+		if len(refs) == 0 || e.secrets == nil {
+			continue
+		}
+		env, err := e.secrets.ResolveEnv(ctx, pipeline.ProjectID, refs)
+		if err != nil {
+			return fmt.Errorf("任务 %s 获取密钥失败: %w", task.Name, err)
+		}
+		if tektonRun.SecretEnv == nil {
+			tektonRun.SecretEnv = make(map[string]map[string]string)
+		}
+		tektonRun.SecretEnv[task.Name] = env
+	}
+
+	if err := e.tektonService.CreatePipelineRun(ctx, tektonRun); err != nil {
+		return fmt.Errorf("提交Tekton流水线运行失败: %w", err)
+	}
+	return nil
+}
+
+// Cancel 取消Tekton PipelineRun；仍在等待部署审批的运行尚未提交，只需停止等待
+func (e *tektonExecutor) Cancel(ctx context.Context, runID uuid.UUID) error {
+	e.mu.Lock()
+	cancel, awaiting := e.awaiting[runID]
+	e.mu.Unlock()
+	if awaiting {
+		cancel()
+		return nil
+	}
+
+	if err := e.tektonService.CancelPipelineRun(ctx, runID); err != nil {
+		return fmt.Errorf("取消Tekton流水线运行失败: %w", err)
+	}
+	return nil
+}
+
+// awaitDeployments 等待全部部署任务通过审批，任一审批被拒绝或超时时返回错误
+func (e *tektonExecutor) awaitDeployments(ctx context.Context, req *ExecutionRequest) error {
+	var gates []*DeploymentGateRequest
+	for i := range req.Pipeline.Tasks {
+		if gateReq := deploymentGateRequest(req, &req.Pipeline.Tasks[i]); gateReq != nil {
+			gates = append(gates, gateReq)
+		}
+	}
+	if len(gates) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	e.mu.Lock()
+	e.awaiting[req.Run.ID] = cancel
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		delete(e.awaiting, req.Run.ID)
+		e.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(deploymentPollInterval)
+	defer ticker.Stop()
+	for {
+		waiting := false
+		for _, gateReq := range gates {
+			ready, reason := req.Gate.CheckDeployment(gateReq)
+			if reason != "" {
+				message := reason
+				if err := req.Reporter.UpdateTaskRunStatus(gateReq.TaskRunID, &TaskRunStatusUpdate{Status: "failed", ErrorMessage: &message}); err != nil {
+					log.Printf("⚠️ 回写任务运行状态失败 %s: %v", gateReq.TaskRunID, err)
+				}
+				return fmt.Errorf("任务 %s: %s", gateReq.TaskName, reason)
+			}
+			waiting = waiting || !ready
+		}
+		if !waiting {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// tektonEvaluateTasks 提交前求值执行条件和插值。Tekton在集群中调度任务，表达式不能引用任务结果；
+// 条件不满足的任务及依赖它的任务不提交，返回其跳过原因
+func tektonEvaluateTasks(req *ExecutionRequest) (*ExecutionRequest, map[string]string, error) {
+	base := newExpressionScope(req)
+	known := make(map[string]bool, len(req.Pipeline.Tasks))
+	states := make(map[string]string, len(req.Pipeline.Tasks))
+	skipped := make(map[string]string)
+	for _, task := range req.Pipeline.Tasks {
+		known[task.Name] = true
+		exprs, err := taskExpressions(&task)
+		if err != nil {
+			return nil, nil, fmt.Errorf("任务 %s: %w", task.Name, err)
+		}
+		for _, expr := range exprs {
+			if len(expr.TaskReferences()) > 0 {
+				return nil, nil, fmt.Errorf("Tekton执行器不支持引用任务结果的表达式（任务 %s: %s）", task.Name, expr.String())
+			}
+		}
+
+		states[task.Name] = "succeeded"
+		gate, reason := checkTaskGate(nil, task.Condition, nil, nil, func() map[string]interface{} {
+			return taskExpressionScope(base, nil, req.Matrix[task.Name])
+		})
+		switch gate {
+		case gateFail:
+			return nil, nil, fmt.Errorf("任务 %s: %s", task.Name, reason)
+		case gateSkip:
+			states[task.Name] = "skipped"
+			skipped[task.Name] = reason
+		}
+	}
+	matrixKnownTasks(known, req.Matrix)
+
+	// 依赖被跳过的任务同样跳过
+	for changed := true; changed; {
+		changed = false
+		logical := logicalTaskStates(states, req.Matrix)
+		for _, task := range req.Pipeline.Tasks {
+			if states[task.Name] == "skipped" {
+				continue
+			}
+			if _, reason := dependencyState(task.DependsOn, logical, known); reason != "" {
+				states[task.Name] = "skipped"
+				skipped[task.Name] = reason
+				changed = true
+			}
+		}
+	}
+
+	pipeline := *req.Pipeline
+	pipeline.Tasks = make([]models.Task, 0, len(req.Pipeline.Tasks))
+	taskRunIDs := make(map[string]uuid.UUID, len(req.TaskRunIDs))
+	for _, task := range req.Pipeline.Tasks {
+		if states[task.Name] == "skipped" {
+			continue
+		}
+		rendered, err := renderTask(task, taskExpressionScope(base, nil, req.Matrix[task.Name]))
+		if err != nil {
+			return nil, nil, fmt.Errorf("任务 %s: %w", task.Name, err)
+		}
+		pipeline.Tasks = append(pipeline.Tasks, rendered)
+		if taskRunID, ok := req.TaskRunIDs[task.Name]; ok {
+			taskRunIDs[task.Name] = taskRunID
+		}
+	}
+
+	evaluated := *req
+	evaluated.Pipeline = &pipeline
+	evaluated.TaskRunIDs = taskRunIDs
+	return &evaluated, skipped, nil
+}
+
+// tektonMatrixPipeline 改写矩阵组合以适配Tekton：组合任务名改为 <矩阵任务名>-<序号>（Tekton任务名需符合DNS标签规范），
+// 依赖矩阵任务改为依赖其全部组合，max_parallel 通过组合之间的 runAfter 串联实现。
+// Tekton在任一任务失败后不再启动新任务，fail_fast: false 无法生效
+func tektonMatrixPipeline(req *ExecutionRequest) (*models.Pipeline, map[string]uuid.UUID) {
+	if len(req.Matrix) == 0 {
+		return req.Pipeline, req.TaskRunIDs
+	}
+
+	renamed := make(map[string]string, len(req.Matrix))
+	members := make(map[string][]string)
+	for _, task := range req.Pipeline.Tasks {
+		if combination := req.Matrix[task.Name]; combination != nil {
+			name := fmt.Sprintf("%s-%d", combination.Task, len(members[combination.Task])+1)
+			renamed[task.Name] = name
+			members[combination.Task] = append(members[combination.Task], name)
+		}
+	}
+
+	warned := make(map[string]bool)
+	pipeline := *req.Pipeline
+	pipeline.Tasks = make([]models.Task, 0, len(req.Pipeline.Tasks))
+	for _, task := range req.Pipeline.Tasks {
+		var deps []string
+		for _, dep := range task.DependsOn {
+			if names, ok := members[dep]; ok {
+				deps = append(deps, names...)
+			} else {
+				deps = append(deps, dep)
+			}
+		}
+
+		if combination := req.Matrix[task.Name]; combination != nil {
+			group := members[combination.Task]
+			task.Name = renamed[task.Name]
+			for i, name := range group {
+				if name == task.Name && combination.MaxParallel > 0 && i >= combination.MaxParallel {
+					deps = append(deps, group[i-combination.MaxParallel])
+				}
+			}
+			if !combination.FailFast && !warned[combination.Task] {
+				log.Printf("⚠️ Tekton执行器在任务失败后不再启动新任务，矩阵任务 %s 的 fail_fast: false 不生效", combination.Task)
+				warned[combination.Task] = true
+			}
+		}
+		task.DependsOn = deps
+		pipeline.Tasks = append(pipeline.Tasks, task)
+	}
+
+	taskRunIDs := make(map[string]uuid.UUID, len(req.TaskRunIDs))
+	for name, id := range req.TaskRunIDs {
+		if tektonName, ok := renamed[name]; ok {
+			name = tektonName
+		}
+		taskRunIDs[name] = id
+	}
+	return &pipeline, taskRunIDs
+}
+
+// taskEnvironment 构造任务环境变量，优先级：任务环境变量 > 运行参数 > 流水线变量 > 内置变量
+func taskEnvironment(req *ExecutionRequest, task models.Task) map[string]string {
+	env := map[string]string{
+		"CI":                    "true",
+		"AXIOM_PIPELINE_ID":     req.Pipeline.ID.String(),
+		"AXIOM_PIPELINE_RUN_ID": req.Run.ID.String(),
+		"AXIOM_RUN_NUMBER":      strconv.Itoa(req.Run.RunNumber),
+		"AXIOM_TASK_NAME":       task.Name,
+	}
+	if req.Run.CommitSHA != nil {
+		env["AXIOM_COMMIT_SHA"] = *req.Run.CommitSHA
+	}
+	if req.Run.Branch != nil {
+		env["AXIOM_BRANCH"] = *req.Run.Branch
+	}
+
+	if req.Pipeline.Variables != nil {
+		var variables map[string]interface{}
+		if err := json.Unmarshal(req.Pipeline.Variables, &variables); err == nil {
+			for key, value := range variables {
+				env[key] = fmt.Sprint(value)
+			}
+		}
+	}
+	for key, value := range req.Parameters {
+		env[key] = fmt.Sprint(value)
+	}
+	if task.Env != nil {
+		var taskEnv map[string]string
+		if err := json.Unmarshal(task.Env, &taskEnv); err == nil {
+			for key, value := range taskEnv {
+				env[key] = value
+			}
+		}
+	}
+	return env
+}
+
+// newExpressionScope 构造运行级的表达式上下文，变量优先级：运行参数 > 流水线变量
+func newExpressionScope(req *ExecutionRequest) map[string]interface{} {
+	variables := make(map[string]interface{})
+	if req.Pipeline.Variables != nil {
+		if err := json.Unmarshal(req.Pipeline.Variables, &variables); err != nil {
+			variables = make(map[string]interface{})
+		}
+	}
+	for key, value := range req.Parameters {
+		variables[key] = value
+	}
+
+	triggerData := make(map[string]interface{})
+	if len(req.Run.TriggerData) > 0 {
+		if err := json.Unmarshal(req.Run.TriggerData, &triggerData); err != nil {
+			triggerData = make(map[string]interface{})
+		}
+	}
+	trigger := map[string]interface{}{"type": req.Run.TriggerType, "data": triggerData}
+	if req.Run.TriggerBy != nil {
+		trigger["by"] = req.Run.TriggerBy.String()
+	}
+
+	scope := map[string]interface{}{
+		"branch":     nil,
+		"commit_sha": nil,
+		"trigger":    trigger,
+		"variables":  variables,
+		"run":        map[string]interface{}{"id": req.Run.ID.String(), "number": float64(req.Run.RunNumber)},
+		"pipeline":   map[string]interface{}{"id": req.Pipeline.ID.String(), "name": req.Pipeline.Name},
+	}
+	if req.Run.Branch != nil {
+		scope["branch"] = *req.Run.Branch
+	}
+	if req.Run.CommitSHA != nil {
+		scope["commit_sha"] = *req.Run.CommitSHA
+	}
+	return scope
+}
+
+// taskExpressionScope 在运行级上下文上补充任务结果（tasks.<任务名>.status，矩阵任务为汇总状态）和矩阵取值
+func taskExpressionScope(base map[string]interface{}, states map[string]string, combination *MatrixCombination) map[string]interface{} {
+	scope := make(map[string]interface{}, len(base)+2)
+	for key, value := range base {
+		scope[key] = value
+	}
+
+	tasks := make(map[string]interface{}, len(states))
+	for name, state := range states {
+		tasks[name] = map[string]interface{}{"status": state}
+	}
+	scope["tasks"] = tasks
+
+	matrix := make(map[string]interface{})
+	if combination != nil {
+		for key, value := range combination.Values {
+			matrix[key] = value
+		}
+	}
+	scope["matrix"] = matrix
+	return scope
+}
+
+// taskGate 任务能否开始的判断结果
+type taskGate int
+
+const (
+	gateWait taskGate = iota // 等待依赖结束
+	gateRun                  // 可以执行
+	gateSkip                 // 依赖未成功或条件不满足，跳过
+	gateFail                 // 条件无法求值，任务失败
+)
+
+// checkTaskGate 判断任务能否开始，reason 为跳过或失败的原因。依赖全部成功后求值执行条件；
+// 条件引用任务结果（tasks.*）时依赖全部结束即求值，是否执行完全由条件决定（如上游失败时执行的清理任务）
+func checkTaskGate(dependsOn []string, condition *string, states map[string]string, known map[string]bool,
+	scope func() map[string]interface{}) (taskGate, string) {
+	var expr *Expression
+	if condition != nil && strings.TrimSpace(*condition) != "" {
+		parsed, err := ParseExpression(*condition)
+		if err != nil {
+			return gateFail, fmt.Sprintf("执行条件无效: %v", err)
+		}
+		expr = parsed
+	}
+
+	if expr != nil && len(expr.TaskReferences()) > 0 {
+		for _, dep := range append(append([]string{}, dependsOn...), expr.TaskReferences()...) {
+			if !known[dep] {
+				if containsString(dependsOn, dep) {
+					return gateSkip, fmt.Sprintf("依赖任务 %s 不存在", dep)
+				}
+				continue
+			}
+			if state, seen := states[dep]; !seen || state == "running" {
+				return gateWait, ""
+			}
+		}
+	} else {
+		ready, reason := dependencyState(dependsOn, states, known)
+		if reason != "" {
+			return gateSkip, reason
+		}
+		if !ready {
+			return gateWait, ""
+		}
+	}
+
+	if expr == nil {
+		return gateRun, ""
+	}
+	ok, err := expr.EvaluateBool(scope())
+	if err != nil {
+		return gateFail, fmt.Sprintf("执行条件求值失败: %v", err)
+	}
+	if !ok {
+		return gateSkip, fmt.Sprintf("执行条件不满足: %s", *condition)
+	}
+	return gateRun, ""
+}
+
+// renderTask 替换任务命令、参数和环境变量中的 ${{ }} 插值，返回任务副本
+func renderTask(task models.Task, scope map[string]interface{}) (models.Task, error) {
+	command, err := renderStrings("command", task.Command, scope)
+	if err != nil {
+		return task, err
+	}
+	args, err := renderStrings("args", task.Args, scope)
+	if err != nil {
+		return task, err
+	}
+	task.Command, task.Args = command, args
+
+	if len(task.Env) > 0 {
+		var env map[string]string
+		if err := json.Unmarshal(task.Env, &env); err == nil {
+			rendered, err := renderEnv(env, scope)
+			if err != nil {
+				return task, err
+			}
+			envJSON, err := json.Marshal(rendered)
+			if err != nil {
+				return task, fmt.Errorf("序列化任务环境变量失败: %w", err)
+			}
+			task.Env = envJSON
+		}
+	}
+	return task, nil
+}
+
+// renderStrings 替换字符串列表中的插值
+func renderStrings(field string, values []string, scope map[string]interface{}) ([]string, error) {
+	if values == nil {
+		return nil, nil
+	}
+	rendered := make([]string, len(values))
+	for i, value := range values {
+		s, err := interpolate(value, scope)
+		if err != nil {
+			return nil, fmt.Errorf("%s 表达式求值失败: %w", field, err)
+		}
+		rendered[i] = s
+	}
+	return rendered, nil
+}
+
+// renderEnv 替换环境变量值中的插值
+func renderEnv(env map[string]string, scope map[string]interface{}) (map[string]string, error) {
+	rendered := make(map[string]string, len(env))
+	for key, value := range env {
+		s, err := interpolate(value, scope)
+		if err != nil {
+			return nil, fmt.Errorf("env.%s 表达式求值失败: %w", key, err)
+		}
+		rendered[key] = s
+	}
+	return rendered, nil
+}
+
+// taskExpressions 解析任务执行条件和插值中的全部表达式
+func taskExpressions(task *models.Task) ([]*Expression, error) {
+	var exprs []*Expression
+	if task.Condition != nil && strings.TrimSpace(*task.Condition) != "" {
+		expr, err := ParseExpression(*task.Condition)
+		if err != nil {
+			return nil, fmt.Errorf("执行条件无效: %w", err)
+		}
+		exprs = append(exprs, expr)
+	}
+
+	values := append(append([]string{}, task.Command...), task.Args...)
+	if len(task.Env) > 0 {
+		var env map[string]string
+		if err := json.Unmarshal(task.Env, &env); err == nil {
+			for _, key := range sortedKeys(env) {
+				values = append(values, env[key])
+			}
+		}
+	}
+	for _, value := range values {
+		parsed, err := templateExpressions(value)
+		if err != nil {
+			return nil, fmt.Errorf("插值 %q 无效: %w", value, err)
+		}
+		exprs = append(exprs, parsed...)
+	}
+	return exprs, nil
+}
+
+// taskNeedsEvaluation 任务是否配置了执行条件或包含插值
+func taskNeedsEvaluation(task *models.Task) bool {
+	if task.Condition != nil && strings.TrimSpace(*task.Condition) != "" {
+		return true
+	}
+	for _, value := range append(append([]string{}, task.Command...), task.Args...) {
+		if strings.Contains(value, "${{") {
+			return true
+		}
+	}
+	return strings.Contains(string(task.Env), "${{")
+}
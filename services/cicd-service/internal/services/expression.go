@@ -0,0 +1,845 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 表达式用于任务执行条件（condition）和命令、参数、环境变量中的 ${{ }} 插值：
+//
+//	branch == "main" && tasks.test.status == "succeeded"
+//	startsWith(branch, 'release/') || trigger.type == 'manual'
+//	go test -tags ${{ variables.BUILD_TAGS }} ./...
+//
+// 字符串可用单引号或双引号，支持 == != < <= > >= && || ! 和括号，以及 contains、startsWith、endsWith 函数；
+// 名称含空格等字符时使用下标形式，如 tasks['unit test'].status。
+// 引用不存在的字段得到 null，null、false、0 和空字符串视为假
+
+// expressionRoots 表达式可以引用的上下文
+var expressionRoots = []string{"branch", "commit_sha", "trigger", "variables", "run", "pipeline", "tasks", "matrix"}
+
+// expressionFuncs 表达式函数及参数个数
+var expressionFuncs = map[string]int{"contains": 2, "startsWith": 2, "endsWith": 2}
+
+// Expression 解析后的表达式
+type Expression struct {
+	source string
+	root   exprNode
+	refs   [][]string
+}
+
+// ParseExpression 解析表达式
+func ParseExpression(source string) (*Expression, error) {
+	p := &exprParser{src: source}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokEOF {
+		return nil, fmt.Errorf("表达式为空")
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.unexpected()
+	}
+	return &Expression{source: source, root: root, refs: p.refs}, nil
+}
+
+// String 返回表达式原文
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Evaluate 求值表达式
+func (e *Expression) Evaluate(scope map[string]interface{}) (interface{}, error) {
+	return e.root.eval(scope)
+}
+
+// EvaluateBool 求值表达式并转换为布尔值
+func (e *Expression) EvaluateBool(scope map[string]interface{}) (bool, error) {
+	value, err := e.root.eval(scope)
+	if err != nil {
+		return false, err
+	}
+	return exprTruthy(value), nil
+}
+
+// References 表达式引用的上下文路径，如 [tasks test status]
+func (e *Expression) References() [][]string {
+	return e.refs
+}
+
+// TaskReferences 表达式引用的任务名（tasks.<任务名>）
+func (e *Expression) TaskReferences() []string {
+	var names []string
+	for _, ref := range e.refs {
+		if ref[0] == "tasks" && len(ref) > 1 && !containsString(names, ref[1]) {
+			names = append(names, ref[1])
+		}
+	}
+	return names
+}
+
+// exprTemplate 含 ${{ }} 插值的字符串
+type exprTemplate struct {
+	literals []string      // 插值之间的原文，比 exprs 多一个
+	exprs    []*Expression // 插值表达式
+}
+
+// parseTemplate 解析字符串中的 ${{ }} 插值
+func parseTemplate(s string) (*exprTemplate, error) {
+	t := &exprTemplate{}
+	rest := s
+	offset := 0
+	for {
+		start := strings.Index(rest, "${{")
+		if start < 0 {
+			t.literals = append(t.literals, rest)
+			return t, nil
+		}
+		t.literals = append(t.literals, rest[:start])
+
+		p := &exprParser{src: s, pos: offset + start + 3, template: true}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		root, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokClose {
+			return nil, fmt.Errorf("位置 %d: 插值缺少 }}", offset+start+1)
+		}
+		t.exprs = append(t.exprs, &Expression{
+			source: strings.TrimSpace(s[offset+start+3 : p.tok.pos]),
+			root:   root,
+			refs:   p.refs,
+		})
+		offset = p.pos
+		rest = s[offset:]
+	}
+}
+
+// render 求值全部插值并拼接
+func (t *exprTemplate) render(scope map[string]interface{}) (string, error) {
+	var b strings.Builder
+	for i, expr := range t.exprs {
+		b.WriteString(t.literals[i])
+		value, err := expr.Evaluate(scope)
+		if err != nil {
+			return "", fmt.Errorf("${{ %s }}: %w", expr.source, err)
+		}
+		b.WriteString(exprString(value))
+	}
+	b.WriteString(t.literals[len(t.exprs)])
+	return b.String(), nil
+}
+
+// interpolate 替换字符串中的 ${{ }} 插值
+func interpolate(s string, scope map[string]interface{}) (string, error) {
+	if !strings.Contains(s, "${{") {
+		return s, nil
+	}
+	t, err := parseTemplate(s)
+	if err != nil {
+		return "", err
+	}
+	return t.render(scope)
+}
+
+// templateExpressions 解析字符串中的插值表达式，用于校验
+func templateExpressions(s string) ([]*Expression, error) {
+	if !strings.Contains(s, "${{") {
+		return nil, nil
+	}
+	t, err := parseTemplate(s)
+	if err != nil {
+		return nil, err
+	}
+	return t.exprs, nil
+}
+
+// ---- 词法分析 ----
+
+type exprTokenKind int
+
+const (
+	tokEOF exprTokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokClose // 插值结束符 }}
+)
+
+type exprToken struct {
+	kind  exprTokenKind
+	text  string
+	value interface{}
+	pos   int
+}
+
+// exprParser 递归下降解析器，按需读取词法单元
+type exprParser struct {
+	src      string
+	pos      int
+	template bool // 解析插值，遇到 }} 结束
+	tok      exprToken
+	refs     [][]string
+}
+
+// advance 读取下一个词法单元
+func (p *exprParser) advance() error {
+	for p.pos < len(p.src) && strings.ContainsRune(" \t\r\n", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		if p.template {
+			return fmt.Errorf("位置 %d: 插值缺少 }}", start+1)
+		}
+		p.tok = exprToken{kind: tokEOF, pos: start}
+		return nil
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case p.template && strings.HasPrefix(p.src[p.pos:], "}}"):
+		p.pos += 2
+		p.tok = exprToken{kind: tokClose, text: "}}", pos: start}
+	case c == '\'' || c == '"':
+		value, err := p.scanString(c)
+		if err != nil {
+			return err
+		}
+		p.tok = exprToken{kind: tokString, text: p.src[start:p.pos], value: value, pos: start}
+	case isExprDigit(c) || (c == '-' && p.pos+1 < len(p.src) && isExprDigit(p.src[p.pos+1])):
+		p.pos++
+		for p.pos < len(p.src) && (isExprDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		text := p.src[start:p.pos]
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return fmt.Errorf("位置 %d: 无效的数字 %q", start+1, text)
+		}
+		p.tok = exprToken{kind: tokNumber, text: text, value: value, pos: start}
+	case isExprIdentStart(c):
+		for p.pos < len(p.src) && (isExprIdentStart(p.src[p.pos]) || isExprDigit(p.src[p.pos]) || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		p.tok = exprToken{kind: tokIdent, text: p.src[start:p.pos], pos: start}
+	default:
+		for _, op := range []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", ".", "[", "]", ","} {
+			if strings.HasPrefix(p.src[p.pos:], op) {
+				p.pos += len(op)
+				p.tok = exprToken{kind: tokOp, text: op, pos: start}
+				return nil
+			}
+		}
+		return fmt.Errorf("位置 %d: 无法识别的字符 %q", start+1, c)
+	}
+	return nil
+}
+
+// scanString 读取引号字符串，支持反斜杠转义
+func (p *exprParser) scanString(quote byte) (string, error) {
+	start := p.pos
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c == '\\' && p.pos+1 < len(p.src):
+			p.pos++
+			switch p.src[p.pos] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(p.src[p.pos])
+			}
+		default:
+			b.WriteByte(c)
+		}
+		p.pos++
+	}
+	return "", fmt.Errorf("位置 %d: 字符串缺少结束引号", start+1)
+}
+
+func isExprDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isExprIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// ---- 语法分析 ----
+
+// unexpected 当前词法单元不符合语法
+func (p *exprParser) unexpected() error {
+	switch p.tok.kind {
+	case tokEOF:
+		return fmt.Errorf("表达式不完整")
+	case tokClose:
+		return fmt.Errorf("位置 %d: 插值不完整", p.tok.pos+1)
+	}
+	return fmt.Errorf("位置 %d: 意外的 %q", p.tok.pos+1, p.tok.text)
+}
+
+func (p *exprParser) isOp(ops ...string) bool {
+	return p.tok.kind == tokOp && containsString(ops, p.tok.text)
+}
+
+// parseOr or := and ('||' and)*
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.isOp("||") {
+		var right exprNode
+		if err = p.advance(); err == nil {
+			if right, err = p.parseAnd(); err == nil {
+				left = &logicalNode{op: "||", left: left, right: right}
+			}
+		}
+	}
+	return left, err
+}
+
+// parseAnd and := comparison ('&&' comparison)*
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseComparison()
+	for err == nil && p.isOp("&&") {
+		var right exprNode
+		if err = p.advance(); err == nil {
+			if right, err = p.parseComparison(); err == nil {
+				left = &logicalNode{op: "&&", left: left, right: right}
+			}
+		}
+	}
+	return left, err
+}
+
+// parseComparison comparison := unary (op unary)?
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil || !p.isOp("==", "!=", "<", "<=", ">", ">=") {
+		return left, err
+	}
+	op := p.tok.text
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	right, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &compareNode{op: op, left: left, right: right}, nil
+}
+
+// parseUnary unary := '!' unary | primary
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.isOp("!") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary primary := 字面量 | '(' or ')' | 函数调用 | 上下文引用
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokString, tokNumber:
+		return &literalNode{value: tok.value}, p.advance()
+	case tokOp:
+		if tok.text != "(" {
+			return nil, p.unexpected()
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOp(")") {
+			return nil, p.unexpected()
+		}
+		return inner, p.advance()
+	case tokIdent:
+	default:
+		return nil, p.unexpected()
+	}
+
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	switch tok.text {
+	case "true":
+		return &literalNode{value: true}, nil
+	case "false":
+		return &literalNode{value: false}, nil
+	case "null":
+		return &literalNode{value: nil}, nil
+	}
+	if p.isOp("(") {
+		return p.parseCall(tok)
+	}
+	return p.parseReference(tok)
+}
+
+// parseCall 函数调用
+func (p *exprParser) parseCall(name exprToken) (exprNode, error) {
+	arity, ok := expressionFuncs[name.text]
+	if !ok {
+		return nil, fmt.Errorf("位置 %d: 未知函数 %q", name.pos+1, name.text)
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	call := &callNode{name: name.text}
+	for !p.isOp(")") {
+		if len(call.args) > 0 {
+			if !p.isOp(",") {
+				return nil, p.unexpected()
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+	}
+	if len(call.args) != arity {
+		return nil, fmt.Errorf("位置 %d: 函数 %s 需要 %d 个参数", name.pos+1, name.text, arity)
+	}
+	return call, p.advance()
+}
+
+// parseReference 上下文引用：root('.' 名称 | '[' 字符串或数字 ']')*
+func (p *exprParser) parseReference(root exprToken) (exprNode, error) {
+	if !containsString(expressionRoots, root.text) {
+		return nil, fmt.Errorf("位置 %d: 未知的上下文 %q（可用 %s）", root.pos+1, root.text, strings.Join(expressionRoots, ", "))
+	}
+
+	path := []string{root.text}
+	for p.isOp(".", "[") {
+		if p.tok.text == "." {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokIdent {
+				return nil, p.unexpected()
+			}
+			path = append(path, p.tok.text)
+		} else {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokString && p.tok.kind != tokNumber {
+				return nil, p.unexpected()
+			}
+			key := exprString(p.tok.value)
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if !p.isOp("]") {
+				return nil, p.unexpected()
+			}
+			path = append(path, key)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	p.refs = append(p.refs, path)
+	return &refNode{path: path}, nil
+}
+
+// ---- 求值 ----
+
+type exprNode interface {
+	eval(scope map[string]interface{}) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(map[string]interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+type refNode struct {
+	path []string
+}
+
+func (n *refNode) eval(scope map[string]interface{}) (interface{}, error) {
+	var current interface{} = scope
+	for _, key := range n.path {
+		switch value := current.(type) {
+		case map[string]interface{}:
+			current = value[key]
+		case map[string]string:
+			if s, ok := value[key]; ok {
+				current = s
+			} else {
+				current = nil
+			}
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(value) {
+				return nil, nil
+			}
+			current = value[index]
+		default:
+			return nil, nil
+		}
+	}
+	return current, nil
+}
+
+type notNode struct {
+	operand exprNode
+}
+
+func (n *notNode) eval(scope map[string]interface{}) (interface{}, error) {
+	value, err := n.operand.eval(scope)
+	if err != nil {
+		return nil, err
+	}
+	return !exprTruthy(value), nil
+}
+
+type logicalNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *logicalNode) eval(scope map[string]interface{}) (interface{}, error) {
+	left, err := n.left.eval(scope)
+	if err != nil {
+		return nil, err
+	}
+	// 短路求值
+	if exprTruthy(left) == (n.op == "||") {
+		return n.op == "||", nil
+	}
+	right, err := n.right.eval(scope)
+	if err != nil {
+		return nil, err
+	}
+	return exprTruthy(right), nil
+}
+
+type compareNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *compareNode) eval(scope map[string]interface{}) (interface{}, error) {
+	left, err := n.left.eval(scope)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return exprEqual(left, right), nil
+	case "!=":
+		return !exprEqual(left, right), nil
+	}
+	if left == nil || right == nil {
+		return false, nil
+	}
+
+	var cmp int
+	ln, lok := exprNumber(left)
+	rn, rok := exprNumber(right)
+	ls, lstr := left.(string)
+	rs, rstr := right.(string)
+	switch {
+	case lok && rok:
+		cmp = compareFloat(ln, rn)
+	case lstr && rstr:
+		cmp = strings.Compare(ls, rs)
+	case lok && rstr:
+		parsed, err := strconv.ParseFloat(rs, 64)
+		if err != nil {
+			return nil, fmt.Errorf("无法比较 %s 和 %s", exprTypeName(left), exprTypeName(right))
+		}
+		cmp = compareFloat(ln, parsed)
+	case lstr && rok:
+		parsed, err := strconv.ParseFloat(ls, 64)
+		if err != nil {
+			return nil, fmt.Errorf("无法比较 %s 和 %s", exprTypeName(left), exprTypeName(right))
+		}
+		cmp = compareFloat(parsed, rn)
+	default:
+		return nil, fmt.Errorf("无法比较 %s 和 %s", exprTypeName(left), exprTypeName(right))
+	}
+
+	switch n.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+type callNode struct {
+	name string
+	args []exprNode
+}
+
+func (n *callNode) eval(scope map[string]interface{}) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(scope)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+
+	if args[0] == nil {
+		return false, nil
+	}
+	switch n.name {
+	case "contains":
+		if list, ok := args[0].([]interface{}); ok {
+			for _, item := range list {
+				if exprEqual(item, args[1]) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return strings.Contains(exprString(args[0]), exprString(args[1])), nil
+	case "startsWith":
+		return strings.HasPrefix(exprString(args[0]), exprString(args[1])), nil
+	default:
+		return strings.HasSuffix(exprString(args[0]), exprString(args[1])), nil
+	}
+}
+
+// exprTruthy null、false、0 和空字符串为假
+func exprTruthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+	if n, ok := exprNumber(value); ok {
+		return n != 0
+	}
+	return true
+}
+
+// exprEqual 比较两个值是否相等，数字与数字形式的字符串按数值比较
+func exprEqual(left, right interface{}) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+	if ln, ok := exprNumber(left); ok {
+		if rn, ok := exprNumber(right); ok {
+			return ln == rn
+		}
+		if rs, ok := right.(string); ok {
+			rn, err := strconv.ParseFloat(rs, 64)
+			return err == nil && ln == rn
+		}
+		return false
+	}
+	if _, ok := exprNumber(right); ok {
+		return exprEqual(right, left)
+	}
+
+	switch l := left.(type) {
+	case string:
+		r, ok := right.(string)
+		return ok && l == r
+	case bool:
+		r, ok := right.(bool)
+		return ok && l == r
+	}
+	return false
+}
+
+// exprNumber 将数值类型统一为 float64
+func exprNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// exprString 值的字符串形式，用于插值：null 为空字符串，对象和数组为JSON
+func exprString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	if n, ok := exprNumber(value); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// exprTypeName 值类型名称，用于错误信息
+func exprTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "字符串"
+	case bool:
+		return "布尔值"
+	case map[string]interface{}, map[string]string:
+		return "对象"
+	case []interface{}:
+		return "数组"
+	}
+	if _, ok := exprNumber(value); ok {
+		return "数字"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// validateTaskExpressions 校验任务的执行条件和插值：语法正确，tasks.<任务名> 必须是（传递）上游依赖，
+// matrix.<维度> 只能用于配置了矩阵且包含该维度的任务
+func validateTaskExpressions(field string, task *DefinitionTask, upstream map[string]bool) []string {
+	var problems []string
+	var exprs []*Expression
+
+	if task.Condition != nil && strings.TrimSpace(*task.Condition) != "" {
+		if len(*task.Condition) > 255 {
+			problems = append(problems, field+" 的 condition 不能超过255个字符")
+		}
+		expr, err := ParseExpression(*task.Condition)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s 的 condition 无效: %v", field, err))
+		} else {
+			exprs = append(exprs, expr)
+		}
+	}
+
+	templates := map[string][]string{"command": task.Command, "args": task.Args}
+	for _, key := range sortedKeys(task.Env) {
+		templates["env."+key] = append(templates["env."+key], task.Env[key])
+	}
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, s := range templates[name] {
+			parsed, err := templateExpressions(s)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s 的 %s 中的表达式无效: %v", field, name, err))
+				continue
+			}
+			exprs = append(exprs, parsed...)
+		}
+	}
+
+	axes := make(map[string]bool)
+	if task.Matrix != nil {
+		for axis := range task.Matrix.Axes {
+			axes[axis] = true
+		}
+		for _, entry := range task.Matrix.Include {
+			for key := range entry {
+				axes[key] = true
+			}
+		}
+	}
+
+	reported := make(map[string]bool)
+	report := func(problem string) {
+		if !reported[problem] {
+			reported[problem] = true
+			problems = append(problems, problem)
+		}
+	}
+	for _, expr := range exprs {
+		for _, ref := range expr.References() {
+			switch ref[0] {
+			case "tasks":
+				if len(ref) < 2 {
+					report(fmt.Sprintf("%s 的表达式 %q 需要指定任务名，如 tasks.<任务名>.status", field, expr.String()))
+				} else if !upstream[ref[1]] {
+					report(fmt.Sprintf("%s 的表达式引用的任务 %q 必须是其直接或间接依赖", field, ref[1]))
+				}
+			case "matrix":
+				if task.Matrix == nil {
+					report(fmt.Sprintf("%s 未配置 matrix，表达式不能引用 matrix", field))
+				} else if len(ref) > 1 && !axes[ref[1]] {
+					report(fmt.Sprintf("%s 的表达式引用的矩阵维度 %q 不存在", field, ref[1]))
+				}
+			}
+		}
+	}
+	return problems
+}
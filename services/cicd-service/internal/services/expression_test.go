@@ -0,0 +1,198 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+// expressionScope 表达式测试使用的上下文
+func expressionScope() map[string]interface{} {
+	return map[string]interface{}{
+		"branch":     "release/1.2",
+		"commit_sha": "0123456789abcdef0123456789abcdef01234567",
+		"trigger":    map[string]interface{}{"type": "webhook", "labels": []interface{}{"ci", "urgent"}},
+		"variables":  map[string]string{"BUILD_TAGS": "integration", "RETRIES": "3"},
+		"run":        map[string]interface{}{"number": 42},
+		"tasks": map[string]interface{}{
+			"build":     map[string]interface{}{"status": "succeeded"},
+			"unit test": map[string]interface{}{"status": "failed"},
+			"lint-go":   map[string]interface{}{"status": "skipped"},
+		},
+		"matrix": map[string]interface{}{"go": "1.21"},
+	}
+}
+
+func TestExpressionEvaluate(t *testing.T) {
+	tests := []struct {
+		source string
+		want   interface{}
+	}{
+		{`branch == "release/1.2"`, true},
+		{`branch != 'main'`, true},
+		{`startsWith(branch, 'release/') && tasks.build.status == 'succeeded'`, true},
+		{`tasks['unit test'].status == "failed"`, true},
+		{`tasks.lint-go.status`, "skipped"},
+		{`endsWith(commit_sha, '567')`, true},
+		{`contains(trigger.labels, 'urgent')`, true},
+		{`contains(trigger.labels, 'nightly')`, false},
+		{`contains(branch, '1.2')`, true},
+		{`trigger.labels[1]`, "urgent"},
+		{`trigger.labels[5]`, nil},
+		{`variables.RETRIES > 2`, true},
+		{`variables.RETRIES == 3`, true},
+		{`run.number >= 42 && run.number < 43.5`, true},
+		{`-1 < 0`, true},
+		{`'b' > 'a'`, true},
+		{`!tasks.missing`, true},
+		{`tasks.missing.status == null`, true},
+		{`tasks.missing.status < 1`, false},
+		{`!(branch == 'main') || false`, true},
+		{`true && (false || 1)`, true},
+		{`trigger.type == 'manual' || trigger.type == 'webhook' && matrix.go == '1.21'`, true},
+		{`'it\'s' == "it's"`, true},
+		{`0`, float64(0)},
+		{`''`, ""},
+		{`contains(tasks.missing, 'x')`, false},
+	}
+
+	scope := expressionScope()
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			expr, err := ParseExpression(tt.source)
+			if err != nil {
+				t.Fatalf("ParseExpression: %v", err)
+			}
+			got, err := expr.Evaluate(scope)
+			if err != nil {
+				t.Fatalf("Evaluate: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Evaluate = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpressionShortCircuit(t *testing.T) {
+	// 右侧比较会出错，短路时不求值
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{`false && trigger < 1`, false},
+		{`true || trigger < 1`, true},
+	}
+
+	scope := expressionScope()
+	for _, tt := range tests {
+		expr, err := ParseExpression(tt.source)
+		if err != nil {
+			t.Fatalf("ParseExpression(%q): %v", tt.source, err)
+		}
+		got, err := expr.EvaluateBool(scope)
+		if err != nil || got != tt.want {
+			t.Fatalf("EvaluateBool(%q) = %v, %v, want %v", tt.source, got, err, tt.want)
+		}
+	}
+}
+
+func TestExpressionEvaluateError(t *testing.T) {
+	for _, source := range []string{`trigger < 1`, `branch > 1`, `true >= false`} {
+		expr, err := ParseExpression(source)
+		if err != nil {
+			t.Fatalf("ParseExpression(%q): %v", source, err)
+		}
+		if _, err := expr.Evaluate(expressionScope()); err == nil {
+			t.Fatalf("Evaluate(%q) should fail", source)
+		}
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"empty", ""},
+		{"blank", "   "},
+		{"unknown context", `env.HOME == 'x'`},
+		{"unknown function", `matches(branch, 'x')`},
+		{"wrong arity", `contains(branch)`},
+		{"unterminated string", `branch == 'main`},
+		{"dangling operator", `branch ==`},
+		{"missing paren", `(branch == 'main'`},
+		{"extra token", `branch 'main'`},
+		{"chained comparison", `run.number < 1 < 2`},
+		{"invalid character", `branch == 'main' ; true`},
+		{"single ampersand", `true & false`},
+		{"bad index", `tasks[build]`},
+		{"unclosed index", `tasks['build'`},
+		{"invalid number", `1.2.3 == 1`},
+		{"trailing dot", `tasks.`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if expr, err := ParseExpression(tt.source); err == nil {
+				t.Fatalf("ParseExpression(%q) = %v, want error", tt.source, expr)
+			}
+		})
+	}
+}
+
+func TestExpressionReferences(t *testing.T) {
+	expr, err := ParseExpression(`tasks.build.status == 'succeeded' && tasks['unit test'].status != 'failed' || tasks.build.outputs.url != null && branch == 'main'`)
+	if err != nil {
+		t.Fatalf("ParseExpression: %v", err)
+	}
+	wantRefs := [][]string{
+		{"tasks", "build", "status"},
+		{"tasks", "unit test", "status"},
+		{"tasks", "build", "outputs", "url"},
+		{"branch"},
+	}
+	if got := expr.References(); !reflect.DeepEqual(got, wantRefs) {
+		t.Fatalf("References = %q, want %q", got, wantRefs)
+	}
+	if got, want := expr.TaskReferences(), []string{"build", "unit test"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("TaskReferences = %q, want %q", got, want)
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"go test ./...", "go test ./...", false},
+		{"go test -tags ${{ variables.BUILD_TAGS }} ./...", "go test -tags integration ./...", false},
+		{"${{run.number}}-${{ matrix.go }}", "42-1.21", false},
+		{"labels=${{ trigger.labels }}", `labels=["ci","urgent"]`, false},
+		{"missing=[${{ variables.NOPE }}]", "missing=[]", false},
+		{"brace ${{ '}}' }} ok", "brace }} ok", false},
+		{"ok=${{ branch == 'main' }}", "ok=false", false},
+		{"unterminated ${{ branch", "", true},
+		{"empty ${{ }}", "", true},
+		{"bad ${{ branch == }}", "", true},
+	}
+
+	scope := expressionScope()
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := interpolate(tt.input, scope)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("interpolate = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("interpolate: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("interpolate = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
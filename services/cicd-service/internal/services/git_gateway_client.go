@@ -0,0 +1,130 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cicd-service/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// GitGatewayClient Git网关服务客户端接口
+type GitGatewayClient interface {
+	GetFile(repositoryID uuid.UUID, ref, path string, userID uuid.UUID) (*RepositoryFile, error)
+}
+
+type gitGatewayClient struct {
+	config *config.Config
+	client *http.Client
+}
+
+// NewGitGatewayClient 创建Git网关服务客户端
+func NewGitGatewayClient(cfg *config.Config) GitGatewayClient {
+	return &gitGatewayClient{
+		config: cfg,
+		client: &http.Client{
+			Timeout: time.Duration(cfg.GitGateway.Timeout) * time.Second,
+		},
+	}
+}
+
+// RepositoryFile 仓库中指定提交的文件
+type RepositoryFile struct {
+	CommitSHA string `json:"commit_sha"`
+	Path      string `json:"path"`
+	BlobSHA   string `json:"blob_sha"`
+	Size      int64  `json:"size"`
+	Encoding  string `json:"encoding"`
+	Content   string `json:"content"`
+}
+
+// Decode 解码文件内容
+func (f *RepositoryFile) Decode() ([]byte, error) {
+	if f.Encoding != "base64" {
+		return []byte(f.Content), nil
+	}
+	data, err := base64.StdEncoding.DecodeString(f.Content)
+	if err != nil {
+		return nil, fmt.Errorf("解码文件内容失败: %w", err)
+	}
+	return data, nil
+}
+
+// gitGatewayResponse Git网关统一响应格式
+type gitGatewayResponse struct {
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Error   string          `json:"error"`
+}
+
+// GetFile 读取仓库在指定引用（分支、标签或提交SHA）下的文件，ref为空时使用默认分支
+func (c *gitGatewayClient) GetFile(repositoryID uuid.UUID, ref, path string, userID uuid.UUID) (*RepositoryFile, error) {
+	query := url.Values{}
+	query.Set("path", path)
+	if ref != "" {
+		query.Set("ref", ref)
+	}
+
+	endpoint := fmt.Sprintf("%s/api/v1/repositories/%s/files?%s",
+		strings.TrimRight(c.config.GitGateway.BaseURL, "/"), repositoryID, query.Encode())
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := signServiceToken(c.config.JWT.Secret, userID)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("User-Agent", "Axiom-CICD")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求Git网关失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result gitGatewayResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析响应失败 (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode >= 300 {
+		if result.Error != "" {
+			return nil, fmt.Errorf("读取文件 %s 失败: %s", path, result.Error)
+		}
+		return nil, fmt.Errorf("读取文件 %s 失败: HTTP %d", path, resp.StatusCode)
+	}
+
+	var file RepositoryFile
+	if err := json.Unmarshal(result.Data, &file); err != nil {
+		return nil, fmt.Errorf("解析文件内容失败: %w", err)
+	}
+	return &file, nil
+}
+
+// signServiceToken 签发调用其他服务的短期令牌（各服务共享JWT密钥）
+func signServiceToken(secret string, userID uuid.UUID) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userID.String(),
+		"role":    "service",
+		"iss":     "cicd-service",
+		"iat":     now.Unix(),
+		"exp":     now.Add(5 * time.Minute).Unix(),
+	})
+	signed, err := token.SignedString([]byte(secret))
+	if err != nil {
+		return "", fmt.Errorf("签发服务令牌失败: %w", err)
+	}
+	return signed, nil
+}
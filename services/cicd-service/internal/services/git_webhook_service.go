@@ -0,0 +1,557 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"cicd-service/internal/config"
+	"cicd-service/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Git事件类型，与git-gateway-service的 X-Event-Type 一致
+const (
+	GitEventPush        = "push"
+	GitEventPullRequest = "pull_request"
+	GitEventPing        = "ping"
+)
+
+// 触发器匹配使用的事件类别，tag 为推送标签
+const (
+	gitEventKindPush        = "push"
+	gitEventKindTag         = "tag"
+	gitEventKindPullRequest = "pull_request"
+)
+
+var (
+	// ErrWebhookSecretNotConfigured 未配置Git Webhook密钥
+	ErrWebhookSecretNotConfigured = errors.New("未配置Git Webhook密钥")
+	// ErrWebhookSignatureInvalid Webhook签名无效
+	ErrWebhookSignatureInvalid = errors.New("Webhook签名无效")
+	// ErrWebhookPayloadInvalid Webhook载荷无效
+	ErrWebhookPayloadInvalid = errors.New("Webhook载荷无效")
+	// ErrWebhookReplayed 事件已处理过（相同的投递ID）
+	ErrWebhookReplayed = errors.New("事件已处理，拒绝重放")
+)
+
+var (
+	// defaultPullRequestActions pull_request 触发器缺省响应的动作
+	defaultPullRequestActions = []string{"opened", "synchronize", "reopened"}
+	// pullRequestActions 合并请求事件的动作
+	pullRequestActions = []string{"opened", "synchronize", "reopened", "closed", "edited"}
+	// webhookTriggerEvents webhook 触发器 conditions.events 的可选值
+	webhookTriggerEvents = []string{gitEventKindPush, gitEventKindTag, gitEventKindPullRequest}
+	// commitSHAPattern 完整的提交SHA
+	commitSHAPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+)
+
+// GitWebhookService Git事件服务：校验git-gateway-service投递的事件，按流水线触发器创建运行
+type GitWebhookService interface {
+	// VerifySignature 校验 X-Hub-Signature-256（sha256=<HMAC-SHA256十六进制>）
+	VerifySignature(body []byte, signature string) error
+	// HandleEvent 处理仓库事件，为触发器匹配的流水线创建运行；同一投递ID只处理一次
+	HandleEvent(repositoryID uuid.UUID, deliveryID, eventType string, body []byte) (*GitWebhookResult, error)
+}
+
+type gitWebhookService struct {
+	db         *gorm.DB
+	config     *config.Config
+	runService PipelineRunService
+}
+
+// NewGitWebhookService 创建Git事件服务实例
+func NewGitWebhookService(db *gorm.DB, cfg *config.Config, runService PipelineRunService) GitWebhookService {
+	return &gitWebhookService{
+		db:         db,
+		config:     cfg,
+		runService: runService,
+	}
+}
+
+// GitEventUser 事件触发者
+type GitEventUser struct {
+	UserID   uuid.UUID `json:"user_id"` // 部署密钥/令牌推送时为空UUID
+	Username string    `json:"username"`
+}
+
+// GitPushCommit 推送中的提交
+type GitPushCommit struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+}
+
+// GitPushEvent 推送事件载荷（分支和标签）
+type GitPushEvent struct {
+	Ref          string          `json:"ref"`
+	Before       string          `json:"before"`
+	After        string          `json:"after"`
+	Created      bool            `json:"created"`
+	Deleted      bool            `json:"deleted"`
+	Forced       bool            `json:"forced"`
+	RepositoryID uuid.UUID       `json:"repository_id"`
+	Repository   string          `json:"repository"`
+	Pusher       GitEventUser    `json:"pusher"`
+	Commits      []GitPushCommit `json:"commits"`
+	ChangedFiles []string        `json:"changed_files"` // before到after的全部变更文件，null表示未知
+}
+
+// GitPullRequestEvent 合并请求事件载荷
+type GitPullRequestEvent struct {
+	Action        string       `json:"action"` // opened, synchronize, reopened, closed, edited
+	RepositoryID  uuid.UUID    `json:"repository_id"`
+	Repository    string       `json:"repository"`
+	PullRequestID *uuid.UUID   `json:"pull_request_id"`
+	Number        int          `json:"number"`
+	Title         string       `json:"title"`
+	SourceBranch  string       `json:"source_branch"`
+	TargetBranch  string       `json:"target_branch"`
+	HeadSHA       string       `json:"head_sha"`
+	BaseSHA       string       `json:"base_sha"`
+	Sender        GitEventUser `json:"sender"`
+	ChangedFiles  []string     `json:"changed_files"` // 合并基础到head的变更文件，null表示未知
+}
+
+// GitWebhookResult 事件处理结果
+type GitWebhookResult struct {
+	Event   string          `json:"event"`
+	Ref     string          `json:"ref,omitempty"`
+	Ignored string          `json:"ignored,omitempty"` // 未处理的原因
+	Runs    []GitWebhookRun `json:"runs"`
+}
+
+// GitWebhookRun 触发器匹配的流水线及创建结果
+type GitWebhookRun struct {
+	PipelineID   uuid.UUID  `json:"pipeline_id"`
+	PipelineName string     `json:"pipeline_name"`
+	RunID        *uuid.UUID `json:"run_id,omitempty"`
+	Error        string     `json:"error,omitempty"`
+}
+
+// gitEvent 用于触发器匹配的事件
+type gitEvent struct {
+	kind        string
+	ref         string   // 完整引用，合并请求为源分支引用
+	name        string   // 分支或标签名，合并请求为目标分支
+	action      string   // 合并请求动作
+	commitSHA   string   // 构建的提交
+	branch      string   // 运行的分支或标签
+	paths       []string // 变更文件，pathsKnown 为 false 时未知
+	pathsKnown  bool
+	triggerBy   *uuid.UUID
+	triggerData map[string]interface{}
+}
+
+// VerifySignature 校验事件签名
+func (s *gitWebhookService) VerifySignature(body []byte, signature string) error {
+	secret := s.config.GitGateway.WebhookSecret
+	if secret == "" {
+		return ErrWebhookSecretNotConfigured
+	}
+	digest, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return ErrWebhookSignatureInvalid
+	}
+	expected, err := hex.DecodeString(digest)
+	if err != nil {
+		return ErrWebhookSignatureInvalid
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return ErrWebhookSignatureInvalid
+	}
+	return nil
+}
+
+// HandleEvent 处理仓库事件。单个流水线创建运行失败不影响其他流水线，结果中记录失败原因
+func (s *gitWebhookService) HandleEvent(repositoryID uuid.UUID, deliveryID, eventType string, body []byte) (*GitWebhookResult, error) {
+	if err := verifyDeliveryEnvelope(body, deliveryID, eventType); err != nil {
+		return nil, err
+	}
+	result := &GitWebhookResult{Event: eventType, Runs: []GitWebhookRun{}}
+
+	var event *gitEvent
+	var err error
+	switch eventType {
+	case GitEventPush:
+		event, err = parseGitPushEvent(repositoryID, body)
+	case GitEventPullRequest:
+		event, err = parseGitPullRequestEvent(repositoryID, body)
+	case GitEventPing:
+		result.Ignored = "ping"
+		return result, nil
+	default:
+		// 分支/标签创建删除等事件与 push 事件同时投递，只处理 push 避免重复触发
+		result.Ignored = fmt.Sprintf("不处理 %s 事件", eventType)
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		result.Ignored = "引用已删除"
+		return result, nil
+	}
+	result.Ref = event.ref
+
+	delivery, err := s.recordDelivery(repositoryID, deliveryID, eventType)
+	if err != nil {
+		return nil, err
+	}
+
+	var pipelines []models.Pipeline
+	if err := s.db.Where("repository_id = ? AND status = ? AND deleted_at IS NULL", repositoryID, "active").
+		Order("created_at ASC").
+		Find(&pipelines).Error; err != nil {
+		// 未创建任何运行，删除投递记录以便重试
+		s.db.Delete(delivery)
+		return nil, fmt.Errorf("获取仓库流水线失败: %w", err)
+	}
+
+	for _, pipeline := range pipelines {
+		var triggers []TriggerConfig
+		if err := jsonUnmarshal(pipeline.Triggers, &triggers); err != nil {
+			continue
+		}
+
+		matched := false
+		for _, trigger := range triggers {
+			if trigger.Enabled && matchGitTrigger(&trigger, event) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		entry := GitWebhookRun{PipelineID: pipeline.ID, PipelineName: pipeline.Name}
+		commitSHA, branch := event.commitSHA, event.branch
+		run, err := s.runService.Create(&CreatePipelineRunRequest{
+			PipelineID:  pipeline.ID,
+			TriggerType: "webhook",
+			TriggerBy:   event.triggerBy,
+			TriggerData: event.triggerData,
+			CommitSHA:   &commitSHA,
+			Branch:      &branch,
+		})
+		if err != nil {
+			entry.Error = err.Error()
+		} else {
+			entry.RunID = &run.ID
+		}
+		result.Runs = append(result.Runs, entry)
+	}
+	return result, nil
+}
+
+// verifyDeliveryEnvelope 校验签名载荷中的 event 和 delivery_id 与请求头一致。
+// 请求头不在签名范围内，以载荷为准才能拒绝改写事件类型或投递ID后的重放
+func verifyDeliveryEnvelope(body []byte, deliveryID, eventType string) error {
+	var envelope struct {
+		Event      string `json:"event"`
+		DeliveryID string `json:"delivery_id"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("%w: %v", ErrWebhookPayloadInvalid, err)
+	}
+	if envelope.Event == "" || envelope.DeliveryID == "" {
+		return fmt.Errorf("%w: 缺少 event 或 delivery_id", ErrWebhookPayloadInvalid)
+	}
+	if envelope.Event != eventType {
+		return fmt.Errorf("%w: event 与 X-Event-Type 不一致", ErrWebhookPayloadInvalid)
+	}
+	if envelope.DeliveryID != deliveryID {
+		return fmt.Errorf("%w: delivery_id 与 X-Delivery-ID 不一致", ErrWebhookPayloadInvalid)
+	}
+	return nil
+}
+
+// recordDelivery 记录投递，投递ID已存在时返回 ErrWebhookReplayed
+func (s *gitWebhookService) recordDelivery(repositoryID uuid.UUID, deliveryID, eventType string) (*models.GitWebhookDelivery, error) {
+	delivery := &models.GitWebhookDelivery{
+		DeliveryID:   deliveryID,
+		RepositoryID: repositoryID,
+		EventType:    eventType,
+	}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(delivery)
+	if result.Error != nil {
+		return nil, fmt.Errorf("记录事件投递失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrWebhookReplayed
+	}
+	return delivery, nil
+}
+
+// parseGitPushEvent 解析推送事件，引用被删除时返回nil
+func parseGitPushEvent(repositoryID uuid.UUID, body []byte) (*gitEvent, error) {
+	var payload GitPushEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWebhookPayloadInvalid, err)
+	}
+	if payload.RepositoryID == uuid.Nil {
+		return nil, fmt.Errorf("%w: 缺少 repository_id", ErrWebhookPayloadInvalid)
+	}
+	if payload.RepositoryID != repositoryID {
+		return nil, fmt.Errorf("%w: 仓库ID与请求路径不一致", ErrWebhookPayloadInvalid)
+	}
+	if payload.Deleted {
+		return nil, nil
+	}
+	if !commitSHAPattern.MatchString(payload.After) {
+		return nil, fmt.Errorf("%w: after 不是有效的提交SHA", ErrWebhookPayloadInvalid)
+	}
+
+	event := &gitEvent{ref: payload.Ref, commitSHA: payload.After}
+	if name, ok := strings.CutPrefix(payload.Ref, "refs/heads/"); ok {
+		event.kind = gitEventKindPush
+		event.name = name
+	} else if name, ok := strings.CutPrefix(payload.Ref, "refs/tags/"); ok {
+		event.kind = gitEventKindTag
+		event.name = name
+	} else {
+		return nil, fmt.Errorf("%w: 不支持的引用 %q", ErrWebhookPayloadInvalid, payload.Ref)
+	}
+	event.branch = event.name
+
+	// 新建引用、变更过多时 changed_files 为null；提交列表有数量上限，不能代替 changed_files
+	event.paths, event.pathsKnown = payload.ChangedFiles, payload.ChangedFiles != nil
+
+	if payload.Pusher.UserID != uuid.Nil {
+		userID := payload.Pusher.UserID
+		event.triggerBy = &userID
+	}
+	event.triggerData = map[string]interface{}{
+		"event":         event.kind,
+		"ref":           payload.Ref,
+		"commit_sha":    payload.After,
+		"before":        payload.Before,
+		"created":       payload.Created,
+		"forced":        payload.Forced,
+		"repository_id": repositoryID.String(),
+		"pusher":        payload.Pusher.Username,
+	}
+	if event.kind == gitEventKindTag {
+		event.triggerData["tag"] = event.name
+	} else {
+		event.triggerData["branch"] = event.name
+	}
+	if len(payload.Commits) > 0 {
+		head := payload.Commits[len(payload.Commits)-1]
+		event.triggerData["commit_message"] = head.Message
+	}
+	return event, nil
+}
+
+// parseGitPullRequestEvent 解析合并请求事件
+func parseGitPullRequestEvent(repositoryID uuid.UUID, body []byte) (*gitEvent, error) {
+	var payload GitPullRequestEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWebhookPayloadInvalid, err)
+	}
+	if payload.RepositoryID == uuid.Nil {
+		return nil, fmt.Errorf("%w: 缺少 repository_id", ErrWebhookPayloadInvalid)
+	}
+	if payload.RepositoryID != repositoryID {
+		return nil, fmt.Errorf("%w: 仓库ID与请求路径不一致", ErrWebhookPayloadInvalid)
+	}
+	if !containsString(pullRequestActions, payload.Action) {
+		return nil, fmt.Errorf("%w: 不支持的合并请求动作 %q", ErrWebhookPayloadInvalid, payload.Action)
+	}
+	if payload.SourceBranch == "" || payload.TargetBranch == "" {
+		return nil, fmt.Errorf("%w: 缺少源分支或目标分支", ErrWebhookPayloadInvalid)
+	}
+	if !commitSHAPattern.MatchString(payload.HeadSHA) {
+		return nil, fmt.Errorf("%w: head_sha 不是有效的提交SHA", ErrWebhookPayloadInvalid)
+	}
+
+	event := &gitEvent{
+		kind:       gitEventKindPullRequest,
+		ref:        "refs/heads/" + payload.SourceBranch,
+		name:       payload.TargetBranch,
+		action:     payload.Action,
+		commitSHA:  payload.HeadSHA,
+		branch:     payload.SourceBranch,
+		paths:      payload.ChangedFiles,
+		pathsKnown: payload.ChangedFiles != nil,
+	}
+	if payload.Sender.UserID != uuid.Nil {
+		userID := payload.Sender.UserID
+		event.triggerBy = &userID
+	}
+	event.triggerData = map[string]interface{}{
+		"event":         gitEventKindPullRequest,
+		"action":        payload.Action,
+		"ref":           event.ref,
+		"commit_sha":    payload.HeadSHA,
+		"base_sha":      payload.BaseSHA,
+		"source_branch": payload.SourceBranch,
+		"target_branch": payload.TargetBranch,
+		"number":        payload.Number,
+		"title":         payload.Title,
+		"repository_id": repositoryID.String(),
+		"sender":        payload.Sender.Username,
+	}
+	if payload.PullRequestID != nil {
+		event.triggerData["pull_request_id"] = payload.PullRequestID.String()
+	}
+	return event, nil
+}
+
+// matchGitTrigger 判断触发器是否匹配事件：
+// push 匹配分支推送，tag 匹配标签推送，pull_request 匹配合并请求，webhook 按 conditions.events 匹配以上任意事件
+func matchGitTrigger(trigger *TriggerConfig, event *gitEvent) bool {
+	conditions := trigger.Conditions
+	switch trigger.Type {
+	case "push", "tag", "pull_request":
+		if trigger.Type != event.kind {
+			return false
+		}
+	case "webhook":
+		events, _ := conditionStrings(conditions, "events")
+		if len(events) > 0 && !containsString(events, event.kind) {
+			return false
+		}
+	default:
+		return false
+	}
+
+	switch event.kind {
+	case gitEventKindTag:
+		return matchRefFilter(conditions, "tags", event.name)
+	case gitEventKindPullRequest:
+		actions, _ := conditionStrings(conditions, "actions")
+		if len(actions) == 0 {
+			actions = defaultPullRequestActions
+		}
+		if !containsString(actions, event.action) {
+			return false
+		}
+	}
+	return matchRefFilter(conditions, "branches", event.name) && matchPathFilter(conditions, event)
+}
+
+// matchRefFilter 按 <key> 和 <key>_ignore 的通配符匹配分支或标签名，** 可匹配多级
+func matchRefFilter(conditions map[string]interface{}, key, name string) bool {
+	include, _ := conditionStrings(conditions, key)
+	ignore, _ := conditionStrings(conditions, key+"_ignore")
+	if len(include) > 0 && !matchRefPatterns(include, name) {
+		return false
+	}
+	return !matchRefPatterns(ignore, name)
+}
+
+// matchRefPatterns 判断名称是否匹配任一模式
+func matchRefPatterns(patterns []string, name string) bool {
+	segments := strings.Split(name, "/")
+	for _, pattern := range patterns {
+		if matchPathSegments(strings.Split(pattern, "/"), segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPathFilter 按 paths 和 paths_ignore 匹配变更文件：至少一个变更文件匹配 paths 且不被 paths_ignore 排除。
+// 事件未携带变更文件时视为匹配，避免漏掉构建
+func matchPathFilter(conditions map[string]interface{}, event *gitEvent) bool {
+	include, _ := conditionStrings(conditions, "paths")
+	ignore, _ := conditionStrings(conditions, "paths_ignore")
+	if len(include) == 0 && len(ignore) == 0 {
+		return true
+	}
+	if !event.pathsKnown {
+		return true
+	}
+	for _, file := range event.paths {
+		file = strings.TrimPrefix(path.Clean(file), "/")
+		if len(include) > 0 && !ArtifactPathMatches(include, file) {
+			continue
+		}
+		if len(ignore) > 0 && ArtifactPathMatches(ignore, file) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// conditionStrings 读取触发条件中的字符串列表，允许单个字符串
+func conditionStrings(conditions map[string]interface{}, key string) ([]string, error) {
+	value, ok := conditions[key]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("conditions.%s 必须是字符串列表", key)
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("conditions.%s 必须是字符串列表", key)
+	}
+}
+
+// validateGitTriggerConditions 校验Git事件触发器的过滤条件
+func validateGitTriggerConditions(triggerType string, conditions map[string]interface{}) []string {
+	var problems []string
+	keys := []string{"branches", "branches_ignore", "paths", "paths_ignore"}
+	switch triggerType {
+	case "tag":
+		keys = []string{"tags", "tags_ignore"}
+	case "pull_request":
+		keys = append(keys, "actions")
+	case "webhook":
+		keys = append(keys, "tags", "tags_ignore", "actions", "events")
+	}
+
+	for _, key := range keys {
+		values, err := conditionStrings(conditions, key)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		for _, value := range values {
+			switch key {
+			case "actions":
+				if !containsString(pullRequestActions, value) {
+					problems = append(problems, fmt.Sprintf("conditions.actions 无效: %q（可选 %s）",
+						value, strings.Join(pullRequestActions, ", ")))
+				}
+			case "events":
+				if !containsString(webhookTriggerEvents, value) {
+					problems = append(problems, fmt.Sprintf("conditions.events 无效: %q（可选 %s）",
+						value, strings.Join(webhookTriggerEvents, ", ")))
+				}
+			default:
+				if value == "" {
+					problems = append(problems, fmt.Sprintf("conditions.%s 不能包含空模式", key))
+				} else if _, err := path.Match(value, ""); err != nil {
+					problems = append(problems, fmt.Sprintf("conditions.%s 模式无效: %q", key, value))
+				}
+			}
+		}
+	}
+	return problems
+}
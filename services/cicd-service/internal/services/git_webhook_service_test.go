@@ -0,0 +1,141 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParseGitPushEvent(t *testing.T) {
+	repositoryID := uuid.New()
+	sha := "0123456789abcdef0123456789abcdef01234567"
+
+	tests := []struct {
+		name       string
+		payload    map[string]interface{}
+		wantErr    bool
+		wantNil    bool
+		wantKind   string
+		pathsKnown bool
+	}{
+		{"branch push", map[string]interface{}{"ref": "refs/heads/main", "after": sha, "repository_id": repositoryID, "changed_files": []string{"a.go"}}, false, false, gitEventKindPush, true},
+		{"tag push", map[string]interface{}{"ref": "refs/tags/v1.0.0", "after": sha, "repository_id": repositoryID}, false, false, gitEventKindTag, false},
+		{"null changed files unknown", map[string]interface{}{"ref": "refs/heads/main", "after": sha, "repository_id": repositoryID, "changed_files": nil}, false, false, gitEventKindPush, false},
+		{"empty changed files known", map[string]interface{}{"ref": "refs/heads/main", "after": sha, "repository_id": repositoryID, "changed_files": []string{}}, false, false, gitEventKindPush, true},
+		{"deleted ref", map[string]interface{}{"ref": "refs/heads/main", "deleted": true, "repository_id": repositoryID}, false, true, "", false},
+		{"missing repository id", map[string]interface{}{"ref": "refs/heads/main", "after": sha}, true, false, "", false},
+		{"other repository", map[string]interface{}{"ref": "refs/heads/main", "after": sha, "repository_id": uuid.New()}, true, false, "", false},
+		{"invalid sha", map[string]interface{}{"ref": "refs/heads/main", "after": "abc", "repository_id": repositoryID}, true, false, "", false},
+		{"unsupported ref", map[string]interface{}{"ref": "refs/notes/commits", "after": sha, "repository_id": repositoryID}, true, false, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.payload)
+			event, err := parseGitPushEvent(repositoryID, body)
+			if tt.wantErr {
+				if !errors.Is(err, ErrWebhookPayloadInvalid) {
+					t.Fatalf("err = %v, want ErrWebhookPayloadInvalid", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseGitPushEvent: %v", err)
+			}
+			if tt.wantNil {
+				if event != nil {
+					t.Fatalf("event = %+v, want nil", event)
+				}
+				return
+			}
+			if event.kind != tt.wantKind || event.pathsKnown != tt.pathsKnown {
+				t.Fatalf("kind=%q pathsKnown=%v, want %q %v", event.kind, event.pathsKnown, tt.wantKind, tt.pathsKnown)
+			}
+		})
+	}
+}
+
+func TestMatchGitTrigger(t *testing.T) {
+	push := &gitEvent{kind: gitEventKindPush, name: "main", paths: []string{"docs/readme.md", "src/app/main.go"}, pathsKnown: true}
+	docsOnly := &gitEvent{kind: gitEventKindPush, name: "main", paths: []string{"docs/readme.md"}, pathsKnown: true}
+	unknownPaths := &gitEvent{kind: gitEventKindPush, name: "main"}
+	tag := &gitEvent{kind: gitEventKindTag, name: "v1.2.0"}
+	pr := &gitEvent{kind: gitEventKindPullRequest, name: "main", action: "synchronize"}
+	prClosed := &gitEvent{kind: gitEventKindPullRequest, name: "main", action: "closed"}
+
+	tests := []struct {
+		name    string
+		trigger TriggerConfig
+		event   *gitEvent
+		want    bool
+	}{
+		{"push branch glob", TriggerConfig{Type: "push", Conditions: map[string]interface{}{"branches": "ma*"}}, push, true},
+		{"push branch ignored", TriggerConfig{Type: "push", Conditions: map[string]interface{}{"branches_ignore": []interface{}{"main"}}}, push, false},
+		{"push paths match", TriggerConfig{Type: "push", Conditions: map[string]interface{}{"paths": "src/**"}}, push, true},
+		{"push paths no match", TriggerConfig{Type: "push", Conditions: map[string]interface{}{"paths": "src/**"}}, docsOnly, false},
+		{"push paths_ignore all", TriggerConfig{Type: "push", Conditions: map[string]interface{}{"paths_ignore": "docs/**"}}, docsOnly, false},
+		{"unknown paths match", TriggerConfig{Type: "push", Conditions: map[string]interface{}{"paths": "src/**"}}, unknownPaths, true},
+		{"push trigger ignores tag", TriggerConfig{Type: "push"}, tag, false},
+		{"tag glob", TriggerConfig{Type: "tag", Conditions: map[string]interface{}{"tags": "v1.*"}}, tag, true},
+		{"pull request default actions", TriggerConfig{Type: "pull_request"}, pr, true},
+		{"pull request closed not default", TriggerConfig{Type: "pull_request"}, prClosed, false},
+		{"webhook events filter", TriggerConfig{Type: "webhook", Conditions: map[string]interface{}{"events": []interface{}{"tag"}}}, push, false},
+		{"webhook all events", TriggerConfig{Type: "webhook"}, tag, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchGitTrigger(&tt.trigger, tt.event); got != tt.want {
+				t.Fatalf("matchGitTrigger = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyDeliveryEnvelope(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		deliveryID string
+		eventType  string
+		wantErr    bool
+	}{
+		{"matching headers", `{"event":"push","delivery_id":"d1","ref":"refs/heads/main"}`, "d1", "push", false},
+		{"event type rewritten", `{"event":"pull_request","delivery_id":"d1"}`, "d1", "push", true},
+		{"delivery id rewritten", `{"event":"push","delivery_id":"d1"}`, "d2", "push", true},
+		{"unsigned event type", `{"delivery_id":"d1"}`, "d1", "push", true},
+		{"unsigned delivery id", `{"event":"push"}`, "d1", "push", true},
+		{"not an object", `["push"]`, "d1", "push", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyDeliveryEnvelope([]byte(tt.body), tt.deliveryID, tt.eventType)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrWebhookPayloadInvalid) {
+				t.Fatalf("err = %v, want ErrWebhookPayloadInvalid", err)
+			}
+		})
+	}
+}
+
+func TestRecordDelivery(t *testing.T) {
+	db := newTestDB(t, `CREATE TABLE git_webhook_deliveries (id TEXT PRIMARY KEY, delivery_id TEXT NOT NULL UNIQUE,
+		repository_id TEXT NOT NULL, event_type TEXT NOT NULL, created_at DATETIME NOT NULL)`)
+	s := &gitWebhookService{db: db}
+	repositoryID := uuid.New()
+
+	// 同一提交再次推送时载荷相同，但投递ID不同，两次都处理
+	for _, deliveryID := range []string{"d1", "d2"} {
+		if _, err := s.recordDelivery(repositoryID, deliveryID, GitEventPush); err != nil {
+			t.Fatalf("recordDelivery(%s): %v", deliveryID, err)
+		}
+	}
+	if _, err := s.recordDelivery(repositoryID, "d1", GitEventPush); !errors.Is(err, ErrWebhookReplayed) {
+		t.Fatalf("replayed delivery err = %v, want ErrWebhookReplayed", err)
+	}
+}
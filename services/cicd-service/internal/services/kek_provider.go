@@ -0,0 +1,259 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"cicd-service/internal/config"
+)
+
+// ErrKEKNotFound 主密钥引用在提供方中不存在
+var ErrKEKNotFound = errors.New("主密钥不存在")
+
+// KEKProvider 主密钥(KEK)提供方，负责加解密数据密钥(DEK)，主密钥本身不离开提供方
+type KEKProvider interface {
+	// Name 提供方名称
+	Name() string
+	// CurrentRef 当前用于加密新DEK的主密钥引用
+	CurrentRef() (string, error)
+	// WrapKey 使用当前主密钥加密DEK，返回主密钥引用和密文
+	WrapKey(ctx context.Context, dek []byte) (string, []byte, error)
+	// UnwrapKey 使用引用对应的主密钥解密DEK
+	UnwrapKey(ctx context.Context, ref string, wrapped []byte) ([]byte, error)
+}
+
+// NewKEKProvider 根据配置创建主密钥提供方
+func NewKEKProvider(cfg *config.Config) (KEKProvider, error) {
+	switch cfg.Secrets.KEKProvider {
+	case "", "local":
+		return NewLocalKEKProvider(cfg.Secrets.LocalKeyFile, !cfg.IsProduction())
+	default:
+		return nil, fmt.Errorf("不支持的主密钥提供方: %s", cfg.Secrets.KEKProvider)
+	}
+}
+
+// localKEKProvider 基于本地密钥文件的主密钥提供方。
+// 文件每行一个 "<密钥ID>:<base64编码的32字节密钥>"，以 # 开头的行为注释，最后一个密钥为当前密钥；
+// 轮换时在文件末尾追加新密钥，旧密钥需保留到所有密钥重新加密完成。文件修改后自动重新加载。
+type localKEKProvider struct {
+	path string
+
+	mu      sync.RWMutex
+	keys    map[string][]byte
+	current string
+	modTime time.Time
+}
+
+const localKEKRefPrefix = "local:"
+
+// NewLocalKEKProvider 创建本地密钥文件提供方，文件不存在且允许生成时创建新的主密钥文件
+func NewLocalKEKProvider(path string, generate bool) (KEKProvider, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if !generate {
+			return nil, fmt.Errorf("主密钥文件不存在: %s", path)
+		}
+		if err := generateLocalKEKFile(path); err != nil {
+			return nil, err
+		}
+		log.Printf("🔑 已生成本地主密钥文件: %s", path)
+	}
+
+	p := &localKEKProvider{path: path}
+	if err := p.reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// generateLocalKEKFile 生成只包含一个随机主密钥的密钥文件
+func generateLocalKEKFile(path string) error {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return fmt.Errorf("生成主密钥失败: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("创建主密钥目录失败: %w", err)
+	}
+
+	content := fmt.Sprintf("# Axiom CI/CD 主密钥文件，最后一行为当前密钥\n%s:%s\n",
+		time.Now().UTC().Format("20060102150405"), base64.StdEncoding.EncodeToString(key))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("创建主密钥文件失败: %w", err)
+	}
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		return fmt.Errorf("写入主密钥文件失败: %w", err)
+	}
+	return file.Close()
+}
+
+// Name 提供方名称
+func (p *localKEKProvider) Name() string {
+	return "local"
+}
+
+// CurrentRef 当前主密钥引用
+func (p *localKEKProvider) CurrentRef() (string, error) {
+	if err := p.refresh(); err != nil {
+		return "", err
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return localKEKRefPrefix + p.current, nil
+}
+
+// WrapKey 使用当前主密钥加密DEK，主密钥引用作为附加认证数据
+func (p *localKEKProvider) WrapKey(ctx context.Context, dek []byte) (string, []byte, error) {
+	if err := p.refresh(); err != nil {
+		return "", nil, err
+	}
+	p.mu.RLock()
+	ref, key := localKEKRefPrefix+p.current, p.keys[p.current]
+	p.mu.RUnlock()
+
+	wrapped, err := sealAESGCM(key, dek, []byte(ref))
+	if err != nil {
+		return "", nil, fmt.Errorf("加密数据密钥失败: %w", err)
+	}
+	return ref, wrapped, nil
+}
+
+// UnwrapKey 使用引用对应的主密钥解密DEK
+func (p *localKEKProvider) UnwrapKey(ctx context.Context, ref string, wrapped []byte) ([]byte, error) {
+	if err := p.refresh(); err != nil {
+		return nil, err
+	}
+	id, ok := strings.CutPrefix(ref, localKEKRefPrefix)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKEKNotFound, ref)
+	}
+	p.mu.RLock()
+	key, ok := p.keys[id]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKEKNotFound, ref)
+	}
+
+	dek, err := openAESGCM(key, wrapped, []byte(ref))
+	if err != nil {
+		return nil, fmt.Errorf("解密数据密钥失败: %w", err)
+	}
+	return dek, nil
+}
+
+// refresh 密钥文件修改后重新加载
+func (p *localKEKProvider) refresh() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return fmt.Errorf("读取主密钥文件失败: %w", err)
+	}
+	p.mu.RLock()
+	changed := !info.ModTime().Equal(p.modTime)
+	p.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+	return p.reload()
+}
+
+// reload 读取并解析密钥文件
+func (p *localKEKProvider) reload() error {
+	file, err := os.Open(p.path)
+	if err != nil {
+		return fmt.Errorf("读取主密钥文件失败: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("读取主密钥文件失败: %w", err)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		log.Printf("⚠️ 主密钥文件 %s 权限过宽(%s)，建议设置为 0600", p.path, info.Mode().Perm())
+	}
+
+	keys := make(map[string][]byte)
+	var current string
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(line, ":")
+		id = strings.TrimSpace(id)
+		if !ok || id == "" {
+			return fmt.Errorf("主密钥文件第 %d 行格式错误", lineNo)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(key) != 32 {
+			return fmt.Errorf("主密钥文件第 %d 行不是base64编码的32字节密钥", lineNo)
+		}
+		if _, exists := keys[id]; exists {
+			return fmt.Errorf("主密钥文件中密钥ID重复: %s", id)
+		}
+		keys[id] = key
+		current = id
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取主密钥文件失败: %w", err)
+	}
+	if current == "" {
+		return fmt.Errorf("主密钥文件中没有密钥: %s", p.path)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.current != "" && p.current != current {
+		log.Printf("🔑 主密钥已切换: %s -> %s", p.current, current)
+	}
+	p.keys, p.current, p.modTime = keys, current, info.ModTime()
+	return nil
+}
+
+// sealAESGCM 使用AES-256-GCM加密，输出为 nonce+密文
+func sealAESGCM(key, plaintext, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// openAESGCM 解密 sealAESGCM 的输出
+func openAESGCM(key, sealed, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("密文长度不足")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, additionalData)
+}
@@ -0,0 +1,717 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cicd-service/internal/config"
+	"cicd-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// localOutputTailSize 任务失败时保留在错误信息中的输出尾部长度
+	localOutputTailSize = 4096
+	// localDefaultMountPath 容器内工作空间挂载路径
+	localDefaultMountPath = "/workspace"
+)
+
+// localExecutor 本地执行器：按依赖关系在本机执行任务，
+// 检测到容器运行时（docker/podman）时在容器中运行任务镜像，否则以受限进程直接运行命令。
+// 进程模式只提供基础隔离（独立进程组、清空的环境变量、限定在运行工作空间内的工作目录），
+// 仅用于开发和测试，生产环境应使用Tekton或容器运行时。
+type localExecutor struct {
+	config     *config.Config
+	logService LogService
+	artifacts  ArtifactService
+	secrets    SecretService
+	runtime    string // 容器运行时可执行文件路径，为空时使用进程模式
+
+	mu   sync.Mutex
+	runs map[uuid.UUID]*localRun
+}
+
+// localRun 本地执行中的流水线运行
+type localRun struct {
+	cancel    context.CancelFunc
+	cancelled atomic.Bool // 由 Cancel 主动取消，最终状态已由调用方回写
+}
+
+// localTaskResult 单个任务的执行结果
+type localTaskResult struct {
+	name     string
+	status   string
+	exitCode *int
+	message  *string
+}
+
+// NewLocalExecutor 创建本地执行器
+// 进程模式下任务与服务以同一用户运行，可以读取主密钥文件等服务数据，因此只允许在开发环境显式启用
+func NewLocalExecutor(cfg *config.Config, logService LogService, artifactService ArtifactService, secretService SecretService) (Executor, error) {
+	runtime, err := detectContainerRuntime(cfg.Executor.ContainerRuntime)
+	if err != nil {
+		return nil, err
+	}
+	if runtime != "" {
+		log.Printf("🐳 本地执行器使用容器运行时: %s", runtime)
+	} else {
+		if !cfg.Executor.AllowProcessMode || !cfg.IsDevelopment() {
+			return nil, fmt.Errorf("本地执行器没有可用的容器运行时；以本地进程运行任务需在开发环境中开启 executor.allow_process_mode")
+		}
+		log.Printf("⚠️ 本地执行器未使用容器运行时，任务将以本地进程运行（仅限开发环境，任务可访问服务的全部文件）")
+	}
+
+	return &localExecutor{
+		config:     cfg,
+		logService: logService,
+		artifacts:  artifactService,
+		secrets:    secretService,
+		runtime:    runtime,
+		runs:       make(map[uuid.UUID]*localRun),
+	}, nil
+}
+
+// detectContainerRuntime 查找可用的容器运行时：auto 依次查找 docker、podman，都不存在时返回空；
+// 显式指定的运行时不存在时返回错误，不会退回到进程模式
+func detectContainerRuntime(setting string) (string, error) {
+	switch setting {
+	case "none":
+		return "", nil
+	case "docker", "podman":
+		runtimePath, err := exec.LookPath(setting)
+		if err != nil {
+			return "", fmt.Errorf("未找到容器运行时 %s: %w", setting, err)
+		}
+		return runtimePath, nil
+	}
+
+	for _, name := range []string{"docker", "podman"} {
+		if runtimePath, err := exec.LookPath(name); err == nil {
+			return runtimePath, nil
+		}
+	}
+	return "", nil
+}
+
+// Name 执行器名称
+func (e *localExecutor) Name() string {
+	return "local"
+}
+
+// Execute 创建运行工作空间并在后台执行流水线
+func (e *localExecutor) Execute(ctx context.Context, req *ExecutionRequest) error {
+	workspace := filepath.Join(e.config.Executor.WorkDir, req.Run.ID.String())
+	if err := os.MkdirAll(workspace, 0o755); err != nil {
+		return fmt.Errorf("创建工作空间失败: %w", err)
+	}
+
+	var runCtx context.Context
+	var cancel context.CancelFunc
+	if req.Pipeline.Config.Timeout > 0 {
+		runCtx, cancel = context.WithTimeout(context.Background(), time.Duration(req.Pipeline.Config.Timeout)*time.Second)
+	} else {
+		runCtx, cancel = context.WithCancel(context.Background())
+	}
+
+	lr := &localRun{cancel: cancel}
+	e.mu.Lock()
+	e.runs[req.Run.ID] = lr
+	e.mu.Unlock()
+
+	go e.run(runCtx, req, lr, workspace)
+	return nil
+}
+
+// Cancel 取消本地执行中的流水线运行，正在运行的任务会被终止
+func (e *localExecutor) Cancel(ctx context.Context, runID uuid.UUID) error {
+	e.mu.Lock()
+	lr, ok := e.runs[runID]
+	e.mu.Unlock()
+	if !ok {
+		// 运行已结束或不在本实例中（如服务重启后），无需终止
+		return nil
+	}
+
+	lr.cancelled.Store(true)
+	lr.cancel()
+	return nil
+}
+
+// run 执行流水线并回写最终状态
+func (e *localExecutor) run(ctx context.Context, req *ExecutionRequest, lr *localRun, workspace string) {
+	defer func() {
+		lr.cancel()
+		e.mu.Lock()
+		delete(e.runs, req.Run.ID)
+		e.mu.Unlock()
+
+		if !e.config.Executor.KeepWorkspace {
+			if err := os.RemoveAll(workspace); err != nil {
+				log.Printf("⚠️ 清理工作空间失败 %s: %v", workspace, err)
+			}
+		}
+	}()
+
+	results := e.runTasks(ctx, req, workspace)
+
+	if lr.cancelled.Load() {
+		return
+	}
+
+	status := "succeeded"
+	var message *string
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		status = "timeout"
+		msg := fmt.Sprintf("流水线运行超时(%d秒)", req.Pipeline.Config.Timeout)
+		message = &msg
+	default:
+		var failed []string
+		for _, task := range req.Pipeline.Tasks {
+			if results[task.Name] == "failed" {
+				failed = append(failed, task.Name)
+			}
+		}
+		if len(failed) > 0 {
+			status = "failed"
+			msg := fmt.Sprintf("任务执行失败: %s", strings.Join(failed, ", "))
+			message = &msg
+		}
+	}
+
+	if err := req.Reporter.UpdateStatus(req.Run.ID, status, message); err != nil {
+		log.Printf("⚠️ 回写流水线运行状态失败 %s: %v", req.Run.ID, err)
+	}
+}
+
+// runTasks 按依赖关系和执行条件调度任务，无依赖关系的任务并行执行，返回各任务最终状态。
+// 矩阵任务的组合受 max_parallel 限制，fail_fast 时任一组合失败即取消同一矩阵的其余组合；
+// 部署到受保护环境的任务在审批通过前保持等待
+func (e *localExecutor) runTasks(ctx context.Context, req *ExecutionRequest, workspace string) map[string]string {
+	tasks := make([]models.Task, len(req.Pipeline.Tasks))
+	copy(tasks, req.Pipeline.Tasks)
+	sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].Order < tasks[j].Order })
+
+	known := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		known[task.Name] = true
+	}
+	matrixKnownTasks(known, req.Matrix)
+
+	// 每个矩阵任务一个子上下文，fail_fast 时取消正在执行的组合
+	matrixCtx := make(map[string]context.Context)
+	matrixCancel := make(map[string]context.CancelCauseFunc)
+	for _, combination := range req.Matrix {
+		if _, ok := matrixCtx[combination.Task]; !ok {
+			matrixCtx[combination.Task], matrixCancel[combination.Task] = context.WithCancelCause(ctx)
+		}
+	}
+	defer func() {
+		for _, cancel := range matrixCancel {
+			cancel(nil)
+		}
+	}()
+
+	maxParallel := e.config.Executor.MaxParallelTasks
+	if maxParallel <= 0 {
+		maxParallel = 1
+	}
+	slots := make(chan struct{}, maxParallel)
+	done := make(chan localTaskResult)
+
+	// 执行条件和插值的运行级上下文，任务结果在判断每个任务时补充
+	scope := newExpressionScope(req)
+	states := make(map[string]string, len(tasks))
+	running := 0
+
+	// 等待部署审批的任务定期重新检查，审批结果由审批服务记录
+	poll := time.NewTicker(deploymentPollInterval)
+	defer poll.Stop()
+
+	for {
+		awaiting := make(map[string]bool)
+		// 反复扫描，直到没有新的任务可以启动或跳过
+		for changed := true; changed; {
+			changed = false
+			for _, task := range tasks {
+				if _, seen := states[task.Name]; seen {
+					continue
+				}
+
+				taskCtx := ctx
+				combination := req.Matrix[task.Name]
+				if combination != nil {
+					taskCtx = matrixCtx[combination.Task]
+					if cause := context.Cause(taskCtx); errors.Is(cause, errMatrixFailFast) {
+						msg := cause.Error()
+						states[task.Name] = "cancelled"
+						e.reportTask(req, task.Name, &TaskRunStatusUpdate{Status: "cancelled", ErrorMessage: &msg})
+						changed = true
+						continue
+					}
+				}
+
+				logical := logicalTaskStates(states, req.Matrix)
+				gate, reason := checkTaskGate(task.DependsOn, task.Condition, logical, known, func() map[string]interface{} {
+					return taskExpressionScope(scope, logical, combination)
+				})
+				if gate != gateWait && ctx.Err() != nil {
+					msg := "流水线运行已终止"
+					states[task.Name] = "cancelled"
+					e.reportTask(req, task.Name, &TaskRunStatusUpdate{Status: "cancelled", ErrorMessage: &msg})
+					changed = true
+					continue
+				}
+				if gate == gateSkip || gate == gateFail {
+					status := "skipped"
+					if gate == gateFail {
+						status = "failed"
+					}
+					states[task.Name] = status
+					e.reportTask(req, task.Name, &TaskRunStatusUpdate{Status: status, ErrorMessage: &reason})
+					changed = true
+					continue
+				}
+				if gate == gateWait {
+					continue
+				}
+				if combination != nil && combination.MaxParallel > 0 &&
+					matrixRunning(states, req.Matrix, combination.Task) >= combination.MaxParallel {
+					continue
+				}
+				if gateReq := deploymentGateRequest(req, &task); gateReq != nil {
+					ready, reason := req.Gate.CheckDeployment(gateReq)
+					if reason != "" {
+						states[task.Name] = "failed"
+						e.reportTask(req, task.Name, &TaskRunStatusUpdate{Status: "failed", ErrorMessage: &reason})
+						changed = true
+						continue
+					}
+					if !ready {
+						awaiting[task.Name] = true
+						continue
+					}
+					delete(awaiting, task.Name)
+				}
+
+				rendered, err := renderTask(task, taskExpressionScope(scope, logical, combination))
+				if err != nil {
+					msg := err.Error()
+					states[task.Name] = "failed"
+					e.reportTask(req, task.Name, &TaskRunStatusUpdate{Status: "failed", ErrorMessage: &msg})
+					changed = true
+					continue
+				}
+
+				states[task.Name] = "running"
+				running++
+				changed = true
+				go func(ctx context.Context, task models.Task) {
+					select {
+					case slots <- struct{}{}:
+						defer func() { <-slots }()
+						done <- e.runTask(ctx, req, task, workspace)
+					case <-ctx.Done():
+						msg := cancelMessage(ctx)
+						e.reportTask(req, task.Name, &TaskRunStatusUpdate{Status: "cancelled", ErrorMessage: &msg})
+						done <- localTaskResult{name: task.Name, status: "cancelled"}
+					}
+				}(taskCtx, rendered)
+			}
+		}
+
+		if running == 0 && len(awaiting) == 0 {
+			return states
+		}
+
+		// 有任务等待审批时，定期重新检查，运行终止后下一轮扫描将其取消
+		var recheck <-chan time.Time
+		var stopped <-chan struct{}
+		if len(awaiting) > 0 {
+			recheck, stopped = poll.C, ctx.Done()
+		}
+		select {
+		case result := <-done:
+			running--
+			states[result.name] = result.status
+			if combination := req.Matrix[result.name]; combination != nil && combination.FailFast && result.status == "failed" {
+				matrixCancel[combination.Task](fmt.Errorf("%w: %s", errMatrixFailFast, result.name))
+			}
+		case <-recheck:
+		case <-stopped:
+		}
+	}
+}
+
+// cancelMessage 任务被取消的原因：矩阵 fail_fast 取消或流水线运行终止
+func cancelMessage(ctx context.Context) string {
+	if cause := context.Cause(ctx); errors.Is(cause, errMatrixFailFast) {
+		return cause.Error()
+	}
+	return "流水线运行已终止"
+}
+
+// dependencyState 判断任务依赖是否满足；reason 非空表示任务因依赖无法执行而跳过
+func dependencyState(dependsOn []string, states map[string]string, known map[string]bool) (ready bool, reason string) {
+	for _, dep := range dependsOn {
+		if !known[dep] {
+			return false, fmt.Sprintf("依赖任务 %s 不存在", dep)
+		}
+		switch state, seen := states[dep]; {
+		case !seen, state == "running":
+			return false, ""
+		case state != "succeeded":
+			return false, fmt.Sprintf("依赖任务 %s 未成功(%s)", dep, state)
+		}
+	}
+	return true, ""
+}
+
+// runTask 执行单个任务（包含重试）并回写任务运行状态，任务输出写入任务日志
+func (e *localExecutor) runTask(ctx context.Context, req *ExecutionRequest, task models.Task, workspace string) localTaskResult {
+	e.reportTask(req, task.Name, &TaskRunStatusUpdate{Status: "running"})
+
+	var logs io.WriteCloser = nopWriteCloser{io.Discard}
+	if taskRunID, ok := req.TaskRunIDs[task.Name]; ok {
+		logs = e.logService.NewWriter(req.Run.ID, taskRunID)
+	}
+
+	var result localTaskResult
+	secretEnv, err := e.resolveSecrets(ctx, req, task, logs)
+	if err == nil {
+		err = e.fetchArtifacts(ctx, req, task, workspace, logs)
+	}
+	if err != nil {
+		msg := err.Error()
+		result = localTaskResult{name: task.Name, status: "failed", message: &msg}
+	} else {
+		for attempt := 0; attempt <= task.Retries; attempt++ {
+			if attempt > 0 {
+				retryCount := attempt
+				fmt.Fprintf(logs, "\n--- 第 %d 次重试 ---\n", attempt)
+				e.reportTask(req, task.Name, &TaskRunStatusUpdate{Status: "running", RetryCount: &retryCount})
+			}
+
+			result = e.execTask(ctx, req, task, workspace, secretEnv, logs)
+			if result.status != "failed" || ctx.Err() != nil {
+				break
+			}
+		}
+
+		if err := e.collectArtifacts(ctx, req, task, workspace, result.status, logs); err != nil && result.status == "succeeded" {
+			msg := err.Error()
+			result.status = "failed"
+			result.message = &msg
+		}
+	}
+
+	// 回写结束状态前写入剩余日志，保证日志跟踪方读到完整输出
+	logs.Close()
+	e.reportTask(req, task.Name, &TaskRunStatusUpdate{
+		Status:       result.status,
+		ExitCode:     result.exitCode,
+		ErrorMessage: result.message,
+	})
+	return result
+}
+
+// resolveSecrets 解密任务引用的密钥，返回需要注入的环境变量
+func (e *localExecutor) resolveSecrets(ctx context.Context, req *ExecutionRequest, task models.Task, logs io.Writer) (map[string]string, error) {
+	refs := taskSecretReferences(&task)
+	if len(refs) == 0 || e.secrets == nil {
+		return nil, nil
+	}
+
+	env, err := e.secrets.ResolveEnv(ctx, req.Pipeline.ProjectID, refs)
+	if err != nil {
+		fmt.Fprintf(logs, "获取密钥失败: %v\n", err)
+		return nil, fmt.Errorf("获取密钥失败: %w", err)
+	}
+	return env, nil
+}
+
+// fetchArtifacts 将任务依赖的产物下载到工作空间
+func (e *localExecutor) fetchArtifacts(ctx context.Context, req *ExecutionRequest, task models.Task, workspace string, logs io.Writer) error {
+	deps := taskArtifactDependencies(&task)
+	if len(deps) == 0 || e.artifacts == nil {
+		return nil
+	}
+
+	artifacts, err := e.artifacts.ResolveDependencies(req.Pipeline, req.Run.ID, deps)
+	if err == nil {
+		err = e.artifacts.FetchToWorkspace(ctx, artifacts, workspace)
+	}
+	if err != nil {
+		fmt.Fprintf(logs, "下载依赖产物失败: %v\n", err)
+		return fmt.Errorf("下载依赖产物失败: %w", err)
+	}
+	fmt.Fprintf(logs, "已下载 %d 个依赖产物文件\n", len(artifacts))
+	return nil
+}
+
+// collectArtifacts 按任务的产物声明收集工作空间中的文件
+func (e *localExecutor) collectArtifacts(ctx context.Context, req *ExecutionRequest, task models.Task, workspace, status string, logs io.Writer) error {
+	spec := taskArtifactSpec(&task)
+	taskRunID, ok := req.TaskRunIDs[task.Name]
+	if spec == nil || !ok || e.artifacts == nil || !ArtifactWhenMatches(spec.When, status) {
+		return nil
+	}
+
+	artifacts, err := e.artifacts.CollectWorkspace(ctx, &ArtifactUpload{
+		PipelineID:    req.Pipeline.ID,
+		PipelineRunID: req.Run.ID,
+		TaskRunID:     taskRunID,
+		TaskName:      task.Name,
+		ExpireInDays:  e.artifacts.ExpireDays(req.Pipeline, spec),
+	}, spec, workspace)
+	if err != nil {
+		fmt.Fprintf(logs, "上传产物失败: %v\n", err)
+		return fmt.Errorf("上传产物失败: %w", err)
+	}
+	fmt.Fprintf(logs, "已上传 %d 个产物文件\n", len(artifacts))
+	return nil
+}
+
+// execTask 执行一次任务命令
+func (e *localExecutor) execTask(ctx context.Context, req *ExecutionRequest, task models.Task, workspace string, secretEnv map[string]string, logs io.Writer) localTaskResult {
+	result := localTaskResult{name: task.Name}
+
+	var taskCtx context.Context
+	var cancel context.CancelFunc
+	if task.Timeout > 0 {
+		taskCtx, cancel = context.WithTimeout(ctx, time.Duration(task.Timeout)*time.Second)
+	} else {
+		taskCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	cmd, containerName, err := e.buildCommand(taskCtx, req, task, workspace, secretEnv)
+	if err != nil {
+		msg := err.Error()
+		result.status = "failed"
+		result.message = &msg
+		return result
+	}
+
+	output := &tailBuffer{limit: localOutputTailSize}
+	cmd.Stdout = io.MultiWriter(output, logs)
+	cmd.Stderr = cmd.Stdout
+	cmd.WaitDelay = 10 * time.Second
+
+	runErr := cmd.Run()
+	if containerName != "" && taskCtx.Err() != nil {
+		// 终止docker/podman客户端不会停止容器，需要显式删除
+		e.removeContainer(containerName)
+	}
+
+	if cmd.ProcessState != nil && cmd.ProcessState.ExitCode() >= 0 {
+		exitCode := cmd.ProcessState.ExitCode()
+		result.exitCode = &exitCode
+	}
+
+	var msg string
+	switch {
+	case ctx.Err() != nil:
+		result.status = "cancelled"
+		msg = cancelMessage(ctx)
+	case errors.Is(taskCtx.Err(), context.DeadlineExceeded):
+		result.status = "failed"
+		msg = fmt.Sprintf("任务执行超时(%d秒)", task.Timeout)
+	case runErr != nil:
+		result.status = "failed"
+		msg = fmt.Sprintf("任务执行失败: %v", runErr)
+		if tail := output.String(); tail != "" {
+			msg += "\n" + e.logService.Redact(req.Run.ID, tail)
+		}
+	default:
+		result.status = "succeeded"
+		return result
+	}
+	result.message = &msg
+	return result
+}
+
+// buildCommand 构造任务命令，容器模式下同时返回容器名称。
+// 密钥不出现在命令行参数中：容器模式只传递变量名，值通过客户端进程的环境变量继承
+func (e *localExecutor) buildCommand(ctx context.Context, req *ExecutionRequest, task models.Task, workspace string, secretEnv map[string]string) (*exec.Cmd, string, error) {
+	mountPath := req.Pipeline.Config.Workspace
+	if mountPath == "" {
+		mountPath = localDefaultMountPath
+	}
+	relDir := workingDirRelative(task.WorkingDir, mountPath)
+
+	if e.runtime != "" {
+		if task.Image == "" {
+			return nil, "", fmt.Errorf("任务 %s 未配置镜像", task.Name)
+		}
+
+		containerName := "axiom-" + uuid.New().String()
+		if taskRunID, ok := req.TaskRunIDs[task.Name]; ok {
+			containerName = "axiom-" + taskRunID.String()
+		}
+
+		args := []string{"run", "--rm", "--name", containerName,
+			"-v", workspace + ":" + mountPath,
+			"-w", path.Join(mountPath, relDir),
+		}
+		for _, kv := range e.taskEnv(req, task, mountPath, secretEnv) {
+			args = append(args, "-e", kv)
+		}
+		secretNames := sortedKeys(secretEnv)
+		for _, name := range secretNames {
+			args = append(args, "-e", name)
+		}
+		if len(task.Command) > 0 {
+			args = append(args, "--entrypoint", task.Command[0])
+		}
+		args = append(args, task.Image)
+		if len(task.Command) > 1 {
+			args = append(args, task.Command[1:]...)
+		}
+		args = append(args, task.Args...)
+
+		cmd := exec.CommandContext(ctx, e.runtime, args...)
+		if len(secretNames) > 0 {
+			cmd.Env = os.Environ()
+			for _, name := range secretNames {
+				cmd.Env = append(cmd.Env, name+"="+secretEnv[name])
+			}
+		}
+		return cmd, containerName, nil
+	}
+
+	if len(task.Command) == 0 {
+		return nil, "", fmt.Errorf("任务 %s 未配置命令，进程模式无法使用镜像默认入口", task.Name)
+	}
+
+	dir := filepath.Join(workspace, filepath.FromSlash(relDir))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, "", fmt.Errorf("创建工作目录失败: %w", err)
+	}
+
+	args := append(append([]string{}, task.Command[1:]...), task.Args...)
+	cmd := exec.CommandContext(ctx, task.Command[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append([]string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + workspace,
+	}, e.taskEnv(req, task, workspace, secretEnv)...)
+	for _, name := range sortedKeys(secretEnv) {
+		cmd.Env = append(cmd.Env, name+"="+secretEnv[name])
+	}
+	setProcessGroup(cmd)
+
+	return cmd, "", nil
+}
+
+// removeContainer 强制删除任务容器
+func (e *localExecutor) removeContainer(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := exec.CommandContext(ctx, e.runtime, "rm", "-f", name).Run(); err != nil {
+		log.Printf("⚠️ 删除任务容器失败 %s: %v", name, err)
+	}
+}
+
+// taskEnv 构造本地任务环境变量列表，exclude 中的变量（如密钥）由调用方单独注入
+func (e *localExecutor) taskEnv(req *ExecutionRequest, task models.Task, workspace string, exclude map[string]string) []string {
+	env := taskEnvironment(req, task)
+	env["AXIOM_WORKSPACE"] = workspace
+	for key := range exclude {
+		delete(env, key)
+	}
+
+	keys := sortedKeys(env)
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, key+"="+env[key])
+	}
+	return result
+}
+
+// sortedKeys 返回排序后的键列表
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// reportTask 回写任务运行状态
+func (e *localExecutor) reportTask(req *ExecutionRequest, name string, update *TaskRunStatusUpdate) {
+	taskRunID, ok := req.TaskRunIDs[name]
+	if !ok {
+		return
+	}
+	if err := req.Reporter.UpdateTaskRunStatus(taskRunID, update); err != nil {
+		log.Printf("⚠️ 回写任务运行状态失败 %s: %v", taskRunID, err)
+	}
+}
+
+// workingDirRelative 将任务工作目录换算为相对工作空间的路径，
+// 绝对路径按容器内路径处理（去掉挂载前缀），并消除 ".." 避免逃逸出工作空间
+func workingDirRelative(workingDir *string, mountPath string) string {
+	if workingDir == nil || *workingDir == "" {
+		return "."
+	}
+
+	cleaned := path.Clean("/" + *workingDir)
+	if strings.HasPrefix(*workingDir, "/") {
+		mount := path.Clean("/" + mountPath)
+		if cleaned == mount {
+			return "."
+		}
+		if strings.HasPrefix(cleaned, mount+"/") {
+			cleaned = strings.TrimPrefix(cleaned, mount)
+		}
+	}
+	return strings.TrimPrefix(cleaned, "/")
+}
+
+// tailBuffer 只保留最后 limit 字节的输出缓冲区
+type tailBuffer struct {
+	mu    sync.Mutex
+	limit int
+	data  []byte
+}
+
+// Write 写入输出，超出部分丢弃最早的数据
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.data = append(b.data, p...)
+	if len(b.data) > b.limit {
+		b.data = append([]byte(nil), b.data[len(b.data)-b.limit:]...)
+	}
+	return len(p), nil
+}
+
+// String 返回缓冲区内容
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.TrimSpace(string(b.data))
+}
+
+// nopWriteCloser 为 io.Writer 补充空的 Close 方法
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
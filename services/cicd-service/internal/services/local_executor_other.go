@@ -0,0 +1,8 @@
+//go:build !unix
+
+package services
+
+import "os/exec"
+
+// setProcessGroup 非Unix平台不支持进程组，终止时只结束任务主进程
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"cicd-service/internal/config"
+	"cicd-service/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// logMaskCacheTTL 运行日志掩码的刷新间隔
+	logMaskCacheTTL = 30 * time.Second
+	// logMaskCacheIdle 运行日志掩码闲置超过该时间后从缓存移除
+	logMaskCacheIdle = time.Hour
+	// logMaskMaxPatterns 单个项目的掩码规则数量上限
+	logMaskMaxPatterns = 100
+)
+
+// ErrLogMaskPatternNotFound 掩码规则不存在
+var ErrLogMaskPatternNotFound = errors.New("掩码规则不存在")
+
+// LogMaskService 日志脱敏服务：管理项目掩码规则，并为运行构建日志掩码
+type LogMaskService interface {
+	CreatePattern(tenantID uuid.UUID, req *CreateLogMaskPatternRequest) (*models.LogMaskPattern, error)
+	ListPatterns(tenantID, projectID uuid.UUID) ([]models.LogMaskPattern, error)
+	DeletePattern(tenantID, projectID, id uuid.UUID) error
+
+	// Masker 获取运行的日志掩码（项目全部密钥值和掩码规则），无需脱敏时返回nil。
+	// refresh 为 true 时立即重新读取密钥，任务开始写日志时使用，保证任务拿到的密钥值都在掩码中
+	Masker(runID uuid.UUID, refresh bool) (*LogMasker, error)
+}
+
+type logMaskService struct {
+	db      *gorm.DB
+	config  *config.Config
+	secrets SecretService
+
+	mu    sync.Mutex
+	cache map[uuid.UUID]*cachedLogMasker
+}
+
+// cachedLogMasker 缓存的运行日志掩码。刷新时密钥值只增不减：
+// 运行中更新的密钥，旧值可能已注入正在执行的任务，在运行期间继续替换
+type cachedLogMasker struct {
+	values    map[string]struct{}
+	masker    *LogMasker
+	expiresAt time.Time
+	usedAt    time.Time
+}
+
+// NewLogMaskService 创建日志脱敏服务实例
+func NewLogMaskService(db *gorm.DB, cfg *config.Config, secretService SecretService) LogMaskService {
+	return &logMaskService{
+		db:      db,
+		config:  cfg,
+		secrets: secretService,
+		cache:   make(map[uuid.UUID]*cachedLogMasker),
+	}
+}
+
+// CreateLogMaskPatternRequest 创建掩码规则请求
+type CreateLogMaskPatternRequest struct {
+	ProjectID   uuid.UUID `json:"-"`
+	Pattern     string    `json:"pattern" binding:"required,max=1024"`
+	Description *string   `json:"description"`
+	CreatedBy   uuid.UUID `json:"-"`
+}
+
+// CreatePattern 创建掩码规则
+func (s *logMaskService) CreatePattern(tenantID uuid.UUID, req *CreateLogMaskPatternRequest) (*models.LogMaskPattern, error) {
+	if err := checkProjectTenant(s.db, tenantID, req.ProjectID); err != nil {
+		return nil, err
+	}
+	if err := ValidateLogMaskPattern(req.Pattern); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Model(&models.LogMaskPattern{}).Where("project_id = ?", req.ProjectID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("统计掩码规则失败: %w", err)
+	}
+	if count >= logMaskMaxPatterns {
+		return nil, fmt.Errorf("每个项目最多 %d 条掩码规则", logMaskMaxPatterns)
+	}
+
+	pattern := &models.LogMaskPattern{
+		ID:          uuid.New(),
+		ProjectID:   req.ProjectID,
+		Pattern:     req.Pattern,
+		Description: req.Description,
+		CreatedBy:   req.CreatedBy,
+	}
+	if err := s.db.Create(pattern).Error; err != nil {
+		return nil, fmt.Errorf("创建掩码规则失败: %w", err)
+	}
+	s.invalidate()
+	return pattern, nil
+}
+
+// ListPatterns 获取项目的掩码规则
+func (s *logMaskService) ListPatterns(tenantID, projectID uuid.UUID) ([]models.LogMaskPattern, error) {
+	if err := checkProjectTenant(s.db, tenantID, projectID); err != nil {
+		return nil, err
+	}
+
+	var patterns []models.LogMaskPattern
+	if err := s.db.Where("project_id = ?", projectID).Order("created_at ASC").Find(&patterns).Error; err != nil {
+		return nil, fmt.Errorf("获取掩码规则失败: %w", err)
+	}
+	return patterns, nil
+}
+
+// DeletePattern 删除掩码规则
+func (s *logMaskService) DeletePattern(tenantID, projectID, id uuid.UUID) error {
+	if err := checkProjectTenant(s.db, tenantID, projectID); err != nil {
+		return err
+	}
+
+	result := s.db.Where("id = ? AND project_id = ?", id, projectID).Delete(&models.LogMaskPattern{})
+	if result.Error != nil {
+		return fmt.Errorf("删除掩码规则失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrLogMaskPatternNotFound
+	}
+	s.invalidate()
+	return nil
+}
+
+// Masker 获取运行的日志掩码，结果按运行缓存，每 logMaskCacheTTL 刷新
+func (s *logMaskService) Masker(runID uuid.UUID, refresh bool) (*LogMasker, error) {
+	now := time.Now()
+	s.mu.Lock()
+	cached, ok := s.cache[runID]
+	if ok {
+		cached.usedAt = now
+		if !refresh && now.Before(cached.expiresAt) {
+			s.mu.Unlock()
+			return cached.masker, nil
+		}
+	}
+	s.mu.Unlock()
+
+	var run struct {
+		ProjectID uuid.UUID
+	}
+	if err := s.db.Table("pipeline_runs").
+		Select("pipelines.project_id").
+		Joins("JOIN pipelines ON pipelines.id = pipeline_runs.pipeline_id").
+		Where("pipeline_runs.id = ?", runID).
+		Scan(&run).Error; err != nil {
+		return nil, fmt.Errorf("获取运行所属项目失败: %w", err)
+	}
+	if run.ProjectID == uuid.Nil {
+		return nil, nil
+	}
+	projectID := run.ProjectID
+
+	values, err := s.secrets.ProjectValues(context.Background(), projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []models.LogMaskPattern
+	if err := s.db.Where("project_id = ?", projectID).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("获取掩码规则失败: %w", err)
+	}
+	patterns := make([]*regexp.Regexp, 0, len(rows))
+	for _, row := range rows {
+		// 创建时已校验，无法编译的规则（如手工写入）跳过
+		if re, err := regexp.Compile(row.Pattern); err == nil {
+			patterns = append(patterns, re)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, entry := range s.cache {
+		if now.Sub(entry.usedAt) > logMaskCacheIdle {
+			delete(s.cache, id)
+		}
+	}
+
+	entry, ok := s.cache[runID]
+	if !ok {
+		entry = &cachedLogMasker{values: make(map[string]struct{})}
+		s.cache[runID] = entry
+	}
+	for _, value := range values {
+		entry.values[value] = struct{}{}
+	}
+	merged := make([]string, 0, len(entry.values))
+	for value := range entry.values {
+		merged = append(merged, value)
+	}
+	entry.masker = NewLogMasker(merged, patterns)
+	entry.expiresAt = now.Add(logMaskCacheTTL)
+	entry.usedAt = now
+	return entry.masker, nil
+}
+
+// invalidate 掩码规则变更后让本实例的缓存在下次使用时刷新
+func (s *logMaskService) invalidate() {
+	s.mu.Lock()
+	for _, entry := range s.cache {
+		entry.expiresAt = time.Time{}
+	}
+	s.mu.Unlock()
+}
+
+// ValidateLogMaskPattern 校验掩码规则：必须是可编译的RE2正则，且不能匹配空字符串
+func ValidateLogMaskPattern(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("掩码规则不是有效的正则表达式: %w", err)
+	}
+	if re.MatchString("") {
+		return fmt.Errorf("掩码规则不能匹配空字符串")
+	}
+	return nil
+}
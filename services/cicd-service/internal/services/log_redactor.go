@@ -0,0 +1,184 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// logMaskReplacement 脱敏后的替换内容
+	logMaskReplacement = "***"
+	// logMaskMinLength 参与脱敏的最短值，过短的值替换后会破坏正常日志
+	logMaskMinLength = 4
+	// logRedactMaxLine 未结束的行超过该长度时不再等待换行
+	logRedactMaxLine = 64 * 1024
+)
+
+// LogMasker 日志掩码：替换密钥值及其base64、URL编码形式，并应用项目的掩码规则
+type LogMasker struct {
+	values   []string // 按长度降序
+	replacer *strings.Replacer
+	patterns []*regexp.Regexp
+	maxLen   int
+}
+
+// NewLogMasker 创建日志掩码，没有需要替换的内容时返回nil
+func NewLogMasker(secrets []string, patterns []*regexp.Regexp) *LogMasker {
+	set := make(map[string]struct{})
+	add := func(value string) {
+		if len(value) >= logMaskMinLength {
+			set[value] = struct{}{}
+		}
+	}
+	for _, secret := range secrets {
+		candidates := []string{secret}
+		// 多行值（如私钥）在日志中通常逐行出现，每行单独替换
+		if strings.ContainsAny(secret, "\r\n") {
+			for _, line := range strings.FieldsFunc(secret, func(r rune) bool { return r == '\n' || r == '\r' }) {
+				candidates = append(candidates, strings.TrimSpace(line))
+			}
+		}
+		for _, candidate := range candidates {
+			add(candidate)
+			add(url.QueryEscape(candidate))
+			add(url.PathEscape(candidate))
+			for _, encoded := range base64Fragments(base64.RawStdEncoding, []byte(candidate)) {
+				add(encoded)
+			}
+			for _, encoded := range base64Fragments(base64.RawURLEncoding, []byte(candidate)) {
+				add(encoded)
+			}
+		}
+	}
+	if len(set) == 0 && len(patterns) == 0 {
+		return nil
+	}
+
+	m := &LogMasker{patterns: patterns}
+	for value := range set {
+		m.values = append(m.values, value)
+	}
+	// 同一位置优先替换较长的值
+	sort.Slice(m.values, func(i, j int) bool {
+		if len(m.values[i]) != len(m.values[j]) {
+			return len(m.values[i]) > len(m.values[j])
+		}
+		return m.values[i] < m.values[j]
+	})
+	if len(m.values) > 0 {
+		m.maxLen = len(m.values[0])
+		pairs := make([]string, 0, 2*len(m.values))
+		for _, value := range m.values {
+			pairs = append(pairs, value, logMaskReplacement)
+		}
+		m.replacer = strings.NewReplacer(pairs...)
+	}
+	return m
+}
+
+// base64Fragments 返回值在base64编码流中任意对齐位置都会出现的编码片段，
+// 覆盖单独编码和作为更长内容一部分编码（如 user:password）两种情况
+func base64Fragments(encoding *base64.Encoding, value []byte) []string {
+	fragments := make([]string, 0, 3)
+	for shift := 0; shift < 3; shift++ {
+		buf := make([]byte, shift+len(value))
+		copy(buf[shift:], value)
+		encoded := encoding.EncodeToString(buf)
+		// 只保留完全由值决定的字符
+		start := (8*shift + 5) / 6
+		end := 8 * (shift + len(value)) / 6
+		if end > start {
+			fragments = append(fragments, encoded[start:end])
+		}
+	}
+	return fragments
+}
+
+// Mask 替换内容中的密钥值和掩码规则匹配的内容，m 为nil时原样返回
+func (m *LogMasker) Mask(data []byte) []byte {
+	if m == nil || len(data) == 0 {
+		return data
+	}
+	if m.replacer != nil {
+		data = []byte(m.replacer.Replace(string(data)))
+	}
+	for _, pattern := range m.patterns {
+		data = pattern.ReplaceAll(data, []byte(logMaskReplacement))
+	}
+	return data
+}
+
+// MaskString 替换字符串中的敏感内容
+func (m *LogMasker) MaskString(text string) string {
+	if m == nil {
+		return text
+	}
+	return string(m.Mask([]byte(text)))
+}
+
+// safeCut 返回可以立即脱敏输出的前缀长度：末尾可能是密钥值开头的部分和跨越切分点的密钥值都留到后续处理
+func (m *LogMasker) safeCut(data []byte) int {
+	if m == nil || m.maxLen == 0 {
+		return len(data)
+	}
+	cut := len(data) - (m.maxLen - 1)
+	for changed := true; changed && cut > 0; {
+		changed = false
+		for _, value := range m.values {
+			start := max(cut-len(value)+1, 0)
+			for start < cut {
+				i := bytes.Index(data[start:], []byte(value))
+				if i < 0 || start+i >= cut {
+					break
+				}
+				if start+i+len(value) > cut {
+					cut = start + i
+					changed = true
+					break
+				}
+				start += i + 1
+			}
+		}
+	}
+	return max(cut, 0)
+}
+
+// logRedactor 流式日志脱敏。完整的行（以 \n 或 \r 结尾）立即脱敏输出；
+// 未结束的行只在超过 logRedactMaxLine 或定时刷新时输出不可能截断密钥值的前缀，
+// 因此跨写入分块的密钥值同样会被替换。掩码规则按输出块匹配，跨越强制切分点的内容不保证匹配
+type logRedactor struct {
+	masker  *LogMasker
+	pending []byte
+}
+
+// Write 追加输出，返回可以写出的脱敏内容
+func (r *logRedactor) Write(p []byte) []byte {
+	r.pending = append(r.pending, p...)
+	end := bytes.LastIndexAny(r.pending, "\r\n") + 1
+	if len(r.pending)-end > logRedactMaxLine {
+		return r.emit(r.masker.safeCut(r.pending))
+	}
+	return r.emit(end)
+}
+
+// Flush 输出未结束的行，final 为 false 时保留可能是密钥值开头的末尾部分
+func (r *logRedactor) Flush(final bool) []byte {
+	if final {
+		return r.emit(len(r.pending))
+	}
+	return r.emit(r.masker.safeCut(r.pending))
+}
+
+// emit 脱敏输出前 n 个字节
+func (r *logRedactor) emit(n int) []byte {
+	if n <= 0 {
+		return nil
+	}
+	out := r.masker.Mask(bytes.Clone(r.pending[:n]))
+	r.pending = append(r.pending[:0], r.pending[n:]...)
+	return out
+}
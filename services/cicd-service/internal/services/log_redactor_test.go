@@ -0,0 +1,130 @@
+package services
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestBase64Fragments(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding *base64.Encoding
+		value    string
+	}{
+		{"std password", base64.RawStdEncoding, "s3cr3t-password"},
+		{"std short", base64.RawStdEncoding, "abcd"},
+		{"url binary", base64.RawURLEncoding, "\xff\xfe\xfd\xfc\xfb\xfa"},
+	}
+
+	// 值出现在编码内容的任意对齐位置（前缀长度0~2字节）以及前后带有其他内容时，至少一个片段出现在编码结果中
+	prefixes := []string{"", "u", "us", "user:", "Basic:x"}
+	suffixes := []string{"", "@host", "\n"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fragments := base64Fragments(tt.encoding, []byte(tt.value))
+			if len(fragments) != 3 {
+				t.Fatalf("got %d fragments, want 3", len(fragments))
+			}
+			for _, prefix := range prefixes {
+				for _, suffix := range suffixes {
+					encoded := tt.encoding.EncodeToString([]byte(prefix + tt.value + suffix))
+					found := false
+					for _, fragment := range fragments {
+						if strings.Contains(encoded, fragment) {
+							found = true
+							break
+						}
+					}
+					if !found {
+						t.Errorf("no fragment of %q found in encoding of %q", tt.value, prefix+tt.value+suffix)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBase64FragmentsTooShort(t *testing.T) {
+	// 单字节值在某些对齐位置没有完全由值决定的字符
+	fragments := base64Fragments(base64.RawStdEncoding, []byte("x"))
+	for _, fragment := range fragments {
+		if fragment == "" {
+			t.Fatal("empty fragment returned")
+		}
+	}
+	if len(fragments) >= 3 {
+		t.Fatalf("got %d fragments for a single byte, want fewer than 3", len(fragments))
+	}
+}
+
+func TestSafeCut(t *testing.T) {
+	masker := NewLogMasker([]string{"hunter22"}, nil)
+	tail := masker.maxLen - 1
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"no secret", strings.Repeat("a", 40)},
+		{"partial secret at end", strings.Repeat("a", 40) + "hunt"},
+		{"secret before tail", "token=hunter22 " + strings.Repeat("a", 40)},
+		{"secret across cut", strings.Repeat("a", 30) + "hunter22" + strings.Repeat("b", tail-4)},
+		{"shorter than tail", "hunt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := []byte(tt.data)
+			cut := masker.safeCut(data)
+			if cut < 0 || cut > len(data) {
+				t.Fatalf("cut %d out of range [0, %d]", cut, len(data))
+			}
+			if cut > len(data)-tail && len(data) > tail {
+				t.Fatalf("cut %d keeps fewer than %d trailing bytes", cut, tail)
+			}
+			for _, value := range masker.values {
+				for start := 0; start < cut; start++ {
+					if strings.HasPrefix(tt.data[start:], value) && start+len(value) > cut {
+						t.Fatalf("value %q at %d crosses cut %d", value, start, cut)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSafeCutNilMasker(t *testing.T) {
+	var masker *LogMasker
+	if got := masker.safeCut([]byte("hunter22")); got != 8 {
+		t.Fatalf("safeCut on nil masker = %d, want 8", got)
+	}
+}
+
+func TestLogRedactorChunkBoundaries(t *testing.T) {
+	secret := "hunter22"
+	encoded := base64.StdEncoding.EncodeToString([]byte("admin:" + secret))
+	input := "login with " + secret + " ok\nAuthorization: Basic " + encoded + "\nprogress " + secret + strings.Repeat(".", 50)
+
+	masker := NewLogMasker([]string{secret}, nil)
+	want := masker.MaskString(input)
+	if strings.Contains(want, secret) {
+		t.Fatalf("whole-input masking leaked the secret: %q", want)
+	}
+
+	// 任意分块大小并在每块后定时刷新，输出与整体脱敏一致，且不会泄露密钥
+	for _, chunk := range []int{1, 2, 3, 5, 7, 13, 64} {
+		r := &logRedactor{masker: masker}
+		var out strings.Builder
+		for i := 0; i < len(input); i += chunk {
+			out.Write(r.Write([]byte(input[i:min(i+chunk, len(input))])))
+			out.Write(r.Flush(false))
+		}
+		out.Write(r.Flush(true))
+
+		if got := out.String(); got != want {
+			t.Errorf("chunk %d: got %q, want %q", chunk, got, want)
+		}
+	}
+}
@@ -0,0 +1,674 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"cicd-service/internal/config"
+	"cicd-service/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// logChunkReadSize 实时跟踪时单次推送的最大日志长度
+	logChunkReadSize = 64 * 1024
+	// logWriterFlushSize 执行器日志写入缓冲达到该长度时立即写入一个分块
+	logWriterFlushSize = 64 * 1024
+	// logWriterFlushInterval 执行器日志写入缓冲的最长停留时间
+	logWriterFlushInterval = time.Second
+	// logFollowPollInterval 实时跟踪轮询间隔，用于感知其他实例写入的日志和任务结束
+	logFollowPollInterval = 2 * time.Second
+	// logFollowHeartbeatInterval 无新日志时推送心跳的间隔
+	logFollowHeartbeatInterval = 15 * time.Second
+	// logLockStripes 日志写入锁分片数
+	logLockStripes = 64
+)
+
+// ErrLogOffsetMismatch 日志写入偏移量与已接收长度不一致
+var ErrLogOffsetMismatch = errors.New("日志偏移量不匹配")
+
+// LogService 任务运行日志服务。
+// 日志按追加顺序切分为分块写入对象存储，分块以起始偏移量命名：
+// logs/<运行ID>/<任务运行ID>/<起始偏移量>.log，读取时可以从任意偏移量续读。
+// 设置日志脱敏服务后，日志在写入存储前替换密钥值和项目掩码规则匹配的内容，偏移量均指脱敏后的日志。
+type LogService interface {
+	// SetMaskService 设置日志脱敏服务
+	SetMaskService(maskService LogMaskService)
+	// Append 在 offset 处追加日志，offset 为 -1 时追加到末尾，返回追加后的日志长度。
+	// 每次追加的内容单独脱敏，调用方应按整行提交
+	Append(runID, taskRunID uuid.UUID, offset int64, data []byte) (int64, error)
+	// NewWriter 创建带缓冲的日志写入器，供执行器捕获任务输出，跨写入的密钥值同样会被替换
+	NewWriter(runID, taskRunID uuid.UUID) io.WriteCloser
+	// Redact 替换文本中的敏感内容，用于任务错误信息等日志以外的输出
+	Redact(runID uuid.UUID, text string) string
+	// Size 获取当前日志长度
+	Size(runID, taskRunID uuid.UUID) (int64, error)
+	// Open 从 offset 开始读取日志
+	Open(ctx context.Context, runID, taskRunID uuid.UUID, offset int64) (io.ReadCloser, error)
+	// Reset 清空任务日志（如作业重新分配后从头执行）
+	Reset(runID, taskRunID uuid.UUID) error
+	// Follow 从 offset 开始持续推送日志，任务结束且日志全部推送后返回任务的最终状态
+	Follow(ctx context.Context, runID, taskRunID uuid.UUID, offset int64, emit func(*LogChunk) error) (string, error)
+	// GetTaskRun 获取租户下的任务运行
+	GetTaskRun(tenantID, runID, taskRunID uuid.UUID) (*models.TaskRun, error)
+	// GetPipelineRun 获取租户下的流水线运行及其任务运行
+	GetPipelineRun(tenantID, runID uuid.UUID) (*models.PipelineRun, error)
+	// CleanupExpired 清理超过保留天数的运行日志，返回清理的运行数
+	CleanupExpired() (int, error)
+}
+
+// LogChunk 实时推送的一段日志
+type LogChunk struct {
+	Offset     int64  `json:"offset"`          // 本段日志的起始偏移量
+	NextOffset int64  `json:"next_offset"`     // 续读偏移量
+	Content    string `json:"content"`         // 日志内容，为空时表示心跳
+	Reset      bool   `json:"reset,omitempty"` // 日志已被清空（作业重新分配），客户端应丢弃已显示的内容
+}
+
+type logService struct {
+	db      *gorm.DB
+	config  *config.Config
+	storage ObjectStorage
+	masks   LogMaskService
+
+	locks [logLockStripes]sync.Mutex // 按任务运行ID分片的写入锁，保证偏移量检查与写入的原子性
+
+	mu          sync.Mutex
+	subscribers map[uuid.UUID]map[chan struct{}]struct{}
+}
+
+// NewLogService 创建日志服务实例
+func NewLogService(db *gorm.DB, cfg *config.Config, storage ObjectStorage) LogService {
+	return &logService{
+		db:          db,
+		config:      cfg,
+		storage:     storage,
+		subscribers: make(map[uuid.UUID]map[chan struct{}]struct{}),
+	}
+}
+
+// SetMaskService 设置日志脱敏服务
+func (s *logService) SetMaskService(maskService LogMaskService) {
+	s.masks = maskService
+}
+
+// masker 获取运行的日志掩码，未设置脱敏服务时返回nil
+func (s *logService) masker(runID uuid.UUID, refresh bool) (*LogMasker, error) {
+	if s.masks == nil {
+		return nil, nil
+	}
+	masker, err := s.masks.Masker(runID, refresh)
+	if err != nil {
+		return nil, fmt.Errorf("获取日志掩码失败: %w", err)
+	}
+	return masker, nil
+}
+
+// Redact 替换文本中的敏感内容，无法获取掩码时隐藏全部内容
+func (s *logService) Redact(runID uuid.UUID, text string) string {
+	if text == "" {
+		return text
+	}
+	masker, err := s.masker(runID, false)
+	if err != nil {
+		log.Printf("⚠️ %v", err)
+		return "[日志脱敏不可用，内容已隐藏]"
+	}
+	return masker.MaskString(text)
+}
+
+// runLogPrefix 运行日志前缀
+func runLogPrefix(runID uuid.UUID) string {
+	return "logs/" + runID.String() + "/"
+}
+
+// taskLogPrefix 任务运行日志前缀
+func taskLogPrefix(runID, taskRunID uuid.UUID) string {
+	return runLogPrefix(runID) + taskRunID.String() + "/"
+}
+
+// logSegment 日志分块
+type logSegment struct {
+	key    string
+	offset int64
+	size   int64
+}
+
+// lockFor 获取任务运行对应的写入锁
+func (s *logService) lockFor(taskRunID uuid.UUID) *sync.Mutex {
+	return &s.locks[int(taskRunID[len(taskRunID)-1])%logLockStripes]
+}
+
+// segments 列出任务日志分块，按起始偏移量排序，返回日志总长度
+func (s *logService) segments(ctx context.Context, runID, taskRunID uuid.UUID) ([]logSegment, int64, error) {
+	objects, err := s.storage.List(ctx, taskLogPrefix(runID, taskRunID))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	segments := make([]logSegment, 0, len(objects))
+	var size int64
+	for _, object := range objects {
+		offset, err := strconv.ParseInt(strings.TrimSuffix(path.Base(object.Key), ".log"), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, logSegment{key: object.Key, offset: offset, size: object.Size})
+		size = offset + object.Size
+	}
+	return segments, size, nil
+}
+
+// Append 脱敏后追加日志分块，任务日志的第一个分块会刷新运行的掩码
+func (s *logService) Append(runID, taskRunID uuid.UUID, offset int64, data []byte) (int64, error) {
+	masker, err := s.masker(runID, offset == 0)
+	if err != nil {
+		return 0, err
+	}
+	return s.append(runID, taskRunID, offset, masker.Mask(data))
+}
+
+// append 追加已脱敏的日志分块
+func (s *logService) append(runID, taskRunID uuid.UUID, offset int64, data []byte) (int64, error) {
+	lock := s.lockFor(taskRunID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	ctx := context.Background()
+	_, size, err := s.segments(ctx, runID, taskRunID)
+	if err != nil {
+		return 0, fmt.Errorf("读取日志失败: %w", err)
+	}
+	if offset >= 0 && offset != size {
+		return size, ErrLogOffsetMismatch
+	}
+	if len(data) == 0 {
+		return size, nil
+	}
+
+	key := fmt.Sprintf("%s%020d.log", taskLogPrefix(runID, taskRunID), size)
+	if _, err := s.storage.Put(ctx, key, bytes.NewReader(data)); err != nil {
+		return size, fmt.Errorf("写入日志失败: %w", err)
+	}
+
+	if size == 0 {
+		s.recordLogsPath(runID, taskRunID)
+	}
+	s.notify(taskRunID)
+	return size + int64(len(data)), nil
+}
+
+// recordLogsPath 在首次写入日志时记录运行和任务运行的日志存储路径
+func (s *logService) recordLogsPath(runID, taskRunID uuid.UUID) {
+	if err := s.db.Model(&models.TaskRun{}).
+		Where("id = ? AND logs_path IS NULL", taskRunID).
+		Update("logs_path", taskLogPrefix(runID, taskRunID)).Error; err != nil {
+		log.Printf("⚠️ 记录任务日志路径失败 %s: %v", taskRunID, err)
+	}
+	if err := s.db.Model(&models.PipelineRun{}).
+		Where("id = ? AND logs_path IS NULL", runID).
+		Update("logs_path", runLogPrefix(runID)).Error; err != nil {
+		log.Printf("⚠️ 记录运行日志路径失败 %s: %v", runID, err)
+	}
+}
+
+// Size 获取当前日志长度
+func (s *logService) Size(runID, taskRunID uuid.UUID) (int64, error) {
+	_, size, err := s.segments(context.Background(), runID, taskRunID)
+	if err != nil {
+		return 0, fmt.Errorf("读取日志失败: %w", err)
+	}
+	return size, nil
+}
+
+// Open 从 offset 开始读取日志，offset 超过日志长度时返回空内容
+func (s *logService) Open(ctx context.Context, runID, taskRunID uuid.UUID, offset int64) (io.ReadCloser, error) {
+	segments, _, err := s.segments(ctx, runID, taskRunID)
+	if err != nil {
+		return nil, fmt.Errorf("读取日志失败: %w", err)
+	}
+
+	reader := &segmentReader{ctx: ctx, storage: s.storage}
+	for _, segment := range segments {
+		if segment.offset+segment.size <= offset {
+			continue
+		}
+		if segment.offset < offset {
+			reader.skip = offset - segment.offset
+		}
+		reader.segments = append(reader.segments, segment)
+	}
+	return reader, nil
+}
+
+// Reset 删除任务的全部日志分块
+func (s *logService) Reset(runID, taskRunID uuid.UUID) error {
+	lock := s.lockFor(taskRunID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := s.storage.DeletePrefix(context.Background(), taskLogPrefix(runID, taskRunID)); err != nil {
+		return fmt.Errorf("清空日志失败: %w", err)
+	}
+	s.notify(taskRunID)
+	return nil
+}
+
+// Follow 持续推送日志。推送按UTF-8字符边界切分；
+// 日志长度小于 offset 时说明日志已被清空，推送 Reset 后从头开始
+func (s *logService) Follow(ctx context.Context, runID, taskRunID uuid.UUID, offset int64, emit func(*LogChunk) error) (string, error) {
+	updates := s.subscribe(taskRunID)
+	defer s.unsubscribe(taskRunID, updates)
+
+	poll := time.NewTicker(logFollowPollInterval)
+	defer poll.Stop()
+	lastEmit := time.Now()
+
+	for {
+		// 先确认任务是否结束，再读取日志，保证结束前写入的日志都会被推送
+		status, err := s.taskRunStatus(taskRunID)
+		if err != nil {
+			return "", err
+		}
+		finished := IsTaskRunFinished(status)
+
+		size, err := s.Size(runID, taskRunID)
+		if err != nil {
+			return "", err
+		}
+		if offset > size {
+			offset = 0
+			if err := emit(&LogChunk{Reset: true}); err != nil {
+				return "", err
+			}
+			lastEmit = time.Now()
+		}
+
+		if offset < size {
+			sent, err := s.emitRange(ctx, runID, taskRunID, offset, size, finished, emit)
+			if err != nil {
+				return "", err
+			}
+			if sent > offset {
+				offset = sent
+				lastEmit = time.Now()
+			}
+		}
+
+		if finished {
+			return status, nil
+		}
+
+		if time.Since(lastEmit) >= logFollowHeartbeatInterval {
+			if err := emit(&LogChunk{Offset: offset, NextOffset: offset}); err != nil {
+				return "", err
+			}
+			lastEmit = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-updates:
+		case <-poll.C:
+		}
+	}
+}
+
+// emitRange 推送 [offset, size) 范围内的日志，返回推送到的偏移量。
+// 末尾不完整的UTF-8字符留到下次推送，任务已结束时原样推送
+func (s *logService) emitRange(ctx context.Context, runID, taskRunID uuid.UUID, offset, size int64, finished bool, emit func(*LogChunk) error) (int64, error) {
+	reader, err := s.Open(ctx, runID, taskRunID, offset)
+	if err != nil {
+		return offset, err
+	}
+	defer reader.Close()
+
+	buf := make([]byte, logChunkReadSize)
+	pending := 0
+	for offset < size {
+		limit := int64(len(buf) - pending)
+		if remaining := size - offset - int64(pending); remaining < limit {
+			limit = remaining
+		}
+		n, err := io.ReadFull(reader, buf[pending:pending+int(limit)])
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			return offset, fmt.Errorf("读取日志失败: %w", err)
+		}
+		data := buf[:pending+n]
+		if n == 0 {
+			// 分块在读取期间被清空，等待下一轮处理
+			return offset, nil
+		}
+
+		last := offset+int64(len(data)) >= size
+		cut := len(data)
+		if !(last && finished) {
+			cut = completeUTF8Length(data)
+		}
+		if cut == 0 {
+			if last {
+				return offset, nil
+			}
+			cut = len(data)
+		}
+
+		if err := emit(&LogChunk{
+			Offset:     offset,
+			NextOffset: offset + int64(cut),
+			Content:    string(data[:cut]),
+		}); err != nil {
+			return offset, err
+		}
+		offset += int64(cut)
+		pending = copy(buf, data[cut:])
+		if last && pending > 0 {
+			return offset, nil
+		}
+	}
+	return offset, nil
+}
+
+// completeUTF8Length 返回不以不完整UTF-8字符结尾的最长前缀长度
+func completeUTF8Length(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if !utf8.RuneStart(data[i]) {
+			continue
+		}
+		if !utf8.FullRune(data[i:]) {
+			return i
+		}
+		break
+	}
+	return len(data)
+}
+
+// taskRunStatus 获取任务运行的最新状态
+func (s *logService) taskRunStatus(taskRunID uuid.UUID) (string, error) {
+	var taskRun models.TaskRun
+	if err := s.db.Select("id", "status").Where("id = ?", taskRunID).First(&taskRun).Error; err != nil {
+		return "", fmt.Errorf("获取任务运行失败: %w", err)
+	}
+	return taskRun.Status, nil
+}
+
+// IsTaskRunFinished 任务运行状态是否为结束状态
+func IsTaskRunFinished(status string) bool {
+	switch status {
+	case "succeeded", "failed", "cancelled", "skipped":
+		return true
+	}
+	return false
+}
+
+// subscribe 订阅任务日志更新通知
+func (s *logService) subscribe(taskRunID uuid.UUID) chan struct{} {
+	ch := make(chan struct{}, 1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subscribers[taskRunID] == nil {
+		s.subscribers[taskRunID] = make(map[chan struct{}]struct{})
+	}
+	s.subscribers[taskRunID][ch] = struct{}{}
+	return ch
+}
+
+// unsubscribe 取消订阅
+func (s *logService) unsubscribe(taskRunID uuid.UUID, ch chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subscribers[taskRunID], ch)
+	if len(s.subscribers[taskRunID]) == 0 {
+		delete(s.subscribers, taskRunID)
+	}
+}
+
+// notify 通知订阅者有新日志
+func (s *logService) notify(taskRunID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers[taskRunID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// GetTaskRun 获取租户下的任务运行
+func (s *logService) GetTaskRun(tenantID, runID, taskRunID uuid.UUID) (*models.TaskRun, error) {
+	var taskRun models.TaskRun
+	if err := s.db.Model(&models.TaskRun{}).
+		Joins("JOIN pipeline_runs ON pipeline_runs.id = task_runs.pipeline_run_id").
+		Joins("JOIN pipelines ON pipelines.id = pipeline_runs.pipeline_id").
+		Joins("JOIN projects ON projects.id = pipelines.project_id").
+		Where("task_runs.id = ? AND task_runs.pipeline_run_id = ? AND projects.tenant_id = ?", taskRunID, runID, tenantID).
+		First(&taskRun).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("任务运行不存在")
+		}
+		return nil, fmt.Errorf("获取任务运行失败: %w", err)
+	}
+	return &taskRun, nil
+}
+
+// GetPipelineRun 获取租户下的流水线运行及其任务运行
+func (s *logService) GetPipelineRun(tenantID, runID uuid.UUID) (*models.PipelineRun, error) {
+	var run models.PipelineRun
+	if err := s.db.Model(&models.PipelineRun{}).
+		Joins("JOIN pipelines ON pipelines.id = pipeline_runs.pipeline_id").
+		Joins("JOIN projects ON projects.id = pipelines.project_id").
+		Where("pipeline_runs.id = ? AND projects.tenant_id = ?", runID, tenantID).
+		Preload("TaskRuns", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		First(&run).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("流水线运行不存在")
+		}
+		return nil, fmt.Errorf("获取流水线运行失败: %w", err)
+	}
+	return &run, nil
+}
+
+// CleanupExpired 清理超过保留天数的运行日志。
+// 以运行为单位，只有运行下所有日志分块都早于保留期限且运行已结束时才删除，
+// 运行记录已被清理的遗留日志同样会被删除
+func (s *logService) CleanupExpired() (int, error) {
+	if s.config.Storage.RetentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -s.config.Storage.RetentionDays)
+
+	ctx := context.Background()
+	objects, err := s.storage.List(ctx, "logs/")
+	if err != nil {
+		return 0, fmt.Errorf("列举日志失败: %w", err)
+	}
+
+	latest := make(map[string]time.Time)
+	for _, object := range objects {
+		runIDStr, _, _ := strings.Cut(strings.TrimPrefix(object.Key, "logs/"), "/")
+		if modTime, ok := latest[runIDStr]; !ok || object.ModTime.After(modTime) {
+			latest[runIDStr] = object.ModTime
+		}
+	}
+
+	cleaned := 0
+	for runIDStr, modTime := range latest {
+		if modTime.After(cutoff) {
+			continue
+		}
+		runID, err := uuid.Parse(runIDStr)
+		if err != nil {
+			continue
+		}
+
+		var active int64
+		s.db.Model(&models.PipelineRun{}).
+			Where("id = ? AND status IN ?", runID, []string{"pending", "running"}).
+			Count(&active)
+		if active > 0 {
+			continue
+		}
+
+		if err := s.storage.DeletePrefix(ctx, runLogPrefix(runID)); err != nil {
+			log.Printf("⚠️ 删除运行日志失败 %s: %v", runID, err)
+			continue
+		}
+		s.db.Model(&models.TaskRun{}).Where("pipeline_run_id = ?", runID).Update("logs_path", nil)
+		s.db.Model(&models.PipelineRun{}).Where("id = ?", runID).Update("logs_path", nil)
+		cleaned++
+	}
+	return cleaned, nil
+}
+
+// segmentReader 顺序读取多个日志分块
+type segmentReader struct {
+	ctx      context.Context
+	storage  ObjectStorage
+	segments []logSegment
+	skip     int64 // 第一个分块需要跳过的字节数
+	current  io.ReadCloser
+}
+
+func (r *segmentReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.segments) == 0 {
+				return 0, io.EOF
+			}
+			reader, err := r.storage.Get(r.ctx, r.segments[0].key)
+			if err != nil {
+				return 0, err
+			}
+			r.segments = r.segments[1:]
+			if r.skip > 0 {
+				if _, err := io.CopyN(io.Discard, reader, r.skip); err != nil {
+					reader.Close()
+					return 0, err
+				}
+				r.skip = 0
+			}
+			r.current = reader
+		}
+
+		n, err := r.current.Read(p)
+		if errors.Is(err, io.EOF) {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *segmentReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
+
+// logWriter 带缓冲的日志写入器，缓冲达到 logWriterFlushSize 或停留超过
+// logWriterFlushInterval 时写入一个分块。输出先经过流式脱敏再进入缓冲；
+// 无法获取日志掩码时丢弃输出，不写入未脱敏的内容。写入失败只记录日志，不影响任务执行
+type logWriter struct {
+	service   *logService
+	runID     uuid.UUID
+	taskRunID uuid.UUID
+	redactor  *logRedactor
+	maskErr   error
+
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	timer  *time.Timer
+	closed bool
+	failed bool
+}
+
+// NewWriter 创建带缓冲的日志写入器，创建时刷新运行的日志掩码
+func (s *logService) NewWriter(runID, taskRunID uuid.UUID) io.WriteCloser {
+	w := &logWriter{service: s, runID: runID, taskRunID: taskRunID}
+	masker, err := s.masker(runID, true)
+	if err != nil {
+		log.Printf("⚠️ 任务 %s 的日志将被丢弃: %v", taskRunID, err)
+		w.maskErr = err
+		w.buf.WriteString("[日志脱敏不可用，任务输出已丢弃]\n")
+	} else if masker != nil {
+		w.redactor = &logRedactor{masker: masker}
+	}
+	return w
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	switch {
+	case w.maskErr != nil:
+	case w.redactor != nil:
+		w.buf.Write(w.redactor.Write(p))
+	default:
+		w.buf.Write(p)
+	}
+	if w.buf.Len() >= logWriterFlushSize {
+		w.flushLocked()
+	} else if w.timer == nil {
+		w.timer = time.AfterFunc(logWriterFlushInterval, func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			w.timer = nil
+			if w.redactor != nil {
+				w.buf.Write(w.redactor.Flush(false))
+			}
+			w.flushLocked()
+		})
+	}
+	return len(p), nil
+}
+
+// Close 写入剩余缓冲并关闭写入器
+func (w *logWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if w.redactor != nil {
+		w.buf.Write(w.redactor.Flush(true))
+	}
+	w.flushLocked()
+	w.closed = true
+	return nil
+}
+
+func (w *logWriter) flushLocked() {
+	if w.buf.Len() == 0 {
+		return
+	}
+	data := bytes.Clone(w.buf.Bytes())
+	w.buf.Reset()
+
+	if _, err := w.service.append(w.runID, w.taskRunID, -1, data); err != nil && !w.failed {
+		w.failed = true
+		log.Printf("⚠️ 写入任务日志失败 %s: %v", w.taskRunID, err)
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"cicd-service/internal/config"
+)
+
+// ErrObjectNotFound 存储对象不存在
+var ErrObjectNotFound = errors.New("存储对象不存在")
+
+// ObjectStorage 对象存储接口，日志、构建产物等运行数据通过该接口读写，由 storage.type 选择存储后端。
+// 对象键使用 "/" 分隔，如 logs/<运行ID>/<任务运行ID>/00000000000000000000.log
+type ObjectStorage interface {
+	// Put 写入对象，已存在时覆盖，返回写入的字节数
+	Put(ctx context.Context, key string, reader io.Reader) (int64, error)
+	// Get 读取对象，对象不存在时返回 ErrObjectNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List 列出键以 prefix 开头的对象，按键排序
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// Delete 删除对象，对象不存在时不报错
+	Delete(ctx context.Context, key string) error
+	// DeletePrefix 删除键以 prefix 开头的全部对象
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+// ObjectInfo 存储对象信息
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// NewObjectStorage 根据配置创建对象存储
+func NewObjectStorage(cfg *config.Config) (ObjectStorage, error) {
+	switch cfg.Storage.Type {
+	case "", "local", "nfs":
+		// NFS 以挂载目录的方式使用，与本地存储相同
+		return NewLocalObjectStorage(cfg.Storage.LocalPath), nil
+	case "s3":
+		return NewS3ObjectStorage(cfg.Storage.S3Config)
+	default:
+		return nil, fmt.Errorf("不支持的存储类型: %s", cfg.Storage.Type)
+	}
+}
+
+// localObjectStorage 基于本地文件系统的对象存储，对象键映射为根目录下的相对路径
+type localObjectStorage struct {
+	root string
+}
+
+// NewLocalObjectStorage 创建本地文件系统对象存储
+func NewLocalObjectStorage(root string) ObjectStorage {
+	return &localObjectStorage{root: root}
+}
+
+// localTempPrefix 写入中的临时文件前缀，列举时忽略
+const localTempPrefix = ".tmp-"
+
+// objectPath 将对象键转换为文件路径，拒绝可能逃逸根目录的键
+func (s *localObjectStorage) objectPath(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || path.Clean(key) != key ||
+		key == ".." || strings.HasPrefix(key, "../") {
+		return "", fmt.Errorf("无效的对象键: %s", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Put 先写入临时文件再重命名，读取方不会看到写了一半的对象
+func (s *localObjectStorage) Put(ctx context.Context, key string, reader io.Reader) (int64, error) {
+	objectPath, err := s.objectPath(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0o755); err != nil {
+		return 0, fmt.Errorf("创建存储目录失败: %w", err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(objectPath), localTempPrefix+"*")
+	if err != nil {
+		return 0, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	written, err := io.Copy(file, reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("写入对象失败: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), objectPath); err != nil {
+		return 0, fmt.Errorf("保存对象失败: %w", err)
+	}
+	return written, nil
+}
+
+// Get 读取对象
+func (s *localObjectStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	objectPath, err := s.objectPath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(objectPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取对象失败: %w", err)
+	}
+	return file, nil
+}
+
+// List 列出键以 prefix 开头的对象
+func (s *localObjectStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	// 从前缀所在的目录开始遍历，再按完整前缀过滤
+	dir := strings.TrimSuffix(prefix, "/")
+	if !strings.HasSuffix(prefix, "/") {
+		dir = path.Dir(prefix)
+	}
+	root := s.root
+	if dir != "." && dir != "" {
+		var err error
+		if root, err = s.objectPath(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	var objects []ObjectInfo
+	err := filepath.WalkDir(root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), localTempPrefix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, filePath)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		objects = append(objects, ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("列举对象失败: %w", err)
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// Delete 删除对象
+func (s *localObjectStorage) Delete(ctx context.Context, key string) error {
+	objectPath, err := s.objectPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(objectPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("删除对象失败: %w", err)
+	}
+	return nil
+}
+
+// DeletePrefix 删除键以 prefix 开头的全部对象，前缀以 "/" 结尾时直接删除整个目录
+func (s *localObjectStorage) DeletePrefix(ctx context.Context, prefix string) error {
+	if strings.HasSuffix(prefix, "/") {
+		dir, err := s.objectPath(strings.TrimSuffix(prefix, "/"))
+		if err != nil {
+			return err
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("删除对象失败: %w", err)
+		}
+		return nil
+	}
+
+	objects, err := s.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if err := s.Delete(ctx, object.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
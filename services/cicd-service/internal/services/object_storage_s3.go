@@ -0,0 +1,385 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"cicd-service/internal/config"
+)
+
+// s3UnsignedPayload 请求体不参与签名，避免为计算签名读取两遍上传内容
+const s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// s3EmptyPayloadHash 空请求体的SHA-256
+const s3EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// s3MaxPutSize 单次PUT允许的最大对象大小
+const s3MaxPutSize = 5 << 30
+
+// s3ObjectStorage S3兼容对象存储（AWS S3、MinIO等），使用路径风格访问 <endpoint>/<bucket>/<key>，请求按 Signature V4 签名
+type s3ObjectStorage struct {
+	client    *http.Client
+	baseURL   string
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	now       func() time.Time
+}
+
+// NewS3ObjectStorage 创建S3兼容对象存储
+func NewS3ObjectStorage(cfg config.S3Config) (ObjectStorage, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3存储的endpoint和bucket不能为空")
+	}
+
+	endpoint := strings.TrimSuffix(cfg.Endpoint, "/")
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		scheme := "http"
+		if cfg.UseSSL {
+			scheme = "https"
+		}
+		endpoint = scheme + "://" + endpoint
+	}
+	if _, err := url.Parse(endpoint); err != nil {
+		return nil, fmt.Errorf("无效的S3 endpoint: %w", err)
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &s3ObjectStorage{
+		client:    &http.Client{Timeout: 30 * time.Minute},
+		baseURL:   endpoint,
+		bucket:    cfg.Bucket,
+		region:    region,
+		accessKey: cfg.AccessKeyID,
+		secretKey: cfg.SecretAccessKey,
+		now:       time.Now,
+	}, nil
+}
+
+// Put 上传对象。S3要求预先知道内容长度，长度未知的内容先写入临时文件
+func (s *s3ObjectStorage) Put(ctx context.Context, key string, reader io.Reader) (int64, error) {
+	size, body, cleanup, err := sizedReader(reader)
+	if err != nil {
+		return 0, err
+	}
+	defer cleanup()
+	if size > s3MaxPutSize {
+		return 0, fmt.Errorf("对象大小超过S3单次上传上限: %d", size)
+	}
+
+	// 长度为0时不发送请求体，否则会以 chunked 编码发送
+	var requestBody io.Reader
+	if size > 0 {
+		requestBody = io.NopCloser(body)
+	}
+	req, err := s.newRequest(ctx, http.MethodPut, key, nil, requestBody)
+	if err != nil {
+		return 0, err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := s.do(req, s3UnsignedPayload)
+	if err != nil {
+		return 0, fmt.Errorf("上传对象失败: %w", err)
+	}
+	resp.Body.Close()
+	return size, nil
+}
+
+// Get 下载对象
+func (s *s3ObjectStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req, s3EmptyPayloadHash)
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("读取对象失败: %w", err)
+	}
+	return resp.Body, nil
+}
+
+// s3ListResult ListObjectsV2 响应
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List 通过 ListObjectsV2 分页列出对象
+func (s *s3ObjectStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		req, err := s.newRequest(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req, s3EmptyPayloadHash)
+		if err != nil {
+			return nil, fmt.Errorf("列举对象失败: %w", err)
+		}
+
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("解析对象列表失败: %w", err)
+		}
+
+		for _, content := range result.Contents {
+			objects = append(objects, ObjectInfo{Key: content.Key, Size: content.Size, ModTime: content.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// Delete 删除对象，S3删除不存在的对象同样返回成功
+func (s *s3ObjectStorage) Delete(ctx context.Context, key string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, s3EmptyPayloadHash)
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("删除对象失败: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// DeletePrefix 逐个删除键以 prefix 开头的对象
+func (s *s3ObjectStorage) DeletePrefix(ctx context.Context, prefix string) error {
+	objects, err := s.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if err := s.Delete(ctx, object.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newRequest 构造路径风格的请求，key 为空时请求存储桶本身
+func (s *s3ObjectStorage) newRequest(ctx context.Context, method, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	if key != "" && (strings.HasPrefix(key, "/") || strings.Contains(key, "\\")) {
+		return nil, fmt.Errorf("无效的对象键: %s", key)
+	}
+
+	rawURL := s.baseURL + "/" + s3Escape(s.bucket, false)
+	if key != "" {
+		rawURL += "/" + s3Escape(key, false)
+	}
+	if len(query) > 0 {
+		rawURL += "?" + s3CanonicalQuery(query)
+	}
+	return http.NewRequestWithContext(ctx, method, rawURL, body)
+}
+
+// s3Error S3错误响应
+type s3Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *s3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("S3返回状态码 %d", e.StatusCode)
+	}
+	return fmt.Sprintf("S3返回状态码 %d: %s %s", e.StatusCode, e.Code, e.Message)
+}
+
+func isS3NotFound(err error) bool {
+	s3Err, ok := err.(*s3Error)
+	return ok && s3Err.StatusCode == http.StatusNotFound
+}
+
+// do 签名并发送请求，非2xx响应转换为 s3Error
+func (s *s3ObjectStorage) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	s.sign(req, payloadHash, s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	s3Err := &s3Error{StatusCode: resp.StatusCode}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	_ = xml.Unmarshal(body, s3Err)
+	return nil, s3Err
+}
+
+// sign 按 AWS Signature Version 4 为请求添加 Authorization 头，签名 host、range 及全部 x-amz-* 头
+func (s *s3ObjectStorage) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "range" || lower == "content-md5" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+// s3CanonicalQuery 按参数名排序并以 RFC 3986 规则编码查询参数
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, s3Escape(key, true)+"="+s3Escape(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape 按 SigV4 规则编码，仅保留非保留字符，路径中的 "/" 不编码
+func s3Escape(value string, encodeSlash bool) string {
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			builder.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&builder, "%%%02X", c)
+	}
+	return builder.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// sizedReader 返回内容长度已知的读取器，长度未知时写入临时文件
+func sizedReader(reader io.Reader) (int64, io.Reader, func(), error) {
+	noop := func() {}
+	switch r := reader.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len()), reader, noop, nil
+	case *os.File:
+		info, err := r.Stat()
+		if err == nil && info.Mode().IsRegular() {
+			offset, err := r.Seek(0, io.SeekCurrent)
+			if err == nil {
+				return info.Size() - offset, reader, noop, nil
+			}
+		}
+	}
+
+	file, err := os.CreateTemp("", "s3-upload-*")
+	if err != nil {
+		return 0, nil, noop, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	cleanup := func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+	size, err := io.Copy(file, reader)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return 0, nil, noop, fmt.Errorf("缓存上传内容失败: %w", err)
+	}
+	return size, file, cleanup, nil
+}
@@ -0,0 +1,735 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cicd-service/internal/models"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// PipelineDefinition 仓库中的流水线定义文件（如 .axiom-ci.yml）
+//
+//	name: build
+//	config:
+//	  timeout: 3600
+//	triggers:
+//	  - type: push
+//	    conditions:
+//	      branches: [main]
+//	variables:
+//	  GO_VERSION: "1.21"
+//	tasks:
+//	  - name: test
+//	    image: golang:${{ matrix.go }}
+//	    command: [go, test, ./...]
+//	    matrix:
+//	      axes:
+//	        go: ["1.21", "1.22"]
+//	  - name: build
+//	    type: build
+//	    image: golang:1.21
+//	    command: [go, build, ./...]
+//	    depends_on: [test]
+//	    artifacts:
+//	      paths: [bin/]
+//	    secrets:
+//	      - name: registry
+//	      - name: signing
+//	        key: private_key
+//	        env: SIGNING_KEY
+//	  - name: package
+//	    image: alpine:3.19
+//	    command: [tar, czf, "app-${{ run.number }}.tgz", bin]
+//	    depends_on: [build]
+//	    condition: branch == 'main'
+//	    artifacts_from:
+//	      - task: build
+type PipelineDefinition struct {
+	Name        string                 `yaml:"name" json:"name"`
+	Description *string                `yaml:"description" json:"description,omitempty"`
+	Config      DefinitionConfig       `yaml:"config" json:"config"`
+	Triggers    []DefinitionTrigger    `yaml:"triggers" json:"triggers,omitempty"`
+	Variables   map[string]interface{} `yaml:"variables" json:"variables,omitempty"`
+	Tasks       []DefinitionTask       `yaml:"tasks" json:"tasks"`
+}
+
+// DefinitionConfig 定义文件中的流水线配置
+type DefinitionConfig struct {
+	Timeout              int                    `yaml:"timeout" json:"timeout,omitempty"`
+	Retries              int                    `yaml:"retries" json:"retries,omitempty"`
+	Workspace            string                 `yaml:"workspace" json:"workspace,omitempty"`
+	ServiceAccount       string                 `yaml:"service_account" json:"service_account,omitempty"`
+	NodeSelector         string                 `yaml:"node_selector" json:"node_selector,omitempty"`
+	ResourceLimits       string                 `yaml:"resource_limits" json:"resource_limits,omitempty"`
+	EnableCache          *bool                  `yaml:"enable_cache" json:"enable_cache,omitempty"`
+	CacheKeys            []string               `yaml:"cache_keys" json:"cache_keys,omitempty"`
+	NotificationChannels []string               `yaml:"notification_channels" json:"notification_channels,omitempty"`
+	ArtifactExpireDays   int                    `yaml:"artifact_expire_days" json:"artifact_expire_days,omitempty"`
+	KeepLatestArtifacts  bool                   `yaml:"keep_latest_artifacts" json:"keep_latest_artifacts,omitempty"`
+	Priority             int                    `yaml:"priority" json:"priority,omitempty"`
+	Concurrency          *DefinitionConcurrency `yaml:"concurrency" json:"concurrency,omitempty"`
+}
+
+// DefinitionConcurrency 定义文件中的并发组配置，同组同一分支同时只启动一个运行
+type DefinitionConcurrency struct {
+	Group            string `yaml:"group" json:"group"`
+	CancelInProgress bool   `yaml:"cancel_in_progress" json:"cancel_in_progress,omitempty"`
+}
+
+// DefinitionTrigger 定义文件中的触发器，enabled缺省为true
+type DefinitionTrigger struct {
+	Type       string                 `yaml:"type" json:"type"`
+	Conditions map[string]interface{} `yaml:"conditions" json:"conditions,omitempty"`
+	Enabled    *bool                  `yaml:"enabled" json:"enabled,omitempty"`
+}
+
+// DefinitionTask 定义文件中的任务，type缺省为custom
+type DefinitionTask struct {
+	Name        string            `yaml:"name" json:"name"`
+	Description *string           `yaml:"description" json:"description,omitempty"`
+	Type        string            `yaml:"type" json:"type"`
+	Image       string            `yaml:"image" json:"image"`
+	Command     []string          `yaml:"command" json:"command,omitempty"`
+	Args        []string          `yaml:"args" json:"args,omitempty"`
+	WorkingDir  *string           `yaml:"working_dir" json:"working_dir,omitempty"`
+	Env         map[string]string `yaml:"env" json:"env,omitempty"`
+	Volumes     []VolumeMount     `yaml:"volumes" json:"volumes,omitempty"`
+	DependsOn   []string          `yaml:"depends_on" json:"depends_on,omitempty"`
+	Condition   *string           `yaml:"condition" json:"condition,omitempty"`
+	Timeout     int               `yaml:"timeout" json:"timeout,omitempty"`
+	Retries     int               `yaml:"retries" json:"retries,omitempty"`
+	RunnerTags  []string          `yaml:"runner_tags" json:"runner_tags,omitempty"` // 自托管执行器需具备的标签
+	Artifacts   *ArtifactSpec     `yaml:"artifacts" json:"artifacts,omitempty"`
+	ArtifactsFrom []ArtifactDependency `yaml:"artifacts_from" json:"artifacts_from,omitempty"`
+	Secrets     []SecretReference `yaml:"secrets" json:"secrets,omitempty"`
+	Matrix      *TaskMatrix       `yaml:"matrix" json:"matrix,omitempty"`
+	Environment *string           `yaml:"environment" json:"environment,omitempty"` // 部署的目标环境
+}
+
+// 定义文件允许的取值
+var (
+	definitionTaskTypes    = []string{"build", "test", "deploy", "custom"}
+	definitionTriggerTypes = []string{"webhook", "push", "tag", "pull_request", "schedule", "manual"}
+)
+
+// ParsePipelineDefinition 解析并校验YAML流水线定义，未知字段视为错误
+func ParsePipelineDefinition(data []byte) (*PipelineDefinition, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var definition PipelineDefinition
+	if err := decoder.Decode(&definition); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("流水线定义为空")
+		}
+		return nil, fmt.Errorf("解析流水线定义失败: %w", err)
+	}
+
+	if err := definition.Validate(); err != nil {
+		return nil, err
+	}
+	return &definition, nil
+}
+
+// Validate 校验流水线定义：任务名唯一、依赖存在且无环、所有任务可执行、取值合法
+func (d *PipelineDefinition) Validate() error {
+	var problems []string
+
+	if len(d.Name) > 255 {
+		problems = append(problems, "name 不能超过255个字符")
+	}
+	if d.Config.Timeout < 0 {
+		problems = append(problems, "config.timeout 不能为负数")
+	}
+	if d.Config.Retries < 0 || d.Config.Retries > 5 {
+		problems = append(problems, "config.retries 必须在0到5之间")
+	}
+	if d.Config.ArtifactExpireDays < 0 || d.Config.ArtifactExpireDays > 3650 {
+		problems = append(problems, "config.artifact_expire_days 必须在0到3650之间")
+	}
+	if d.Config.Priority < 0 || d.Config.Priority > maxRunPriority {
+		problems = append(problems, fmt.Sprintf("config.priority 必须在0到%d之间", maxRunPriority))
+	}
+	if d.Config.Concurrency != nil && (d.Config.Concurrency.Group == "" || len(d.Config.Concurrency.Group) > 255) {
+		problems = append(problems, "config.concurrency.group 不能为空且不能超过255个字符")
+	}
+
+	problems = append(problems, d.validateTriggers()...)
+
+	if len(d.Tasks) == 0 {
+		problems = append(problems, "至少需要定义一个任务")
+	}
+
+	names := make(map[string]bool, len(d.Tasks))
+	for i, task := range d.Tasks {
+		field := fmt.Sprintf("tasks[%d]", i)
+		if task.Name == "" {
+			problems = append(problems, field+".name 不能为空")
+		} else {
+			field = fmt.Sprintf("任务 %q", task.Name)
+			if names[task.Name] {
+				problems = append(problems, field+" 名称重复")
+			}
+			names[task.Name] = true
+		}
+		if len(task.Name) > 255 {
+			problems = append(problems, field+" 名称不能超过255个字符")
+		}
+		if task.Image == "" {
+			problems = append(problems, field+" 缺少 image")
+		}
+		if task.Type != "" && !containsString(definitionTaskTypes, task.Type) {
+			problems = append(problems, fmt.Sprintf("%s 的 type 无效: %q（可选 %s）",
+				field, task.Type, strings.Join(definitionTaskTypes, ", ")))
+		}
+		if task.Timeout < 0 || task.Timeout > 7200 {
+			problems = append(problems, field+" 的 timeout 必须在1到7200秒之间")
+		}
+		if task.Retries < 0 || task.Retries > 5 {
+			problems = append(problems, field+" 的 retries 必须在0到5之间")
+		}
+		for _, volume := range task.Volumes {
+			if volume.Name == "" || volume.MountPath == "" {
+				problems = append(problems, field+" 的 volumes 需要 name 和 mount_path")
+			}
+		}
+		problems = append(problems, validateArtifactSpec(field, task.Artifacts)...)
+		problems = append(problems, validateSecretReferences(field, task.Secrets)...)
+		problems = append(problems, validateTaskMatrix(field, task.Matrix)...)
+		if task.Environment != nil && (*task.Environment == "" || len(*task.Environment) > 255) {
+			problems = append(problems, field+" 的 environment 长度必须在1到255之间")
+		}
+	}
+
+	problems = append(problems, d.validateTaskGraph(names)...)
+
+	// 本次运行内的产物依赖和表达式引用的任务结果必须是前置任务，否则使用时可能尚未产出
+	if len(problems) == 0 {
+		for _, task := range d.Tasks {
+			field := fmt.Sprintf("任务 %q", task.Name)
+			upstream := d.upstreamTasks(task.Name)
+			problems = append(problems, validateTaskExpressions(field, &task, upstream)...)
+			for _, dep := range task.ArtifactsFrom {
+				if dep.Task == "" {
+					problems = append(problems, field+" 的 artifacts_from 缺少 task")
+					continue
+				}
+				if dep.Target != "" && !isRelativeWorkspacePath(dep.Target) {
+					problems = append(problems, fmt.Sprintf("%s 的 artifacts_from.target 必须是工作空间内的相对路径: %q", field, dep.Target))
+				}
+				if dep.Pipeline != "" {
+					continue
+				}
+				if dep.Branch != "" {
+					problems = append(problems, field+" 的 artifacts_from.branch 只能与 pipeline 一起使用")
+				}
+				if !upstream[dep.Task] {
+					problems = append(problems, fmt.Sprintf("%s 的 artifacts_from 引用的任务 %q 必须是其直接或间接依赖", field, dep.Task))
+				}
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("流水线定义校验失败: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateTaskGraph 校验任务依赖图：依赖的任务存在（名称相近时给出提示）、不重复、无环，
+// 并找出因上游依赖无效或成环而永远不会执行的任务
+func (d *PipelineDefinition) validateTaskGraph(names map[string]bool) []string {
+	var problems []string
+	broken := make(map[string]bool)
+	for _, task := range d.Tasks {
+		seen := make(map[string]bool, len(task.DependsOn))
+		for _, dep := range task.DependsOn {
+			switch {
+			case dep == task.Name:
+				problems = append(problems, fmt.Sprintf("任务 %q 不能依赖自身", task.Name))
+			case !names[dep]:
+				if similar := similarTaskName(dep, names); similar != "" {
+					problems = append(problems, fmt.Sprintf("任务 %q 依赖的任务 %q 不存在，是否为 %q？", task.Name, dep, similar))
+				} else {
+					problems = append(problems, fmt.Sprintf("任务 %q 依赖的任务 %q 不存在", task.Name, dep))
+				}
+			case seen[dep]:
+				problems = append(problems, fmt.Sprintf("任务 %q 重复依赖任务 %q", task.Name, dep))
+				continue
+			default:
+				seen[dep] = true
+				continue
+			}
+			broken[task.Name] = true
+		}
+	}
+
+	var cycle []string
+	if len(problems) == 0 {
+		if cycle = d.findCycle(); cycle != nil {
+			problems = append(problems, fmt.Sprintf("任务依赖存在循环: %s", strings.Join(cycle, " -> ")))
+			for _, name := range cycle {
+				broken[name] = true
+			}
+		}
+	}
+
+	var blocked []string
+	for _, name := range d.unreachableTasks(names) {
+		if !broken[name] {
+			blocked = append(blocked, strconv.Quote(name))
+		}
+	}
+	if len(blocked) > 0 {
+		problems = append(problems, fmt.Sprintf("任务 %s 的上游依赖不存在或存在循环，永远不会执行", strings.Join(blocked, ", ")))
+	}
+	return problems
+}
+
+// unreachableTasks 按依赖关系逐层放行任务，返回始终无法放行的任务（依赖不存在、成环或依赖这些任务）
+func (d *PipelineDefinition) unreachableTasks(names map[string]bool) []string {
+	done := make(map[string]bool, len(d.Tasks))
+	for changed := true; changed; {
+		changed = false
+		for _, task := range d.Tasks {
+			if done[task.Name] {
+				continue
+			}
+			ready := true
+			for _, dep := range task.DependsOn {
+				if !names[dep] || !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				done[task.Name] = true
+				changed = true
+			}
+		}
+	}
+
+	var unreachable []string
+	for _, task := range d.Tasks {
+		if !done[task.Name] {
+			unreachable = append(unreachable, task.Name)
+		}
+	}
+	return unreachable
+}
+
+// similarTaskName 查找与 name 相近的任务名（忽略大小写和 -、_、空格差异，或编辑距离足够小），用于提示拼写错误
+func similarTaskName(name string, names map[string]bool) string {
+	normalize := func(s string) string {
+		return strings.NewReplacer("-", "", "_", "", " ", "").Replace(strings.ToLower(s))
+	}
+
+	candidates := make([]string, 0, len(names))
+	for candidate := range names {
+		candidates = append(candidates, candidate)
+	}
+	sort.Strings(candidates)
+
+	// 名称越短允许的差异越小，避免把任意短名称提示为另一个任务
+	best, bestDistance := "", min(len([]rune(name))/2, 2)+1
+	for _, candidate := range candidates {
+		if normalize(candidate) == normalize(name) {
+			return candidate
+		}
+		if distance := editDistance(name, candidate); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// editDistance 两个字符串的编辑距离
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr := make([]int, len(rb)+1)
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev = curr
+	}
+	return prev[len(rb)]
+}
+
+// findCycle 深度优先查找依赖环，返回环上的任务名
+func (d *PipelineDefinition) findCycle() []string {
+	deps := make(map[string][]string, len(d.Tasks))
+	for _, task := range d.Tasks {
+		deps[task.Name] = task.DependsOn
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(d.Tasks))
+	var stack []string
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, dep := range deps[name] {
+			switch state[dep] {
+			case visiting:
+				for i, n := range stack {
+					if n == dep {
+						return append(append([]string{}, stack[i:]...), dep)
+					}
+				}
+			case unvisited:
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = visited
+		return nil
+	}
+
+	for _, task := range d.Tasks {
+		if state[task.Name] == unvisited {
+			if cycle := visit(task.Name); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// upstreamTasks 返回任务直接或间接依赖的全部任务
+func (d *PipelineDefinition) upstreamTasks(name string) map[string]bool {
+	deps := make(map[string][]string, len(d.Tasks))
+	for _, task := range d.Tasks {
+		deps[task.Name] = task.DependsOn
+	}
+
+	upstream := make(map[string]bool)
+	pending := append([]string(nil), deps[name]...)
+	for len(pending) > 0 {
+		dep := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if upstream[dep] {
+			continue
+		}
+		upstream[dep] = true
+		pending = append(pending, deps[dep]...)
+	}
+	return upstream
+}
+
+// validateArtifactSpec 校验任务的产物声明
+func validateArtifactSpec(field string, spec *ArtifactSpec) []string {
+	if spec == nil {
+		return nil
+	}
+
+	var problems []string
+	if len(spec.Paths) == 0 {
+		problems = append(problems, field+" 的 artifacts.paths 不能为空")
+	}
+	for _, p := range spec.Paths {
+		if !isRelativeWorkspacePath(p) {
+			problems = append(problems, fmt.Sprintf("%s 的 artifacts.paths 必须是工作空间内的相对路径: %q", field, p))
+		} else if _, err := path.Match(p, ""); err != nil {
+			problems = append(problems, fmt.Sprintf("%s 的 artifacts.paths 通配符无效: %q", field, p))
+		}
+	}
+	if len(spec.Name) > 255 {
+		problems = append(problems, field+" 的 artifacts.name 不能超过255个字符")
+	}
+	if spec.When != "" && spec.When != ArtifactWhenOnSuccess && spec.When != ArtifactWhenOnFailure && spec.When != ArtifactWhenAlways {
+		problems = append(problems, fmt.Sprintf("%s 的 artifacts.when 无效: %q（可选 %s, %s, %s）",
+			field, spec.When, ArtifactWhenOnSuccess, ArtifactWhenOnFailure, ArtifactWhenAlways))
+	}
+	if spec.ExpireInDays < 0 || spec.ExpireInDays > 3650 {
+		problems = append(problems, field+" 的 artifacts.expire_in_days 必须在0到3650之间")
+	}
+	return problems
+}
+
+// validateSecretReferences 校验任务的密钥引用，密钥是否存在在运行时解析
+func validateSecretReferences(field string, refs []SecretReference) []string {
+	var problems []string
+	envs := make(map[string]bool, len(refs))
+	for _, ref := range refs {
+		if ref.Name == "" {
+			problems = append(problems, field+" 的 secrets.name 不能为空")
+			continue
+		}
+		if ref.Env != "" && ref.Key == "" {
+			problems = append(problems, fmt.Sprintf("%s 的密钥 %q 指定 env 时必须同时指定 key", field, ref.Name))
+		}
+		env := ref.Env
+		if env == "" {
+			env = ref.Key
+		}
+		if env == "" {
+			continue
+		}
+		if !IsEnvVarName(env) {
+			problems = append(problems, fmt.Sprintf("%s 的密钥 %q 的环境变量名无效: %q", field, ref.Name, env))
+		} else if envs[env] {
+			problems = append(problems, fmt.Sprintf("%s 的环境变量 %q 被多个密钥引用", field, env))
+		}
+		envs[env] = true
+	}
+	return problems
+}
+
+// isRelativeWorkspacePath 判断路径是否为不会逃逸工作空间的相对路径
+func isRelativeWorkspacePath(p string) bool {
+	if p == "" || strings.HasPrefix(p, "/") || strings.Contains(p, "\\") {
+		return false
+	}
+	for _, segment := range strings.Split(p, "/") {
+		if segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// PipelineConfig 转换为流水线配置，未设置的字段使用默认值
+func (d *PipelineDefinition) PipelineConfig(defaultTimeout int) models.PipelineConfig {
+	cfg := models.PipelineConfig{
+		Timeout:              d.Config.Timeout,
+		Retries:              d.Config.Retries,
+		Workspace:            d.Config.Workspace,
+		ServiceAccount:       d.Config.ServiceAccount,
+		NodeSelector:         d.Config.NodeSelector,
+		ResourceLimits:       d.Config.ResourceLimits,
+		EnableCache:          true,
+		CacheKeys:            d.Config.CacheKeys,
+		NotificationChannels: d.Config.NotificationChannels,
+		ArtifactExpireDays:   d.Config.ArtifactExpireDays,
+		KeepLatestArtifacts:  d.Config.KeepLatestArtifacts,
+		Priority:             d.Config.Priority,
+	}
+	if d.Config.Concurrency != nil {
+		cfg.ConcurrencyGroup = d.Config.Concurrency.Group
+		cfg.CancelInProgress = d.Config.Concurrency.CancelInProgress
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.Workspace == "" {
+		cfg.Workspace = "/workspace"
+	}
+	if d.Config.EnableCache != nil {
+		cfg.EnableCache = *d.Config.EnableCache
+	}
+	return cfg
+}
+
+// validateTriggers 校验触发器类型，schedule 触发器需要有效的cron表达式和时区，Git事件触发器需要有效的过滤条件
+func (d *PipelineDefinition) validateTriggers() []string {
+	var problems []string
+	for i, trigger := range d.Triggers {
+		if !containsString(definitionTriggerTypes, trigger.Type) {
+			problems = append(problems, fmt.Sprintf("triggers[%d].type 无效: %q（可选 %s）",
+				i, trigger.Type, strings.Join(definitionTriggerTypes, ", ")))
+			continue
+		}
+		if trigger.Type == "schedule" {
+			if _, err := ParseScheduleTrigger(trigger.Conditions); err != nil {
+				problems = append(problems, fmt.Sprintf("triggers[%d].%v", i, err))
+			}
+		} else if trigger.Type != "manual" {
+			for _, problem := range validateGitTriggerConditions(trigger.Type, trigger.Conditions) {
+				problems = append(problems, fmt.Sprintf("triggers[%d].%s", i, problem))
+			}
+		}
+	}
+	return problems
+}
+
+// TriggerConfigs 转换为触发器配置
+func (d *PipelineDefinition) TriggerConfigs() []TriggerConfig {
+	triggers := make([]TriggerConfig, 0, len(d.Triggers))
+	for _, trigger := range d.Triggers {
+		enabled := true
+		if trigger.Enabled != nil {
+			enabled = *trigger.Enabled
+		}
+		triggers = append(triggers, TriggerConfig{
+			Type:       trigger.Type,
+			Conditions: trigger.Conditions,
+			Enabled:    enabled,
+		})
+	}
+	return triggers
+}
+
+// TaskRequests 转换为任务创建请求
+func (d *PipelineDefinition) TaskRequests() []CreateTaskRequest {
+	tasks := make([]CreateTaskRequest, 0, len(d.Tasks))
+	for i, task := range d.Tasks {
+		taskType := task.Type
+		if taskType == "" {
+			taskType = "custom"
+		}
+		tasks = append(tasks, CreateTaskRequest{
+			Name:        task.Name,
+			Description: task.Description,
+			Type:        taskType,
+			Image:       task.Image,
+			Command:     task.Command,
+			Args:        task.Args,
+			WorkingDir:  task.WorkingDir,
+			Env:         task.Env,
+			Volumes:     task.Volumes,
+			DependsOn:   task.DependsOn,
+			Condition:   task.Condition,
+			Order:       i + 1,
+			Timeout:     task.Timeout,
+			Retries:     task.Retries,
+			RunnerTags:  task.RunnerTags,
+			Artifacts:   task.Artifacts,
+			ArtifactsFrom: task.ArtifactsFrom,
+			Secrets:     task.Secrets,
+			Matrix:      task.Matrix,
+			Environment: task.Environment,
+		})
+	}
+	return tasks
+}
+
+// BuildPipeline 基于已有流水线记录构建本次运行使用的流水线（不落库），
+// 任务ID按任务名确定性生成，与导入时创建的任务记录一致
+func (d *PipelineDefinition) BuildPipeline(base *models.Pipeline, defaultTimeout int) (*models.Pipeline, error) {
+	pipeline := *base
+	pipeline.Config = d.PipelineConfig(defaultTimeout)
+	if d.Description != nil {
+		pipeline.Description = d.Description
+	}
+
+	triggersJSON, err := json.Marshal(d.TriggerConfigs())
+	if err != nil {
+		return nil, fmt.Errorf("序列化触发器配置失败: %w", err)
+	}
+	pipeline.Triggers = triggersJSON
+
+	if d.Variables != nil {
+		variablesJSON, err := json.Marshal(d.Variables)
+		if err != nil {
+			return nil, fmt.Errorf("序列化变量配置失败: %w", err)
+		}
+		pipeline.Variables = variablesJSON
+	}
+
+	pipeline.Tasks = make([]models.Task, 0, len(d.Tasks))
+	for _, req := range d.TaskRequests() {
+		task, err := newTaskModel(pipeline.ID, &req, defaultTimeout)
+		if err != nil {
+			return nil, err
+		}
+		task.ID = DefinitionTaskID(pipeline.ID, req.Name)
+		pipeline.Tasks = append(pipeline.Tasks, *task)
+	}
+
+	return &pipeline, nil
+}
+
+// DefinitionTaskID 定义文件中任务的确定性ID
+func DefinitionTaskID(pipelineID uuid.UUID, taskName string) uuid.UUID {
+	return uuid.NewSHA1(pipelineID, []byte(taskName))
+}
+
+// definitionFromRequest 将API创建请求转换为定义，复用同一套校验规则
+func definitionFromRequest(name string, triggers []TriggerConfig, tasks []CreateTaskRequest) *PipelineDefinition {
+	definition := &PipelineDefinition{Name: name}
+	for _, trigger := range triggers {
+		enabled := trigger.Enabled
+		definition.Triggers = append(definition.Triggers, DefinitionTrigger{
+			Type:       trigger.Type,
+			Conditions: trigger.Conditions,
+			Enabled:    &enabled,
+		})
+	}
+	for _, task := range tasks {
+		definition.Tasks = append(definition.Tasks, DefinitionTask{
+			Name:      task.Name,
+			Type:      task.Type,
+			Image:     task.Image,
+			Command:   task.Command,
+			Args:      task.Args,
+			Env:       task.Env,
+			Volumes:   task.Volumes,
+			DependsOn: task.DependsOn,
+			Condition: task.Condition,
+			Timeout:   task.Timeout,
+			Retries:   task.Retries,
+			Artifacts: task.Artifacts,
+			ArtifactsFrom: task.ArtifactsFrom,
+			Secrets:   task.Secrets,
+			Matrix:    task.Matrix,
+			Environment: task.Environment,
+		})
+	}
+	return definition
+}
+
+// containsString 判断切片是否包含指定字符串
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// loadPipelineDefinition 通过Git网关读取指定引用下的定义文件并解析校验
+func loadPipelineDefinition(client GitGatewayClient, repositoryID uuid.UUID, ref, path string,
+	userID uuid.UUID) (*PipelineDefinition, *RepositoryFile, error) {
+	file, err := client.GetFile(repositoryID, ref, path, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := file.Decode()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	definition, err := ParsePipelineDefinition(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s@%s: %w", file.Path, shortSHA(file.CommitSHA), err)
+	}
+	return definition, file, nil
+}
+
+// shortSHA 提交SHA的短格式
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
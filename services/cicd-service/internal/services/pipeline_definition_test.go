@@ -0,0 +1,129 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// taskGraph 按 "名称:依赖1,依赖2" 构造只含依赖关系的流水线定义
+func taskGraph(specs ...string) (*PipelineDefinition, map[string]bool) {
+	d := &PipelineDefinition{}
+	names := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		name, deps, _ := strings.Cut(spec, ":")
+		task := DefinitionTask{Name: name}
+		if deps != "" {
+			task.DependsOn = strings.Split(deps, ",")
+		}
+		d.Tasks = append(d.Tasks, task)
+		names[name] = true
+	}
+	return d, names
+}
+
+func TestFindCycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		specs []string
+		want  []string
+	}{
+		{"linear", []string{"a", "b:a", "c:b"}, nil},
+		{"diamond", []string{"a", "b:a", "c:a", "d:b,c"}, nil},
+		{"two tasks", []string{"a:b", "b:a"}, []string{"a", "b", "a"}},
+		{"three tasks", []string{"a", "b:a,d", "c:b", "d:c"}, []string{"b", "d", "c", "b"}},
+		{"cycle behind acyclic prefix", []string{"a", "b:a", "c:b,e", "e:c"}, []string{"c", "e", "c"}},
+		{"missing dependency is not a cycle", []string{"a:x"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, _ := taskGraph(tt.specs...)
+			if got := d.findCycle(); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("findCycle = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnreachableTasks(t *testing.T) {
+	tests := []struct {
+		name  string
+		specs []string
+		want  []string
+	}{
+		{"all reachable", []string{"a", "b:a", "c:a", "d:b,c"}, nil},
+		{"declared out of order", []string{"d:b,c", "c:a", "b:a", "a"}, nil},
+		{"missing dependency", []string{"a", "b:x", "c:b"}, []string{"b", "c"}},
+		{"cycle blocks downstream", []string{"a", "b:c", "c:b", "d:c", "e:a"}, []string{"b", "c", "d"}},
+		{"partially blocked join", []string{"a", "b:x", "c:a,b"}, []string{"b", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, names := taskGraph(tt.specs...)
+			if got := d.unreachableTasks(names); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("unreachableTasks = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTaskGraph(t *testing.T) {
+	tests := []struct {
+		name  string
+		specs []string
+		want  []string // 每个问题需包含的文本，按顺序
+	}{
+		{"valid", []string{"a", "b:a"}, nil},
+		{"self dependency", []string{"a:a"}, []string{`任务 "a" 不能依赖自身`}},
+		{"missing with suggestion", []string{"unit-test", "build:unit_test"}, []string{`依赖的任务 "unit_test" 不存在，是否为 "unit-test"？`}},
+		{"missing without suggestion", []string{"a", "b:deploy"}, []string{`依赖的任务 "deploy" 不存在`}},
+		{"duplicate dependency", []string{"a", "b:a,a"}, []string{`任务 "b" 重复依赖任务 "a"`}},
+		{"cycle", []string{"a", "b:c", "c:b"}, []string{"任务依赖存在循环: b -> c -> b"}},
+		{"cycle blocks downstream", []string{"a:c", "b:a", "c:b", "d:c", "e"}, []string{
+			"任务依赖存在循环: a -> c -> b -> a",
+			`任务 "d" 的上游依赖不存在或存在循环，永远不会执行`,
+		}},
+		{"missing dependency blocks downstream", []string{"a:x", "b:a"}, []string{
+			`任务 "a" 依赖的任务 "x" 不存在`,
+			`任务 "b" 的上游依赖不存在或存在循环，永远不会执行`,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, names := taskGraph(tt.specs...)
+			got := d.validateTaskGraph(names)
+			if len(got) != len(tt.want) {
+				t.Fatalf("validateTaskGraph = %q, want %d problems", got, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(got[i], want) {
+					t.Fatalf("problem %d = %q, want to contain %q", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestSimilarTaskName(t *testing.T) {
+	names := map[string]bool{"unit-test": true, "build": true, "deploy-prod": true, "ab": true}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Unit_Test", "unit-test"},
+		{"biuld", "build"},
+		{"deploy-prd", "deploy-prod"},
+		{"lint", ""},
+		{"xy", ""},
+	}
+
+	for _, tt := range tests {
+		if got := similarTaskName(tt.name, names); got != tt.want {
+			t.Errorf("similarTaskName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
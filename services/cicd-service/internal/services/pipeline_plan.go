@@ -0,0 +1,279 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"cicd-service/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// planHistorySamples 估算任务时长时每个任务最多参考的历史运行数
+const planHistorySamples = 20
+
+// ExecutionPlan 流水线执行计划：按依赖关系分阶段，并根据历史运行时长估算关键路径和总时长
+type ExecutionPlan struct {
+	PipelineID        *uuid.UUID  `json:"pipeline_id,omitempty"` // 参考历史时长的流水线，无历史时为空
+	Stages            []PlanStage `json:"stages"`
+	CriticalPath      []string    `json:"critical_path"`             // 决定总时长的任务链
+	EstimatedDuration int         `json:"estimated_duration"`        // 预计总时长(秒)，即关键路径时长
+	MissingHistory    []string    `json:"missing_history,omitempty"` // 没有成功运行记录、未计入估算的任务
+}
+
+// PlanStage 执行阶段，同一阶段的任务依赖都在之前的阶段中，可以并行执行
+type PlanStage struct {
+	Index int        `json:"index"`
+	Tasks []PlanTask `json:"tasks"`
+}
+
+// PlanTask 执行计划中的任务
+type PlanTask struct {
+	Name              string   `json:"name"`
+	DependsOn         []string `json:"depends_on,omitempty"`
+	Condition         *string  `json:"condition,omitempty"`    // 配置了执行条件，运行时可能跳过
+	Combinations      int      `json:"combinations,omitempty"` // 矩阵任务展开的组合数
+	EstimatedDuration int      `json:"estimated_duration"`     // 预计时长(秒)，矩阵任务按 max_parallel 分批计算
+	Samples           int      `json:"samples"`                // 参与估算的历史运行数
+	EarliestStart     int      `json:"earliest_start"`         // 依赖全部完成的最早时间(秒)
+	Critical          bool     `json:"critical"`               // 位于关键路径上
+}
+
+// planTask 计算执行计划所需的任务信息
+type planTask struct {
+	name      string
+	dependsOn []string
+	condition *string
+	matrix    *TaskMatrix
+	order     int
+}
+
+// Plan 计算已有流水线的执行计划
+func (s *pipelineService) Plan(id uuid.UUID) (*ExecutionPlan, error) {
+	pipeline, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make([]planTask, 0, len(pipeline.Tasks))
+	for i := range pipeline.Tasks {
+		task := &pipeline.Tasks[i]
+		tasks = append(tasks, planTask{
+			name:      task.Name,
+			dependsOn: task.DependsOn,
+			condition: task.Condition,
+			matrix:    taskMatrixSpec(task),
+			order:     task.Order,
+		})
+	}
+
+	// 历史数据中的流水线可能早于依赖校验创建，计算前重新校验依赖图
+	definition := &PipelineDefinition{}
+	names := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		definition.Tasks = append(definition.Tasks, DefinitionTask{Name: task.name, DependsOn: task.dependsOn})
+		names[task.name] = true
+	}
+	if problems := definition.validateTaskGraph(names); len(problems) > 0 {
+		return nil, fmt.Errorf("流水线任务依赖无效: %s", strings.Join(problems, "; "))
+	}
+
+	history, err := s.taskDurationHistory(pipeline.ID, tasks)
+	if err != nil {
+		return nil, err
+	}
+	plan := buildExecutionPlan(tasks, history)
+	plan.PipelineID = &pipeline.ID
+	return plan, nil
+}
+
+// DryRun 校验创建请求并计算执行计划，不创建流水线；同项目已有同名流水线时参考其历史运行时长
+func (s *pipelineService) DryRun(req *CreatePipelineRequest) (*ExecutionPlan, error) {
+	if err := s.ValidateConfig(req); err != nil {
+		return nil, err
+	}
+
+	tasks := make([]planTask, 0, len(req.Tasks))
+	for _, task := range req.Tasks {
+		tasks = append(tasks, planTask{
+			name:      task.Name,
+			dependsOn: task.DependsOn,
+			condition: task.Condition,
+			matrix:    task.Matrix,
+			order:     task.Order,
+		})
+	}
+
+	history := map[string][]int{}
+	var existing models.Pipeline
+	err := s.db.Where("project_id = ? AND name = ? AND deleted_at IS NULL", req.ProjectID, req.Name).First(&existing).Error
+	switch {
+	case err == nil:
+		if history, err = s.taskDurationHistory(existing.ID, tasks); err != nil {
+			return nil, err
+		}
+	case err != gorm.ErrRecordNotFound:
+		return nil, fmt.Errorf("查询流水线失败: %w", err)
+	}
+
+	plan := buildExecutionPlan(tasks, history)
+	if len(history) > 0 {
+		plan.PipelineID = &existing.ID
+	}
+	return plan, nil
+}
+
+// taskDurationHistory 查询流水线最近成功的任务运行时长（任务名 -> 时长，由近及远），矩阵任务按组合汇总到矩阵任务名下
+func (s *pipelineService) taskDurationHistory(pipelineID uuid.UUID, tasks []planTask) (map[string][]int, error) {
+	runs := 0
+	for _, task := range tasks {
+		runs += planCombinations(task)
+	}
+
+	var samples []struct {
+		Name     string
+		Duration int
+	}
+	if err := s.db.Table("task_runs").
+		Select("COALESCE(task_runs.matrix_task, task_runs.name) AS name, task_runs.duration").
+		Joins("JOIN pipeline_runs ON pipeline_runs.id = task_runs.pipeline_run_id").
+		Where("pipeline_runs.pipeline_id = ? AND task_runs.status = ? AND task_runs.duration IS NOT NULL",
+			pipelineID, "succeeded").
+		Order("task_runs.created_at DESC").
+		Limit(runs * planHistorySamples).
+		Scan(&samples).Error; err != nil {
+		return nil, fmt.Errorf("查询任务历史时长失败: %w", err)
+	}
+
+	history := make(map[string][]int)
+	for _, sample := range samples {
+		if len(history[sample.Name]) < planHistorySamples {
+			history[sample.Name] = append(history[sample.Name], sample.Duration)
+		}
+	}
+	return history, nil
+}
+
+// buildExecutionPlan 按依赖关系计算阶段和关键路径；任务时长取历史时长的中位数，依赖图须已通过校验
+func buildExecutionPlan(tasks []planTask, history map[string][]int) *ExecutionPlan {
+	sorted := make([]planTask, len(tasks))
+	copy(sorted, tasks)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].order < sorted[j].order })
+
+	plan := &ExecutionPlan{Stages: []PlanStage{}, CriticalPath: []string{}}
+	stage := make(map[string]int, len(sorted))
+	finish := make(map[string]int, len(sorted))
+	previous := make(map[string]string, len(sorted))
+	planned := make(map[string]*PlanTask, len(sorted))
+
+	// 依赖已全部计算的任务放入下一阶段，阶段号为最长依赖链长度
+	for len(planned) < len(sorted) {
+		progressed := false
+		for _, task := range sorted {
+			if planned[task.name] != nil {
+				continue
+			}
+			ready := true
+			for _, dep := range task.dependsOn {
+				if planned[dep] == nil {
+					ready = false
+					break
+				}
+			}
+			if !ready {
+				continue
+			}
+
+			item := &PlanTask{
+				Name:      task.name,
+				DependsOn: task.dependsOn,
+				Condition: task.condition,
+				Samples:   len(history[task.name]),
+			}
+			if task.matrix != nil {
+				item.Combinations = planCombinations(task)
+			}
+			// 最晚完成的依赖决定最早开始时间，也是关键路径上的前一个任务
+			for _, dep := range task.dependsOn {
+				stage[task.name] = max(stage[task.name], stage[dep]+1)
+				if prev := previous[task.name]; prev == "" || finish[dep] > finish[prev] ||
+					(finish[dep] == finish[prev] && stage[dep] > stage[prev]) {
+					previous[task.name] = dep
+				}
+			}
+			if prev := previous[task.name]; prev != "" {
+				item.EarliestStart = finish[prev]
+			}
+			if item.Samples > 0 {
+				item.EstimatedDuration = median(history[task.name]) * planBatches(task)
+			}
+			finish[task.name] = item.EarliestStart + item.EstimatedDuration
+			planned[task.name] = item
+			progressed = true
+		}
+		if !progressed {
+			break
+		}
+	}
+
+	// 关键路径从最晚结束的任务沿最晚完成的依赖回溯，时长相同时取阶段更深的任务
+	var last string
+	for _, task := range sorted {
+		if planned[task.name] == nil {
+			continue
+		}
+		if last == "" || finish[task.name] > finish[last] ||
+			(finish[task.name] == finish[last] && stage[task.name] > stage[last]) {
+			last = task.name
+		}
+	}
+	critical := make(map[string]bool)
+	for name := last; name != ""; name = previous[name] {
+		critical[name] = true
+		plan.CriticalPath = append([]string{name}, plan.CriticalPath...)
+	}
+	if last != "" {
+		plan.EstimatedDuration = finish[last]
+	}
+
+	for _, task := range sorted {
+		if item := planned[task.name]; item != nil {
+			item.Critical = critical[task.name]
+			if item.Samples == 0 {
+				plan.MissingHistory = append(plan.MissingHistory, task.name)
+			}
+			index := stage[task.name]
+			for len(plan.Stages) <= index {
+				plan.Stages = append(plan.Stages, PlanStage{Index: len(plan.Stages) + 1, Tasks: []PlanTask{}})
+			}
+			plan.Stages[index].Tasks = append(plan.Stages[index].Tasks, *item)
+		}
+	}
+	return plan
+}
+
+// planCombinations 任务每次运行展开的任务运行数，非矩阵任务为1
+func planCombinations(task planTask) int {
+	if task.matrix == nil {
+		return 1
+	}
+	return max(len(task.matrix.Combinations()), 1)
+}
+
+// planBatches 矩阵任务受 max_parallel 限制需要分几批执行，不考虑执行器自身的并发限制
+func planBatches(task planTask) int {
+	combinations := planCombinations(task)
+	if task.matrix == nil || task.matrix.MaxParallel <= 0 || task.matrix.MaxParallel >= combinations {
+		return 1
+	}
+	return (combinations + task.matrix.MaxParallel - 1) / task.matrix.MaxParallel
+}
+
+// median 时长中位数，偶数个时取较小的一个
+func median(values []int) int {
+	sorted := append([]int{}, values...)
+	sort.Ints(sorted)
+	return sorted[(len(sorted)-1)/2]
+}
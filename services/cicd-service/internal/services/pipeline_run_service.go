@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
 	"cicd-service/internal/config"
@@ -21,26 +22,38 @@ type PipelineRunService interface {
 	Cancel(id uuid.UUID, reason string) error
 	Retry(id uuid.UUID) (*models.PipelineRun, error)
 	UpdateStatus(id uuid.UUID, status string, message *string) error
+	UpdateTaskRunStatus(id uuid.UUID, update *TaskRunStatusUpdate) error
 	List(req *ListPipelineRunsRequest) ([]models.PipelineRun, int64, error)
 	GetStatistics(req *PipelineRunStatsRequest) (*PipelineRunStats, error)
 	CleanupExpiredRuns() error
+	DispatchQueue() (int, error)
+	SetDeploymentGate(gate DeploymentGate)
 }
 
 type pipelineRunService struct {
 	db            *gorm.DB
 	config        *config.Config
-	tektonService TektonService
+	executor      Executor
+	gitGateway    GitGatewayClient
+	gate          DeploymentGate
+	queueMu       sync.Mutex // 串行化本实例的出队
 }
 
 // NewPipelineRunService 创建流水线运行服务实例
-func NewPipelineRunService(db *gorm.DB, cfg *config.Config, tektonSvc TektonService) PipelineRunService {
+func NewPipelineRunService(db *gorm.DB, cfg *config.Config, executor Executor, gitGateway GitGatewayClient) PipelineRunService {
 	return &pipelineRunService{
 		db:            db,
 		config:        cfg,
-		tektonService: tektonSvc,
+		executor:      executor,
+		gitGateway:    gitGateway,
 	}
 }
 
+// SetDeploymentGate 注入部署审批检查，传给执行器用于部署任务开始前的审批
+func (s *pipelineRunService) SetDeploymentGate(gate DeploymentGate) {
+	s.gate = gate
+}
+
 // CreatePipelineRunRequest 创建流水线运行请求
 type CreatePipelineRunRequest struct {
 	PipelineID    uuid.UUID              `json:"pipeline_id" validate:"required"`
@@ -50,6 +63,19 @@ type CreatePipelineRunRequest struct {
 	Parameters    map[string]interface{} `json:"parameters"`
 	Environment   *string                `json:"environment"`
 	ScheduledAt   *time.Time            `json:"scheduled_at"`
+	CommitSHA     *string                `json:"commit_sha"` // 构建的提交，缺省取trigger_data.commit_sha
+	Branch        *string                `json:"branch"`     // 构建的分支或标签，缺省取trigger_data.ref
+	RollbackOf    *uuid.UUID             `json:"-"`          // 回滚的部署，运行只执行该部署的任务
+	Priority      *int                   `json:"priority"`   // 排队优先级(0-100)，缺省使用流水线配置
+}
+
+// TaskRunStatusUpdate 任务运行状态回写
+type TaskRunStatusUpdate struct {
+	Status       string  `json:"status"`
+	ExitCode     *int    `json:"exit_code"`
+	PodName      *string `json:"pod_name"`
+	ErrorMessage *string `json:"error_message"`
+	RetryCount   *int    `json:"retry_count"`
 }
 
 // ListPipelineRunsRequest 列表查询请求
@@ -82,6 +108,7 @@ type PipelineRunStats struct {
 	SuccessfulRuns    int64                    `json:"successful_runs"`
 	FailedRuns        int64                    `json:"failed_runs"`
 	CancelledRuns     int64                    `json:"cancelled_runs"`
+	QueuedRuns        int64                    `json:"queued_runs"`
 	PendingRuns       int64                    `json:"pending_runs"`
 	RunningRuns       int64                    `json:"running_runs"`
 	SuccessRate       float64                  `json:"success_rate"`
@@ -131,24 +158,37 @@ func (s *pipelineRunService) Create(req *CreatePipelineRunRequest) (*models.Pipe
 		return nil, fmt.Errorf("流水线已禁用，无法运行")
 	}
 
-	// 检查并发运行限制
-	var runningCount int64
-	if err := s.db.Model(&models.PipelineRun{}).
-		Where("pipeline_id = ? AND status IN (?)", req.PipelineID, []string{"pending", "running"}).
-		Count(&runningCount).Error; err != nil {
-		return nil, fmt.Errorf("检查并发运行失败: %w", err)
-	}
-
-	if runningCount >= int64(s.config.Tekton.MaxConcurrentRuns) {
-		return nil, fmt.Errorf("已达到最大并发运行限制(%d)", s.config.Tekton.MaxConcurrentRuns)
+	if req.Priority != nil && (*req.Priority < 0 || *req.Priority > maxRunPriority) {
+		return nil, fmt.Errorf("优先级必须在0到%d之间", maxRunPriority)
 	}
 
-	// 创建流水线运行记录
+	// 创建流水线运行记录，运行先进入队列，由出队按并发上限启动
+	now := time.Now()
 	pipelineRun := &models.PipelineRun{
 		PipelineID:  req.PipelineID,
-		Status:      "pending",
+		Status:      "queued",
+		QueuedAt:    &now,
 		TriggerType: req.TriggerType,
 		TriggerBy:   req.TriggerBy,
+		CommitSHA:   req.CommitSHA,
+		Branch:      req.Branch,
+		RollbackOf:  req.RollbackOf,
+	}
+	if pipelineRun.CommitSHA == nil {
+		pipelineRun.CommitSHA = triggerDataString(req.TriggerData, "commit_sha")
+	}
+	if pipelineRun.Branch == nil {
+		pipelineRun.Branch = triggerDataString(req.TriggerData, "ref")
+	}
+
+	// 定义文件维护的流水线使用构建提交中的定义，而不是数据库中的任务
+	runPipeline := &pipeline
+	if pipeline.DefinitionFilePath != nil && pipeline.RepositoryID != nil {
+		definitionPipeline, err := s.loadRunDefinition(&pipeline, pipelineRun, req.TriggerBy)
+		if err != nil {
+			return nil, err
+		}
+		runPipeline = definitionPipeline
 	}
 
 	// 处理触发数据
@@ -160,49 +200,147 @@ func (s *pipelineRunService) Create(req *CreatePipelineRunRequest) (*models.Pipe
 		pipelineRun.TriggerData = triggerDataJSON
 	}
 
+	// 保存运行参数，出队启动时使用
+	if req.Parameters != nil {
+		parametersJSON, err := json.Marshal(req.Parameters)
+		if err != nil {
+			return nil, fmt.Errorf("序列化运行参数失败: %w", err)
+		}
+		pipelineRun.Parameters = parametersJSON
+	}
+
+	// 优先级和并发组取本次运行的流水线配置（定义文件维护的流水线取提交中的定义）
+	pipelineRun.Priority = runPipeline.Config.Priority
+	if req.Priority != nil {
+		pipelineRun.Priority = *req.Priority
+	}
+	pipelineRun.ConcurrencyGroup = runConcurrencyGroup(runPipeline, pipelineRun.Branch)
+
 	// 保存到数据库
 	if err := s.db.Create(pipelineRun).Error; err != nil {
 		return nil, fmt.Errorf("创建流水线运行记录失败: %w", err)
 	}
 
-	// 异步启动流水线
-	go s.startPipelineRunAsync(pipelineRun, &pipeline, req.Parameters)
+	if pipelineRun.ConcurrencyGroup != nil && runPipeline.Config.CancelInProgress {
+		s.supersedeRuns(pipelineRun)
+	}
+
+	// 立即尝试出队，未达到并发上限时运行直接开始
+	s.dispatchQueue()
+	var current models.PipelineRun
+	if err := s.db.Select("status").Where("id = ?", pipelineRun.ID).First(&current).Error; err == nil {
+		pipelineRun.Status = current.Status
+	}
+	runs := []models.PipelineRun{*pipelineRun}
+	s.fillQueuePositions(runs)
+	pipelineRun.QueuePosition = runs[0].QueuePosition
 
 	return pipelineRun, nil
 }
 
+// loadRunDefinition 读取构建提交中的流水线定义，记录快照和解析后的提交SHA
+func (s *pipelineRunService) loadRunDefinition(pipeline *models.Pipeline, run *models.PipelineRun,
+	triggerBy *uuid.UUID) (*models.Pipeline, error) {
+	ref := ""
+	if run.CommitSHA != nil {
+		ref = *run.CommitSHA
+	} else if run.Branch != nil {
+		ref = *run.Branch
+	}
+
+	userID := uuid.Nil
+	if triggerBy != nil {
+		userID = *triggerBy
+	}
+
+	definition, file, err := loadPipelineDefinition(s.gitGateway, *pipeline.RepositoryID, ref, *pipeline.DefinitionFilePath, userID)
+	if err != nil {
+		return nil, fmt.Errorf("加载流水线定义失败: %w", err)
+	}
+
+	runPipeline, err := definition.BuildPipeline(pipeline, s.config.Tekton.DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := json.Marshal(definition)
+	if err != nil {
+		return nil, fmt.Errorf("序列化流水线定义失败: %w", err)
+	}
+	run.Definition = snapshot
+	run.CommitSHA = &file.CommitSHA
+
+	return runPipeline, nil
+}
+
+// triggerDataString 读取触发数据中的字符串字段
+func triggerDataString(data map[string]interface{}, key string) *string {
+	if value, ok := data[key].(string); ok && value != "" {
+		return &value
+	}
+	return nil
+}
+
 // startPipelineRunAsync 异步启动流水线
 func (s *pipelineRunService) startPipelineRunAsync(run *models.PipelineRun, pipeline *models.Pipeline, params map[string]interface{}) {
 	ctx := context.Background()
 
-	// 更新状态为运行中
-	s.UpdateStatus(run.ID, "running", nil)
+	// 条件更新为运行中：运行在出队后、启动前可能已被取消或超时重新排队，只有一个调用者能启动
 	startTime := time.Now()
-	s.db.Model(run).Update("started_at", startTime)
-
-	// 创建Tekton PipelineRun
-	tektonRun := &TektonPipelineRunRequest{
-		Name:        fmt.Sprintf("run-%s-%d", run.ID.String()[:8], run.RunNumber),
-		PipelineID:  pipeline.ID,
-		RunID:       run.ID,
-		Parameters:  params,
-		Timeout:     pipeline.Config.Timeout,
-		Workspace:   pipeline.Config.Workspace,
-		ServiceAccount: pipeline.Config.ServiceAccount,
-	}
-
-	// 提交到Tekton
-	if err := s.tektonService.CreatePipelineRun(ctx, tektonRun); err != nil {
-		s.UpdateStatus(run.ID, "failed", &err.Error())
+	result := s.db.Model(&models.PipelineRun{}).
+		Where("id = ? AND status = ?", run.ID, "pending").
+		Updates(map[string]interface{}{
+			"status":     "running",
+			"started_at": startTime,
+			"updated_at": startTime,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+	run.Status = "running"
+	run.StartedAt = &startTime
+	s.recordPipelineLastRun(run.ID)
+
+	// 矩阵任务按组合展开，每个组合一个任务运行
+	pipeline, matrix, err := expandPipelineMatrix(pipeline)
+	if err == nil && run.RollbackOf != nil {
+		// 回滚只重新执行原部署的任务
+		var deployment models.Deployment
+		if err = s.db.Where("id = ?", *run.RollbackOf).First(&deployment).Error; err == nil {
+			pipeline, err = rollbackPipeline(pipeline, &deployment)
+		} else {
+			err = fmt.Errorf("获取回滚的部署失败: %w", err)
+		}
+	}
+	if err != nil {
+		message := err.Error()
+		s.UpdateStatus(run.ID, "failed", &message)
 		return
 	}
 
-	// 创建任务运行记录
-	s.createTaskRuns(run, pipeline.Tasks)
+	// 先创建任务运行记录，TaskRun通过记录ID标签回写状态
+	taskRunIDs := s.createTaskRuns(run, pipeline.Tasks, matrix)
+
+	// 提交到执行器
+	execReq := &ExecutionRequest{
+		Run:        run,
+		Pipeline:   pipeline,
+		Parameters: params,
+		TaskRunIDs: taskRunIDs,
+		Matrix:     matrix,
+		Reporter:   s,
+		Gate:       s.gate,
+	}
+	if err := s.executor.Execute(ctx, execReq); err != nil {
+		message := err.Error()
+		s.UpdateStatus(run.ID, "failed", &message)
+		return
+	}
 }
 
-// createTaskRuns 创建任务运行记录
-func (s *pipelineRunService) createTaskRuns(pipelineRun *models.PipelineRun, tasks []models.Task) {
+// createTaskRuns 创建任务运行记录，返回任务名到记录ID的映射
+func (s *pipelineRunService) createTaskRuns(pipelineRun *models.PipelineRun, tasks []models.Task, matrix map[string]*MatrixCombination) map[string]uuid.UUID {
+	taskRunIDs := make(map[string]uuid.UUID, len(tasks))
 	for _, task := range tasks {
 		taskRun := &models.TaskRun{
 			PipelineRunID: pipelineRun.ID,
@@ -210,9 +348,19 @@ func (s *pipelineRunService) createTaskRuns(pipelineRun *models.PipelineRun, tas
 			Name:          task.Name,
 			Status:        "pending",
 		}
+		if combination, ok := matrix[task.Name]; ok {
+			valuesJSON, err := jsonMarshal(combination.Values)
+			if err == nil {
+				taskRun.MatrixTask = &combination.Task
+				taskRun.MatrixValues = valuesJSON
+			}
+		}
 
-		s.db.Create(taskRun)
+		if err := s.db.Create(taskRun).Error; err == nil {
+			taskRunIDs[task.Name] = taskRun.ID
+		}
 	}
+	return taskRunIDs
 }
 
 // GetByID 根据ID获取流水线运行
@@ -230,7 +378,9 @@ func (s *pipelineRunService) GetByID(id uuid.UUID) (*models.PipelineRun, error)
 		}
 		return nil, fmt.Errorf("获取流水线运行失败: %w", err)
 	}
-	return &pipelineRun, nil
+	runs := []models.PipelineRun{pipelineRun}
+	s.fillQueuePositions(runs)
+	return &runs[0], nil
 }
 
 // GetByPipeline 获取流水线的运行历史
@@ -257,14 +407,33 @@ func (s *pipelineRunService) Cancel(id uuid.UUID, reason string) error {
 	}
 
 	// 检查状态
-	if run.Status != "pending" && run.Status != "running" {
+	if run.Status != "queued" && run.Status != "pending" && run.Status != "running" {
 		return fmt.Errorf("流水线运行状态为 %s，无法取消", run.Status)
 	}
 
-	// 取消Tekton PipelineRun
+	// 排队中的运行尚未提交到执行器，直接标记为取消；出队后按执行中的运行取消
+	if run.Status == "queued" {
+		now := time.Now()
+		result := s.db.Model(&models.PipelineRun{}).
+			Where("id = ? AND status = ?", id, "queued").
+			Updates(map[string]interface{}{
+				"status":        "cancelled",
+				"error_message": reason,
+				"finished_at":   now,
+				"updated_at":    now,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("取消排队运行失败: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+	}
+
+	// 通知执行器终止运行
 	ctx := context.Background()
-	if err := s.tektonService.CancelPipelineRun(ctx, run.ID); err != nil {
-		return fmt.Errorf("取消Tekton流水线运行失败: %w", err)
+	if err := s.executor.Cancel(ctx, run.ID); err != nil {
+		return err
 	}
 
 	// 更新状态
@@ -291,12 +460,23 @@ func (s *pipelineRunService) Retry(id uuid.UUID) (*models.PipelineRun, error) {
 		}
 	}
 
+	var parameters map[string]interface{}
+	if len(originalRun.Parameters) > 0 {
+		if err := json.Unmarshal(originalRun.Parameters, &parameters); err != nil {
+			return nil, fmt.Errorf("解析运行参数失败: %w", err)
+		}
+	}
+
 	// 创建重试请求
 	retryReq := &CreatePipelineRunRequest{
 		PipelineID:  originalRun.PipelineID,
 		TriggerType: "manual", // 重试总是手动触发
 		TriggerBy:   originalRun.TriggerBy,
 		TriggerData: triggerData,
+		Parameters:  parameters,
+		CommitSHA:   originalRun.CommitSHA, // 重试同一提交，使用相同版本的定义
+		Branch:      originalRun.Branch,
+		Priority:    &originalRun.Priority,
 	}
 
 	return s.Create(retryReq)
@@ -333,14 +513,63 @@ func (s *pipelineRunService) UpdateStatus(id uuid.UUID, status string, message *
 		return fmt.Errorf("更新流水线运行状态失败: %w", err)
 	}
 
-	// 更新流水线的最后运行信息
+	// 运行结束释放并发额度，启动排队中的运行
+	if _, ok := updates["finished_at"]; ok {
+		go s.dispatchQueue()
+	}
+
 	if status == "running" {
-		s.db.Model(&models.Pipeline{}).
-			Where("id = (SELECT pipeline_id FROM pipeline_runs WHERE id = ?)", id).
-			Updates(map[string]interface{}{
-				"last_run_id": id,
-				"last_run_at": time.Now(),
-			})
+		s.recordPipelineLastRun(id)
+	}
+
+	return nil
+}
+
+// recordPipelineLastRun 更新流水线的最后运行信息
+func (s *pipelineRunService) recordPipelineLastRun(id uuid.UUID) {
+	s.db.Model(&models.Pipeline{}).
+		Where("id = (SELECT pipeline_id FROM pipeline_runs WHERE id = ?)", id).
+		Updates(map[string]interface{}{
+			"last_run_id": id,
+			"last_run_at": time.Now(),
+		})
+}
+
+// UpdateTaskRunStatus 更新任务运行状态
+func (s *pipelineRunService) UpdateTaskRunStatus(id uuid.UUID, update *TaskRunStatusUpdate) error {
+	updates := map[string]interface{}{
+		"status":     update.Status,
+		"updated_at": time.Now(),
+	}
+
+	switch update.Status {
+	case "running":
+		updates["started_at"] = gorm.Expr("COALESCE(started_at, ?)", time.Now())
+	case "succeeded", "failed", "cancelled", "skipped":
+		now := time.Now()
+		updates["finished_at"] = now
+
+		var taskRun models.TaskRun
+		if err := s.db.Where("id = ?", id).First(&taskRun).Error; err == nil && taskRun.StartedAt != nil {
+			updates["duration"] = int(now.Sub(*taskRun.StartedAt).Seconds())
+		}
+	}
+
+	if update.ExitCode != nil {
+		updates["exit_code"] = *update.ExitCode
+	}
+	if update.PodName != nil {
+		updates["pod_name"] = *update.PodName
+	}
+	if update.ErrorMessage != nil {
+		updates["error_message"] = *update.ErrorMessage
+	}
+	if update.RetryCount != nil {
+		updates["retry_count"] = *update.RetryCount
+	}
+
+	if err := s.db.Model(&models.TaskRun{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		return fmt.Errorf("更新任务运行状态失败: %w", err)
 	}
 
 	return nil
@@ -409,6 +638,7 @@ func (s *pipelineRunService) List(req *ListPipelineRunsRequest) ([]models.Pipeli
 	if err := query.Preload("Pipeline").Find(&runs).Error; err != nil {
 		return nil, 0, fmt.Errorf("查询流水线运行列表失败: %w", err)
 	}
+	s.fillQueuePositions(runs)
 
 	return runs, total, nil
 }
@@ -453,6 +683,10 @@ func (s *pipelineRunService) GetStatistics(req *PipelineRunStatsRequest) (*Pipel
 		return nil, fmt.Errorf("统计取消运行次数失败: %w", err)
 	}
 
+	if err := query.Where("status = ?", "queued").Count(&stats.QueuedRuns).Error; err != nil {
+		return nil, fmt.Errorf("统计排队运行次数失败: %w", err)
+	}
+
 	if err := query.Where("status = ?", "pending").Count(&stats.PendingRuns).Error; err != nil {
 		return nil, fmt.Errorf("统计待运行次数失败: %w", err)
 	}
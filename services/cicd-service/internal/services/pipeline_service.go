@@ -1,7 +1,10 @@
 package services
 
 import (
+	"encoding/json"
 	"fmt"
+	"path"
+	"strings"
 	"time"
 
 	"cicd-service/internal/config"
@@ -24,24 +27,32 @@ type PipelineService interface {
 	List(req *ListPipelinesRequest) ([]models.Pipeline, int64, error)
 	GetStatistics(projectID *uuid.UUID) (*PipelineStats, error)
 	ValidateConfig(config interface{}) error
+	ImportFromRepository(req *ImportPipelineRequest) (*models.Pipeline, error)
+	Plan(id uuid.UUID) (*ExecutionPlan, error)
+	DryRun(req *CreatePipelineRequest) (*ExecutionPlan, error)
 }
 
 type pipelineService struct {
-	db     *gorm.DB
-	config *config.Config
+	db         *gorm.DB
+	config     *config.Config
+	gitGateway GitGatewayClient
 }
 
 // NewPipelineService 创建流水线服务实例
-func NewPipelineService(db *gorm.DB, cfg *config.Config) PipelineService {
+func NewPipelineService(db *gorm.DB, cfg *config.Config, gitGateway GitGatewayClient) PipelineService {
 	return &pipelineService{
-		db:     db,
-		config: cfg,
+		db:         db,
+		config:     cfg,
+		gitGateway: gitGateway,
 	}
 }
 
 // CreatePipelineRequest 创建流水线请求
 type CreatePipelineRequest struct {
 	ProjectID   uuid.UUID               `json:"project_id" validate:"required"`
+	RepositoryID       *uuid.UUID       `json:"-"` // 仅由定义文件导入设置
+	DefinitionFilePath *string          `json:"-"`
+
 	Name        string                  `json:"name" validate:"required,max=255"`
 	Description *string                 `json:"description"`
 	Config      models.PipelineConfig   `json:"config"`
@@ -50,6 +61,15 @@ type CreatePipelineRequest struct {
 	Tasks       []CreateTaskRequest     `json:"tasks" validate:"required,min=1"`
 }
 
+// ImportPipelineRequest 从仓库定义文件导入流水线请求
+type ImportPipelineRequest struct {
+	ProjectID          uuid.UUID `json:"project_id" binding:"required"`
+	RepositoryID       uuid.UUID `json:"repository_id" binding:"required"`
+	DefinitionFilePath string    `json:"definition_file_path"` // 默认使用配置中的定义文件
+	Ref                string    `json:"ref"`                  // 分支、标签或提交SHA，默认为仓库默认分支
+	UserID             uuid.UUID `json:"-"`
+}
+
 // UpdatePipelineRequest 更新流水线请求
 type UpdatePipelineRequest struct {
 	Name        *string                 `json:"name" validate:"omitempty,max=255"`
@@ -76,11 +96,17 @@ type CreateTaskRequest struct {
 	Order       int                    `json:"order"`
 	Timeout     int                    `json:"timeout" validate:"min=1,max=7200"`
 	Retries     int                    `json:"retries" validate:"min=0,max=5"`
+	RunnerTags  []string               `json:"runner_tags"` // 自托管执行器需具备的标签
+	Artifacts   *ArtifactSpec          `json:"artifacts"`      // 产出的构建产物
+	ArtifactsFrom []ArtifactDependency `json:"artifacts_from"` // 执行前下载的构建产物
+	Secrets     []SecretReference      `json:"secrets"`        // 注入环境变量的项目密钥
+	Matrix      *TaskMatrix            `json:"matrix"`         // 矩阵配置，按组合展开为多个任务运行
+	Environment *string                `json:"environment"`    // 部署的目标环境，受环境保护规则约束
 }
 
 // TriggerConfig 触发器配置
 type TriggerConfig struct {
-	Type       string                 `json:"type" validate:"required,oneof=webhook push tag schedule manual"`
+	Type       string                 `json:"type" validate:"required,oneof=webhook push tag pull_request schedule manual"`
 	Conditions map[string]interface{} `json:"conditions"`
 	Enabled    bool                   `json:"enabled"`
 }
@@ -93,6 +119,40 @@ type VolumeMount struct {
 	ReadOnly  bool   `json:"read_only"`
 }
 
+// ArtifactSpec 任务产出的构建产物，paths 为相对工作空间的文件、目录或通配符（支持 **）
+type ArtifactSpec struct {
+	Name         string   `yaml:"name" json:"name,omitempty"`                     // 产物名称，缺省为任务名
+	Paths        []string `yaml:"paths" json:"paths"`                             // 收集的路径
+	When         string   `yaml:"when" json:"when,omitempty"`                     // on_success（默认）, on_failure, always
+	ExpireInDays int      `yaml:"expire_in_days" json:"expire_in_days,omitempty"` // 保留天数，缺省使用流水线配置
+}
+
+// ArtifactDependency 任务执行前下载到工作空间的构建产物。
+// pipeline 为空时取本次运行中 task 的产物，否则取同项目流水线（ID或名称）最近一次成功运行的产物
+type ArtifactDependency struct {
+	Task     string     `yaml:"task" json:"task"`                   // 产出产物的任务名
+	Pipeline string     `yaml:"pipeline" json:"pipeline,omitempty"` // 其他流水线的ID或名称
+	Branch   string     `yaml:"branch" json:"branch,omitempty"`     // 只取该分支的运行
+	Name     string     `yaml:"name" json:"name,omitempty"`         // 只取该名称的产物
+	Target   string     `yaml:"target" json:"target,omitempty"`     // 下载到工作空间中的目录，缺省为根目录
+	RunID    *uuid.UUID `yaml:"-" json:"run_id,omitempty"`          // 固定取该运行的产物，部署回滚时指向原部署使用的产物
+}
+
+// SecretReference 任务引用的项目密钥，运行时解密后注入环境变量。
+// key 为空时注入密钥的全部数据键（环境变量与键同名），否则只注入该键，env 可指定环境变量名
+type SecretReference struct {
+	Name string `yaml:"name" json:"name"`                   // 密钥名称
+	Key  string `yaml:"key" json:"key,omitempty"`           // 数据键
+	Env  string `yaml:"env" json:"env,omitempty"`           // 环境变量名，缺省与键同名
+}
+
+// 产物收集时机
+const (
+	ArtifactWhenOnSuccess = "on_success"
+	ArtifactWhenOnFailure = "on_failure"
+	ArtifactWhenAlways    = "always"
+)
+
 // ListPipelinesRequest 列表查询请求
 type ListPipelinesRequest struct {
 	ProjectID *uuid.UUID `json:"project_id"`
@@ -150,18 +210,25 @@ func (s *pipelineService) Create(req *CreatePipelineRequest) (*models.Pipeline,
 	return s.createPipelineWithTasks(req)
 }
 
-// createPipelineWithTasks 创建流水线及任务（事务）
+// createPipelineWithTasks 校验定义后创建流水线及任务（事务），创建、克隆和导入共用，
+// 依赖不存在、成环或无法执行的任务在写入前拒绝，而不是等到提交Tekton时才失败
 func (s *pipelineService) createPipelineWithTasks(req *CreatePipelineRequest) (*models.Pipeline, error) {
+	if err := s.ValidateConfig(req); err != nil {
+		return nil, err
+	}
+
 	var pipeline *models.Pipeline
 	
 	err := s.db.Transaction(func(tx *gorm.DB) error {
 		// 创建流水线
 		pipeline = &models.Pipeline{
-			ProjectID:   req.ProjectID,
-			Name:        req.Name,
-			Description: req.Description,
-			Status:      "active",
-			Config:      req.Config,
+			ProjectID:          req.ProjectID,
+			RepositoryID:       req.RepositoryID,
+			DefinitionFilePath: req.DefinitionFilePath,
+			Name:               req.Name,
+			Description:        req.Description,
+			Status:             "active",
+			Config:             req.Config,
 		}
 
 		// 处理触发器
@@ -187,54 +254,8 @@ func (s *pipelineService) createPipelineWithTasks(req *CreatePipelineRequest) (*
 		}
 
 		// 创建任务
-		for i, taskReq := range req.Tasks {
-			task := &models.Task{
-				PipelineID:  pipeline.ID,
-				Name:        taskReq.Name,
-				Description: taskReq.Description,
-				Type:        taskReq.Type,
-				Image:       taskReq.Image,
-				Command:     taskReq.Command,
-				Args:        taskReq.Args,
-				WorkingDir:  taskReq.WorkingDir,
-				DependsOn:   taskReq.DependsOn,
-				Condition:   taskReq.Condition,
-				Order:       taskReq.Order,
-				Timeout:     taskReq.Timeout,
-				Retries:     taskReq.Retries,
-			}
-
-			// 处理环境变量
-			if taskReq.Env != nil {
-				envJSON, err := jsonMarshal(taskReq.Env)
-				if err != nil {
-					return fmt.Errorf("序列化任务环境变量失败: %w", err)
-				}
-				task.Env = envJSON
-			}
-
-			// 处理卷挂载
-			if taskReq.Volumes != nil {
-				volumesJSON, err := jsonMarshal(taskReq.Volumes)
-				if err != nil {
-					return fmt.Errorf("序列化任务卷挂载失败: %w", err)
-				}
-				task.Volumes = volumesJSON
-			}
-
-			// 设置默认超时时间
-			if task.Timeout == 0 {
-				task.Timeout = s.config.Tekton.DefaultTimeout
-			}
-
-			// 设置默认顺序
-			if task.Order == 0 {
-				task.Order = i + 1
-			}
-
-			if err := tx.Create(task).Error; err != nil {
-				return fmt.Errorf("创建任务失败: %w", err)
-			}
+		if err := s.createTasks(tx, pipeline, req.Tasks); err != nil {
+			return err
 		}
 
 		return nil
@@ -248,6 +269,138 @@ func (s *pipelineService) createPipelineWithTasks(req *CreatePipelineRequest) (*
 	return s.GetByID(pipeline.ID)
 }
 
+// createTasks 创建流水线任务，定义文件导入的任务使用确定性ID
+func (s *pipelineService) createTasks(tx *gorm.DB, pipeline *models.Pipeline, tasks []CreateTaskRequest) error {
+	for i := range tasks {
+		task, err := newTaskModel(pipeline.ID, &tasks[i], s.config.Tekton.DefaultTimeout)
+		if err != nil {
+			return err
+		}
+
+		// 设置默认顺序
+		if task.Order == 0 {
+			task.Order = i + 1
+		}
+
+		if pipeline.DefinitionFilePath != nil {
+			task.ID = DefinitionTaskID(pipeline.ID, task.Name)
+		}
+
+		if err := tx.Create(task).Error; err != nil {
+			return fmt.Errorf("创建任务失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// newTaskModel 根据任务请求构建任务模型
+func newTaskModel(pipelineID uuid.UUID, taskReq *CreateTaskRequest, defaultTimeout int) (*models.Task, error) {
+	task := &models.Task{
+		PipelineID:  pipelineID,
+		Name:        taskReq.Name,
+		Description: taskReq.Description,
+		Type:        taskReq.Type,
+		Image:       taskReq.Image,
+		Command:     taskReq.Command,
+		Args:        taskReq.Args,
+		WorkingDir:  taskReq.WorkingDir,
+		DependsOn:   taskReq.DependsOn,
+		Condition:   taskReq.Condition,
+		Order:       taskReq.Order,
+		Timeout:     taskReq.Timeout,
+		Retries:     taskReq.Retries,
+		Environment: taskReq.Environment,
+	}
+
+	// 处理环境变量
+	if taskReq.Env != nil {
+		envJSON, err := jsonMarshal(taskReq.Env)
+		if err != nil {
+			return nil, fmt.Errorf("序列化任务环境变量失败: %w", err)
+		}
+		task.Env = envJSON
+	}
+
+	// 处理卷挂载
+	if taskReq.Volumes != nil {
+		volumesJSON, err := jsonMarshal(taskReq.Volumes)
+		if err != nil {
+			return nil, fmt.Errorf("序列化任务卷挂载失败: %w", err)
+		}
+		task.Volumes = volumesJSON
+	}
+
+	// 处理执行器标签
+	if taskReq.RunnerTags != nil {
+		tagsJSON, err := jsonMarshal(taskReq.RunnerTags)
+		if err != nil {
+			return nil, fmt.Errorf("序列化任务执行器标签失败: %w", err)
+		}
+		task.RunnerTags = tagsJSON
+	}
+
+	// 处理构建产物
+	if taskReq.Artifacts != nil {
+		artifactsJSON, err := jsonMarshal(taskReq.Artifacts)
+		if err != nil {
+			return nil, fmt.Errorf("序列化任务构建产物失败: %w", err)
+		}
+		task.Artifacts = artifactsJSON
+	}
+	if taskReq.ArtifactsFrom != nil {
+		dependenciesJSON, err := jsonMarshal(taskReq.ArtifactsFrom)
+		if err != nil {
+			return nil, fmt.Errorf("序列化任务产物依赖失败: %w", err)
+		}
+		task.ArtifactsFrom = dependenciesJSON
+	}
+
+	// 处理密钥引用
+	if taskReq.Secrets != nil {
+		secretsJSON, err := jsonMarshal(taskReq.Secrets)
+		if err != nil {
+			return nil, fmt.Errorf("序列化任务密钥引用失败: %w", err)
+		}
+		task.Secrets = secretsJSON
+	}
+
+	// 处理矩阵配置
+	if taskReq.Matrix != nil {
+		matrixJSON, err := jsonMarshal(taskReq.Matrix)
+		if err != nil {
+			return nil, fmt.Errorf("序列化任务矩阵配置失败: %w", err)
+		}
+		task.Matrix = matrixJSON
+	}
+
+	// 设置默认超时时间
+	if task.Timeout == 0 {
+		task.Timeout = defaultTimeout
+	}
+
+	return task, nil
+}
+
+// pipelineConfigColumns 流水线配置对应的列（配置以embedded方式存储）
+func pipelineConfigColumns(cfg *models.PipelineConfig) map[string]interface{} {
+	return map[string]interface{}{
+		"timeout":               cfg.Timeout,
+		"retries":               cfg.Retries,
+		"workspace":             cfg.Workspace,
+		"service_account":       cfg.ServiceAccount,
+		"node_selector":         cfg.NodeSelector,
+		"resource_limits":       cfg.ResourceLimits,
+		"enable_cache":          cfg.EnableCache,
+		"cache_keys":            cfg.CacheKeys,
+		"notification_channels": cfg.NotificationChannels,
+		"artifact_expire_days":  cfg.ArtifactExpireDays,
+		"keep_latest_artifacts": cfg.KeepLatestArtifacts,
+		"priority":              cfg.Priority,
+		"concurrency_group":     cfg.ConcurrencyGroup,
+		"cancel_in_progress":    cfg.CancelInProgress,
+	}
+}
+
 // GetByID 根据ID获取流水线
 func (s *pipelineService) GetByID(id uuid.UUID) (*models.Pipeline, error) {
 	var pipeline models.Pipeline
@@ -282,6 +435,10 @@ func (s *pipelineService) Update(id uuid.UUID, req *UpdatePipelineRequest) (*mod
 		return nil, err
 	}
 
+	if err := s.ValidateConfig(req); err != nil {
+		return nil, err
+	}
+
 	return s.updatePipelineWithTasks(pipeline, req)
 }
 
@@ -305,7 +462,9 @@ func (s *pipelineService) updatePipelineWithTasks(pipeline *models.Pipeline, req
 		}
 		
 		if req.Config != nil {
-			updates["config"] = *req.Config
+			for column, value := range pipelineConfigColumns(req.Config) {
+				updates[column] = value
+			}
 		}
 
 		if req.Triggers != nil {
@@ -338,52 +497,8 @@ func (s *pipelineService) updatePipelineWithTasks(pipeline *models.Pipeline, req
 			}
 
 			// 创建新任务
-			for i, taskReq := range req.Tasks {
-				task := &models.Task{
-					PipelineID:  pipeline.ID,
-					Name:        taskReq.Name,
-					Description: taskReq.Description,
-					Type:        taskReq.Type,
-					Image:       taskReq.Image,
-					Command:     taskReq.Command,
-					Args:        taskReq.Args,
-					WorkingDir:  taskReq.WorkingDir,
-					DependsOn:   taskReq.DependsOn,
-					Condition:   taskReq.Condition,
-					Order:       taskReq.Order,
-					Timeout:     taskReq.Timeout,
-					Retries:     taskReq.Retries,
-				}
-
-				// 处理环境变量
-				if taskReq.Env != nil {
-					envJSON, err := jsonMarshal(taskReq.Env)
-					if err != nil {
-						return fmt.Errorf("序列化任务环境变量失败: %w", err)
-					}
-					task.Env = envJSON
-				}
-
-				// 处理卷挂载
-				if taskReq.Volumes != nil {
-					volumesJSON, err := jsonMarshal(taskReq.Volumes)
-					if err != nil {
-						return fmt.Errorf("序列化任务卷挂载失败: %w", err)
-					}
-					task.Volumes = volumesJSON
-				}
-
-				// 设置默认值
-				if task.Timeout == 0 {
-					task.Timeout = s.config.Tekton.DefaultTimeout
-				}
-				if task.Order == 0 {
-					task.Order = i + 1
-				}
-
-				if err := tx.Create(task).Error; err != nil {
-					return fmt.Errorf("创建任务失败: %w", err)
-				}
+			if err := s.createTasks(tx, pipeline, req.Tasks); err != nil {
+				return err
 			}
 		}
 
@@ -407,7 +522,7 @@ func (s *pipelineService) Delete(id uuid.UUID) error {
 	// 检查是否有运行中的流水线
 	var runningCount int64
 	if err := s.db.Model(&models.PipelineRun{}).
-		Where("pipeline_id = ? AND status IN (?)", id, []string{"pending", "running"}).
+		Where("pipeline_id = ? AND status IN (?)", id, []string{"queued", "pending", "running"}).
 		Count(&runningCount).Error; err != nil {
 		return fmt.Errorf("检查运行状态失败: %w", err)
 	}
@@ -493,6 +608,7 @@ func (s *pipelineService) Clone(id uuid.UUID, newName string) (*models.Pipeline,
 			Order:       task.Order,
 			Timeout:     task.Timeout,
 			Retries:     task.Retries,
+			Environment: task.Environment,
 		}
 
 		// 反序列化环境变量和卷挂载
@@ -506,6 +622,16 @@ func (s *pipelineService) Clone(id uuid.UUID, newName string) (*models.Pipeline,
 			taskReq.Volumes = volumes
 		}
 
+		var runnerTags []string
+		if err := jsonUnmarshal(task.RunnerTags, &runnerTags); err == nil {
+			taskReq.RunnerTags = runnerTags
+		}
+
+		taskReq.Artifacts = taskArtifactSpec(&task)
+		taskReq.ArtifactsFrom = taskArtifactDependencies(&task)
+		taskReq.Secrets = taskSecretReferences(&task)
+		taskReq.Matrix = taskMatrixSpec(&task)
+
 		tasks = append(tasks, taskReq)
 	}
 
@@ -675,21 +801,131 @@ func (s *pipelineService) GetStatistics(projectID *uuid.UUID) (*PipelineStats, e
 	return stats, nil
 }
 
-// ValidateConfig 验证配置
+// ValidateConfig 验证配置，支持YAML定义文件内容、解析后的定义以及API创建/更新请求
 func (s *pipelineService) ValidateConfig(config interface{}) error {
-	// TODO: 实现配置验证逻辑
+	switch cfg := config.(type) {
+	case []byte:
+		_, err := ParsePipelineDefinition(cfg)
+		return err
+	case string:
+		_, err := ParsePipelineDefinition([]byte(cfg))
+		return err
+	case *PipelineDefinition:
+		return cfg.Validate()
+	case *CreatePipelineRequest:
+		if err := validateQueueConfig(&cfg.Config); err != nil {
+			return err
+		}
+		return definitionFromRequest(cfg.Name, cfg.Triggers, cfg.Tasks).Validate()
+	case *UpdatePipelineRequest:
+		if cfg.Config != nil {
+			if err := validateQueueConfig(cfg.Config); err != nil {
+				return err
+			}
+		}
+		if cfg.Tasks == nil {
+			// 只更新触发器时单独校验触发器
+			problems := definitionFromRequest("", cfg.Triggers, nil).validateTriggers()
+			if len(problems) > 0 {
+				return fmt.Errorf("流水线定义校验失败: %s", strings.Join(problems, "; "))
+			}
+			return nil
+		}
+		return definitionFromRequest("", cfg.Triggers, cfg.Tasks).Validate()
+	default:
+		return fmt.Errorf("不支持的配置类型: %T", config)
+	}
+}
+
+// validateQueueConfig 校验流水线的排队优先级和并发组
+func validateQueueConfig(cfg *models.PipelineConfig) error {
+	if cfg.Priority < 0 || cfg.Priority > maxRunPriority {
+		return fmt.Errorf("config.priority 必须在0到%d之间", maxRunPriority)
+	}
+	if len(cfg.ConcurrencyGroup) > 255 {
+		return fmt.Errorf("config.concurrency_group 不能超过255个字符")
+	}
+	if cfg.CancelInProgress && cfg.ConcurrencyGroup == "" {
+		return fmt.Errorf("config.cancel_in_progress 需要同时设置 concurrency_group")
+	}
 	return nil
 }
 
+// ImportFromRepository 读取仓库中的定义文件，创建或同步对应的流水线
+// （同一仓库同一定义文件只对应一条流水线）
+func (s *pipelineService) ImportFromRepository(req *ImportPipelineRequest) (*models.Pipeline, error) {
+	var project models.Project
+	if err := s.db.Where("id = ?", req.ProjectID).First(&project).Error; err != nil {
+		return nil, fmt.Errorf("项目不存在")
+	}
+
+	definitionPath := req.DefinitionFilePath
+	if definitionPath == "" {
+		definitionPath = s.config.GitGateway.DefinitionFile
+	}
+
+	definition, file, err := loadPipelineDefinition(s.gitGateway, req.RepositoryID, req.Ref, definitionPath, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := definition.Name
+	if name == "" {
+		name = path.Base(file.Path)
+	}
+	cfg := definition.PipelineConfig(s.config.Tekton.DefaultTimeout)
+
+	var existing models.Pipeline
+	err = s.db.Where("repository_id = ? AND definition_file_path = ? AND deleted_at IS NULL",
+		req.RepositoryID, file.Path).First(&existing).Error
+	if err == gorm.ErrRecordNotFound {
+		var conflict models.Pipeline
+		if err := s.db.Where("project_id = ? AND name = ? AND deleted_at IS NULL",
+			req.ProjectID, name).First(&conflict).Error; err == nil {
+			return nil, fmt.Errorf("流水线名称 '%s' 已存在", name)
+		}
+
+		return s.createPipelineWithTasks(&CreatePipelineRequest{
+			ProjectID:          req.ProjectID,
+			RepositoryID:       &req.RepositoryID,
+			DefinitionFilePath: &file.Path,
+			Name:               name,
+			Description:        definition.Description,
+			Config:             cfg,
+			Triggers:           definition.TriggerConfigs(),
+			Variables:          definition.Variables,
+			Tasks:              definition.TaskRequests(),
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询流水线失败: %w", err)
+	}
+	if existing.ProjectID != req.ProjectID {
+		return nil, fmt.Errorf("该定义文件已被其他项目的流水线使用")
+	}
+
+	variables := definition.Variables
+	if variables == nil {
+		variables = map[string]interface{}{}
+	}
+	return s.updatePipelineWithTasks(&existing, &UpdatePipelineRequest{
+		Name:        &name,
+		Description: definition.Description,
+		Config:      &cfg,
+		Triggers:    definition.TriggerConfigs(),
+		Variables:   variables,
+		Tasks:       definition.TaskRequests(),
+	})
+}
+
 // 辅助函数
 func jsonMarshal(v interface{}) ([]byte, error) {
-	// 这里应该使用实际的JSON序列化
-	// 为了简化示例，返回空字节切片
-	return []byte("{}"), nil
+	return json.Marshal(v)
 }
 
 func jsonUnmarshal(data []byte, v interface{}) error {
-	// 这里应该使用实际的JSON反序列化
-	// 为了简化示例，直接返回nil
-	return nil
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
 }
\ No newline at end of file
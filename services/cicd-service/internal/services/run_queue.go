@@ -0,0 +1,280 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"cicd-service/internal/config"
+	"cicd-service/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxRunPriority 运行排队优先级上限，数值大的先启动
+	maxRunPriority = 100
+	// queueDispatchBatch 单次出队检查的排队运行数上限
+	queueDispatchBatch = 200
+	// queueClaimTimeout 已出队超过该时间仍未开始执行的运行重新排队（出队的实例在启动前退出）
+	queueClaimTimeout = 5 * time.Minute
+	// queueDispatchLockKey 出队咨询锁的键，各副本共用
+	queueDispatchLockKey = "pipeline-run-queue-dispatch"
+)
+
+// queueOrder 队列顺序：优先级高的在前，同优先级先进先出
+const queueOrder = "pipeline_runs.priority DESC, pipeline_runs.queued_at ASC, pipeline_runs.id ASC"
+
+// queueSlot 排队或执行中的运行及其所属项目和租户
+type queueSlot struct {
+	ID               uuid.UUID
+	ConcurrencyGroup *string
+	ProjectID        uuid.UUID
+	TenantID         *uuid.UUID
+}
+
+// queueUsage 执行中运行对全局、租户、项目和并发组的占用
+type queueUsage struct {
+	total    int
+	tenants  map[uuid.UUID]int
+	projects map[uuid.UUID]int
+	groups   map[string]bool
+}
+
+func newQueueUsage(active []queueSlot) *queueUsage {
+	usage := &queueUsage{
+		tenants:  make(map[uuid.UUID]int),
+		projects: make(map[uuid.UUID]int),
+		groups:   make(map[string]bool),
+	}
+	for _, slot := range active {
+		usage.add(slot)
+	}
+	return usage
+}
+
+func (u *queueUsage) add(slot queueSlot) {
+	u.total++
+	u.tenants[slot.tenant()]++
+	u.projects[slot.ProjectID]++
+	if slot.ConcurrencyGroup != nil {
+		u.groups[*slot.ConcurrencyGroup] = true
+	}
+}
+
+// admits 运行启动后是否仍在租户、项目上限内，且并发组中没有执行中的运行
+func (u *queueUsage) admits(slot queueSlot, cfg config.QueueConfig) bool {
+	if cfg.MaxRunsPerTenant > 0 && u.tenants[slot.tenant()] >= cfg.MaxRunsPerTenant {
+		return false
+	}
+	if cfg.MaxRunsPerProject > 0 && u.projects[slot.ProjectID] >= cfg.MaxRunsPerProject {
+		return false
+	}
+	return slot.ConcurrencyGroup == nil || !u.groups[*slot.ConcurrencyGroup]
+}
+
+func (s queueSlot) tenant() uuid.UUID {
+	if s.TenantID == nil {
+		return uuid.Nil
+	}
+	return *s.TenantID
+}
+
+// runConcurrencyGroup 运行所属的并发组：项目、流水线配置的组名和分支，未配置并发组时为空
+func runConcurrencyGroup(pipeline *models.Pipeline, branch *string) *string {
+	if pipeline.Config.ConcurrencyGroup == "" {
+		return nil
+	}
+	ref := ""
+	if branch != nil {
+		ref = *branch
+	}
+	group := fmt.Sprintf("%s/%s/%s", pipeline.ProjectID, pipeline.Config.ConcurrencyGroup, ref)
+	return &group
+}
+
+// DispatchQueue 按优先级和排队时间启动排队中的运行，直到达到全局、租户或项目并发上限；
+// 并发组中已有执行中运行的排队运行继续等待。返回本次启动的运行数
+func (s *pipelineRunService) DispatchQueue() (int, error) {
+	// 本实例内先串行，避免多个出队同时占用连接等待咨询锁
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	var claimed []uuid.UUID
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 各副本通过事务级咨询锁串行出队，否则可能读到相同的占用而同时启动，超出并发上限
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", queueDispatchLockKey).Error; err != nil {
+			return fmt.Errorf("获取出队锁失败: %w", err)
+		}
+
+		if err := requeueStaleClaims(tx); err != nil {
+			return err
+		}
+
+		var queued []queueSlot
+		if err := queueSlots(tx, "queued").Order(queueOrder).Limit(queueDispatchBatch).Scan(&queued).Error; err != nil {
+			return fmt.Errorf("获取排队运行失败: %w", err)
+		}
+		if len(queued) == 0 {
+			return nil
+		}
+
+		var active []queueSlot
+		if err := queueSlots(tx, "pending", "running").Scan(&active).Error; err != nil {
+			return fmt.Errorf("获取执行中运行失败: %w", err)
+		}
+
+		usage := newQueueUsage(active)
+		maxRuns := s.config.Tekton.MaxConcurrentRuns
+		for _, slot := range queued {
+			if maxRuns > 0 && usage.total >= maxRuns {
+				break
+			}
+			if !usage.admits(slot, s.config.Queue) {
+				continue
+			}
+
+			// 条件更新出队，运行可能已被取消
+			result := tx.Model(&models.PipelineRun{}).
+				Where("id = ? AND status = ?", slot.ID, "queued").
+				Updates(map[string]interface{}{
+					"status":     "pending",
+					"updated_at": time.Now(),
+				})
+			if result.Error != nil {
+				return fmt.Errorf("运行出队失败: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+
+			usage.add(slot)
+			claimed = append(claimed, slot.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// 提交后再启动，启动时按 pending 条件更新，未提交的出队不可见
+	for _, id := range claimed {
+		go s.startQueuedRun(id)
+	}
+	return len(claimed), nil
+}
+
+// dispatchQueue 出队并记录失败，用于创建运行和运行结束时
+func (s *pipelineRunService) dispatchQueue() {
+	if _, err := s.DispatchQueue(); err != nil {
+		log.Printf("⚠️ 运行出队失败: %v", err)
+	}
+}
+
+// queueSlots 查询指定状态的运行及其所属项目和租户
+func queueSlots(db *gorm.DB, statuses ...string) *gorm.DB {
+	return db.Table("pipeline_runs").
+		Select("pipeline_runs.id, pipeline_runs.concurrency_group, pipelines.project_id, projects.tenant_id").
+		Joins("JOIN pipelines ON pipelines.id = pipeline_runs.pipeline_id").
+		Joins("LEFT JOIN projects ON projects.id = pipelines.project_id").
+		Where("pipeline_runs.status IN ?", statuses)
+}
+
+// requeueStaleClaims 已出队但长时间未开始执行的运行重新排队，避免一直占用并发额度
+func requeueStaleClaims(db *gorm.DB) error {
+	if err := db.Model(&models.PipelineRun{}).
+		Where("status = ? AND queued_at IS NOT NULL AND started_at IS NULL AND updated_at < ?",
+			"pending", time.Now().Add(-queueClaimTimeout)).
+		Updates(map[string]interface{}{
+			"status":     "queued",
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+		return fmt.Errorf("重新排队未启动的运行失败: %w", err)
+	}
+	return nil
+}
+
+// startQueuedRun 启动已出队的运行，按运行记录重建本次运行的流水线和参数
+func (s *pipelineRunService) startQueuedRun(id uuid.UUID) {
+	var run models.PipelineRun
+	if err := s.db.Where("id = ?", id).First(&run).Error; err != nil {
+		return
+	}
+
+	pipeline, params, err := s.queuedRunPipeline(&run)
+	if err != nil {
+		message := err.Error()
+		s.UpdateStatus(run.ID, "failed", &message)
+		return
+	}
+
+	s.startPipelineRunAsync(&run, pipeline, params)
+}
+
+// queuedRunPipeline 运行使用的流水线和参数：有定义快照时使用快照，否则使用数据库中的任务
+func (s *pipelineRunService) queuedRunPipeline(run *models.PipelineRun) (*models.Pipeline, map[string]interface{}, error) {
+	var pipeline models.Pipeline
+	if err := s.db.Where("id = ?", run.PipelineID).Preload("Tasks").First(&pipeline).Error; err != nil {
+		return nil, nil, fmt.Errorf("获取流水线失败: %w", err)
+	}
+
+	runPipeline := &pipeline
+	if len(run.Definition) > 0 {
+		var definition PipelineDefinition
+		if err := json.Unmarshal(run.Definition, &definition); err != nil {
+			return nil, nil, fmt.Errorf("解析流水线定义快照失败: %w", err)
+		}
+		definitionPipeline, err := definition.BuildPipeline(&pipeline, s.config.Tekton.DefaultTimeout)
+		if err != nil {
+			return nil, nil, err
+		}
+		runPipeline = definitionPipeline
+	}
+
+	var params map[string]interface{}
+	if len(run.Parameters) > 0 {
+		if err := json.Unmarshal(run.Parameters, &params); err != nil {
+			return nil, nil, fmt.Errorf("解析运行参数失败: %w", err)
+		}
+	}
+	return runPipeline, params, nil
+}
+
+// supersedeRuns 取消同一并发组中更早的运行（流水线配置了 cancel_in_progress）
+func (s *pipelineRunService) supersedeRuns(run *models.PipelineRun) {
+	var older []models.PipelineRun
+	if err := s.db.Select("id").
+		Where("concurrency_group = ? AND id <> ? AND status IN ?", *run.ConcurrencyGroup, run.ID,
+			[]string{"queued", "pending", "running"}).
+		Find(&older).Error; err != nil {
+		return
+	}
+
+	reason := fmt.Sprintf("被同一并发组的运行 #%d 取代", run.RunNumber)
+	for _, superseded := range older {
+		s.Cancel(superseded.ID, reason)
+	}
+}
+
+// fillQueuePositions 为排队中的运行填充排队位置（在全局队列中的顺序，从1开始）
+func (s *pipelineRunService) fillQueuePositions(runs []models.PipelineRun) {
+	for i := range runs {
+		run := &runs[i]
+		if run.Status != "queued" || run.QueuedAt == nil {
+			continue
+		}
+
+		var ahead int64
+		if err := s.db.Model(&models.PipelineRun{}).
+			Where("status = ?", "queued").
+			Where("priority > ? OR (priority = ? AND (queued_at < ? OR (queued_at = ? AND id < ?)))",
+				run.Priority, run.Priority, *run.QueuedAt, *run.QueuedAt, run.ID).
+			Count(&ahead).Error; err != nil {
+			continue
+		}
+		position := int(ahead) + 1
+		run.QueuePosition = &position
+	}
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"cicd-service/internal/config"
+
+	"github.com/google/uuid"
+)
+
+func TestQueueUsageAdmits(t *testing.T) {
+	tenantA, tenantB := uuid.New(), uuid.New()
+	projectA, projectB, projectC := uuid.New(), uuid.New(), uuid.New()
+	group, otherGroup := "deploy/main", "deploy/release"
+
+	// 执行中：租户A的项目A两个、项目B一个（并发组 deploy/main），无租户的项目C一个
+	usage := newQueueUsage([]queueSlot{
+		{ID: uuid.New(), ProjectID: projectA, TenantID: &tenantA},
+		{ID: uuid.New(), ProjectID: projectA, TenantID: &tenantA},
+		{ID: uuid.New(), ProjectID: projectB, TenantID: &tenantA, ConcurrencyGroup: &group},
+		{ID: uuid.New(), ProjectID: projectC},
+	})
+	if usage.total != 4 {
+		t.Fatalf("total = %d, want 4", usage.total)
+	}
+
+	tests := []struct {
+		name string
+		slot queueSlot
+		cfg  config.QueueConfig
+		want bool
+	}{
+		{"no limits", queueSlot{ProjectID: projectA, TenantID: &tenantA}, config.QueueConfig{}, true},
+		{"tenant at limit", queueSlot{ProjectID: uuid.New(), TenantID: &tenantA}, config.QueueConfig{MaxRunsPerTenant: 3}, false},
+		{"tenant below limit", queueSlot{ProjectID: uuid.New(), TenantID: &tenantA}, config.QueueConfig{MaxRunsPerTenant: 4}, true},
+		{"other tenant unaffected", queueSlot{ProjectID: uuid.New(), TenantID: &tenantB}, config.QueueConfig{MaxRunsPerTenant: 1}, true},
+		{"runs without tenant share a bucket", queueSlot{ProjectID: uuid.New()}, config.QueueConfig{MaxRunsPerTenant: 1}, false},
+		{"project at limit", queueSlot{ProjectID: projectA, TenantID: &tenantA}, config.QueueConfig{MaxRunsPerProject: 2}, false},
+		{"project below limit", queueSlot{ProjectID: projectB, TenantID: &tenantA}, config.QueueConfig{MaxRunsPerProject: 2}, true},
+		{"project limit with tenant room", queueSlot{ProjectID: projectA, TenantID: &tenantA}, config.QueueConfig{MaxRunsPerTenant: 10, MaxRunsPerProject: 2}, false},
+		{"concurrency group busy", queueSlot{ProjectID: projectB, TenantID: &tenantA, ConcurrencyGroup: &group}, config.QueueConfig{}, false},
+		{"other concurrency group free", queueSlot{ProjectID: projectB, TenantID: &tenantA, ConcurrencyGroup: &otherGroup}, config.QueueConfig{}, true},
+		{"free group still bound by project limit", queueSlot{ProjectID: projectA, TenantID: &tenantA, ConcurrencyGroup: &otherGroup}, config.QueueConfig{MaxRunsPerProject: 2}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := usage.admits(tt.slot, tt.cfg); got != tt.want {
+				t.Fatalf("admits = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("add occupies group", func(t *testing.T) {
+		usage := newQueueUsage(nil)
+		slot := queueSlot{ID: uuid.New(), ProjectID: projectA, ConcurrencyGroup: &otherGroup}
+		if !usage.admits(slot, config.QueueConfig{}) {
+			t.Fatal("empty usage should admit")
+		}
+		usage.add(slot)
+		if usage.admits(queueSlot{ProjectID: projectB, ConcurrencyGroup: &otherGroup}, config.QueueConfig{}) {
+			t.Fatal("group should be occupied after add")
+		}
+	})
+}
+
+func TestRequeueStaleClaims(t *testing.T) {
+	db := newTestDB(t, `CREATE TABLE pipeline_runs (id TEXT PRIMARY KEY, status TEXT,
+		queued_at DATETIME, started_at DATETIME, updated_at DATETIME)`)
+
+	now := time.Now()
+	stale := now.Add(-2 * queueClaimTimeout)
+	fresh := now.Add(-time.Second)
+
+	tests := []struct {
+		name      string
+		status    string
+		queuedAt  *time.Time
+		startedAt *time.Time
+		updatedAt time.Time
+		want      string
+	}{
+		{"stale pending claim", "pending", &stale, nil, stale, "queued"},
+		{"recent pending claim", "pending", &fresh, nil, fresh, "pending"},
+		{"stale pending already started", "pending", &stale, &stale, stale, "pending"},
+		{"stale pending never queued", "pending", nil, nil, stale, "pending"},
+		{"stale running", "running", &stale, &stale, stale, "running"},
+	}
+
+	ids := make([]uuid.UUID, len(tests))
+	for i, tt := range tests {
+		ids[i] = uuid.New()
+		if err := db.Exec("INSERT INTO pipeline_runs (id, status, queued_at, started_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+			ids[i], tt.status, tt.queuedAt, tt.startedAt, tt.updatedAt).Error; err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	if err := requeueStaleClaims(db); err != nil {
+		t.Fatalf("requeueStaleClaims: %v", err)
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var status string
+			if err := db.Raw("SELECT status FROM pipeline_runs WHERE id = ?", ids[i]).Scan(&status).Error; err != nil {
+				t.Fatalf("select: %v", err)
+			}
+			if status != tt.want {
+				t.Fatalf("status = %q, want %q", status, tt.want)
+			}
+		})
+	}
+}
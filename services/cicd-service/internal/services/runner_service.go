@@ -0,0 +1,1446 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cicd-service/internal/config"
+	"cicd-service/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	runnerTokenPrefix             = "axr_"  // 执行器认证令牌前缀
+	runnerRegistrationTokenPrefix = "axrt_" // 执行器注册令牌前缀
+)
+
+var (
+	// ErrJobNotAssigned 作业不存在或未分配给当前执行器
+	ErrJobNotAssigned = errors.New("作业不存在或未分配给当前执行器")
+	// ErrJobCancelled 作业已取消或已结束，执行器应终止执行
+	ErrJobCancelled = errors.New("作业已取消或已结束")
+	// ErrJobArtifactNotFound 产物不在作业的依赖中
+	ErrJobArtifactNotFound = errors.New("作业依赖中不存在该产物")
+	// ErrJobArtifactNotDeclared 上传的文件不在作业声明的产物路径中
+	ErrJobArtifactNotDeclared = errors.New("文件不在作业声明的产物路径中")
+
+	// errJobSecretsUnavailable 作业引用的密钥无法解密
+	errJobSecretsUnavailable = errors.New("获取作业密钥失败")
+	// errJobSpecInvalid 作业定义无法解析
+	errJobSpecInvalid = errors.New("解析作业定义失败")
+)
+
+// RunnerService 自托管执行器服务接口，同时作为 runner 类型的流水线执行器
+type RunnerService interface {
+	Executor
+	SetRunService(runService PipelineRunService)
+	SetDeploymentGate(gate DeploymentGate)
+	// ScheduleRun 重新调度运行中等待的作业，部署审批结束后调用
+	ScheduleRun(runID uuid.UUID)
+
+	// 注册令牌管理
+	CreateRegistrationToken(req *CreateRegistrationTokenRequest) (*RegistrationTokenResult, error)
+	ListRegistrationTokens(tenantID uuid.UUID) ([]models.RunnerRegistrationToken, error)
+	RevokeRegistrationToken(tenantID, id uuid.UUID) error
+
+	// 执行器管理
+	List(tenantID uuid.UUID, status string) ([]models.Runner, error)
+	GetByID(tenantID, id uuid.UUID) (*models.Runner, error)
+	Update(tenantID, id uuid.UUID, req *UpdateRunnerRequest) (*models.Runner, error)
+	Drain(tenantID, id uuid.UUID) error
+	Resume(tenantID, id uuid.UUID) error
+	Delete(tenantID, id uuid.UUID) error
+
+	// 执行器协议
+	Register(req *RegisterRunnerRequest) (*RegisterRunnerResult, error)
+	Authenticate(token string) (*models.Runner, error)
+	Heartbeat(runner *models.Runner, req *RunnerHeartbeatRequest) (*RunnerHeartbeatResponse, error)
+	Shutdown(runner *models.Runner) error
+	RequestJob(ctx context.Context, runner *models.Runner) (*RunnerJob, error)
+	AppendJobLog(runner *models.Runner, jobID uuid.UUID, offset int64, data []byte) (int64, error)
+	ListJobArtifacts(runner *models.Runner, jobID uuid.UUID) ([]RunnerJobArtifact, error)
+	OpenJobArtifact(ctx context.Context, runner *models.Runner, jobID, artifactID uuid.UUID) (*models.Artifact, io.ReadCloser, error)
+	UploadJobArtifact(ctx context.Context, runner *models.Runner, jobID uuid.UUID, path, checksum string, reader io.Reader) (*models.Artifact, error)
+	UpdateJobStatus(runner *models.Runner, jobID uuid.UUID, req *JobStatusRequest) error
+
+	DetectOfflineRunners() error
+	ExpireTimedOutRuns() error
+}
+
+type runnerService struct {
+	db         *gorm.DB
+	config     *config.Config
+	logService LogService
+	artifacts  ArtifactService
+	secrets    SecretService
+	runService PipelineRunService
+	gate       DeploymentGate
+
+	scheduleMu sync.Mutex // 串行化同一实例内的依赖调度和运行收尾
+	signalMu   sync.Mutex
+	jobSignal  chan struct{} // 有新作业可领取时关闭并替换，唤醒长轮询
+}
+
+// NewRunnerService 创建自托管执行器服务实例
+func NewRunnerService(db *gorm.DB, cfg *config.Config, logService LogService, artifactService ArtifactService, secretService SecretService) RunnerService {
+	return &runnerService{
+		db:         db,
+		config:     cfg,
+		logService: logService,
+		artifacts:  artifactService,
+		secrets:    secretService,
+		jobSignal:  make(chan struct{}),
+	}
+}
+
+// CreateRegistrationTokenRequest 创建注册令牌请求
+type CreateRegistrationTokenRequest struct {
+	TenantID       uuid.UUID `json:"-"`
+	CreatedBy      uuid.UUID `json:"-"`
+	Description    *string   `json:"description"`
+	Tags           []string  `json:"tags"`
+	ExpiresInHours int       `json:"expires_in_hours" binding:"min=0,max=8760"` // 0表示不过期
+}
+
+// RegistrationTokenResult 注册令牌创建结果，明文令牌只返回一次
+type RegistrationTokenResult struct {
+	Token             string                          `json:"token"`
+	RegistrationToken *models.RunnerRegistrationToken `json:"registration_token"`
+}
+
+// UpdateRunnerRequest 更新执行器请求
+type UpdateRunnerRequest struct {
+	Name              *string   `json:"name" binding:"omitempty,max=255"`
+	Description       *string   `json:"description"`
+	Tags              *[]string `json:"tags"`
+	MaxConcurrentJobs *int      `json:"max_concurrent_jobs" binding:"omitempty,min=1,max=64"`
+	Disabled          *bool     `json:"disabled"`
+}
+
+// RegisterRunnerRequest 执行器注册请求
+type RegisterRunnerRequest struct {
+	RegistrationToken string   `json:"registration_token" binding:"required"`
+	Name              string   `json:"name" binding:"required,max=255"`
+	Description       *string  `json:"description"`
+	Tags              []string `json:"tags"`
+	Version           *string  `json:"version"`
+	Platform          *string  `json:"platform"`
+	MaxConcurrentJobs int      `json:"max_concurrent_jobs" binding:"min=0,max=64"`
+	IPAddress         *string  `json:"-"`
+}
+
+// RegisterRunnerResult 执行器注册结果，认证令牌只返回一次
+type RegisterRunnerResult struct {
+	Runner            *models.Runner `json:"runner"`
+	Token             string         `json:"token"`
+	HeartbeatInterval int            `json:"heartbeat_interval"`
+}
+
+// RunnerHeartbeatRequest 执行器心跳请求
+type RunnerHeartbeatRequest struct {
+	RunningJobs []uuid.UUID `json:"running_jobs"` // 执行器上正在执行的作业
+	Draining    bool        `json:"draining"`     // 执行器主动进入排空（如收到退出信号）
+	Version     *string     `json:"version"`
+	IPAddress   *string     `json:"-"`
+}
+
+// RunnerHeartbeatResponse 执行器心跳响应
+type RunnerHeartbeatResponse struct {
+	Draining          bool        `json:"draining"`       // 是否应停止领取新作业
+	CancelledJobs     []uuid.UUID `json:"cancelled_jobs"` // 应终止的作业（已取消或已重新分配）
+	HeartbeatInterval int         `json:"heartbeat_interval"`
+}
+
+// RunnerJob 下发给执行器的作业
+type RunnerJob struct {
+	ID            uuid.UUID     `json:"id"`
+	PipelineRunID uuid.UUID     `json:"pipeline_run_id"`
+	PipelineID    uuid.UUID     `json:"pipeline_id"`
+	RunNumber     int           `json:"run_number"`
+	RepositoryID  *uuid.UUID    `json:"repository_id,omitempty"`
+	CommitSHA     *string       `json:"commit_sha,omitempty"`
+	Branch        *string       `json:"branch,omitempty"`
+	Spec          RunnerJobSpec `json:"spec"`
+}
+
+// RunnerJobSpec 作业定义快照
+type RunnerJobSpec struct {
+	Name       string            `json:"name"`
+	Image      string            `json:"image"`
+	Command    []string          `json:"command,omitempty"`
+	Args       []string          `json:"args,omitempty"`
+	WorkingDir *string           `json:"working_dir,omitempty"`
+	Env        map[string]string `json:"env,omitempty"`
+	Timeout    int               `json:"timeout"`
+	Retries    int               `json:"retries"`
+	DependsOn  []string          `json:"depends_on,omitempty"`
+	Artifacts  *ArtifactSpec     `json:"artifacts,omitempty"`      // 执行结束后上传的产物，expire_in_days 已按流水线配置解析
+	ArtifactsFrom []ArtifactDependency `json:"artifacts_from,omitempty"` // 执行前下载的产物
+	Secrets    []SecretReference `json:"secrets,omitempty"` // 引用的密钥，领取作业时解密并合并到 env，明文不持久化
+	Matrix     *MatrixCombination `json:"matrix,omitempty"`  // 矩阵任务的组合，取值已注入 env
+	Template   *RunnerJobTemplate `json:"template,omitempty"` // 待求值的执行条件和插值，放行作业时求值后清空
+	Environment string           `json:"environment,omitempty"` // 部署的目标环境，审批通过后才放行
+}
+
+// RunnerJobTemplate 作业的执行条件和插值，依赖满足后结合上游任务结果求值
+type RunnerJobTemplate struct {
+	Condition *string                `json:"condition,omitempty"`
+	Env       map[string]string      `json:"env,omitempty"` // 任务定义的环境变量，值可能包含插值
+	Scope     map[string]interface{} `json:"scope"`         // 运行级表达式上下文
+}
+
+// RunnerJobArtifact 作业依赖的产物，执行器下载到工作空间的 path
+type RunnerJobArtifact struct {
+	ID     uuid.UUID `json:"id"`
+	Name   string    `json:"name"`
+	Path   string    `json:"path"`
+	Size   int64     `json:"size"`
+	SHA256 string    `json:"sha256"`
+}
+
+// JobStatusRequest 作业状态上报请求
+type JobStatusRequest struct {
+	Status       string  `json:"status" binding:"required,oneof=running succeeded failed"`
+	ExitCode     *int    `json:"exit_code"`
+	ErrorMessage *string `json:"error_message"`
+	RetryCount   *int    `json:"retry_count"`
+}
+
+// SetRunService 注入流水线运行服务，用于回写运行和任务状态
+func (s *runnerService) SetRunService(runService PipelineRunService) {
+	s.runService = runService
+}
+
+// SetDeploymentGate 注入部署审批检查，部署到受保护环境的作业在审批通过后才放行
+func (s *runnerService) SetDeploymentGate(gate DeploymentGate) {
+	s.gate = gate
+}
+
+// CreateRegistrationToken 创建租户级执行器注册令牌
+func (s *runnerService) CreateRegistrationToken(req *CreateRegistrationTokenRequest) (*RegistrationTokenResult, error) {
+	token, err := generateRunnerToken(runnerRegistrationTokenPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	tagsJSON, err := json.Marshal(normalizeRunnerTags(req.Tags))
+	if err != nil {
+		return nil, fmt.Errorf("序列化标签失败: %w", err)
+	}
+
+	registrationToken := &models.RunnerRegistrationToken{
+		TenantID:    req.TenantID,
+		Description: req.Description,
+		TokenHash:   hashRunnerToken(token),
+		TokenPrefix: token[:len(runnerRegistrationTokenPrefix)+6],
+		Tags:        tagsJSON,
+		CreatedBy:   req.CreatedBy,
+	}
+	if req.ExpiresInHours > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		registrationToken.ExpiresAt = &expiresAt
+	}
+
+	if err := s.db.Create(registrationToken).Error; err != nil {
+		return nil, fmt.Errorf("创建注册令牌失败: %w", err)
+	}
+
+	return &RegistrationTokenResult{Token: token, RegistrationToken: registrationToken}, nil
+}
+
+// ListRegistrationTokens 获取租户的注册令牌列表
+func (s *runnerService) ListRegistrationTokens(tenantID uuid.UUID) ([]models.RunnerRegistrationToken, error) {
+	var tokens []models.RunnerRegistrationToken
+	if err := s.db.Where("tenant_id = ?", tenantID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("获取注册令牌列表失败: %w", err)
+	}
+	return tokens, nil
+}
+
+// RevokeRegistrationToken 吊销注册令牌，已注册的执行器不受影响
+func (s *runnerService) RevokeRegistrationToken(tenantID, id uuid.UUID) error {
+	result := s.db.Model(&models.RunnerRegistrationToken{}).
+		Where("id = ? AND tenant_id = ? AND revoked_at IS NULL", id, tenantID).
+		Updates(map[string]interface{}{
+			"revoked_at": time.Now(),
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("吊销注册令牌失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("注册令牌不存在或已吊销")
+	}
+	return nil
+}
+
+// List 获取租户的执行器列表
+func (s *runnerService) List(tenantID uuid.UUID, status string) ([]models.Runner, error) {
+	query := s.db.Where("tenant_id = ? AND deleted_at IS NULL", tenantID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var runners []models.Runner
+	if err := query.Order("created_at DESC").Find(&runners).Error; err != nil {
+		return nil, fmt.Errorf("获取执行器列表失败: %w", err)
+	}
+	return runners, nil
+}
+
+// GetByID 获取执行器详情
+func (s *runnerService) GetByID(tenantID, id uuid.UUID) (*models.Runner, error) {
+	var runner models.Runner
+	if err := s.db.Where("id = ? AND tenant_id = ? AND deleted_at IS NULL", id, tenantID).
+		First(&runner).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("执行器不存在")
+		}
+		return nil, fmt.Errorf("获取执行器失败: %w", err)
+	}
+	return &runner, nil
+}
+
+// Update 更新执行器信息，禁用时释放其正在执行的作业
+func (s *runnerService) Update(tenantID, id uuid.UUID, req *UpdateRunnerRequest) (*models.Runner, error) {
+	runner, err := s.GetByID(tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"updated_at": time.Now(),
+	}
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Tags != nil {
+		tagsJSON, err := json.Marshal(normalizeRunnerTags(*req.Tags))
+		if err != nil {
+			return nil, fmt.Errorf("序列化标签失败: %w", err)
+		}
+		updates["tags"] = datatypes.JSON(tagsJSON)
+	}
+	if req.MaxConcurrentJobs != nil {
+		updates["max_concurrent_jobs"] = *req.MaxConcurrentJobs
+	}
+	if req.Disabled != nil {
+		if *req.Disabled {
+			updates["status"] = "disabled"
+		} else if runner.Status == "disabled" {
+			updates["status"] = "offline"
+		}
+	}
+
+	if err := s.db.Model(runner).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("更新执行器失败: %w", err)
+	}
+
+	if req.Disabled != nil && *req.Disabled {
+		s.releaseRunnerJobs(runner.ID, "执行器已禁用")
+	}
+
+	return s.GetByID(tenantID, id)
+}
+
+// Drain 排空执行器：不再分配新作业，当前作业执行完毕
+func (s *runnerService) Drain(tenantID, id uuid.UUID) error {
+	return s.setDraining(tenantID, id, true)
+}
+
+// Resume 恢复执行器领取作业
+func (s *runnerService) Resume(tenantID, id uuid.UUID) error {
+	return s.setDraining(tenantID, id, false)
+}
+
+// setDraining 设置执行器排空状态
+func (s *runnerService) setDraining(tenantID, id uuid.UUID, draining bool) error {
+	result := s.db.Model(&models.Runner{}).
+		Where("id = ? AND tenant_id = ? AND deleted_at IS NULL", id, tenantID).
+		Updates(map[string]interface{}{
+			"draining":   draining,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("更新执行器排空状态失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("执行器不存在")
+	}
+	return nil
+}
+
+// Delete 删除执行器，其正在执行的作业重新分配
+func (s *runnerService) Delete(tenantID, id uuid.UUID) error {
+	runner, err := s.GetByID(tenantID, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Model(runner).Updates(map[string]interface{}{
+		"status":     "offline",
+		"deleted_at": time.Now(),
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		return fmt.Errorf("删除执行器失败: %w", err)
+	}
+
+	s.releaseRunnerJobs(runner.ID, "执行器已删除")
+	return nil
+}
+
+// Register 使用注册令牌注册执行器
+func (s *runnerService) Register(req *RegisterRunnerRequest) (*RegisterRunnerResult, error) {
+	var registrationToken models.RunnerRegistrationToken
+	if err := s.db.Where("token_hash = ?", hashRunnerToken(req.RegistrationToken)).
+		First(&registrationToken).Error; err != nil {
+		return nil, fmt.Errorf("注册令牌无效")
+	}
+	if registrationToken.RevokedAt != nil {
+		return nil, fmt.Errorf("注册令牌已吊销")
+	}
+	if registrationToken.ExpiresAt != nil && registrationToken.ExpiresAt.Before(time.Now()) {
+		return nil, fmt.Errorf("注册令牌已过期")
+	}
+
+	var tokenTags []string
+	if err := jsonUnmarshal(registrationToken.Tags, &tokenTags); err != nil {
+		return nil, fmt.Errorf("解析注册令牌标签失败: %w", err)
+	}
+	tagsJSON, err := json.Marshal(normalizeRunnerTags(append(tokenTags, req.Tags...)))
+	if err != nil {
+		return nil, fmt.Errorf("序列化标签失败: %w", err)
+	}
+
+	token, err := generateRunnerToken(runnerTokenPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	maxJobs := req.MaxConcurrentJobs
+	if maxJobs <= 0 {
+		maxJobs = 1
+	}
+
+	now := time.Now()
+	runner := &models.Runner{
+		TenantID:          registrationToken.TenantID,
+		Name:              req.Name,
+		Description:       req.Description,
+		Tags:              tagsJSON,
+		Status:            "online",
+		TokenHash:         hashRunnerToken(token),
+		TokenPrefix:       token[:len(runnerTokenPrefix)+6],
+		Version:           req.Version,
+		Platform:          req.Platform,
+		IPAddress:         req.IPAddress,
+		MaxConcurrentJobs: maxJobs,
+		RegisteredBy:      &registrationToken.ID,
+		LastContactAt:     &now,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(runner).Error; err != nil {
+			return fmt.Errorf("注册执行器失败: %w", err)
+		}
+		return tx.Model(&registrationToken).Updates(map[string]interface{}{
+			"usage_count":  gorm.Expr("usage_count + 1"),
+			"last_used_at": now,
+			"updated_at":   now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RegisterRunnerResult{
+		Runner:            runner,
+		Token:             token,
+		HeartbeatInterval: s.config.Runner.HeartbeatInterval,
+	}, nil
+}
+
+// Authenticate 校验执行器认证令牌
+func (s *runnerService) Authenticate(token string) (*models.Runner, error) {
+	if !strings.HasPrefix(token, runnerTokenPrefix) {
+		return nil, fmt.Errorf("执行器令牌无效")
+	}
+
+	var runner models.Runner
+	if err := s.db.Where("token_hash = ? AND deleted_at IS NULL", hashRunnerToken(token)).
+		First(&runner).Error; err != nil {
+		return nil, fmt.Errorf("执行器令牌无效")
+	}
+	if runner.Status == "disabled" {
+		return nil, fmt.Errorf("执行器已禁用")
+	}
+	return &runner, nil
+}
+
+// Heartbeat 处理执行器心跳，返回排空状态和需要终止的作业
+func (s *runnerService) Heartbeat(runner *models.Runner, req *RunnerHeartbeatRequest) (*RunnerHeartbeatResponse, error) {
+	updates := map[string]interface{}{
+		"status":          "online",
+		"last_contact_at": time.Now(),
+		"updated_at":      time.Now(),
+	}
+	if req.Draining {
+		updates["draining"] = true
+	}
+	if req.Version != nil {
+		updates["version"] = *req.Version
+	}
+	if req.IPAddress != nil {
+		updates["ip_address"] = *req.IPAddress
+	}
+	if err := s.db.Model(runner).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("更新执行器心跳失败: %w", err)
+	}
+
+	response := &RunnerHeartbeatResponse{
+		Draining:          runner.Draining || req.Draining,
+		CancelledJobs:     []uuid.UUID{},
+		HeartbeatInterval: s.config.Runner.HeartbeatInterval,
+	}
+
+	if len(req.RunningJobs) > 0 {
+		var activeIDs []uuid.UUID
+		if err := s.db.Model(&models.TaskRun{}).
+			Where("id IN ? AND runner_id = ? AND status = ?", req.RunningJobs, runner.ID, "running").
+			Pluck("id", &activeIDs).Error; err != nil {
+			return nil, fmt.Errorf("查询执行中的作业失败: %w", err)
+		}
+
+		active := make(map[uuid.UUID]bool, len(activeIDs))
+		for _, id := range activeIDs {
+			active[id] = true
+		}
+		for _, id := range req.RunningJobs {
+			if !active[id] {
+				response.CancelledJobs = append(response.CancelledJobs, id)
+			}
+		}
+	}
+
+	return response, nil
+}
+
+// Shutdown 执行器正常退出，标记离线并释放未完成的作业
+func (s *runnerService) Shutdown(runner *models.Runner) error {
+	if err := s.db.Model(runner).Updates(map[string]interface{}{
+		"status":          "offline",
+		"draining":        false,
+		"last_contact_at": time.Now(),
+		"updated_at":      time.Now(),
+	}).Error; err != nil {
+		return fmt.Errorf("更新执行器状态失败: %w", err)
+	}
+
+	s.releaseRunnerJobs(runner.ID, "执行器已退出")
+	return nil
+}
+
+// RequestJob 长轮询领取作业，等待超时仍无可执行作业时返回nil
+func (s *runnerService) RequestJob(ctx context.Context, runner *models.Runner) (*RunnerJob, error) {
+	if err := s.db.Model(runner).Updates(map[string]interface{}{
+		"status":          "online",
+		"last_contact_at": time.Now(),
+	}).Error; err != nil {
+		return nil, fmt.Errorf("更新执行器状态失败: %w", err)
+	}
+
+	deadline := time.NewTimer(time.Duration(s.config.Runner.LongPollTimeout) * time.Second)
+	defer deadline.Stop()
+
+	for {
+		// 每轮重新读取执行器，感知排空和禁用
+		var current models.Runner
+		if err := s.db.Where("id = ? AND deleted_at IS NULL", runner.ID).First(&current).Error; err != nil {
+			return nil, fmt.Errorf("执行器不存在")
+		}
+		if current.Draining || current.Status == "disabled" {
+			return nil, nil
+		}
+
+		signal := s.jobSignalChan()
+		job, err := s.acquireJob(&current)
+		if err != nil || job != nil {
+			return job, err
+		}
+
+		// 多实例部署时其他实例产生的作业只能通过定期轮询发现
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-deadline.C:
+			return nil, nil
+		case <-signal:
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// runnerJobCandidate 待领取作业
+type runnerJobCandidate struct {
+	ID         uuid.UUID
+	RunnerTags datatypes.JSON
+}
+
+// acquireJob 为执行器领取一个标签匹配的作业
+func (s *runnerService) acquireJob(runner *models.Runner) (*RunnerJob, error) {
+	var running int64
+	if err := s.db.Model(&models.TaskRun{}).
+		Where("runner_id = ? AND status = ?", runner.ID, "running").
+		Count(&running).Error; err != nil {
+		return nil, fmt.Errorf("统计执行器作业失败: %w", err)
+	}
+	if running >= int64(runner.MaxConcurrentJobs) {
+		return nil, nil
+	}
+
+	var runnerTags []string
+	if err := jsonUnmarshal(runner.Tags, &runnerTags); err != nil {
+		return nil, fmt.Errorf("解析执行器标签失败: %w", err)
+	}
+
+	var candidates []runnerJobCandidate
+	if err := s.db.Table("task_runs").
+		Select("task_runs.id, task_runs.runner_tags").
+		Joins("JOIN pipeline_runs ON pipeline_runs.id = task_runs.pipeline_run_id").
+		Joins("JOIN pipelines ON pipelines.id = pipeline_runs.pipeline_id").
+		Joins("JOIN projects ON projects.id = pipelines.project_id").
+		Where("task_runs.status = ? AND task_runs.runner_id IS NULL AND task_runs.queued_at IS NOT NULL", "pending").
+		Where("pipeline_runs.status = ? AND projects.tenant_id = ?", "running", runner.TenantID).
+		Order("task_runs.queued_at ASC").
+		Limit(50).
+		Scan(&candidates).Error; err != nil {
+		return nil, fmt.Errorf("查询待执行作业失败: %w", err)
+	}
+
+	for _, candidate := range candidates {
+		var jobTags []string
+		if err := jsonUnmarshal(candidate.RunnerTags, &jobTags); err != nil {
+			continue
+		}
+		if !runnerTagsMatch(jobTags, runnerTags) {
+			continue
+		}
+
+		claimed, full, err := s.claimJob(runner, candidate.ID)
+		if err != nil {
+			return nil, err
+		}
+		if full {
+			return nil, nil
+		}
+		if !claimed {
+			continue
+		}
+
+		job, err := s.buildRunnerJob(candidate.ID)
+		if errors.Is(err, errJobSecretsUnavailable) || errors.Is(err, errJobSpecInvalid) {
+			// 作业无法下发时重试也不会成功，直接失败并继续领取下一个作业
+			s.failClaimedJob(runner.ID, candidate.ID, err)
+			continue
+		}
+		if err != nil {
+			s.releaseJob(runner.ID, candidate.ID)
+			return nil, err
+		}
+		return job, nil
+	}
+
+	return nil, nil
+}
+
+// claimJob 在执行器行锁内检查运行中的作业数并以条件更新抢占作业；
+// 同一执行器的并发领取（多个长轮询请求或多个服务副本）在行锁上串行，运行中的作业数不会超过 MaxConcurrentJobs，
+// 多个执行器并发领取同一作业时只有一个条件更新成功
+func (s *runnerService) claimJob(runner *models.Runner, taskRunID uuid.UUID) (claimed, full bool, err error) {
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var locked models.Runner
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "max_concurrent_jobs").
+			Where("id = ?", runner.ID).
+			First(&locked).Error; err != nil {
+			return fmt.Errorf("锁定执行器失败: %w", err)
+		}
+
+		var running int64
+		if err := tx.Model(&models.TaskRun{}).
+			Where("runner_id = ? AND status = ?", runner.ID, "running").
+			Count(&running).Error; err != nil {
+			return fmt.Errorf("统计执行器作业失败: %w", err)
+		}
+		if running >= int64(locked.MaxConcurrentJobs) {
+			full = true
+			return nil
+		}
+
+		now := time.Now()
+		result := tx.Model(&models.TaskRun{}).
+			Where("id = ? AND status = ? AND runner_id IS NULL", taskRunID, "pending").
+			Updates(map[string]interface{}{
+				"status":       "running",
+				"runner_id":    runner.ID,
+				"node_name":    runner.Name,
+				"started_at":   now,
+				"assign_count": gorm.Expr("assign_count + 1"),
+				"updated_at":   now,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("领取作业失败: %w", result.Error)
+		}
+		claimed = result.RowsAffected > 0
+		return nil
+	})
+	return claimed, full, err
+}
+
+// releaseJob 撤销尚未下发的领取，作业回到待领取状态
+func (s *runnerService) releaseJob(runnerID, taskRunID uuid.UUID) {
+	if err := s.db.Model(&models.TaskRun{}).
+		Where("id = ? AND runner_id = ? AND status = ?", taskRunID, runnerID, "running").
+		Updates(map[string]interface{}{
+			"status":       "pending",
+			"runner_id":    nil,
+			"node_name":    nil,
+			"started_at":   nil,
+			"assign_count": gorm.Expr("assign_count - 1"),
+			"updated_at":   time.Now(),
+		}).Error; err != nil {
+		log.Printf("⚠️ 释放作业领取失败 %s: %v", taskRunID, err)
+	}
+}
+
+// failClaimedJob 将已领取但无法下发的作业置为失败并调度后续作业，置为失败不成功时释放领取，避免作业停留在运行中
+func (s *runnerService) failClaimedJob(runnerID, taskRunID uuid.UUID, cause error) {
+	var taskRun models.TaskRun
+	if err := s.db.Select("id", "pipeline_run_id").Where("id = ?", taskRunID).First(&taskRun).Error; err != nil {
+		log.Printf("⚠️ 获取作业失败 %s: %v", taskRunID, err)
+		s.releaseJob(runnerID, taskRunID)
+		return
+	}
+
+	msg := cause.Error()
+	if err := s.runService.UpdateTaskRunStatus(taskRunID, &TaskRunStatusUpdate{
+		Status:       "failed",
+		ErrorMessage: &msg,
+	}); err != nil {
+		log.Printf("⚠️ 更新作业状态失败 %s: %v", taskRunID, err)
+		s.releaseJob(runnerID, taskRunID)
+		return
+	}
+	s.scheduleRun(taskRun.PipelineRunID)
+}
+
+// buildRunnerJob 组装下发给执行器的作业
+func (s *runnerService) buildRunnerJob(taskRunID uuid.UUID) (*RunnerJob, error) {
+	var taskRun models.TaskRun
+	if err := s.db.Where("id = ?", taskRunID).Preload("PipelineRun").Preload("PipelineRun.Pipeline").
+		First(&taskRun).Error; err != nil {
+		return nil, fmt.Errorf("获取作业失败: %w", err)
+	}
+
+	job := &RunnerJob{
+		ID:            taskRun.ID,
+		PipelineRunID: taskRun.PipelineRunID,
+	}
+	if err := jsonUnmarshal(taskRun.JobSpec, &job.Spec); err != nil {
+		return nil, fmt.Errorf("%w: %v", errJobSpecInvalid, err)
+	}
+	if run := taskRun.PipelineRun; run != nil {
+		job.PipelineID = run.PipelineID
+		job.RunNumber = run.RunNumber
+		job.CommitSHA = run.CommitSHA
+		job.Branch = run.Branch
+		if run.Pipeline != nil {
+			job.RepositoryID = run.Pipeline.RepositoryID
+		}
+	}
+
+	if len(job.Spec.Secrets) > 0 {
+		if taskRun.PipelineRun == nil || taskRun.PipelineRun.Pipeline == nil || s.secrets == nil {
+			return nil, fmt.Errorf("%w: 无法确定所属项目", errJobSecretsUnavailable)
+		}
+		env, err := s.secrets.ResolveEnv(context.Background(), taskRun.PipelineRun.Pipeline.ProjectID, job.Spec.Secrets)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errJobSecretsUnavailable, err)
+		}
+		if job.Spec.Env == nil {
+			job.Spec.Env = make(map[string]string, len(env))
+		}
+		for key, value := range env {
+			job.Spec.Env[key] = value
+		}
+	}
+	return job, nil
+}
+
+// AppendJobLog 追加作业日志，offset 必须等于已接收的长度，返回追加后的长度
+func (s *runnerService) AppendJobLog(runner *models.Runner, jobID uuid.UUID, offset int64, data []byte) (int64, error) {
+	taskRun, err := s.assignedJob(runner, jobID)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.logService.Append(taskRun.PipelineRunID, taskRun.ID, offset, data)
+}
+
+// ListJobArtifacts 解析作业依赖的产物
+func (s *runnerService) ListJobArtifacts(runner *models.Runner, jobID uuid.UUID) ([]RunnerJobArtifact, error) {
+	taskRun, err := s.assignedJob(runner, jobID)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := s.resolveJobArtifacts(taskRun)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := make([]RunnerJobArtifact, 0, len(resolved))
+	for _, artifact := range resolved {
+		artifacts = append(artifacts, RunnerJobArtifact{
+			ID:     artifact.ID,
+			Name:   artifact.Name,
+			Path:   artifact.DestinationPath(),
+			Size:   artifact.Size,
+			SHA256: artifact.SHA256,
+		})
+	}
+	return artifacts, nil
+}
+
+// OpenJobArtifact 读取作业依赖的产物，只能下载作业声明依赖的产物
+func (s *runnerService) OpenJobArtifact(ctx context.Context, runner *models.Runner, jobID, artifactID uuid.UUID) (*models.Artifact, io.ReadCloser, error) {
+	taskRun, err := s.assignedJob(runner, jobID)
+	if err != nil {
+		return nil, nil, err
+	}
+	resolved, err := s.resolveJobArtifacts(taskRun)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, artifact := range resolved {
+		if artifact.ID == artifactID {
+			reader, err := s.artifacts.Open(ctx, &artifact.Artifact)
+			if err != nil {
+				return nil, nil, err
+			}
+			return &artifact.Artifact, reader, nil
+		}
+	}
+	return nil, nil, ErrJobArtifactNotFound
+}
+
+// UploadJobArtifact 保存执行器上传的产物，路径必须匹配作业声明的产物路径
+func (s *runnerService) UploadJobArtifact(ctx context.Context, runner *models.Runner, jobID uuid.UUID, filePath, checksum string, reader io.Reader) (*models.Artifact, error) {
+	taskRun, err := s.assignedJob(runner, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec RunnerJobSpec
+	if err := jsonUnmarshal(taskRun.JobSpec, &spec); err != nil {
+		return nil, fmt.Errorf("解析作业定义失败: %w", err)
+	}
+	if spec.Artifacts == nil || !ArtifactPathMatches(spec.Artifacts.Paths, filePath) {
+		return nil, ErrJobArtifactNotDeclared
+	}
+
+	var run models.PipelineRun
+	if err := s.db.Where("id = ?", taskRun.PipelineRunID).First(&run).Error; err != nil {
+		return nil, fmt.Errorf("获取流水线运行失败: %w", err)
+	}
+
+	return s.artifacts.Store(ctx, &ArtifactUpload{
+		PipelineID:     run.PipelineID,
+		PipelineRunID:  taskRun.PipelineRunID,
+		TaskRunID:      taskRun.ID,
+		TaskName:       taskRun.Name,
+		Name:           spec.Artifacts.Name,
+		Path:           filePath,
+		ExpireInDays:   spec.Artifacts.ExpireInDays,
+		ExpectedSHA256: checksum,
+	}, reader)
+}
+
+// resolveJobArtifacts 按作业定义快照解析依赖的产物
+func (s *runnerService) resolveJobArtifacts(taskRun *models.TaskRun) ([]ResolvedArtifact, error) {
+	var spec RunnerJobSpec
+	if err := jsonUnmarshal(taskRun.JobSpec, &spec); err != nil {
+		return nil, fmt.Errorf("解析作业定义失败: %w", err)
+	}
+	if len(spec.ArtifactsFrom) == 0 {
+		return nil, nil
+	}
+
+	var run models.PipelineRun
+	if err := s.db.Where("id = ?", taskRun.PipelineRunID).Preload("Pipeline").First(&run).Error; err != nil {
+		return nil, fmt.Errorf("获取流水线运行失败: %w", err)
+	}
+	if run.Pipeline == nil {
+		return nil, fmt.Errorf("流水线不存在")
+	}
+	return s.artifacts.ResolveDependencies(run.Pipeline, run.ID, spec.ArtifactsFrom)
+}
+
+// UpdateJobStatus 处理执行器上报的作业状态，作业结束后调度后续任务
+func (s *runnerService) UpdateJobStatus(runner *models.Runner, jobID uuid.UUID, req *JobStatusRequest) error {
+	taskRun, err := s.assignedJob(runner, jobID)
+	if err != nil {
+		return err
+	}
+
+	// 错误信息可能包含任务输出，与日志一样脱敏后保存
+	errorMessage := req.ErrorMessage
+	if errorMessage != nil {
+		redacted := s.logService.Redact(taskRun.PipelineRunID, *errorMessage)
+		errorMessage = &redacted
+	}
+
+	if err := s.runService.UpdateTaskRunStatus(taskRun.ID, &TaskRunStatusUpdate{
+		Status:       req.Status,
+		ExitCode:     req.ExitCode,
+		ErrorMessage: errorMessage,
+		RetryCount:   req.RetryCount,
+	}); err != nil {
+		return err
+	}
+
+	if req.Status != "running" {
+		s.scheduleRun(taskRun.PipelineRunID)
+	}
+	return nil
+}
+
+// assignedJob 获取分配给执行器且仍在执行的作业
+func (s *runnerService) assignedJob(runner *models.Runner, jobID uuid.UUID) (*models.TaskRun, error) {
+	var taskRun models.TaskRun
+	if err := s.db.Where("id = ? AND runner_id = ?", jobID, runner.ID).First(&taskRun).Error; err != nil {
+		return nil, ErrJobNotAssigned
+	}
+	if taskRun.Status != "running" {
+		return nil, ErrJobCancelled
+	}
+	return &taskRun, nil
+}
+
+// DetectOfflineRunners 将超时未联系的执行器标记为离线，并重新分配其作业
+func (s *runnerService) DetectOfflineRunners() error {
+	cutoff := time.Now().Add(-time.Duration(s.config.Runner.OfflineTimeout) * time.Second)
+
+	var runners []models.Runner
+	if err := s.db.Where("status = ? AND deleted_at IS NULL", "online").
+		Where("last_contact_at IS NULL OR last_contact_at < ?", cutoff).
+		Find(&runners).Error; err != nil {
+		return fmt.Errorf("查询离线执行器失败: %w", err)
+	}
+
+	for _, runner := range runners {
+		result := s.db.Model(&models.Runner{}).
+			Where("id = ? AND status = ?", runner.ID, "online").
+			Updates(map[string]interface{}{
+				"status":     "offline",
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		log.Printf("⚠️ 执行器 %s(%s) 超时未联系，标记为离线", runner.Name, runner.ID)
+		s.releaseRunnerJobs(runner.ID, "执行器离线")
+	}
+	return nil
+}
+
+// ExpireTimedOutRuns 结束超过流水线超时时间仍未完成的执行器运行（如没有执行器具备所需标签）
+func (s *runnerService) ExpireTimedOutRuns() error {
+	var runs []models.PipelineRun
+	if err := s.db.Where("status = ? AND started_at IS NOT NULL", "running").
+		Where("EXISTS (SELECT 1 FROM task_runs WHERE task_runs.pipeline_run_id = pipeline_runs.id AND task_runs.job_spec IS NOT NULL)").
+		Preload("Pipeline").
+		Find(&runs).Error; err != nil {
+		return fmt.Errorf("查询执行中的运行失败: %w", err)
+	}
+
+	for _, run := range runs {
+		if run.Pipeline == nil || run.Pipeline.Config.Timeout <= 0 {
+			continue
+		}
+		timeout := time.Duration(run.Pipeline.Config.Timeout) * time.Second
+		if time.Since(*run.StartedAt) < timeout {
+			continue
+		}
+
+		if err := s.Cancel(context.Background(), run.ID); err != nil {
+			log.Printf("⚠️ 终止超时运行的作业失败 %s: %v", run.ID, err)
+			continue
+		}
+		message := fmt.Sprintf("流水线运行超时(%d秒)", run.Pipeline.Config.Timeout)
+		if err := s.runService.UpdateStatus(run.ID, "timeout", &message); err != nil {
+			log.Printf("⚠️ 回写流水线运行状态失败 %s: %v", run.ID, err)
+		}
+	}
+	return nil
+}
+
+// releaseRunnerJobs 释放执行器未完成的作业：未超过重新分配次数的重新排队，否则标记失败
+func (s *runnerService) releaseRunnerJobs(runnerID uuid.UUID, reason string) {
+	var taskRuns []models.TaskRun
+	if err := s.db.Where("runner_id = ? AND status = ?", runnerID, "running").Find(&taskRuns).Error; err != nil {
+		log.Printf("⚠️ 查询执行器作业失败 %s: %v", runnerID, err)
+		return
+	}
+
+	requeued := false
+	for _, taskRun := range taskRuns {
+		if taskRun.AssignCount > s.config.Runner.MaxReassignments {
+			message := fmt.Sprintf("%s，作业已分配 %d 次，不再重新分配", reason, taskRun.AssignCount)
+			if err := s.runService.UpdateTaskRunStatus(taskRun.ID, &TaskRunStatusUpdate{
+				Status:       "failed",
+				ErrorMessage: &message,
+			}); err != nil {
+				log.Printf("⚠️ 更新作业状态失败 %s: %v", taskRun.ID, err)
+			}
+			s.scheduleRun(taskRun.PipelineRunID)
+			continue
+		}
+
+		result := s.db.Model(&models.TaskRun{}).
+			Where("id = ? AND runner_id = ? AND status = ?", taskRun.ID, runnerID, "running").
+			Updates(map[string]interface{}{
+				"status":     "pending",
+				"runner_id":  nil,
+				"node_name":  nil,
+				"started_at": nil,
+				"logs_path":  nil,
+				"queued_at":  time.Now(),
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil {
+			log.Printf("⚠️ 重新分配作业失败 %s: %v", taskRun.ID, result.Error)
+			continue
+		}
+
+		// 新执行器从头上传日志和产物，丢弃上一次未完成的输出
+		if err := s.logService.Reset(taskRun.PipelineRunID, taskRun.ID); err != nil {
+			log.Printf("⚠️ 清空作业日志失败 %s: %v", taskRun.ID, err)
+		}
+		if err := s.artifacts.DeleteByTaskRun(context.Background(), taskRun.ID); err != nil {
+			log.Printf("⚠️ 清理作业产物失败 %s: %v", taskRun.ID, err)
+		}
+		requeued = true
+	}
+
+	if requeued {
+		s.notifyJobs()
+	}
+}
+
+// Name 执行器名称
+func (s *runnerService) Name() string {
+	return "runner"
+}
+
+// Execute 为运行的每个任务记录作业定义快照，并将依赖已满足的作业放入队列。
+// 执行条件和插值在放行作业时求值，以便引用上游任务结果
+func (s *runnerService) Execute(ctx context.Context, req *ExecutionRequest) error {
+	for _, task := range req.Pipeline.Tasks {
+		taskRunID, ok := req.TaskRunIDs[task.Name]
+		if !ok {
+			continue
+		}
+
+		spec := RunnerJobSpec{
+			Name:       task.Name,
+			Image:      task.Image,
+			Command:    task.Command,
+			Args:       task.Args,
+			WorkingDir: task.WorkingDir,
+			Env:        taskEnvironment(req, task),
+			Timeout:    task.Timeout,
+			Retries:    task.Retries,
+			DependsOn:  task.DependsOn,
+			ArtifactsFrom: taskArtifactDependencies(&task),
+			Secrets:    taskSecretReferences(&task),
+			Matrix:     req.Matrix[task.Name],
+		}
+		if task.Environment != nil {
+			spec.Environment = *task.Environment
+		}
+		if taskNeedsEvaluation(&task) {
+			template := &RunnerJobTemplate{Condition: task.Condition, Scope: newExpressionScope(req)}
+			if len(task.Env) > 0 {
+				if err := json.Unmarshal(task.Env, &template.Env); err != nil {
+					template.Env = nil
+				}
+			}
+			spec.Template = template
+		}
+		if artifactSpec := taskArtifactSpec(&task); artifactSpec != nil {
+			artifactSpec.ExpireInDays = s.artifacts.ExpireDays(req.Pipeline, artifactSpec)
+			spec.Artifacts = artifactSpec
+		}
+		specJSON, err := json.Marshal(spec)
+		if err != nil {
+			return fmt.Errorf("序列化作业定义失败: %w", err)
+		}
+
+		runnerTags := task.RunnerTags
+		if len(runnerTags) == 0 {
+			runnerTags = datatypes.JSON("[]")
+		}
+
+		if err := s.db.Model(&models.TaskRun{}).Where("id = ?", taskRunID).Updates(map[string]interface{}{
+			"job_spec":    datatypes.JSON(specJSON),
+			"runner_tags": runnerTags,
+			"updated_at":  time.Now(),
+		}).Error; err != nil {
+			return fmt.Errorf("保存作业定义失败: %w", err)
+		}
+	}
+
+	s.scheduleRun(req.Run.ID)
+	return nil
+}
+
+// Cancel 取消运行中未结束的作业，执行器通过心跳或状态上报得知后终止执行
+func (s *runnerService) Cancel(ctx context.Context, runID uuid.UUID) error {
+	now := time.Now()
+	if err := s.db.Model(&models.TaskRun{}).
+		Where("pipeline_run_id = ? AND status IN ?", runID, []string{"pending", "running"}).
+		Updates(map[string]interface{}{
+			"status":        "cancelled",
+			"finished_at":   now,
+			"error_message": "流水线运行已取消",
+			"updated_at":    now,
+		}).Error; err != nil {
+		return fmt.Errorf("取消作业失败: %w", err)
+	}
+	return nil
+}
+
+// ScheduleRun 重新调度运行中等待的作业
+func (s *runnerService) ScheduleRun(runID uuid.UUID) {
+	s.scheduleRun(runID)
+}
+
+// scheduleRun 根据依赖关系、执行条件和部署审批放行或跳过等待中的作业，所有作业结束后回写运行状态。
+// 矩阵任务的组合受 max_parallel 限制，fail_fast 时任一组合失败即取消同一矩阵的其余组合
+func (s *runnerService) scheduleRun(runID uuid.UUID) {
+	s.scheduleMu.Lock()
+	defer s.scheduleMu.Unlock()
+
+	var run models.PipelineRun
+	if err := s.db.Where("id = ?", runID).First(&run).Error; err != nil || run.Status != "running" {
+		return
+	}
+
+	var taskRuns []models.TaskRun
+	if err := s.db.Where("pipeline_run_id = ?", runID).Order("created_at ASC").Find(&taskRuns).Error; err != nil {
+		log.Printf("⚠️ 查询作业失败 %s: %v", runID, err)
+		return
+	}
+
+	known := make(map[string]bool, len(taskRuns))
+	states := make(map[string]string, len(taskRuns))
+	specs := make(map[string]*RunnerJobSpec, len(taskRuns))
+	matrix := make(map[string]*MatrixCombination)
+	for _, taskRun := range taskRuns {
+		known[taskRun.Name] = true
+		var spec RunnerJobSpec
+		if err := jsonUnmarshal(taskRun.JobSpec, &spec); err == nil {
+			specs[taskRun.Name] = &spec
+			if spec.Matrix != nil {
+				matrix[taskRun.Name] = spec.Matrix
+			}
+		}
+		// 已放行的作业视为进行中，依赖它的作业需要等待
+		if taskRun.Status == "pending" && taskRun.QueuedAt != nil {
+			states[taskRun.Name] = "running"
+		} else if taskRun.Status != "pending" {
+			states[taskRun.Name] = taskRun.Status
+		}
+	}
+	matrixKnownTasks(known, matrix)
+	s.cancelFailedMatrix(taskRuns, states, matrix)
+
+	queued := false
+	var projectID uuid.UUID // 部署审批需要的项目ID，有部署作业时才查询
+	for changed := true; changed; {
+		changed = false
+		for _, taskRun := range taskRuns {
+			if _, seen := states[taskRun.Name]; seen {
+				continue
+			}
+
+			spec := specs[taskRun.Name]
+			if spec == nil {
+				spec = &RunnerJobSpec{}
+			}
+			var condition *string
+			if spec.Template != nil {
+				condition = spec.Template.Condition
+			}
+			logical := logicalTaskStates(states, matrix)
+			gate, reason := checkTaskGate(spec.DependsOn, condition, logical, known, func() map[string]interface{} {
+				return taskExpressionScope(spec.Template.Scope, logical, spec.Matrix)
+			})
+			if gate == gateSkip || gate == gateFail {
+				s.finishJob(&taskRun, states, gate, reason)
+				changed = true
+				continue
+			}
+			if gate == gateWait {
+				continue
+			}
+			if combination := matrix[taskRun.Name]; combination != nil && combination.MaxParallel > 0 &&
+				matrixRunning(states, matrix, combination.Task) >= combination.MaxParallel {
+				continue
+			}
+			if spec.Environment != "" && s.gate != nil {
+				if projectID == uuid.Nil {
+					var pipeline models.Pipeline
+					if err := s.db.Select("id", "project_id").Where("id = ?", run.PipelineID).First(&pipeline).Error; err != nil {
+						log.Printf("⚠️ 查询流水线失败 %s: %v", run.PipelineID, err)
+						continue
+					}
+					projectID = pipeline.ProjectID
+				}
+				ready, reason := s.gate.CheckDeployment(&DeploymentGateRequest{
+					ProjectID:     projectID,
+					Run:           &run,
+					TaskRunID:     taskRun.ID,
+					TaskName:      taskRun.Name,
+					Environment:   spec.Environment,
+					ArtifactsFrom: spec.ArtifactsFrom,
+				})
+				if reason != "" {
+					s.finishJob(&taskRun, states, gateFail, reason)
+					changed = true
+					continue
+				}
+				if !ready {
+					continue
+				}
+			}
+
+			updates := map[string]interface{}{
+				"queued_at":  time.Now(),
+				"updated_at": time.Now(),
+			}
+			if spec.Template != nil {
+				specJSON, err := renderJobSpec(spec, logical)
+				if err != nil {
+					s.finishJob(&taskRun, states, gateFail, err.Error())
+					changed = true
+					continue
+				}
+				updates["job_spec"] = datatypes.JSON(specJSON)
+			}
+
+			states[taskRun.Name] = "running"
+			if err := s.db.Model(&models.TaskRun{}).
+				Where("id = ? AND queued_at IS NULL", taskRun.ID).
+				Updates(updates).Error; err != nil {
+				log.Printf("⚠️ 放行作业失败 %s: %v", taskRun.ID, err)
+			}
+			queued = true
+			changed = true
+		}
+	}
+
+	if queued {
+		s.notifyJobs()
+	}
+
+	// 所有作业结束后汇总运行状态
+	var failed []string
+	for _, taskRun := range taskRuns {
+		switch states[taskRun.Name] {
+		case "running", "":
+			return
+		case "failed":
+			failed = append(failed, taskRun.Name)
+		}
+	}
+
+	status := "succeeded"
+	var message *string
+	if len(failed) > 0 {
+		status = "failed"
+		msg := fmt.Sprintf("任务执行失败: %s", strings.Join(failed, ", "))
+		message = &msg
+	}
+	if err := s.runService.UpdateStatus(runID, status, message); err != nil {
+		log.Printf("⚠️ 回写流水线运行状态失败 %s: %v", runID, err)
+	}
+}
+
+// finishJob 结束未放行的作业：依赖未成功或条件不满足时跳过，条件无法求值时失败
+func (s *runnerService) finishJob(taskRun *models.TaskRun, states map[string]string, gate taskGate, reason string) {
+	status := "skipped"
+	if gate == gateFail {
+		status = "failed"
+	}
+	states[taskRun.Name] = status
+	if err := s.runService.UpdateTaskRunStatus(taskRun.ID, &TaskRunStatusUpdate{
+		Status:       status,
+		ErrorMessage: &reason,
+	}); err != nil {
+		log.Printf("⚠️ 更新作业状态失败 %s: %v", taskRun.ID, err)
+	}
+}
+
+// renderJobSpec 替换作业命令、参数和任务环境变量中的插值，返回求值后的作业定义
+func renderJobSpec(spec *RunnerJobSpec, states map[string]string) ([]byte, error) {
+	scope := taskExpressionScope(spec.Template.Scope, states, spec.Matrix)
+	command, err := renderStrings("command", spec.Command, scope)
+	if err != nil {
+		return nil, err
+	}
+	args, err := renderStrings("args", spec.Args, scope)
+	if err != nil {
+		return nil, err
+	}
+	env, err := renderEnv(spec.Template.Env, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered := *spec
+	rendered.Command, rendered.Args, rendered.Template = command, args, nil
+	rendered.Env = make(map[string]string, len(spec.Env))
+	for key, value := range spec.Env {
+		rendered.Env[key] = value
+	}
+	for key, value := range env {
+		rendered.Env[key] = value
+	}
+	specJSON, err := json.Marshal(rendered)
+	if err != nil {
+		return nil, fmt.Errorf("序列化作业定义失败: %w", err)
+	}
+	return specJSON, nil
+}
+
+// cancelFailedMatrix 取消 fail_fast 矩阵中已有组合失败时其余未结束的组合，
+// 执行中的作业由执行器通过心跳或状态上报得知后终止
+func (s *runnerService) cancelFailedMatrix(taskRuns []models.TaskRun, states map[string]string, matrix map[string]*MatrixCombination) {
+	failed := make(map[string]string)
+	for _, taskRun := range taskRuns {
+		if combination := matrix[taskRun.Name]; combination != nil && combination.FailFast && states[taskRun.Name] == "failed" {
+			if _, ok := failed[combination.Task]; !ok {
+				failed[combination.Task] = taskRun.Name
+			}
+		}
+	}
+	if len(failed) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for _, taskRun := range taskRuns {
+		combination := matrix[taskRun.Name]
+		if combination == nil || failed[combination.Task] == "" {
+			continue
+		}
+		if state, seen := states[taskRun.Name]; seen && state != "running" {
+			continue
+		}
+
+		msg := fmt.Errorf("%w: %s", errMatrixFailFast, failed[combination.Task]).Error()
+		result := s.db.Model(&models.TaskRun{}).
+			Where("id = ? AND status IN ?", taskRun.ID, []string{"pending", "running"}).
+			Updates(map[string]interface{}{
+				"status":        "cancelled",
+				"finished_at":   now,
+				"error_message": msg,
+				"updated_at":    now,
+			})
+		if result.Error != nil {
+			log.Printf("⚠️ 取消矩阵作业失败 %s: %v", taskRun.ID, result.Error)
+			continue
+		}
+		// 作业已自行结束时状态以执行器上报为准，由其结束时的调度处理
+		if result.RowsAffected > 0 {
+			states[taskRun.Name] = "cancelled"
+		}
+	}
+}
+
+// jobSignalChan 获取当前的作业通知通道
+func (s *runnerService) jobSignalChan() <-chan struct{} {
+	s.signalMu.Lock()
+	defer s.signalMu.Unlock()
+	return s.jobSignal
+}
+
+// notifyJobs 唤醒等待作业的长轮询
+func (s *runnerService) notifyJobs() {
+	s.signalMu.Lock()
+	defer s.signalMu.Unlock()
+	close(s.jobSignal)
+	s.jobSignal = make(chan struct{})
+}
+
+// runnerTagsMatch 作业要求的标签必须全部被执行器具备
+func runnerTagsMatch(jobTags, runnerTags []string) bool {
+	for _, tag := range jobTags {
+		if !containsString(runnerTags, strings.ToLower(strings.TrimSpace(tag))) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeRunnerTags 标签去空白、转小写、去重并排序
+func normalizeRunnerTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// generateRunnerToken 生成带前缀的随机令牌
+func generateRunnerToken(prefix string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成令牌失败: %w", err)
+	}
+	return prefix + hex.EncodeToString(buf), nil
+}
+
+// hashRunnerToken 令牌只保存SHA-256哈希
+func hashRunnerToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
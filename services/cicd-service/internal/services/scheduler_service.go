@@ -0,0 +1,349 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"cicd-service/internal/config"
+	"cicd-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 定时触发错过时间点的处理策略
+const (
+	// ScheduleMissedSkip 跳过错过的时间点，只执行延迟不超过容忍时间的最近一次
+	ScheduleMissedSkip = "skip"
+	// ScheduleMissedCatchUp 补跑错过的每个时间点，最多 scheduler.max_catch_up 次
+	ScheduleMissedCatchUp = "catch_up"
+)
+
+// scheduleDueBatch 每次检查最多处理的到期定时触发数量
+const scheduleDueBatch = 100
+
+// cronParser 标准5段cron表达式，支持 @daily、@every 1h 等描述符
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ScheduleTrigger schedule 触发器的条件
+//
+//	triggers:
+//	  - type: schedule
+//	    conditions:
+//	      cron: "0 2 * * *"
+//	      timezone: Asia/Shanghai
+//	      branch: main
+//	      missed: catch_up
+type ScheduleTrigger struct {
+	Cron     string  // cron表达式
+	Timezone string  // IANA时区，缺省UTC
+	Branch   *string // 构建的分支，缺省使用仓库默认分支
+	Missed   string  // skip（缺省）或 catch_up
+
+	schedule cron.Schedule
+}
+
+// ParseScheduleTrigger 解析并校验 schedule 触发器条件
+func ParseScheduleTrigger(conditions map[string]interface{}) (*ScheduleTrigger, error) {
+	text := func(key string) (string, error) {
+		value, ok := conditions[key]
+		if !ok || value == nil {
+			return "", nil
+		}
+		s, ok := value.(string)
+		if !ok {
+			return "", fmt.Errorf("conditions.%s 必须是字符串", key)
+		}
+		return strings.TrimSpace(s), nil
+	}
+
+	trigger := &ScheduleTrigger{}
+	var err error
+	if trigger.Cron, err = text("cron"); err != nil {
+		return nil, err
+	}
+	if trigger.Timezone, err = text("timezone"); err != nil {
+		return nil, err
+	}
+	if trigger.Missed, err = text("missed"); err != nil {
+		return nil, err
+	}
+	branch, err := text("branch")
+	if err != nil {
+		return nil, err
+	}
+	if branch != "" {
+		trigger.Branch = &branch
+	}
+
+	if trigger.Timezone == "" {
+		trigger.Timezone = "UTC"
+	}
+	if trigger.Missed == "" {
+		trigger.Missed = ScheduleMissedSkip
+	}
+	if trigger.Missed != ScheduleMissedSkip && trigger.Missed != ScheduleMissedCatchUp {
+		return nil, fmt.Errorf("conditions.missed 无效: %q（可选 %s, %s）", trigger.Missed, ScheduleMissedSkip, ScheduleMissedCatchUp)
+	}
+
+	trigger.schedule, err = parseCronSchedule(trigger.Cron, trigger.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	if trigger.schedule.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("conditions.cron 没有可触发的时间点: %q", trigger.Cron)
+	}
+	return trigger, nil
+}
+
+// parseCronSchedule 按时区解析cron表达式，时区只能通过 timezone 指定
+func parseCronSchedule(expr, timezone string) (cron.Schedule, error) {
+	if expr == "" {
+		return nil, fmt.Errorf("conditions.cron 不能为空")
+	}
+	if strings.HasPrefix(expr, "TZ=") || strings.HasPrefix(expr, "CRON_TZ=") {
+		return nil, fmt.Errorf("conditions.cron 不能包含时区，请使用 conditions.timezone")
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return nil, fmt.Errorf("conditions.timezone 无效: %q", timezone)
+	}
+	schedule, err := cronParser.Parse("CRON_TZ=" + timezone + " " + expr)
+	if err != nil {
+		return nil, fmt.Errorf("conditions.cron 无效: %w", err)
+	}
+	return schedule, nil
+}
+
+// SchedulerService 定时触发服务：根据流水线的 schedule 触发器按时创建运行。
+// 多个副本可以同时运行，到期的时间点通过条件更新认领，只会被一个副本触发
+type SchedulerService interface {
+	// SyncSchedules 根据流水线触发器配置同步定时触发状态
+	SyncSchedules() error
+	// RunDueSchedules 触发到期的定时触发，返回创建的运行数量
+	RunDueSchedules() (int, error)
+}
+
+type schedulerService struct {
+	db         *gorm.DB
+	config     *config.Config
+	runService PipelineRunService
+}
+
+// NewSchedulerService 创建定时触发服务实例
+func NewSchedulerService(db *gorm.DB, cfg *config.Config, runService PipelineRunService) SchedulerService {
+	return &schedulerService{
+		db:         db,
+		config:     cfg,
+		runService: runService,
+	}
+}
+
+// scheduleKey 定时触发状态的唯一键
+type scheduleKey struct {
+	pipelineID   uuid.UUID
+	triggerIndex int
+}
+
+// SyncSchedules 为启用的 schedule 触发器创建状态，删除已移除的触发器和已禁用、删除流水线的状态。
+// 表达式、时区、分支或策略变化后从当前时间重新计算下一个时间点
+func (s *schedulerService) SyncSchedules() error {
+	var pipelines []models.Pipeline
+	if err := s.db.Select("id", "triggers").
+		Where("status = ? AND deleted_at IS NULL AND CAST(triggers AS TEXT) LIKE ?", "active", "%schedule%").
+		Find(&pipelines).Error; err != nil {
+		return fmt.Errorf("获取定时触发流水线失败: %w", err)
+	}
+
+	desired := make(map[scheduleKey]*ScheduleTrigger)
+	for _, pipeline := range pipelines {
+		var triggers []TriggerConfig
+		if err := jsonUnmarshal(pipeline.Triggers, &triggers); err != nil {
+			log.Printf("⚠️ 流水线 %s 的触发器配置无效: %v", pipeline.ID, err)
+			continue
+		}
+		for i, trigger := range triggers {
+			if trigger.Type != "schedule" || !trigger.Enabled {
+				continue
+			}
+			parsed, err := ParseScheduleTrigger(trigger.Conditions)
+			if err != nil {
+				log.Printf("⚠️ 流水线 %s 的定时触发器 triggers[%d] 无效: %v", pipeline.ID, i, err)
+				continue
+			}
+			desired[scheduleKey{pipeline.ID, i}] = parsed
+		}
+	}
+
+	var existing []models.PipelineSchedule
+	if err := s.db.Find(&existing).Error; err != nil {
+		return fmt.Errorf("获取定时触发状态失败: %w", err)
+	}
+
+	now := time.Now()
+	for _, schedule := range existing {
+		key := scheduleKey{schedule.PipelineID, schedule.TriggerIndex}
+		trigger, ok := desired[key]
+		delete(desired, key)
+		if !ok {
+			if err := s.db.Delete(&models.PipelineSchedule{}, "id = ?", schedule.ID).Error; err != nil {
+				return fmt.Errorf("删除定时触发状态失败: %w", err)
+			}
+			continue
+		}
+		if schedule.Cron == trigger.Cron && schedule.Timezone == trigger.Timezone &&
+			stringPtrEqual(schedule.Branch, trigger.Branch) && schedule.MissedPolicy == trigger.Missed {
+			continue
+		}
+		if err := s.db.Model(&models.PipelineSchedule{}).Where("id = ?", schedule.ID).Updates(map[string]interface{}{
+			"cron":          trigger.Cron,
+			"timezone":      trigger.Timezone,
+			"branch":        trigger.Branch,
+			"missed_policy": trigger.Missed,
+			"next_run_at":   trigger.schedule.Next(now).UTC(),
+			"updated_at":    now,
+		}).Error; err != nil {
+			return fmt.Errorf("更新定时触发状态失败: %w", err)
+		}
+	}
+
+	for key, trigger := range desired {
+		schedule := &models.PipelineSchedule{
+			ID:           uuid.New(),
+			PipelineID:   key.pipelineID,
+			TriggerIndex: key.triggerIndex,
+			Cron:         trigger.Cron,
+			Timezone:     trigger.Timezone,
+			Branch:       trigger.Branch,
+			MissedPolicy: trigger.Missed,
+			NextRunAt:    trigger.schedule.Next(now).UTC(),
+		}
+		// 其他副本可能同时创建，以先创建的为准
+		if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(schedule).Error; err != nil {
+			return fmt.Errorf("创建定时触发状态失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// RunDueSchedules 触发到期的定时触发
+func (s *schedulerService) RunDueSchedules() (int, error) {
+	now := time.Now()
+	var due []models.PipelineSchedule
+	if err := s.db.Where("next_run_at <= ?", now.UTC()).
+		Order("next_run_at ASC").
+		Limit(scheduleDueBatch).
+		Find(&due).Error; err != nil {
+		return 0, fmt.Errorf("获取到期定时触发失败: %w", err)
+	}
+
+	created := 0
+	for i := range due {
+		created += s.fire(&due[i], now)
+	}
+	return created, nil
+}
+
+// fire 认领定时触发到期的时间点并创建运行，返回创建的运行数量。
+// 认领在创建运行之前完成，实例在两者之间退出时该时间点不会再触发
+func (s *schedulerService) fire(schedule *models.PipelineSchedule, now time.Time) int {
+	cronSchedule, err := parseCronSchedule(schedule.Cron, schedule.Timezone)
+	if err != nil {
+		log.Printf("⚠️ 定时触发 %s 的表达式无效: %v", schedule.ID, err)
+		return 0
+	}
+
+	slots := s.dueSlots(schedule, cronSchedule, now)
+	next := cronSchedule.Next(now).UTC()
+
+	// 条件更新 next_run_at，只有一个副本能认领这一批时间点
+	result := s.db.Model(&models.PipelineSchedule{}).
+		Where("id = ? AND next_run_at = ?", schedule.ID, schedule.NextRunAt).
+		Updates(map[string]interface{}{
+			"next_run_at": next,
+			"updated_at":  now,
+		})
+	if result.Error != nil {
+		log.Printf("⚠️ 认领定时触发 %s 失败: %v", schedule.ID, result.Error)
+		return 0
+	}
+	if result.RowsAffected == 0 {
+		return 0
+	}
+
+	if len(slots) == 0 {
+		log.Printf("⏭️ 流水线 %s 的定时触发错过 %s 起的时间点，按 skip 策略跳过",
+			schedule.PipelineID, schedule.NextRunAt.Format(time.RFC3339))
+		return 0
+	}
+
+	created := 0
+	for _, slot := range slots {
+		scheduledAt := slot
+		run, err := s.runService.Create(&CreatePipelineRunRequest{
+			PipelineID:  schedule.PipelineID,
+			TriggerType: "schedule",
+			TriggerData: map[string]interface{}{
+				"cron":          schedule.Cron,
+				"timezone":      schedule.Timezone,
+				"trigger_index": schedule.TriggerIndex,
+				"scheduled_at":  slot.Format(time.RFC3339),
+			},
+			ScheduledAt: &scheduledAt,
+			Branch:      schedule.Branch,
+		})
+
+		updates := map[string]interface{}{
+			"last_scheduled_at": slot,
+			"updated_at":        time.Now(),
+		}
+		if err != nil {
+			log.Printf("⚠️ 流水线 %s 定时触发 %s 创建运行失败: %v", schedule.PipelineID, slot.Format(time.RFC3339), err)
+			updates["last_error"] = err.Error()
+		} else {
+			updates["last_run_id"] = run.ID
+			updates["last_error"] = nil
+			created++
+		}
+		if err := s.db.Model(&models.PipelineSchedule{}).Where("id = ?", schedule.ID).Updates(updates).Error; err != nil {
+			log.Printf("⚠️ 更新定时触发状态失败 %s: %v", schedule.ID, err)
+		}
+	}
+	return created
+}
+
+// dueSlots 计算本次需要触发的时间点（按时间升序）：
+// skip 只取延迟不超过 scheduler.misfire_grace 的最近一个时间点；
+// catch_up 取全部错过的时间点，超过 scheduler.max_catch_up 时只保留最近的部分
+func (s *schedulerService) dueSlots(schedule *models.PipelineSchedule, cronSchedule cron.Schedule, now time.Time) []time.Time {
+	from := schedule.NextRunAt
+	limit := max(s.config.Scheduler.MaxCatchUp, 1)
+	if schedule.MissedPolicy != ScheduleMissedCatchUp {
+		limit = 1
+		// 延迟超过容忍时间的时间点直接跳过，不需要逐个计算
+		cutoff := now.Add(-time.Duration(s.config.Scheduler.MisfireGrace) * time.Second)
+		if from.Before(cutoff) {
+			from = cronSchedule.Next(cutoff.Add(-time.Second))
+		}
+	}
+
+	var slots []time.Time
+	for slot := from; !slot.IsZero() && !slot.After(now); slot = cronSchedule.Next(slot) {
+		slots = append(slots, slot.UTC())
+		if len(slots) > limit {
+			slots = slots[1:]
+		}
+	}
+	return slots
+}
+
+// stringPtrEqual 比较两个可选字符串
+func stringPtrEqual(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
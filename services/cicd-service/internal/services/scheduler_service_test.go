@@ -0,0 +1,90 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"cicd-service/internal/config"
+	"cicd-service/internal/models"
+)
+
+func TestDueSlots(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name       string
+		cron       string
+		timezone   string
+		policy     string
+		maxCatchUp int
+		nextRunAt  time.Time
+		now        time.Time
+		want       []time.Time
+	}{
+		{"skip not due yet", "0 * * * *", "UTC", ScheduleMissedSkip, 3, at(11, 0), at(10, 30), nil},
+		{"skip within grace", "0 * * * *", "UTC", ScheduleMissedSkip, 3, at(10, 0), at(10, 3), []time.Time{at(10, 0)}},
+		{"skip beyond grace", "0 * * * *", "UTC", ScheduleMissedSkip, 3, at(10, 0), at(10, 30), nil},
+		{"skip keeps only latest slot", "0 * * * *", "UTC", ScheduleMissedSkip, 3, at(6, 0), at(10, 3), []time.Time{at(10, 0)}},
+		{"skip is default policy", "0 * * * *", "UTC", "", 3, at(6, 0), at(10, 3), []time.Time{at(10, 0)}},
+		{"catch up all missed", "0 * * * *", "UTC", ScheduleMissedCatchUp, 3, at(8, 0), at(10, 30), []time.Time{at(8, 0), at(9, 0), at(10, 0)}},
+		{"catch up keeps most recent", "0 * * * *", "UTC", ScheduleMissedCatchUp, 3, at(2, 0), at(10, 30), []time.Time{at(8, 0), at(9, 0), at(10, 0)}},
+		{"catch up ignores grace", "0 * * * *", "UTC", ScheduleMissedCatchUp, 3, at(10, 0), at(10, 30), []time.Time{at(10, 0)}},
+		{"catch up limit at least one", "0 * * * *", "UTC", ScheduleMissedCatchUp, 0, at(8, 0), at(10, 30), []time.Time{at(10, 0)}},
+		{"timezone slots returned in utc", "0 2 * * *", "Asia/Shanghai", ScheduleMissedCatchUp, 3, at(18, 0).AddDate(0, 0, -2), at(19, 0), []time.Time{at(18, 0).AddDate(0, 0, -2), at(18, 0).AddDate(0, 0, -1), at(18, 0)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &schedulerService{config: &config.Config{Scheduler: config.SchedulerConfig{MisfireGrace: 300, MaxCatchUp: tt.maxCatchUp}}}
+			cronSchedule, err := parseCronSchedule(tt.cron, tt.timezone)
+			if err != nil {
+				t.Fatalf("parseCronSchedule: %v", err)
+			}
+			schedule := &models.PipelineSchedule{MissedPolicy: tt.policy, NextRunAt: tt.nextRunAt}
+
+			got := s.dueSlots(schedule, cronSchedule, tt.now)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("dueSlots = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScheduleTrigger(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions map[string]interface{}
+		wantMissed string
+		wantErr    bool
+	}{
+		{"defaults", map[string]interface{}{"cron": "0 2 * * *"}, ScheduleMissedSkip, false},
+		{"catch up", map[string]interface{}{"cron": "@daily", "missed": "catch_up"}, ScheduleMissedCatchUp, false},
+		{"invalid missed", map[string]interface{}{"cron": "0 2 * * *", "missed": "replay"}, "", true},
+		{"missing cron", map[string]interface{}{}, "", true},
+		{"timezone in cron", map[string]interface{}{"cron": "CRON_TZ=UTC 0 2 * * *"}, "", true},
+		{"invalid timezone", map[string]interface{}{"cron": "0 2 * * *", "timezone": "Mars/Base"}, "", true},
+		{"never fires", map[string]interface{}{"cron": "0 0 30 2 *"}, "", true},
+		{"non-string cron", map[string]interface{}{"cron": 5}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trigger, err := ParseScheduleTrigger(tt.conditions)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got trigger %+v", trigger)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseScheduleTrigger: %v", err)
+			}
+			if trigger.Missed != tt.wantMissed {
+				t.Fatalf("missed = %q, want %q", trigger.Missed, tt.wantMissed)
+			}
+		})
+	}
+}
@@ -0,0 +1,495 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"cicd-service/internal/config"
+	"cicd-service/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// secretMaxDataSize 单个密钥数据明文总大小上限
+	secretMaxDataSize = 64 * 1024
+	// secretRotateBatchSize 密钥轮换每批处理的数量
+	secretRotateBatchSize = 100
+)
+
+var (
+	// ErrSecretNotFound 密钥不存在
+	ErrSecretNotFound = errors.New("密钥不存在")
+	// ErrSecretExists 同一项目下密钥名称已存在
+	ErrSecretExists = errors.New("密钥名称已存在")
+	// ErrProjectNotFound 项目不存在或不属于当前租户
+	ErrProjectNotFound = errors.New("项目不存在")
+
+	secretNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	envVarNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	secretTypes       = []string{"generic", "docker-registry", "ssh-auth"}
+)
+
+// SecretService 项目密钥服务接口，密钥数据采用信封加密存储，接口只返回元数据
+type SecretService interface {
+	Create(tenantID uuid.UUID, req *CreateSecretRequest) (*models.Secret, error)
+	List(tenantID, projectID uuid.UUID) ([]models.Secret, error)
+	GetByID(tenantID, projectID, id uuid.UUID) (*models.Secret, error)
+	Update(tenantID, projectID, id uuid.UUID, req *UpdateSecretRequest) (*models.Secret, error)
+	Delete(tenantID, projectID, id uuid.UUID) error
+
+	// RotateKeys 为租户的全部密钥生成新的数据密钥并用当前主密钥加密，返回轮换数量
+	RotateKeys(ctx context.Context, tenantID uuid.UUID) (int, error)
+	// RewrapStaleKeys 将仍由旧主密钥加密的数据密钥改用当前主密钥加密，返回处理数量
+	RewrapStaleKeys(ctx context.Context) (int, error)
+
+	// ResolveEnv 解密任务引用的密钥，返回注入任务的环境变量
+	ResolveEnv(ctx context.Context, projectID uuid.UUID, refs []SecretReference) (map[string]string, error)
+	// ProjectValues 解密项目全部密钥的值，仅用于日志脱敏
+	ProjectValues(ctx context.Context, projectID uuid.UUID) ([]string, error)
+}
+
+type secretService struct {
+	db     *gorm.DB
+	config *config.Config
+	kek    KEKProvider
+}
+
+// NewSecretService 创建密钥服务实例
+func NewSecretService(db *gorm.DB, cfg *config.Config, kek KEKProvider) SecretService {
+	return &secretService{
+		db:     db,
+		config: cfg,
+		kek:    kek,
+	}
+}
+
+// CreateSecretRequest 创建密钥请求
+type CreateSecretRequest struct {
+	ProjectID   uuid.UUID         `json:"project_id"`
+	Name        string            `json:"name" binding:"required,max=255"`
+	Type        string            `json:"type"`
+	Description *string           `json:"description"`
+	Data        map[string]string `json:"data" binding:"required"`
+	CreatedBy   uuid.UUID         `json:"-"`
+}
+
+// UpdateSecretRequest 更新密钥请求，data 不为空时整体替换密钥数据
+type UpdateSecretRequest struct {
+	Description *string           `json:"description"`
+	Data        map[string]string `json:"data"`
+}
+
+// Create 创建密钥
+func (s *secretService) Create(tenantID uuid.UUID, req *CreateSecretRequest) (*models.Secret, error) {
+	if err := s.checkProject(tenantID, req.ProjectID); err != nil {
+		return nil, err
+	}
+	if req.Type == "" {
+		req.Type = "generic"
+	}
+	if err := validateSecretRequest(req.Name, req.Type, req.Data); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Model(&models.Secret{}).
+		Where("project_id = ? AND name = ?", req.ProjectID, req.Name).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("检查密钥名称失败: %w", err)
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSecretExists, req.Name)
+	}
+
+	secret := &models.Secret{
+		ID:          uuid.New(),
+		ProjectID:   req.ProjectID,
+		Name:        req.Name,
+		Type:        req.Type,
+		Description: req.Description,
+		Version:     1,
+		CreatedBy:   req.CreatedBy,
+	}
+	if err := s.seal(context.Background(), secret, req.Data); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(secret).Error; err != nil {
+		return nil, fmt.Errorf("创建密钥失败: %w", err)
+	}
+	return secret, nil
+}
+
+// List 获取项目的密钥列表
+func (s *secretService) List(tenantID, projectID uuid.UUID) ([]models.Secret, error) {
+	if err := s.checkProject(tenantID, projectID); err != nil {
+		return nil, err
+	}
+
+	var secrets []models.Secret
+	if err := s.db.Where("project_id = ?", projectID).Order("name ASC").Find(&secrets).Error; err != nil {
+		return nil, fmt.Errorf("获取密钥列表失败: %w", err)
+	}
+	return secrets, nil
+}
+
+// GetByID 获取密钥元数据
+func (s *secretService) GetByID(tenantID, projectID, id uuid.UUID) (*models.Secret, error) {
+	if err := s.checkProject(tenantID, projectID); err != nil {
+		return nil, err
+	}
+
+	var secret models.Secret
+	if err := s.db.Where("id = ? AND project_id = ?", id, projectID).First(&secret).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSecretNotFound
+		}
+		return nil, fmt.Errorf("获取密钥失败: %w", err)
+	}
+	return &secret, nil
+}
+
+// Update 更新密钥描述或替换密钥数据，替换数据时生成新的数据密钥
+func (s *secretService) Update(tenantID, projectID, id uuid.UUID, req *UpdateSecretRequest) (*models.Secret, error) {
+	secret, err := s.GetByID(tenantID, projectID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"updated_at": time.Now(),
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Data != nil {
+		if err := validateSecretRequest(secret.Name, secret.Type, req.Data); err != nil {
+			return nil, err
+		}
+		if err := s.seal(context.Background(), secret, req.Data); err != nil {
+			return nil, err
+		}
+		updates["value_encrypted"] = secret.ValueEncrypted
+		updates["dek_encrypted"] = secret.DEKEncrypted
+		updates["kek_ref"] = secret.KEKRef
+		updates["keys"] = secret.Keys
+		updates["version"] = gorm.Expr("version + 1")
+	}
+
+	if err := s.db.Model(&models.Secret{}).Where("id = ?", secret.ID).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("更新密钥失败: %w", err)
+	}
+	return s.GetByID(tenantID, projectID, id)
+}
+
+// Delete 删除密钥
+func (s *secretService) Delete(tenantID, projectID, id uuid.UUID) error {
+	secret, err := s.GetByID(tenantID, projectID, id)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Delete(&models.Secret{}, "id = ?", secret.ID).Error; err != nil {
+		return fmt.Errorf("删除密钥失败: %w", err)
+	}
+	return nil
+}
+
+// RotateKeys 为租户的全部密钥重新加密：解密后用新的数据密钥和当前主密钥加密
+func (s *secretService) RotateKeys(ctx context.Context, tenantID uuid.UUID) (int, error) {
+	query := s.db.Model(&models.Secret{}).
+		Joins("JOIN projects ON projects.id = secrets.project_id").
+		Where("projects.tenant_id = ?", tenantID)
+	return s.reencrypt(ctx, query, true)
+}
+
+// RewrapStaleKeys 将旧主密钥加密的数据密钥改用当前主密钥加密，密钥数据密文不变
+func (s *secretService) RewrapStaleKeys(ctx context.Context) (int, error) {
+	current, err := s.kek.CurrentRef()
+	if err != nil {
+		return 0, err
+	}
+	return s.reencrypt(ctx, s.db.Model(&models.Secret{}).Where("secrets.kek_ref <> ?", current), false)
+}
+
+// reencrypt 按批重新加密 query 匹配的密钥，newDEK 为 true 时同时更换数据密钥
+func (s *secretService) reencrypt(ctx context.Context, query *gorm.DB, newDEK bool) (int, error) {
+	rotated := 0
+	var lastID uuid.UUID
+	for {
+		var secrets []models.Secret
+		if err := query.Session(&gorm.Session{}).
+			Select("secrets.*").
+			Where("secrets.id > ?", lastID).
+			Order("secrets.id ASC").
+			Limit(secretRotateBatchSize).
+			Find(&secrets).Error; err != nil {
+			return rotated, fmt.Errorf("查询待轮换密钥失败: %w", err)
+		}
+		if len(secrets) == 0 {
+			return rotated, nil
+		}
+
+		for i := range secrets {
+			secret := &secrets[i]
+			lastID = secret.ID
+			if err := s.reencryptSecret(ctx, secret, newDEK); err != nil {
+				return rotated, fmt.Errorf("轮换密钥 %s 失败: %w", secret.Name, err)
+			}
+			rotated++
+		}
+	}
+}
+
+// reencryptSecret 重新加密单个密钥，以读取时的密文为条件更新，避免覆盖并发写入的新值
+func (s *secretService) reencryptSecret(ctx context.Context, secret *models.Secret, newDEK bool) error {
+	dek, err := s.kek.UnwrapKey(ctx, secret.KEKRef, secret.DEKEncrypted)
+	if err != nil {
+		return err
+	}
+	oldDEK := secret.DEKEncrypted
+
+	updates := map[string]interface{}{}
+	if newDEK {
+		data, err := openSecretData(dek, secret)
+		if err != nil {
+			return err
+		}
+		if err := s.seal(ctx, secret, data); err != nil {
+			return err
+		}
+		updates["value_encrypted"] = secret.ValueEncrypted
+	} else {
+		ref, wrapped, err := s.kek.WrapKey(ctx, dek)
+		if err != nil {
+			return err
+		}
+		secret.KEKRef, secret.DEKEncrypted = ref, wrapped
+	}
+
+	now := time.Now()
+	updates["dek_encrypted"] = secret.DEKEncrypted
+	updates["kek_ref"] = secret.KEKRef
+	updates["rotated_at"] = now
+	updates["updated_at"] = now
+	return s.db.Model(&models.Secret{}).
+		Where("id = ? AND dek_encrypted = ?", secret.ID, oldDEK).
+		Updates(updates).Error
+}
+
+// ResolveEnv 解密任务引用的密钥，返回环境变量名到值的映射
+func (s *secretService) ResolveEnv(ctx context.Context, projectID uuid.UUID, refs []SecretReference) (map[string]string, error) {
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		names = append(names, ref.Name)
+	}
+	var secrets []models.Secret
+	if err := s.db.Where("project_id = ? AND name IN ?", projectID, names).Find(&secrets).Error; err != nil {
+		return nil, fmt.Errorf("获取密钥失败: %w", err)
+	}
+	byName := make(map[string]*models.Secret, len(secrets))
+	for i := range secrets {
+		byName[secrets[i].Name] = &secrets[i]
+	}
+
+	env := make(map[string]string)
+	decrypted := make(map[string]map[string]string, len(secrets))
+	for _, ref := range refs {
+		secret, ok := byName[ref.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrSecretNotFound, ref.Name)
+		}
+		data, ok := decrypted[ref.Name]
+		if !ok {
+			dek, err := s.kek.UnwrapKey(ctx, secret.KEKRef, secret.DEKEncrypted)
+			if err != nil {
+				return nil, fmt.Errorf("解密密钥 %s 失败: %w", ref.Name, err)
+			}
+			if data, err = openSecretData(dek, secret); err != nil {
+				return nil, fmt.Errorf("解密密钥 %s 失败: %w", ref.Name, err)
+			}
+			decrypted[ref.Name] = data
+		}
+
+		if ref.Key == "" {
+			// 数据键在写入时已校验为有效的环境变量名
+			for key, value := range data {
+				env[key] = value
+			}
+			continue
+		}
+		value, ok := data[ref.Key]
+		if !ok {
+			return nil, fmt.Errorf("密钥 %s 中不存在键 %q", ref.Name, ref.Key)
+		}
+		name := ref.Env
+		if name == "" {
+			name = ref.Key
+		}
+		env[name] = value
+	}
+
+	ids := make([]uuid.UUID, 0, len(secrets))
+	for _, secret := range secrets {
+		ids = append(ids, secret.ID)
+	}
+	if err := s.db.Model(&models.Secret{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+		"usage_count":  gorm.Expr("usage_count + 1"),
+		"last_used_at": time.Now(),
+	}).Error; err != nil {
+		log.Printf("⚠️ 更新密钥使用记录失败: %v", err)
+	}
+	return env, nil
+}
+
+// ProjectValues 解密项目全部密钥的值，不计入使用记录
+func (s *secretService) ProjectValues(ctx context.Context, projectID uuid.UUID) ([]string, error) {
+	var secrets []models.Secret
+	if err := s.db.Where("project_id = ?", projectID).Find(&secrets).Error; err != nil {
+		return nil, fmt.Errorf("获取密钥失败: %w", err)
+	}
+
+	var values []string
+	for i := range secrets {
+		secret := &secrets[i]
+		dek, err := s.kek.UnwrapKey(ctx, secret.KEKRef, secret.DEKEncrypted)
+		if err != nil {
+			return nil, fmt.Errorf("解密密钥 %s 失败: %w", secret.Name, err)
+		}
+		data, err := openSecretData(dek, secret)
+		if err != nil {
+			return nil, fmt.Errorf("解密密钥 %s 失败: %w", secret.Name, err)
+		}
+		for _, value := range data {
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+// checkProject 检查项目属于当前租户
+func (s *secretService) checkProject(tenantID, projectID uuid.UUID) error {
+	return checkProjectTenant(s.db, tenantID, projectID)
+}
+
+// checkProjectTenant 检查项目属于指定租户
+func checkProjectTenant(db *gorm.DB, tenantID, projectID uuid.UUID) error {
+	var count int64
+	if err := db.Model(&models.Project{}).
+		Where("id = ? AND tenant_id = ?", projectID, tenantID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("获取项目失败: %w", err)
+	}
+	if count == 0 {
+		return ErrProjectNotFound
+	}
+	return nil
+}
+
+// seal 生成新的数据密钥加密密钥数据，并用当前主密钥加密数据密钥
+func (s *secretService) seal(ctx context.Context, secret *models.Secret, data map[string]string) error {
+	plaintext, err := jsonMarshal(data)
+	if err != nil {
+		return fmt.Errorf("序列化密钥数据失败: %w", err)
+	}
+
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return fmt.Errorf("生成数据密钥失败: %w", err)
+	}
+	ciphertext, err := sealAESGCM(dek, plaintext, secretAdditionalData(secret))
+	if err != nil {
+		return fmt.Errorf("加密密钥数据失败: %w", err)
+	}
+	ref, wrapped, err := s.kek.WrapKey(ctx, dek)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	keysJSON, err := jsonMarshal(keys)
+	if err != nil {
+		return fmt.Errorf("序列化密钥键名失败: %w", err)
+	}
+
+	secret.ValueEncrypted = ciphertext
+	secret.DEKEncrypted = wrapped
+	secret.KEKRef = ref
+	secret.Keys = keysJSON
+	return nil
+}
+
+// openSecretData 使用数据密钥解密密钥数据
+func openSecretData(dek []byte, secret *models.Secret) (map[string]string, error) {
+	plaintext, err := openAESGCM(dek, secret.ValueEncrypted, secretAdditionalData(secret))
+	if err != nil {
+		return nil, fmt.Errorf("解密密钥数据失败: %w", err)
+	}
+	var data map[string]string
+	if err := jsonUnmarshal(plaintext, &data); err != nil {
+		return nil, fmt.Errorf("解析密钥数据失败: %w", err)
+	}
+	return data, nil
+}
+
+// secretAdditionalData 密文绑定的附加认证数据，防止密文被复制到其他密钥记录
+func secretAdditionalData(secret *models.Secret) []byte {
+	return []byte(secret.ProjectID.String() + "/" + secret.ID.String())
+}
+
+// validateSecretRequest 校验密钥名称、类型和数据
+func validateSecretRequest(name, secretType string, data map[string]string) error {
+	if !secretNamePattern.MatchString(name) || len(name) > 255 {
+		return fmt.Errorf("密钥名称只能包含字母、数字、'_'、'.'和'-'，且以字母或数字开头")
+	}
+	if !containsString(secretTypes, secretType) {
+		return fmt.Errorf("不支持的密钥类型: %s（可选 %s）", secretType, strings.Join(secretTypes, ", "))
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("密钥数据不能为空")
+	}
+
+	size := 0
+	for key, value := range data {
+		if !envVarNamePattern.MatchString(key) {
+			return fmt.Errorf("密钥数据键 %q 无效，只能包含字母、数字和'_'，且不能以数字开头", key)
+		}
+		size += len(key) + len(value)
+	}
+	if size > secretMaxDataSize {
+		return fmt.Errorf("密钥数据不能超过%dKB", secretMaxDataSize/1024)
+	}
+	return nil
+}
+
+// IsEnvVarName 判断是否为有效的环境变量名
+func IsEnvVarName(name string) bool {
+	return envVarNamePattern.MatchString(name)
+}
+
+// taskSecretReferences 读取任务的密钥引用
+func taskSecretReferences(task *models.Task) []SecretReference {
+	var refs []SecretReference
+	if len(task.Secrets) > 0 {
+		if err := jsonUnmarshal(task.Secrets, &refs); err != nil {
+			return nil
+		}
+	}
+	return refs
+}
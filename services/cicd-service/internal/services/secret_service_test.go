@@ -0,0 +1,224 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cicd-service/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB 创建临时SQLite数据库并执行建表语句
+func newTestDB(t *testing.T, schema ...string) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=5000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	for _, statement := range schema {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("schema: %v", err)
+		}
+	}
+	return db
+}
+
+// kekLine 生成一行随机主密钥
+func kekLine(t *testing.T, id string) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return id + ":" + base64.StdEncoding.EncodeToString(key)
+}
+
+// writeKEKFile 写入主密钥文件，并推进修改时间以触发提供方重新加载
+func writeKEKFile(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte("# test\n"+strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatalf("write kek file: %v", err)
+	}
+	next := time.Now().Add(time.Duration(len(lines)+1) * time.Second)
+	if err := os.Chtimes(path, next, next); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+}
+
+// newTestKEKProvider 基于临时密钥文件创建主密钥提供方
+func newTestKEKProvider(t *testing.T, lines ...string) (KEKProvider, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "kek.keys")
+	writeKEKFile(t, path, lines...)
+	provider, err := NewLocalKEKProvider(path, false)
+	if err != nil {
+		t.Fatalf("NewLocalKEKProvider: %v", err)
+	}
+	return provider, path
+}
+
+// openSecret 用提供方解密数据密钥后解密密钥数据
+func openSecret(kek KEKProvider, secret *models.Secret) (map[string]string, error) {
+	dek, err := kek.UnwrapKey(context.Background(), secret.KEKRef, secret.DEKEncrypted)
+	if err != nil {
+		return nil, err
+	}
+	return openSecretData(dek, secret)
+}
+
+func TestSecretEnvelopeRoundTrip(t *testing.T) {
+	kek, _ := newTestKEKProvider(t, kekLine(t, "k1"))
+	s := &secretService{kek: kek}
+	data := map[string]string{"username": "deploy", "password": "s3cr3t-value"}
+
+	secret := &models.Secret{ID: uuid.New(), ProjectID: uuid.New()}
+	if err := s.seal(context.Background(), secret, data); err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if secret.KEKRef != "local:k1" {
+		t.Fatalf("kek ref = %q, want local:k1", secret.KEKRef)
+	}
+	if bytes.Contains(secret.ValueEncrypted, []byte("s3cr3t-value")) {
+		t.Fatal("ciphertext contains plaintext")
+	}
+	if string(secret.Keys) != `["password","username"]` {
+		t.Fatalf("keys = %s", secret.Keys)
+	}
+
+	got, err := openSecret(kek, secret)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if !reflect.DeepEqual(got, data) {
+		t.Fatalf("data = %v, want %v", got, data)
+	}
+
+	// 密文绑定密钥记录，复制到其他记录后无法解密
+	copied := *secret
+	copied.ID = uuid.New()
+	if _, err := openSecret(kek, &copied); err == nil {
+		t.Fatal("expected copied ciphertext to fail")
+	}
+}
+
+func TestSecretWrongKEK(t *testing.T) {
+	kek, _ := newTestKEKProvider(t, kekLine(t, "k1"))
+	secret := &models.Secret{ID: uuid.New(), ProjectID: uuid.New()}
+	if err := (&secretService{kek: kek}).seal(context.Background(), secret, map[string]string{"token": "x"}); err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+
+	tampered := bytes.Clone(secret.DEKEncrypted)
+	tampered[len(tampered)-1] ^= 0xff
+
+	tests := []struct {
+		name         string
+		lines        []string
+		ref          string
+		wrapped      []byte
+		wantNotFound bool
+	}{
+		{"same id different key", []string{kekLine(t, "k1")}, secret.KEKRef, secret.DEKEncrypted, false},
+		{"unknown key id", []string{kekLine(t, "k2")}, secret.KEKRef, secret.DEKEncrypted, true},
+		{"foreign provider ref", nil, "vault:k1", secret.DEKEncrypted, true},
+		{"tampered wrapped key", nil, secret.KEKRef, tampered, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := kek
+			if tt.lines != nil {
+				provider, _ = newTestKEKProvider(t, tt.lines...)
+			}
+			_, err := provider.UnwrapKey(context.Background(), tt.ref, tt.wrapped)
+			if err == nil {
+				t.Fatal("expected unwrap to fail")
+			}
+			if errors.Is(err, ErrKEKNotFound) != tt.wantNotFound {
+				t.Fatalf("err = %v, want ErrKEKNotFound %v", err, tt.wantNotFound)
+			}
+		})
+	}
+}
+
+func TestRewrapStaleKeys(t *testing.T) {
+	db := newTestDB(t, `CREATE TABLE secrets (id TEXT PRIMARY KEY, project_id TEXT, name TEXT, type TEXT,
+		description TEXT, keys TEXT, value_encrypted BLOB, kek_ref TEXT, dek_encrypted BLOB, version INTEGER,
+		rotated_at DATETIME, usage_count INTEGER, last_used_at DATETIME, created_by TEXT,
+		created_at DATETIME, updated_at DATETIME)`)
+
+	oldKey := kekLine(t, "k1")
+	kek, path := newTestKEKProvider(t, oldKey)
+	s := &secretService{db: db, kek: kek}
+
+	values := map[string]map[string]string{
+		"registry": {"password": "one"},
+		"deploy":   {"ssh_key": "two"},
+	}
+	ciphertexts := make(map[string][]byte)
+	for name, data := range values {
+		secret := &models.Secret{ID: uuid.New(), ProjectID: uuid.New(), Name: name, Type: "generic"}
+		if err := s.seal(context.Background(), secret, data); err != nil {
+			t.Fatalf("seal: %v", err)
+		}
+		if err := db.Create(secret).Error; err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		ciphertexts[name] = secret.ValueEncrypted
+	}
+
+	// 追加新主密钥后重新加密数据密钥，密钥数据密文不变
+	newKey := kekLine(t, "k2")
+	writeKEKFile(t, path, oldKey, newKey)
+	rotated, err := s.RewrapStaleKeys(context.Background())
+	if err != nil || rotated != 2 {
+		t.Fatalf("RewrapStaleKeys = %d, %v, want 2", rotated, err)
+	}
+	if rotated, err := s.RewrapStaleKeys(context.Background()); err != nil || rotated != 0 {
+		t.Fatalf("second RewrapStaleKeys = %d, %v, want 0", rotated, err)
+	}
+
+	// 旧主密钥移除后仍能解密
+	writeKEKFile(t, path, newKey)
+
+	var secrets []models.Secret
+	if err := db.Find(&secrets).Error; err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	for i := range secrets {
+		secret := &secrets[i]
+		if secret.KEKRef != "local:k2" || secret.RotatedAt == nil {
+			t.Fatalf("secret %s kek ref = %q rotated_at = %v", secret.Name, secret.KEKRef, secret.RotatedAt)
+		}
+		if !bytes.Equal(secret.ValueEncrypted, ciphertexts[secret.Name]) {
+			t.Fatalf("secret %s ciphertext changed", secret.Name)
+		}
+		got, err := openSecret(kek, secret)
+		if err != nil {
+			t.Fatalf("open %s: %v", secret.Name, err)
+		}
+		if !reflect.DeepEqual(got, values[secret.Name]) {
+			t.Fatalf("secret %s = %v, want %v", secret.Name, got, values[secret.Name])
+		}
+	}
+}
@@ -0,0 +1,439 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"cicd-service/internal/models"
+)
+
+// maxMatrixCombinations 单个矩阵任务允许展开的最大组合数
+const maxMatrixCombinations = 256
+
+var (
+	// matrixAxisPattern 矩阵维度名，同时用于 MATRIX_<维度> 环境变量名
+	matrixAxisPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// matrixExprPattern 任务字段中引用矩阵取值的表达式 ${{ matrix.<维度> }}
+	matrixExprPattern = regexp.MustCompile(`\$\{\{\s*matrix\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+	// matrixSlugPattern 组合目录名中需要替换的字符
+	matrixSlugPattern = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+	errMatrixFailFast = errors.New("矩阵任务的组合执行失败，已取消其余组合")
+)
+
+// TaskMatrix 任务矩阵配置，任务按 axes 各维度取值的笛卡尔积展开，每个组合一个任务运行
+//
+//	matrix:
+//	  axes:
+//	    go: ["1.21", "1.22"]
+//	    os: [linux, windows]
+//	  exclude:
+//	    - go: "1.21"
+//	      os: windows
+//	  include:
+//	    - go: "1.22"
+//	      os: linux
+//	      race: "true"
+//	  max_parallel: 2
+//	  fail_fast: false
+//
+// exclude 从笛卡尔积中去掉匹配的组合；include 条目不改变已有维度取值时合并到匹配的组合中，
+// 否则作为新组合追加（与 GitHub Actions 一致）
+type TaskMatrix struct {
+	Axes        map[string][]string `yaml:"axes" json:"axes,omitempty"`
+	Include     []map[string]string `yaml:"include" json:"include,omitempty"`
+	Exclude     []map[string]string `yaml:"exclude" json:"exclude,omitempty"`
+	MaxParallel int                 `yaml:"max_parallel" json:"max_parallel,omitempty"` // 同时执行的组合数，0表示不限制
+	FailFast    *bool               `yaml:"fail_fast" json:"fail_fast,omitempty"`       // 任一组合失败时取消其余组合，缺省为true
+}
+
+// MatrixCombination 矩阵任务展开后的单个组合
+type MatrixCombination struct {
+	Task        string            `json:"task"`   // 矩阵任务名
+	Values      map[string]string `json:"values"` // 本组合的取值
+	MaxParallel int               `json:"max_parallel,omitempty"`
+	FailFast    bool              `json:"fail_fast"`
+}
+
+// taskMatrixSpec 读取任务的矩阵配置，未配置时返回nil
+func taskMatrixSpec(task *models.Task) *TaskMatrix {
+	if len(task.Matrix) == 0 || string(task.Matrix) == "null" {
+		return nil
+	}
+	var matrix TaskMatrix
+	if err := jsonUnmarshal(task.Matrix, &matrix); err != nil {
+		return nil
+	}
+	return &matrix
+}
+
+// Combinations 展开矩阵组合：先求笛卡尔积，再应用 exclude 和 include
+func (m *TaskMatrix) Combinations() []map[string]string {
+	var combos []map[string]string
+	if len(m.Axes) > 0 {
+		combos = []map[string]string{{}}
+		for _, axis := range sortedAxisNames(m.Axes) {
+			next := make([]map[string]string, 0, len(combos)*len(m.Axes[axis]))
+			for _, combo := range combos {
+				for _, value := range m.Axes[axis] {
+					extended := copyStringMap(combo)
+					extended[axis] = value
+					next = append(next, extended)
+				}
+			}
+			combos = next
+		}
+	}
+
+	kept := combos[:0]
+	for _, combo := range combos {
+		excluded := false
+		for _, entry := range m.Exclude {
+			if matrixEntryMatches(combo, entry) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			kept = append(kept, combo)
+		}
+	}
+	combos = kept
+
+	original := len(combos)
+	for _, entry := range m.Include {
+		merged := false
+		for _, combo := range combos[:original] {
+			if m.includeCompatible(combo, entry) {
+				for key, value := range entry {
+					combo[key] = value
+				}
+				merged = true
+			}
+		}
+		if !merged {
+			combos = append(combos, copyStringMap(entry))
+		}
+	}
+	return combos
+}
+
+// includeCompatible include 条目不改变组合中任何原有维度的取值
+func (m *TaskMatrix) includeCompatible(combo, entry map[string]string) bool {
+	for key, value := range entry {
+		if _, isAxis := m.Axes[key]; isAxis && combo[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// matrixEntryMatches 组合包含条目的全部键值
+func matrixEntryMatches(combo, entry map[string]string) bool {
+	for key, value := range entry {
+		if current, ok := combo[key]; !ok || current != value {
+			return false
+		}
+	}
+	return true
+}
+
+// validateTaskMatrix 校验任务矩阵配置，返回问题列表
+func validateTaskMatrix(field string, m *TaskMatrix) []string {
+	if m == nil {
+		return nil
+	}
+
+	var problems []string
+	if len(m.Axes) == 0 && len(m.Include) == 0 {
+		problems = append(problems, field+" 的 matrix 至少需要一个 axes 维度或 include 组合")
+	}
+
+	product := 1
+	for _, axis := range sortedAxisNames(m.Axes) {
+		values := m.Axes[axis]
+		if !matrixAxisPattern.MatchString(axis) {
+			problems = append(problems, fmt.Sprintf("%s 的 matrix 维度名无效: %q（只能包含字母、数字和下划线，且不能以数字开头）", field, axis))
+		}
+		if len(values) == 0 {
+			problems = append(problems, fmt.Sprintf("%s 的 matrix 维度 %q 没有取值", field, axis))
+		}
+		seen := make(map[string]bool, len(values))
+		for _, value := range values {
+			if seen[value] {
+				problems = append(problems, fmt.Sprintf("%s 的 matrix 维度 %q 的取值 %q 重复", field, axis, value))
+			}
+			seen[value] = true
+		}
+		if product <= maxMatrixCombinations {
+			product *= len(values)
+		}
+	}
+
+	for i, entry := range m.Exclude {
+		if len(entry) == 0 {
+			problems = append(problems, fmt.Sprintf("%s 的 matrix.exclude[%d] 不能为空", field, i))
+		}
+		for key := range entry {
+			if _, ok := m.Axes[key]; !ok {
+				problems = append(problems, fmt.Sprintf("%s 的 matrix.exclude[%d] 引用的维度 %q 不存在", field, i, key))
+			}
+		}
+	}
+	for i, entry := range m.Include {
+		if len(entry) == 0 {
+			problems = append(problems, fmt.Sprintf("%s 的 matrix.include[%d] 不能为空", field, i))
+		}
+		for key := range entry {
+			if !matrixAxisPattern.MatchString(key) {
+				problems = append(problems, fmt.Sprintf("%s 的 matrix.include[%d] 的维度名无效: %q", field, i, key))
+			}
+		}
+	}
+	if m.MaxParallel < 0 {
+		problems = append(problems, field+" 的 matrix.max_parallel 不能为负数")
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+
+	if product > maxMatrixCombinations || product+len(m.Include) > maxMatrixCombinations {
+		return append(problems, fmt.Sprintf("%s 的 matrix 组合数超过上限 %d", field, maxMatrixCombinations))
+	}
+	if len(m.Combinations()) == 0 {
+		problems = append(problems, field+" 的 matrix 排除后没有任何组合")
+	}
+	return problems
+}
+
+// matrixTaskName 组合任务名，如 "test (go=1.21, os=linux)"
+func matrixTaskName(taskName string, values map[string]string) string {
+	keys := sortedKeys(values)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+values[key])
+	}
+	return fmt.Sprintf("%s (%s)", taskName, strings.Join(pairs, ", "))
+}
+
+// matrixSlug 组合的目录名，如 "1.21-linux"，用于区分各组合下载到工作空间的产物
+func matrixSlug(values map[string]string) string {
+	keys := sortedKeys(values)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, strings.Trim(matrixSlugPattern.ReplaceAllString(values[key], "_"), "."))
+	}
+	return strings.Join(parts, "-")
+}
+
+// expandPipelineMatrix 将矩阵任务按组合展开，返回展开后的流水线副本和组合信息（组合任务名 -> 组合）。
+// 组合任务保留矩阵任务的ID和依赖；矩阵任务的 depends_on 引用不变，由 logicalTaskStates 汇总组合状态判断，
+// 同一运行内对矩阵任务的 artifacts_from 改写为下载全部组合的产物，分别放在 <target>/<组合目录> 下
+func expandPipelineMatrix(pipeline *models.Pipeline) (*models.Pipeline, map[string]*MatrixCombination, error) {
+	matrix := make(map[string]*MatrixCombination)
+	members := make(map[string][]string)
+	tasks := make([]models.Task, 0, len(pipeline.Tasks))
+
+	for _, task := range pipeline.Tasks {
+		spec := taskMatrixSpec(&task)
+		if spec == nil {
+			tasks = append(tasks, task)
+			continue
+		}
+		if problems := validateTaskMatrix(fmt.Sprintf("任务 %q", task.Name), spec); len(problems) > 0 {
+			return nil, nil, fmt.Errorf("矩阵配置无效: %s", strings.Join(problems, "; "))
+		}
+
+		failFast := spec.FailFast == nil || *spec.FailFast
+		for _, values := range spec.Combinations() {
+			combination := &MatrixCombination{
+				Task:        task.Name,
+				Values:      values,
+				MaxParallel: spec.MaxParallel,
+				FailFast:    failFast,
+			}
+			expanded, err := matrixTask(task, values)
+			if err != nil {
+				return nil, nil, err
+			}
+			matrix[expanded.Name] = combination
+			members[task.Name] = append(members[task.Name], expanded.Name)
+			tasks = append(tasks, expanded)
+		}
+	}
+	if len(matrix) == 0 {
+		return pipeline, nil, nil
+	}
+
+	for i := range tasks {
+		deps := taskArtifactDependencies(&tasks[i])
+		rewritten := make([]ArtifactDependency, 0, len(deps))
+		changed := false
+		for _, dep := range deps {
+			names, ok := members[dep.Task]
+			if dep.Pipeline != "" || !ok {
+				rewritten = append(rewritten, dep)
+				continue
+			}
+			changed = true
+			for _, name := range names {
+				rewritten = append(rewritten, ArtifactDependency{
+					Task:   name,
+					Name:   dep.Name,
+					Target: path.Join(dep.Target, matrixSlug(matrix[name].Values)),
+				})
+			}
+		}
+		if changed {
+			depsJSON, err := jsonMarshal(rewritten)
+			if err != nil {
+				return nil, nil, fmt.Errorf("序列化任务产物依赖失败: %w", err)
+			}
+			tasks[i].ArtifactsFrom = depsJSON
+		}
+	}
+
+	expanded := *pipeline
+	expanded.Tasks = tasks
+	return &expanded, matrix, nil
+}
+
+// matrixTask 构造单个组合的任务：替换 ${{ matrix.<维度> }} 引用，并注入 MATRIX_<维度> 环境变量
+func matrixTask(task models.Task, values map[string]string) (models.Task, error) {
+	expand := func(s string) string {
+		return matrixExprPattern.ReplaceAllStringFunc(s, func(expr string) string {
+			if value, ok := values[matrixExprPattern.FindStringSubmatch(expr)[1]]; ok {
+				return value
+			}
+			return expr
+		})
+	}
+	expandAll := func(list []string) []string {
+		if list == nil {
+			return nil
+		}
+		result := make([]string, len(list))
+		for i, s := range list {
+			result[i] = expand(s)
+		}
+		return result
+	}
+
+	expanded := task
+	expanded.Name = matrixTaskName(task.Name, values)
+	expanded.Matrix = nil
+	expanded.Image = expand(task.Image)
+	expanded.Command = expandAll(task.Command)
+	expanded.Args = expandAll(task.Args)
+	if task.WorkingDir != nil {
+		workingDir := expand(*task.WorkingDir)
+		expanded.WorkingDir = &workingDir
+	}
+
+	env := make(map[string]string)
+	if len(task.Env) > 0 {
+		if err := jsonUnmarshal(task.Env, &env); err != nil {
+			return expanded, fmt.Errorf("解析任务 %s 的环境变量失败: %w", task.Name, err)
+		}
+	}
+	for key, value := range env {
+		env[key] = expand(value)
+	}
+	for key, value := range values {
+		name := "MATRIX_" + strings.ToUpper(key)
+		if _, ok := env[name]; !ok {
+			env[name] = value
+		}
+	}
+	envJSON, err := jsonMarshal(env)
+	if err != nil {
+		return expanded, fmt.Errorf("序列化任务环境变量失败: %w", err)
+	}
+	expanded.Env = envJSON
+	return expanded, nil
+}
+
+// logicalTaskStates 在任务状态中补充矩阵任务的汇总状态，供依赖判断和表达式使用：
+// 有组合未开始时视为未开始，有组合执行中时为 running，否则按 failed > cancelled 取最严重的结果；
+// 因执行条件跳过的组合不影响汇总，其余组合全部成功为 succeeded，全部跳过为 skipped
+func logicalTaskStates(states map[string]string, matrix map[string]*MatrixCombination) map[string]string {
+	if len(matrix) == 0 {
+		return states
+	}
+
+	logical := make(map[string]string, len(states)+len(matrix))
+	for name, state := range states {
+		logical[name] = state
+	}
+
+	groups := make(map[string][]string)
+	for name, combination := range matrix {
+		groups[combination.Task] = append(groups[combination.Task], name)
+	}
+	for task, names := range groups {
+		if state, ok := aggregateMatrixState(states, names); ok {
+			logical[task] = state
+		}
+	}
+	return logical
+}
+
+// aggregateMatrixState 汇总组合状态，ok 为 false 表示仍有组合未开始
+func aggregateMatrixState(states map[string]string, names []string) (string, bool) {
+	counts := make(map[string]int)
+	for _, name := range names {
+		state, seen := states[name]
+		if !seen {
+			return "", false
+		}
+		counts[state]++
+	}
+
+	for _, state := range []string{"running", "failed", "cancelled", "succeeded"} {
+		if counts[state] > 0 {
+			return state, true
+		}
+	}
+	return "skipped", true
+}
+
+// matrixRunning 统计矩阵任务执行中的组合数
+func matrixRunning(states map[string]string, matrix map[string]*MatrixCombination, task string) int {
+	running := 0
+	for name, combination := range matrix {
+		if combination.Task == task && states[name] == "running" {
+			running++
+		}
+	}
+	return running
+}
+
+// matrixKnownTasks 在已知任务中补充矩阵任务名
+func matrixKnownTasks(known map[string]bool, matrix map[string]*MatrixCombination) {
+	for _, combination := range matrix {
+		known[combination.Task] = true
+	}
+}
+
+// sortedAxisNames 返回排序后的矩阵维度名
+func sortedAxisNames(axes map[string][]string) []string {
+	names := make([]string, 0, len(axes))
+	for name := range axes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// copyStringMap 复制字符串映射
+func copyStringMap(m map[string]string) map[string]string {
+	copied := make(map[string]string, len(m))
+	for key, value := range m {
+		copied[key] = value
+	}
+	return copied
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"cicd-service/internal/models"
+
+	"gorm.io/datatypes"
+)
+
+func TestMatrixCombinations(t *testing.T) {
+	axes := map[string][]string{"go": {"1.21", "1.22"}, "os": {"linux", "windows"}}
+
+	tests := []struct {
+		name   string
+		matrix TaskMatrix
+		want   []map[string]string
+	}{
+		{"cartesian product in axis order", TaskMatrix{Axes: axes}, []map[string]string{
+			{"go": "1.21", "os": "linux"}, {"go": "1.21", "os": "windows"},
+			{"go": "1.22", "os": "linux"}, {"go": "1.22", "os": "windows"},
+		}},
+		{"exclude full combination", TaskMatrix{Axes: axes, Exclude: []map[string]string{{"go": "1.21", "os": "windows"}}}, []map[string]string{
+			{"go": "1.21", "os": "linux"}, {"go": "1.22", "os": "linux"}, {"go": "1.22", "os": "windows"},
+		}},
+		{"exclude partial match", TaskMatrix{Axes: axes, Exclude: []map[string]string{{"os": "windows"}}}, []map[string]string{
+			{"go": "1.21", "os": "linux"}, {"go": "1.22", "os": "linux"},
+		}},
+		{"include merges extra key", TaskMatrix{Axes: axes, Include: []map[string]string{{"go": "1.22", "race": "true"}}}, []map[string]string{
+			{"go": "1.21", "os": "linux"}, {"go": "1.21", "os": "windows"},
+			{"go": "1.22", "os": "linux", "race": "true"}, {"go": "1.22", "os": "windows", "race": "true"},
+		}},
+		{"include with new axis value appended", TaskMatrix{Axes: axes, Include: []map[string]string{{"go": "1.23", "os": "linux"}}}, []map[string]string{
+			{"go": "1.21", "os": "linux"}, {"go": "1.21", "os": "windows"},
+			{"go": "1.22", "os": "linux"}, {"go": "1.22", "os": "windows"},
+			{"go": "1.23", "os": "linux"},
+		}},
+		{"later include overrides earlier", TaskMatrix{Axes: axes, Include: []map[string]string{{"os": "linux", "cgo": "1"}, {"go": "1.22", "cgo": "0"}}}, []map[string]string{
+			{"go": "1.21", "os": "linux", "cgo": "1"}, {"go": "1.21", "os": "windows"},
+			{"go": "1.22", "os": "linux", "cgo": "0"}, {"go": "1.22", "os": "windows", "cgo": "0"},
+		}},
+		{"include does not merge into appended entries", TaskMatrix{Axes: map[string][]string{"go": {"1.21"}}, Include: []map[string]string{{"go": "1.23"}, {"lint": "true"}}}, []map[string]string{
+			{"go": "1.21", "lint": "true"}, {"go": "1.23"},
+		}},
+		{"include applies after exclude", TaskMatrix{Axes: axes, Exclude: []map[string]string{{"os": "windows"}}, Include: []map[string]string{{"go": "1.21", "os": "windows"}}}, []map[string]string{
+			{"go": "1.21", "os": "linux"}, {"go": "1.22", "os": "linux"}, {"go": "1.21", "os": "windows"},
+		}},
+		{"no axes include only", TaskMatrix{Include: []map[string]string{{"target": "amd64"}, {"target": "arm64"}}}, []map[string]string{
+			{"target": "amd64"}, {"target": "arm64"},
+		}},
+		{"axis without values", TaskMatrix{Axes: map[string][]string{"go": {"1.21"}, "os": {}}}, []map[string]string{}},
+		{"everything excluded", TaskMatrix{Axes: map[string][]string{"go": {"1.21"}}, Exclude: []map[string]string{{"go": "1.21"}}}, []map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.matrix.Combinations()
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Combinations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatrixNameAndSlug(t *testing.T) {
+	tests := []struct {
+		values   map[string]string
+		wantName string
+		wantSlug string
+	}{
+		{map[string]string{"os": "linux", "go": "1.21"}, "test (go=1.21, os=linux)", "1.21-linux"},
+		{map[string]string{"image": "golang:1.21/alpine"}, "test (image=golang:1.21/alpine)", "golang_1.21_alpine"},
+		{map[string]string{"name": "my target"}, "test (name=my target)", "my_target"},
+		{map[string]string{"dir": "..hidden."}, "test (dir=..hidden.)", "hidden"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.wantName, func(t *testing.T) {
+			if got := matrixTaskName("test", tt.values); got != tt.wantName {
+				t.Fatalf("matrixTaskName = %q, want %q", got, tt.wantName)
+			}
+			if got := matrixSlug(tt.values); got != tt.wantSlug {
+				t.Fatalf("matrixSlug = %q, want %q", got, tt.wantSlug)
+			}
+		})
+	}
+}
+
+func TestExpandPipelineMatrix(t *testing.T) {
+	pipeline := &models.Pipeline{Tasks: []models.Task{
+		{
+			Name:    "test",
+			Image:   "golang:${{ matrix.go }}",
+			Command: []string{"go", "test", "-tags=${{ matrix.tags }}", "${{ matrix.missing }}"},
+			Env:     datatypes.JSON(`{"GOFLAGS":"-race=${{ matrix.race }}","MATRIX_GO":"custom"}`),
+			Matrix:  datatypes.JSON(`{"axes":{"go":["1.21","1.22"]},"include":[{"go":"1.22","race":"true"}],"max_parallel":1}`),
+		},
+		{
+			Name:          "package",
+			Image:         "alpine",
+			ArtifactsFrom: datatypes.JSON(`[{"task":"test","name":"coverage","target":"reports"},{"task":"lint"}]`),
+		},
+	}}
+
+	expanded, matrix, err := expandPipelineMatrix(pipeline)
+	if err != nil {
+		t.Fatalf("expandPipelineMatrix: %v", err)
+	}
+
+	var names []string
+	for _, task := range expanded.Tasks {
+		names = append(names, task.Name)
+	}
+	if want := []string{"test (go=1.21)", "test (go=1.22, race=true)", "package"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("tasks = %q, want %q", names, want)
+	}
+
+	combination := matrix["test (go=1.22, race=true)"]
+	if combination == nil || combination.Task != "test" || !combination.FailFast || combination.MaxParallel != 1 {
+		t.Fatalf("combination = %+v", combination)
+	}
+
+	second := expanded.Tasks[1]
+	if second.Image != "golang:1.22" || second.Matrix != nil {
+		t.Fatalf("image = %q matrix = %s", second.Image, second.Matrix)
+	}
+	if want := []string{"go", "test", "-tags=${{ matrix.tags }}", "${{ matrix.missing }}"}; !reflect.DeepEqual(second.Command, want) {
+		t.Fatalf("command = %q, want %q", second.Command, want)
+	}
+	var env map[string]string
+	if err := jsonUnmarshal(second.Env, &env); err != nil {
+		t.Fatalf("env: %v", err)
+	}
+	if want := map[string]string{"GOFLAGS": "-race=true", "MATRIX_GO": "custom", "MATRIX_RACE": "true"}; !reflect.DeepEqual(env, want) {
+		t.Fatalf("env = %v, want %v", env, want)
+	}
+
+	deps := taskArtifactDependencies(&expanded.Tasks[2])
+	want := []ArtifactDependency{
+		{Task: "test (go=1.21)", Name: "coverage", Target: "reports/1.21"},
+		{Task: "test (go=1.22, race=true)", Name: "coverage", Target: "reports/1.22-true"},
+		{Task: "lint"},
+	}
+	if !reflect.DeepEqual(deps, want) {
+		t.Fatalf("artifacts_from = %+v, want %+v", deps, want)
+	}
+
+	// 原流水线不受影响
+	if len(pipeline.Tasks) != 2 || pipeline.Tasks[0].Image != "golang:${{ matrix.go }}" {
+		t.Fatalf("original pipeline modified: %+v", pipeline.Tasks[0])
+	}
+
+	plain := &models.Pipeline{Tasks: []models.Task{{Name: "build", Image: "alpine"}}}
+	if got, matrix, err := expandPipelineMatrix(plain); err != nil || got != plain || matrix != nil {
+		t.Fatalf("pipeline without matrix = %v, %v, %v", got, matrix, err)
+	}
+
+	invalid := &models.Pipeline{Tasks: []models.Task{{Name: "test", Matrix: datatypes.JSON(`{"axes":{"go":[]}}`)}}}
+	if _, _, err := expandPipelineMatrix(invalid); err == nil {
+		t.Fatal("expected invalid matrix to fail")
+	}
+}
+
+func TestAggregateMatrixState(t *testing.T) {
+	names := []string{"a", "b", "c"}
+
+	tests := []struct {
+		name   string
+		states map[string]string
+		want   string
+		wantOK bool
+	}{
+		{"combination not started", map[string]string{"a": "succeeded", "b": "succeeded"}, "", false},
+		{"running wins", map[string]string{"a": "failed", "b": "running", "c": "succeeded"}, "running", true},
+		{"failed over cancelled", map[string]string{"a": "cancelled", "b": "failed", "c": "succeeded"}, "failed", true},
+		{"cancelled over succeeded", map[string]string{"a": "cancelled", "b": "succeeded", "c": "succeeded"}, "cancelled", true},
+		{"skipped ignored", map[string]string{"a": "skipped", "b": "succeeded", "c": "succeeded"}, "succeeded", true},
+		{"all skipped", map[string]string{"a": "skipped", "b": "skipped", "c": "skipped"}, "skipped", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := aggregateMatrixState(tt.states, names)
+			if got != tt.want || ok != tt.wantOK {
+				t.Fatalf("aggregateMatrixState = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	t.Run("logical states", func(t *testing.T) {
+		matrix := map[string]*MatrixCombination{"a": {Task: "test"}, "b": {Task: "test"}}
+		states := map[string]string{"a": "succeeded", "b": "failed", "build": "succeeded"}
+		want := map[string]string{"a": "succeeded", "b": "failed", "build": "succeeded", "test": "failed"}
+		if got := logicalTaskStates(states, matrix); !reflect.DeepEqual(got, want) {
+			t.Fatalf("logicalTaskStates = %v, want %v", got, want)
+		}
+	})
+}
@@ -2,7 +2,12 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
 	"time"
 
 	"cicd-service/internal/config"
@@ -15,12 +20,12 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/api/resource"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/watch"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
+	"knative.dev/pkg/apis"
 )
 
 // TektonService Tekton集成服务接口
@@ -43,13 +48,13 @@ type TektonService interface {
 	WatchPipelineRuns(ctx context.Context) error
 	WatchTaskRuns(ctx context.Context) error
 	
-	// 资源管理
-	CreateSecret(ctx context.Context, secret *models.Secret) error
-	UpdateSecret(ctx context.Context, secret *models.Secret) error
-	DeleteSecret(ctx context.Context, secretID uuid.UUID) error
-	
 	// 健康检查
 	HealthCheck(ctx context.Context) error
+
+	// SetRunService 设置状态回写的流水线运行服务（两者互相依赖，创建后注入）
+	SetRunService(runService PipelineRunService)
+	// SetLogService 设置任务日志服务，TaskRun的Pod日志将实时写入
+	SetLogService(logService LogService)
 }
 
 type tektonService struct {
@@ -58,6 +63,8 @@ type tektonService struct {
 	tektonClient  tektonclient.Interface
 	restConfig    *rest.Config
 	runService    PipelineRunService
+	logService    LogService
+	logStreams    sync.Map // 正在采集日志的任务运行ID
 }
 
 // NewTektonService 创建Tekton服务实例
@@ -114,6 +121,9 @@ type TektonPipelineRunRequest struct {
 	Timeout        int                    `json:"timeout"`
 	Workspace      string                 `json:"workspace"`
 	ServiceAccount string                 `json:"service_account"`
+	Pipeline       *models.Pipeline       `json:"-"`            // 本次运行的流水线定义，内嵌到PipelineRun中
+	TaskRunIDs     map[string]uuid.UUID   `json:"task_run_ids"` // 任务名 -> TaskRun记录ID
+	SecretEnv      map[string]map[string]string `json:"-"`      // 任务名 -> 解密后注入的密钥环境变量
 }
 
 // TektonPipelineRunStatus Tekton流水线运行状态
@@ -158,11 +168,51 @@ func (s *tektonService) CreatePipeline(ctx context.Context, pipeline *models.Pip
 	return nil
 }
 
+// UpdatePipeline 更新Tekton Pipeline，不存在时创建
+func (s *tektonService) UpdatePipeline(ctx context.Context, pipeline *models.Pipeline) error {
+	pipelines := s.tektonClient.TektonV1beta1().Pipelines(s.config.Kubernetes.Namespace)
+
+	existing, err := pipelines.Get(ctx, tektonPipelineName(pipeline.ID), metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return s.CreatePipeline(ctx, pipeline)
+		}
+		return fmt.Errorf("获取Tekton Pipeline失败: %w", err)
+	}
+
+	tektonPipeline := s.buildTektonPipeline(pipeline)
+	tektonPipeline.ResourceVersion = existing.ResourceVersion
+
+	if _, err := pipelines.Update(ctx, tektonPipeline, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("更新Tekton Pipeline失败: %w", err)
+	}
+
+	return nil
+}
+
+// DeletePipeline 删除Tekton Pipeline
+func (s *tektonService) DeletePipeline(ctx context.Context, pipelineID uuid.UUID) error {
+	err := s.tektonClient.TektonV1beta1().
+		Pipelines(s.config.Kubernetes.Namespace).
+		Delete(ctx, tektonPipelineName(pipelineID), metav1.DeleteOptions{})
+
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("删除Tekton Pipeline失败: %w", err)
+	}
+
+	return nil
+}
+
 // buildTektonPipeline 构建Tekton Pipeline对象
 func (s *tektonService) buildTektonPipeline(pipeline *models.Pipeline) *tektonv1beta1.Pipeline {
 	tasks := make([]tektonv1beta1.PipelineTask, 0, len(pipeline.Tasks))
 	
 	for _, task := range pipeline.Tasks {
+		var workingDir string
+		if task.WorkingDir != nil {
+			workingDir = *task.WorkingDir
+		}
+
 		pipelineTask := tektonv1beta1.PipelineTask{
 			Name: task.Name,
 			TaskSpec: &tektonv1beta1.EmbeddedTask{
@@ -173,7 +223,7 @@ func (s *tektonService) buildTektonPipeline(pipeline *models.Pipeline) *tektonv1
 							Image:      task.Image,
 							Command:    task.Command,
 							Args:       task.Args,
-							WorkingDir: task.WorkingDir,
+							WorkingDir: workingDir,
 						},
 					},
 				},
@@ -182,9 +232,14 @@ func (s *tektonService) buildTektonPipeline(pipeline *models.Pipeline) *tektonv1
 		
 		// 添加环境变量
 		if task.Env != nil {
-			var envVars []corev1.EnvVar
-			// TODO: 解析task.Env JSON并转换为EnvVar切片
-			pipelineTask.TaskSpec.TaskSpec.Steps[0].Env = envVars
+			var env map[string]string
+			if err := json.Unmarshal(task.Env, &env); err == nil {
+				envVars := make([]corev1.EnvVar, 0, len(env))
+				for name, value := range env {
+					envVars = append(envVars, corev1.EnvVar{Name: name, Value: value})
+				}
+				pipelineTask.TaskSpec.TaskSpec.Steps[0].Env = envVars
+			}
 		}
 		
 		// 添加依赖关系
@@ -220,9 +275,6 @@ func (s *tektonService) buildTektonPipeline(pipeline *models.Pipeline) *tektonv1
 
 // CreatePipelineRun 创建Tekton PipelineRun
 func (s *tektonService) CreatePipelineRun(ctx context.Context, req *TektonPipelineRunRequest) error {
-	pipelineRef := &tektonv1beta1.PipelineRef{
-		Name: fmt.Sprintf("pipeline-%s", req.PipelineID.String()[:8]),
-	}
 	
 	// 构建参数
 	var params []tektonv1beta1.Param
@@ -253,7 +305,6 @@ func (s *tektonService) CreatePipelineRun(ctx context.Context, req *TektonPipeli
 			},
 		},
 		Spec: tektonv1beta1.PipelineRunSpec{
-			PipelineRef: pipelineRef,
 			Params:      params,
 			Timeout:     timeout,
 			Workspaces: []tektonv1beta1.WorkspaceBinding{
@@ -276,6 +327,26 @@ func (s *tektonService) CreatePipelineRun(ctx context.Context, req *TektonPipeli
 		},
 	}
 	
+	// 内嵌本次运行的流水线定义（定义文件在每个提交中可能不同），否则引用已创建的Pipeline
+	if req.Pipeline != nil {
+		spec := s.buildTektonPipeline(req.Pipeline).Spec
+		pipelineRun.Spec.PipelineSpec = &spec
+	} else {
+		pipelineRun.Spec.PipelineRef = &tektonv1beta1.PipelineRef{
+			Name: tektonPipelineName(req.PipelineID),
+		}
+	}
+
+	// 为每个任务的TaskRun打上记录ID标签，便于回写任务状态
+	for taskName, taskRunID := range req.TaskRunIDs {
+		pipelineRun.Spec.TaskRunSpecs = append(pipelineRun.Spec.TaskRunSpecs, tektonv1beta1.PipelineTaskRunSpec{
+			PipelineTaskName: taskName,
+			Metadata: &tektonv1beta1.PipelineTaskMetadata{
+				Labels: map[string]string{"euclid.io/task-run-id": taskRunID.String()},
+			},
+		})
+	}
+
 	// 设置ServiceAccount
 	if req.ServiceAccount != "" {
 		pipelineRun.Spec.ServiceAccountName = req.ServiceAccount
@@ -283,17 +354,114 @@ func (s *tektonService) CreatePipelineRun(ctx context.Context, req *TektonPipeli
 		pipelineRun.Spec.ServiceAccountName = s.config.Kubernetes.ServiceAccount
 	}
 	
-	_, err := s.tektonClient.TektonV1beta1().
+	// 密钥写入本次运行专用的Secret，步骤通过 secretKeyRef 引用，不出现在PipelineRun定义中
+	var runSecret *corev1.Secret
+	if pipelineRun.Spec.PipelineSpec != nil && len(req.SecretEnv) > 0 {
+		runSecret = s.buildRunSecret(req, pipelineRun.Spec.PipelineSpec)
+		if _, err := s.k8sClient.CoreV1().Secrets(s.config.Kubernetes.Namespace).
+			Create(ctx, runSecret, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("创建运行密钥失败: %w", err)
+		}
+	}
+
+	created, err := s.tektonClient.TektonV1beta1().
 		PipelineRuns(s.config.Kubernetes.Namespace).
 		Create(ctx, pipelineRun, metav1.CreateOptions{})
 	
 	if err != nil {
+		if runSecret != nil {
+			s.deleteRunSecret(runSecret.Name)
+		}
 		return fmt.Errorf("创建Tekton PipelineRun失败: %w", err)
 	}
+
+	if runSecret != nil {
+		s.adoptRunSecret(ctx, runSecret.Name, created)
+	}
 	
 	return nil
 }
 
+// buildRunSecret 构建运行密钥Secret，并将任务步骤的环境变量改为引用该Secret
+func (s *tektonService) buildRunSecret(req *TektonPipelineRunRequest, spec *tektonv1beta1.PipelineSpec) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      req.Name + "-secrets",
+			Namespace: s.config.Kubernetes.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":      "euclid-cicd",
+				"app.kubernetes.io/component": "pipelinerun-secrets",
+				"euclid.io/pipeline-id":       req.PipelineID.String(),
+				"euclid.io/run-id":            req.RunID.String(),
+			},
+		},
+		Type:       corev1.SecretTypeOpaque,
+		StringData: make(map[string]string),
+	}
+
+	for i := range spec.Tasks {
+		env := req.SecretEnv[spec.Tasks[i].Name]
+		if len(env) == 0 || spec.Tasks[i].TaskSpec == nil || len(spec.Tasks[i].TaskSpec.Steps) == 0 {
+			continue
+		}
+		names := make([]string, 0, len(env))
+		for name := range env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		step := &spec.Tasks[i].TaskSpec.Steps[0]
+		for _, name := range names {
+			// 同名的普通环境变量被密钥覆盖
+			for j := len(step.Env) - 1; j >= 0; j-- {
+				if step.Env[j].Name == name {
+					step.Env = append(step.Env[:j], step.Env[j+1:]...)
+				}
+			}
+			key := fmt.Sprintf("%d.%s", i, name)
+			secret.StringData[key] = env[name]
+			step.Env = append(step.Env, corev1.EnvVar{
+				Name: name,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+						Key:                  key,
+					},
+				},
+			})
+		}
+	}
+	return secret
+}
+
+// adoptRunSecret 将运行密钥的属主设为PipelineRun，PipelineRun被清理时Secret随之删除
+func (s *tektonService) adoptRunSecret(ctx context.Context, name string, pipelineRun *tektonv1beta1.PipelineRun) {
+	secrets := s.k8sClient.CoreV1().Secrets(s.config.Kubernetes.Namespace)
+	secret, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		secret.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: tektonv1beta1.SchemeGroupVersion.String(),
+			Kind:       "PipelineRun",
+			Name:       pipelineRun.Name,
+			UID:        pipelineRun.UID,
+		}}
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		log.Printf("⚠️ 设置运行密钥属主失败 %s: %v", name, err)
+	}
+}
+
+// deleteRunSecret 删除运行密钥Secret
+func (s *tektonService) deleteRunSecret(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err := s.k8sClient.CoreV1().Secrets(s.config.Kubernetes.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		log.Printf("⚠️ 删除运行密钥失败 %s: %v", name, err)
+	}
+}
+
 // GetPipelineRunStatus 获取PipelineRun状态
 func (s *tektonService) GetPipelineRunStatus(ctx context.Context, runID uuid.UUID) (*TektonPipelineRunStatus, error) {
 	// 通过Label查找PipelineRun
@@ -316,7 +484,7 @@ func (s *tektonService) GetPipelineRunStatus(ctx context.Context, runID uuid.UUI
 	pipelineRun := pipelineRuns.Items[0]
 	
 	status := &TektonPipelineRunStatus{
-		Status: string(pipelineRun.Status.GetCondition(tektonv1beta1.PipelineRunSucceeded).Type),
+		Status: conditionStatus(pipelineRun.Status.GetCondition(apis.ConditionSucceeded)),
 	}
 	
 	if pipelineRun.Status.StartTime != nil {
@@ -332,7 +500,7 @@ func (s *tektonService) GetPipelineRunStatus(ctx context.Context, runID uuid.UUI
 		status.TaskRuns = make(map[string]interface{})
 		for name, taskRun := range pipelineRun.Status.TaskRuns {
 			status.TaskRuns[name] = map[string]interface{}{
-				"status":     string(taskRun.Status.GetCondition(tektonv1beta1.TaskRunSucceeded).Type),
+				"status":     conditionStatus(taskRun.Status.GetCondition(apis.ConditionSucceeded)),
 				"start_time": taskRun.Status.StartTime,
 				"end_time":   taskRun.Status.CompletionTime,
 			}
@@ -377,6 +545,104 @@ func (s *tektonService) CancelPipelineRun(ctx context.Context, runID uuid.UUID)
 	return nil
 }
 
+// GetTaskRunStatus 获取TaskRun状态
+func (s *tektonService) GetTaskRunStatus(ctx context.Context, taskRunID uuid.UUID) (*TektonTaskRunStatus, error) {
+	taskRun, err := s.findTaskRun(ctx, taskRunID)
+	if err != nil {
+		return nil, err
+	}
+
+	condition := taskRun.Status.GetCondition(apis.ConditionSucceeded)
+	status := &TektonTaskRunStatus{
+		Status: conditionStatus(condition),
+	}
+	if condition != nil {
+		status.Message = condition.Message
+	}
+
+	if taskRun.Status.StartTime != nil {
+		status.StartTime = &taskRun.Status.StartTime.Time
+	}
+
+	if taskRun.Status.CompletionTime != nil {
+		status.EndTime = &taskRun.Status.CompletionTime.Time
+	}
+
+	for _, step := range taskRun.Status.Steps {
+		stepStatus := StepStatus{Name: step.Name, Status: "running"}
+		switch {
+		case step.Terminated != nil:
+			stepStatus.Status = "succeeded"
+			if step.Terminated.ExitCode != 0 {
+				stepStatus.Status = "failed"
+			}
+			stepStatus.Message = step.Terminated.Reason
+			startedAt := step.Terminated.StartedAt.Time
+			finishedAt := step.Terminated.FinishedAt.Time
+			stepStatus.StartTime = &startedAt
+			stepStatus.EndTime = &finishedAt
+		case step.Waiting != nil:
+			stepStatus.Status = "pending"
+			stepStatus.Message = step.Waiting.Reason
+		case step.Running != nil:
+			startedAt := step.Running.StartedAt.Time
+			stepStatus.StartTime = &startedAt
+		}
+		status.Steps = append(status.Steps, stepStatus)
+	}
+
+	return status, nil
+}
+
+// GetTaskRunLogs 获取TaskRun对应Pod中主步骤的日志
+func (s *tektonService) GetTaskRunLogs(ctx context.Context, taskRunID uuid.UUID) (string, error) {
+	taskRun, err := s.findTaskRun(ctx, taskRunID)
+	if err != nil {
+		return "", err
+	}
+
+	if taskRun.Status.PodName == "" {
+		return "", fmt.Errorf("TaskRun尚未调度到Pod")
+	}
+
+	stream, err := s.k8sClient.CoreV1().
+		Pods(s.config.Kubernetes.Namespace).
+		GetLogs(taskRun.Status.PodName, &corev1.PodLogOptions{Container: "step-main"}).
+		Stream(ctx)
+	if err != nil {
+		return "", fmt.Errorf("获取TaskRun日志失败: %w", err)
+	}
+	defer stream.Close()
+
+	logs, err := io.ReadAll(stream)
+	if err != nil {
+		return "", fmt.Errorf("读取TaskRun日志失败: %w", err)
+	}
+
+	return string(logs), nil
+}
+
+// findTaskRun 通过记录ID标签查找TaskRun
+func (s *tektonService) findTaskRun(ctx context.Context, taskRunID uuid.UUID) (*tektonv1beta1.TaskRun, error) {
+	labelSelector := fmt.Sprintf("euclid.io/task-run-id=%s", taskRunID.String())
+
+	taskRuns, err := s.tektonClient.TektonV1beta1().
+		TaskRuns(s.config.Kubernetes.Namespace).
+		List(ctx, metav1.ListOptions{
+			LabelSelector: labelSelector,
+		})
+
+	if err != nil {
+		return nil, fmt.Errorf("获取TaskRun失败: %w", err)
+	}
+
+	if len(taskRuns.Items) == 0 {
+		return nil, fmt.Errorf("TaskRun不存在")
+	}
+
+	return &taskRuns.Items[0], nil
+}
+
 // WatchPipelineRuns 监听PipelineRun事件
 func (s *tektonService) WatchPipelineRuns(ctx context.Context) error {
 	watchlist := cache.NewListWatchFromClient(
@@ -426,24 +692,15 @@ func (s *tektonService) handlePipelineRunEvent(eventType string, obj interface{}
 	}
 	
 	// 更新数据库状态
-	var status string
 	var message *string
 	
-	condition := pipelineRun.Status.GetCondition(tektonv1beta1.PipelineRunSucceeded)
-	if condition != nil {
-		switch condition.Status {
-		case corev1.ConditionTrue:
-			status = "succeeded"
-		case corev1.ConditionFalse:
-			status = "failed"
-			if condition.Message != "" {
-				message = &condition.Message
-			}
-		default:
-			status = "running"
-		}
-	} else {
-		status = "pending"
+	condition := pipelineRun.Status.GetCondition(apis.ConditionSucceeded)
+	status := conditionStatus(condition)
+	if status == "failed" && condition.Message != "" {
+		message = &condition.Message
+	}
+	if s.runService == nil {
+		return
 	}
 	
 	// 异步更新状态
@@ -454,22 +711,66 @@ func (s *tektonService) handlePipelineRunEvent(eventType string, obj interface{}
 	}()
 }
 
-// CreateSecret 创建Secret
-func (s *tektonService) CreateSecret(ctx context.Context, secret *models.Secret) error {
-	// TODO: 实现Secret创建逻辑
-	return nil
-}
+// WatchTaskRuns 监听TaskRun事件
+func (s *tektonService) WatchTaskRuns(ctx context.Context) error {
+	watchlist := cache.NewListWatchFromClient(
+		s.tektonClient.TektonV1beta1().RESTClient(),
+		"taskruns",
+		s.config.Kubernetes.Namespace,
+		fields.Everything(),
+	)
 
-// UpdateSecret 更新Secret
-func (s *tektonService) UpdateSecret(ctx context.Context, secret *models.Secret) error {
-	// TODO: 实现Secret更新逻辑
+	_, controller := cache.NewInformer(
+		watchlist,
+		&tektonv1beta1.TaskRun{},
+		time.Second*10,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				s.handleTaskRunEvent(obj)
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				s.handleTaskRunEvent(newObj)
+			},
+		},
+	)
+
+	go controller.Run(ctx.Done())
 	return nil
 }
 
-// DeleteSecret 删除Secret
-func (s *tektonService) DeleteSecret(ctx context.Context, secretID uuid.UUID) error {
-	// TODO: 实现Secret删除逻辑
-	return nil
+// handleTaskRunEvent 处理TaskRun事件，回写任务运行状态
+func (s *tektonService) handleTaskRunEvent(obj interface{}) {
+	taskRun, ok := obj.(*tektonv1beta1.TaskRun)
+	if !ok || s.runService == nil {
+		return
+	}
+
+	taskRunID, err := uuid.Parse(taskRun.Labels["euclid.io/task-run-id"])
+	if err != nil {
+		return
+	}
+
+	condition := taskRun.Status.GetCondition(apis.ConditionSucceeded)
+	update := &TaskRunStatusUpdate{Status: conditionStatus(condition)}
+	if update.Status == "failed" && condition.Message != "" {
+		update.ErrorMessage = &condition.Message
+	}
+	if taskRun.Status.PodName != "" {
+		update.PodName = &taskRun.Status.PodName
+		s.startLogStream(taskRun, taskRunID)
+	}
+	for _, step := range taskRun.Status.Steps {
+		if step.Terminated != nil {
+			exitCode := int(step.Terminated.ExitCode)
+			update.ExitCode = &exitCode
+		}
+	}
+
+	go func() {
+		if err := s.runService.UpdateTaskRunStatus(taskRunID, update); err != nil {
+			fmt.Printf("更新TaskRun状态失败: %v\n", err)
+		}
+	}()
 }
 
 // HealthCheck 健康检查
@@ -487,4 +788,100 @@ func (s *tektonService) HealthCheck(ctx context.Context) error {
 	}
 	
 	return nil
-}
\ No newline at end of file
+}
+
+// SetRunService 设置状态回写的流水线运行服务
+func (s *tektonService) SetRunService(runService PipelineRunService) {
+	s.runService = runService
+}
+
+// SetLogService 设置任务日志服务
+func (s *tektonService) SetLogService(logService LogService) {
+	s.logService = logService
+}
+
+// startLogStream TaskRun调度到Pod后开始采集主步骤日志，每个TaskRun只采集一次
+func (s *tektonService) startLogStream(taskRun *tektonv1beta1.TaskRun, taskRunID uuid.UUID) {
+	if s.logService == nil {
+		return
+	}
+	runID, err := uuid.Parse(taskRun.Labels["euclid.io/run-id"])
+	if err != nil {
+		return
+	}
+	if _, streaming := s.logStreams.LoadOrStore(taskRunID, struct{}{}); streaming {
+		return
+	}
+
+	go func() {
+		defer s.logStreams.Delete(taskRunID)
+		if err := s.streamTaskRunLogs(runID, taskRunID, taskRun.Status.PodName); err != nil {
+			log.Printf("⚠️ 采集TaskRun日志失败 %s: %v", taskRunID, err)
+		}
+	}()
+}
+
+// streamTaskRunLogs 跟随Pod主步骤容器输出写入任务日志，直到容器结束。
+// 已有日志的任务（如服务重启后监听重新同步）不再重复采集
+func (s *tektonService) streamTaskRunLogs(runID, taskRunID uuid.UUID, podName string) error {
+	size, err := s.logService.Size(runID, taskRunID)
+	if err != nil {
+		return err
+	}
+	if size > 0 {
+		return nil
+	}
+
+	writer := s.logService.NewWriter(runID, taskRunID)
+	defer writer.Close()
+
+	var lastErr error
+	for attempt := 0; attempt < 60; attempt++ {
+		stream, err := s.k8sClient.CoreV1().
+			Pods(s.config.Kubernetes.Namespace).
+			GetLogs(podName, &corev1.PodLogOptions{Container: "step-main", Follow: true}).
+			Stream(context.Background())
+		if err != nil {
+			// 容器尚未启动，等待后重试
+			lastErr = err
+			time.Sleep(2 * time.Second)
+			continue
+		}
+		defer stream.Close()
+
+		if _, err := io.Copy(writer, stream); err != nil {
+			return fmt.Errorf("读取Pod日志失败: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("获取Pod日志失败: %w", lastErr)
+}
+
+// tektonPipelineName Tekton Pipeline资源名
+func tektonPipelineName(pipelineID uuid.UUID) string {
+	return fmt.Sprintf("pipeline-%s", pipelineID.String()[:8])
+}
+
+// conditionStatus 将Tekton Succeeded条件转换为运行状态
+func conditionStatus(condition *apis.Condition) string {
+	if condition == nil {
+		return "pending"
+	}
+
+	switch condition.Status {
+	case corev1.ConditionTrue:
+		return "succeeded"
+	case corev1.ConditionFalse:
+		if condition.Reason == tektonv1beta1.PipelineRunReasonCancelled.String() ||
+			condition.Reason == tektonv1beta1.TaskRunReasonCancelled.String() {
+			return "cancelled"
+		}
+		if condition.Reason == tektonv1beta1.PipelineRunReasonTimedOut.String() ||
+			condition.Reason == tektonv1beta1.TaskRunReasonTimedOut.String() {
+			return "timeout"
+		}
+		return "failed"
+	default:
+		return "running"
+	}
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -34,7 +36,7 @@ func main() {
 	}
 
 	// 设置路由
-	router := routes.SetupRoutes(db, cfg)
+	router, sshServer := routes.SetupRoutes(db, cfg)
 
 	// 启动服务器
 	server := &http.Server{
@@ -50,6 +52,16 @@ func main() {
 		}
 	}()
 
+	// Git SSH协议服务
+	if cfg.Git.EnableSSH {
+		go func() {
+			log.Printf("Git SSH服务启动在端口: %s", cfg.Git.SSHPort)
+			if err := sshServer.ListenAndServe(":" + cfg.Git.SSHPort); err != nil && !errors.Is(err, net.ErrClosed) {
+				log.Fatalf("Git SSH服务启动失败: %v", err)
+			}
+		}()
+	}
+
 	// 等待中断信号优雅关闭服务器
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -60,6 +72,10 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	if err := sshServer.Close(); err != nil {
+		log.Printf("关闭Git SSH服务失败: %v", err)
+	}
+
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("服务器强制关闭: %v", err)
 	}
@@ -112,12 +128,19 @@ func autoMigrate(db *gorm.DB) error {
 	// 自动迁移所有模型
 	err := db.AutoMigrate(
 		&models.Repository{},
+		&models.RepositoryStorageLease{},
 		&models.Branch{},
+		&models.ProtectionRule{},
 		&models.Tag{},
 		&models.Webhook{},
 		&models.WebhookDelivery{},
 		&models.PushEvent{},
+		&models.RepositoryInsight{},
 		&models.AccessKey{},
+		&models.DeployKey{},
+		&models.DeployToken{},
+		&models.SigningKey{},
+		&models.MergeQueueEntry{},
 		&models.GitOperation{},
 		&models.Project{},
 		&models.User{},
@@ -11,14 +11,18 @@ import (
 
 // Config 应用配置结构
 type Config struct {
-	Environment string         `mapstructure:"environment"`
-	Port        string         `mapstructure:"port"`
-	LogLevel    string         `mapstructure:"log_level"`
-	Database    DatabaseConfig `mapstructure:"database"`
-	JWT         JWTConfig      `mapstructure:"jwt"`
-	CORS        CORSConfig     `mapstructure:"cors"`
-	Git         GitConfig      `mapstructure:"git"`
-	Webhook     WebhookConfig  `mapstructure:"webhook"`
+	Environment string           `mapstructure:"environment"`
+	Port        string           `mapstructure:"port"`
+	LogLevel    string           `mapstructure:"log_level"`
+	Database    DatabaseConfig   `mapstructure:"database"`
+	JWT         JWTConfig        `mapstructure:"jwt"`
+	CORS        CORSConfig       `mapstructure:"cors"`
+	Git         GitConfig        `mapstructure:"git"`
+	Webhook     WebhookConfig    `mapstructure:"webhook"`
+	Search      SearchConfig     `mapstructure:"search"`
+	CICD        CICDConfig       `mapstructure:"cicd"`
+	MergeQueue  MergeQueueConfig `mapstructure:"merge_queue"`
+	Project     ProjectConfig    `mapstructure:"project"`
 }
 
 // DatabaseConfig 数据库配置
@@ -55,6 +59,35 @@ type GitConfig struct {
 	MaxRepositorySize int64 `mapstructure:"max_repository_size"` // 最大仓库大小 (MB)
 	EnableLFS       bool   `mapstructure:"enable_lfs"`
 	LFSStorage      string `mapstructure:"lfs_storage"`
+	StorageRoots    []StorageRootConfig `mapstructure:"storage_roots"` // 多存储根目录，为空时仅使用RepositoryRoot
+}
+
+// DefaultStorageRoot 默认存储根目录名称（对应RepositoryRoot）
+const DefaultStorageRoot = "default"
+
+// StorageRootConfig 仓库存储根目录配置
+type StorageRootConfig struct {
+	Name   string `mapstructure:"name"`
+	Path   string `mapstructure:"path"`
+	Weight int    `mapstructure:"weight"` // 放置权重，0表示不再放置新仓库
+}
+
+// Roots 获取所有存储根目录
+func (c *GitConfig) Roots() []StorageRootConfig {
+	if len(c.StorageRoots) == 0 {
+		return []StorageRootConfig{{Name: DefaultStorageRoot, Path: c.RepositoryRoot, Weight: 1}}
+	}
+	return c.StorageRoots
+}
+
+// RootPath 获取存储根目录路径，未知名称（如配置调整前创建的仓库）回退到RepositoryRoot
+func (c *GitConfig) RootPath(name string) string {
+	for _, root := range c.Roots() {
+		if root.Name == name {
+			return root.Path
+		}
+	}
+	return c.RepositoryRoot
 }
 
 // WebhookConfig Webhook配置
@@ -66,6 +99,30 @@ type WebhookConfig struct {
 	EnableSignature bool   `mapstructure:"enable_signature"` // 启用签名验证
 }
 
+// SearchConfig 代码搜索配置
+type SearchConfig struct {
+	IndexRoot   string `mapstructure:"index_root"`    // 索引存储目录
+	MaxFileSize int64  `mapstructure:"max_file_size"` // 参与索引的最大文件大小 (KB)
+}
+
+// CICDConfig CI/CD服务调用配置
+type CICDConfig struct {
+	BaseURL string `mapstructure:"base_url"` // CI/CD服务地址
+	Timeout int    `mapstructure:"timeout"`  // 请求超时 (秒)
+}
+
+// ProjectConfig 项目服务调用配置（上报任务关联的代码事件）
+type ProjectConfig struct {
+	BaseURL string `mapstructure:"base_url"` // 项目服务地址
+	Timeout int    `mapstructure:"timeout"`  // 请求超时 (秒)
+}
+
+// MergeQueueConfig 合并队列配置
+type MergeQueueConfig struct {
+	PollInterval int `mapstructure:"poll_interval"` // 流水线状态轮询间隔 (秒)
+	BatchSize    int `mapstructure:"batch_size"`    // 每批同时验证的最大条目数
+}
+
 // Load 加载配置
 func Load() *Config {
 	config := &Config{}
@@ -146,6 +203,22 @@ func setDefaults() {
 	viper.SetDefault("webhook.timeout", 30)
 	viper.SetDefault("webhook.max_payload_size", 1024) // 1MB
 	viper.SetDefault("webhook.enable_signature", true)
+
+	// 代码搜索设置
+	viper.SetDefault("search.index_root", "/data/search-index")
+	viper.SetDefault("search.max_file_size", 1024) // 1MB
+
+	// CI/CD服务设置
+	viper.SetDefault("cicd.base_url", "http://cicd-service:8005")
+	viper.SetDefault("cicd.timeout", 10)
+
+	// 合并队列设置
+	viper.SetDefault("merge_queue.poll_interval", 15)
+	viper.SetDefault("merge_queue.batch_size", 5)
+
+	// 项目服务设置
+	viper.SetDefault("project.base_url", "http://project-service:8003")
+	viper.SetDefault("project.timeout", 10)
 }
 
 // validateConfig 验证配置
@@ -166,6 +239,20 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("Git仓库根目录不能为空")
 	}
 
+	names := make(map[string]bool)
+	for _, root := range config.Git.StorageRoots {
+		if root.Name == "" || root.Path == "" {
+			return fmt.Errorf("存储根目录名称和路径不能为空")
+		}
+		if names[root.Name] {
+			return fmt.Errorf("存储根目录名称重复: %s", root.Name)
+		}
+		if root.Weight < 0 {
+			return fmt.Errorf("存储根目录权重不能为负数: %s", root.Name)
+		}
+		names[root.Name] = true
+	}
+
 	return nil
 }
 
@@ -223,6 +310,7 @@ func LoadFromEnv() *Config {
 			MaxRepositorySize: getEnvAsInt64("GIT_MAX_REPOSITORY_SIZE", 2048),
 			EnableLFS:         getEnvAsBool("GIT_ENABLE_LFS", true),
 			LFSStorage:        getEnv("GIT_LFS_STORAGE", "/data/lfs"),
+			StorageRoots:      getEnvAsStorageRoots("GIT_STORAGE_ROOTS"),
 		},
 		Webhook: WebhookConfig{
 			MaxRetries:      getEnvAsInt("WEBHOOK_MAX_RETRIES", 3),
@@ -231,6 +319,22 @@ func LoadFromEnv() *Config {
 			MaxPayloadSize:  getEnvAsInt64("WEBHOOK_MAX_PAYLOAD_SIZE", 1024),
 			EnableSignature: getEnvAsBool("WEBHOOK_ENABLE_SIGNATURE", true),
 		},
+		Search: SearchConfig{
+			IndexRoot:   getEnv("SEARCH_INDEX_ROOT", "/data/search-index"),
+			MaxFileSize: getEnvAsInt64("SEARCH_MAX_FILE_SIZE", 1024),
+		},
+		CICD: CICDConfig{
+			BaseURL: getEnv("CICD_BASE_URL", "http://cicd-service:8005"),
+			Timeout: getEnvAsInt("CICD_TIMEOUT", 10),
+		},
+		MergeQueue: MergeQueueConfig{
+			PollInterval: getEnvAsInt("MERGE_QUEUE_POLL_INTERVAL", 15),
+			BatchSize:    getEnvAsInt("MERGE_QUEUE_BATCH_SIZE", 5),
+		},
+		Project: ProjectConfig{
+			BaseURL: getEnv("PROJECT_BASE_URL", "http://project-service:8003"),
+			Timeout: getEnvAsInt("PROJECT_TIMEOUT", 10),
+		},
 	}
 }
 
@@ -268,6 +372,28 @@ func getEnvAsInt64(key string, defaultValue int64) int64 {
 	return value
 }
 
+// getEnvAsStorageRoots 解析存储根目录列表，格式: name:path:weight,name:path:weight
+func getEnvAsStorageRoots(key string) []StorageRootConfig {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return nil
+	}
+
+	var roots []StorageRootConfig
+	for _, item := range strings.Split(valueStr, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if len(parts) < 2 {
+			continue
+		}
+		root := StorageRootConfig{Name: parts[0], Path: parts[1], Weight: 1}
+		if len(parts) > 2 {
+			fmt.Sscanf(parts[2], "%d", &root.Weight)
+		}
+		roots = append(roots, root)
+	}
+	return roots
+}
+
 func getEnvAsBool(key string, defaultValue bool) bool {
 	valueStr := getEnv(key, "")
 	if valueStr == "" {
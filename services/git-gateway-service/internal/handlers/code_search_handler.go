@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"git-gateway-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CodeSearchHandler 代码搜索处理器
+type CodeSearchHandler struct {
+	codeSearchService services.CodeSearchService
+	repoService       services.RepositoryService
+}
+
+// NewCodeSearchHandler 创建代码搜索处理器
+func NewCodeSearchHandler(codeSearchService services.CodeSearchService, repoService services.RepositoryService) *CodeSearchHandler {
+	return &CodeSearchHandler{
+		codeSearchService: codeSearchService,
+		repoService:       repoService,
+	}
+}
+
+// SearchCode 搜索代码
+func (h *CodeSearchHandler) SearchCode(c *gin.Context) {
+	userID, err := uuid.Parse(fmt.Sprint(c.MustGet("user_id")))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的用户身份"})
+		return
+	}
+
+	req := services.CodeSearchRequest{
+		Query:         c.Query("q"),
+		Regex:         c.Query("regex") == "true",
+		CaseSensitive: c.Query("case_sensitive") == "true",
+		Path:          c.Query("path"),
+		Language:      c.Query("language"),
+		UserID:        userID,
+	}
+
+	if repositoryIDParam := c.Query("repository_id"); repositoryIDParam != "" {
+		repositoryID, err := uuid.Parse(repositoryIDParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的仓库ID"})
+			return
+		}
+		req.RepositoryID = &repositoryID
+	}
+
+	if projectIDParam := c.Query("project_id"); projectIDParam != "" {
+		projectID, err := uuid.Parse(projectIDParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的项目ID"})
+			return
+		}
+		req.ProjectID = &projectID
+	}
+
+	req.Context, _ = strconv.Atoi(c.DefaultQuery("context", "2"))
+	req.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	req.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	result, err := h.codeSearchService.Search(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "搜索成功",
+		"data":    result,
+	})
+}
+
+// ReindexRepository 重新索引仓库默认分支
+func (h *CodeSearchHandler) ReindexRepository(c *gin.Context) {
+	idParam := c.Param("id")
+	repositoryID, err := uuid.Parse(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的仓库ID"})
+		return
+	}
+
+	if _, ok := authorizeRepository(c, h.repoService, repositoryID, services.RepositoryAccessWrite); !ok {
+		return
+	}
+
+	h.codeSearchService.Enqueue(repositoryID)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "已加入索引队列",
+	})
+}
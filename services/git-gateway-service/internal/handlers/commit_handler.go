@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"git-gateway-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CommitHandler 提交查询处理器，读取仓库内容需要仓库读权限
+type CommitHandler struct {
+	commitService services.CommitService
+	repoService   services.RepositoryService
+}
+
+// NewCommitHandler 创建提交查询处理器
+func NewCommitHandler(commitService services.CommitService, repoService services.RepositoryService) *CommitHandler {
+	return &CommitHandler{
+		commitService: commitService,
+		repoService:   repoService,
+	}
+}
+
+// ListCommits 获取提交历史
+func (h *CommitHandler) ListCommits(c *gin.Context) {
+	repositoryID, ok := h.authorizeRead(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	req := &services.ListCommitsRequest{
+		Ref:   c.Query("ref"),
+		Page:  page,
+		Limit: limit,
+	}
+
+	commits, err := h.commitService.List(repositoryID, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data": gin.H{
+			"commits": commits,
+			"page":    page,
+			"limit":   limit,
+		},
+	})
+}
+
+// GetCommit 获取提交详情
+func (h *CommitHandler) GetCommit(c *gin.Context) {
+	repositoryID, ok := h.authorizeRead(c)
+	if !ok {
+		return
+	}
+
+	commit, err := h.commitService.Get(repositoryID, c.Param("sha"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    commit,
+	})
+}
+
+// GetFile 获取指定引用（默认为仓库默认分支）下的文件内容
+func (h *CommitHandler) GetFile(c *gin.Context) {
+	repositoryID, ok := h.authorizeRead(c)
+	if !ok {
+		return
+	}
+
+	path := c.Query("path")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少文件路径"})
+		return
+	}
+
+	file, err := h.commitService.GetFile(repositoryID, c.Query("ref"), path)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    file,
+	})
+}
+
+// cicdServiceIssuer CI/CD服务签发的服务令牌的签发者，读取流水线定义文件时由CI/CD服务自行完成权限校验
+const cicdServiceIssuer = "cicd-service"
+
+// authorizeRead 解析仓库ID并校验读权限：CI/CD服务令牌直接放行，其余调用者按仓库读权限校验，
+// 无权读取时与仓库不存在返回相同结果
+func (h *CommitHandler) authorizeRead(c *gin.Context) (uuid.UUID, bool) {
+	repositoryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的仓库ID"})
+		return uuid.Nil, false
+	}
+
+	if c.GetString("role") == "service" && c.GetString("issuer") == cicdServiceIssuer {
+		return repositoryID, true
+	}
+
+	userID, err := uuid.Parse(fmt.Sprint(c.MustGet("user_id")))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的用户身份"})
+		return uuid.Nil, false
+	}
+
+	repo, err := h.repoService.GetByID(repositoryID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return uuid.Nil, false
+	}
+	if err := h.repoService.Authorize(repo, userID, services.RepositoryAccessRead); err != nil {
+		if errors.Is(err, services.ErrRepositoryAccessDenied) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "仓库不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return uuid.Nil, false
+	}
+
+	return repositoryID, true
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"git-gateway-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// DeployKeyHandler 部署密钥与部署令牌处理器，所有操作需要仓库管理权限
+type DeployKeyHandler struct {
+	deployKeyService services.DeployKeyService
+	repoService      services.RepositoryService
+}
+
+// NewDeployKeyHandler 创建部署密钥处理器
+func NewDeployKeyHandler(deployKeyService services.DeployKeyService, repoService services.RepositoryService) *DeployKeyHandler {
+	return &DeployKeyHandler{
+		deployKeyService: deployKeyService,
+		repoService:      repoService,
+	}
+}
+
+// CreateDeployKey 为仓库添加部署密钥
+func (h *DeployKeyHandler) CreateDeployKey(c *gin.Context) {
+	repositoryID, userID, ok := h.deployRequestContext(c)
+	if !ok {
+		return
+	}
+
+	var req services.CreateDeployKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.RepositoryID = repositoryID
+	req.CreatedBy = userID
+	req.ClientIP = c.ClientIP()
+
+	key, err := h.deployKeyService.CreateKey(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "部署密钥创建成功",
+		"data":    key,
+	})
+}
+
+// ListDeployKeys 获取仓库的部署密钥
+func (h *DeployKeyHandler) ListDeployKeys(c *gin.Context) {
+	repositoryID, _, ok := h.deployRequestContext(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.deployKeyService.ListKeys(repositoryID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    keys,
+	})
+}
+
+// DeleteDeployKey 删除部署密钥
+func (h *DeployKeyHandler) DeleteDeployKey(c *gin.Context) {
+	repositoryID, userID, ok := h.deployRequestContext(c)
+	if !ok {
+		return
+	}
+	keyID, err := uuid.Parse(c.Param("key_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的部署密钥ID"})
+		return
+	}
+
+	if err := h.deployKeyService.DeleteKey(repositoryID, keyID, &services.CredentialActor{UserID: userID, ClientIP: c.ClientIP()}); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "删除成功",
+	})
+}
+
+// CreateDeployToken 为仓库生成部署令牌，明文令牌仅在响应中返回一次
+func (h *DeployKeyHandler) CreateDeployToken(c *gin.Context) {
+	repositoryID, userID, ok := h.deployRequestContext(c)
+	if !ok {
+		return
+	}
+
+	var req services.CreateDeployTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.RepositoryID = repositoryID
+	req.CreatedBy = userID
+	req.ClientIP = c.ClientIP()
+
+	token, err := h.deployKeyService.CreateToken(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "部署令牌创建成功，请妥善保存，令牌不会再次显示",
+		"data":    token,
+	})
+}
+
+// ListDeployTokens 获取仓库的部署令牌
+func (h *DeployKeyHandler) ListDeployTokens(c *gin.Context) {
+	repositoryID, _, ok := h.deployRequestContext(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.deployKeyService.ListTokens(repositoryID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    tokens,
+	})
+}
+
+// DeleteDeployToken 吊销部署令牌
+func (h *DeployKeyHandler) DeleteDeployToken(c *gin.Context) {
+	repositoryID, userID, ok := h.deployRequestContext(c)
+	if !ok {
+		return
+	}
+	tokenID, err := uuid.Parse(c.Param("token_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的部署令牌ID"})
+		return
+	}
+
+	if err := h.deployKeyService.DeleteToken(repositoryID, tokenID, &services.CredentialActor{UserID: userID, ClientIP: c.ClientIP()}); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "删除成功",
+	})
+}
+
+// deployRequestContext 解析仓库ID和当前用户，并校验当前用户具有仓库管理权限
+func (h *DeployKeyHandler) deployRequestContext(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	repositoryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的仓库ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(fmt.Sprint(c.MustGet("user_id")))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的用户身份"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	repo, err := h.repoService.GetByID(repositoryID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return uuid.Nil, uuid.Nil, false
+	}
+	if err := h.repoService.Authorize(repo, userID, services.RepositoryAccessAdmin); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrRepositoryAccessDenied) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return repositoryID, userID, true
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"git-gateway-service/internal/models"
+	"git-gateway-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GitHTTPHandler Git智能HTTP协议处理器
+type GitHTTPHandler struct {
+	repoService        services.RepositoryService
+	gitProtocolService services.GitProtocolService
+	deployKeyService   services.DeployKeyService
+}
+
+// NewGitHTTPHandler 创建Git智能HTTP协议处理器
+func NewGitHTTPHandler(repoService services.RepositoryService, gitProtocolService services.GitProtocolService,
+	deployKeyService services.DeployKeyService) *GitHTTPHandler {
+	return &GitHTTPHandler{
+		repoService:        repoService,
+		gitProtocolService: gitProtocolService,
+		deployKeyService:   deployKeyService,
+	}
+}
+
+// ServeGit 处理 /git/:project_id/:name.git/{info/refs,git-upload-pack,git-receive-pack}
+func (h *GitHTTPHandler) ServeGit(c *gin.Context) {
+	projectID, repoName, action, ok := parseGitPath(c.Param("path"))
+	if !ok {
+		c.String(http.StatusNotFound, "仓库不存在")
+		return
+	}
+
+	var service string
+	switch {
+	case action == "info/refs" && c.Request.Method == http.MethodGet:
+		service = c.Query("service")
+	case action == services.ServiceUploadPack && c.Request.Method == http.MethodPost:
+		service = services.ServiceUploadPack
+	case action == services.ServiceReceivePack && c.Request.Method == http.MethodPost:
+		service = services.ServiceReceivePack
+	default:
+		c.String(http.StatusNotFound, "不支持的Git请求")
+		return
+	}
+
+	if service != services.ServiceUploadPack && service != services.ServiceReceivePack {
+		c.String(http.StatusForbidden, "仅支持智能HTTP协议")
+		return
+	}
+
+	repo, err := h.repoService.GetByName(projectID, repoName)
+	if err != nil {
+		c.String(http.StatusNotFound, "仓库不存在")
+		return
+	}
+
+	session, authenticated := gitSessionFromContext(c)
+	if token, ok := c.Get("deploy_token"); ok {
+		credential, err := h.deployKeyService.AuthenticateToken(fmt.Sprint(token))
+		if err != nil {
+			c.Header("WWW-Authenticate", `Basic realm="Git"`)
+			c.String(http.StatusUnauthorized, err.Error())
+			return
+		}
+		if err := h.deployKeyService.Authorize(credential, repo.ID, service == services.ServiceReceivePack, c.ClientIP()); err != nil {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		session = credential.Session(c.ClientIP(), c.Request.UserAgent(), "http")
+		authenticated = true
+	}
+	if !authenticated && (service == services.ServiceReceivePack || repo.Visibility != "public") {
+		c.Header("WWW-Authenticate", `Basic realm="Git"`)
+		c.String(http.StatusUnauthorized, "需要认证")
+		return
+	}
+
+	// 用户凭据按项目角色校验：拉取需要读权限，推送需要写权限；部署令牌已在上面按仓库授权
+	if _, isDeployToken := c.Get("deploy_token"); authenticated && !isDeployToken {
+		required := services.RepositoryAccessRead
+		if service == services.ServiceReceivePack {
+			required = services.RepositoryAccessWrite
+		}
+		access, err := h.repoService.AccessLevel(repo, session.UserID)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		if access < services.RepositoryAccessRead {
+			c.String(http.StatusNotFound, "仓库不存在")
+			return
+		}
+		if access < required {
+			c.String(http.StatusForbidden, services.ErrRepositoryAccessDenied.Error())
+			return
+		}
+	}
+
+	if service == services.ServiceReceivePack && !repo.Settings.AllowPush {
+		c.String(http.StatusForbidden, "仓库已禁止推送")
+		return
+	}
+
+	if action == "info/refs" {
+		h.advertiseReferences(c, repo, service)
+		return
+	}
+
+	body, err := requestBody(c.Request)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	defer body.Close()
+
+	c.Header("Content-Type", fmt.Sprintf("application/x-%s-result", service))
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+
+	if service == services.ServiceUploadPack {
+		err = h.gitProtocolService.UploadPack(c.Request.Context(), repo, session, body, c.Writer)
+	} else {
+		err = h.gitProtocolService.ReceivePack(c.Request.Context(), repo, session, body, c.Writer)
+	}
+	if err != nil {
+		c.Error(err)
+	}
+}
+
+// advertiseReferences 返回引用通告
+func (h *GitHTTPHandler) advertiseReferences(c *gin.Context, repo *models.Repository, service string) {
+	c.Header("Content-Type", fmt.Sprintf("application/x-%s-advertisement", service))
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+
+	if err := h.gitProtocolService.AdvertiseReferences(c.Request.Context(), repo, service, c.Writer); err != nil {
+		c.Error(err)
+	}
+}
+
+// parseGitPath 解析 /:project_id/:name.git/:action 形式的路径
+func parseGitPath(path string) (uuid.UUID, string, string, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 3)
+	if len(parts) != 3 {
+		return uuid.Nil, "", "", false
+	}
+
+	projectID, err := uuid.Parse(parts[0])
+	if err != nil {
+		return uuid.Nil, "", "", false
+	}
+
+	name := strings.TrimSuffix(parts[1], ".git")
+	if name == "" {
+		return uuid.Nil, "", "", false
+	}
+
+	return projectID, name, parts[2], true
+}
+
+// gitSessionFromContext 从认证中间件写入的上下文构造Git会话
+func gitSessionFromContext(c *gin.Context) (*services.GitSession, bool) {
+	session := &services.GitSession{
+		ClientIP:   c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		Protocol:   "http",
+		AuthMethod: services.AuthMethodJWT,
+	}
+
+	userIDValue, exists := c.Get("user_id")
+	if !exists {
+		return session, false
+	}
+
+	userID, err := uuid.Parse(fmt.Sprint(userIDValue))
+	if err != nil {
+		return session, false
+	}
+	session.UserID = userID
+
+	if username, ok := c.Get("username"); ok && username != nil {
+		session.Username = fmt.Sprint(username)
+	}
+
+	return session, true
+}
+
+// requestBody 获取请求体，处理客户端的gzip压缩
+func requestBody(r *http.Request) (io.ReadCloser, error) {
+	if r.Header.Get("Content-Encoding") != "gzip" {
+		return r.Body, nil
+	}
+
+	reader, err := gzip.NewReader(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("解压请求体失败: %w", err)
+	}
+	return reader, nil
+}
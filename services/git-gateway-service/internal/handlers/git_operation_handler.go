@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -14,12 +15,14 @@ import (
 // GitOperationHandler Git操作审计处理器
 type GitOperationHandler struct {
 	gitOpService services.GitOperationService
+	repoService  services.RepositoryService
 }
 
 // NewGitOperationHandler 创建Git操作审计处理器
-func NewGitOperationHandler(gitOpService services.GitOperationService) *GitOperationHandler {
+func NewGitOperationHandler(gitOpService services.GitOperationService, repoService services.RepositoryService) *GitOperationHandler {
 	return &GitOperationHandler{
 		gitOpService: gitOpService,
+		repoService:  repoService,
 	}
 }
 
@@ -84,6 +87,15 @@ func (h *GitOperationHandler) ListOperations(c *gin.Context) {
 		req.ClientIP = &clientIP
 	}
 
+	if credentialIDParam := c.Query("credential_id"); credentialIDParam != "" {
+		credentialID, err := uuid.Parse(credentialIDParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的凭据ID"})
+			return
+		}
+		req.CredentialID = &credentialID
+	}
+
 	// 时间范围参数
 	if startTimeParam := c.Query("start_time"); startTimeParam != "" {
 		startTime, err := time.Parse(time.RFC3339, startTimeParam)
@@ -206,4 +218,193 @@ func (h *GitOperationHandler) CleanupOldRecords(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"message": "清理完成",
 	})
-}
\ No newline at end of file
+}
+
+// GetRepositoryTraffic 获取仓库克隆/拉取流量时间序列
+func (h *GitOperationHandler) GetRepositoryTraffic(c *gin.Context) {
+	repositoryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的仓库ID"})
+		return
+	}
+
+	req := services.TrafficStatsRequest{
+		RepositoryID: repositoryID,
+		Interval:     c.DefaultQuery("interval", "day"),
+	}
+	if req.StartTime, req.EndTime, err = parseTimeRange(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stats, err := h.gitOpService.GetTrafficStats(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    stats,
+	})
+}
+
+// GetTopClients 获取操作最多的用户和IP
+func (h *GitOperationHandler) GetTopClients(c *gin.Context) {
+	var req services.TopClientsRequest
+
+	if repositoryIDParam := c.Query("repository_id"); repositoryIDParam != "" {
+		repositoryID, err := uuid.Parse(repositoryIDParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的仓库ID"})
+			return
+		}
+		req.RepositoryID = &repositoryID
+	}
+
+	if operation := c.Query("operation"); operation != "" {
+		req.Operation = &operation
+	}
+
+	var err error
+	if req.StartTime, req.EndTime, err = parseTimeRange(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if !authorizeOperationScope(c, h.repoService, req.RepositoryID) {
+		return
+	}
+
+	clients, err := h.gitOpService.GetTopClients(&req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    clients,
+	})
+}
+
+// DetectAnomalies 检测异常活动（批量克隆、失败激增、大流量传输）
+func (h *GitOperationHandler) DetectAnomalies(c *gin.Context) {
+	var req services.AnomalyDetectionRequest
+
+	var err error
+	if req.StartTime, req.EndTime, err = parseTimeRange(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	windowMinutes, _ := strconv.Atoi(c.DefaultQuery("window_minutes", "60"))
+	req.Window = time.Duration(windowMinutes) * time.Minute
+	req.MassCloneThreshold, _ = strconv.Atoi(c.Query("mass_clone_threshold"))
+	req.FailureThreshold, _ = strconv.Atoi(c.Query("failure_threshold"))
+	req.TransferThreshold, _ = strconv.ParseInt(c.Query("transfer_threshold"), 10, 64)
+
+	// 异常检测跨所有仓库统计
+	if !authorizeOperationScope(c, h.repoService, nil) {
+		return
+	}
+
+	anomalies, err := h.gitOpService.DetectAnomalies(&req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "检测完成",
+		"data":    anomalies,
+	})
+}
+
+// ExportOperations 按时间范围导出操作记录（format=csv 或 jsonl）
+func (h *GitOperationHandler) ExportOperations(c *gin.Context) {
+	req := services.ExportOperationsRequest{
+		Format: c.DefaultQuery("format", services.ExportFormatCSV),
+	}
+
+	startTime, endTime, err := parseTimeRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if startTime == nil || endTime == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "导出必须指定开始时间和结束时间"})
+		return
+	}
+	req.StartTime, req.EndTime = *startTime, *endTime
+
+	if repositoryIDParam := c.Query("repository_id"); repositoryIDParam != "" {
+		repositoryID, err := uuid.Parse(repositoryIDParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的仓库ID"})
+			return
+		}
+		req.RepositoryID = &repositoryID
+	}
+
+	if userIDParam := c.Query("user_id"); userIDParam != "" {
+		userID, err := uuid.Parse(userIDParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+			return
+		}
+		req.UserID = &userID
+	}
+
+	if operation := c.Query("operation"); operation != "" {
+		req.Operation = &operation
+	}
+
+	if !authorizeOperationScope(c, h.repoService, req.RepositoryID) {
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	switch req.Format {
+	case services.ExportFormatCSV:
+	case services.ExportFormatJSONL:
+		contentType = "application/x-ndjson"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "导出格式必须为 csv 或 jsonl"})
+		return
+	}
+
+	filename := fmt.Sprintf("git-operations-%s-%s.%s",
+		req.StartTime.UTC().Format("20060102"), req.EndTime.UTC().Format("20060102"), req.Format)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	// 响应头已发送，导出中途出错只能记录日志并中断输出
+	if err := h.gitOpService.ExportOperations(&req, c.Writer); err != nil {
+		fmt.Printf("导出Git操作记录失败: %v\n", err)
+		c.Abort()
+	}
+}
+
+// parseTimeRange 解析 start_time / end_time 查询参数（RFC3339）
+func parseTimeRange(c *gin.Context) (*time.Time, *time.Time, error) {
+	var startTime, endTime *time.Time
+	if startTimeParam := c.Query("start_time"); startTimeParam != "" {
+		t, err := time.Parse(time.RFC3339, startTimeParam)
+		if err != nil {
+			return nil, nil, fmt.Errorf("无效的开始时间格式")
+		}
+		startTime = &t
+	}
+	if endTimeParam := c.Query("end_time"); endTimeParam != "" {
+		t, err := time.Parse(time.RFC3339, endTimeParam)
+		if err != nil {
+			return nil, nil, fmt.Errorf("无效的结束时间格式")
+		}
+		endTime = &t
+	}
+	return startTime, endTime, nil
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"git-gateway-service/internal/config"
+	"git-gateway-service/internal/models"
+	"git-gateway-service/internal/services"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+)
+
+// GitSSHServer Git SSH协议服务：通过用户访问密钥或仓库部署密钥认证，
+// 仅支持执行 git-upload-pack / git-receive-pack
+type GitSSHServer struct {
+	config             *config.Config
+	repoService        services.RepositoryService
+	gitProtocolService services.GitProtocolService
+	accessKeyService   services.AccessKeyService
+	deployKeyService   services.DeployKeyService
+
+	mu       sync.Mutex
+	listener net.Listener
+}
+
+// sshIdentity SSH公钥对应的身份：用户访问密钥或部署密钥
+type sshIdentity struct {
+	accessKey *models.AccessKey
+	deployKey *services.DeployCredential
+}
+
+// sshExitStatus exit-status请求载荷
+type sshExitStatus struct {
+	Status uint32
+}
+
+// NewGitSSHServer 创建Git SSH协议服务
+func NewGitSSHServer(cfg *config.Config, repoService services.RepositoryService, gitProtocolService services.GitProtocolService,
+	accessKeyService services.AccessKeyService, deployKeyService services.DeployKeyService) *GitSSHServer {
+	return &GitSSHServer{
+		config:             cfg,
+		repoService:        repoService,
+		gitProtocolService: gitProtocolService,
+		accessKeyService:   accessKeyService,
+		deployKeyService:   deployKeyService,
+	}
+}
+
+// ListenAndServe 加载主机密钥并在指定地址接受SSH连接
+func (s *GitSSHServer) ListenAndServe(addr string) error {
+	hostKey, err := loadHostKey(s.config.Git.SSHHostKey)
+	if err != nil {
+		return err
+	}
+
+	sshConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			fingerprint := services.SSHKeyFingerprint(key)
+			if _, err := s.identify(fingerprint); err != nil {
+				return nil, err
+			}
+			return &ssh.Permissions{Extensions: map[string]string{"fingerprint": fingerprint}}, nil
+		},
+	}
+	sshConfig.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("监听SSH端口失败: %w", err)
+	}
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleConn(conn, sshConfig)
+	}
+}
+
+// Close 停止接受新的SSH连接
+func (s *GitSSHServer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// handleConn 完成SSH握手并处理会话通道
+func (s *GitSSHServer) handleConn(netConn net.Conn, sshConfig *ssh.ServerConfig) {
+	conn, chans, reqs, err := ssh.NewServerConn(netConn, sshConfig)
+	if err != nil {
+		netConn.Close()
+		return
+	}
+	defer conn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "仅支持session通道")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go s.handleSession(conn, channel, requests)
+	}
+}
+
+// handleSession 处理会话请求：exec执行Git命令，shell仅提示认证成功
+func (s *GitSSHServer) handleSession(conn *ssh.ServerConn, channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	for req := range requests {
+		switch req.Type {
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			go ssh.DiscardRequests(requests)
+
+			status := s.execute(conn, channel, payload.Command)
+			channel.SendRequest("exit-status", false, ssh.Marshal(&sshExitStatus{Status: status}))
+			return
+		case "shell":
+			req.Reply(true, nil)
+			go ssh.DiscardRequests(requests)
+
+			fmt.Fprintln(channel.Stderr(), "认证成功，但不提供shell访问")
+			channel.SendRequest("exit-status", false, ssh.Marshal(&sshExitStatus{Status: 1}))
+			return
+		default:
+			// env（含GIT_PROTOCOL）、pty-req等请求不支持，客户端回退到协议v0
+			req.Reply(false, nil)
+		}
+	}
+}
+
+// execute 解析并执行Git命令，返回退出码
+func (s *GitSSHServer) execute(conn *ssh.ServerConn, channel ssh.Channel, command string) uint32 {
+	fail := func(err error) uint32 {
+		fmt.Fprintf(channel.Stderr(), "fatal: %v\n", err)
+		return 1
+	}
+
+	service, projectID, repoName, err := parseSSHCommand(command)
+	if err != nil {
+		return fail(err)
+	}
+
+	repo, err := s.repoService.GetByName(projectID, repoName)
+	if err != nil {
+		return fail(fmt.Errorf("仓库不存在"))
+	}
+
+	identity, err := s.identify(conn.Permissions.Extensions["fingerprint"])
+	if err != nil {
+		return fail(err)
+	}
+
+	clientIP, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	session, err := s.authorize(identity, repo, service == services.ServiceReceivePack, clientIP, string(conn.ClientVersion()))
+	if err != nil {
+		return fail(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := s.gitProtocolService.ServeSSH(ctx, repo, session, service, channel, channel); err != nil {
+		log.Printf("SSH %s 执行失败 [%s]: %v", service, repo.Name, err)
+		return fail(err)
+	}
+	return 0
+}
+
+// identify 根据公钥指纹查找用户访问密钥或部署密钥
+func (s *GitSSHServer) identify(fingerprint string) (*sshIdentity, error) {
+	if accessKey, err := s.accessKeyService.GetByFingerprint(fingerprint); err == nil {
+		return &sshIdentity{accessKey: accessKey}, nil
+	}
+
+	deployKey, err := s.deployKeyService.FindKey(fingerprint)
+	if err != nil {
+		return nil, fmt.Errorf("公钥未授权: %w", err)
+	}
+	return &sshIdentity{deployKey: deployKey}, nil
+}
+
+// authorize 校验身份对仓库的访问权限并构造Git会话
+func (s *GitSSHServer) authorize(identity *sshIdentity, repo *models.Repository, write bool,
+	clientIP, userAgent string) (*services.GitSession, error) {
+	if write && !repo.Settings.AllowPush {
+		return nil, fmt.Errorf("仓库已禁止推送")
+	}
+
+	if identity.deployKey != nil {
+		if err := s.deployKeyService.Authorize(identity.deployKey, repo.ID, write, clientIP); err != nil {
+			return nil, err
+		}
+		return identity.deployKey.Session(clientIP, userAgent, "ssh"), nil
+	}
+
+	accessKey := identity.accessKey
+	if accessKey.RepositoryID != nil && *accessKey.RepositoryID != repo.ID {
+		return nil, fmt.Errorf("访问密钥不能访问该仓库")
+	}
+	if write && accessKey.AccessLevel == "read" {
+		return nil, fmt.Errorf("访问密钥为只读权限")
+	}
+
+	// 访问密钥代表其所有者，所有者须具有项目中对应的仓库权限（全局密钥同样适用）
+	required := services.RepositoryAccessRead
+	if write {
+		required = services.RepositoryAccessWrite
+	}
+	if err := s.repoService.Authorize(repo, accessKey.UserID, required); err != nil {
+		return nil, err
+	}
+	if err := s.accessKeyService.UpdateLastUsed(accessKey.ID); err != nil {
+		log.Printf("更新访问密钥使用时间失败 [%s]: %v", accessKey.ID, err)
+	}
+
+	credentialID := accessKey.ID
+	return &services.GitSession{
+		UserID:       accessKey.UserID,
+		ClientIP:     clientIP,
+		UserAgent:    userAgent,
+		Protocol:     "ssh",
+		AuthMethod:   services.AuthMethodAccessKey,
+		CredentialID: &credentialID,
+	}, nil
+}
+
+// parseSSHCommand 解析 git-upload-pack '/<project_id>/<name>.git' 形式的命令，
+// 兼容 scp 风格地址（git@host:<project_id>/<name>.git）产生的无前导斜杠路径
+func parseSSHCommand(command string) (string, uuid.UUID, string, error) {
+	service, path, ok := strings.Cut(strings.TrimSpace(command), " ")
+	if !ok || (service != services.ServiceUploadPack && service != services.ServiceReceivePack) {
+		return "", uuid.Nil, "", fmt.Errorf("不支持的命令: %s", command)
+	}
+
+	path = strings.Trim(strings.TrimSpace(path), `'"`)
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != 2 {
+		return "", uuid.Nil, "", fmt.Errorf("无效的仓库路径: %s", path)
+	}
+
+	projectID, err := uuid.Parse(parts[0])
+	if err != nil {
+		return "", uuid.Nil, "", fmt.Errorf("无效的仓库路径: %s", path)
+	}
+	name := strings.TrimSuffix(parts[1], ".git")
+	if name == "" {
+		return "", uuid.Nil, "", fmt.Errorf("无效的仓库路径: %s", path)
+	}
+
+	return service, projectID, name, nil
+}
+
+// loadHostKey 读取主机密钥，不存在时生成Ed25519密钥并尝试保存，保存失败则仅在本次运行中使用
+func loadHostKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("解析SSH主机密钥失败: %w", err)
+		}
+		return signer, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("读取SSH主机密钥失败: %w", err)
+	}
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("生成SSH主机密钥失败: %w", err)
+	}
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("生成SSH主机密钥失败: %w", err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(privateKey, "git-gateway-service")
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(path), 0o700); err == nil {
+			err = os.WriteFile(path, pem.EncodeToMemory(block), 0o600)
+		}
+	}
+	if err != nil {
+		log.Printf("保存SSH主机密钥失败，本次运行使用临时密钥: %v", err)
+	} else {
+		log.Printf("已生成SSH主机密钥: %s", path)
+	}
+
+	return signer, nil
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"git-gateway-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// MergeQueueHandler 合并队列处理器
+type MergeQueueHandler struct {
+	mergeQueueService services.MergeQueueService
+}
+
+// NewMergeQueueHandler 创建合并队列处理器
+func NewMergeQueueHandler(mergeQueueService services.MergeQueueService) *MergeQueueHandler {
+	return &MergeQueueHandler{
+		mergeQueueService: mergeQueueService,
+	}
+}
+
+// EnqueueMerge 将分支加入合并队列
+func (h *MergeQueueHandler) EnqueueMerge(c *gin.Context) {
+	repositoryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的仓库ID"})
+		return
+	}
+
+	userID, err := uuid.Parse(fmt.Sprint(c.MustGet("user_id")))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的用户身份"})
+		return
+	}
+
+	var req services.EnqueueMergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.RepositoryID = repositoryID
+	req.UserID = userID
+
+	entry, err := h.mergeQueueService.Enqueue(&req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrRepositoryAccessDenied) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "已加入合并队列",
+		"data":    entry,
+	})
+}
+
+// ListMergeQueue 获取目标分支的合并队列
+func (h *MergeQueueHandler) ListMergeQueue(c *gin.Context) {
+	repositoryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的仓库ID"})
+		return
+	}
+
+	entries, err := h.mergeQueueService.List(repositoryID, c.Query("target_branch"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    entries,
+	})
+}
+
+// GetMergeQueueEntry 获取合并队列条目及其队列位置
+func (h *MergeQueueHandler) GetMergeQueueEntry(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的条目ID"})
+		return
+	}
+
+	entry, err := h.mergeQueueService.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    entry,
+	})
+}
+
+// DequeueMerge 将条目移出合并队列
+func (h *MergeQueueHandler) DequeueMerge(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的条目ID"})
+		return
+	}
+
+	userID, err := uuid.Parse(fmt.Sprint(c.MustGet("user_id")))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的用户身份"})
+		return
+	}
+
+	if err := h.mergeQueueService.Dequeue(id, userID); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrRepositoryAccessDenied) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已移出合并队列",
+	})
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"net/http"
+
+	"git-gateway-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ProtectionRuleHandler 引用保护规则处理器
+type ProtectionRuleHandler struct {
+	protectionRuleService services.ProtectionRuleService
+	repoService           services.RepositoryService
+}
+
+// NewProtectionRuleHandler 创建引用保护规则处理器
+func NewProtectionRuleHandler(protectionRuleService services.ProtectionRuleService, repoService services.RepositoryService) *ProtectionRuleHandler {
+	return &ProtectionRuleHandler{
+		protectionRuleService: protectionRuleService,
+		repoService:           repoService,
+	}
+}
+
+// CreateProtectionRule 创建保护规则
+func (h *ProtectionRuleHandler) CreateProtectionRule(c *gin.Context) {
+	var req services.CreateProtectionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := authorizeRepository(c, h.repoService, req.RepositoryID, services.RepositoryAccessAdmin); !ok {
+		return
+	}
+
+	rule, err := h.protectionRuleService.Create(&req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "保护规则创建成功",
+		"data":    rule,
+	})
+}
+
+// GetProtectionRule 获取保护规则详情
+func (h *ProtectionRuleHandler) GetProtectionRule(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的保护规则ID"})
+		return
+	}
+
+	rule, err := h.protectionRuleService.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok := authorizeRepository(c, h.repoService, rule.RepositoryID, services.RepositoryAccessRead); !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    rule,
+	})
+}
+
+// ListProtectionRules 获取仓库的保护规则
+func (h *ProtectionRuleHandler) ListProtectionRules(c *gin.Context) {
+	repositoryIDParam := c.Query("repository_id")
+	repositoryID, err := uuid.Parse(repositoryIDParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的仓库ID"})
+		return
+	}
+
+	if _, ok := authorizeRepository(c, h.repoService, repositoryID, services.RepositoryAccessRead); !ok {
+		return
+	}
+
+	rules, err := h.protectionRuleService.GetByRepository(repositoryID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    rules,
+	})
+}
+
+// UpdateProtectionRule 更新保护规则
+func (h *ProtectionRuleHandler) UpdateProtectionRule(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的保护规则ID"})
+		return
+	}
+
+	var req services.UpdateProtectionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.authorizeRule(c, id) {
+		return
+	}
+
+	rule, err := h.protectionRuleService.Update(id, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "更新成功",
+		"data":    rule,
+	})
+}
+
+// DeleteProtectionRule 删除保护规则
+func (h *ProtectionRuleHandler) DeleteProtectionRule(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的保护规则ID"})
+		return
+	}
+
+	if !h.authorizeRule(c, id) {
+		return
+	}
+
+	if err := h.protectionRuleService.Delete(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "删除成功",
+	})
+}
+
+// ResolveProtectionRule 查询引用实际生效的保护规则
+func (h *ProtectionRuleHandler) ResolveProtectionRule(c *gin.Context) {
+	repositoryIDParam := c.Query("repository_id")
+	repositoryID, err := uuid.Parse(repositoryIDParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的仓库ID"})
+		return
+	}
+
+	refType := c.DefaultQuery("ref_type", services.RefTypeBranch)
+	name := c.Query("name")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "引用名称不能为空"})
+		return
+	}
+
+	if _, ok := authorizeRepository(c, h.repoService, repositoryID, services.RepositoryAccessRead); !ok {
+		return
+	}
+
+	rule, err := h.protectionRuleService.Resolve(repositoryID, refType, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data": gin.H{
+			"protected": rule != nil,
+			"rule":      rule,
+		},
+	})
+}
+
+// authorizeRule 校验当前用户对保护规则所属仓库具有管理权限
+func (h *ProtectionRuleHandler) authorizeRule(c *gin.Context, id uuid.UUID) bool {
+	rule, err := h.protectionRuleService.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return false
+	}
+	_, ok := authorizeRepository(c, h.repoService, rule.RepositoryID, services.RepositoryAccessAdmin)
+	return ok
+}
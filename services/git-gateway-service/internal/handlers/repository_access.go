@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"git-gateway-service/internal/models"
+	"git-gateway-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// authorizeRepository 校验当前用户对仓库具有 required 访问级别，不通过时写入响应并返回false。
+// 读取权限不足时按仓库不存在处理，避免暴露私有仓库
+func authorizeRepository(c *gin.Context, repoService services.RepositoryService, repositoryID uuid.UUID,
+	required services.RepositoryAccess) (*models.Repository, bool) {
+	userID, err := uuid.Parse(fmt.Sprint(c.MustGet("user_id")))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的用户身份"})
+		return nil, false
+	}
+
+	repo, err := repoService.GetByID(repositoryID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err := repoService.Authorize(repo, userID, required); err != nil {
+		switch {
+		case !errors.Is(err, services.ErrRepositoryAccessDenied):
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		case required == services.RepositoryAccessRead:
+			c.JSON(http.StatusNotFound, gin.H{"error": "仓库不存在"})
+		default:
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+
+	return repo, true
+}
+
+// authorizeOperationScope 校验跨仓库统计与导出的查询范围：指定仓库时要求仓库管理权限，
+// 不指定仓库的全局查询只允许平台管理员和内部服务
+func authorizeOperationScope(c *gin.Context, repoService services.RepositoryService, repositoryID *uuid.UUID) bool {
+	if repositoryID != nil {
+		_, ok := authorizeRepository(c, repoService, *repositoryID, services.RepositoryAccessAdmin)
+		return ok
+	}
+	if !platformOperator(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "未指定仓库时仅平台管理员可以查询"})
+		return false
+	}
+	return true
+}
+
+// platformOperator 当前调用方是否为平台管理员或内部服务
+func platformOperator(c *gin.Context) bool {
+	role := c.GetString("role")
+	return role == "admin" || role == "service"
+}
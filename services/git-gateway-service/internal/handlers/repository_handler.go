@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -30,6 +31,13 @@ func (h *RepositoryHandler) CreateRepository(c *gin.Context) {
 		return
 	}
 
+	userID, err := uuid.Parse(fmt.Sprint(c.MustGet("user_id")))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的用户身份"})
+		return
+	}
+	req.UserID = userID
+
 	repo, err := h.repoService.Create(&req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -162,6 +170,11 @@ func (h *RepositoryHandler) ListRepositories(c *gin.Context) {
 		req.Search = &search
 	}
 
+	if isTemplate := c.Query("is_template"); isTemplate != "" {
+		value := isTemplate == "true"
+		req.IsTemplate = &value
+	}
+
 	// 分页参数
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"git-gateway-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SigningKeyHandler 签名密钥处理器
+type SigningKeyHandler struct {
+	signingKeyService services.SigningKeyService
+}
+
+// NewSigningKeyHandler 创建签名密钥处理器
+func NewSigningKeyHandler(signingKeyService services.SigningKeyService) *SigningKeyHandler {
+	return &SigningKeyHandler{
+		signingKeyService: signingKeyService,
+	}
+}
+
+// CreateSigningKey 登记签名密钥
+func (h *SigningKeyHandler) CreateSigningKey(c *gin.Context) {
+	var req services.CreateSigningKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := signingKeyUser(c)
+	if !ok {
+		return
+	}
+	req.UserID = userID
+
+	key, err := h.signingKeyService.Create(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "签名密钥创建成功",
+		"data":    key,
+	})
+}
+
+// GetSigningKey 获取签名密钥详情
+func (h *SigningKeyHandler) GetSigningKey(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的签名密钥ID"})
+		return
+	}
+
+	userID, ok := signingKeyUser(c)
+	if !ok {
+		return
+	}
+
+	key, err := h.signingKeyService.GetByID(id, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    key,
+	})
+}
+
+// ListSigningKeys 获取用户的签名密钥
+func (h *SigningKeyHandler) ListSigningKeys(c *gin.Context) {
+	userIDParam := c.Query("user_id")
+	userID, err := uuid.Parse(userIDParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+
+	keys, err := h.signingKeyService.GetByUser(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    keys,
+	})
+}
+
+// DeleteSigningKey 删除签名密钥
+func (h *SigningKeyHandler) DeleteSigningKey(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的签名密钥ID"})
+		return
+	}
+
+	userID, ok := signingKeyUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.signingKeyService.Delete(id, userID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrSigningKeyNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "删除成功",
+	})
+}
+
+// signingKeyUser 获取当前登录用户，签名密钥只能由所有者本人登记和管理
+func signingKeyUser(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(fmt.Sprint(c.MustGet("user_id")))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的用户身份"})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
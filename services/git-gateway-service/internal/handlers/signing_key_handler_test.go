@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"git-gateway-service/internal/models"
+	"git-gateway-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// recordingSigningKeyService 记录登记请求的签名密钥服务
+type recordingSigningKeyService struct {
+	services.SigningKeyService
+	created *services.CreateSigningKeyRequest
+}
+
+func (s *recordingSigningKeyService) Create(req *services.CreateSigningKeyRequest) (*models.SigningKey, error) {
+	s.created = req
+	return &models.SigningKey{UserID: req.UserID}, nil
+}
+
+func TestCreateSigningKeyUsesCaller(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	caller := uuid.New()
+	victim := uuid.New()
+
+	service := &recordingSigningKeyService{}
+	handler := NewSigningKeyHandler(service)
+	router := gin.New()
+	router.POST("/signing-keys", func(c *gin.Context) {
+		c.Set("user_id", caller.String())
+	}, handler.CreateSigningKey)
+
+	body := `{"user_id":"` + victim.String() + `","title":"laptop","key_type":"ssh","public_key":"ssh-ed25519 AAAA"}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/signing-keys", strings.NewReader(body)))
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	if service.created == nil || service.created.UserID != caller {
+		t.Fatalf("registered for %v, want caller %s", service.created, caller)
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+
+	"git-gateway-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// StorageHandler 仓库存储处理器
+type StorageHandler struct {
+	storageService services.StorageService
+	repoService    services.RepositoryService
+}
+
+// NewStorageHandler 创建仓库存储处理器
+func NewStorageHandler(storageService services.StorageService, repoService services.RepositoryService) *StorageHandler {
+	return &StorageHandler{
+		storageService: storageService,
+		repoService:    repoService,
+	}
+}
+
+// ListStorageRoots 获取存储根目录状态
+func (h *StorageHandler) ListStorageRoots(c *gin.Context) {
+	roots, err := h.storageService.ListRoots()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    roots,
+	})
+}
+
+// MoveRepository 将仓库迁移到另一个存储根目录
+func (h *StorageHandler) MoveRepository(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的仓库ID"})
+		return
+	}
+
+	// 平台管理员和内部服务可以迁移任意仓库，其他用户需要仓库管理权限
+	if !platformOperator(c) {
+		if _, ok := authorizeRepository(c, h.repoService, id, services.RepositoryAccessAdmin); !ok {
+			return
+		}
+	}
+
+	var req struct {
+		StorageRoot string `json:"storage_root" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	repo, err := h.storageService.MoveRepository(id, req.StorageRoot)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "仓库迁移成功",
+		"data":    repo,
+	})
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"git-gateway-service/internal/models"
+	"git-gateway-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// stubRepositoryService 按预设访问级别授权的仓库服务
+type stubRepositoryService struct {
+	services.RepositoryService
+	access services.RepositoryAccess
+}
+
+func (s *stubRepositoryService) GetByID(id uuid.UUID) (*models.Repository, error) {
+	return &models.Repository{ID: id}, nil
+}
+
+func (s *stubRepositoryService) Authorize(repo *models.Repository, userID uuid.UUID, required services.RepositoryAccess) error {
+	if s.access < required {
+		return services.ErrRepositoryAccessDenied
+	}
+	return nil
+}
+
+// recordingStorageService 记录迁移请求的存储服务
+type recordingStorageService struct {
+	services.StorageService
+	moved bool
+}
+
+func (s *recordingStorageService) MoveRepository(id uuid.UUID, root string) (*models.Repository, error) {
+	s.moved = true
+	return &models.Repository{ID: id, StorageRoot: root}, nil
+}
+
+func TestMoveRepositoryAuthorization(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		role       string
+		access     services.RepositoryAccess
+		wantStatus int
+	}{
+		{"platform admin", "admin", services.RepositoryAccessNone, http.StatusOK},
+		{"service token", "service", services.RepositoryAccessNone, http.StatusOK},
+		{"repository admin", "user", services.RepositoryAccessAdmin, http.StatusOK},
+		{"repository writer", "user", services.RepositoryAccessWrite, http.StatusForbidden},
+		{"no access", "user", services.RepositoryAccessNone, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &recordingStorageService{}
+			handler := NewStorageHandler(storage, &stubRepositoryService{access: tt.access})
+			router := gin.New()
+			router.POST("/repositories/:id/storage/move", func(c *gin.Context) {
+				c.Set("user_id", uuid.New().String())
+				c.Set("role", tt.role)
+			}, handler.MoveRepository)
+
+			w := httptest.NewRecorder()
+			path := "/repositories/" + uuid.New().String() + "/storage/move"
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"storage_root":"ssd"}`)))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if storage.moved != (tt.wantStatus == http.StatusOK) {
+				t.Fatalf("moved = %v, want %v", storage.moved, tt.wantStatus == http.StatusOK)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"strings"
 
+	"git-gateway-service/internal/services"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -35,13 +37,7 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 		}
 
 		// 解析token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(jwtSecret), nil
-		})
-
+		token, err := parseToken(tokenString, jwtSecret)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的token"})
 			c.Abort()
@@ -59,6 +55,8 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 			c.Set("user_id", claims["user_id"])
 			c.Set("tenant_id", claims["tenant_id"])
 			c.Set("username", claims["username"])
+			c.Set("role", claims["role"])
+			c.Set("issuer", claims["iss"])
 		}
 
 		c.Next()
@@ -79,6 +77,59 @@ func OptionalAuthMiddleware(jwtSecret string) gin.HandlerFunc {
 	}
 }
 
+// GitAuthMiddleware Git HTTP协议认证中间件
+// Git客户端通过Basic认证传递凭据（密码为访问token），也兼容Bearer token；
+// 部署令牌只对单个仓库有效，写入上下文后由处理器结合目标仓库校验；
+// 未携带凭据时放行，由处理器根据仓库可见性决定是否要求认证
+func GitAuthMiddleware(jwtSecret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var tokenString string
+		if _, password, ok := c.Request.BasicAuth(); ok {
+			tokenString = password
+		} else if authHeader := c.GetHeader("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+			tokenString = strings.TrimPrefix(authHeader, "Bearer ")
+		}
+
+		if tokenString == "" {
+			c.Next()
+			return
+		}
+
+		if strings.HasPrefix(tokenString, services.DeployTokenPrefix) {
+			c.Set("deploy_token", tokenString)
+			c.Next()
+			return
+		}
+
+		token, err := parseToken(tokenString, jwtSecret)
+		if err != nil || !token.Valid {
+			c.Header("WWW-Authenticate", `Basic realm="Git"`)
+			c.String(http.StatusUnauthorized, "认证失败")
+			c.Abort()
+			return
+		}
+
+		if claims, ok := token.Claims.(jwt.MapClaims); ok {
+			c.Set("user_id", claims["user_id"])
+			c.Set("tenant_id", claims["tenant_id"])
+			c.Set("username", claims["username"])
+			c.Set("role", claims["role"])
+		}
+
+		c.Next()
+	}
+}
+
+// parseToken 解析并校验JWT
+func parseToken(tokenString, jwtSecret string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return []byte(jwtSecret), nil
+	})
+}
+
 // RateLimitMiddleware 限流中间件（简单实现）
 func RateLimitMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -19,6 +19,7 @@ type Repository struct {
 	GitURL           string          `json:"git_url" gorm:"size:512;not null"`
 	HTTPURL          string          `json:"http_url" gorm:"size:512;not null"`
 	SSHURL           string          `json:"ssh_url" gorm:"size:512;not null"`
+	StorageRoot      string          `json:"storage_root" gorm:"size:64;not null;default:default"`    // 所在存储根目录名称
 	Size             int64           `json:"size" gorm:"default:0"`                                  // 仓库大小 (bytes)
 	CommitCount      int             `json:"commit_count" gorm:"default:0"`                          // 提交数量
 	BranchCount      int             `json:"branch_count" gorm:"default:1"`                          // 分支数量
@@ -26,6 +27,8 @@ type Repository struct {
 	Language         *string         `json:"language" gorm:"size:50"`                                // 主要语言
 	Topics           datatypes.JSON  `json:"topics" gorm:"type:jsonb;default:'[]'"`                  // 主题标签
 	Settings         RepositorySettings `json:"settings" gorm:"embedded"`                            // 仓库设置
+	IsTemplate       bool            `json:"is_template" gorm:"default:false;index"`                 // 是否为模板仓库
+	TemplateID       *uuid.UUID      `json:"template_id" gorm:"type:uuid"`                           // 创建时使用的模板仓库
 	LastActivityAt   *time.Time      `json:"last_activity_at"`                                       // 最后活动时间
 	CreatedAt        time.Time       `json:"created_at" gorm:"not null"`
 	UpdatedAt        time.Time       `json:"updated_at" gorm:"not null"`
@@ -38,6 +41,7 @@ type Repository struct {
 	Webhooks        []Webhook        `json:"webhooks,omitempty" gorm:"foreignKey:RepositoryID"`
 	PushEvents      []PushEvent      `json:"push_events,omitempty" gorm:"foreignKey:RepositoryID"`
 	AccessKeys      []AccessKey      `json:"access_keys,omitempty" gorm:"foreignKey:RepositoryID"`
+	ProtectionRules []ProtectionRule `json:"protection_rules,omitempty" gorm:"foreignKey:RepositoryID"`
 }
 
 // RepositorySettings 仓库设置
@@ -51,6 +55,7 @@ type RepositorySettings struct {
 	EnableWiki          bool                `json:"enable_wiki" gorm:"default:true"`
 	AutoDeleteBranch    bool                `json:"auto_delete_branch" gorm:"default:false"`
 	DefaultMergeMethod  string              `json:"default_merge_method" gorm:"size:20;default:merge"` // merge, squash, rebase
+	MergeQueuePipelineID *uuid.UUID         `json:"merge_queue_pipeline_id" gorm:"type:uuid"`         // 合并队列验证使用的CI/CD流水线
 }
 
 // Project 项目模型 (简化版)
@@ -60,6 +65,41 @@ type Project struct {
 	TenantID uuid.UUID `json:"tenant_id" gorm:"type:uuid;not null"`
 }
 
+// ProjectMember 项目成员（简化版，由项目服务维护，用于仓库访问控制）
+type ProjectMember struct {
+	ProjectID uuid.UUID `json:"project_id" gorm:"type:uuid;primary_key"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;primary_key"`
+	RoleID    uuid.UUID `json:"role_id" gorm:"type:uuid;not null"`
+}
+
+// TenantMember 租户成员（简化版，由租户服务维护，用于内部仓库访问控制）
+type TenantMember struct {
+	TenantID uuid.UUID `json:"tenant_id" gorm:"type:uuid;primary_key"`
+	UserID   uuid.UUID `json:"user_id" gorm:"type:uuid;primary_key"`
+	RoleID   uuid.UUID `json:"role_id" gorm:"type:uuid;not null"`
+	Status   string    `json:"status" gorm:"size:20;not null;default:active"`
+}
+
+// Role 角色（简化版，由IAM服务维护），Permissions 为权限字符串数组，如 ["repository:write"]
+type Role struct {
+	ID          uuid.UUID      `json:"id" gorm:"type:uuid;primary_key"`
+	Name        string         `json:"name" gorm:"size:255;not null"`
+	Permissions datatypes.JSON `json:"permissions" gorm:"type:jsonb"`
+}
+
+// AuditLog 平台审计日志（按月分区的共享表，由迁移脚本创建）
+type AuditLog struct {
+	ID               int64          `json:"id" gorm:"primary_key"`
+	TenantID         uuid.UUID      `json:"tenant_id" gorm:"type:uuid;not null"`
+	UserID           *uuid.UUID     `json:"user_id" gorm:"type:uuid"`
+	Action           string         `json:"action" gorm:"size:100;not null"`
+	TargetEntityType string         `json:"target_entity_type" gorm:"size:50"`
+	TargetEntityID   *uuid.UUID     `json:"target_entity_id" gorm:"type:uuid"`
+	Details          datatypes.JSON `json:"details" gorm:"type:jsonb"`
+	ClientIP         *string        `json:"client_ip" gorm:"type:inet"`
+	CreatedAt        time.Time      `json:"created_at" gorm:"not null"`
+}
+
 // Branch 分支模型
 type Branch struct {
 	ID           uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
@@ -89,6 +129,22 @@ type BranchProtection struct {
 	AllowDeletions           bool `json:"allow_deletions" gorm:"default:false"`
 }
 
+// ProtectionRule 引用保护规则（仓库级，按通配符匹配分支或标签）
+type ProtectionRule struct {
+	ID            uuid.UUID        `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
+	RepositoryID  uuid.UUID        `json:"repository_id" gorm:"type:uuid;not null;index"`
+	RefType       string           `json:"ref_type" gorm:"size:10;not null;default:branch"` // branch, tag
+	Pattern       string           `json:"pattern" gorm:"size:255;not null"`                 // 通配符，如 release/*、v*
+	Priority      int              `json:"priority" gorm:"default:0"`                        // 多条规则命中时数值大者优先
+	Protection    BranchProtection `json:"protection" gorm:"embedded"`
+	PushAllowlist datatypes.JSON   `json:"push_allowlist" gorm:"type:jsonb;default:'[]'"` // RestrictPushes时允许推送的用户ID
+	CreatedAt     time.Time        `json:"created_at" gorm:"not null"`
+	UpdatedAt     time.Time        `json:"updated_at" gorm:"not null"`
+
+	// 关联关系
+	Repository *Repository `json:"repository,omitempty" gorm:"foreignKey:RepositoryID"`
+}
+
 // Tag 标签模型
 type Tag struct {
 	ID           uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
@@ -188,6 +244,120 @@ type AccessKey struct {
 	Repository *Repository `json:"repository,omitempty" gorm:"foreignKey:RepositoryID"`
 }
 
+// DeployKey 部署密钥（仓库级SSH公钥，不属于任何用户，供CI系统和服务器访问单个仓库）
+type DeployKey struct {
+	ID           uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
+	RepositoryID uuid.UUID  `json:"repository_id" gorm:"type:uuid;not null;index"`
+	Title        string     `json:"title" gorm:"size:255;not null"`
+	PublicKey    string     `json:"public_key" gorm:"type:text;not null"`
+	Fingerprint  string     `json:"fingerprint" gorm:"size:64;not null;uniqueIndex"`
+	KeyType      string     `json:"key_type" gorm:"size:20;not null"`
+	Scope        string     `json:"scope" gorm:"size:10;not null;default:read"` // read, write
+	ExpiresAt    *time.Time `json:"expires_at"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	LastUsedIP   *string    `json:"last_used_ip" gorm:"size:45"`
+	CreatedBy    uuid.UUID  `json:"created_by" gorm:"type:uuid;not null"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"not null"`
+	UpdatedAt    time.Time  `json:"updated_at" gorm:"not null"`
+}
+
+// DeployToken 部署令牌（仓库级Git HTTP凭据，仅保存令牌哈希）
+type DeployToken struct {
+	ID           uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
+	RepositoryID uuid.UUID  `json:"repository_id" gorm:"type:uuid;not null;index"`
+	Title        string     `json:"title" gorm:"size:255;not null"`
+	TokenHash    string     `json:"-" gorm:"size:64;not null;uniqueIndex"`    // SHA-256
+	TokenHint    string     `json:"token_hint" gorm:"size:20;not null"`      // 令牌前若干位，便于识别
+	Scope        string     `json:"scope" gorm:"size:10;not null;default:read"` // read, write
+	ExpiresAt    *time.Time `json:"expires_at"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	LastUsedIP   *string    `json:"last_used_ip" gorm:"size:45"`
+	CreatedBy    uuid.UUID  `json:"created_by" gorm:"type:uuid;not null"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"not null"`
+	UpdatedAt    time.Time  `json:"updated_at" gorm:"not null"`
+}
+
+// RepositoryInsight 仓库分析结果（默认分支推送后由后台分析生成）
+type RepositoryInsight struct {
+	ID             uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
+	RepositoryID   uuid.UUID      `json:"repository_id" gorm:"type:uuid;not null;uniqueIndex"`
+	CommitSHA      string         `json:"commit_sha" gorm:"size:40;not null"`              // 分析时默认分支的提交
+	Languages      datatypes.JSON `json:"languages" gorm:"type:jsonb;default:'[]'"`       // 语言占比（按字节）
+	Contributors   datatypes.JSON `json:"contributors" gorm:"type:jsonb;default:'[]'"`    // 贡献者统计
+	CommitActivity datatypes.JSON `json:"commit_activity" gorm:"type:jsonb;default:'[]'"` // 每周提交数
+	CodeFrequency  datatypes.JSON `json:"code_frequency" gorm:"type:jsonb;default:'[]'"`  // 每周新增/删除行数
+	Topics         datatypes.JSON `json:"topics" gorm:"type:jsonb;default:'[]'"`          // 自动识别的主题标签
+	Truncated      bool           `json:"truncated" gorm:"default:false"`                 // 历史过长，贡献者与频率统计仅覆盖最近的提交
+	AnalyzedAt     time.Time      `json:"analyzed_at" gorm:"not null"`
+	CreatedAt      time.Time      `json:"created_at" gorm:"not null"`
+	UpdatedAt      time.Time      `json:"updated_at" gorm:"not null"`
+}
+
+// SigningKey 用户签名密钥（用于验证提交和标签签名）
+type SigningKey struct {
+	ID          uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
+	UserID      uuid.UUID      `json:"user_id" gorm:"type:uuid;not null;index"`
+	Title       string         `json:"title" gorm:"size:255;not null"`
+	KeyType     string         `json:"key_type" gorm:"size:10;not null"`          // gpg, ssh
+	PublicKey   string         `json:"public_key" gorm:"type:text;not null"`      // ASCII armored GPG公钥或SSH公钥
+	KeyID       *string        `json:"key_id" gorm:"size:16;index"`               // GPG主密钥ID
+	SubkeyIDs   datatypes.JSON `json:"subkey_ids" gorm:"type:jsonb;default:'[]'"` // GPG子密钥ID
+	Fingerprint string         `json:"fingerprint" gorm:"size:128;not null;uniqueIndex"`
+	Emails      datatypes.JSON `json:"emails" gorm:"type:jsonb;default:'[]'"` // GPG密钥身份中的邮箱
+	ExpiresAt   *time.Time     `json:"expires_at"`
+	CreatedAt   time.Time      `json:"created_at" gorm:"not null"`
+	UpdatedAt   time.Time      `json:"updated_at" gorm:"not null"`
+}
+
+// RepositoryStorageLease 仓库存储使用租约，读写仓库目录期间持有并定期续期，实例退出后过期失效。
+// exclusive 为迁移切换存储位置时的独占租约，存在时不再发放新的共享租约
+type RepositoryStorageLease struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;index"`
+	Exclusive    bool      `json:"exclusive" gorm:"not null;default:false"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"not null"`
+	CreatedAt    time.Time `json:"created_at" gorm:"not null"`
+}
+
+// MergeQueueEntry 合并队列条目（待合并到受保护分支的源分支）
+type MergeQueueEntry struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
+	RepositoryID   uuid.UUID  `json:"repository_id" gorm:"type:uuid;not null;index"`
+	TargetBranch   string     `json:"target_branch" gorm:"size:255;not null;index"`
+	SourceBranch   string     `json:"source_branch" gorm:"size:255;not null"`
+	PullRequestID  *uuid.UUID `json:"pull_request_id" gorm:"type:uuid"`                // 关联的合并请求
+	Title          string     `json:"title" gorm:"size:255;not null"`
+	UserID         uuid.UUID  `json:"user_id" gorm:"type:uuid;not null"`              // 加入队列的用户，合并以其身份执行
+	Status         string     `json:"status" gorm:"size:20;not null;default:queued"` // queued, testing, merged, failed, cancelled
+	Priority       int        `json:"priority" gorm:"default:0"`                      // 数值大者优先出队
+	HeadSHA        string     `json:"head_sha" gorm:"size:40"`                        // 参与验证的源分支提交
+	BaseSHA        string     `json:"base_sha" gorm:"size:40"`                        // 推测合并的基础提交
+	MergeSHA       string     `json:"merge_sha" gorm:"size:40"`                       // 推测合并提交
+	SpeculativeRef *string    `json:"speculative_ref" gorm:"size:255"`                // refs/merge-queue/<id>
+	BatchID        *uuid.UUID `json:"batch_id" gorm:"type:uuid;index"`
+	BatchPosition  int        `json:"batch_position" gorm:"default:0"`
+	PipelineRunID  *uuid.UUID `json:"pipeline_run_id" gorm:"type:uuid"`
+	Attempts       int        `json:"attempts" gorm:"default:0"` // 验证次数（批次中前序条目失败后会重新验证）
+	FailureReason  *string    `json:"failure_reason" gorm:"type:text"`
+	MergedAt       *time.Time `json:"merged_at"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"not null"`
+	UpdatedAt      time.Time  `json:"updated_at" gorm:"not null"`
+}
+
+// PullRequest 合并请求（简化版，合并队列据此校验条目是否可合并）
+type PullRequest struct {
+	ID           uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	RepositoryID uuid.UUID  `json:"repository_id" gorm:"type:uuid;not null"`
+	PRNumber     int64      `json:"pr_number" gorm:"column:pr_number;not null"`
+	Title        string     `json:"title" gorm:"size:512;not null"`
+	SourceBranch string     `json:"source_branch" gorm:"size:255;not null"`
+	TargetBranch string     `json:"target_branch" gorm:"size:255;not null"`
+	Status       string     `json:"status" gorm:"size:20;not null"` // open, draft, merged, closed
+	CreatorID    uuid.UUID  `json:"creator_id" gorm:"type:uuid;not null"`
+	MergedAt     *time.Time `json:"merged_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
 // GitOperation Git操作审计记录
 type GitOperation struct {
 	ID           uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
@@ -203,6 +373,8 @@ type GitOperation struct {
 	ErrorMsg     *string        `json:"error_msg" gorm:"type:text"`               // 错误信息
 	Duration     int            `json:"duration" gorm:"default:0"`                // 操作耗时(毫秒)
 	BytesTransferred int64      `json:"bytes_transferred" gorm:"default:0"`       // 传输字节数
+	AuthMethod   string         `json:"auth_method" gorm:"size:20;not null;default:''"` // jwt, access_key, deploy_key, deploy_token
+	CredentialID *uuid.UUID     `json:"credential_id" gorm:"type:uuid;index"`     // 访问密钥或部署密钥/令牌ID
 	CreatedAt    time.Time      `json:"created_at" gorm:"not null"`
 
 	// 关联关系
@@ -211,9 +383,10 @@ type GitOperation struct {
 
 // User 用户模型 (简化版)
 type User struct {
-	ID       uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
-	Email    string    `json:"email" gorm:"size:255;uniqueIndex;not null"`
-	FullName *string   `json:"full_name" gorm:"size:255"`
+	ID            uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	Email         string    `json:"email" gorm:"size:255;uniqueIndex;not null"`
+	FullName      *string   `json:"full_name" gorm:"size:255"`
+	EmailVerified bool      `json:"email_verified" gorm:"default:false"`
 }
 
 // BeforeCreate GORM钩子：创建前
@@ -231,6 +404,13 @@ func (b *Branch) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+func (r *ProtectionRule) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return
+}
+
 func (t *Tag) BeforeCreate(tx *gorm.DB) (err error) {
 	if t.ID == uuid.Nil {
 		t.ID = uuid.New()
@@ -259,6 +439,41 @@ func (a *AccessKey) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+func (k *DeployKey) BeforeCreate(tx *gorm.DB) (err error) {
+	if k.ID == uuid.Nil {
+		k.ID = uuid.New()
+	}
+	return
+}
+
+func (t *DeployToken) BeforeCreate(tx *gorm.DB) (err error) {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return
+}
+
+func (i *RepositoryInsight) BeforeCreate(tx *gorm.DB) (err error) {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return
+}
+
+func (k *SigningKey) BeforeCreate(tx *gorm.DB) (err error) {
+	if k.ID == uuid.Nil {
+		k.ID = uuid.New()
+	}
+	return
+}
+
+func (e *MergeQueueEntry) BeforeCreate(tx *gorm.DB) (err error) {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return
+}
+
 func (g *GitOperation) BeforeCreate(tx *gorm.DB) (err error) {
 	if g.ID == uuid.Nil {
 		g.ID = uuid.New()
@@ -275,6 +490,10 @@ func (Branch) TableName() string {
 	return "branches"
 }
 
+func (ProtectionRule) TableName() string {
+	return "protection_rules"
+}
+
 func (Tag) TableName() string {
 	return "tags"
 }
@@ -295,6 +514,30 @@ func (AccessKey) TableName() string {
 	return "access_keys"
 }
 
+func (DeployKey) TableName() string {
+	return "deploy_keys"
+}
+
+func (DeployToken) TableName() string {
+	return "deploy_tokens"
+}
+
+func (RepositoryInsight) TableName() string {
+	return "repository_insights"
+}
+
+func (SigningKey) TableName() string {
+	return "signing_keys"
+}
+
+func (MergeQueueEntry) TableName() string {
+	return "merge_queue_entries"
+}
+
+func (PullRequest) TableName() string {
+	return "pull_requests"
+}
+
 func (GitOperation) TableName() string {
 	return "git_operations"
 }
@@ -303,6 +546,22 @@ func (Project) TableName() string {
 	return "projects"
 }
 
+func (ProjectMember) TableName() string {
+	return "project_members"
+}
+
+func (TenantMember) TableName() string {
+	return "tenant_members"
+}
+
+func (Role) TableName() string {
+	return "roles"
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
 func (User) TableName() string {
 	return "users"
 }
\ No newline at end of file
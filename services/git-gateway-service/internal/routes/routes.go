@@ -10,21 +10,43 @@ import (
 	"gorm.io/gorm"
 )
 
-// SetupRoutes 配置路由
-func SetupRoutes(db *gorm.DB, cfg *config.Config) *gin.Engine {
+// SetupRoutes 配置路由，同时返回共用同一组服务实例的Git SSH服务
+func SetupRoutes(db *gorm.DB, cfg *config.Config) (*gin.Engine, *handlers.GitSSHServer) {
 	// 创建服务实例
-	repoService := services.NewRepositoryService(db, cfg)
-	branchService := services.NewBranchService(db)
+	storageService := services.NewStorageService(db, cfg)
+	repoService := services.NewRepositoryService(db, cfg, storageService)
+	protectionRuleService := services.NewProtectionRuleService(db)
+	branchService := services.NewBranchService(db, protectionRuleService)
 	webhookService := services.NewWebhookService(db, cfg)
 	accessKeyService := services.NewAccessKeyService(db)
+	deployKeyService := services.NewDeployKeyService(db, accessKeyService)
 	gitOpService := services.NewGitOperationService(db)
+	signingKeyService := services.NewSigningKeyService(db)
+	commitService := services.NewCommitService(db, cfg, signingKeyService, storageService)
+	insightService := services.NewInsightService(db, cfg, storageService)
+	codeSearchService := services.NewCodeSearchService(db, cfg, storageService, repoService)
+	projectClient := services.NewProjectClient(cfg)
+	taskLinkService := services.NewTaskLinkService(db, projectClient)
+	gitProtocolService := services.NewGitProtocolService(db, cfg, protectionRuleService, storageService, signingKeyService,
+		insightService, codeSearchService, webhookService, gitOpService, taskLinkService)
+	cicdClient := services.NewCICDClient(cfg)
+	mergeQueueService := services.NewMergeQueueService(db, cfg, cicdClient, gitProtocolService, storageService, repoService, taskLinkService)
 
 	// 创建处理器实例
 	repoHandler := handlers.NewRepositoryHandler(repoService)
 	branchHandler := handlers.NewBranchHandler(branchService)
 	webhookHandler := handlers.NewWebhookHandler(webhookService)
 	accessKeyHandler := handlers.NewAccessKeyHandler(accessKeyService)
-	gitOpHandler := handlers.NewGitOperationHandler(gitOpService)
+	deployKeyHandler := handlers.NewDeployKeyHandler(deployKeyService, repoService)
+	gitOpHandler := handlers.NewGitOperationHandler(gitOpService, repoService)
+	protectionRuleHandler := handlers.NewProtectionRuleHandler(protectionRuleService, repoService)
+	signingKeyHandler := handlers.NewSigningKeyHandler(signingKeyService)
+	commitHandler := handlers.NewCommitHandler(commitService, repoService)
+	codeSearchHandler := handlers.NewCodeSearchHandler(codeSearchService, repoService)
+	mergeQueueHandler := handlers.NewMergeQueueHandler(mergeQueueService)
+	storageHandler := handlers.NewStorageHandler(storageService, repoService)
+	gitHTTPHandler := handlers.NewGitHTTPHandler(repoService, gitProtocolService, deployKeyService)
+	gitSSHServer := handlers.NewGitSSHServer(cfg, repoService, gitProtocolService, accessKeyService, deployKeyService)
 
 	// 设置Gin模式
 	if cfg.IsProduction() {
@@ -53,7 +75,7 @@ func SetupRoutes(db *gorm.DB, cfg *config.Config) *gin.Engine {
 	api := router.Group("/api/v1")
 	
 	// 应用JWT认证中间件
-	api.Use(middleware.JWTAuthMiddleware(cfg.JWT.Secret))
+	api.Use(middleware.AuthMiddleware(cfg.JWT.Secret))
 
 	// 仓库管理路由
 	repositories := api.Group("/repositories")
@@ -65,6 +87,30 @@ func SetupRoutes(db *gorm.DB, cfg *config.Config) *gin.Engine {
 		repositories.DELETE("/:id", repoHandler.DeleteRepository)
 		repositories.GET("/:id/stats", repoHandler.GetRepositoryStatistics)
 		repositories.POST("/:id/stats", repoHandler.UpdateRepositoryStatistics)
+		repositories.GET("/:id/traffic", gitOpHandler.GetRepositoryTraffic)
+
+		// 提交历史（含签名验证状态）
+		repositories.GET("/:id/commits", commitHandler.ListCommits)
+		repositories.GET("/:id/commits/:sha", commitHandler.GetCommit)
+		repositories.GET("/:id/files", commitHandler.GetFile)
+
+		// 重建代码搜索索引
+		repositories.POST("/:id/search-index", codeSearchHandler.ReindexRepository)
+
+		// 合并队列
+		repositories.POST("/:id/merge-queue", mergeQueueHandler.EnqueueMerge)
+		repositories.GET("/:id/merge-queue", mergeQueueHandler.ListMergeQueue)
+
+		// 迁移仓库存储位置
+		repositories.POST("/:id/storage/move", storageHandler.MoveRepository)
+
+		// 部署密钥与部署令牌（仓库级凭据，不属于任何用户）
+		repositories.POST("/:id/deploy-keys", deployKeyHandler.CreateDeployKey)
+		repositories.GET("/:id/deploy-keys", deployKeyHandler.ListDeployKeys)
+		repositories.DELETE("/:id/deploy-keys/:key_id", deployKeyHandler.DeleteDeployKey)
+		repositories.POST("/:id/deploy-tokens", deployKeyHandler.CreateDeployToken)
+		repositories.GET("/:id/deploy-tokens", deployKeyHandler.ListDeployTokens)
+		repositories.DELETE("/:id/deploy-tokens/:token_id", deployKeyHandler.DeleteDeployToken)
 		
 		// 通过项目ID和名称获取仓库
 		repositories.GET("/project/:project_id/name/:name", repoHandler.GetRepositoryByName)
@@ -87,6 +133,17 @@ func SetupRoutes(db *gorm.DB, cfg *config.Config) *gin.Engine {
 		branches.GET("/repository/:repository_id/name/:name", branchHandler.GetBranchByName)
 	}
 
+	// 引用保护规则路由
+	protectionRules := api.Group("/protection-rules")
+	{
+		protectionRules.POST("", protectionRuleHandler.CreateProtectionRule)
+		protectionRules.GET("", protectionRuleHandler.ListProtectionRules)
+		protectionRules.GET("/resolve", protectionRuleHandler.ResolveProtectionRule)
+		protectionRules.GET("/:id", protectionRuleHandler.GetProtectionRule)
+		protectionRules.PUT("/:id", protectionRuleHandler.UpdateProtectionRule)
+		protectionRules.DELETE("/:id", protectionRuleHandler.DeleteProtectionRule)
+	}
+
 	// 默认分支设置路由
 	api.POST("/repositories/:repository_id/branches/:branch_id/default", branchHandler.SetDefaultBranch)
 
@@ -116,34 +173,49 @@ func SetupRoutes(db *gorm.DB, cfg *config.Config) *gin.Engine {
 		accessKeys.POST("/validate", accessKeyHandler.ValidatePublicKey)
 	}
 
+	// 代码搜索路由
+	api.GET("/search/code", codeSearchHandler.SearchCode)
+
+	// 存储根目录状态
+	api.GET("/storage/roots", storageHandler.ListStorageRoots)
+
+	// 合并队列条目路由
+	mergeQueue := api.Group("/merge-queue")
+	{
+		mergeQueue.GET("/:id", mergeQueueHandler.GetMergeQueueEntry)
+		mergeQueue.DELETE("/:id", mergeQueueHandler.DequeueMerge)
+	}
+
+	// 签名密钥管理路由
+	signingKeys := api.Group("/signing-keys")
+	{
+		signingKeys.POST("", signingKeyHandler.CreateSigningKey)
+		signingKeys.GET("", signingKeyHandler.ListSigningKeys)
+		signingKeys.GET("/:id", signingKeyHandler.GetSigningKey)
+		signingKeys.DELETE("/:id", signingKeyHandler.DeleteSigningKey)
+	}
+
 	// Git操作审计路由
 	operations := api.Group("/operations")
 	{
 		operations.GET("", gitOpHandler.ListOperations)
 		operations.GET("/:id", gitOpHandler.GetOperation)
 		operations.GET("/stats", gitOpHandler.GetOperationStats)
+		operations.GET("/top-clients", gitOpHandler.GetTopClients)
+		operations.GET("/anomalies", gitOpHandler.DetectAnomalies)
+		operations.GET("/export", gitOpHandler.ExportOperations)
 		
 		// 清理旧记录（管理员功能）
 		operations.DELETE("/cleanup", gitOpHandler.CleanupOldRecords)
 	}
 
-	// Git协议处理路由 (不需要JWT认证)
+	// Git协议处理路由 (使用Git客户端凭据认证)
 	gitProtocol := router.Group("/git")
+	gitProtocol.Use(middleware.GitAuthMiddleware(cfg.JWT.Secret))
 	{
-		// HTTP Git协议支持
-		gitProtocol.Any("/*path", handleGitHTTPProtocol)
+		// HTTP Git智能协议支持
+		gitProtocol.Any("/*path", gitHTTPHandler.ServeGit)
 	}
 
-	return router
+	return router, gitSSHServer
 }
-
-// handleGitHTTPProtocol 处理Git HTTP协议请求
-func handleGitHTTPProtocol(c *gin.Context) {
-	// TODO: 实现Git HTTP协议处理逻辑
-	// 这里需要实现git-upload-pack和git-receive-pack的HTTP包装器
-	
-	c.JSON(501, gin.H{
-		"error": "Git HTTP协议处理器尚未实现",
-		"path":  c.Param("path"),
-	})
-}
\ No newline at end of file
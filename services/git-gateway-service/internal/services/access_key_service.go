@@ -1,12 +1,9 @@
 package services
 
 import (
-	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
-	"crypto/x509"
 	"encoding/base64"
-	"encoding/pem"
 	"fmt"
 	"strings"
 	"time"
@@ -90,11 +87,6 @@ func (s *accessKeyService) Create(req *CreateAccessKeyRequest) (*models.AccessKe
 		return nil, fmt.Errorf("公钥已存在")
 	}
 
-	// 检查指纹是否已存在
-	if err := s.db.Where("fingerprint = ?", keyInfo.Fingerprint).First(&existingKey).Error; err == nil {
-		return nil, fmt.Errorf("相同指纹的密钥已存在")
-	}
-
 	// 如果指定了仓库ID，检查仓库是否存在
 	if req.RepositoryID != nil {
 		var repo models.Repository
@@ -114,8 +106,21 @@ func (s *accessKeyService) Create(req *CreateAccessKeyRequest) (*models.AccessKe
 		AccessLevel:  req.AccessLevel,
 	}
 
-	if err := s.db.Create(accessKey).Error; err != nil {
-		return nil, fmt.Errorf("创建访问密钥失败: %w", err)
+	// 指纹不能与已有的访问密钥或部署密钥重复，检查与写入在同一指纹锁内完成
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockKeyFingerprint(tx, keyInfo.Fingerprint); err != nil {
+			return err
+		}
+		if err := checkFingerprintUnused(tx, keyInfo.Fingerprint); err != nil {
+			return err
+		}
+		if err := tx.Create(accessKey).Error; err != nil {
+			return fmt.Errorf("创建访问密钥失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return accessKey, nil
@@ -328,16 +333,18 @@ func (s *accessKeyService) List(req *ListAccessKeysRequest) ([]models.AccessKey,
 
 // calculateFingerprint 计算SSH密钥指纹
 func (s *accessKeyService) calculateFingerprint(publicKey ssh.PublicKey) string {
+	return SSHKeyFingerprint(publicKey)
+}
+
+// SSHKeyFingerprint 计算SSH公钥的SHA256指纹（与访问密钥、部署密钥中保存的格式一致）
+func SSHKeyFingerprint(publicKey ssh.PublicKey) string {
 	hash := sha256.Sum256(publicKey.Marshal())
 	return "SHA256:" + base64.StdEncoding.EncodeToString(hash[:])
 }
 
 // getKeySize 获取密钥长度
 func (s *accessKeyService) getKeySize(publicKey ssh.PublicKey) int {
-	switch key := publicKey.(type) {
-	case *rsa.PublicKey:
-		return key.N.BitLen()
-	case ssh.CryptoPublicKey:
+	if key, ok := publicKey.(ssh.CryptoPublicKey); ok {
 		if rsaKey, ok := key.CryptoPublicKey().(*rsa.PublicKey); ok {
 			return rsaKey.N.BitLen()
 		}
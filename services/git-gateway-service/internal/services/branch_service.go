@@ -25,12 +25,16 @@ type BranchService interface {
 }
 
 type branchService struct {
-	db *gorm.DB
+	db              *gorm.DB
+	protectionRules ProtectionRuleService
 }
 
 // NewBranchService 创建分支服务实例
-func NewBranchService(db *gorm.DB) BranchService {
-	return &branchService{db: db}
+func NewBranchService(db *gorm.DB, protectionRules ProtectionRuleService) BranchService {
+	return &branchService{
+		db:              db,
+		protectionRules: protectionRules,
+	}
 }
 
 // CreateBranchRequest 创建分支请求
@@ -77,18 +81,29 @@ func (s *branchService) Create(req *CreateBranchRequest) (*models.Branch, error)
 		Name:         req.Name,
 		CommitSHA:    req.CommitSHA,
 		IsDefault:    false, // 新建分支默认不是主分支
-		IsProtected:  req.IsProtected,
 	}
 
-	// 设置分支保护
-	if req.Protection != nil {
-		branch.Protection = *req.Protection
+	// 按仓库保护规则自动设置分支保护
+	if err := s.protectionRules.ApplyToBranch(branch); err != nil {
+		return nil, err
 	}
 
 	if err := s.db.Create(branch).Error; err != nil {
 		return nil, fmt.Errorf("创建分支失败: %w", err)
 	}
 
+	// 显式指定的保护设置转换为针对该分支的精确规则
+	if req.IsProtected || req.Protection != nil {
+		protection := models.BranchProtection{}
+		if req.Protection != nil {
+			protection = *req.Protection
+		}
+		if err := s.SetProtection(branch.ID, protection); err != nil {
+			return nil, err
+		}
+		return s.GetByID(branch.ID)
+	}
+
 	return branch, nil
 }
 
@@ -152,20 +167,33 @@ func (s *branchService) Update(id uuid.UUID, req *UpdateBranchRequest) (*models.
 		updates["commit_sha"] = *req.CommitSHA
 	}
 	
-	if req.IsProtected != nil {
-		updates["is_protected"] = *req.IsProtected
-	}
-	
-	if req.Protection != nil {
-		updates["protection"] = *req.Protection
-	}
-
 	updates["updated_at"] = time.Now()
 
 	if err := s.db.Model(branch).Updates(updates).Error; err != nil {
 		return nil, fmt.Errorf("更新分支失败: %w", err)
 	}
 
+	// 保护状态由仓库保护规则决定
+	switch {
+	case req.Protection != nil && (req.IsProtected == nil || *req.IsProtected):
+		if err := s.SetProtection(id, *req.Protection); err != nil {
+			return nil, err
+		}
+	case req.IsProtected != nil && *req.IsProtected:
+		if err := s.SetProtection(id, branch.Protection); err != nil {
+			return nil, err
+		}
+	case req.IsProtected != nil && !*req.IsProtected:
+		if err := s.RemoveProtection(id); err != nil {
+			return nil, err
+		}
+	case req.Name != nil:
+		// 重命名后可能命中不同的规则
+		if err := s.protectionRules.SyncBranches(branch.RepositoryID); err != nil {
+			return nil, err
+		}
+	}
+
 	return s.GetByID(id)
 }
 
@@ -181,6 +209,15 @@ func (s *branchService) Delete(id uuid.UUID) error {
 		return fmt.Errorf("不能删除默认分支")
 	}
 
+	// 校验保护规则是否允许删除
+	if err := s.protectionRules.CheckRefUpdate(branch.RepositoryID, &RefUpdate{
+		RefName: branchRefPrefix + branch.Name,
+		OldSHA:  branch.CommitSHA,
+		NewSHA:  ZeroCommitSHA,
+	}); err != nil {
+		return err
+	}
+
 	if err := s.db.Delete(branch).Error; err != nil {
 		return fmt.Errorf("删除分支失败: %w", err)
 	}
@@ -188,41 +225,53 @@ func (s *branchService) Delete(id uuid.UUID) error {
 	return nil
 }
 
-// SetProtection 设置分支保护
+// SetProtection 设置分支保护（创建或更新精确匹配该分支的保护规则）
 func (s *branchService) SetProtection(id uuid.UUID, protection models.BranchProtection) error {
 	branch, err := s.GetByID(id)
 	if err != nil {
 		return err
 	}
 
-	updates := map[string]interface{}{
-		"is_protected": true,
-		"protection":   protection,
-		"updated_at":   time.Now(),
+	rule, err := s.protectionRules.GetByPattern(branch.RepositoryID, RefTypeBranch, branch.Name)
+	if err != nil {
+		_, err = s.protectionRules.Create(&CreateProtectionRuleRequest{
+			RepositoryID: branch.RepositoryID,
+			RefType:      RefTypeBranch,
+			Pattern:      branch.Name,
+			Protection:   protection,
+		})
+	} else {
+		_, err = s.protectionRules.Update(rule.ID, &UpdateProtectionRuleRequest{
+			Protection: &protection,
+		})
 	}
-
-	if err := s.db.Model(branch).Updates(updates).Error; err != nil {
+	if err != nil {
 		return fmt.Errorf("设置分支保护失败: %w", err)
 	}
 
 	return nil
 }
 
-// RemoveProtection 移除分支保护
+// RemoveProtection 移除分支保护（删除精确匹配该分支的保护规则）
 func (s *branchService) RemoveProtection(id uuid.UUID) error {
 	branch, err := s.GetByID(id)
 	if err != nil {
 		return err
 	}
 
-	updates := map[string]interface{}{
-		"is_protected": false,
-		"protection":   models.BranchProtection{}, // 重置为默认值
-		"updated_at":   time.Now(),
+	if rule, err := s.protectionRules.GetByPattern(branch.RepositoryID, RefTypeBranch, branch.Name); err == nil {
+		if err := s.protectionRules.Delete(rule.ID); err != nil {
+			return fmt.Errorf("移除分支保护失败: %w", err)
+		}
 	}
 
-	if err := s.db.Model(branch).Updates(updates).Error; err != nil {
-		return fmt.Errorf("移除分支保护失败: %w", err)
+	// 仍被通配符规则命中时需修改对应规则
+	rule, err := s.protectionRules.Resolve(branch.RepositoryID, RefTypeBranch, branch.Name)
+	if err != nil {
+		return err
+	}
+	if rule != nil {
+		return fmt.Errorf("分支仍受保护规则 '%s' 保护，请修改对应的保护规则", rule.Pattern)
 	}
 
 	return nil
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"git-gateway-service/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// CICDClient CI/CD服务客户端接口
+type CICDClient interface {
+	TriggerPipelineRun(req *TriggerPipelineRunRequest) (*PipelineRunInfo, error)
+	GetPipelineRun(runID, userID uuid.UUID) (*PipelineRunInfo, error)
+	CancelPipelineRun(runID, userID uuid.UUID) error
+}
+
+type cicdClient struct {
+	config *config.Config
+	client *http.Client
+}
+
+// NewCICDClient 创建CI/CD服务客户端
+func NewCICDClient(cfg *config.Config) CICDClient {
+	return &cicdClient{
+		config: cfg,
+		client: &http.Client{
+			Timeout: time.Duration(cfg.CICD.Timeout) * time.Second,
+		},
+	}
+}
+
+// 流水线运行状态（与CI/CD服务保持一致）
+const (
+	PipelineRunPending   = "pending"
+	PipelineRunRunning   = "running"
+	PipelineRunSucceeded = "succeeded"
+	PipelineRunFailed    = "failed"
+	PipelineRunCancelled = "cancelled"
+	PipelineRunTimeout   = "timeout"
+)
+
+// TriggerPipelineRunRequest 触发流水线运行请求
+type TriggerPipelineRunRequest struct {
+	PipelineID  uuid.UUID              `json:"pipeline_id"`
+	UserID      uuid.UUID              `json:"-"` // 以该用户身份触发
+	TriggerData map[string]interface{} `json:"trigger_data"`
+}
+
+// PipelineRunInfo 流水线运行信息
+type PipelineRunInfo struct {
+	ID           uuid.UUID `json:"id"`
+	PipelineID   uuid.UUID `json:"pipeline_id"`
+	RunNumber    int       `json:"run_number"`
+	Status       string    `json:"status"`
+	ErrorMessage *string   `json:"error_message"`
+}
+
+// Finished 运行是否已结束
+func (r *PipelineRunInfo) Finished() bool {
+	switch r.Status {
+	case PipelineRunSucceeded, PipelineRunFailed, PipelineRunCancelled, PipelineRunTimeout:
+		return true
+	}
+	return false
+}
+
+// cicdResponse CI/CD服务统一响应格式
+type cicdResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Error   string          `json:"error"`
+}
+
+// TriggerPipelineRun 触发流水线运行
+func (c *cicdClient) TriggerPipelineRun(req *TriggerPipelineRunRequest) (*PipelineRunInfo, error) {
+	body := map[string]interface{}{
+		"pipeline_id":  req.PipelineID,
+		"trigger_type": "api",
+		"trigger_by":   req.UserID,
+		"trigger_data": req.TriggerData,
+	}
+
+	var run PipelineRunInfo
+	if err := c.do(http.MethodPost, "/api/v1/pipeline-runs", req.UserID, body, &run); err != nil {
+		return nil, fmt.Errorf("触发流水线失败: %w", err)
+	}
+	return &run, nil
+}
+
+// GetPipelineRun 获取流水线运行状态
+func (c *cicdClient) GetPipelineRun(runID, userID uuid.UUID) (*PipelineRunInfo, error) {
+	var run PipelineRunInfo
+	if err := c.do(http.MethodGet, "/api/v1/pipeline-runs/"+runID.String(), userID, nil, &run); err != nil {
+		return nil, fmt.Errorf("获取流水线运行失败: %w", err)
+	}
+	return &run, nil
+}
+
+// CancelPipelineRun 取消流水线运行
+func (c *cicdClient) CancelPipelineRun(runID, userID uuid.UUID) error {
+	if err := c.do(http.MethodPost, "/api/v1/pipeline-runs/"+runID.String()+"/cancel", userID, nil, nil); err != nil {
+		return fmt.Errorf("取消流水线运行失败: %w", err)
+	}
+	return nil
+}
+
+// do 以指定用户身份调用CI/CD服务接口并解析响应数据
+func (c *cicdClient) do(method, path string, userID uuid.UUID, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimRight(c.config.CICD.BaseURL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+
+	token, err := signServiceToken(c.config.JWT.Secret, userID)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Axiom-Git-Gateway")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result cicdResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return fmt.Errorf("解析响应失败 (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode >= 300 || !result.Success {
+		if result.Error != "" {
+			return fmt.Errorf("%s: %s", result.Message, result.Error)
+		}
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, result.Message)
+	}
+
+	if out == nil || len(result.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(result.Data, out)
+}
+
+// signServiceToken 签发调用其他服务的短期令牌（各服务共享JWT密钥）
+func signServiceToken(secret string, userID uuid.UUID) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userID.String(),
+		"role":    "service",
+		"iss":     "git-gateway-service",
+		"iat":     now.Unix(),
+		"exp":     now.Add(5 * time.Minute).Unix(),
+	})
+	signed, err := token.SignedString([]byte(secret))
+	if err != nil {
+		return "", fmt.Errorf("签发服务令牌失败: %w", err)
+	}
+	return signed, nil
+}
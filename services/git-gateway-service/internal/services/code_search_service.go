@@ -0,0 +1,730 @@
+package services
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"git-gateway-service/internal/config"
+	"git-gateway-service/internal/models"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CodeSearchService 代码搜索服务接口（默认分支三元组索引）
+type CodeSearchService interface {
+	Enqueue(repositoryID uuid.UUID)
+	IndexRepository(repositoryID uuid.UUID) error
+	Search(req *CodeSearchRequest) (*CodeSearchResult, error)
+}
+
+type codeSearchService struct {
+	db     *gorm.DB
+	config *config.Config
+	storage StorageService
+	repos   RepositoryService
+	queue   *repositoryQueue
+	mu      sync.RWMutex
+	cache   map[uuid.UUID]*codeIndex
+}
+
+// NewCodeSearchService 创建代码搜索服务实例，并启动后台索引协程
+func NewCodeSearchService(db *gorm.DB, cfg *config.Config, storage StorageService, repos RepositoryService) CodeSearchService {
+	s := &codeSearchService{
+		db:      db,
+		config:  cfg,
+		storage: storage,
+		repos:   repos,
+		cache:   make(map[uuid.UUID]*codeIndex),
+	}
+	s.queue = newRepositoryQueue("代码索引", codeIndexQueueSize, s.IndexRepository)
+	return s
+}
+
+const (
+	// codeIndexQueueSize 待索引仓库队列长度
+	codeIndexQueueSize = 256
+	// maxCachedCodeIndexes 内存中缓存的仓库索引数
+	maxCachedCodeIndexes = 64
+	// maxSearchMatchedFiles 单次搜索统计的最大匹配文件数
+	maxSearchMatchedFiles = 1000
+	// maxMatchesPerFile 每个文件返回的最大匹配行数
+	maxMatchesPerFile = 20
+	// maxSearchContext 匹配行前后最多返回的上下文行数
+	maxSearchContext = 5
+)
+
+// CodeSearchRequest 代码搜索请求
+type CodeSearchRequest struct {
+	Query         string     `json:"query" validate:"required"`
+	Regex         bool       `json:"regex"`
+	CaseSensitive bool       `json:"case_sensitive"`
+	Path          string     `json:"path"`     // 路径过滤，支持 * 与 ** 通配符，不含通配符时按子串匹配
+	Language      string     `json:"language"` // 语言过滤，如 Go、TypeScript
+	RepositoryID  *uuid.UUID `json:"repository_id"`
+	ProjectID     *uuid.UUID `json:"project_id"`
+	Context       int        `json:"context"` // 匹配行前后的上下文行数
+	Page          int        `json:"page"`
+	Limit         int        `json:"limit"`
+
+	// 调用者身份，用于仓库权限过滤
+	UserID uuid.UUID `json:"-"`
+}
+
+// CodeSearchResult 代码搜索结果
+type CodeSearchResult struct {
+	Total     int              `json:"total"`     // 匹配的文件数
+	Truncated bool             `json:"truncated"` // 匹配文件过多，统计已截断
+	Files     []CodeSearchFile `json:"files"`
+}
+
+// CodeSearchFile 匹配的文件
+type CodeSearchFile struct {
+	RepositoryID uuid.UUID         `json:"repository_id"`
+	Repository   string            `json:"repository"`
+	CommitSHA    string            `json:"commit_sha"`
+	Path         string            `json:"path"`
+	Language     string            `json:"language,omitempty"`
+	MatchCount   int               `json:"match_count"`
+	Matches      []CodeSearchMatch `json:"matches"`
+}
+
+// CodeSearchMatch 匹配行及上下文
+type CodeSearchMatch struct {
+	LineNumber int      `json:"line_number"`
+	Line       string   `json:"line"`
+	Ranges     [][2]int `json:"ranges"` // 行内匹配的字节区间
+	Before     []string `json:"before,omitempty"`
+	After      []string `json:"after,omitempty"`
+}
+
+// codeIndex 仓库默认分支的三元组索引（以gob格式存储在磁盘上）
+type codeIndex struct {
+	RepositoryID uuid.UUID
+	CommitSHA    string
+	Files        []indexedFile
+	Blobs        map[string][]uint32 // 文件对象哈希 -> 排序后的三元组（ASCII小写）
+	Skipped      map[string]bool     // 二进制文件对象，增量索引时无需重新读取
+
+	postings map[uint32][]int // 三元组 -> 文件下标（加载后构建）
+}
+
+// indexedFile 已索引文件
+type indexedFile struct {
+	Path     string
+	Blob     string
+	Language string
+	Size     int64
+}
+
+// Enqueue 将仓库加入后台索引队列
+func (s *codeSearchService) Enqueue(repositoryID uuid.UUID) {
+	s.queue.Enqueue(repositoryID)
+}
+
+// IndexRepository 增量索引仓库默认分支，未变化的文件对象复用已有三元组
+func (s *codeSearchService) IndexRepository(repositoryID uuid.UUID) error {
+	var repo models.Repository
+	if err := s.db.Where("id = ? AND deleted_at IS NULL", repositoryID).First(&repo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("仓库不存在")
+		}
+		return fmt.Errorf("获取仓库失败: %w", err)
+	}
+
+	gitRepo, release, err := s.storage.Open(&repo)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	ref, err := gitRepo.Reference(plumbing.NewBranchReferenceName(repo.DefaultBranch), true)
+	if err != nil {
+		// 空仓库无需索引
+		return nil
+	}
+
+	old, err := s.loadIndex(repo.ID)
+	if err != nil {
+		return err
+	}
+	if old != nil && old.CommitSHA == ref.Hash().String() {
+		return nil
+	}
+
+	commit, err := gitRepo.CommitObject(ref.Hash())
+	if err != nil {
+		return fmt.Errorf("读取提交失败: %w", err)
+	}
+	idx, err := buildCodeIndex(repo.ID, commit, old, s.config.Search.MaxFileSize*1024)
+	if err != nil {
+		return err
+	}
+
+	if err := s.writeIndex(idx); err != nil {
+		return err
+	}
+
+	idx.buildPostings()
+	s.cacheIndex(idx)
+	return nil
+}
+
+// buildCodeIndex 为提交构建索引，复用旧索引中未变化文件对象的三元组
+func buildCodeIndex(repositoryID uuid.UUID, commit *object.Commit, old *codeIndex, maxSize int64) (*codeIndex, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("读取目录失败: %w", err)
+	}
+
+	idx := &codeIndex{
+		RepositoryID: repositoryID,
+		CommitSHA:    commit.Hash.String(),
+		Blobs:        make(map[string][]uint32),
+		Skipped:      make(map[string]bool),
+	}
+
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if !f.Mode.IsFile() || f.Mode == filemode.Symlink || f.Size > maxSize {
+			return nil
+		}
+
+		blob := f.Hash.String()
+		if !idx.reuse(blob, old) {
+			trigrams, binary, err := fileTrigrams(f)
+			if err != nil {
+				return err
+			}
+			if binary {
+				idx.Skipped[blob] = true
+			} else {
+				idx.Blobs[blob] = trigrams
+			}
+		}
+		if idx.Skipped[blob] {
+			return nil
+		}
+
+		idx.Files = append(idx.Files, indexedFile{
+			Path:     f.Name,
+			Blob:     blob,
+			Language: detectLanguage(f.Name),
+			Size:     f.Size,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("索引文件失败: %w", err)
+	}
+
+	return idx, nil
+}
+
+// Search 在调用者有权访问的仓库中搜索代码
+func (s *codeSearchService) Search(req *CodeSearchRequest) (*CodeSearchResult, error) {
+	if strings.TrimSpace(req.Query) == "" {
+		return nil, fmt.Errorf("搜索内容不能为空")
+	}
+
+	matcher, trigrams, err := compileCodeQuery(req.Query, req.Regex, req.CaseSensitive)
+	if err != nil {
+		return nil, err
+	}
+
+	pathFilter, err := compilePathFilter(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	contextLines := req.Context
+	if contextLines < 0 {
+		contextLines = 0
+	}
+	if contextLines > maxSearchContext {
+		contextLines = maxSearchContext
+	}
+	page, limit := req.Page, req.Limit
+	if page <= 0 {
+		page = 1
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	repos, err := s.accessibleRepositories(req)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CodeSearchResult{Files: []CodeSearchFile{}}
+	for i := range repos {
+		repo := &repos[i]
+		idx, err := s.loadIndex(repo.ID)
+		if err != nil || idx == nil {
+			continue
+		}
+
+		// 每个仓库在读取第一个候选文件时获取存储使用锁，处理完该仓库即释放
+		truncated := func() bool {
+			var gitRepo *git.Repository
+			for _, fileIndex := range idx.candidates(trigrams) {
+				file := idx.Files[fileIndex]
+				if req.Language != "" && !strings.EqualFold(file.Language, req.Language) {
+					continue
+				}
+				if pathFilter != nil && !pathFilter(file.Path) {
+					continue
+				}
+
+				if gitRepo == nil {
+					var release func()
+					if gitRepo, release, err = s.storage.Open(repo); err != nil {
+						return false
+					}
+					defer release()
+				}
+
+				content, err := readBlob(gitRepo, file.Blob)
+				if err != nil {
+					continue
+				}
+
+				matches, count := findCodeMatches(content, matcher, contextLines)
+				if count == 0 {
+					continue
+				}
+
+				result.Total++
+				if result.Total > offset && len(result.Files) < limit {
+					result.Files = append(result.Files, CodeSearchFile{
+						RepositoryID: repo.ID,
+						Repository:   repo.Name,
+						CommitSHA:    idx.CommitSHA,
+						Path:         file.Path,
+						Language:     file.Language,
+						MatchCount:   count,
+						Matches:      matches,
+					})
+				}
+				if result.Total >= maxSearchMatchedFiles {
+					result.Truncated = true
+					return true
+				}
+			}
+			return false
+		}()
+		if truncated {
+			return result, nil
+		}
+	}
+
+	return result, nil
+}
+
+// accessibleRepositories 搜索范围内调用者具有读取权限的仓库，权限按仓库访问级别统一计算
+func (s *codeSearchService) accessibleRepositories(req *CodeSearchRequest) ([]models.Repository, error) {
+	query := s.db.Model(&models.Repository{}).Where("deleted_at IS NULL")
+	if req.RepositoryID != nil {
+		query = query.Where("id = ?", *req.RepositoryID)
+	}
+	if req.ProjectID != nil {
+		query = query.Where("project_id = ?", *req.ProjectID)
+	}
+
+	var repos []models.Repository
+	if err := query.Order("name ASC").Find(&repos).Error; err != nil {
+		return nil, fmt.Errorf("查询仓库失败: %w", err)
+	}
+
+	accessible := repos[:0]
+	for i := range repos {
+		access, err := s.repos.AccessLevel(&repos[i], req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if access >= RepositoryAccessRead {
+			accessible = append(accessible, repos[i])
+		}
+	}
+	return accessible, nil
+}
+
+// indexPath 仓库索引文件路径
+func (s *codeSearchService) indexPath(repositoryID uuid.UUID) string {
+	return filepath.Join(s.config.Search.IndexRoot, repositoryID.String()+".idx")
+}
+
+// loadIndex 读取仓库索引，优先使用内存缓存；未索引时返回nil
+func (s *codeSearchService) loadIndex(repositoryID uuid.UUID) (*codeIndex, error) {
+	s.mu.RLock()
+	idx, ok := s.cache[repositoryID]
+	s.mu.RUnlock()
+	if ok {
+		return idx, nil
+	}
+
+	file, err := os.Open(s.indexPath(repositoryID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取代码索引失败: %w", err)
+	}
+	defer file.Close()
+
+	idx = &codeIndex{}
+	if err := gob.NewDecoder(file).Decode(idx); err != nil {
+		// 索引损坏时按未索引处理，下次推送会重建
+		return nil, nil
+	}
+
+	idx.buildPostings()
+	s.cacheIndex(idx)
+	return idx, nil
+}
+
+// writeIndex 原子写入索引文件
+func (s *codeSearchService) writeIndex(idx *codeIndex) error {
+	if err := os.MkdirAll(s.config.Search.IndexRoot, 0755); err != nil {
+		return fmt.Errorf("创建索引目录失败: %w", err)
+	}
+
+	target := s.indexPath(idx.RepositoryID)
+	tmp, err := os.CreateTemp(s.config.Search.IndexRoot, ".idx-*")
+	if err != nil {
+		return fmt.Errorf("写入代码索引失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(idx); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入代码索引失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入代码索引失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("写入代码索引失败: %w", err)
+	}
+	return nil
+}
+
+// cacheIndex 缓存索引，超出容量时淘汰任意一个
+func (s *codeSearchService) cacheIndex(idx *codeIndex) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.cache[idx.RepositoryID]; !ok && len(s.cache) >= maxCachedCodeIndexes {
+		for id := range s.cache {
+			delete(s.cache, id)
+			break
+		}
+	}
+	s.cache[idx.RepositoryID] = idx
+}
+
+// reuse 从旧索引复用文件对象的三元组，返回是否已有结果
+func (idx *codeIndex) reuse(blob string, old *codeIndex) bool {
+	if _, ok := idx.Blobs[blob]; ok || idx.Skipped[blob] {
+		return true
+	}
+	if old == nil {
+		return false
+	}
+	if trigrams, ok := old.Blobs[blob]; ok {
+		idx.Blobs[blob] = trigrams
+		return true
+	}
+	if old.Skipped[blob] {
+		idx.Skipped[blob] = true
+		return true
+	}
+	return false
+}
+
+// buildPostings 构建三元组倒排表
+func (idx *codeIndex) buildPostings() {
+	idx.postings = make(map[uint32][]int)
+	for i, file := range idx.Files {
+		for _, trigram := range idx.Blobs[file.Blob] {
+			idx.postings[trigram] = append(idx.postings[trigram], i)
+		}
+	}
+}
+
+// candidates 返回包含全部三元组的文件下标，无三元组时返回全部文件
+func (idx *codeIndex) candidates(trigrams []uint32) []int {
+	if len(trigrams) == 0 {
+		all := make([]int, len(idx.Files))
+		for i := range all {
+			all[i] = i
+		}
+		return all
+	}
+
+	lists := make([][]int, 0, len(trigrams))
+	for _, trigram := range trigrams {
+		list := idx.postings[trigram]
+		if len(list) == 0 {
+			return nil
+		}
+		lists = append(lists, list)
+	}
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+
+	result := lists[0]
+	for _, list := range lists[1:] {
+		result = intersectSorted(result, list)
+		if len(result) == 0 {
+			return nil
+		}
+	}
+	return result
+}
+
+// intersectSorted 求两个有序列表的交集
+func intersectSorted(a, b []int) []int {
+	var out []int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, a[i])
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return out
+}
+
+// fileTrigrams 计算文件内容的三元组集合，二进制文件返回binary=true
+func fileTrigrams(f *object.File) ([]uint32, bool, error) {
+	reader, err := f.Reader()
+	if err != nil {
+		return nil, false, err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, false, err
+	}
+	if bytes.IndexByte(content, 0) >= 0 {
+		return nil, true, nil
+	}
+
+	return textTrigrams(content), false, nil
+}
+
+// textTrigrams 计算文本的三元组（按ASCII小写），结果已排序去重
+func textTrigrams(content []byte) []uint32 {
+	if len(content) < 3 {
+		return nil
+	}
+
+	set := make(map[uint32]struct{})
+	for i := 0; i+3 <= len(content); i++ {
+		set[trigramAt(content, i)] = struct{}{}
+	}
+
+	trigrams := make([]uint32, 0, len(set))
+	for trigram := range set {
+		trigrams = append(trigrams, trigram)
+	}
+	sort.Slice(trigrams, func(i, j int) bool { return trigrams[i] < trigrams[j] })
+	return trigrams
+}
+
+// trigramAt 取位置i起的三字节三元组
+func trigramAt(b []byte, i int) uint32 {
+	return uint32(lowerASCII(b[i]))<<16 | uint32(lowerASCII(b[i+1]))<<8 | uint32(lowerASCII(b[i+2]))
+}
+
+func lowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// compileCodeQuery 编译搜索表达式，并提取用于索引过滤的三元组
+func compileCodeQuery(query string, isRegex, caseSensitive bool) (*regexp.Regexp, []uint32, error) {
+	pattern := query
+	literals := []string{query}
+	if isRegex {
+		parsed, err := syntax.Parse(query, syntax.Perl)
+		if err != nil {
+			return nil, nil, fmt.Errorf("无效的正则表达式: %w", err)
+		}
+		literals = requiredLiterals(parsed.Simplify())
+	} else {
+		pattern = regexp.QuoteMeta(query)
+	}
+	if !caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+
+	matcher, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, nil, fmt.Errorf("无效的正则表达式: %w", err)
+	}
+
+	set := make(map[uint32]struct{})
+	for _, literal := range literals {
+		// 非ASCII字符的大小写折叠无法通过ASCII小写三元组表达
+		if (!caseSensitive || isRegex) && !isASCII(literal) {
+			continue
+		}
+		for i := 0; i+3 <= len(literal); i++ {
+			set[trigramAt([]byte(literal), i)] = struct{}{}
+		}
+	}
+
+	trigrams := make([]uint32, 0, len(set))
+	for trigram := range set {
+		trigrams = append(trigrams, trigram)
+	}
+	return matcher, trigrams, nil
+}
+
+// requiredLiterals 提取正则表达式匹配时必然出现的字面量
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var literals []string
+		var current []rune
+		flush := func() {
+			if len(current) > 0 {
+				literals = append(literals, string(current))
+				current = nil
+			}
+		}
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpLiteral {
+				current = append(current, sub.Rune...)
+				continue
+			}
+			flush()
+			literals = append(literals, requiredLiterals(sub)...)
+		}
+		flush()
+		return literals
+	}
+	return nil
+}
+
+// compilePathFilter 编译路径过滤条件
+func compilePathFilter(pattern string) (func(string) bool, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	if !strings.ContainsAny(pattern, "*?") {
+		return func(p string) bool { return strings.Contains(p, pattern) }, nil
+	}
+
+	re, err := compileRefPattern(strings.TrimPrefix(pattern, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("无效的路径过滤: %w", err)
+	}
+	basename := !strings.Contains(pattern, "/")
+	return func(p string) bool {
+		if basename {
+			return re.MatchString(path.Base(p))
+		}
+		return re.MatchString(p)
+	}, nil
+}
+
+// readBlob 读取文件对象内容
+func readBlob(gitRepo *git.Repository, hash string) ([]byte, error) {
+	blob, err := gitRepo.BlobObject(plumbing.NewHash(hash))
+	if err != nil {
+		return nil, err
+	}
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// findCodeMatches 按行匹配并附带上下文，返回前maxMatchesPerFile条匹配与匹配行总数
+func findCodeMatches(content []byte, matcher *regexp.Regexp, contextLines int) ([]CodeSearchMatch, int) {
+	lines := strings.Split(string(content), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+
+	var matches []CodeSearchMatch
+	count := 0
+	for i, line := range lines {
+		locs := matcher.FindAllStringIndex(line, -1)
+		if len(locs) == 0 {
+			continue
+		}
+		count++
+		if len(matches) >= maxMatchesPerFile {
+			continue
+		}
+
+		match := CodeSearchMatch{
+			LineNumber: i + 1,
+			Line:       line,
+		}
+		for _, loc := range locs {
+			match.Ranges = append(match.Ranges, [2]int{loc[0], loc[1]})
+		}
+		if contextLines > 0 {
+			start := i - contextLines
+			if start < 0 {
+				start = 0
+			}
+			end := i + 1 + contextLines
+			if end > len(lines) {
+				end = len(lines)
+			}
+			match.Before = lines[start:i]
+			match.After = lines[i+1 : end]
+		}
+		matches = append(matches, match)
+	}
+	return matches, count
+}
+
+// isASCII 判断字符串是否只包含ASCII字符
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
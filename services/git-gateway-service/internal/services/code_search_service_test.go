@@ -0,0 +1,111 @@
+package services
+
+import (
+	"reflect"
+	"regexp/syntax"
+	"testing"
+)
+
+func TestRequiredLiterals(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{`hello`, []string{"hello"}},
+		{`func\s+main`, []string{"func", "main"}},
+		{`(handler)Func`, []string{"handler", "Func"}},
+		{`(foo)+bar`, []string{"foo", "bar"}},
+		{`(abc){2,3}`, []string{"abc", "abc"}},
+		{`(abc){0,3}xyz`, []string{"xyz"}},
+		{`abc?d`, []string{"ab", "d"}},
+		{`foo|bar`, nil},
+		{`(foo|bar)baz`, []string{"baz"}},
+		{`.*`, nil},
+		{`[a-z]+_test`, []string{"_test"}},
+		{`^import\b`, []string{"import"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			parsed, err := syntax.Parse(tt.pattern, syntax.Perl)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			got := requiredLiterals(parsed.Simplify())
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("requiredLiterals(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileCodeQueryTrigrams(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		isRegex       bool
+		caseSensitive bool
+		wantTrigrams  int
+	}{
+		{"plain literal", "ServeGit", false, false, 6},
+		{"short literal has no trigram", "go", false, false, 0},
+		{"repeated trigrams deduplicated", "aaaa", false, false, 1},
+		{"case folded to one set", "AbcABC", false, false, 3},
+		{"regex alternation disables index", "foo|bar", true, false, 0},
+		{"regex concat literals", `func\s+main`, true, false, 4},
+		{"non-ascii literal skipped when case-insensitive", "日志记录", false, false, 0},
+		{"non-ascii literal kept when case-sensitive", "日志", false, true, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, trigrams, err := compileCodeQuery(tt.query, tt.isRegex, tt.caseSensitive)
+			if err != nil {
+				t.Fatalf("compileCodeQuery: %v", err)
+			}
+			if len(trigrams) != tt.wantTrigrams {
+				t.Fatalf("got %d trigrams, want %d", len(trigrams), tt.wantTrigrams)
+			}
+		})
+	}
+}
+
+func TestQueryTrigramsSubsetOfContent(t *testing.T) {
+	content := []byte("package main\n\nfunc main() {\n\tServeGit()\n}\n")
+	indexed := make(map[uint32]bool)
+	for _, trigram := range textTrigrams(content) {
+		indexed[trigram] = true
+	}
+
+	for _, query := range []string{"servegit", `func\s+main`, "PACKAGE"} {
+		matcher, trigrams, err := compileCodeQuery(query, query == `func\s+main`, false)
+		if err != nil {
+			t.Fatalf("compileCodeQuery(%q): %v", query, err)
+		}
+		if !matcher.Match(content) {
+			t.Fatalf("query %q should match content", query)
+		}
+		for _, trigram := range trigrams {
+			if !indexed[trigram] {
+				t.Fatalf("query %q requires trigram %06x missing from matching content", query, trigram)
+			}
+		}
+	}
+}
+
+func TestIntersectSorted(t *testing.T) {
+	tests := []struct {
+		a, b []int
+		want []int
+	}{
+		{[]int{1, 3, 5, 7}, []int{3, 4, 5, 8}, []int{3, 5}},
+		{[]int{1, 2}, []int{3, 4}, nil},
+		{nil, []int{1}, nil},
+	}
+
+	for _, tt := range tests {
+		if got := intersectSorted(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("intersectSorted(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
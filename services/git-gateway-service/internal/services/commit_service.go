@@ -0,0 +1,251 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"git-gateway-service/internal/config"
+	"git-gateway-service/internal/models"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CommitService 提交查询服务接口
+type CommitService interface {
+	List(repositoryID uuid.UUID, req *ListCommitsRequest) ([]CommitInfo, error)
+	Get(repositoryID uuid.UUID, sha string) (*CommitInfo, error)
+	GetFile(repositoryID uuid.UUID, ref, path string) (*FileContent, error)
+}
+
+type commitService struct {
+	db         *gorm.DB
+	config     *config.Config
+	signingKey SigningKeyService
+	storage    StorageService
+}
+
+// NewCommitService 创建提交查询服务实例
+func NewCommitService(db *gorm.DB, cfg *config.Config, signingKey SigningKeyService, storage StorageService) CommitService {
+	return &commitService{
+		db:         db,
+		config:     cfg,
+		signingKey: signingKey,
+		storage:    storage,
+	}
+}
+
+// ListCommitsRequest 提交列表查询请求
+type ListCommitsRequest struct {
+	Ref   string `json:"ref"` // 分支、标签或提交SHA，默认为仓库默认分支
+	Page  int    `json:"page"`
+	Limit int    `json:"limit"`
+}
+
+// CommitSignature 提交中的作者/提交者信息
+type CommitSignature struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+// CommitInfo 提交信息
+type CommitInfo struct {
+	SHA          string                 `json:"sha"`
+	Message      string                 `json:"message"`
+	Author       CommitSignature        `json:"author"`
+	Committer    CommitSignature        `json:"committer"`
+	Parents      []string               `json:"parents"`
+	Verification *SignatureVerification `json:"verification"`
+}
+
+// MaxFileContentSize 单个文件读取上限（流水线定义等配置文件）
+const MaxFileContentSize = 1 << 20
+
+// FileContent 指定提交中的文件内容（Base64编码）
+type FileContent struct {
+	CommitSHA string `json:"commit_sha"`
+	Path      string `json:"path"`
+	BlobSHA   string `json:"blob_sha"`
+	Size      int64  `json:"size"`
+	Encoding  string `json:"encoding"`
+	Content   string `json:"content"`
+}
+
+// List 获取提交历史（附带签名验证结果）
+func (s *commitService) List(repositoryID uuid.UUID, req *ListCommitsRequest) ([]CommitInfo, error) {
+	repo, gitRepo, release, err := s.openRepository(repositoryID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ref := req.Ref
+	if ref == "" {
+		ref = repo.DefaultBranch
+	}
+
+	hash, err := gitRepo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, fmt.Errorf("引用不存在: %s", ref)
+	}
+
+	page, limit := req.Page, req.Limit
+	if page <= 0 {
+		page = 1
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	iter, err := gitRepo.Log(&git.LogOptions{From: *hash})
+	if err != nil {
+		return nil, fmt.Errorf("读取提交历史失败: %w", err)
+	}
+	defer iter.Close()
+
+	commits := make([]CommitInfo, 0, limit)
+	index := 0
+	err = iter.ForEach(func(c *object.Commit) error {
+		if index < offset {
+			index++
+			return nil
+		}
+		if len(commits) >= limit {
+			return storer.ErrStop
+		}
+		commits = append(commits, s.newCommitInfo(c))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取提交历史失败: %w", err)
+	}
+
+	return commits, nil
+}
+
+// Get 获取单个提交（附带签名验证结果）
+func (s *commitService) Get(repositoryID uuid.UUID, sha string) (*CommitInfo, error) {
+	_, gitRepo, release, err := s.openRepository(repositoryID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	hash, err := gitRepo.ResolveRevision(plumbing.Revision(sha))
+	if err != nil {
+		return nil, fmt.Errorf("提交不存在")
+	}
+
+	commit, err := gitRepo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("提交不存在")
+	}
+
+	info := s.newCommitInfo(commit)
+	return &info, nil
+}
+
+// GetFile 读取指定引用（分支、标签或提交SHA）下的文件内容
+func (s *commitService) GetFile(repositoryID uuid.UUID, ref, path string) (*FileContent, error) {
+	repo, gitRepo, release, err := s.openRepository(repositoryID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if ref == "" {
+		ref = repo.DefaultBranch
+	}
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil, fmt.Errorf("文件路径不能为空")
+	}
+
+	hash, err := gitRepo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, fmt.Errorf("引用不存在: %s", ref)
+	}
+	commit, err := gitRepo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("提交不存在")
+	}
+
+	file, err := commit.File(path)
+	if err != nil {
+		return nil, fmt.Errorf("文件不存在: %s", path)
+	}
+	if file.Size > MaxFileContentSize {
+		return nil, fmt.Errorf("文件过大: %d 字节", file.Size)
+	}
+
+	reader, err := file.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+
+	return &FileContent{
+		CommitSHA: commit.Hash.String(),
+		Path:      path,
+		BlobSHA:   file.Hash.String(),
+		Size:      file.Size,
+		Encoding:  "base64",
+		Content:   base64.StdEncoding.EncodeToString(data),
+	}, nil
+}
+
+// openRepository 加载仓库记录，获取存储使用锁后打开磁盘上的Git仓库
+func (s *commitService) openRepository(repositoryID uuid.UUID) (*models.Repository, *git.Repository, func(), error) {
+	var repo models.Repository
+	if err := s.db.Where("id = ? AND deleted_at IS NULL", repositoryID).First(&repo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, nil, fmt.Errorf("仓库不存在")
+		}
+		return nil, nil, nil, fmt.Errorf("获取仓库失败: %w", err)
+	}
+
+	gitRepo, release, err := s.storage.Open(&repo)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return &repo, gitRepo, release, nil
+}
+
+// newCommitInfo 转换提交对象并验证签名
+func (s *commitService) newCommitInfo(c *object.Commit) CommitInfo {
+	parents := make([]string, 0, len(c.ParentHashes))
+	for _, parent := range c.ParentHashes {
+		parents = append(parents, parent.String())
+	}
+
+	return CommitInfo{
+		SHA:     c.Hash.String(),
+		Message: c.Message,
+		Author: CommitSignature{
+			Name:  c.Author.Name,
+			Email: c.Author.Email,
+			Date:  c.Author.When,
+		},
+		Committer: CommitSignature{
+			Name:  c.Committer.Name,
+			Email: c.Committer.Email,
+			Date:  c.Committer.When,
+		},
+		Parents:      parents,
+		Verification: s.signingKey.VerifyCommit(c),
+	}
+}
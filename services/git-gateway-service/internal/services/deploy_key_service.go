@@ -0,0 +1,452 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"git-gateway-service/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Git认证方式（记录在操作审计中）
+const (
+	AuthMethodJWT         = "jwt"
+	AuthMethodAccessKey   = "access_key"
+	AuthMethodDeployKey   = "deploy_key"
+	AuthMethodDeployToken = "deploy_token"
+)
+
+// 部署凭据权限范围
+const (
+	DeployScopeRead  = "read"
+	DeployScopeWrite = "write"
+)
+
+// DeployTokenPrefix 部署令牌前缀，Git HTTP认证据此区分部署令牌与用户JWT
+const DeployTokenPrefix = "axdt_"
+
+// deployTokenHintLength 列表中展示的令牌前缀长度
+const deployTokenHintLength = 12
+
+var (
+	errDeployCredentialExpired    = errors.New("部署凭据已过期")
+	errDeployCredentialRepository = errors.New("部署凭据不能访问该仓库")
+	errDeployCredentialReadOnly   = errors.New("部署凭据为只读权限")
+)
+
+// DeployKeyService 部署密钥与部署令牌服务接口：仓库级凭据，不属于任何用户
+type DeployKeyService interface {
+	CreateKey(req *CreateDeployKeyRequest) (*models.DeployKey, error)
+	ListKeys(repositoryID uuid.UUID) ([]models.DeployKey, error)
+	DeleteKey(repositoryID, id uuid.UUID, actor *CredentialActor) error
+	CreateToken(req *CreateDeployTokenRequest) (*CreatedDeployToken, error)
+	ListTokens(repositoryID uuid.UUID) ([]models.DeployToken, error)
+	DeleteToken(repositoryID, id uuid.UUID, actor *CredentialActor) error
+	FindKey(fingerprint string) (*DeployCredential, error)
+	AuthenticateToken(token string) (*DeployCredential, error)
+	Authorize(credential *DeployCredential, repositoryID uuid.UUID, write bool, clientIP string) error
+}
+
+type deployKeyService struct {
+	db         *gorm.DB
+	accessKeys AccessKeyService
+}
+
+// NewDeployKeyService 创建部署密钥服务实例
+func NewDeployKeyService(db *gorm.DB, accessKeys AccessKeyService) DeployKeyService {
+	return &deployKeyService{db: db, accessKeys: accessKeys}
+}
+
+// CredentialActor 管理部署凭据的操作者，记录在审计日志中
+type CredentialActor struct {
+	UserID   uuid.UUID
+	ClientIP string
+}
+
+// CreateDeployKeyRequest 创建部署密钥请求
+type CreateDeployKeyRequest struct {
+	RepositoryID uuid.UUID  `json:"-"`
+	CreatedBy    uuid.UUID  `json:"-"`
+	ClientIP     string     `json:"-"`
+	Title        string     `json:"title" binding:"required,max=255"`
+	PublicKey    string     `json:"public_key" binding:"required"`
+	Scope        string     `json:"scope" binding:"omitempty,oneof=read write"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+}
+
+// CreateDeployTokenRequest 创建部署令牌请求
+type CreateDeployTokenRequest struct {
+	RepositoryID uuid.UUID  `json:"-"`
+	CreatedBy    uuid.UUID  `json:"-"`
+	ClientIP     string     `json:"-"`
+	Title        string     `json:"title" binding:"required,max=255"`
+	Scope        string     `json:"scope" binding:"omitempty,oneof=read write"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+}
+
+// CreatedDeployToken 新建的部署令牌，明文令牌仅在创建时返回一次
+type CreatedDeployToken struct {
+	models.DeployToken
+	Token string `json:"token"`
+}
+
+// DeployCredential 通过认证的部署密钥或部署令牌
+type DeployCredential struct {
+	ID           uuid.UUID
+	Method       string // deploy_key, deploy_token
+	RepositoryID uuid.UUID
+	Title        string
+	Scope        string
+}
+
+// CreateKey 为仓库添加部署密钥
+func (s *deployKeyService) CreateKey(req *CreateDeployKeyRequest) (*models.DeployKey, error) {
+	if err := s.checkRepository(req.RepositoryID); err != nil {
+		return nil, err
+	}
+	if err := checkExpiry(req.ExpiresAt); err != nil {
+		return nil, err
+	}
+
+	publicKey := strings.TrimSpace(req.PublicKey)
+	keyInfo, err := s.accessKeys.ValidatePublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("公钥格式无效: %w", err)
+	}
+
+	key := &models.DeployKey{
+		RepositoryID: req.RepositoryID,
+		Title:        req.Title,
+		PublicKey:    publicKey,
+		Fingerprint:  keyInfo.Fingerprint,
+		KeyType:      keyInfo.KeyType,
+		Scope:        deployScope(req.Scope),
+		ExpiresAt:    req.ExpiresAt,
+		CreatedBy:    req.CreatedBy,
+	}
+
+	// SSH认证仅凭公钥识别身份，同一公钥不能同时作为用户密钥和部署密钥；
+	// 两张表的检查与写入在同一指纹锁内完成，避免并发创建绕过检查
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockKeyFingerprint(tx, keyInfo.Fingerprint); err != nil {
+			return err
+		}
+		if err := checkFingerprintUnused(tx, keyInfo.Fingerprint); err != nil {
+			return err
+		}
+		if err := tx.Create(key).Error; err != nil {
+			return fmt.Errorf("创建部署密钥失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordAudit(key.RepositoryID, &CredentialActor{UserID: req.CreatedBy, ClientIP: req.ClientIP},
+		"deploy_key.created", "deploy_key", key.ID, map[string]interface{}{
+			"title":       key.Title,
+			"fingerprint": key.Fingerprint,
+			"scope":       key.Scope,
+		})
+	return key, nil
+}
+
+// ListKeys 获取仓库的部署密钥
+func (s *deployKeyService) ListKeys(repositoryID uuid.UUID) ([]models.DeployKey, error) {
+	var keys []models.DeployKey
+	if err := s.db.Where("repository_id = ?", repositoryID).
+		Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("获取部署密钥失败: %w", err)
+	}
+	return keys, nil
+}
+
+// DeleteKey 删除部署密钥，删除后立即失效
+func (s *deployKeyService) DeleteKey(repositoryID, id uuid.UUID, actor *CredentialActor) error {
+	var key models.DeployKey
+	if err := s.db.Where("id = ? AND repository_id = ?", id, repositoryID).First(&key).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("部署密钥不存在")
+		}
+		return fmt.Errorf("获取部署密钥失败: %w", err)
+	}
+
+	result := s.db.Where("id = ?", key.ID).Delete(&models.DeployKey{})
+	if result.Error != nil {
+		return fmt.Errorf("删除部署密钥失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("部署密钥不存在")
+	}
+
+	s.recordAudit(repositoryID, actor, "deploy_key.deleted", "deploy_key", key.ID, map[string]interface{}{
+		"title":       key.Title,
+		"fingerprint": key.Fingerprint,
+		"scope":       key.Scope,
+	})
+	return nil
+}
+
+// CreateToken 为仓库生成部署令牌，只保存哈希
+func (s *deployKeyService) CreateToken(req *CreateDeployTokenRequest) (*CreatedDeployToken, error) {
+	if err := s.checkRepository(req.RepositoryID); err != nil {
+		return nil, err
+	}
+	if err := checkExpiry(req.ExpiresAt); err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("生成部署令牌失败: %w", err)
+	}
+	token := DeployTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	deployToken := models.DeployToken{
+		RepositoryID: req.RepositoryID,
+		Title:        req.Title,
+		TokenHash:    hashDeployToken(token),
+		TokenHint:    token[:deployTokenHintLength],
+		Scope:        deployScope(req.Scope),
+		ExpiresAt:    req.ExpiresAt,
+		CreatedBy:    req.CreatedBy,
+	}
+	if err := s.db.Create(&deployToken).Error; err != nil {
+		return nil, fmt.Errorf("创建部署令牌失败: %w", err)
+	}
+
+	s.recordAudit(deployToken.RepositoryID, &CredentialActor{UserID: req.CreatedBy, ClientIP: req.ClientIP},
+		"deploy_token.created", "deploy_token", deployToken.ID, map[string]interface{}{
+			"title":      deployToken.Title,
+			"token_hint": deployToken.TokenHint,
+			"scope":      deployToken.Scope,
+		})
+	return &CreatedDeployToken{DeployToken: deployToken, Token: token}, nil
+}
+
+// ListTokens 获取仓库的部署令牌（不含明文）
+func (s *deployKeyService) ListTokens(repositoryID uuid.UUID) ([]models.DeployToken, error) {
+	var tokens []models.DeployToken
+	if err := s.db.Where("repository_id = ?", repositoryID).
+		Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("获取部署令牌失败: %w", err)
+	}
+	return tokens, nil
+}
+
+// DeleteToken 吊销部署令牌
+func (s *deployKeyService) DeleteToken(repositoryID, id uuid.UUID, actor *CredentialActor) error {
+	var deployToken models.DeployToken
+	if err := s.db.Where("id = ? AND repository_id = ?", id, repositoryID).First(&deployToken).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("部署令牌不存在")
+		}
+		return fmt.Errorf("获取部署令牌失败: %w", err)
+	}
+
+	result := s.db.Where("id = ?", deployToken.ID).Delete(&models.DeployToken{})
+	if result.Error != nil {
+		return fmt.Errorf("删除部署令牌失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("部署令牌不存在")
+	}
+
+	s.recordAudit(repositoryID, actor, "deploy_token.deleted", "deploy_token", deployToken.ID, map[string]interface{}{
+		"title":      deployToken.Title,
+		"token_hint": deployToken.TokenHint,
+		"scope":      deployToken.Scope,
+	})
+	return nil
+}
+
+// FindKey 根据公钥指纹查找有效的部署密钥（SSH认证阶段尚不知道目标仓库，仓库与权限由Authorize校验）
+func (s *deployKeyService) FindKey(fingerprint string) (*DeployCredential, error) {
+	var key models.DeployKey
+	if err := s.db.Where("fingerprint = ?", fingerprint).First(&key).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("部署密钥不存在")
+		}
+		return nil, fmt.Errorf("获取部署密钥失败: %w", err)
+	}
+	if expired(key.ExpiresAt) {
+		return nil, errDeployCredentialExpired
+	}
+
+	return &DeployCredential{
+		ID:           key.ID,
+		Method:       AuthMethodDeployKey,
+		RepositoryID: key.RepositoryID,
+		Title:        key.Title,
+		Scope:        key.Scope,
+	}, nil
+}
+
+// AuthenticateToken 校验部署令牌
+func (s *deployKeyService) AuthenticateToken(token string) (*DeployCredential, error) {
+	if !strings.HasPrefix(token, DeployTokenPrefix) {
+		return nil, fmt.Errorf("无效的部署令牌")
+	}
+
+	hash := hashDeployToken(token)
+	var deployToken models.DeployToken
+	if err := s.db.Where("token_hash = ?", hash).First(&deployToken).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("无效的部署令牌")
+		}
+		return nil, fmt.Errorf("获取部署令牌失败: %w", err)
+	}
+	if expired(deployToken.ExpiresAt) {
+		return nil, errDeployCredentialExpired
+	}
+
+	return &DeployCredential{
+		ID:           deployToken.ID,
+		Method:       AuthMethodDeployToken,
+		RepositoryID: deployToken.RepositoryID,
+		Title:        deployToken.Title,
+		Scope:        deployToken.Scope,
+	}, nil
+}
+
+// Authorize 校验部署凭据对仓库的访问权限，通过后记录最后使用时间和来源IP
+func (s *deployKeyService) Authorize(credential *DeployCredential, repositoryID uuid.UUID, write bool, clientIP string) error {
+	if credential.RepositoryID != repositoryID {
+		return errDeployCredentialRepository
+	}
+	if write && credential.Scope != DeployScopeWrite {
+		return errDeployCredentialReadOnly
+	}
+
+	var model interface{} = &models.DeployKey{}
+	if credential.Method == AuthMethodDeployToken {
+		model = &models.DeployToken{}
+	}
+	if err := s.db.Model(model).Where("id = ?", credential.ID).Updates(map[string]interface{}{
+		"last_used_at": time.Now(),
+		"last_used_ip": clientIP,
+	}).Error; err != nil {
+		fmt.Printf("更新部署凭据使用记录失败 [%s]: %v\n", credential.ID, err)
+	}
+	return nil
+}
+
+// Session 构造部署凭据的Git会话：不关联用户，以凭据标题标识操作者
+func (c *DeployCredential) Session(clientIP, userAgent, protocol string) *GitSession {
+	credentialID := c.ID
+	return &GitSession{
+		Username:     strings.ReplaceAll(c.Method, "_", "-") + ":" + c.Title,
+		ClientIP:     clientIP,
+		UserAgent:    userAgent,
+		Protocol:     protocol,
+		AuthMethod:   c.Method,
+		CredentialID: &credentialID,
+	}
+}
+
+// checkRepository 检查仓库是否存在
+func (s *deployKeyService) checkRepository(repositoryID uuid.UUID) error {
+	var count int64
+	if err := s.db.Model(&models.Repository{}).
+		Where("id = ? AND deleted_at IS NULL", repositoryID).Count(&count).Error; err != nil {
+		return fmt.Errorf("获取仓库失败: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("仓库不存在")
+	}
+	return nil
+}
+
+// recordAudit 记录部署凭据管理操作的审计日志，写入失败不影响主流程
+func (s *deployKeyService) recordAudit(repositoryID uuid.UUID, actor *CredentialActor, action, entityType string,
+	entityID uuid.UUID, details map[string]interface{}) {
+	var tenantID uuid.UUID
+	if err := s.db.Model(&models.Project{}).
+		Joins("JOIN repositories ON repositories.project_id = projects.id").
+		Where("repositories.id = ?", repositoryID).
+		Pluck("projects.tenant_id", &tenantID).Error; err != nil || tenantID == uuid.Nil {
+		fmt.Printf("获取仓库租户失败，跳过审计日志 [%s %s]: %v\n", action, entityID, err)
+		return
+	}
+
+	details["repository_id"] = repositoryID
+	detailsJSON, _ := json.Marshal(details)
+	entry := &models.AuditLog{
+		TenantID:         tenantID,
+		UserID:           &actor.UserID,
+		Action:           action,
+		TargetEntityType: entityType,
+		TargetEntityID:   &entityID,
+		Details:          detailsJSON,
+		CreatedAt:        time.Now(),
+	}
+	if actor.ClientIP != "" {
+		entry.ClientIP = &actor.ClientIP
+	}
+	if err := s.db.Create(entry).Error; err != nil {
+		fmt.Printf("记录审计日志失败 [%s %s]: %v\n", action, entityID, err)
+	}
+}
+
+// lockKeyFingerprint 在事务内获取SSH公钥指纹的咨询锁，事务结束时自动释放
+func lockKeyFingerprint(tx *gorm.DB, fingerprint string) error {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "ssh-key:"+fingerprint).Error; err != nil {
+		return fmt.Errorf("获取公钥锁失败: %w", err)
+	}
+	return nil
+}
+
+// checkFingerprintUnused 检查公钥未被用作部署密钥或用户访问密钥
+func checkFingerprintUnused(tx *gorm.DB, fingerprint string) error {
+	var count int64
+	if err := tx.Model(&models.DeployKey{}).Where("fingerprint = ?", fingerprint).Count(&count).Error; err != nil {
+		return fmt.Errorf("检查部署密钥失败: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("该公钥已被用作部署密钥")
+	}
+	if err := tx.Model(&models.AccessKey{}).Where("fingerprint = ?", fingerprint).Count(&count).Error; err != nil {
+		return fmt.Errorf("检查访问密钥失败: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("该公钥已被用作用户访问密钥")
+	}
+	return nil
+}
+
+// checkExpiry 过期时间必须晚于当前时间
+func checkExpiry(expiresAt *time.Time) error {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return fmt.Errorf("过期时间必须晚于当前时间")
+	}
+	return nil
+}
+
+// expired 凭据是否已过期
+func expired(expiresAt *time.Time) bool {
+	return expiresAt != nil && !expiresAt.After(time.Now())
+}
+
+// deployScope 未指定权限范围时默认只读
+func deployScope(scope string) string {
+	if scope == DeployScopeWrite {
+		return DeployScopeWrite
+	}
+	return DeployScopeRead
+}
+
+// hashDeployToken 计算部署令牌的SHA-256哈希
+func hashDeployToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,667 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"git-gateway-service/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 导出格式
+const (
+	ExportFormatCSV   = "csv"
+	ExportFormatJSONL = "jsonl"
+)
+
+// 异常活动类型
+const (
+	AnomalyMassClone     = "mass_clone"     // 短时间内克隆/拉取大量仓库
+	AnomalyFailureBurst  = "failure_burst"  // 同一IP短时间内大量失败操作
+	AnomalyLargeTransfer = "large_transfer" // 短时间内传输数据量过大
+)
+
+// 异常检测默认参数
+const (
+	defaultAnomalyWindow      = time.Hour
+	defaultMassCloneThreshold = 20
+	defaultFailureThreshold   = 30
+	defaultTransferThreshold  = 5 << 30 // 5GB
+	maxAnomalyRepositories    = 50
+)
+
+// TrafficStatsRequest 仓库克隆/拉取流量统计请求
+type TrafficStatsRequest struct {
+	RepositoryID uuid.UUID  `json:"repository_id" validate:"required"`
+	StartTime    *time.Time `json:"start_time"` // 默认最近14天
+	EndTime      *time.Time `json:"end_time"`
+	Interval     string     `json:"interval"` // day, hour
+}
+
+// TrafficSummary 流量汇总
+type TrafficSummary struct {
+	Count            int64 `json:"count"`
+	UniqueUsers      int64 `json:"unique_users"`
+	UniqueIPs        int64 `json:"unique_ips"`
+	BytesTransferred int64 `json:"bytes_transferred"`
+}
+
+// TrafficPoint 流量时间序列中的一个时间桶
+type TrafficPoint struct {
+	Timestamp        time.Time `json:"timestamp"`
+	Clones           int64     `json:"clones"`
+	Fetches          int64     `json:"fetches"`
+	UniqueUsers      int64     `json:"unique_users"`
+	UniqueIPs        int64     `json:"unique_ips"`
+	BytesTransferred int64     `json:"bytes_transferred"`
+}
+
+// TrafficStats 仓库克隆/拉取流量统计
+type TrafficStats struct {
+	RepositoryID uuid.UUID      `json:"repository_id"`
+	Interval     string         `json:"interval"`
+	StartTime    time.Time      `json:"start_time"`
+	EndTime      time.Time      `json:"end_time"`
+	Clones       TrafficSummary `json:"clones"`
+	Fetches      TrafficSummary `json:"fetches"`
+	Series       []TrafficPoint `json:"series"`
+}
+
+// TopClientsRequest 活跃用户/IP排行请求
+type TopClientsRequest struct {
+	RepositoryID *uuid.UUID `json:"repository_id"`
+	Operation    *string    `json:"operation"`
+	StartTime    *time.Time `json:"start_time"` // 默认最近7天
+	EndTime      *time.Time `json:"end_time"`
+	Limit        int        `json:"limit"`
+}
+
+// ClientActivity 用户或IP的操作汇总
+type ClientActivity struct {
+	UserID           *uuid.UUID `json:"user_id,omitempty"`
+	ClientIP         *string    `json:"client_ip,omitempty"`
+	Operations       int64      `json:"operations"`
+	FailedOperations int64      `json:"failed_operations"`
+	Repositories     int64      `json:"repositories"`
+	Users            int64      `json:"users,omitempty"` // 仅IP排行：使用该IP的用户数
+	BytesTransferred int64      `json:"bytes_transferred"`
+	LastSeenAt       time.Time  `json:"last_seen_at"`
+}
+
+// TopClients 活跃用户/IP排行
+type TopClients struct {
+	TopUsers []ClientActivity `json:"top_users"`
+	TopIPs   []ClientActivity `json:"top_ips"`
+}
+
+// AnomalyDetectionRequest 异常活动检测请求
+type AnomalyDetectionRequest struct {
+	StartTime          *time.Time    `json:"start_time"` // 默认最近24小时
+	EndTime            *time.Time    `json:"end_time"`
+	Window             time.Duration `json:"window"`               // 滑动窗口，默认1小时
+	MassCloneThreshold int           `json:"mass_clone_threshold"` // 窗口内克隆/拉取的不同仓库数
+	FailureThreshold   int           `json:"failure_threshold"`    // 窗口内同一IP的失败操作数
+	TransferThreshold  int64         `json:"transfer_threshold"`   // 窗口内同一用户的传输字节数
+}
+
+// ActivityAnomaly 异常活动
+type ActivityAnomaly struct {
+	Type             string      `json:"type"`
+	UserID           *uuid.UUID  `json:"user_id,omitempty"`
+	ClientIP         *string     `json:"client_ip,omitempty"`
+	WindowStart      time.Time   `json:"window_start"`
+	WindowEnd        time.Time   `json:"window_end"`
+	Count            int64       `json:"count"` // 峰值：仓库数或失败次数
+	BytesTransferred int64       `json:"bytes_transferred"`
+	Repositories     []uuid.UUID `json:"repositories,omitempty"`
+	Description      string      `json:"description"`
+}
+
+// ExportOperationsRequest 操作记录导出请求
+type ExportOperationsRequest struct {
+	Format       string     `json:"format" validate:"oneof=csv jsonl"`
+	StartTime    time.Time  `json:"start_time" validate:"required"`
+	EndTime      time.Time  `json:"end_time" validate:"required"`
+	RepositoryID *uuid.UUID `json:"repository_id"`
+	UserID       *uuid.UUID `json:"user_id"`
+	Operation    *string    `json:"operation"`
+}
+
+// GetTrafficStats 获取仓库克隆/拉取流量时间序列
+func (s *gitOperationService) GetTrafficStats(req *TrafficStatsRequest) (*TrafficStats, error) {
+	interval := req.Interval
+	if interval == "" {
+		interval = "day"
+	}
+	if interval != "day" && interval != "hour" {
+		return nil, fmt.Errorf("无效的统计间隔: %s", interval)
+	}
+
+	end := time.Now().UTC()
+	if req.EndTime != nil {
+		end = req.EndTime.UTC()
+	}
+	start := end.AddDate(0, 0, -14)
+	if req.StartTime != nil {
+		start = req.StartTime.UTC()
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("开始时间必须早于结束时间")
+	}
+
+	query := s.db.Model(&models.GitOperation{}).
+		Where("repository_id = ? AND success = ? AND operation IN ?",
+			req.RepositoryID, true, []string{OperationClone, OperationFetch}).
+		Where("created_at >= ? AND created_at <= ?", start, end)
+
+	stats := &TrafficStats{
+		RepositoryID: req.RepositoryID,
+		Interval:     interval,
+		StartTime:    start,
+		EndTime:      end,
+	}
+
+	// 匿名访问（公开仓库）不计入独立用户
+	uniqueUsers := "COUNT(DISTINCT CASE WHEN user_id <> ? THEN user_id END)"
+
+	var summaries []struct {
+		Operation        string
+		Count            int64
+		UniqueUsers      int64
+		UniqueIPs        int64
+		BytesTransferred int64
+	}
+	if err := query.Session(&gorm.Session{}).
+		Select("operation, COUNT(*) AS count, "+uniqueUsers+" AS unique_users, "+
+			"COUNT(DISTINCT client_ip) AS unique_ips, COALESCE(SUM(bytes_transferred), 0) AS bytes_transferred", uuid.Nil).
+		Group("operation").
+		Scan(&summaries).Error; err != nil {
+		return nil, fmt.Errorf("统计流量失败: %w", err)
+	}
+	for _, summary := range summaries {
+		value := TrafficSummary{
+			Count:            summary.Count,
+			UniqueUsers:      summary.UniqueUsers,
+			UniqueIPs:        summary.UniqueIPs,
+			BytesTransferred: summary.BytesTransferred,
+		}
+		if summary.Operation == OperationClone {
+			stats.Clones = value
+		} else {
+			stats.Fetches = value
+		}
+	}
+
+	var buckets []struct {
+		Bucket           time.Time
+		Clones           int64
+		Fetches          int64
+		UniqueUsers      int64
+		UniqueIPs        int64
+		BytesTransferred int64
+	}
+	if err := query.Session(&gorm.Session{}).
+		Select("DATE_TRUNC(?, created_at AT TIME ZONE 'UTC') AS bucket, "+
+			"COUNT(CASE WHEN operation = ? THEN 1 END) AS clones, "+
+			"COUNT(CASE WHEN operation = ? THEN 1 END) AS fetches, "+
+			uniqueUsers+" AS unique_users, "+
+			"COUNT(DISTINCT client_ip) AS unique_ips, "+
+			"COALESCE(SUM(bytes_transferred), 0) AS bytes_transferred",
+			interval, OperationClone, OperationFetch, uuid.Nil).
+		Group("bucket").
+		Order("bucket").
+		Scan(&buckets).Error; err != nil {
+		return nil, fmt.Errorf("统计流量时间序列失败: %w", err)
+	}
+
+	// 补齐没有流量的时间桶，保证序列连续
+	points := make(map[time.Time]TrafficPoint, len(buckets))
+	for _, bucket := range buckets {
+		points[bucket.Bucket.UTC()] = TrafficPoint{
+			Timestamp:        bucket.Bucket.UTC(),
+			Clones:           bucket.Clones,
+			Fetches:          bucket.Fetches,
+			UniqueUsers:      bucket.UniqueUsers,
+			UniqueIPs:        bucket.UniqueIPs,
+			BytesTransferred: bucket.BytesTransferred,
+		}
+	}
+	step := 24 * time.Hour
+	if interval == "hour" {
+		step = time.Hour
+	}
+	for t := start.UTC().Truncate(step); !t.After(end); t = t.Add(step) {
+		point, ok := points[t]
+		if !ok {
+			point = TrafficPoint{Timestamp: t}
+		}
+		stats.Series = append(stats.Series, point)
+	}
+
+	return stats, nil
+}
+
+// GetTopClients 获取操作最多的用户和IP
+func (s *gitOperationService) GetTopClients(req *TopClientsRequest) (*TopClients, error) {
+	end := time.Now()
+	if req.EndTime != nil {
+		end = *req.EndTime
+	}
+	start := end.AddDate(0, 0, -7)
+	if req.StartTime != nil {
+		start = *req.StartTime
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 10
+	}
+
+	query := s.db.Model(&models.GitOperation{}).
+		Where("created_at >= ? AND created_at <= ?", start, end)
+	if req.RepositoryID != nil {
+		query = query.Where("repository_id = ?", *req.RepositoryID)
+	}
+	if req.Operation != nil {
+		query = query.Where("operation = ?", *req.Operation)
+	}
+
+	type activityRow struct {
+		UserID           uuid.UUID
+		ClientIP         string
+		Operations       int64
+		FailedOperations int64
+		Repositories     int64
+		Users            int64
+		BytesTransferred int64
+		LastSeenAt       time.Time
+	}
+	aggregates := "COUNT(*) AS operations, " +
+		"COUNT(CASE WHEN success = false THEN 1 END) AS failed_operations, " +
+		"COUNT(DISTINCT repository_id) AS repositories, " +
+		"COALESCE(SUM(bytes_transferred), 0) AS bytes_transferred, " +
+		"MAX(created_at) AS last_seen_at"
+
+	var userRows []activityRow
+	if err := query.Session(&gorm.Session{}).
+		Select("user_id, "+aggregates).
+		Where("user_id <> ?", uuid.Nil).
+		Group("user_id").
+		Order("operations DESC").
+		Limit(limit).
+		Scan(&userRows).Error; err != nil {
+		return nil, fmt.Errorf("统计活跃用户失败: %w", err)
+	}
+
+	var ipRows []activityRow
+	if err := query.Session(&gorm.Session{}).
+		Select("client_ip, COUNT(DISTINCT user_id) AS users, " + aggregates).
+		Group("client_ip").
+		Order("operations DESC").
+		Limit(limit).
+		Scan(&ipRows).Error; err != nil {
+		return nil, fmt.Errorf("统计活跃IP失败: %w", err)
+	}
+
+	result := &TopClients{
+		TopUsers: make([]ClientActivity, 0, len(userRows)),
+		TopIPs:   make([]ClientActivity, 0, len(ipRows)),
+	}
+	for _, row := range userRows {
+		userID := row.UserID
+		result.TopUsers = append(result.TopUsers, ClientActivity{
+			UserID:           &userID,
+			Operations:       row.Operations,
+			FailedOperations: row.FailedOperations,
+			Repositories:     row.Repositories,
+			BytesTransferred: row.BytesTransferred,
+			LastSeenAt:       row.LastSeenAt,
+		})
+	}
+	for _, row := range ipRows {
+		clientIP := row.ClientIP
+		result.TopIPs = append(result.TopIPs, ClientActivity{
+			ClientIP:         &clientIP,
+			Operations:       row.Operations,
+			FailedOperations: row.FailedOperations,
+			Repositories:     row.Repositories,
+			Users:            row.Users,
+			BytesTransferred: row.BytesTransferred,
+			LastSeenAt:       row.LastSeenAt,
+		})
+	}
+	return result, nil
+}
+
+// DetectAnomalies 按滑动窗口检测异常活动：批量克隆仓库、同一IP大量失败、短时间大流量传输
+func (s *gitOperationService) DetectAnomalies(req *AnomalyDetectionRequest) ([]ActivityAnomaly, error) {
+	end := time.Now()
+	if req.EndTime != nil {
+		end = *req.EndTime
+	}
+	start := end.Add(-24 * time.Hour)
+	if req.StartTime != nil {
+		start = *req.StartTime
+	}
+
+	detector := newAnomalyDetector(req)
+
+	rows, err := s.db.Model(&models.GitOperation{}).
+		Select("repository_id, user_id, operation, client_ip, success, bytes_transferred, created_at").
+		Where("created_at >= ? AND created_at <= ?", start, end).
+		Order("created_at").
+		Rows()
+	if err != nil {
+		return nil, fmt.Errorf("读取操作记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var op models.GitOperation
+		if err := s.db.ScanRows(rows, &op); err != nil {
+			return nil, fmt.Errorf("读取操作记录失败: %w", err)
+		}
+		detector.observe(&op)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取操作记录失败: %w", err)
+	}
+
+	return detector.result(), nil
+}
+
+// ExportOperations 按时间范围导出操作记录（CSV或JSON Lines），逐行写出避免一次性加载
+func (s *gitOperationService) ExportOperations(req *ExportOperationsRequest, w io.Writer) error {
+	if req.Format != ExportFormatCSV && req.Format != ExportFormatJSONL {
+		return fmt.Errorf("不支持的导出格式: %s", req.Format)
+	}
+	if !req.StartTime.Before(req.EndTime) {
+		return fmt.Errorf("开始时间必须早于结束时间")
+	}
+
+	query := s.db.Model(&models.GitOperation{}).
+		Where("created_at >= ? AND created_at <= ?", req.StartTime, req.EndTime)
+	if req.RepositoryID != nil {
+		query = query.Where("repository_id = ?", *req.RepositoryID)
+	}
+	if req.UserID != nil {
+		query = query.Where("user_id = ?", *req.UserID)
+	}
+	if req.Operation != nil {
+		query = query.Where("operation = ?", *req.Operation)
+	}
+
+	rows, err := query.Order("created_at, id").Rows()
+	if err != nil {
+		return fmt.Errorf("读取操作记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var csvWriter *csv.Writer
+	var encoder *json.Encoder
+	if req.Format == ExportFormatCSV {
+		csvWriter = csv.NewWriter(w)
+		if err := csvWriter.Write(operationExportHeader); err != nil {
+			return err
+		}
+	} else {
+		encoder = json.NewEncoder(w)
+	}
+
+	for rows.Next() {
+		var op models.GitOperation
+		if err := s.db.ScanRows(rows, &op); err != nil {
+			return fmt.Errorf("读取操作记录失败: %w", err)
+		}
+		if csvWriter != nil {
+			err = csvWriter.Write(operationExportRecord(&op))
+		} else {
+			err = encoder.Encode(&op)
+		}
+		if err != nil {
+			return fmt.Errorf("写入导出数据失败: %w", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("读取操作记录失败: %w", err)
+	}
+
+	if csvWriter != nil {
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+	return nil
+}
+
+// operationExportHeader CSV导出列
+var operationExportHeader = []string{
+	"id", "created_at", "repository_id", "user_id", "operation", "protocol", "ref_name", "commit_sha",
+	"client_ip", "user_agent", "success", "error_msg", "duration_ms", "bytes_transferred",
+	"auth_method", "credential_id",
+}
+
+// operationExportRecord 将操作记录转换为CSV行
+func operationExportRecord(op *models.GitOperation) []string {
+	optional := func(value *string) string {
+		if value == nil {
+			return ""
+		}
+		return *value
+	}
+	credentialID := ""
+	if op.CredentialID != nil {
+		credentialID = op.CredentialID.String()
+	}
+	return []string{
+		op.ID.String(),
+		op.CreatedAt.UTC().Format(time.RFC3339),
+		op.RepositoryID.String(),
+		op.UserID.String(),
+		op.Operation,
+		op.Protocol,
+		optional(op.RefName),
+		optional(op.CommitSHA),
+		op.ClientIP,
+		optional(op.UserAgent),
+		strconv.FormatBool(op.Success),
+		optional(op.ErrorMsg),
+		strconv.Itoa(op.Duration),
+		strconv.FormatInt(op.BytesTransferred, 10),
+		op.AuthMethod,
+		credentialID,
+	}
+}
+
+// windowEvent 滑动窗口中的一次操作
+type windowEvent struct {
+	at    time.Time
+	key   string
+	bytes int64
+}
+
+// activityWindow 单个用户或IP的滑动窗口
+type activityWindow struct {
+	events []windowEvent
+	keys   map[string]int
+	bytes  int64
+	alert  *ActivityAnomaly // 当前仍在持续的异常，窗口内条件持续满足时合并为一条
+}
+
+func newActivityWindow() *activityWindow {
+	return &activityWindow{keys: make(map[string]int)}
+}
+
+// push 加入事件并移出窗口外的旧事件
+func (w *activityWindow) push(event windowEvent, window time.Duration) {
+	cutoff := event.at.Add(-window)
+	drop := 0
+	for drop < len(w.events) && w.events[drop].at.Before(cutoff) {
+		old := w.events[drop]
+		w.keys[old.key]--
+		if w.keys[old.key] == 0 {
+			delete(w.keys, old.key)
+		}
+		w.bytes -= old.bytes
+		drop++
+	}
+	w.events = append(w.events[drop:], event)
+	w.keys[event.key]++
+	w.bytes += event.bytes
+}
+
+// anomalyDetector 异常活动检测器
+type anomalyDetector struct {
+	window             time.Duration
+	massCloneThreshold int
+	failureThreshold   int
+	transferThreshold  int64
+	clones             map[uuid.UUID]*activityWindow
+	failures           map[string]*activityWindow
+	transfers          map[uuid.UUID]*activityWindow
+	anomalies          []*ActivityAnomaly
+}
+
+func newAnomalyDetector(req *AnomalyDetectionRequest) *anomalyDetector {
+	d := &anomalyDetector{
+		window:             req.Window,
+		massCloneThreshold: req.MassCloneThreshold,
+		failureThreshold:   req.FailureThreshold,
+		transferThreshold:  req.TransferThreshold,
+		clones:             make(map[uuid.UUID]*activityWindow),
+		failures:           make(map[string]*activityWindow),
+		transfers:          make(map[uuid.UUID]*activityWindow),
+	}
+	if d.window <= 0 {
+		d.window = defaultAnomalyWindow
+	}
+	if d.massCloneThreshold <= 0 {
+		d.massCloneThreshold = defaultMassCloneThreshold
+	}
+	if d.failureThreshold <= 0 {
+		d.failureThreshold = defaultFailureThreshold
+	}
+	if d.transferThreshold <= 0 {
+		d.transferThreshold = defaultTransferThreshold
+	}
+	return d
+}
+
+// observe 按时间顺序处理一条操作记录
+func (d *anomalyDetector) observe(op *models.GitOperation) {
+	if !op.Success {
+		ip := op.ClientIP
+		if d.failures[ip] == nil {
+			d.failures[ip] = newActivityWindow()
+		}
+		w := d.failures[ip]
+		w.push(windowEvent{at: op.CreatedAt, key: op.RepositoryID.String()}, d.window)
+		count := int64(len(w.events))
+		if count >= int64(d.failureThreshold) {
+			d.raise(w, op.CreatedAt, func() *ActivityAnomaly {
+				return &ActivityAnomaly{Type: AnomalyFailureBurst, ClientIP: &ip}
+			}, count, 0, nil)
+		} else {
+			w.alert = nil
+		}
+		return
+	}
+
+	if op.UserID == uuid.Nil {
+		return
+	}
+	userID := op.UserID
+
+	if op.Operation == OperationClone || op.Operation == OperationFetch {
+		if d.clones[userID] == nil {
+			d.clones[userID] = newActivityWindow()
+		}
+		w := d.clones[userID]
+		w.push(windowEvent{at: op.CreatedAt, key: op.RepositoryID.String()}, d.window)
+		count := int64(len(w.keys))
+		if count >= int64(d.massCloneThreshold) {
+			d.raise(w, op.CreatedAt, func() *ActivityAnomaly {
+				return &ActivityAnomaly{Type: AnomalyMassClone, UserID: &userID}
+			}, count, w.bytes, windowRepositories(w))
+		} else {
+			w.alert = nil
+		}
+	}
+
+	if op.BytesTransferred > 0 {
+		if d.transfers[userID] == nil {
+			d.transfers[userID] = newActivityWindow()
+		}
+		w := d.transfers[userID]
+		w.push(windowEvent{at: op.CreatedAt, key: op.RepositoryID.String(), bytes: op.BytesTransferred}, d.window)
+		if w.bytes >= d.transferThreshold {
+			d.raise(w, op.CreatedAt, func() *ActivityAnomaly {
+				return &ActivityAnomaly{Type: AnomalyLargeTransfer, UserID: &userID}
+			}, int64(len(w.keys)), w.bytes, windowRepositories(w))
+		} else {
+			w.alert = nil
+		}
+	}
+}
+
+// raise 记录异常；同一窗口内持续满足条件时更新已有异常的结束时间和峰值
+func (d *anomalyDetector) raise(w *activityWindow, at time.Time, create func() *ActivityAnomaly,
+	count, bytes int64, repositories []uuid.UUID) {
+	if w.alert == nil {
+		w.alert = create()
+		w.alert.WindowStart = w.events[0].at
+		d.anomalies = append(d.anomalies, w.alert)
+	}
+	w.alert.WindowEnd = at
+	if count > w.alert.Count {
+		w.alert.Count = count
+	}
+	if bytes > w.alert.BytesTransferred {
+		w.alert.BytesTransferred = bytes
+	}
+	if len(repositories) > len(w.alert.Repositories) {
+		w.alert.Repositories = repositories
+	}
+}
+
+// result 生成异常列表，补充描述并按开始时间排序
+func (d *anomalyDetector) result() []ActivityAnomaly {
+	anomalies := make([]ActivityAnomaly, 0, len(d.anomalies))
+	for _, anomaly := range d.anomalies {
+		switch anomaly.Type {
+		case AnomalyMassClone:
+			anomaly.Description = fmt.Sprintf("用户在%s内克隆/拉取了 %d 个仓库", d.window, anomaly.Count)
+		case AnomalyFailureBurst:
+			anomaly.Description = fmt.Sprintf("IP在%s内有 %d 次失败操作", d.window, anomaly.Count)
+		case AnomalyLargeTransfer:
+			anomaly.Description = fmt.Sprintf("用户在%s内传输了 %d 字节", d.window, anomaly.BytesTransferred)
+		}
+		anomalies = append(anomalies, *anomaly)
+	}
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		return anomalies[i].WindowStart.Before(anomalies[j].WindowStart)
+	})
+	return anomalies
+}
+
+// windowRepositories 窗口内涉及的仓库（最多50个）
+func windowRepositories(w *activityWindow) []uuid.UUID {
+	repositories := make([]uuid.UUID, 0, len(w.keys))
+	for key := range w.keys {
+		if id, err := uuid.Parse(key); err == nil {
+			repositories = append(repositories, id)
+		}
+		if len(repositories) >= maxAnomalyRepositories {
+			break
+		}
+	}
+	sort.Slice(repositories, func(i, j int) bool {
+		return repositories[i].String() < repositories[j].String()
+	})
+	return repositories
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"io"
 	"time"
 
 	"git-gateway-service/internal/models"
@@ -19,6 +20,10 @@ type GitOperationService interface {
 	GetOperationStats(req *OperationStatsRequest) (*OperationStats, error)
 	List(req *ListOperationsRequest) ([]models.GitOperation, int64, error)
 	CleanupOldRecords(retentionDays int) error
+	GetTrafficStats(req *TrafficStatsRequest) (*TrafficStats, error)
+	GetTopClients(req *TopClientsRequest) (*TopClients, error)
+	DetectAnomalies(req *AnomalyDetectionRequest) ([]ActivityAnomaly, error)
+	ExportOperations(req *ExportOperationsRequest, w io.Writer) error
 }
 
 type gitOperationService struct {
@@ -32,18 +37,20 @@ func NewGitOperationService(db *gorm.DB) GitOperationService {
 
 // RecordOperationRequest 记录操作请求
 type RecordOperationRequest struct {
-	RepositoryID     uuid.UUID `json:"repository_id" validate:"required"`
-	UserID           uuid.UUID `json:"user_id" validate:"required"`
-	Operation        string    `json:"operation" validate:"required,max=50"`
-	Protocol         string    `json:"protocol" validate:"required,oneof=http ssh"`
-	RefName          *string   `json:"ref_name" validate:"omitempty,max=255"`
-	CommitSHA        *string   `json:"commit_sha" validate:"omitempty,len=40"`
-	ClientIP         string    `json:"client_ip" validate:"required,max=45"`
-	UserAgent        *string   `json:"user_agent" validate:"omitempty,max=512"`
-	Success          bool      `json:"success"`
-	ErrorMsg         *string   `json:"error_msg"`
-	Duration         int       `json:"duration"` // 毫秒
-	BytesTransferred int64     `json:"bytes_transferred"`
+	RepositoryID     uuid.UUID  `json:"repository_id" validate:"required"`
+	UserID           uuid.UUID  `json:"user_id" validate:"required"`
+	Operation        string     `json:"operation" validate:"required,max=50"`
+	Protocol         string     `json:"protocol" validate:"required,oneof=http ssh"`
+	RefName          *string    `json:"ref_name" validate:"omitempty,max=255"`
+	CommitSHA        *string    `json:"commit_sha" validate:"omitempty,len=40"`
+	ClientIP         string     `json:"client_ip" validate:"required,max=45"`
+	UserAgent        *string    `json:"user_agent" validate:"omitempty,max=512"`
+	Success          bool       `json:"success"`
+	ErrorMsg         *string    `json:"error_msg"`
+	Duration         int        `json:"duration"` // 毫秒
+	BytesTransferred int64      `json:"bytes_transferred"`
+	AuthMethod       string     `json:"auth_method"`
+	CredentialID     *uuid.UUID `json:"credential_id"`
 }
 
 // ListOperationsRequest 列表查询请求
@@ -56,6 +63,7 @@ type ListOperationsRequest struct {
 	StartTime    *time.Time `json:"start_time"`
 	EndTime      *time.Time `json:"end_time"`
 	ClientIP     *string    `json:"client_ip"`
+	CredentialID *uuid.UUID `json:"credential_id"`
 	Page         int        `json:"page"`
 	Limit        int        `json:"limit"`
 	SortBy       string     `json:"sort_by"`
@@ -129,6 +137,8 @@ func (s *gitOperationService) RecordOperation(req *RecordOperationRequest) (*mod
 		ErrorMsg:         req.ErrorMsg,
 		Duration:         req.Duration,
 		BytesTransferred: req.BytesTransferred,
+		AuthMethod:       req.AuthMethod,
+		CredentialID:     req.CredentialID,
 		CreatedAt:        time.Now(),
 	}
 
@@ -401,6 +411,10 @@ func (s *gitOperationService) List(req *ListOperationsRequest) ([]models.GitOper
 		query = query.Where("client_ip = ?", *req.ClientIP)
 	}
 
+	if req.CredentialID != nil {
+		query = query.Where("credential_id = ?", *req.CredentialID)
+	}
+
 	// 计算总数
 	var total int64
 	if err := query.Count(&total).Error; err != nil {
@@ -0,0 +1,1009 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"git-gateway-service/internal/config"
+	"git-gateway-service/internal/models"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/format/pktline"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GitProtocolService Git智能协议服务接口（upload-pack / receive-pack）
+type GitProtocolService interface {
+	AdvertiseReferences(ctx context.Context, repo *models.Repository, service string, w io.Writer) error
+	UploadPack(ctx context.Context, repo *models.Repository, session *GitSession, r io.Reader, w io.Writer) error
+	ReceivePack(ctx context.Context, repo *models.Repository, session *GitSession, r io.Reader, w io.Writer) error
+	ServeSSH(ctx context.Context, repo *models.Repository, session *GitSession, service string, r io.Reader, w io.Writer) error
+	UpdateReference(repo *models.Repository, session *GitSession, update *RefUpdate) error
+}
+
+type gitProtocolService struct {
+	db              *gorm.DB
+	config          *config.Config
+	protectionRules ProtectionRuleService
+	storage         StorageService
+	signingKeys     SigningKeyService
+	insights        InsightService
+	codeSearch      CodeSearchService
+	webhookService  WebhookService
+	gitOpService    GitOperationService
+	taskLinks       TaskLinkService
+}
+
+// NewGitProtocolService 创建Git智能协议服务实例
+func NewGitProtocolService(db *gorm.DB, cfg *config.Config, protectionRules ProtectionRuleService,
+	storage StorageService, signingKeys SigningKeyService, insights InsightService, codeSearch CodeSearchService,
+	webhookService WebhookService, gitOpService GitOperationService, taskLinks TaskLinkService) GitProtocolService {
+	return &gitProtocolService{
+		db:              db,
+		config:          cfg,
+		protectionRules: protectionRules,
+		storage:         storage,
+		signingKeys:     signingKeys,
+		insights:        insights,
+		codeSearch:      codeSearch,
+		webhookService:  webhookService,
+		gitOpService:    gitOpService,
+		taskLinks:       taskLinks,
+	}
+}
+
+// Git服务名称
+const (
+	ServiceUploadPack  = "git-upload-pack"
+	ServiceReceivePack = "git-receive-pack"
+)
+
+// GitSession Git客户端会话信息
+type GitSession struct {
+	UserID       uuid.UUID  `json:"user_id"` // 部署密钥/令牌不关联用户，为空UUID
+	Username     string     `json:"username"`
+	ClientIP     string     `json:"client_ip"`
+	UserAgent    string     `json:"user_agent"`
+	Protocol     string     `json:"protocol"` // http, ssh
+	AuthMethod   string     `json:"-"`        // jwt, access_key, deploy_key, deploy_token
+	CredentialID *uuid.UUID `json:"-"`        // 访问密钥或部署密钥/令牌ID
+}
+
+// PushCommit 推送中包含的提交，added/modified/removed 为相对第一个父提交的变更文件
+type PushCommit struct {
+	ID        string    `json:"id"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+	Author    struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"author"`
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Removed  []string `json:"removed"`
+}
+
+// PushPayload 推送事件载荷
+type PushPayload struct {
+	Ref          string       `json:"ref"`
+	Before       string       `json:"before"`
+	After        string       `json:"after"`
+	Created      bool         `json:"created"`
+	Deleted      bool         `json:"deleted"`
+	Forced       bool         `json:"forced"`
+	RepositoryID uuid.UUID    `json:"repository_id"`
+	Repository   string       `json:"repository"`
+	Pusher       GitSession   `json:"pusher"`
+	Commits      []PushCommit `json:"commits"`
+	ChangedFiles []string     `json:"changed_files"` // before到after的全部变更文件，无法计算时为null
+	PushedAt     time.Time    `json:"pushed_at"`
+}
+
+// PullRequestPayload 合并请求事件载荷
+type PullRequestPayload struct {
+	Action        string     `json:"action"` // synchronize：源分支有新的推送
+	RepositoryID  uuid.UUID  `json:"repository_id"`
+	Repository    string     `json:"repository"`
+	PullRequestID uuid.UUID  `json:"pull_request_id"`
+	Number        int64      `json:"number"`
+	Title         string     `json:"title"`
+	SourceBranch  string     `json:"source_branch"`
+	TargetBranch  string     `json:"target_branch"`
+	HeadSHA       string     `json:"head_sha"`
+	BaseSHA       string     `json:"base_sha"`
+	Sender        GitSession `json:"sender"`
+	ChangedFiles  []string   `json:"changed_files"` // 合并基础到head的变更文件，无法计算时为null
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// maxPushCommits 推送事件中记录的最大提交数
+const maxPushCommits = 20
+
+// maxChangedFiles 事件中记录的最大变更文件数，超过时不记录（视为未知）
+const maxChangedFiles = 1000
+
+var (
+	errPushDisabled = errors.New("仓库已禁止推送")
+	errStaleRef     = errors.New("引用已过期，请先拉取")
+)
+
+// AdvertiseReferences 输出智能HTTP协议的引用通告
+func (s *gitProtocolService) AdvertiseReferences(ctx context.Context, repo *models.Repository, service string, w io.Writer) error {
+	e := pktline.NewEncoder(w)
+	if err := e.EncodeString(fmt.Sprintf("# service=%s\n", service)); err != nil {
+		return err
+	}
+	if err := e.Flush(); err != nil {
+		return err
+	}
+
+	return s.advertiseRefs(ctx, repo, service, w)
+}
+
+// advertiseRefs 输出引用通告（不含智能HTTP的服务头）
+func (s *gitProtocolService) advertiseRefs(ctx context.Context, repo *models.Repository, service string, w io.Writer) error {
+	release, err := s.storage.Acquire(repo)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	switch service {
+	case ServiceUploadPack:
+		cmd := exec.CommandContext(ctx, "git", "upload-pack", "--stateless-rpc", "--advertise-refs",
+			repositoryPath(s.config, repo))
+		cmd.Stdout = w
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("获取引用列表失败: %w", err)
+		}
+		return nil
+	case ServiceReceivePack:
+		gitRepo, err := git.PlainOpen(repositoryPath(s.config, repo))
+		if err != nil {
+			return fmt.Errorf("打开Git仓库失败: %w", err)
+		}
+
+		ar, err := s.receivePackAdvRefs(gitRepo)
+		if err != nil {
+			return err
+		}
+		return ar.Encode(w)
+	default:
+		return fmt.Errorf("不支持的Git服务: %s", service)
+	}
+}
+
+// UploadPack 处理克隆/拉取请求
+func (s *gitProtocolService) UploadPack(ctx context.Context, repo *models.Repository, session *GitSession, r io.Reader, w io.Writer) error {
+	return s.uploadPack(ctx, repo, session, r, w, true)
+}
+
+// ServeSSH 在SSH通道上以有状态协议执行upload-pack / receive-pack（引用通告与请求在同一连接中完成）
+func (s *gitProtocolService) ServeSSH(ctx context.Context, repo *models.Repository, session *GitSession,
+	service string, r io.Reader, w io.Writer) error {
+	switch service {
+	case ServiceUploadPack:
+		return s.uploadPack(ctx, repo, session, r, w, false)
+	case ServiceReceivePack:
+		if !repo.Settings.AllowPush {
+			return errPushDisabled
+		}
+		if err := s.advertiseRefs(ctx, repo, service, w); err != nil {
+			return err
+		}
+		return s.ReceivePack(ctx, repo, session, r, w)
+	default:
+		return fmt.Errorf("不支持的Git服务: %s", service)
+	}
+}
+
+// uploadPack 执行upload-pack；有状态模式下由git自身输出引用通告并完成多轮协商
+func (s *gitProtocolService) uploadPack(ctx context.Context, repo *models.Repository, session *GitSession,
+	r io.Reader, w io.Writer, stateless bool) error {
+	release, err := s.storage.Acquire(repo)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	start := time.Now()
+	request := &uploadPackRequest{r: r}
+	response := &countingWriter{w: w}
+
+	args := []string{"upload-pack"}
+	if stateless {
+		args = append(args, "--stateless-rpc")
+	}
+	cmd := exec.CommandContext(ctx, "git", append(args, repositoryPath(s.config, repo))...)
+	cmd.Stdin = request
+	cmd.Stdout = response
+
+	err = cmd.Run()
+	if err != nil {
+		err = fmt.Errorf("执行upload-pack失败: %w", err)
+	}
+
+	// 无状态协议下一次拉取可能包含多轮协商，仅在发送packfile的最后一轮记录；
+	// 有状态协议下客户端已是最新（如ls-remote）时不会发送done
+	if request.done {
+		s.recordUpload(repo, session, request, response.n, start, err)
+	}
+	return err
+}
+
+// ReceivePack 处理推送请求：解包、按保护规则校验每条引用命令、更新引用并同步元数据
+func (s *gitProtocolService) ReceivePack(ctx context.Context, repo *models.Repository, session *GitSession, r io.Reader, w io.Writer) error {
+	start := time.Now()
+	counter := &countingReader{r: r}
+
+	if !repo.Settings.AllowPush {
+		return errPushDisabled
+	}
+
+	release, err := s.storage.Acquire(repo)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	gitRepo, err := git.PlainOpen(repositoryPath(s.config, repo))
+	if err != nil {
+		return fmt.Errorf("打开Git仓库失败: %w", err)
+	}
+
+	// SSH有状态协议下客户端没有需要更新的引用时只发送flush-pkt
+	reader := bufio.NewReader(counter)
+	if head, _ := reader.Peek(4); len(head) == 0 || string(head) == "0000" {
+		return nil
+	}
+
+	req := packp.NewReferenceUpdateRequest()
+	if err := req.Decode(reader); err != nil {
+		return fmt.Errorf("解析推送请求失败: %w", err)
+	}
+
+	report := packp.NewReportStatus()
+	report.UnpackStatus = "ok"
+
+	// 解包对象（仅删除引用时客户端不发送packfile）
+	if req.Packfile != nil && hasNonDeleteCommand(req.Commands) {
+		if err := packfile.UpdateObjectStorage(gitRepo.Storer, req.Packfile); err != nil && err != packfile.ErrEmptyPackfile {
+			report.UnpackStatus = err.Error()
+			for _, cmd := range req.Commands {
+				report.CommandStatuses = append(report.CommandStatuses, &packp.CommandStatus{
+					ReferenceName: cmd.Name,
+					Status:        "n/a (unpacker error)",
+				})
+			}
+			s.writeReport(req, report, w)
+			s.recordPush(repo, session, nil, counter.n, start, err)
+			return nil
+		}
+	}
+
+	var applied []*RefUpdate
+	var firstErr error
+	for _, cmd := range req.Commands {
+		update, err := s.applyCommand(gitRepo, repo, session, cmd)
+		status := "ok"
+		if err != nil {
+			status = err.Error()
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else {
+			applied = append(applied, update)
+		}
+		report.CommandStatuses = append(report.CommandStatuses, &packp.CommandStatus{
+			ReferenceName: cmd.Name,
+			Status:        status,
+		})
+	}
+
+	s.writeReport(req, report, w)
+
+	for _, update := range applied {
+		if err := s.syncRef(gitRepo, repo, session, update); err != nil {
+			fmt.Printf("同步引用元数据失败 [%s]: %v\n", update.RefName, err)
+		}
+	}
+
+	s.recordPush(repo, session, applied, counter.n, start, firstErr)
+	return nil
+}
+
+// applyCommand 校验并执行单条引用更新命令
+func (s *gitProtocolService) applyCommand(gitRepo *git.Repository, repo *models.Repository,
+	session *GitSession, cmd *packp.Command) (*RefUpdate, error) {
+	refType, name := ParseRefName(cmd.Name.String())
+	if refType == "" {
+		return nil, fmt.Errorf("不支持的引用: %s", cmd.Name)
+	}
+
+	update := &RefUpdate{
+		RefName: cmd.Name.String(),
+		OldSHA:  cmd.Old.String(),
+		NewSHA:  cmd.New.String(),
+		UserID:  session.UserID,
+	}
+
+	current, err := gitRepo.Storer.Reference(cmd.Name)
+	exists := err == nil
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return nil, err
+	}
+
+	switch cmd.Action() {
+	case packp.Create:
+		if exists {
+			return nil, fmt.Errorf("引用已存在")
+		}
+	case packp.Update, packp.Delete:
+		if !exists || current.Hash() != cmd.Old {
+			return nil, errStaleRef
+		}
+	default:
+		return nil, fmt.Errorf("无效的引用命令")
+	}
+
+	if cmd.Action() == packp.Delete && refType == RefTypeBranch && name == repo.DefaultBranch {
+		return nil, fmt.Errorf("不能删除默认分支")
+	}
+
+	if cmd.Action() == packp.Update && refType == RefTypeBranch {
+		fastForward, err := isFastForward(gitRepo, cmd.Old, cmd.New)
+		if err != nil {
+			return nil, err
+		}
+		update.Forced = !fastForward
+	}
+
+	if err := s.protectionRules.CheckRefUpdate(repo.ID, update); err != nil {
+		return nil, err
+	}
+
+	if repo.Settings.RequireSignedCommits && cmd.Action() != packp.Delete {
+		if err := s.checkSignatures(gitRepo, repo, cmd.New); err != nil {
+			return nil, err
+		}
+	}
+
+	switch cmd.Action() {
+	case packp.Create:
+		err = gitRepo.Storer.SetReference(plumbing.NewHashReference(cmd.Name, cmd.New))
+	case packp.Update:
+		err = gitRepo.Storer.CheckAndSetReference(plumbing.NewHashReference(cmd.Name, cmd.New), current)
+	case packp.Delete:
+		err = gitRepo.Storer.RemoveReference(cmd.Name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("更新引用失败: %w", err)
+	}
+
+	return update, nil
+}
+
+// UpdateReference 服务端发起的分支更新（如合并队列合并），与推送执行相同的保护规则校验与元数据同步
+// 服务端生成的合并提交不要求签名，源分支提交已在推送时校验；调用方需持有仓库的存储使用锁
+func (s *gitProtocolService) UpdateReference(repo *models.Repository, session *GitSession, update *RefUpdate) error {
+	gitRepo, err := git.PlainOpen(repositoryPath(s.config, repo))
+	if err != nil {
+		return fmt.Errorf("打开Git仓库失败: %w", err)
+	}
+
+	refName := plumbing.ReferenceName(update.RefName)
+	current, err := gitRepo.Storer.Reference(refName)
+	if err != nil {
+		return fmt.Errorf("读取引用失败: %w", err)
+	}
+	if current.Hash().String() != update.OldSHA {
+		return errStaleRef
+	}
+
+	fastForward, err := isFastForward(gitRepo, current.Hash(), plumbing.NewHash(update.NewSHA))
+	if err != nil {
+		return err
+	}
+	update.Forced = !fastForward
+
+	if err := s.protectionRules.CheckRefUpdate(repo.ID, update); err != nil {
+		return err
+	}
+
+	if err := gitRepo.Storer.CheckAndSetReference(
+		plumbing.NewHashReference(refName, plumbing.NewHash(update.NewSHA)), current); err != nil {
+		return fmt.Errorf("更新引用失败: %w", err)
+	}
+
+	if err := s.syncRef(gitRepo, repo, session, update); err != nil {
+		fmt.Printf("同步引用元数据失败 [%s]: %v\n", update.RefName, err)
+	}
+	return nil
+}
+
+// checkSignatures 校验推送引入的新提交（以及附注标签）均带有已验证的签名
+func (s *gitProtocolService) checkSignatures(gitRepo *git.Repository, repo *models.Repository, newHash plumbing.Hash) error {
+	if tag, err := gitRepo.TagObject(newHash); err == nil {
+		if result := s.signingKeys.VerifyTag(tag); !result.Verified {
+			return fmt.Errorf("标签 %s 未通过签名验证: %s", tag.Name, signatureRejectReason(result))
+		}
+		newHash = tag.Target
+	}
+
+	// 仅校验尚未被任何引用包含的提交，历史提交不受新开启的设置影响
+	cmd := exec.Command("git", "rev-list", newHash.String(), "--not", "--all")
+	cmd.Dir = repositoryPath(s.config, repo)
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("计算新提交失败: %w", err)
+	}
+
+	for _, sha := range strings.Fields(string(output)) {
+		commit, err := gitRepo.CommitObject(plumbing.NewHash(sha))
+		if err != nil {
+			return fmt.Errorf("读取提交失败: %w", err)
+		}
+		if result := s.signingKeys.VerifyCommit(commit); !result.Verified {
+			return fmt.Errorf("提交 %s 未通过签名验证: %s", sha[:7], signatureRejectReason(result))
+		}
+	}
+	return nil
+}
+
+// syncRef 将已生效的引用变更同步到分支/标签记录，并记录推送事件、触发Webhook
+func (s *gitProtocolService) syncRef(gitRepo *git.Repository, repo *models.Repository,
+	session *GitSession, update *RefUpdate) error {
+	refType, name := ParseRefName(update.RefName)
+	created := update.OldSHA == ZeroCommitSHA
+	deleted := update.NewSHA == ZeroCommitSHA
+
+	switch refType {
+	case RefTypeBranch:
+		if err := s.syncBranch(repo, name, update.NewSHA, deleted); err != nil {
+			return err
+		}
+		if name == repo.DefaultBranch && !deleted {
+			s.insights.Enqueue(repo.ID)
+			s.codeSearch.Enqueue(repo.ID)
+		}
+	case RefTypeTag:
+		if err := s.syncTag(gitRepo, repo, name, update.NewSHA, deleted); err != nil {
+			return err
+		}
+	}
+
+	var commits []PushCommit
+	var files []string
+	if !deleted {
+		commits = collectPushCommits(gitRepo, update.OldSHA, update.NewSHA, maxPushCommits)
+		// 新建引用没有可比较的原提交，变更文件未知
+		if !created {
+			files = changedFiles(gitRepo, plumbing.NewHash(update.OldSHA), plumbing.NewHash(update.NewSHA))
+		}
+	}
+	s.taskLinks.RecordPush(repo, session, update, commits)
+
+	payload := &PushPayload{
+		Ref:          update.RefName,
+		Before:       update.OldSHA,
+		After:        update.NewSHA,
+		Created:      created,
+		Deleted:      deleted,
+		Forced:       update.Forced,
+		RepositoryID: repo.ID,
+		Repository:   repo.Name,
+		Pusher:       *session,
+		Commits:      commits,
+		ChangedFiles: files,
+		PushedAt:     time.Now(),
+	}
+
+	commitsJSON, _ := json.Marshal(commits)
+	pushEvent := &models.PushEvent{
+		RepositoryID: repo.ID,
+		UserID:       session.UserID,
+		Ref:          update.RefName,
+		Before:       update.OldSHA,
+		After:        update.NewSHA,
+		Forced:       update.Forced,
+		CommitCount:  len(commits),
+		Commits:      commitsJSON,
+		Pusher: models.PusherInfo{
+			Name: session.Username,
+		},
+		CreatedAt: time.Now(),
+	}
+	if err := s.db.Create(pushEvent).Error; err != nil {
+		return fmt.Errorf("记录推送事件失败: %w", err)
+	}
+
+	if err := s.db.Model(&models.Repository{}).Where("id = ?", repo.ID).
+		Update("last_activity_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("更新仓库活动时间失败: %w", err)
+	}
+
+	if err := s.webhookService.TriggerEvent(repo.ID, EventTypePush, payload); err != nil {
+		return err
+	}
+	if refType == RefTypeBranch && !deleted {
+		if err := s.triggerPullRequestSync(gitRepo, repo, session, name, update.NewSHA); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case refType == RefTypeBranch && created:
+		return s.webhookService.TriggerEvent(repo.ID, EventTypeBranchCreate, payload)
+	case refType == RefTypeBranch && deleted:
+		return s.webhookService.TriggerEvent(repo.ID, EventTypeBranchDelete, payload)
+	case refType == RefTypeTag && created:
+		return s.webhookService.TriggerEvent(repo.ID, EventTypeTagCreate, payload)
+	case refType == RefTypeTag && deleted:
+		return s.webhookService.TriggerEvent(repo.ID, EventTypeTagDelete, payload)
+	}
+
+	return nil
+}
+
+// syncBranch 同步分支记录，新分支自动应用保护规则
+func (s *gitProtocolService) syncBranch(repo *models.Repository, name, commitSHA string, deleted bool) error {
+	var branch models.Branch
+	err := s.db.Where("repository_id = ? AND name = ?", repo.ID, name).First(&branch).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return fmt.Errorf("获取分支失败: %w", err)
+	}
+	exists := err == nil
+
+	switch {
+	case deleted:
+		if !exists {
+			return nil
+		}
+		return s.db.Delete(&branch).Error
+	case exists:
+		return s.db.Model(&branch).Updates(map[string]interface{}{
+			"commit_sha": commitSHA,
+			"updated_at": time.Now(),
+		}).Error
+	default:
+		branch = models.Branch{
+			RepositoryID: repo.ID,
+			Name:         name,
+			CommitSHA:    commitSHA,
+			IsDefault:    name == repo.DefaultBranch,
+		}
+		if err := s.protectionRules.ApplyToBranch(&branch); err != nil {
+			return err
+		}
+		return s.db.Create(&branch).Error
+	}
+}
+
+// syncTag 同步标签记录
+func (s *gitProtocolService) syncTag(gitRepo *git.Repository, repo *models.Repository, name, sha string, deleted bool) error {
+	if deleted {
+		return s.db.Where("repository_id = ? AND name = ?", repo.ID, name).Delete(&models.Tag{}).Error
+	}
+
+	tag := models.Tag{
+		RepositoryID: repo.ID,
+		Name:         name,
+		CommitSHA:    sha,
+		CreatedAt:    time.Now(),
+	}
+
+	// 附注标签记录说明和打标签者
+	if tagObj, err := gitRepo.TagObject(plumbing.NewHash(sha)); err == nil {
+		message := strings.TrimSpace(tagObj.Message)
+		tag.CommitSHA = tagObj.Target.String()
+		tag.Message = &message
+		tag.TaggerName = &tagObj.Tagger.Name
+		tag.TaggerEmail = &tagObj.Tagger.Email
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("repository_id = ? AND name = ?", repo.ID, name).
+			Delete(&models.Tag{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&tag).Error
+	})
+}
+
+// recordUpload 记录克隆/拉取操作审计，客户端未声明任何已有提交时视为克隆
+func (s *gitProtocolService) recordUpload(repo *models.Repository, session *GitSession, request *uploadPackRequest,
+	bytesTransferred int64, start time.Time, uploadErr error) {
+	operation := OperationFetch
+	if request.haves == 0 {
+		operation = OperationClone
+	}
+
+	req := &RecordOperationRequest{
+		RepositoryID:     repo.ID,
+		UserID:           session.UserID,
+		Operation:        operation,
+		Protocol:         session.Protocol,
+		ClientIP:         session.ClientIP,
+		Success:          uploadErr == nil,
+		Duration:         int(time.Since(start).Milliseconds()),
+		BytesTransferred: bytesTransferred,
+		AuthMethod:       session.AuthMethod,
+		CredentialID:     session.CredentialID,
+	}
+	if session.UserAgent != "" {
+		req.UserAgent = &session.UserAgent
+	}
+	if uploadErr != nil {
+		msg := uploadErr.Error()
+		req.ErrorMsg = &msg
+	}
+
+	if _, err := s.gitOpService.RecordOperation(req); err != nil {
+		fmt.Printf("记录拉取操作失败: %v\n", err)
+	}
+}
+
+// recordPush 记录推送操作审计
+func (s *gitProtocolService) recordPush(repo *models.Repository, session *GitSession, applied []*RefUpdate,
+	bytesTransferred int64, start time.Time, pushErr error) {
+	req := &RecordOperationRequest{
+		RepositoryID:     repo.ID,
+		UserID:           session.UserID,
+		Operation:        OperationPush,
+		Protocol:         session.Protocol,
+		ClientIP:         session.ClientIP,
+		Success:          pushErr == nil,
+		Duration:         int(time.Since(start).Milliseconds()),
+		BytesTransferred: bytesTransferred,
+		AuthMethod:       session.AuthMethod,
+		CredentialID:     session.CredentialID,
+	}
+	if session.UserAgent != "" {
+		req.UserAgent = &session.UserAgent
+	}
+	if len(applied) > 0 {
+		req.RefName = &applied[0].RefName
+		req.CommitSHA = &applied[0].NewSHA
+	}
+	if pushErr != nil {
+		msg := pushErr.Error()
+		req.ErrorMsg = &msg
+	}
+
+	if _, err := s.gitOpService.RecordOperation(req); err != nil {
+		fmt.Printf("记录推送操作失败: %v\n", err)
+	}
+}
+
+// writeReport 客户端协商了report-status时返回各引用的处理结果
+func (s *gitProtocolService) writeReport(req *packp.ReferenceUpdateRequest, report *packp.ReportStatus, w io.Writer) {
+	if !req.Capabilities.Supports(capability.ReportStatus) {
+		return
+	}
+	if err := report.Encode(w); err != nil {
+		fmt.Printf("写入推送结果失败: %v\n", err)
+	}
+}
+
+// receivePackAdvRefs 构造receive-pack的引用通告
+func (s *gitProtocolService) receivePackAdvRefs(gitRepo *git.Repository) (*packp.AdvRefs, error) {
+	ar := packp.NewAdvRefs()
+
+	caps := ar.Capabilities
+	for _, c := range []capability.Capability{
+		capability.ReportStatus,
+		capability.DeleteRefs,
+		capability.OFSDelta,
+		capability.Capability("no-thin"), // 要求客户端发送完整packfile
+	} {
+		if err := caps.Set(c); err != nil {
+			return nil, err
+		}
+	}
+	if err := caps.Set(capability.Agent, capability.DefaultAgent()); err != nil {
+		return nil, err
+	}
+
+	refs, err := gitRepo.References()
+	if err != nil {
+		return nil, fmt.Errorf("获取引用列表失败: %w", err)
+	}
+	defer refs.Close()
+
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+		ar.References[ref.Name().String()] = ref.Hash()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取引用列表失败: %w", err)
+	}
+
+	return ar, nil
+}
+
+// triggerPullRequestSync 源分支有新推送时，为其打开的合并请求投递 synchronize 事件
+func (s *gitProtocolService) triggerPullRequestSync(gitRepo *git.Repository, repo *models.Repository,
+	session *GitSession, branch, headSHA string) error {
+	var pullRequests []models.PullRequest
+	if err := s.db.Where("repository_id = ? AND source_branch = ? AND status IN ?",
+		repo.ID, branch, []string{"open", "draft"}).Find(&pullRequests).Error; err != nil {
+		return fmt.Errorf("获取合并请求失败: %w", err)
+	}
+
+	for _, pr := range pullRequests {
+		payload := &PullRequestPayload{
+			Action:        "synchronize",
+			RepositoryID:  repo.ID,
+			Repository:    repo.Name,
+			PullRequestID: pr.ID,
+			Number:        pr.PRNumber,
+			Title:         pr.Title,
+			SourceBranch:  pr.SourceBranch,
+			TargetBranch:  pr.TargetBranch,
+			HeadSHA:       headSHA,
+			Sender:        *session,
+			UpdatedAt:     time.Now(),
+		}
+
+		// 变更文件为目标分支与源分支的合并基础到head的差异
+		if target, err := gitRepo.Reference(plumbing.NewBranchReferenceName(pr.TargetBranch), true); err == nil {
+			payload.BaseSHA = target.Hash().String()
+			if base := mergeBase(gitRepo, target.Hash(), plumbing.NewHash(headSHA)); !base.IsZero() {
+				payload.ChangedFiles = changedFiles(gitRepo, base, plumbing.NewHash(headSHA))
+			}
+		}
+
+		if err := s.webhookService.TriggerEvent(repo.ID, EventTypePullRequest, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeBase 计算两个提交的合并基础，不存在时返回零值
+func mergeBase(gitRepo *git.Repository, a, b plumbing.Hash) plumbing.Hash {
+	first, err := gitRepo.CommitObject(a)
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+	second, err := gitRepo.CommitObject(b)
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+	bases, err := first.MergeBase(second)
+	if err != nil || len(bases) == 0 {
+		return plumbing.ZeroHash
+	}
+	return bases[0].Hash
+}
+
+// isFastForward 判断从old到new是否为快进更新
+func isFastForward(gitRepo *git.Repository, oldHash, newHash plumbing.Hash) (bool, error) {
+	newCommit, err := gitRepo.CommitObject(newHash)
+	if err != nil {
+		return false, fmt.Errorf("读取提交失败: %w", err)
+	}
+
+	oldCommit, err := gitRepo.CommitObject(oldHash)
+	if err != nil {
+		// 原提交不可读时按非快进处理
+		return false, nil
+	}
+
+	return oldCommit.IsAncestor(newCommit)
+}
+
+// collectPushCommits 收集推送引入的提交（从new回溯到old为止，最多limit条）
+func collectPushCommits(gitRepo *git.Repository, oldSHA, newSHA string, limit int) []PushCommit {
+	iter, err := gitRepo.Log(&git.LogOptions{From: plumbing.NewHash(newSHA)})
+	if err != nil {
+		return nil
+	}
+	defer iter.Close()
+
+	stop := plumbing.NewHash(oldSHA)
+	var commits []PushCommit
+	_ = iter.ForEach(func(c *object.Commit) error {
+		if c.Hash == stop || len(commits) >= limit {
+			return storer.ErrStop
+		}
+		commits = append(commits, newPushCommit(c))
+		return nil
+	})
+
+	return commits
+}
+
+// newPushCommit 转换提交对象
+func newPushCommit(c *object.Commit) PushCommit {
+	commit := PushCommit{
+		ID:        c.Hash.String(),
+		Message:   c.Message,
+		Timestamp: c.Committer.When,
+	}
+	commit.Author.Name = c.Author.Name
+	commit.Author.Email = c.Author.Email
+
+	// 根提交的变更为全部文件
+	parentTree := &object.Tree{}
+	if c.NumParents() > 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return commit
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return commit
+		}
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return commit
+	}
+	changes, err := object.DiffTree(parentTree, tree)
+	if err != nil || len(changes) > maxChangedFiles {
+		return commit
+	}
+	commit.Added, commit.Modified, commit.Removed = []string{}, []string{}, []string{}
+	for _, change := range changes {
+		action, err := change.Action()
+		if err != nil {
+			continue
+		}
+		switch action {
+		case merkletrie.Insert:
+			commit.Added = append(commit.Added, change.To.Name)
+		case merkletrie.Delete:
+			commit.Removed = append(commit.Removed, change.From.Name)
+		default:
+			commit.Modified = append(commit.Modified, change.To.Name)
+		}
+	}
+	return commit
+}
+
+// changedFiles 计算两个提交之间的变更文件，无法计算或超过 maxChangedFiles 时返回nil
+func changedFiles(gitRepo *git.Repository, fromHash, toHash plumbing.Hash) []string {
+	from, err := gitRepo.CommitObject(fromHash)
+	if err != nil {
+		return nil
+	}
+	to, err := gitRepo.CommitObject(toHash)
+	if err != nil {
+		return nil
+	}
+	fromTree, err := from.Tree()
+	if err != nil {
+		return nil
+	}
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil
+	}
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil || len(changes) > maxChangedFiles {
+		return nil
+	}
+
+	files := make([]string, 0, len(changes))
+	for _, change := range changes {
+		name := change.To.Name
+		if name == "" {
+			name = change.From.Name
+		}
+		files = append(files, name)
+	}
+	return files
+}
+
+// signatureRejectReason 拒绝推送时展示的签名状态说明
+func signatureRejectReason(result *SignatureVerification) string {
+	if result.Reason != "" {
+		return result.Reason
+	}
+	return result.Status
+}
+
+// hasNonDeleteCommand 推送是否包含需要对象数据的命令
+func hasNonDeleteCommand(commands []*packp.Command) bool {
+	for _, cmd := range commands {
+		if cmd.Action() != packp.Delete {
+			return true
+		}
+	}
+	return false
+}
+
+// countingReader 统计读取字节数
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingWriter 统计写入字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// uploadPackRequest 在转发upload-pack请求的同时解析pkt-line，统计want/have并识别协商结束的done
+type uploadPackRequest struct {
+	r         io.Reader
+	header    []byte // 当前pkt-line的长度头
+	remaining int    // 当前pkt-line剩余载荷字节数
+	prefix    []byte // 当前pkt-line载荷的前5个字节
+	wants     int
+	haves     int
+	done      bool
+}
+
+func (u *uploadPackRequest) Read(p []byte) (int, error) {
+	n, err := u.r.Read(p)
+	for _, b := range p[:n] {
+		u.feed(b)
+	}
+	return n, err
+}
+
+func (u *uploadPackRequest) feed(b byte) {
+	if u.remaining == 0 {
+		u.header = append(u.header, b)
+		if len(u.header) < 4 {
+			return
+		}
+		var length int
+		fmt.Sscanf(string(u.header), "%04x", &length)
+		u.header = u.header[:0]
+		u.prefix = u.prefix[:0]
+		if length > 4 {
+			u.remaining = length - 4
+		}
+		return
+	}
+
+	if len(u.prefix) < 5 {
+		u.prefix = append(u.prefix, b)
+	}
+	u.remaining--
+	if u.remaining > 0 {
+		return
+	}
+
+	switch {
+	case string(u.prefix) == "want ":
+		u.wants++
+	case string(u.prefix) == "have ":
+		u.haves++
+	case strings.HasPrefix(string(u.prefix), "done"):
+		u.done = true
+	}
+}
\ No newline at end of file
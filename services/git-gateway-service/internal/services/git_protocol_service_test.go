@@ -0,0 +1,95 @@
+package services
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// commitFiles 在工作区写入/删除文件后提交，content 为nil表示删除
+func commitFiles(t *testing.T, repo *git.Repository, files map[string][]byte) plumbing.Hash {
+	t.Helper()
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("worktree: %v", err)
+	}
+	for name, content := range files {
+		if content == nil {
+			if _, err := worktree.Remove(name); err != nil {
+				t.Fatalf("remove %s: %v", name, err)
+			}
+			continue
+		}
+		f, err := worktree.Filesystem.Create(name)
+		if err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		f.Write(content)
+		f.Close()
+		if _, err := worktree.Add(name); err != nil {
+			t.Fatalf("add %s: %v", name, err)
+		}
+	}
+	hash, err := worktree.Commit("change", &git.CommitOptions{
+		Author: &object.Signature{Name: "dev", Email: "dev@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	return hash
+}
+
+func TestPushChangedFiles(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	root := commitFiles(t, repo, map[string][]byte{"README.md": []byte("a"), "src/main.go": []byte("package main")})
+	second := commitFiles(t, repo, map[string][]byte{"src/main.go": []byte("package main\n"), "docs/guide.md": []byte("g")})
+	third := commitFiles(t, repo, map[string][]byte{"README.md": nil})
+
+	tests := []struct {
+		name                     string
+		commit                   plumbing.Hash
+		added, modified, removed []string
+	}{
+		{"root commit adds all files", root, []string{"README.md", "src/main.go"}, []string{}, []string{}},
+		{"modify and add", second, []string{"docs/guide.md"}, []string{"src/main.go"}, []string{}},
+		{"remove", third, []string{}, []string{}, []string{"README.md"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := repo.CommitObject(tt.commit)
+			if err != nil {
+				t.Fatalf("commit object: %v", err)
+			}
+			commit := newPushCommit(c)
+			sort.Strings(commit.Added)
+			if !reflect.DeepEqual(commit.Added, tt.added) || !reflect.DeepEqual(commit.Modified, tt.modified) ||
+				!reflect.DeepEqual(commit.Removed, tt.removed) {
+				t.Fatalf("got added=%q modified=%q removed=%q, want %q %q %q",
+					commit.Added, commit.Modified, commit.Removed, tt.added, tt.modified, tt.removed)
+			}
+		})
+	}
+
+	files := changedFiles(repo, root, third)
+	sort.Strings(files)
+	if want := []string{"README.md", "docs/guide.md", "src/main.go"}; !reflect.DeepEqual(files, want) {
+		t.Fatalf("changedFiles = %q, want %q", files, want)
+	}
+	if files := changedFiles(repo, plumbing.NewHash(ZeroCommitSHA), third); files != nil {
+		t.Fatalf("changedFiles from unknown commit = %q, want nil", files)
+	}
+	if base := mergeBase(repo, third, second); base != second {
+		t.Fatalf("mergeBase = %s, want %s", base, second)
+	}
+}
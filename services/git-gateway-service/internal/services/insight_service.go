@@ -0,0 +1,679 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"git-gateway-service/internal/config"
+	"git-gateway-service/internal/models"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// InsightService 仓库分析服务接口（语言构成、主题、贡献者、提交频率）
+type InsightService interface {
+	Enqueue(repositoryID uuid.UUID)
+	Analyze(repositoryID uuid.UUID) (*models.RepositoryInsight, error)
+	GetByRepository(repositoryID uuid.UUID) (*models.RepositoryInsight, error)
+}
+
+type insightService struct {
+	db      *gorm.DB
+	config  *config.Config
+	storage StorageService
+	queue   *repositoryQueue
+}
+
+// NewInsightService 创建仓库分析服务实例，并启动后台分析协程
+func NewInsightService(db *gorm.DB, cfg *config.Config, storage StorageService) InsightService {
+	s := &insightService{
+		db:      db,
+		config:  cfg,
+		storage: storage,
+	}
+	s.queue = newRepositoryQueue("仓库分析", insightQueueSize, func(repositoryID uuid.UUID) error {
+		_, err := s.Analyze(repositoryID)
+		return err
+	})
+	return s
+}
+
+const (
+	// insightQueueSize 待分析仓库队列长度
+	insightQueueSize = 256
+	// maxInsightCommits 单次分析遍历的最大提交数
+	maxInsightCommits = 10000
+	// maxCodeFrequencyCommits 计算增删行数的最大提交数（需要逐个计算差异）
+	maxCodeFrequencyCommits = 2000
+	// languageSniffSize 判断二进制/生成文件时读取的文件头长度
+	languageSniffSize = 8000
+	// maxDetectedTopics 自动识别的最大主题数
+	maxDetectedTopics = 10
+	// topicLanguageShare 语言成为主题所需的最低字节占比（百分比）
+	topicLanguageShare = 10
+)
+
+// LanguageStat 语言统计
+type LanguageStat struct {
+	Name       string  `json:"name"`
+	Bytes      int64   `json:"bytes"`
+	Percentage float64 `json:"percentage"`
+}
+
+// ContributorStat 贡献者统计
+type ContributorStat struct {
+	Name          string    `json:"name"`
+	Email         string    `json:"email"`
+	Commits       int       `json:"commits"`
+	Additions     int       `json:"additions"`
+	Deletions     int       `json:"deletions"`
+	FirstCommitAt time.Time `json:"first_commit_at"`
+	LastCommitAt  time.Time `json:"last_commit_at"`
+}
+
+// WeeklyCommitActivity 每周提交数
+type WeeklyCommitActivity struct {
+	Week    time.Time `json:"week"`
+	Commits int       `json:"commits"`
+}
+
+// WeeklyCodeFrequency 每周新增/删除行数
+type WeeklyCodeFrequency struct {
+	Week      time.Time `json:"week"`
+	Additions int       `json:"additions"`
+	Deletions int       `json:"deletions"`
+}
+
+// Enqueue 将仓库加入后台分析队列
+func (s *insightService) Enqueue(repositoryID uuid.UUID) {
+	s.queue.Enqueue(repositoryID)
+}
+
+// GetByRepository 获取仓库最近一次分析结果
+func (s *insightService) GetByRepository(repositoryID uuid.UUID) (*models.RepositoryInsight, error) {
+	var insight models.RepositoryInsight
+	if err := s.db.Where("repository_id = ?", repositoryID).First(&insight).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("仓库尚未分析")
+		}
+		return nil, fmt.Errorf("获取仓库分析结果失败: %w", err)
+	}
+	return &insight, nil
+}
+
+// Analyze 分析仓库默认分支并保存结果
+func (s *insightService) Analyze(repositoryID uuid.UUID) (*models.RepositoryInsight, error) {
+	var repo models.Repository
+	if err := s.db.Where("id = ? AND deleted_at IS NULL", repositoryID).First(&repo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("仓库不存在")
+		}
+		return nil, fmt.Errorf("获取仓库失败: %w", err)
+	}
+
+	gitRepo, release, err := s.storage.Open(&repo)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ref, err := gitRepo.Reference(plumbing.NewBranchReferenceName(repo.DefaultBranch), true)
+	if err != nil {
+		return nil, fmt.Errorf("默认分支不存在: %w", err)
+	}
+
+	head, err := gitRepo.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("读取提交失败: %w", err)
+	}
+
+	languages, err := analyzeLanguages(head)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := analyzeHistory(gitRepo, head.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	// 历史统计只遍历最近的提交，总提交数单独计算
+	commitCount, err := countCommits(repositoryPath(s.config, &repo), head.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	topics, err := detectTopics(head, languages)
+	if err != nil {
+		return nil, err
+	}
+
+	languagesJSON, _ := json.Marshal(languages)
+	contributorsJSON, _ := json.Marshal(history.contributors)
+	activityJSON, _ := json.Marshal(history.commitActivity)
+	frequencyJSON, _ := json.Marshal(history.codeFrequency)
+	topicsJSON, _ := json.Marshal(topics)
+
+	now := time.Now()
+	insight := &models.RepositoryInsight{}
+	err = s.db.Where("repository_id = ?", repo.ID).First(insight).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("获取仓库分析结果失败: %w", err)
+	}
+
+	var previousTopics []string
+	_ = json.Unmarshal(insight.Topics, &previousTopics)
+
+	insight.RepositoryID = repo.ID
+	insight.CommitSHA = head.Hash.String()
+	insight.Languages = datatypes.JSON(languagesJSON)
+	insight.Contributors = datatypes.JSON(contributorsJSON)
+	insight.CommitActivity = datatypes.JSON(activityJSON)
+	insight.CodeFrequency = datatypes.JSON(frequencyJSON)
+	insight.Topics = datatypes.JSON(topicsJSON)
+	insight.Truncated = commitCount > history.commitCount
+	insight.AnalyzedAt = now
+	if err := s.db.Save(insight).Error; err != nil {
+		return nil, fmt.Errorf("保存仓库分析结果失败: %w", err)
+	}
+
+	updates := map[string]interface{}{
+		"commit_count": commitCount,
+		"updated_at":   now,
+	}
+	if len(languages) > 0 {
+		updates["language"] = languages[0].Name
+	}
+	// 仓库主题为空或仍是上次自动识别的结果时才覆盖，保留用户手动设置的主题
+	var currentTopics []string
+	_ = json.Unmarshal(repo.Topics, &currentTopics)
+	if len(currentTopics) == 0 || sameTopics(currentTopics, previousTopics) {
+		updates["topics"] = datatypes.JSON(topicsJSON)
+	}
+	if err := s.db.Model(&repo).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("更新仓库语言失败: %w", err)
+	}
+
+	return insight, nil
+}
+
+// analyzeLanguages 按字节统计默认分支的语言构成，排除第三方、生成和二进制文件
+func analyzeLanguages(head *object.Commit) ([]LanguageStat, error) {
+	tree, err := head.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("读取目录失败: %w", err)
+	}
+
+	attributes := loadLinguistAttributes(tree)
+	totals := make(map[string]int64)
+	var total int64
+
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if !f.Mode.IsFile() || isVendoredPath(f.Name) || attributes.excluded(f.Name) {
+			return nil
+		}
+
+		language := detectLanguage(f.Name)
+		if language == "" {
+			return nil
+		}
+
+		excluded, err := isGeneratedOrBinary(f)
+		if err != nil || excluded {
+			return nil
+		}
+
+		totals[language] += f.Size
+		total += f.Size
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("统计语言失败: %w", err)
+	}
+
+	languages := make([]LanguageStat, 0, len(totals))
+	for name, size := range totals {
+		languages = append(languages, LanguageStat{
+			Name:       name,
+			Bytes:      size,
+			Percentage: float64(int64(float64(size)*10000/float64(total))) / 100,
+		})
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		if languages[i].Bytes != languages[j].Bytes {
+			return languages[i].Bytes > languages[j].Bytes
+		}
+		return languages[i].Name < languages[j].Name
+	})
+	return languages, nil
+}
+
+// countCommits 统计从head可达的提交总数
+func countCommits(repoPath string, head plumbing.Hash) (int, error) {
+	cmd := exec.Command("git", "rev-list", "--count", head.String())
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("统计提交数失败: %w", err)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil {
+		return 0, fmt.Errorf("统计提交数失败: %w", err)
+	}
+	return count, nil
+}
+
+// historyStats 提交历史统计
+type historyStats struct {
+	commitCount    int
+	contributors   []ContributorStat
+	commitActivity []WeeklyCommitActivity
+	codeFrequency  []WeeklyCodeFrequency
+}
+
+// analyzeHistory 统计贡献者、每周提交数与每周增删行数
+func analyzeHistory(gitRepo *git.Repository, head plumbing.Hash) (*historyStats, error) {
+	iter, err := gitRepo.Log(&git.LogOptions{From: head})
+	if err != nil {
+		return nil, fmt.Errorf("读取提交历史失败: %w", err)
+	}
+	defer iter.Close()
+
+	stats := &historyStats{}
+	contributors := make(map[string]*ContributorStat)
+	activity := make(map[time.Time]*WeeklyCommitActivity)
+	frequency := make(map[time.Time]*WeeklyCodeFrequency)
+
+	err = iter.ForEach(func(c *object.Commit) error {
+		if stats.commitCount >= maxInsightCommits {
+			return storer.ErrStop
+		}
+		stats.commitCount++
+
+		key := strings.ToLower(c.Author.Email)
+		contributor, ok := contributors[key]
+		if !ok {
+			contributor = &ContributorStat{
+				Name:          c.Author.Name,
+				Email:         c.Author.Email,
+				FirstCommitAt: c.Author.When,
+				LastCommitAt:  c.Author.When,
+			}
+			contributors[key] = contributor
+		}
+		contributor.Commits++
+		if c.Author.When.Before(contributor.FirstCommitAt) {
+			contributor.FirstCommitAt = c.Author.When
+		}
+		if c.Author.When.After(contributor.LastCommitAt) {
+			contributor.LastCommitAt = c.Author.When
+		}
+
+		week := weekStart(c.Author.When)
+		if activity[week] == nil {
+			activity[week] = &WeeklyCommitActivity{Week: week}
+		}
+		activity[week].Commits++
+
+		// 合并提交的差异已计入被合并的提交
+		if c.NumParents() > 1 || stats.commitCount > maxCodeFrequencyCommits {
+			return nil
+		}
+
+		fileStats, err := c.Stats()
+		if err != nil {
+			return nil
+		}
+		if frequency[week] == nil {
+			frequency[week] = &WeeklyCodeFrequency{Week: week}
+		}
+		for _, fs := range fileStats {
+			contributor.Additions += fs.Addition
+			contributor.Deletions += fs.Deletion
+			frequency[week].Additions += fs.Addition
+			frequency[week].Deletions += fs.Deletion
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取提交历史失败: %w", err)
+	}
+
+	for _, contributor := range contributors {
+		stats.contributors = append(stats.contributors, *contributor)
+	}
+	sort.Slice(stats.contributors, func(i, j int) bool {
+		if stats.contributors[i].Commits != stats.contributors[j].Commits {
+			return stats.contributors[i].Commits > stats.contributors[j].Commits
+		}
+		return stats.contributors[i].Email < stats.contributors[j].Email
+	})
+
+	for _, week := range activity {
+		stats.commitActivity = append(stats.commitActivity, *week)
+	}
+	sort.Slice(stats.commitActivity, func(i, j int) bool {
+		return stats.commitActivity[i].Week.Before(stats.commitActivity[j].Week)
+	})
+
+	for _, week := range frequency {
+		stats.codeFrequency = append(stats.codeFrequency, *week)
+	}
+	sort.Slice(stats.codeFrequency, func(i, j int) bool {
+		return stats.codeFrequency[i].Week.Before(stats.codeFrequency[j].Week)
+	})
+
+	return stats, nil
+}
+
+// weekStart 返回时间所在周的周一零点（UTC）
+func weekStart(t time.Time) time.Time {
+	t = t.UTC()
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
+
+// vendoredPathPattern 第三方依赖与构建产物目录
+var vendoredPathPattern = regexp.MustCompile(`(^|/)(vendor|node_modules|bower_components|third_party|Godeps|dist|\.yarn)/`)
+
+// generatedFilePattern 常见的生成文件命名
+var generatedFilePattern = regexp.MustCompile(`(\.pb\.go|_pb2\.py|\.pb\.(cc|h)|_generated\.go|zz_generated\..*\.go|\.min\.(js|css)|-lock\.json|\.lock)$`)
+
+// generatedHeaderPattern 生成文件头部标记
+var generatedHeaderPattern = regexp.MustCompile(`(?i)(code generated .* do not edit|@generated|autogenerated file)`)
+
+// isVendoredPath 判断文件是否属于第三方依赖或生成文件
+func isVendoredPath(name string) bool {
+	return vendoredPathPattern.MatchString(name) || generatedFilePattern.MatchString(name)
+}
+
+// isGeneratedOrBinary 根据文件头判断是否为生成文件或二进制文件
+func isGeneratedOrBinary(f *object.File) (bool, error) {
+	reader, err := f.Reader()
+	if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+
+	head := make([]byte, languageSniffSize)
+	n, err := io.ReadFull(reader, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	head = head[:n]
+
+	if bytes.IndexByte(head, 0) >= 0 {
+		return true, nil
+	}
+
+	// 仅检查前几行，避免误判普通代码中的字符串
+	lines := bytes.SplitN(head, []byte("\n"), 6)
+	for _, line := range lines[:min(len(lines), 5)] {
+		if generatedHeaderPattern.Match(line) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// linguistAttributes .gitattributes 中的 linguist-vendored / linguist-generated 规则
+type linguistAttributes []linguistRule
+
+type linguistRule struct {
+	pattern  *regexp.Regexp
+	basename bool
+	excluded bool
+}
+
+// loadLinguistAttributes 读取根目录 .gitattributes
+func loadLinguistAttributes(tree *object.Tree) linguistAttributes {
+	file, err := tree.File(".gitattributes")
+	if err != nil {
+		return nil
+	}
+	content, err := file.Contents()
+	if err != nil {
+		return nil
+	}
+
+	var rules linguistAttributes
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		for _, attr := range fields[1:] {
+			name, value := strings.TrimPrefix(attr, "-"), !strings.HasPrefix(attr, "-")
+			if i := strings.Index(name, "="); i >= 0 {
+				value = name[i+1:] == "true"
+				name = name[:i]
+			}
+			if name != "linguist-vendored" && name != "linguist-generated" && name != "linguist-documentation" {
+				continue
+			}
+
+			// 以 / 结尾的模式匹配整个目录
+			pattern := strings.TrimPrefix(fields[0], "/")
+			basename := !strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+			if strings.HasSuffix(pattern, "/") {
+				pattern += "**"
+			}
+			re, err := compileRefPattern(pattern)
+			if err != nil {
+				continue
+			}
+			rules = append(rules, linguistRule{
+				pattern:  re,
+				basename: basename,
+				excluded: value,
+			})
+		}
+	}
+	return rules
+}
+
+// excluded 判断文件是否被 .gitattributes 排除，后出现的规则优先
+func (a linguistAttributes) excluded(name string) bool {
+	excluded := false
+	for _, rule := range a {
+		target := name
+		if rule.basename {
+			target = path.Base(name)
+		}
+		if rule.pattern.MatchString(target) || rule.pattern.MatchString(name) {
+			excluded = rule.excluded
+		}
+	}
+	return excluded
+}
+
+// languageFilenames 按文件名识别的语言
+var languageFilenames = map[string]string{
+	"Dockerfile":  "Dockerfile",
+	"Makefile":    "Makefile",
+	"makefile":    "Makefile",
+	"GNUmakefile": "Makefile",
+	"Jenkinsfile": "Groovy",
+	"Rakefile":    "Ruby",
+	"Gemfile":     "Ruby",
+}
+
+// languageExtensions 按扩展名识别的语言（不含数据/文档类格式）
+var languageExtensions = map[string]string{
+	".go":     "Go",
+	".py":     "Python",
+	".js":     "JavaScript",
+	".mjs":    "JavaScript",
+	".cjs":    "JavaScript",
+	".jsx":    "JavaScript",
+	".ts":     "TypeScript",
+	".tsx":    "TypeScript",
+	".vue":    "Vue",
+	".svelte": "Svelte",
+	".java":   "Java",
+	".kt":     "Kotlin",
+	".kts":    "Kotlin",
+	".scala":  "Scala",
+	".groovy": "Groovy",
+	".c":      "C",
+	".h":      "C",
+	".cc":     "C++",
+	".cpp":    "C++",
+	".cxx":    "C++",
+	".hpp":    "C++",
+	".cs":     "C#",
+	".rs":     "Rust",
+	".rb":     "Ruby",
+	".php":    "PHP",
+	".swift":  "Swift",
+	".m":      "Objective-C",
+	".dart":   "Dart",
+	".lua":    "Lua",
+	".pl":     "Perl",
+	".r":      "R",
+	".ex":     "Elixir",
+	".exs":    "Elixir",
+	".erl":    "Erlang",
+	".hs":     "Haskell",
+	".clj":    "Clojure",
+	".sh":     "Shell",
+	".bash":   "Shell",
+	".zsh":    "Shell",
+	".ps1":    "PowerShell",
+	".sql":    "SQL",
+	".html":   "HTML",
+	".htm":    "HTML",
+	".css":    "CSS",
+	".scss":   "SCSS",
+	".sass":   "Sass",
+	".less":   "Less",
+	".proto":  "Protocol Buffer",
+	".tf":     "HCL",
+	".hcl":    "HCL",
+}
+
+// detectLanguage 根据文件名识别语言，无法识别时返回空字符串
+func detectLanguage(name string) string {
+	base := path.Base(name)
+	if language, ok := languageFilenames[base]; ok {
+		return language
+	}
+	if strings.HasPrefix(base, "Dockerfile.") {
+		return "Dockerfile"
+	}
+	return languageExtensions[strings.ToLower(path.Ext(base))]
+}
+
+// topicMarkers 根目录下标识技术栈的文件或目录
+var topicMarkers = map[string]string{
+	"Dockerfile":          "docker",
+	"docker-compose.yml":  "docker-compose",
+	"docker-compose.yaml": "docker-compose",
+	"compose.yaml":        "docker-compose",
+	"Chart.yaml":          "helm",
+	"package.json":        "nodejs",
+	"pom.xml":             "maven",
+	"build.gradle":        "gradle",
+	"build.gradle.kts":    "gradle",
+	"Cargo.toml":          "cargo",
+	"pyproject.toml":      "python-package",
+	"setup.py":            "python-package",
+	"Gemfile":             "bundler",
+	"composer.json":       "composer",
+	".axiom-ci.yml":       "ci",
+	".github":             "github-actions",
+	"kustomization.yaml":  "kubernetes",
+}
+
+// topicLanguageNames 无法直接作为主题的语言名称
+var topicLanguageNames = map[string]string{
+	"C++":             "cpp",
+	"C#":              "csharp",
+	"Dockerfile":      "docker",
+	"Objective-C":     "objective-c",
+	"Protocol Buffer": "protobuf",
+}
+
+// detectTopics 根据主要语言和根目录的标识文件识别主题
+func detectTopics(head *object.Commit, languages []LanguageStat) ([]string, error) {
+	tree, err := head.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("读取目录失败: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	topics := make([]string, 0, maxDetectedTopics)
+	add := func(topic string) {
+		if topic != "" && !seen[topic] && len(topics) < maxDetectedTopics {
+			seen[topic] = true
+			topics = append(topics, topic)
+		}
+	}
+
+	for _, language := range languages {
+		if language.Percentage < topicLanguageShare {
+			continue
+		}
+		add(languageTopic(language.Name))
+	}
+
+	markers := make([]string, 0)
+	for _, entry := range tree.Entries {
+		if topic, ok := topicMarkers[entry.Name]; ok {
+			if entry.Name == ".github" {
+				if _, err := tree.Tree(".github/workflows"); err != nil {
+					continue
+				}
+			}
+			markers = append(markers, topic)
+		}
+	}
+	sort.Strings(markers)
+	for _, topic := range markers {
+		add(topic)
+	}
+
+	return topics, nil
+}
+
+// languageTopic 将语言名称转换为主题（小写、空格替换为连字符）
+func languageTopic(name string) string {
+	if topic, ok := topicLanguageNames[name]; ok {
+		return topic
+	}
+	return strings.ReplaceAll(strings.ToLower(name), " ", "-")
+}
+
+// sameTopics 判断两组主题是否相同（忽略顺序）
+func sameTopics(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[string]int, len(a))
+	for _, topic := range a {
+		counts[topic]++
+	}
+	for _, topic := range b {
+		if counts[topic] == 0 {
+			return false
+		}
+		counts[topic]--
+	}
+	return true
+}
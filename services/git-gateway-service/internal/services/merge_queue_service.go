@@ -0,0 +1,953 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"git-gateway-service/internal/config"
+	"git-gateway-service/internal/models"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MergeQueueService 合并队列服务接口
+//
+// 队列中的条目按批次验证：每个条目在前序条目的推测合并结果之上再合并，
+// 写入 refs/merge-queue/<id> 并由CI/CD服务运行流水线。某条目验证通过时，
+// 目标分支快进到该条目的推测合并提交（同时合并其前序条目）；验证失败的条目被移出队列，
+// 其后的条目重新排队验证。只有关联了开放合并请求的条目才能入队；
+// 合并前重新确认源分支未在验证后更新，否则条目重新排队验证。
+type MergeQueueService interface {
+	Enqueue(req *EnqueueMergeRequest) (*MergeQueueEntryInfo, error)
+	GetByID(id uuid.UUID) (*MergeQueueEntryInfo, error)
+	List(repositoryID uuid.UUID, targetBranch string) ([]MergeQueueEntryInfo, error)
+	Dequeue(id, userID uuid.UUID) error
+}
+
+type mergeQueueService struct {
+	db          *gorm.DB
+	config      *config.Config
+	cicd        CICDClient
+	gitProtocol GitProtocolService
+	storage     StorageService
+	repos       RepositoryService
+	taskLinks   TaskLinkService
+	mu          sync.Mutex // 串行化队列状态变更，持锁期间不请求CI/CD服务
+	wake        chan struct{}
+}
+
+// NewMergeQueueService 创建合并队列服务实例并启动后台处理
+func NewMergeQueueService(db *gorm.DB, cfg *config.Config, cicd CICDClient, gitProtocol GitProtocolService,
+	storage StorageService, repos RepositoryService, taskLinks TaskLinkService) MergeQueueService {
+	s := &mergeQueueService{
+		db:          db,
+		config:      cfg,
+		cicd:        cicd,
+		gitProtocol: gitProtocol,
+		storage:     storage,
+		repos:       repos,
+		taskLinks:   taskLinks,
+		wake:        make(chan struct{}, 1),
+	}
+	go s.run()
+	return s
+}
+
+// 合并队列条目状态
+const (
+	MergeQueueStatusQueued    = "queued"
+	MergeQueueStatusTesting   = "testing"
+	MergeQueueStatusMerged    = "merged"
+	MergeQueueStatusFailed    = "failed"
+	MergeQueueStatusCancelled = "cancelled"
+)
+
+// mergeQueueRefPrefix 推测合并引用前缀
+const mergeQueueRefPrefix = "refs/merge-queue/"
+
+// pipelineTriggerTimeout 条目进入验证后等待写入流水线运行记录的最长时间，超时后重新排队
+const pipelineTriggerTimeout = 2 * time.Minute
+
+var (
+	activeMergeQueueStatuses = []string{MergeQueueStatusQueued, MergeQueueStatusTesting}
+	errMergeConflict         = errors.New("合并冲突")
+)
+
+// EnqueueMergeRequest 加入合并队列请求
+type EnqueueMergeRequest struct {
+	RepositoryID  uuid.UUID  `json:"-"`
+	UserID        uuid.UUID  `json:"-"`
+	SourceBranch  string     `json:"source_branch"` // 默认为合并请求的源分支
+	TargetBranch  string     `json:"target_branch"` // 默认为合并请求的目标分支
+	Title         string     `json:"title" validate:"max=255"`
+	PullRequestID *uuid.UUID `json:"pull_request_id" validate:"required"`
+	Priority      int        `json:"priority"`
+}
+
+// queueActions 持锁推进队列时收集的CI/CD请求，释放锁后执行
+type queueActions struct {
+	cancels  []models.MergeQueueEntry // 需要取消流水线运行的条目
+	triggers []pipelineTrigger        // 按批次顺序待触发的流水线
+}
+
+// pipelineTrigger 待触发的条目验证流水线
+type pipelineTrigger struct {
+	entryID uuid.UUID
+	batchID uuid.UUID
+	request *TriggerPipelineRunRequest
+}
+
+// cancel 登记需要取消的流水线运行
+func (a *queueActions) cancel(entry *models.MergeQueueEntry) {
+	if entry.PipelineRunID != nil {
+		a.cancels = append(a.cancels, *entry)
+	}
+}
+
+// MergeQueueEntryInfo 合并队列条目及其在队列中的位置
+type MergeQueueEntryInfo struct {
+	models.MergeQueueEntry
+	Position int `json:"position"` // 从1开始，已离开队列的条目为0
+}
+
+// Enqueue 将合并请求的源分支加入目标分支的合并队列，需要仓库写权限
+func (s *mergeQueueService) Enqueue(req *EnqueueMergeRequest) (*MergeQueueEntryInfo, error) {
+	if req.PullRequestID == nil {
+		return nil, fmt.Errorf("加入合并队列需要关联合并请求")
+	}
+
+	repo, gitRepo, release, err := s.lockRepository(req.RepositoryID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := s.repos.Authorize(repo, req.UserID, RepositoryAccessWrite); err != nil {
+		return nil, err
+	}
+
+	if repo.Settings.MergeQueuePipelineID == nil {
+		return nil, fmt.Errorf("仓库未配置合并队列流水线")
+	}
+
+	pr, err := s.pullRequest(repo.ID, *req.PullRequestID)
+	if err != nil {
+		return nil, err
+	}
+	if req.SourceBranch == "" {
+		req.SourceBranch = pr.SourceBranch
+	}
+	if req.TargetBranch == "" {
+		req.TargetBranch = pr.TargetBranch
+	}
+	if reason := pullRequestBlocker(pr, req.SourceBranch, req.TargetBranch); reason != "" {
+		return nil, fmt.Errorf("%s", reason)
+	}
+	if req.SourceBranch == req.TargetBranch {
+		return nil, fmt.Errorf("源分支与目标分支相同")
+	}
+
+	if _, err := gitRepo.Reference(plumbing.NewBranchReferenceName(req.TargetBranch), true); err != nil {
+		return nil, fmt.Errorf("目标分支不存在: %s", req.TargetBranch)
+	}
+	head, err := gitRepo.Reference(plumbing.NewBranchReferenceName(req.SourceBranch), true)
+	if err != nil {
+		return nil, fmt.Errorf("源分支不存在: %s", req.SourceBranch)
+	}
+
+	var count int64
+	if err := s.db.Model(&models.MergeQueueEntry{}).
+		Where("repository_id = ? AND status IN ? AND (pull_request_id = ? OR (target_branch = ? AND source_branch = ?))",
+			repo.ID, activeMergeQueueStatuses, pr.ID, req.TargetBranch, req.SourceBranch).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("检查合并队列失败: %w", err)
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("分支 %s 已在合并队列中", req.SourceBranch)
+	}
+
+	title := req.Title
+	if title == "" {
+		title = pr.Title
+	}
+
+	entry := &models.MergeQueueEntry{
+		RepositoryID:  repo.ID,
+		TargetBranch:  req.TargetBranch,
+		SourceBranch:  req.SourceBranch,
+		PullRequestID: req.PullRequestID,
+		Title:         title,
+		UserID:        req.UserID,
+		Status:        MergeQueueStatusQueued,
+		Priority:      req.Priority,
+		HeadSHA:       head.Hash().String(),
+	}
+	if err := s.db.Create(entry).Error; err != nil {
+		return nil, fmt.Errorf("加入合并队列失败: %w", err)
+	}
+	s.taskLinks.RecordPullRequest(entry, DevelopmentStateOpen)
+
+	s.kick()
+	return s.entryInfo(entry)
+}
+
+// GetByID 获取合并队列条目
+func (s *mergeQueueService) GetByID(id uuid.UUID) (*MergeQueueEntryInfo, error) {
+	var entry models.MergeQueueEntry
+	if err := s.db.Where("id = ?", id).First(&entry).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("合并队列条目不存在")
+		}
+		return nil, fmt.Errorf("获取合并队列条目失败: %w", err)
+	}
+	return s.entryInfo(&entry)
+}
+
+// List 按队列顺序列出目标分支合并队列中的条目
+func (s *mergeQueueService) List(repositoryID uuid.UUID, targetBranch string) ([]MergeQueueEntryInfo, error) {
+	if targetBranch == "" {
+		var repo models.Repository
+		if err := s.db.Where("id = ? AND deleted_at IS NULL", repositoryID).First(&repo).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, fmt.Errorf("仓库不存在")
+			}
+			return nil, fmt.Errorf("获取仓库失败: %w", err)
+		}
+		targetBranch = repo.DefaultBranch
+	}
+
+	entries, err := s.activeEntries(repositoryID, targetBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]MergeQueueEntryInfo, len(entries))
+	for i, entry := range entries {
+		infos[i] = MergeQueueEntryInfo{MergeQueueEntry: entry, Position: i + 1}
+	}
+	return infos, nil
+}
+
+// Dequeue 将条目移出合并队列，验证中的条目会取消流水线，其后的条目重新排队；
+// 加入队列的用户本人可以移出，其他用户需要仓库写权限
+func (s *mergeQueueService) Dequeue(id, userID uuid.UUID) error {
+	var actions queueActions
+	err := s.dequeue(id, userID, &actions)
+	s.runActions(&actions)
+	return err
+}
+
+// dequeue 持锁移出条目，需要取消的流水线记入actions
+func (s *mergeQueueService) dequeue(id, userID uuid.UUID, actions *queueActions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entry models.MergeQueueEntry
+	if err := s.db.Where("id = ?", id).First(&entry).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("合并队列条目不存在")
+		}
+		return fmt.Errorf("获取合并队列条目失败: %w", err)
+	}
+
+	if entry.UserID != userID {
+		repo, err := s.repos.GetByID(entry.RepositoryID)
+		if err != nil {
+			return err
+		}
+		if err := s.repos.Authorize(repo, userID, RepositoryAccessWrite); err != nil {
+			return err
+		}
+	}
+
+	switch entry.Status {
+	case MergeQueueStatusQueued:
+	case MergeQueueStatusTesting:
+		repo, gitRepo, release, err := s.lockRepository(entry.RepositoryID)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		// 后续条目的推测合并包含该条目，需要重新验证
+		var following []models.MergeQueueEntry
+		if err := s.db.Where("batch_id = ? AND batch_position > ? AND status = ?",
+			entry.BatchID, entry.BatchPosition, MergeQueueStatusTesting).
+			Order("batch_position").Find(&following).Error; err != nil {
+			return fmt.Errorf("获取合并队列条目失败: %w", err)
+		}
+		s.requeue(repo, gitRepo, following, "前序条目已移出队列", actions)
+		s.releaseSpeculativeRef(gitRepo, &entry)
+		actions.cancel(&entry)
+	default:
+		return fmt.Errorf("条目不在合并队列中")
+	}
+
+	if err := s.db.Model(&entry).Updates(map[string]interface{}{
+		"status":     MergeQueueStatusCancelled,
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		return fmt.Errorf("移出合并队列失败: %w", err)
+	}
+	s.taskLinks.RecordPullRequest(&entry, DevelopmentStateClosed)
+
+	s.kick()
+	return nil
+}
+
+// entryInfo 计算条目当前在队列中的位置
+func (s *mergeQueueService) entryInfo(entry *models.MergeQueueEntry) (*MergeQueueEntryInfo, error) {
+	info := &MergeQueueEntryInfo{MergeQueueEntry: *entry}
+	if entry.Status != MergeQueueStatusQueued && entry.Status != MergeQueueStatusTesting {
+		return info, nil
+	}
+
+	entries, err := s.activeEntries(entry.RepositoryID, entry.TargetBranch)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].ID == entry.ID {
+			info.Position = i + 1
+			break
+		}
+	}
+	return info, nil
+}
+
+// activeEntries 按队列顺序获取目标分支的活动条目：验证中的批次在前，其余按优先级和入队时间排序
+func (s *mergeQueueService) activeEntries(repositoryID uuid.UUID, targetBranch string) ([]models.MergeQueueEntry, error) {
+	var entries []models.MergeQueueEntry
+	if err := s.db.Where("repository_id = ? AND target_branch = ? AND status IN ?",
+		repositoryID, targetBranch, activeMergeQueueStatuses).
+		Order(fmt.Sprintf("CASE status WHEN '%s' THEN 0 ELSE 1 END", MergeQueueStatusTesting)).
+		Order("batch_position").
+		Order("priority DESC").
+		Order("created_at").
+		Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("获取合并队列失败: %w", err)
+	}
+	return entries, nil
+}
+
+// pullRequest 获取仓库中的合并请求
+func (s *mergeQueueService) pullRequest(repositoryID, id uuid.UUID) (*models.PullRequest, error) {
+	var pr models.PullRequest
+	if err := s.db.Where("id = ? AND repository_id = ?", id, repositoryID).First(&pr).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("合并请求不存在")
+		}
+		return nil, fmt.Errorf("获取合并请求失败: %w", err)
+	}
+	return &pr, nil
+}
+
+// pullRequestBlocker 检查合并请求是否可由合并队列合并，可合并时返回空字符串
+func pullRequestBlocker(pr *models.PullRequest, sourceBranch, targetBranch string) string {
+	switch {
+	case pr.Status == "draft":
+		return "合并请求仍是草稿"
+	case pr.Status == "merged":
+		return "合并请求已合并"
+	case pr.Status == "closed":
+		return "合并请求已关闭"
+	case pr.Status != "open":
+		return fmt.Sprintf("合并请求状态为 %s，无法合并", pr.Status)
+	case pr.SourceBranch != sourceBranch || pr.TargetBranch != targetBranch:
+		return "合并请求的分支与队列条目不一致"
+	}
+	return ""
+}
+
+// lockRepository 获取仓库共享使用锁后打开仓库，用于写入推测合并引用等操作
+func (s *mergeQueueService) lockRepository(repositoryID uuid.UUID) (*models.Repository, *git.Repository, func(), error) {
+	var repo models.Repository
+	if err := s.db.Where("id = ? AND deleted_at IS NULL", repositoryID).First(&repo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, nil, fmt.Errorf("仓库不存在")
+		}
+		return nil, nil, nil, fmt.Errorf("获取仓库失败: %w", err)
+	}
+
+	gitRepo, release, err := s.storage.Open(&repo)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return &repo, gitRepo, release, nil
+}
+
+// kick 唤醒后台处理
+func (s *mergeQueueService) kick() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run 定期推进所有合并队列
+func (s *mergeQueueService) run() {
+	interval := time.Duration(s.config.MergeQueue.PollInterval) * time.Second
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.wake:
+		}
+		s.process()
+	}
+}
+
+// process 逐个推进存在活动条目的合并队列
+func (s *mergeQueueService) process() {
+	var queues []struct {
+		RepositoryID uuid.UUID
+		TargetBranch string
+	}
+	if err := s.db.Model(&models.MergeQueueEntry{}).
+		Distinct("repository_id", "target_branch").
+		Where("status IN ?", activeMergeQueueStatuses).
+		Find(&queues).Error; err != nil {
+		fmt.Printf("获取合并队列失败: %v\n", err)
+		return
+	}
+
+	for _, queue := range queues {
+		if err := s.processQueue(queue.RepositoryID, queue.TargetBranch); err != nil {
+			fmt.Printf("处理合并队列失败 [%s:%s]: %v\n", queue.RepositoryID, queue.TargetBranch, err)
+		}
+	}
+}
+
+// processQueue 先在锁外查询验证中条目的流水线状态，再持锁推进队列，最后在锁外执行收集的CI/CD请求
+func (s *mergeQueueService) processQueue(repositoryID uuid.UUID, targetBranch string) error {
+	entries, err := s.activeEntries(repositoryID, targetBranch)
+	if err != nil {
+		return err
+	}
+
+	runs := make(map[uuid.UUID]*PipelineRunInfo)
+	for _, entry := range entries {
+		if entry.Status != MergeQueueStatusTesting || entry.PipelineRunID == nil {
+			continue
+		}
+		run, err := s.cicd.GetPipelineRun(*entry.PipelineRunID, entry.UserID)
+		if err != nil {
+			return err
+		}
+		runs[run.ID] = run
+	}
+
+	var actions queueActions
+	err = s.advanceQueue(repositoryID, targetBranch, runs, &actions)
+	s.runActions(&actions)
+	return err
+}
+
+// advanceQueue 持锁检查验证中的批次；没有验证中的条目时开始新批次
+func (s *mergeQueueService) advanceQueue(repositoryID uuid.UUID, targetBranch string, runs map[uuid.UUID]*PipelineRunInfo,
+	actions *queueActions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repo, gitRepo, release, err := s.lockRepository(repositoryID)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	entries, err := s.activeEntries(repositoryID, targetBranch)
+	if err != nil {
+		return err
+	}
+
+	testing := 0
+	for testing < len(entries) && entries[testing].Status == MergeQueueStatusTesting {
+		testing++
+	}
+	if testing > 0 {
+		return s.checkBatch(repo, gitRepo, targetBranch, entries[:testing], runs, actions)
+	}
+
+	batchSize := s.config.MergeQueue.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	return s.startBatch(repo, gitRepo, targetBranch, entries[:min(batchSize, len(entries))], actions)
+}
+
+// runActions 在锁外取消流水线并按顺序触发新批次的流水线
+func (s *mergeQueueService) runActions(actions *queueActions) {
+	for i := range actions.cancels {
+		entry := &actions.cancels[i]
+		if run, err := s.cicd.GetPipelineRun(*entry.PipelineRunID, entry.UserID); err == nil && !run.Finished() {
+			if err := s.cicd.CancelPipelineRun(*entry.PipelineRunID, entry.UserID); err != nil {
+				fmt.Printf("取消流水线运行失败 [%s]: %v\n", *entry.PipelineRunID, err)
+			}
+		}
+	}
+
+	for i, trigger := range actions.triggers {
+		run, err := s.cicd.TriggerPipelineRun(trigger.request)
+		if err != nil {
+			// CI/CD服务不可用，当前及之后的条目重新排队，下次轮询重试
+			fmt.Printf("触发合并队列流水线失败 [%s]: %v\n", trigger.entryID, err)
+			s.abortTriggers(actions.triggers[i:])
+			break
+		}
+		if !s.attachRun(trigger, run) {
+			// 触发期间条目已被移出或重新排队
+			if err := s.cicd.CancelPipelineRun(run.ID, trigger.request.UserID); err != nil {
+				fmt.Printf("取消流水线运行失败 [%s]: %v\n", run.ID, err)
+			}
+		}
+	}
+}
+
+// attachRun 记录条目的流水线运行，条目已不在原批次中时返回false
+func (s *mergeQueueService) attachRun(trigger pipelineTrigger, run *PipelineRunInfo) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := s.db.Model(&models.MergeQueueEntry{}).
+		Where("id = ? AND batch_id = ? AND status = ? AND pipeline_run_id IS NULL",
+			trigger.entryID, trigger.batchID, MergeQueueStatusTesting).
+		Updates(map[string]interface{}{
+			"pipeline_run_id": run.ID,
+			"updated_at":      time.Now(),
+		})
+	if result.Error != nil {
+		fmt.Printf("更新合并队列条目失败 [%s]: %v\n", trigger.entryID, result.Error)
+		return false
+	}
+	return result.RowsAffected > 0
+}
+
+// abortTriggers 将未能触发流水线的条目重新排队
+func (s *mergeQueueService) abortTriggers(triggers []pipelineTrigger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []models.MergeQueueEntry
+	for _, trigger := range triggers {
+		var entry models.MergeQueueEntry
+		if err := s.db.Where("id = ? AND batch_id = ? AND status = ?",
+			trigger.entryID, trigger.batchID, MergeQueueStatusTesting).First(&entry).Error; err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	repo, gitRepo, release, err := s.lockRepository(entries[0].RepositoryID)
+	if err != nil {
+		fmt.Printf("处理合并队列失败 [%s]: %v\n", entries[0].RepositoryID, err)
+		return
+	}
+	defer release()
+
+	var actions queueActions
+	s.requeue(repo, gitRepo, entries, "CI/CD服务不可用", &actions)
+}
+
+// startBatch 基于目标分支依次构建推测合并并触发流水线
+func (s *mergeQueueService) startBatch(repo *models.Repository, gitRepo *git.Repository, targetBranch string,
+	entries []models.MergeQueueEntry, actions *queueActions) error {
+	if repo.Settings.MergeQueuePipelineID == nil {
+		for i := range entries {
+			s.eject(gitRepo, &entries[i], "仓库未配置合并队列流水线", actions)
+		}
+		return nil
+	}
+
+	target, err := gitRepo.Reference(plumbing.NewBranchReferenceName(targetBranch), true)
+	if err != nil {
+		for i := range entries {
+			s.eject(gitRepo, &entries[i], "目标分支不存在", actions)
+		}
+		return nil
+	}
+
+	batchID := uuid.New()
+	base := target.Hash()
+	position := 0
+	for i := range entries {
+		entry := &entries[i]
+
+		if reason, _ := s.entryBlocker(gitRepo, entry, false); reason != "" {
+			s.eject(gitRepo, entry, reason, actions)
+			continue
+		}
+		head, err := gitRepo.Reference(plumbing.NewBranchReferenceName(entry.SourceBranch), true)
+		if err != nil {
+			s.eject(gitRepo, entry, "源分支不存在", actions)
+			continue
+		}
+
+		mergeHash, err := s.speculativeMerge(repo, gitRepo, targetBranch, base, head.Hash(), entry)
+		if errors.Is(err, errMergeConflict) {
+			s.eject(gitRepo, entry, "与目标分支或队列中前序条目存在合并冲突", actions)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		refName := mergeQueueRefPrefix + entry.ID.String()
+		if err := gitRepo.Storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName(refName), mergeHash)); err != nil {
+			return fmt.Errorf("写入推测合并引用失败: %w", err)
+		}
+
+		// 流水线在释放锁后触发，运行记录由attachRun写入
+		if err := s.db.Model(entry).Updates(map[string]interface{}{
+			"status":          MergeQueueStatusTesting,
+			"head_sha":        head.Hash().String(),
+			"base_sha":        base.String(),
+			"merge_sha":       mergeHash.String(),
+			"speculative_ref": refName,
+			"batch_id":        batchID,
+			"batch_position":  position,
+			"pipeline_run_id": nil,
+			"attempts":        entry.Attempts + 1,
+			"failure_reason":  nil,
+			"updated_at":      time.Now(),
+		}).Error; err != nil {
+			return fmt.Errorf("更新合并队列条目失败: %w", err)
+		}
+
+		actions.triggers = append(actions.triggers, pipelineTrigger{
+			entryID: entry.ID,
+			batchID: batchID,
+			request: &TriggerPipelineRunRequest{
+				PipelineID: *repo.Settings.MergeQueuePipelineID,
+				UserID:     entry.UserID,
+				TriggerData: map[string]interface{}{
+					"event":                "merge_queue",
+					"repository_id":        repo.ID,
+					"ref":                  refName,
+					"commit_sha":           mergeHash.String(),
+					"base_sha":             base.String(),
+					"head_sha":             head.Hash().String(),
+					"source_branch":        entry.SourceBranch,
+					"target_branch":        targetBranch,
+					"merge_queue_entry_id": entry.ID,
+				},
+			},
+		})
+
+		base = mergeHash
+		position++
+	}
+	return nil
+}
+
+// checkBatch 根据流水线结果推进批次：
+// 合并第一个失败条目之前最靠后的验证通过条目（及其所有前序条目），移出失败条目并将其后条目重新排队
+func (s *mergeQueueService) checkBatch(repo *models.Repository, gitRepo *git.Repository, targetBranch string,
+	batch []models.MergeQueueEntry, runs map[uuid.UUID]*PipelineRunInfo, actions *queueActions) error {
+	target, err := gitRepo.Reference(plumbing.NewBranchReferenceName(targetBranch), true)
+	if err != nil || target.Hash().String() != batch[0].BaseSHA {
+		// 目标分支在队列之外被更新，推测合并已失效
+		s.requeue(repo, gitRepo, batch, "目标分支已更新", actions)
+		s.kick()
+		return nil
+	}
+
+	mergeUpTo, failed, stalled := -1, -1, -1
+	var failure string
+	for i := range batch {
+		if batch[i].PipelineRunID == nil {
+			// 流水线仍在触发中；长时间没有运行记录说明触发中断
+			if time.Since(batch[i].UpdatedAt) > pipelineTriggerTimeout {
+				stalled = i
+			}
+			break
+		}
+		run, ok := runs[*batch[i].PipelineRunID]
+		if !ok {
+			// 查询流水线状态后条目发生了变化，下次轮询再处理
+			continue
+		}
+		if run.Status == PipelineRunSucceeded {
+			mergeUpTo = i
+			continue
+		}
+		if run.Finished() {
+			failed, failure = i, fmt.Sprintf("流水线运行 #%d 未通过: %s", run.RunNumber, run.Status)
+			if run.ErrorMessage != nil && *run.ErrorMessage != "" {
+				failure += " (" + *run.ErrorMessage + ")"
+			}
+			break
+		}
+	}
+
+	changed := false
+	if mergeUpTo >= 0 {
+		// 合并前确认源分支和合并请求仍与验证时一致
+		for i := 0; i <= mergeUpTo; i++ {
+			reason, eject := s.entryBlocker(gitRepo, &batch[i], true)
+			if reason == "" {
+				continue
+			}
+			if !s.mergeBatch(repo, gitRepo, targetBranch, batch, i-1, actions) {
+				return nil
+			}
+			if eject {
+				s.eject(gitRepo, &batch[i], reason, actions)
+				s.requeue(repo, gitRepo, batch[i+1:], "前序条目已移出队列", actions)
+			} else {
+				s.requeue(repo, gitRepo, batch[i:], reason, actions)
+			}
+			s.kick()
+			return nil
+		}
+
+		if !s.mergeBatch(repo, gitRepo, targetBranch, batch, mergeUpTo, actions) {
+			return nil
+		}
+		changed = true
+	}
+
+	if failed >= 0 {
+		s.eject(gitRepo, &batch[failed], failure, actions)
+		s.requeue(repo, gitRepo, batch[failed+1:], "前序条目验证失败", actions)
+		changed = true
+	} else if stalled >= 0 {
+		s.requeue(repo, gitRepo, batch[stalled:], "流水线触发超时", actions)
+		changed = true
+	}
+
+	if changed {
+		s.kick()
+	}
+	return nil
+}
+
+// entryBlocker 检查条目是否仍可合并，返回原因以及是否应移出队列（否则重新排队验证）；
+// checkHead为true时还要求源分支仍指向验证时的提交
+func (s *mergeQueueService) entryBlocker(gitRepo *git.Repository, entry *models.MergeQueueEntry, checkHead bool) (string, bool) {
+	head, err := gitRepo.Reference(plumbing.NewBranchReferenceName(entry.SourceBranch), true)
+	if err != nil {
+		return "源分支不存在", true
+	}
+
+	if entry.PullRequestID == nil {
+		return "未关联合并请求", true
+	}
+	pr, err := s.pullRequest(entry.RepositoryID, *entry.PullRequestID)
+	if err != nil {
+		return err.Error(), true
+	}
+	if reason := pullRequestBlocker(pr, entry.SourceBranch, entry.TargetBranch); reason != "" {
+		return reason, true
+	}
+
+	if checkHead && head.Hash().String() != entry.HeadSHA {
+		return "源分支在验证后有新的提交", false
+	}
+	return "", false
+}
+
+// mergeBatch 合并批次中前upTo+1个条目，upTo小于0时不做任何事；
+// 合并失败时已处理整个批次（重新排队或移出），返回false
+func (s *mergeQueueService) mergeBatch(repo *models.Repository, gitRepo *git.Repository, targetBranch string,
+	batch []models.MergeQueueEntry, upTo int, actions *queueActions) bool {
+	if upTo < 0 {
+		return true
+	}
+
+	merged := batch[:upTo+1]
+	if err := s.merge(repo, gitRepo, targetBranch, merged); err != nil {
+		if errors.Is(err, errStaleRef) {
+			s.requeue(repo, gitRepo, batch, "目标分支已更新", actions)
+		} else {
+			for i := range merged {
+				s.eject(gitRepo, &merged[i], fmt.Sprintf("合并失败: %v", err), actions)
+			}
+			s.requeue(repo, gitRepo, batch[upTo+1:], "前序条目合并失败", actions)
+		}
+		s.kick()
+		return false
+	}
+	return true
+}
+
+// merge 将目标分支快进到批次中最后一个条目的推测合并提交，并将关联的合并请求标记为已合并；
+// 调用前已确认每个条目都关联了开放的合并请求
+func (s *mergeQueueService) merge(repo *models.Repository, gitRepo *git.Repository, targetBranch string,
+	entries []models.MergeQueueEntry) error {
+	viaPullRequest := true
+	for i := range entries {
+		if entries[i].PullRequestID == nil {
+			viaPullRequest = false
+		}
+	}
+
+	last := entries[len(entries)-1]
+	update := &RefUpdate{
+		RefName:        plumbing.NewBranchReferenceName(targetBranch).String(),
+		OldSHA:         entries[0].BaseSHA,
+		NewSHA:         last.MergeSHA,
+		UserID:         last.UserID,
+		ViaPullRequest: viaPullRequest,
+	}
+	session := &GitSession{
+		UserID:   last.UserID,
+		Username: "merge-queue",
+		Protocol: "internal",
+	}
+	if err := s.gitProtocol.UpdateReference(repo, session, update); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := range entries {
+		s.releaseSpeculativeRef(gitRepo, &entries[i])
+		if err := s.db.Model(&entries[i]).Updates(map[string]interface{}{
+			"status":     MergeQueueStatusMerged,
+			"merged_at":  now,
+			"updated_at": now,
+		}).Error; err != nil {
+			fmt.Printf("更新合并队列条目失败 [%s]: %v\n", entries[i].ID, err)
+		}
+		if entries[i].PullRequestID != nil {
+			if err := s.db.Model(&models.PullRequest{}).Where("id = ?", *entries[i].PullRequestID).
+				Updates(map[string]interface{}{
+					"status":     "merged",
+					"merged_at":  now,
+					"updated_at": now,
+				}).Error; err != nil {
+				fmt.Printf("更新合并请求状态失败 [%s]: %v\n", *entries[i].PullRequestID, err)
+			}
+		}
+		s.taskLinks.RecordPullRequest(&entries[i], DevelopmentStateMerged)
+	}
+	return nil
+}
+
+// eject 将条目移出队列并记录原因
+func (s *mergeQueueService) eject(gitRepo *git.Repository, entry *models.MergeQueueEntry, reason string, actions *queueActions) {
+	s.releaseSpeculativeRef(gitRepo, entry)
+	actions.cancel(entry)
+	if err := s.db.Model(entry).Updates(map[string]interface{}{
+		"status":         MergeQueueStatusFailed,
+		"failure_reason": reason,
+		"updated_at":     time.Now(),
+	}).Error; err != nil {
+		fmt.Printf("更新合并队列条目失败 [%s]: %v\n", entry.ID, err)
+	}
+}
+
+// requeue 取消验证并将条目放回队列，保留原有排序
+func (s *mergeQueueService) requeue(repo *models.Repository, gitRepo *git.Repository, entries []models.MergeQueueEntry,
+	reason string, actions *queueActions) {
+	for i := range entries {
+		entry := &entries[i]
+		s.releaseSpeculativeRef(gitRepo, entry)
+		actions.cancel(entry)
+		if err := s.db.Model(entry).Updates(map[string]interface{}{
+			"status":          MergeQueueStatusQueued,
+			"base_sha":        "",
+			"merge_sha":       "",
+			"speculative_ref": nil,
+			"batch_id":        nil,
+			"batch_position":  0,
+			"pipeline_run_id": nil,
+			"updated_at":      time.Now(),
+		}).Error; err != nil {
+			fmt.Printf("更新合并队列条目失败 [%s]: %v\n", entry.ID, err)
+			continue
+		}
+		fmt.Printf("合并队列条目重新排队 [%s/%s]: %s\n", repo.Name, entry.SourceBranch, reason)
+	}
+}
+
+// releaseSpeculativeRef 删除推测合并引用
+func (s *mergeQueueService) releaseSpeculativeRef(gitRepo *git.Repository, entry *models.MergeQueueEntry) {
+	if entry.SpeculativeRef != nil {
+		if err := gitRepo.Storer.RemoveReference(plumbing.ReferenceName(*entry.SpeculativeRef)); err != nil {
+			fmt.Printf("删除推测合并引用失败 [%s]: %v\n", *entry.SpeculativeRef, err)
+		}
+	}
+}
+
+// speculativeMerge 在base之上合并源分支提交，按仓库的默认合并方式生成提交（变基方式按合并提交处理）
+func (s *mergeQueueService) speculativeMerge(repo *models.Repository, gitRepo *git.Repository, targetBranch string,
+	base, head plumbing.Hash, entry *models.MergeQueueEntry) (plumbing.Hash, error) {
+	cmd := exec.Command("git", "merge-tree", "--write-tree", "--no-messages", base.String(), head.String())
+	cmd.Dir = repositoryPath(s.config, repo)
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return plumbing.ZeroHash, errMergeConflict
+		}
+		return plumbing.ZeroHash, fmt.Errorf("计算推测合并失败: %w", err)
+	}
+
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return plumbing.ZeroHash, fmt.Errorf("计算推测合并失败: 无输出")
+	}
+	treeHash := plumbing.NewHash(fields[0])
+
+	author := s.mergeAuthor(entry.UserID)
+	parents := []plumbing.Hash{base, head}
+	message := fmt.Sprintf("Merge branch '%s' into %s\n\n%s", entry.SourceBranch, targetBranch, entry.Title)
+	if repo.Settings.DefaultMergeMethod == "squash" {
+		parents = []plumbing.Hash{base}
+		message = fmt.Sprintf("%s\n\nSquashed commit of branch '%s'", entry.Title, entry.SourceBranch)
+	}
+
+	return writeMergeCommit(gitRepo.Storer, treeHash, parents, author, message)
+}
+
+// mergeAuthor 以加入队列的用户作为合并提交作者，用户不存在时使用系统身份
+func (s *mergeQueueService) mergeAuthor(userID uuid.UUID) object.Signature {
+	signature := templateCommitter
+	var user models.User
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err == nil {
+		signature.Email = user.Email
+		signature.Name = user.Email
+		if user.FullName != nil && *user.FullName != "" {
+			signature.Name = *user.FullName
+		}
+	}
+	signature.When = time.Now()
+	return signature
+}
+
+// writeMergeCommit 创建合并提交，提交者为系统身份
+func writeMergeCommit(dst storer.EncodedObjectStorer, treeHash plumbing.Hash, parents []plumbing.Hash,
+	author object.Signature, message string) (plumbing.Hash, error) {
+	committer := templateCommitter
+	committer.When = author.When
+
+	commit := &object.Commit{
+		Author:       author,
+		Committer:    committer,
+		Message:      message + "\n",
+		TreeHash:     treeHash,
+		ParentHashes: parents,
+	}
+
+	obj := dst.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("创建合并提交失败: %w", err)
+	}
+	return storeTemplateObject(dst, obj)
+}
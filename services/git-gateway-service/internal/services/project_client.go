@@ -0,0 +1,111 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"git-gateway-service/internal/config"
+
+	"github.com/google/uuid"
+)
+
+// ProjectClient 项目服务客户端接口
+type ProjectClient interface {
+	RecordDevelopmentEvents(req *DevelopmentEventsRequest) error
+}
+
+type projectClient struct {
+	config *config.Config
+	client *http.Client
+}
+
+// NewProjectClient 创建项目服务客户端
+func NewProjectClient(cfg *config.Config) ProjectClient {
+	return &projectClient{
+		config: cfg,
+		client: &http.Client{
+			Timeout: time.Duration(cfg.Project.Timeout) * time.Second,
+		},
+	}
+}
+
+// 代码事件类型（与项目服务保持一致）
+const (
+	DevelopmentEventCommit      = "commit"
+	DevelopmentEventBranch      = "branch"
+	DevelopmentEventPullRequest = "pull_request"
+)
+
+// 代码事件状态
+const (
+	DevelopmentStateOpen    = "open"
+	DevelopmentStateMerged  = "merged"
+	DevelopmentStateClosed  = "closed"
+	DevelopmentStateDeleted = "deleted"
+)
+
+// DevelopmentEvent 上报项目服务的代码事件
+type DevelopmentEvent struct {
+	Type        string     `json:"type"`
+	Reference   string     `json:"reference"` // 提交SHA、分支名或合并请求ID
+	Branch      *string    `json:"branch,omitempty"`
+	Title       string     `json:"title"` // 提交信息、分支名或合并请求标题
+	AuthorName  *string    `json:"author_name,omitempty"`
+	AuthorEmail *string    `json:"author_email,omitempty"`
+	UserID      *uuid.UUID `json:"user_id,omitempty"`
+	State       string     `json:"state"`
+	OccurredAt  time.Time  `json:"occurred_at"`
+}
+
+// DevelopmentEventsRequest 上报代码事件请求
+type DevelopmentEventsRequest struct {
+	ProjectID    uuid.UUID          `json:"project_id"`
+	TenantID     uuid.UUID          `json:"tenant_id"`
+	RepositoryID uuid.UUID          `json:"repository_id"`
+	Repository   string             `json:"repository"`
+	Events       []DevelopmentEvent `json:"events"`
+	UserID       uuid.UUID          `json:"-"` // 以该用户身份调用
+}
+
+// RecordDevelopmentEvents 上报代码事件，由项目服务解析任务编号并记录关联
+func (c *projectClient) RecordDevelopmentEvents(req *DevelopmentEventsRequest) error {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost,
+		strings.TrimRight(c.config.Project.BaseURL, "/")+"/api/v1/development/events", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	token, err := signServiceToken(c.config.JWT.Secret, req.UserID)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+token)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "Axiom-Git-Gateway")
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("上报代码事件失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var result struct {
+			Error   string `json:"error"`
+			Details string `json:"details"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result)
+		return fmt.Errorf("上报代码事件失败 (HTTP %d): %s %s", resp.StatusCode, result.Error, result.Details)
+	}
+	return nil
+}
@@ -0,0 +1,469 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"git-gateway-service/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ProtectionRuleService 引用保护规则服务接口
+type ProtectionRuleService interface {
+	Create(req *CreateProtectionRuleRequest) (*models.ProtectionRule, error)
+	GetByID(id uuid.UUID) (*models.ProtectionRule, error)
+	GetByRepository(repositoryID uuid.UUID) ([]models.ProtectionRule, error)
+	GetByPattern(repositoryID uuid.UUID, refType, pattern string) (*models.ProtectionRule, error)
+	Update(id uuid.UUID, req *UpdateProtectionRuleRequest) (*models.ProtectionRule, error)
+	Delete(id uuid.UUID) error
+	Resolve(repositoryID uuid.UUID, refType, name string) (*models.ProtectionRule, error)
+	ApplyToBranch(branch *models.Branch) error
+	SyncBranches(repositoryID uuid.UUID) error
+	CheckRefUpdate(repositoryID uuid.UUID, update *RefUpdate) error
+}
+
+type protectionRuleService struct {
+	db *gorm.DB
+}
+
+// NewProtectionRuleService 创建引用保护规则服务实例
+func NewProtectionRuleService(db *gorm.DB) ProtectionRuleService {
+	return &protectionRuleService{db: db}
+}
+
+// 引用类型常量
+const (
+	RefTypeBranch = "branch"
+	RefTypeTag    = "tag"
+)
+
+// 引用前缀
+const (
+	branchRefPrefix = "refs/heads/"
+	tagRefPrefix    = "refs/tags/"
+)
+
+// ZeroCommitSHA 空提交SHA（引用创建或删除）
+const ZeroCommitSHA = "0000000000000000000000000000000000000000"
+
+// CreateProtectionRuleRequest 创建保护规则请求
+type CreateProtectionRuleRequest struct {
+	RepositoryID  uuid.UUID               `json:"repository_id" validate:"required"`
+	RefType       string                  `json:"ref_type" validate:"required,oneof=branch tag"`
+	Pattern       string                  `json:"pattern" validate:"required,max=255"`
+	Priority      int                     `json:"priority"`
+	Protection    models.BranchProtection `json:"protection"`
+	PushAllowlist []uuid.UUID             `json:"push_allowlist"`
+}
+
+// UpdateProtectionRuleRequest 更新保护规则请求
+type UpdateProtectionRuleRequest struct {
+	Pattern       *string                  `json:"pattern" validate:"omitempty,max=255"`
+	Priority      *int                     `json:"priority"`
+	Protection    *models.BranchProtection `json:"protection"`
+	PushAllowlist []uuid.UUID              `json:"push_allowlist"`
+}
+
+// RefUpdate 一次引用变更（推送中的单条命令）
+type RefUpdate struct {
+	RefName        string    `json:"ref_name"` // 完整引用名，如 refs/heads/main
+	OldSHA         string    `json:"old_sha"`
+	NewSHA         string    `json:"new_sha"`
+	Forced         bool      `json:"forced"`           // 非快进更新
+	UserID         uuid.UUID `json:"user_id"`          // 推送者
+	ViaPullRequest bool      `json:"via_pull_request"` // 由合并请求发起（服务端合并）
+}
+
+// ParseRefName 将完整引用名拆分为引用类型和短名称
+func ParseRefName(refName string) (string, string) {
+	switch {
+	case strings.HasPrefix(refName, branchRefPrefix):
+		return RefTypeBranch, strings.TrimPrefix(refName, branchRefPrefix)
+	case strings.HasPrefix(refName, tagRefPrefix):
+		return RefTypeTag, strings.TrimPrefix(refName, tagRefPrefix)
+	default:
+		return "", refName
+	}
+}
+
+// Create 创建保护规则
+func (s *protectionRuleService) Create(req *CreateProtectionRuleRequest) (*models.ProtectionRule, error) {
+	if req.RefType != RefTypeBranch && req.RefType != RefTypeTag {
+		return nil, fmt.Errorf("无效的引用类型: %s", req.RefType)
+	}
+
+	if _, err := compileRefPattern(req.Pattern); err != nil {
+		return nil, err
+	}
+
+	// 检查仓库是否存在
+	var repo models.Repository
+	if err := s.db.Where("id = ? AND deleted_at IS NULL", req.RepositoryID).First(&repo).Error; err != nil {
+		return nil, fmt.Errorf("仓库不存在")
+	}
+
+	// 检查同仓库下同类型规则模式唯一性
+	var existingRule models.ProtectionRule
+	if err := s.db.Where("repository_id = ? AND ref_type = ? AND pattern = ?",
+		req.RepositoryID, req.RefType, req.Pattern).First(&existingRule).Error; err == nil {
+		return nil, fmt.Errorf("保护规则 '%s' 已存在", req.Pattern)
+	}
+
+	allowlistJSON, _ := json.Marshal(normalizeAllowlist(req.PushAllowlist))
+	rule := &models.ProtectionRule{
+		RepositoryID:  req.RepositoryID,
+		RefType:       req.RefType,
+		Pattern:       req.Pattern,
+		Priority:      req.Priority,
+		Protection:    req.Protection,
+		PushAllowlist: allowlistJSON,
+	}
+
+	if err := s.db.Create(rule).Error; err != nil {
+		return nil, fmt.Errorf("创建保护规则失败: %w", err)
+	}
+
+	if rule.RefType == RefTypeBranch {
+		if err := s.SyncBranches(rule.RepositoryID); err != nil {
+			return nil, err
+		}
+	}
+
+	return rule, nil
+}
+
+// GetByID 根据ID获取保护规则
+func (s *protectionRuleService) GetByID(id uuid.UUID) (*models.ProtectionRule, error) {
+	var rule models.ProtectionRule
+	if err := s.db.Where("id = ?", id).First(&rule).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("保护规则不存在")
+		}
+		return nil, fmt.Errorf("获取保护规则失败: %w", err)
+	}
+	return &rule, nil
+}
+
+// GetByRepository 获取仓库的所有保护规则（按优先级降序）
+func (s *protectionRuleService) GetByRepository(repositoryID uuid.UUID) ([]models.ProtectionRule, error) {
+	var rules []models.ProtectionRule
+	if err := s.db.Where("repository_id = ?", repositoryID).
+		Order("priority DESC, created_at ASC").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("获取仓库保护规则失败: %w", err)
+	}
+	return rules, nil
+}
+
+// GetByPattern 根据引用类型和模式获取保护规则
+func (s *protectionRuleService) GetByPattern(repositoryID uuid.UUID, refType, pattern string) (*models.ProtectionRule, error) {
+	var rule models.ProtectionRule
+	if err := s.db.Where("repository_id = ? AND ref_type = ? AND pattern = ?",
+		repositoryID, refType, pattern).First(&rule).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("保护规则不存在")
+		}
+		return nil, fmt.Errorf("获取保护规则失败: %w", err)
+	}
+	return &rule, nil
+}
+
+// Update 更新保护规则
+func (s *protectionRuleService) Update(id uuid.UUID, req *UpdateProtectionRuleRequest) (*models.ProtectionRule, error) {
+	rule, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+
+	if req.Pattern != nil {
+		if _, err := compileRefPattern(*req.Pattern); err != nil {
+			return nil, err
+		}
+
+		var existingRule models.ProtectionRule
+		if err := s.db.Where("repository_id = ? AND ref_type = ? AND pattern = ? AND id != ?",
+			rule.RepositoryID, rule.RefType, *req.Pattern, id).First(&existingRule).Error; err == nil {
+			return nil, fmt.Errorf("保护规则 '%s' 已存在", *req.Pattern)
+		}
+		updates["pattern"] = *req.Pattern
+	}
+
+	if req.Priority != nil {
+		updates["priority"] = *req.Priority
+	}
+
+	if req.Protection != nil {
+		for column, value := range protectionColumns(*req.Protection) {
+			updates[column] = value
+		}
+	}
+
+	if req.PushAllowlist != nil {
+		allowlistJSON, _ := json.Marshal(normalizeAllowlist(req.PushAllowlist))
+		updates["push_allowlist"] = allowlistJSON
+	}
+
+	updates["updated_at"] = time.Now()
+
+	if err := s.db.Model(rule).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("更新保护规则失败: %w", err)
+	}
+
+	if rule.RefType == RefTypeBranch {
+		if err := s.SyncBranches(rule.RepositoryID); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.GetByID(id)
+}
+
+// Delete 删除保护规则
+func (s *protectionRuleService) Delete(id uuid.UUID) error {
+	rule, err := s.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Delete(rule).Error; err != nil {
+		return fmt.Errorf("删除保护规则失败: %w", err)
+	}
+
+	if rule.RefType == RefTypeBranch {
+		return s.SyncBranches(rule.RepositoryID)
+	}
+
+	return nil
+}
+
+// Resolve 解析引用适用的保护规则，未命中任何规则时返回nil
+func (s *protectionRuleService) Resolve(repositoryID uuid.UUID, refType, name string) (*models.ProtectionRule, error) {
+	var rules []models.ProtectionRule
+	if err := s.db.Where("repository_id = ? AND ref_type = ?", repositoryID, refType).
+		Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("获取保护规则失败: %w", err)
+	}
+
+	return matchProtectionRule(rules, name), nil
+}
+
+// ApplyToBranch 将命中的保护规则写入分支记录（不落库）
+func (s *protectionRuleService) ApplyToBranch(branch *models.Branch) error {
+	rule, err := s.Resolve(branch.RepositoryID, RefTypeBranch, branch.Name)
+	if err != nil {
+		return err
+	}
+
+	if rule == nil {
+		branch.IsProtected = false
+		branch.Protection = models.BranchProtection{}
+		return nil
+	}
+
+	branch.IsProtected = true
+	branch.Protection = rule.Protection
+	return nil
+}
+
+// SyncBranches 按当前规则重新计算仓库所有分支的保护状态
+func (s *protectionRuleService) SyncBranches(repositoryID uuid.UUID) error {
+	var rules []models.ProtectionRule
+	if err := s.db.Where("repository_id = ? AND ref_type = ?", repositoryID, RefTypeBranch).
+		Find(&rules).Error; err != nil {
+		return fmt.Errorf("获取保护规则失败: %w", err)
+	}
+
+	var branches []models.Branch
+	if err := s.db.Where("repository_id = ?", repositoryID).Find(&branches).Error; err != nil {
+		return fmt.Errorf("获取仓库分支失败: %w", err)
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for _, branch := range branches {
+			isProtected := false
+			protection := models.BranchProtection{}
+			if rule := matchProtectionRule(rules, branch.Name); rule != nil {
+				isProtected = true
+				protection = rule.Protection
+			}
+
+			updates := protectionColumns(protection)
+			updates["is_protected"] = isProtected
+			updates["updated_at"] = time.Now()
+
+			if err := tx.Model(&models.Branch{}).Where("id = ?", branch.ID).
+				Updates(updates).Error; err != nil {
+				return fmt.Errorf("同步分支保护状态失败: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// CheckRefUpdate 校验引用变更是否违反保护规则
+func (s *protectionRuleService) CheckRefUpdate(repositoryID uuid.UUID, update *RefUpdate) error {
+	refType, name := ParseRefName(update.RefName)
+	if refType == "" {
+		return nil
+	}
+
+	rule, err := s.Resolve(repositoryID, refType, name)
+	if err != nil {
+		return err
+	}
+	if rule == nil {
+		return nil
+	}
+
+	isCreate := update.OldSHA == "" || update.OldSHA == ZeroCommitSHA
+	isDelete := update.NewSHA == "" || update.NewSHA == ZeroCommitSHA
+	protection := rule.Protection
+
+	if isDelete {
+		if !protection.AllowDeletions {
+			return fmt.Errorf("受保护的引用 '%s' 不允许删除 (规则: %s)", name, rule.Pattern)
+		}
+		return nil
+	}
+
+	if protection.RestrictPushes && !ruleAllowsUser(rule, update.UserID) {
+		return fmt.Errorf("无权推送受保护的引用 '%s' (规则: %s)", name, rule.Pattern)
+	}
+
+	if isCreate {
+		return nil
+	}
+
+	// 标签一经创建不允许移动，除非规则允许强制推送
+	if refType == RefTypeTag && !protection.AllowForcePushes {
+		return fmt.Errorf("受保护的标签 '%s' 不允许修改 (规则: %s)", name, rule.Pattern)
+	}
+
+	if update.Forced && !protection.AllowForcePushes {
+		return fmt.Errorf("受保护的分支 '%s' 不允许强制推送 (规则: %s)", name, rule.Pattern)
+	}
+
+	if refType == RefTypeBranch && protection.RequirePullRequest && !update.ViaPullRequest {
+		return fmt.Errorf("受保护的分支 '%s' 只能通过合并请求更新 (规则: %s)", name, rule.Pattern)
+	}
+
+	return nil
+}
+
+// matchProtectionRule 在规则集中选出命中引用名的最高优先级规则
+// 优先级相同时，更具体的模式（通配符更少、字面量更长）优先
+func matchProtectionRule(rules []models.ProtectionRule, name string) *models.ProtectionRule {
+	var matched []models.ProtectionRule
+	for _, rule := range rules {
+		re, err := compileRefPattern(rule.Pattern)
+		if err != nil {
+			continue
+		}
+		if re.MatchString(name) {
+			matched = append(matched, rule)
+		}
+	}
+
+	if len(matched) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if wa, wb := patternWildcards(a.Pattern), patternWildcards(b.Pattern); wa != wb {
+			return wa < wb
+		}
+		if la, lb := len(a.Pattern), len(b.Pattern); la != lb {
+			return la > lb
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+
+	return &matched[0]
+}
+
+// compileRefPattern 将引用通配符编译为正则表达式
+// 支持 *（不跨越/）、**（可跨越/）、?（单个非/字符）
+func compileRefPattern(pattern string) (*regexp.Regexp, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return nil, fmt.Errorf("保护规则模式不能为空")
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("无效的保护规则模式 '%s': %w", pattern, err)
+	}
+	return re, nil
+}
+
+// protectionColumns 将保护设置展开为嵌入字段对应的列
+func protectionColumns(p models.BranchProtection) map[string]interface{} {
+	return map[string]interface{}{
+		"require_status_checks":      p.RequireStatusChecks,
+		"require_up_to_date":         p.RequireUpToDate,
+		"require_pull_request":       p.RequirePullRequest,
+		"require_code_owner_reviews": p.RequireCodeOwnerReviews,
+		"dismiss_stale_reviews":      p.DismissStaleReviews,
+		"required_reviewers":         p.RequiredReviewers,
+		"restrict_pushes":            p.RestrictPushes,
+		"allow_force_pushes":         p.AllowForcePushes,
+		"allow_deletions":            p.AllowDeletions,
+	}
+}
+
+// patternWildcards 统计模式中的通配符数量
+func patternWildcards(pattern string) int {
+	return strings.Count(pattern, "*") + strings.Count(pattern, "?")
+}
+
+// ruleAllowsUser 检查用户是否在规则的推送白名单中
+func ruleAllowsUser(rule *models.ProtectionRule, userID uuid.UUID) bool {
+	var allowlist []uuid.UUID
+	if err := json.Unmarshal(rule.PushAllowlist, &allowlist); err != nil {
+		return false
+	}
+
+	for _, id := range allowlist {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeAllowlist 保证白名单序列化为数组而非null
+func normalizeAllowlist(allowlist []uuid.UUID) []uuid.UUID {
+	if allowlist == nil {
+		return []uuid.UUID{}
+	}
+	return allowlist
+}
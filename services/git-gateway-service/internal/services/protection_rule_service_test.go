@@ -0,0 +1,136 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"git-gateway-service/internal/models"
+)
+
+func TestCompileRefPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"main", "main", true},
+		{"main", "main2", false},
+		{"release/*", "release/1.0", true},
+		{"release/*", "release/1.0/hotfix", false},
+		{"release/**", "release/1.0/hotfix", true},
+		{"release/**", "release/", true},
+		{"v?", "v1", true},
+		{"v?", "v10", false},
+		{"v?", "v/", false},
+		{"feature.*", "feature.x", true},
+		{"feature.*", "featureAx", false},
+		{"*", "main", true},
+		{"*", "feature/x", false},
+		{"**", "feature/x", true},
+	}
+
+	for _, tt := range tests {
+		re, err := compileRefPattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("compileRefPattern(%q) error: %v", tt.pattern, err)
+		}
+		if got := re.MatchString(tt.name); got != tt.want {
+			t.Errorf("pattern %q match %q = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompileRefPatternEmpty(t *testing.T) {
+	for _, pattern := range []string{"", "   "} {
+		if _, err := compileRefPattern(pattern); err == nil {
+			t.Errorf("compileRefPattern(%q) expected error", pattern)
+		}
+	}
+}
+
+func TestMatchProtectionRule(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rule := func(pattern string, priority int, age time.Duration) models.ProtectionRule {
+		return models.ProtectionRule{Pattern: pattern, Priority: priority, CreatedAt: base.Add(age)}
+	}
+
+	tests := []struct {
+		name  string
+		rules []models.ProtectionRule
+		ref   string
+		want  string // 命中规则的模式，空表示未命中
+	}{
+		{
+			name:  "no match",
+			rules: []models.ProtectionRule{rule("release/*", 0, 0)},
+			ref:   "main",
+			want:  "",
+		},
+		{
+			name:  "higher priority wins over specificity",
+			rules: []models.ProtectionRule{rule("main", 0, 0), rule("**", 10, 0)},
+			ref:   "main",
+			want:  "**",
+		},
+		{
+			name:  "fewer wildcards wins at equal priority",
+			rules: []models.ProtectionRule{rule("release/**", 0, 0), rule("release/*", 0, 0), rule("release/1.0", 0, 0)},
+			ref:   "release/1.0",
+			want:  "release/1.0",
+		},
+		{
+			name:  "longer literal wins at equal wildcards",
+			rules: []models.ProtectionRule{rule("r*", 0, 0), rule("release/*", 0, 0)},
+			ref:   "release/1",
+			want:  "release/*",
+		},
+		{
+			name:  "older rule wins on full tie",
+			rules: []models.ProtectionRule{rule("v*", 0, time.Hour), rule("x*", 0, 0), rule("v*", 0, 0)},
+			ref:   "v1",
+			want:  "v*",
+		},
+		{
+			name:  "invalid pattern is skipped",
+			rules: []models.ProtectionRule{rule("", 100, 0), rule("main", 0, 0)},
+			ref:   "main",
+			want:  "main",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := matchProtectionRule(tt.rules, tt.ref)
+			switch {
+			case tt.want == "" && got != nil:
+				t.Fatalf("expected no match, got %q", got.Pattern)
+			case tt.want != "" && got == nil:
+				t.Fatalf("expected %q, got no match", tt.want)
+			case got != nil && got.Pattern != tt.want:
+				t.Fatalf("got %q, want %q", got.Pattern, tt.want)
+			}
+			if got != nil && !got.CreatedAt.Equal(base) {
+				t.Fatalf("expected the oldest matching rule, got created at %v", got.CreatedAt)
+			}
+		})
+	}
+}
+
+func TestParseRefName(t *testing.T) {
+	tests := []struct {
+		ref      string
+		wantType string
+		wantName string
+	}{
+		{"refs/heads/main", RefTypeBranch, "main"},
+		{"refs/heads/feature/x", RefTypeBranch, "feature/x"},
+		{"refs/tags/v1.0", RefTypeTag, "v1.0"},
+	}
+
+	for _, tt := range tests {
+		refType, name := ParseRefName(tt.ref)
+		if refType != tt.wantType || name != tt.wantName {
+			t.Errorf("ParseRefName(%q) = (%q, %q), want (%q, %q)", tt.ref, refType, name, tt.wantType, tt.wantName)
+		}
+	}
+}
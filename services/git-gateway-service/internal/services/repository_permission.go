@@ -0,0 +1,123 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"git-gateway-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// RepositoryAccess 用户对仓库的访问级别，数值越大权限越高
+type RepositoryAccess int
+
+const (
+	RepositoryAccessNone  RepositoryAccess = iota
+	RepositoryAccessRead                   // 克隆、拉取、读取内容
+	RepositoryAccessWrite                  // 推送
+	RepositoryAccessAdmin                  // 管理仓库设置与凭据
+)
+
+// ErrRepositoryAccessDenied 访问级别不足
+var ErrRepositoryAccessDenied = errors.New("没有访问该仓库的权限")
+
+// String 访问级别名称
+func (a RepositoryAccess) String() string {
+	switch a {
+	case RepositoryAccessRead:
+		return "read"
+	case RepositoryAccessWrite:
+		return "write"
+	case RepositoryAccessAdmin:
+		return "admin"
+	default:
+		return "none"
+	}
+}
+
+// AccessLevel 计算用户对仓库的访问级别：
+// 项目角色决定读写和管理权限，租户Owner/Admin视为管理员；
+// 非项目成员只能读取公开仓库，以及所在租户的内部仓库
+func (s *repositoryService) AccessLevel(repo *models.Repository, userID uuid.UUID) (RepositoryAccess, error) {
+	access := RepositoryAccessNone
+	if repo.Visibility == "public" {
+		access = RepositoryAccessRead
+	}
+	if userID == uuid.Nil {
+		return access, nil
+	}
+
+	var projectPermissions []string
+	if err := s.db.Model(&models.ProjectMember{}).
+		Joins("JOIN roles ON roles.id = project_members.role_id").
+		Where("project_members.project_id = ? AND project_members.user_id = ?", repo.ProjectID, userID).
+		Pluck("roles.permissions", &projectPermissions).Error; err != nil {
+		return RepositoryAccessNone, fmt.Errorf("查询项目成员失败: %w", err)
+	}
+	for _, permissions := range projectPermissions {
+		access = max(access, accessFromPermissions(permissions))
+	}
+
+	var tenantPermissions []string
+	if err := s.db.Model(&models.TenantMember{}).
+		Joins("JOIN roles ON roles.id = tenant_members.role_id").
+		Joins("JOIN projects ON projects.tenant_id = tenant_members.tenant_id").
+		Where("projects.id = ? AND tenant_members.user_id = ? AND tenant_members.status = ?", repo.ProjectID, userID, "active").
+		Pluck("roles.permissions", &tenantPermissions).Error; err != nil {
+		return RepositoryAccessNone, fmt.Errorf("查询租户成员失败: %w", err)
+	}
+	for _, permissions := range tenantPermissions {
+		if repo.Visibility == "internal" {
+			access = max(access, RepositoryAccessRead)
+		}
+		if hasPermission(permissions, "project:*", "tenant:*") {
+			access = RepositoryAccessAdmin
+		}
+	}
+
+	return access, nil
+}
+
+// Authorize 校验用户对仓库至少具有指定访问级别
+func (s *repositoryService) Authorize(repo *models.Repository, userID uuid.UUID, required RepositoryAccess) error {
+	access, err := s.AccessLevel(repo, userID)
+	if err != nil {
+		return err
+	}
+	if access < required {
+		return ErrRepositoryAccessDenied
+	}
+	return nil
+}
+
+// accessFromPermissions 将项目角色的权限列表映射为仓库访问级别
+func accessFromPermissions(permissions string) RepositoryAccess {
+	switch {
+	case hasPermission(permissions, "repository:*", "repository:admin"):
+		return RepositoryAccessAdmin
+	case hasPermission(permissions, "repository:write"):
+		return RepositoryAccessWrite
+	case hasPermission(permissions, "repository:read"):
+		return RepositoryAccessRead
+	default:
+		return RepositoryAccessNone
+	}
+}
+
+// hasPermission 判断JSON权限数组是否包含任一权限
+func hasPermission(permissions string, wanted ...string) bool {
+	var list []string
+	if err := json.Unmarshal([]byte(permissions), &list); err != nil {
+		return false
+	}
+	for _, permission := range list {
+		for _, w := range wanted {
+			if permission == w {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// repositoryQueue 按仓库去重的后台任务队列，由单个协程顺序处理
+type repositoryQueue struct {
+	name    string
+	queue   chan uuid.UUID
+	mu      sync.Mutex
+	pending map[uuid.UUID]bool
+	handle  func(repositoryID uuid.UUID) error
+}
+
+// newRepositoryQueue 创建队列并启动处理协程
+func newRepositoryQueue(name string, size int, handle func(repositoryID uuid.UUID) error) *repositoryQueue {
+	q := &repositoryQueue{
+		name:    name,
+		queue:   make(chan uuid.UUID, size),
+		pending: make(map[uuid.UUID]bool),
+		handle:  handle,
+	}
+	go q.run()
+	return q
+}
+
+// Enqueue 将仓库加入队列，同一仓库排队中时不重复加入
+func (q *repositoryQueue) Enqueue(repositoryID uuid.UUID) {
+	q.mu.Lock()
+	if q.pending[repositoryID] {
+		q.mu.Unlock()
+		return
+	}
+	q.pending[repositoryID] = true
+	q.mu.Unlock()
+
+	select {
+	case q.queue <- repositoryID:
+	default:
+		q.mu.Lock()
+		delete(q.pending, repositoryID)
+		q.mu.Unlock()
+		fmt.Printf("%s队列已满，跳过仓库 %s\n", q.name, repositoryID)
+	}
+}
+
+// run 顺序处理队列中的仓库
+func (q *repositoryQueue) run() {
+	for repositoryID := range q.queue {
+		// 处理开始前移出排队集合，处理期间的新推送会再次入队
+		q.mu.Lock()
+		delete(q.pending, repositoryID)
+		q.mu.Unlock()
+
+		if err := q.handle(repositoryID); err != nil {
+			fmt.Printf("%s失败 [%s]: %v\n", q.name, repositoryID, err)
+		}
+	}
+}
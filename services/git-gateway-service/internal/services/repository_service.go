@@ -2,6 +2,7 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -29,18 +30,22 @@ type RepositoryService interface {
 	List(req *ListRepositoriesRequest) ([]models.Repository, int64, error)
 	InitializeGitRepository(repo *models.Repository) error
 	GetRepositorySize(repoPath string) (int64, error)
+	AccessLevel(repo *models.Repository, userID uuid.UUID) (RepositoryAccess, error)
+	Authorize(repo *models.Repository, userID uuid.UUID, required RepositoryAccess) error
 }
 
 type repositoryService struct {
-	db     *gorm.DB
-	config *config.Config
+	db      *gorm.DB
+	config  *config.Config
+	storage StorageService
 }
 
 // NewRepositoryService 创建仓库服务实例
-func NewRepositoryService(db *gorm.DB, cfg *config.Config) RepositoryService {
+func NewRepositoryService(db *gorm.DB, cfg *config.Config, storage StorageService) RepositoryService {
 	return &repositoryService{
-		db:     db,
-		config: cfg,
+		db:      db,
+		config:  cfg,
+		storage: storage,
 	}
 }
 
@@ -54,6 +59,9 @@ type CreateRepositoryRequest struct {
 	Language      *string                 `json:"language" validate:"omitempty,max=50"`
 	Topics        []string                `json:"topics"`
 	Settings      models.RepositorySettings `json:"settings"`
+	IsTemplate    bool                    `json:"is_template"`
+	Template      *TemplateOptions        `json:"template"` // 可选，从模板仓库创建
+	UserID        uuid.UUID               `json:"-"`        // 创建者，用于校验模板仓库的读取权限
 }
 
 // UpdateRepositoryRequest 更新仓库请求
@@ -65,6 +73,7 @@ type UpdateRepositoryRequest struct {
 	Language      *string                 `json:"language" validate:"omitempty,max=50"`
 	Topics        []string                `json:"topics"`
 	Settings      *models.RepositorySettings `json:"settings"`
+	IsTemplate    *bool                   `json:"is_template"`
 }
 
 // ListRepositoriesRequest 列表查询请求
@@ -73,6 +82,7 @@ type ListRepositoriesRequest struct {
 	Visibility *string    `json:"visibility"`
 	Language   *string    `json:"language"`
 	Search     *string    `json:"search"`
+	IsTemplate *bool      `json:"is_template"`
 	Page       int        `json:"page"`
 	Limit      int        `json:"limit"`
 	SortBy     string     `json:"sort_by"`
@@ -85,6 +95,16 @@ type RepositoryStats struct {
 	CommitCount int   `json:"commit_count"`
 	BranchCount int   `json:"branch_count"`
 	TagCount    int   `json:"tag_count"`
+
+	// 后台分析结果，仓库尚未分析时为空
+	Languages      []LanguageStat         `json:"languages,omitempty"`
+	Contributors   []ContributorStat      `json:"contributors,omitempty"`
+	CommitActivity []WeeklyCommitActivity `json:"commit_activity,omitempty"`
+	CodeFrequency  []WeeklyCodeFrequency  `json:"code_frequency,omitempty"`
+	DetectedTopics []string               `json:"detected_topics,omitempty"`
+	Truncated      bool                   `json:"truncated,omitempty"` // 贡献者与频率统计仅覆盖最近的提交
+	AnalyzedCommit string                 `json:"analyzed_commit,omitempty"`
+	AnalyzedAt     *time.Time             `json:"analyzed_at,omitempty"`
 }
 
 // Create 创建仓库
@@ -96,6 +116,32 @@ func (s *repositoryService) Create(req *CreateRepositoryRequest) (*models.Reposi
 		return nil, fmt.Errorf("仓库名称 '%s' 已存在", req.Name)
 	}
 
+	// 加载模板仓库
+	var template *models.Repository
+	if req.Template != nil {
+		var err error
+		template, err = s.GetByID(req.Template.TemplateID)
+		if err != nil {
+			return nil, fmt.Errorf("模板仓库不存在")
+		}
+		// 只能使用创建者可读的模板（公开模板、同租户的内部模板或所属项目的模板）
+		if err := s.Authorize(template, req.UserID, RepositoryAccessRead); err != nil {
+			if errors.Is(err, ErrRepositoryAccessDenied) {
+				return nil, fmt.Errorf("模板仓库不存在")
+			}
+			return nil, err
+		}
+		if !template.IsTemplate {
+			return nil, fmt.Errorf("仓库 '%s' 不是模板仓库", template.Name)
+		}
+	}
+
+	// 按可用空间和权重选择存储根目录
+	storageRoot, err := s.storage.SelectRoot()
+	if err != nil {
+		return nil, err
+	}
+
 	// 创建仓库记录
 	repo := &models.Repository{
 		ProjectID:     req.ProjectID,
@@ -105,6 +151,8 @@ func (s *repositoryService) Create(req *CreateRepositoryRequest) (*models.Reposi
 		DefaultBranch: req.DefaultBranch,
 		Language:      req.Language,
 		Settings:      req.Settings,
+		IsTemplate:    req.IsTemplate,
+		StorageRoot:   storageRoot,
 		CommitCount:   0,
 		BranchCount:   1, // 默认分支
 		TagCount:      0,
@@ -113,8 +161,15 @@ func (s *repositoryService) Create(req *CreateRepositoryRequest) (*models.Reposi
 
 	// 生成仓库URLs
 	repo.GitURL = fmt.Sprintf("git@localhost:%s/%s.git", req.ProjectID, req.Name)
-	repo.HTTPUrl = fmt.Sprintf("http://localhost:8004/%s/%s.git", req.ProjectID, req.Name)
-	repo.SSHUrl = fmt.Sprintf("git@localhost:2222/%s/%s.git", req.ProjectID, req.Name)
+	repo.HTTPURL = fmt.Sprintf("http://localhost:8004/%s/%s.git", req.ProjectID, req.Name)
+	repo.SSHURL = fmt.Sprintf("git@localhost:2222/%s/%s.git", req.ProjectID, req.Name)
+
+	if template != nil {
+		repo.TemplateID = &template.ID
+		if repo.Language == nil {
+			repo.Language = template.Language
+		}
+	}
 
 	// 处理Topics
 	if req.Topics != nil {
@@ -134,6 +189,16 @@ func (s *repositoryService) Create(req *CreateRepositoryRequest) (*models.Reposi
 		return nil, fmt.Errorf("初始化Git仓库失败: %w", err)
 	}
 
+	// 从模板复制内容
+	if template != nil {
+		if err := s.instantiateTemplate(repo, template, req.Template); err != nil {
+			s.db.Where("repository_id = ?", repo.ID).Delete(&models.Branch{})
+			s.db.Delete(repo)
+			os.RemoveAll(repositoryPath(s.config, repo))
+			return nil, fmt.Errorf("从模板创建仓库失败: %w", err)
+		}
+	}
+
 	return repo, nil
 }
 
@@ -220,6 +285,10 @@ func (s *repositoryService) Update(id uuid.UUID, req *UpdateRepositoryRequest) (
 		updates["settings"] = *req.Settings
 	}
 
+	if req.IsTemplate != nil {
+		updates["is_template"] = *req.IsTemplate
+	}
+
 	updates["updated_at"] = time.Now()
 
 	if err := s.db.Model(repo).Updates(updates).Error; err != nil {
@@ -262,6 +331,18 @@ func (s *repositoryService) GetStatistics(id uuid.UUID) (*RepositoryStats, error
 		TagCount:    repo.TagCount,
 	}
 
+	var insight models.RepositoryInsight
+	if err := s.db.Where("repository_id = ?", id).First(&insight).Error; err == nil {
+		_ = json.Unmarshal(insight.Languages, &stats.Languages)
+		_ = json.Unmarshal(insight.Contributors, &stats.Contributors)
+		_ = json.Unmarshal(insight.CommitActivity, &stats.CommitActivity)
+		_ = json.Unmarshal(insight.CodeFrequency, &stats.CodeFrequency)
+		_ = json.Unmarshal(insight.Topics, &stats.DetectedTopics)
+		stats.Truncated = insight.Truncated
+		stats.AnalyzedCommit = insight.CommitSHA
+		stats.AnalyzedAt = &insight.AnalyzedAt
+	}
+
 	return stats, nil
 }
 
@@ -273,9 +354,12 @@ func (s *repositoryService) UpdateStatistics(id uuid.UUID) error {
 	}
 
 	// 计算仓库大小
-	repoPath := filepath.Join(s.config.Git.RepositoryRoot, 
-		repo.ProjectID.String(), repo.Name)
-	size, err := s.GetRepositorySize(repoPath)
+	release, err := s.storage.Acquire(repo)
+	if err != nil {
+		return err
+	}
+	size, err := s.GetRepositorySize(repositoryPath(s.config, repo))
+	release()
 	if err != nil {
 		return fmt.Errorf("计算仓库大小失败: %w", err)
 	}
@@ -333,6 +417,10 @@ func (s *repositoryService) List(req *ListRepositoriesRequest) ([]models.Reposit
 		query = query.Where("name ILIKE ? OR description ILIKE ?", searchTerm, searchTerm)
 	}
 
+	if req.IsTemplate != nil {
+		query = query.Where("is_template = ?", *req.IsTemplate)
+	}
+
 	// 计算总数
 	var total int64
 	if err := query.Count(&total).Error; err != nil {
@@ -368,8 +456,7 @@ func (s *repositoryService) List(req *ListRepositoriesRequest) ([]models.Reposit
 
 // InitializeGitRepository 初始化Git仓库
 func (s *repositoryService) InitializeGitRepository(repo *models.Repository) error {
-	repoPath := filepath.Join(s.config.Git.RepositoryRoot, 
-		repo.ProjectID.String(), repo.Name)
+	repoPath := repositoryPath(s.config, repo)
 
 	// 创建目录
 	if err := os.MkdirAll(repoPath, 0755); err != nil {
@@ -395,6 +482,12 @@ func (s *repositoryService) InitializeGitRepository(repo *models.Repository) err
 		return fmt.Errorf("创建默认分支记录失败: %w", err)
 	}
 
+	// HEAD指向仓库默认分支
+	head := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName(repo.DefaultBranch))
+	if err := gitRepo.Storer.SetReference(head); err != nil {
+		return fmt.Errorf("设置默认分支失败: %w", err)
+	}
+
 	// 设置仓库配置
 	cfg, err := gitRepo.Config()
 	if err == nil {
@@ -424,4 +517,9 @@ func (s *repositoryService) GetRepositorySize(repoPath string) (int64, error) {
 	}
 	
 	return size, nil
-}
\ No newline at end of file
+}
+
+// repositoryPath 获取仓库在所属存储根目录下的裸仓库路径
+func repositoryPath(cfg *config.Config, repo *models.Repository) string {
+	return filepath.Join(cfg.Git.RootPath(repo.StorageRoot), repo.ProjectID.String(), repo.Name)
+}
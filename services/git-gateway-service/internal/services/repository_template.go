@@ -0,0 +1,348 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"git-gateway-service/internal/models"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/google/uuid"
+)
+
+// TemplateOptions 从模板仓库创建时的选项
+type TemplateOptions struct {
+	TemplateID         uuid.UUID         `json:"template_id" validate:"required"`
+	FullHistory        bool              `json:"full_history"`         // 保留完整历史，否则每个分支压缩为单个初始提交
+	IncludeAllBranches bool              `json:"include_all_branches"` // 复制所有分支，否则仅复制默认分支
+	Variables          map[string]string `json:"variables"`            // 自定义占位符，如 {"SERVICE_PORT": "8080"} 替换 {{SERVICE_PORT}}
+}
+
+// maxTemplateSubstitutionSize 执行占位符替换的最大文件大小
+const maxTemplateSubstitutionSize = 1 << 20
+
+// templateCommitter 模板实例化生成的提交签名
+var templateCommitter = object.Signature{Name: "Axiom", Email: "noreply@axiom.local"}
+
+// templateBranch 待复制的模板分支
+type templateBranch struct {
+	source string
+	target string
+	hash   plumbing.Hash
+}
+
+// instantiateTemplate 将模板仓库的分支复制到新仓库，并替换文件内容中的占位符
+func (s *repositoryService) instantiateTemplate(repo, template *models.Repository, opts *TemplateOptions) error {
+	templateRepo, releaseTemplate, err := s.storage.Open(template)
+	if err != nil {
+		return fmt.Errorf("打开模板仓库失败: %w", err)
+	}
+	defer releaseTemplate()
+
+	release, err := s.storage.Acquire(repo)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	templatePath := repositoryPath(s.config, template)
+	repoPath := repositoryPath(s.config, repo)
+
+	branches, err := templateBranches(templateRepo, template, repo, opts.IncludeAllBranches)
+	if err != nil {
+		return err
+	}
+	if len(branches) == 0 {
+		// 空模板，保持新仓库为空
+		return nil
+	}
+
+	if opts.FullHistory {
+		args := []string{"fetch", "--no-tags", templatePath}
+		for _, branch := range branches {
+			args = append(args, fmt.Sprintf("+refs/heads/%s:refs/heads/%s", branch.source, branch.target))
+		}
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoPath
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("复制模板历史失败: %s", strings.TrimSpace(string(output)))
+		}
+	}
+
+	// 复制历史后再打开新仓库，保证读取到fetch写入的打包文件
+	gitRepo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return fmt.Errorf("打开Git仓库失败: %w", err)
+	}
+
+	replacer := s.templateReplacer(repo, opts.Variables)
+	message := fmt.Sprintf("Initial commit from template %s", template.Name)
+	if opts.FullHistory {
+		message = "Apply template placeholders"
+	}
+
+	for _, branch := range branches {
+		tip, err := templateRepo.CommitObject(branch.hash)
+		if err != nil {
+			return fmt.Errorf("读取模板提交失败: %w", err)
+		}
+
+		treeHash, err := copyTemplateTree(templateRepo.Storer, gitRepo.Storer, tip.TreeHash, replacer)
+		if err != nil {
+			return err
+		}
+
+		var parents []plumbing.Hash
+		if opts.FullHistory {
+			if treeHash == tip.TreeHash {
+				continue
+			}
+			parents = []plumbing.Hash{tip.Hash}
+		}
+
+		commitHash, err := writeTemplateCommit(gitRepo.Storer, treeHash, parents, message)
+		if err != nil {
+			return err
+		}
+
+		ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(branch.target), commitHash)
+		if err := gitRepo.Storer.SetReference(ref); err != nil {
+			return fmt.Errorf("更新分支失败: %w", err)
+		}
+	}
+
+	return s.syncTemplateBranches(repo, gitRepo, branches)
+}
+
+// templateBranches 确定需要复制的分支，模板默认分支映射为新仓库默认分支
+func templateBranches(templateRepo *git.Repository, template, repo *models.Repository, all bool) ([]templateBranch, error) {
+	refs, err := templateRepo.Branches()
+	if err != nil {
+		return nil, fmt.Errorf("读取模板分支失败: %w", err)
+	}
+
+	var branches []templateBranch
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().Short()
+		switch {
+		case name == template.DefaultBranch:
+			branches = append(branches, templateBranch{source: name, target: repo.DefaultBranch, hash: ref.Hash()})
+		case all && name != repo.DefaultBranch:
+			branches = append(branches, templateBranch{source: name, target: name, hash: ref.Hash()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取模板分支失败: %w", err)
+	}
+
+	sort.Slice(branches, func(i, j int) bool {
+		return branches[i].target < branches[j].target
+	})
+	return branches, nil
+}
+
+// templateReplacer 构造占位符替换器，内置项目名、仓库名等占位符
+func (s *repositoryService) templateReplacer(repo *models.Repository, variables map[string]string) *strings.Replacer {
+	values := map[string]string{
+		"REPOSITORY_NAME": repo.Name,
+		"DEFAULT_BRANCH":  repo.DefaultBranch,
+		"PROJECT_ID":      repo.ProjectID.String(),
+		"YEAR":            fmt.Sprint(time.Now().Year()),
+	}
+	if repo.Description != nil {
+		values["REPOSITORY_DESCRIPTION"] = *repo.Description
+	}
+
+	var project models.Project
+	if err := s.db.Where("id = ?", repo.ProjectID).First(&project).Error; err == nil {
+		values["PROJECT_NAME"] = project.Name
+	}
+
+	for key, value := range variables {
+		values[key] = value
+	}
+
+	pairs := make([]string, 0, len(values)*2)
+	for key, value := range values {
+		pairs = append(pairs, "{{"+key+"}}", value)
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// copyTemplateTree 将树对象递归复制到目标存储，对文本文件执行占位符替换，返回新树哈希
+func copyTemplateTree(src, dst storer.EncodedObjectStorer, treeHash plumbing.Hash, replacer *strings.Replacer) (plumbing.Hash, error) {
+	tree, err := object.GetTree(src, treeHash)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("读取模板目录失败: %w", err)
+	}
+
+	entries := make([]object.TreeEntry, 0, len(tree.Entries))
+	for _, entry := range tree.Entries {
+		switch entry.Mode {
+		case filemode.Dir:
+			hash, err := copyTemplateTree(src, dst, entry.Hash, replacer)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			entry.Hash = hash
+		case filemode.Submodule:
+			// 子模块仅记录提交引用，无需复制对象
+		case filemode.Regular, filemode.Executable:
+			hash, err := copyTemplateBlob(src, dst, entry.Hash, replacer)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			entry.Hash = hash
+		default:
+			hash, err := copyTemplateBlob(src, dst, entry.Hash, nil)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			entry.Hash = hash
+		}
+		entries = append(entries, entry)
+	}
+
+	newTree := &object.Tree{Entries: entries}
+	obj := dst.NewEncodedObject()
+	if err := newTree.Encode(obj); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("写入目录失败: %w", err)
+	}
+	return storeTemplateObject(dst, obj)
+}
+
+// copyTemplateBlob 复制文件对象，replacer不为空时替换文本文件中的占位符
+func copyTemplateBlob(src, dst storer.EncodedObjectStorer, hash plumbing.Hash, replacer *strings.Replacer) (plumbing.Hash, error) {
+	blob, err := src.EncodedObject(plumbing.BlobObject, hash)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("读取模板文件失败: %w", err)
+	}
+
+	if replacer == nil || blob.Size() > maxTemplateSubstitutionSize {
+		if src == dst {
+			return hash, nil
+		}
+		return storeTemplateObject(dst, blob)
+	}
+
+	reader, err := blob.Reader()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("读取模板文件失败: %w", err)
+	}
+	content, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("读取模板文件失败: %w", err)
+	}
+
+	// 二进制文件不做替换
+	if !bytes.Contains(content, []byte{0}) {
+		content = []byte(replacer.Replace(string(content)))
+	}
+
+	obj := dst.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("写入文件失败: %w", err)
+	}
+	if _, err := w.Write(content); err != nil {
+		w.Close()
+		return plumbing.ZeroHash, fmt.Errorf("写入文件失败: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("写入文件失败: %w", err)
+	}
+	return storeTemplateObject(dst, obj)
+}
+
+// storeTemplateObject 写入对象，目标存储中已存在时跳过
+func storeTemplateObject(dst storer.EncodedObjectStorer, obj plumbing.EncodedObject) (plumbing.Hash, error) {
+	if dst.HasEncodedObject(obj.Hash()) == nil {
+		return obj.Hash(), nil
+	}
+	hash, err := dst.SetEncodedObject(obj)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("写入对象失败: %w", err)
+	}
+	return hash, nil
+}
+
+// writeTemplateCommit 创建提交对象
+func writeTemplateCommit(dst storer.EncodedObjectStorer, treeHash plumbing.Hash, parents []plumbing.Hash, message string) (plumbing.Hash, error) {
+	signature := templateCommitter
+	signature.When = time.Now()
+
+	commit := &object.Commit{
+		Author:       signature,
+		Committer:    signature,
+		Message:      message + "\n",
+		TreeHash:     treeHash,
+		ParentHashes: parents,
+	}
+
+	obj := dst.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("创建提交失败: %w", err)
+	}
+	return storeTemplateObject(dst, obj)
+}
+
+// syncTemplateBranches 同步分支记录与仓库统计
+func (s *repositoryService) syncTemplateBranches(repo *models.Repository, gitRepo *git.Repository, branches []templateBranch) error {
+	for _, branch := range branches {
+		ref, err := gitRepo.Reference(plumbing.NewBranchReferenceName(branch.target), true)
+		if err != nil {
+			return fmt.Errorf("读取分支失败: %w", err)
+		}
+
+		if branch.target == repo.DefaultBranch {
+			if err := s.db.Model(&models.Branch{}).
+				Where("repository_id = ? AND name = ?", repo.ID, branch.target).
+				Updates(map[string]interface{}{
+					"commit_sha": ref.Hash().String(),
+					"updated_at": time.Now(),
+				}).Error; err != nil {
+				return fmt.Errorf("更新默认分支记录失败: %w", err)
+			}
+			continue
+		}
+
+		record := &models.Branch{
+			RepositoryID: repo.ID,
+			Name:         branch.target,
+			CommitSHA:    ref.Hash().String(),
+		}
+		if err := s.db.Create(record).Error; err != nil {
+			return fmt.Errorf("创建分支记录失败: %w", err)
+		}
+	}
+
+	commitCount := 0
+	if head, err := gitRepo.Reference(plumbing.NewBranchReferenceName(repo.DefaultBranch), true); err == nil {
+		if iter, err := gitRepo.Log(&git.LogOptions{From: head.Hash()}); err == nil {
+			_ = iter.ForEach(func(*object.Commit) error {
+				commitCount++
+				return nil
+			})
+		}
+	}
+
+	repo.BranchCount = len(branches)
+	repo.CommitCount = commitCount
+	return s.db.Model(repo).Updates(map[string]interface{}{
+		"branch_count": repo.BranchCount,
+		"commit_count": repo.CommitCount,
+		"updated_at":   time.Now(),
+	}).Error
+}
@@ -0,0 +1,494 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"time"
+
+	"git-gateway-service/internal/models"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// SigningKeyService 签名密钥服务接口
+type SigningKeyService interface {
+	Create(req *CreateSigningKeyRequest) (*models.SigningKey, error)
+	GetByID(id, userID uuid.UUID) (*models.SigningKey, error)
+	GetByUser(userID uuid.UUID) ([]models.SigningKey, error)
+	Delete(id, userID uuid.UUID) error
+	VerifyCommit(commit *object.Commit) *SignatureVerification
+	VerifyTag(tag *object.Tag) *SignatureVerification
+}
+
+type signingKeyService struct {
+	db *gorm.DB
+}
+
+// NewSigningKeyService 创建签名密钥服务实例
+func NewSigningKeyService(db *gorm.DB) SigningKeyService {
+	return &signingKeyService{db: db}
+}
+
+// 签名密钥类型
+const (
+	SigningKeyTypeGPG = "gpg"
+	SigningKeyTypeSSH = "ssh"
+)
+
+// 签名验证状态
+const (
+	SignatureStatusVerified   = "verified"    // 签名有效且与签名者邮箱匹配
+	SignatureStatusUnverified = "unverified"  // 签名无效、密钥过期或邮箱不匹配
+	SignatureStatusUnknownKey = "unknown_key" // 未找到已登记的签名密钥
+	SignatureStatusUnsigned   = "unsigned"    // 未签名
+)
+
+// ErrSigningKeyNotFound 签名密钥不存在或不属于当前用户
+var ErrSigningKeyNotFound = errors.New("签名密钥不存在")
+
+// sshSignatureNamespace git使用的SSH签名命名空间
+const sshSignatureNamespace = "git"
+
+// CreateSigningKeyRequest 创建签名密钥请求
+type CreateSigningKeyRequest struct {
+	UserID    uuid.UUID  `json:"-"` // 由处理器按当前登录用户填写，不接受请求体指定
+	Title     string     `json:"title" validate:"required,max=255"`
+	KeyType   string     `json:"key_type" validate:"required,oneof=gpg ssh"`
+	PublicKey string     `json:"public_key" validate:"required"`
+	ExpiresAt *time.Time `json:"expires_at"` // 仅SSH密钥，GPG密钥的过期时间从密钥本身读取
+}
+
+// SignatureVerification 签名验证结果
+type SignatureVerification struct {
+	Verified      bool       `json:"verified"`
+	Status        string     `json:"status"`
+	Reason        string     `json:"reason,omitempty"`
+	SignatureType string     `json:"signature_type,omitempty"` // gpg, ssh
+	KeyID         string     `json:"key_id,omitempty"`         // GPG密钥ID或SSH密钥指纹
+	SigningKeyID  *uuid.UUID `json:"signing_key_id,omitempty"`
+	SignerID      *uuid.UUID `json:"signer_id,omitempty"`
+}
+
+// Create 登记签名密钥
+func (s *signingKeyService) Create(req *CreateSigningKeyRequest) (*models.SigningKey, error) {
+	key := &models.SigningKey{
+		UserID:    req.UserID,
+		Title:     req.Title,
+		KeyType:   req.KeyType,
+		PublicKey: strings.TrimSpace(req.PublicKey),
+	}
+
+	switch req.KeyType {
+	case SigningKeyTypeGPG:
+		if err := parseGPGSigningKey(key); err != nil {
+			return nil, err
+		}
+	case SigningKeyTypeSSH:
+		publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key.PublicKey))
+		if err != nil {
+			return nil, fmt.Errorf("SSH公钥格式无效: %w", err)
+		}
+		key.Fingerprint = ssh.FingerprintSHA256(publicKey)
+		key.ExpiresAt = req.ExpiresAt
+		key.SubkeyIDs = datatypes.JSON("[]")
+		key.Emails = datatypes.JSON("[]")
+	default:
+		return nil, fmt.Errorf("不支持的签名密钥类型: %s", req.KeyType)
+	}
+
+	var existing models.SigningKey
+	if err := s.db.Where("fingerprint = ?", key.Fingerprint).First(&existing).Error; err == nil {
+		return nil, fmt.Errorf("相同指纹的签名密钥已存在")
+	}
+
+	if err := s.db.Create(key).Error; err != nil {
+		return nil, fmt.Errorf("创建签名密钥失败: %w", err)
+	}
+
+	return key, nil
+}
+
+// GetByID 根据ID获取用户自己的签名密钥，其他用户的密钥按不存在处理
+func (s *signingKeyService) GetByID(id, userID uuid.UUID) (*models.SigningKey, error) {
+	var key models.SigningKey
+	if err := s.db.Where("id = ? AND user_id = ?", id, userID).First(&key).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrSigningKeyNotFound
+		}
+		return nil, fmt.Errorf("获取签名密钥失败: %w", err)
+	}
+	return &key, nil
+}
+
+// GetByUser 获取用户的所有签名密钥
+func (s *signingKeyService) GetByUser(userID uuid.UUID) ([]models.SigningKey, error) {
+	var keys []models.SigningKey
+	if err := s.db.Where("user_id = ?", userID).
+		Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("获取用户签名密钥失败: %w", err)
+	}
+	return keys, nil
+}
+
+// Delete 删除用户自己的签名密钥
+func (s *signingKeyService) Delete(id, userID uuid.UUID) error {
+	key, err := s.GetByID(id, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Delete(key).Error; err != nil {
+		return fmt.Errorf("删除签名密钥失败: %w", err)
+	}
+	return nil
+}
+
+// VerifyCommit 验证提交签名
+func (s *signingKeyService) VerifyCommit(commit *object.Commit) *SignatureVerification {
+	if commit.PGPSignature == "" {
+		return &SignatureVerification{Status: SignatureStatusUnsigned}
+	}
+
+	encoded := &plumbing.MemoryObject{}
+	if err := commit.EncodeWithoutSignature(encoded); err != nil {
+		return unverifiedSignature("", fmt.Sprintf("读取提交内容失败: %v", err))
+	}
+
+	return s.verify(encoded, commit.PGPSignature, commit.Committer.Email)
+}
+
+// VerifyTag 验证附注标签签名
+func (s *signingKeyService) VerifyTag(tag *object.Tag) *SignatureVerification {
+	if tag.PGPSignature == "" {
+		return &SignatureVerification{Status: SignatureStatusUnsigned}
+	}
+
+	encoded := &plumbing.MemoryObject{}
+	if err := tag.EncodeWithoutSignature(encoded); err != nil {
+		return unverifiedSignature("", fmt.Sprintf("读取标签内容失败: %v", err))
+	}
+
+	return s.verify(encoded, tag.PGPSignature, tag.Tagger.Email)
+}
+
+// verify 按签名格式分发验证
+// 签名时间和提交时间都由签名者填写，不可信，密钥有效期一律按验证时的当前时间校验
+func (s *signingKeyService) verify(encoded *plumbing.MemoryObject, signature, email string) *SignatureVerification {
+	reader, err := encoded.Reader()
+	if err != nil {
+		return unverifiedSignature("", fmt.Sprintf("读取签名内容失败: %v", err))
+	}
+	defer reader.Close()
+
+	payload, err := io.ReadAll(reader)
+	if err != nil {
+		return unverifiedSignature("", fmt.Sprintf("读取签名内容失败: %v", err))
+	}
+
+	if strings.HasPrefix(strings.TrimSpace(signature), "-----BEGIN SSH SIGNATURE-----") {
+		return s.verifySSH(payload, signature, email, time.Now())
+	}
+	return s.verifyGPG(payload, signature, email, time.Now())
+}
+
+// verifyGPG 验证OpenPGP签名
+func (s *signingKeyService) verifyGPG(payload []byte, signature, email string, now time.Time) *SignatureVerification {
+	sig, err := readGPGSignature(signature)
+	if err != nil {
+		return unverifiedSignature(SigningKeyTypeGPG, err.Error())
+	}
+	if sig.IssuerKeyId == nil {
+		return unverifiedSignature(SigningKeyTypeGPG, "签名缺少签发者密钥ID")
+	}
+
+	keyID := fmt.Sprintf("%016X", *sig.IssuerKeyId)
+	result := &SignatureVerification{SignatureType: SigningKeyTypeGPG, KeyID: keyID}
+
+	var key models.SigningKey
+	subkeyFilter, _ := json.Marshal([]string{keyID})
+	err = s.db.Where("key_type = ? AND (key_id = ? OR subkey_ids @> ?)", SigningKeyTypeGPG, keyID, string(subkeyFilter)).
+		First(&key).Error
+	if err != nil {
+		result.Status = SignatureStatusUnknownKey
+		result.Reason = "未找到已登记的GPG密钥"
+		return result
+	}
+	result.SigningKeyID = &key.ID
+	result.SignerID = &key.UserID
+
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key.PublicKey))
+	if err != nil {
+		result.Status = SignatureStatusUnverified
+		result.Reason = fmt.Sprintf("解析GPG公钥失败: %v", err)
+		return result
+	}
+
+	config := &packet.Config{Time: func() time.Time { return now }}
+	if _, err := openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(payload), strings.NewReader(signature), config); err != nil {
+		result.Status = SignatureStatusUnverified
+		result.Reason = fmt.Sprintf("签名无效: %v", err)
+		return result
+	}
+
+	// 密钥中的UID由上传者自行填写，签名者邮箱还必须是密钥所有者已验证的邮箱
+	var emails []string
+	_ = json.Unmarshal(key.Emails, &emails)
+	if !containsEmail(emails, email) {
+		result.Status = SignatureStatusUnverified
+		result.Reason = "签名者邮箱与GPG密钥身份不匹配"
+		return result
+	}
+	if reason := s.checkOwnerEmail(key.UserID, email); reason != "" {
+		result.Status = SignatureStatusUnverified
+		result.Reason = reason
+		return result
+	}
+
+	result.Verified = true
+	result.Status = SignatureStatusVerified
+	return result
+}
+
+// verifySSH 验证SSH签名（OpenSSH sshsig格式）
+func (s *signingKeyService) verifySSH(payload []byte, signature, email string, now time.Time) *SignatureVerification {
+	sig, err := parseSSHSignature(signature)
+	if err != nil {
+		return unverifiedSignature(SigningKeyTypeSSH, err.Error())
+	}
+
+	fingerprint := ssh.FingerprintSHA256(sig.publicKey)
+	result := &SignatureVerification{SignatureType: SigningKeyTypeSSH, KeyID: fingerprint}
+
+	var key models.SigningKey
+	if err := s.db.Where("key_type = ? AND fingerprint = ?", SigningKeyTypeSSH, fingerprint).First(&key).Error; err != nil {
+		result.Status = SignatureStatusUnknownKey
+		result.Reason = "未找到已登记的SSH签名密钥"
+		return result
+	}
+	result.SigningKeyID = &key.ID
+	result.SignerID = &key.UserID
+
+	if err := sig.verify(payload); err != nil {
+		result.Status = SignatureStatusUnverified
+		result.Reason = err.Error()
+		return result
+	}
+
+	if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+		result.Status = SignatureStatusUnverified
+		result.Reason = "SSH签名密钥已过期"
+		return result
+	}
+
+	if reason := s.checkOwnerEmail(key.UserID, email); reason != "" {
+		result.Status = SignatureStatusUnverified
+		result.Reason = reason
+		return result
+	}
+
+	result.Verified = true
+	result.Status = SignatureStatusVerified
+	return result
+}
+
+// checkOwnerEmail 校验签名者邮箱是否为密钥所有者已验证的邮箱，不通过时返回原因
+func (s *signingKeyService) checkOwnerEmail(userID uuid.UUID, email string) string {
+	var user models.User
+	if err := s.db.Select("id", "email", "email_verified").Where("id = ?", userID).First(&user).Error; err != nil {
+		return "无法确认密钥所有者"
+	}
+	if !user.EmailVerified {
+		return "密钥所有者的邮箱尚未验证"
+	}
+	if !containsEmail([]string{user.Email}, email) {
+		return "签名者邮箱与密钥所有者不匹配"
+	}
+	return ""
+}
+
+// parseGPGSigningKey 解析GPG公钥，填充密钥ID、子密钥、邮箱与过期时间
+func parseGPGSigningKey(key *models.SigningKey) error {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key.PublicKey))
+	if err != nil {
+		return fmt.Errorf("GPG公钥格式无效: %w", err)
+	}
+	if len(entities) != 1 {
+		return fmt.Errorf("每次只能登记一个GPG公钥")
+	}
+
+	entity := entities[0]
+	keyID := fmt.Sprintf("%016X", entity.PrimaryKey.KeyId)
+	key.KeyID = &keyID
+	key.Fingerprint = fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)
+
+	subkeyIDs := make([]string, 0, len(entity.Subkeys))
+	for _, subkey := range entity.Subkeys {
+		subkeyIDs = append(subkeyIDs, fmt.Sprintf("%016X", subkey.PublicKey.KeyId))
+	}
+
+	emails := make([]string, 0, len(entity.Identities))
+	for _, identity := range entity.Identities {
+		if identity.UserId != nil && identity.UserId.Email != "" {
+			emails = append(emails, strings.ToLower(identity.UserId.Email))
+		}
+	}
+
+	if identity := entity.PrimaryIdentity(); identity != nil && identity.SelfSignature != nil {
+		if lifetime := identity.SelfSignature.KeyLifetimeSecs; lifetime != nil && *lifetime > 0 {
+			expiresAt := entity.PrimaryKey.CreationTime.Add(time.Duration(*lifetime) * time.Second)
+			key.ExpiresAt = &expiresAt
+		}
+	}
+
+	subkeyJSON, _ := json.Marshal(subkeyIDs)
+	emailJSON, _ := json.Marshal(emails)
+	key.SubkeyIDs = datatypes.JSON(subkeyJSON)
+	key.Emails = datatypes.JSON(emailJSON)
+	return nil
+}
+
+// readGPGSignature 读取ASCII armored签名中的签名包
+func readGPGSignature(signature string) (*packet.Signature, error) {
+	block, err := armor.Decode(strings.NewReader(signature))
+	if err != nil {
+		return nil, fmt.Errorf("GPG签名格式无效: %w", err)
+	}
+
+	p, err := packet.Read(block.Body)
+	if err != nil {
+		return nil, fmt.Errorf("GPG签名格式无效: %w", err)
+	}
+
+	sig, ok := p.(*packet.Signature)
+	if !ok {
+		return nil, fmt.Errorf("GPG签名格式无效")
+	}
+	return sig, nil
+}
+
+// sshSignature 解析后的sshsig签名
+type sshSignature struct {
+	publicKey     ssh.PublicKey
+	namespace     string
+	reserved      string
+	hashAlgorithm string
+	signature     *ssh.Signature
+}
+
+// parseSSHSignature 解析 -----BEGIN SSH SIGNATURE----- 格式的签名
+func parseSSHSignature(armored string) (*sshSignature, error) {
+	var body strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(armored), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "-----") {
+			continue
+		}
+		body.WriteString(line)
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(body.String())
+	if err != nil {
+		return nil, fmt.Errorf("SSH签名格式无效: %w", err)
+	}
+	if !bytes.HasPrefix(blob, []byte("SSHSIG")) {
+		return nil, fmt.Errorf("SSH签名格式无效")
+	}
+
+	var wire struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}
+	if err := ssh.Unmarshal(blob[len("SSHSIG"):], &wire); err != nil {
+		return nil, fmt.Errorf("SSH签名格式无效: %w", err)
+	}
+	if wire.Version != 1 {
+		return nil, fmt.Errorf("不支持的SSH签名版本: %d", wire.Version)
+	}
+
+	publicKey, err := ssh.ParsePublicKey(wire.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("SSH签名公钥无效: %w", err)
+	}
+
+	sig := &ssh.Signature{}
+	if err := ssh.Unmarshal(wire.Signature, sig); err != nil {
+		return nil, fmt.Errorf("SSH签名格式无效: %w", err)
+	}
+
+	return &sshSignature{
+		publicKey:     publicKey,
+		namespace:     wire.Namespace,
+		reserved:      wire.Reserved,
+		hashAlgorithm: wire.HashAlgorithm,
+		signature:     sig,
+	}, nil
+}
+
+// verify 校验签名是否覆盖payload
+func (s *sshSignature) verify(payload []byte) error {
+	if s.namespace != sshSignatureNamespace {
+		return fmt.Errorf("SSH签名命名空间无效: %s", s.namespace)
+	}
+
+	var h hash.Hash
+	switch s.hashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("不支持的SSH签名哈希算法: %s", s.hashAlgorithm)
+	}
+	h.Write(payload)
+
+	signed := append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{s.namespace, s.reserved, s.hashAlgorithm, h.Sum(nil)})...)
+
+	if err := s.publicKey.Verify(signed, s.signature); err != nil {
+		return fmt.Errorf("签名无效: %w", err)
+	}
+	return nil
+}
+
+// unverifiedSignature 构造无法验证的结果
+func unverifiedSignature(signatureType, reason string) *SignatureVerification {
+	return &SignatureVerification{
+		Status:        SignatureStatusUnverified,
+		Reason:        reason,
+		SignatureType: signatureType,
+	}
+}
+
+// containsEmail 不区分大小写地判断邮箱是否在列表中
+func containsEmail(emails []string, email string) bool {
+	for _, e := range emails {
+		if strings.EqualFold(e, email) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+// signSSH 按 ssh-keygen -Y sign 的格式生成armored签名
+func signSSH(t *testing.T, signer ssh.Signer, payload []byte, namespace, hashAlgorithm string) string {
+	t.Helper()
+
+	var digest []byte
+	switch hashAlgorithm {
+	case "sha512":
+		sum := sha512.Sum512(payload)
+		digest = sum[:]
+	default:
+		sum := sha256.Sum256(payload)
+		digest = sum[:]
+	}
+
+	signed := append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{namespace, "", hashAlgorithm, digest})...)
+
+	sig, err := signer.Sign(rand.Reader, signed)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	blob := append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}{1, signer.PublicKey().Marshal(), namespace, "", hashAlgorithm, ssh.Marshal(sig)})...)
+
+	encoded := base64.StdEncoding.EncodeToString(blob)
+	var b strings.Builder
+	b.WriteString("-----BEGIN SSH SIGNATURE-----\n")
+	for len(encoded) > 70 {
+		b.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	b.WriteString(encoded + "\n-----END SSH SIGNATURE-----\n")
+	return b.String()
+}
+
+func newTestSSHSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		t.Fatalf("signer: %v", err)
+	}
+	return signer
+}
+
+func TestSSHSignatureVerify(t *testing.T) {
+	signer := newTestSSHSigner(t)
+	other := newTestSSHSigner(t)
+	payload := []byte("tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n\ninitial commit\n")
+
+	tests := []struct {
+		name      string
+		signature string
+		payload   []byte
+		wantErr   bool
+	}{
+		{"sha512 valid", signSSH(t, signer, payload, "git", "sha512"), payload, false},
+		{"sha256 valid", signSSH(t, signer, payload, "git", "sha256"), payload, false},
+		{"tampered payload", signSSH(t, signer, payload, "git", "sha512"), append([]byte("x"), payload...), true},
+		{"wrong namespace", signSSH(t, signer, payload, "file", "sha512"), payload, true},
+		{"unsupported hash", signSSH(t, signer, payload, "git", "md5"), payload, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig, err := parseSSHSignature(tt.signature)
+			if err != nil {
+				t.Fatalf("parseSSHSignature: %v", err)
+			}
+			if got, want := ssh.FingerprintSHA256(sig.publicKey), ssh.FingerprintSHA256(signer.PublicKey()); got != want {
+				t.Fatalf("fingerprint = %s, want %s", got, want)
+			}
+			if err := sig.verify(tt.payload); (err != nil) != tt.wantErr {
+				t.Fatalf("verify error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("public key swapped", func(t *testing.T) {
+		sig, err := parseSSHSignature(signSSH(t, signer, payload, "git", "sha512"))
+		if err != nil {
+			t.Fatalf("parseSSHSignature: %v", err)
+		}
+		sig.publicKey = other.PublicKey()
+		if err := sig.verify(payload); err == nil {
+			t.Fatal("expected verification with a different key to fail")
+		}
+	})
+}
+
+func TestParseSSHSignatureInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		signature string
+	}{
+		{"not base64", "-----BEGIN SSH SIGNATURE-----\n!!!\n-----END SSH SIGNATURE-----"},
+		{"missing magic", "-----BEGIN SSH SIGNATURE-----\n" + base64.StdEncoding.EncodeToString([]byte("NOTSIG")) + "\n-----END SSH SIGNATURE-----"},
+		{"truncated", "-----BEGIN SSH SIGNATURE-----\n" + base64.StdEncoding.EncodeToString([]byte("SSHSIG\x00\x00")) + "\n-----END SSH SIGNATURE-----"},
+		{
+			"unsupported version",
+			"-----BEGIN SSH SIGNATURE-----\n" + base64.StdEncoding.EncodeToString(append([]byte("SSHSIG"), ssh.Marshal(struct {
+				Version       uint32
+				PublicKey     []byte
+				Namespace     string
+				Reserved      string
+				HashAlgorithm string
+				Signature     []byte
+			}{2, nil, "git", "", "sha512", nil})...)) + "\n-----END SSH SIGNATURE-----",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseSSHSignature(tt.signature); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestContainsEmail(t *testing.T) {
+	emails := []string{"dev@example.com", "ops@example.com"}
+	tests := []struct {
+		email string
+		want  bool
+	}{
+		{"dev@example.com", true},
+		{"DEV@Example.com", true},
+		{"ceo@example.com", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := containsEmail(emails, tt.email); got != tt.want {
+			t.Errorf("containsEmail(%q) = %v, want %v", tt.email, got, tt.want)
+		}
+	}
+}
+
+// signingKeyTestDB 创建只含用户和签名密钥表的临时数据库
+func signingKeyTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return newTestDB(t, 1,
+		"CREATE TABLE users (id TEXT PRIMARY KEY, email TEXT, full_name TEXT, email_verified BOOLEAN)",
+		`CREATE TABLE signing_keys (id TEXT PRIMARY KEY, user_id TEXT, title TEXT, key_type TEXT, public_key TEXT,
+			key_id TEXT, subkey_ids TEXT, fingerprint TEXT UNIQUE, emails TEXT, expires_at DATETIME,
+			created_at DATETIME, updated_at DATETIME)`,
+	)
+}
+
+// signedCommit 构造由 signer 签名、提交者邮箱为 email 的提交
+func signedCommit(t *testing.T, signer ssh.Signer, email string) *object.Commit {
+	t.Helper()
+	when := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	commit := &object.Commit{
+		Author:    object.Signature{Name: "dev", Email: email, When: when},
+		Committer: object.Signature{Name: "dev", Email: email, When: when},
+		Message:   "change\n",
+		TreeHash:  plumbing.NewHash("4b825dc642cb6eb9a060e54bf8d69288fbee4904"),
+	}
+	encoded := &plumbing.MemoryObject{}
+	if err := commit.EncodeWithoutSignature(encoded); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	reader, _ := encoded.Reader()
+	payload, _ := io.ReadAll(reader)
+	commit.PGPSignature = signSSH(t, signer, payload, sshSignatureNamespace, "sha512")
+	return commit
+}
+
+func TestSigningKeyOwnerVerification(t *testing.T) {
+	victim := uuid.New()
+	attacker := uuid.New()
+
+	tests := []struct {
+		name  string
+		owner uuid.UUID
+		want  string
+	}{
+		{"key registered by committer", victim, SignatureStatusVerified},
+		{"key registered by another user", attacker, SignatureStatusUnverified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := signingKeyTestDB(t)
+			db.Exec("INSERT INTO users (id, email, email_verified) VALUES (?, ?, ?), (?, ?, ?)",
+				victim, "victim@example.com", true, attacker, "attacker@example.com", true)
+			s := &signingKeyService{db: db}
+
+			signer := newTestSSHSigner(t)
+			key, err := s.Create(&CreateSigningKeyRequest{
+				UserID:    tt.owner,
+				Title:     "laptop",
+				KeyType:   SigningKeyTypeSSH,
+				PublicKey: string(ssh.MarshalAuthorizedKey(signer.PublicKey())),
+			})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			result := s.VerifyCommit(signedCommit(t, signer, "victim@example.com"))
+			if result.Status != tt.want {
+				t.Fatalf("status = %q (%s), want %q", result.Status, result.Reason, tt.want)
+			}
+			if result.SignerID == nil || *result.SignerID != tt.owner {
+				t.Fatalf("signer = %v, want %s", result.SignerID, tt.owner)
+			}
+
+			// 其他用户不能读取或删除该密钥
+			other := victim
+			if tt.owner == victim {
+				other = attacker
+			}
+			if _, err := s.GetByID(key.ID, other); !errors.Is(err, ErrSigningKeyNotFound) {
+				t.Fatalf("GetByID by other user: %v, want ErrSigningKeyNotFound", err)
+			}
+			if err := s.Delete(key.ID, other); !errors.Is(err, ErrSigningKeyNotFound) {
+				t.Fatalf("Delete by other user: %v, want ErrSigningKeyNotFound", err)
+			}
+			if err := s.Delete(key.ID, tt.owner); err != nil {
+				t.Fatalf("Delete by owner: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,563 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"git-gateway-service/internal/config"
+	"git-gateway-service/internal/models"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StorageService 仓库存储服务接口（多存储根目录的放置与迁移）
+type StorageService interface {
+	ListRoots() ([]StorageRootInfo, error)
+	SelectRoot() (string, error)
+	MoveRepository(repositoryID uuid.UUID, root string) (*models.Repository, error)
+	Acquire(repo *models.Repository) (func(), error)
+	Open(repo *models.Repository) (*git.Repository, func(), error)
+}
+
+type storageService struct {
+	db     *gorm.DB
+	config *config.Config
+}
+
+// NewStorageService 创建仓库存储服务实例
+func NewStorageService(db *gorm.DB, cfg *config.Config) StorageService {
+	return &storageService{
+		db:     db,
+		config: cfg,
+	}
+}
+
+// storageLockMove 迁移仓库的咨询锁用途，与仓库ID一起决定锁键，所有网关副本共享
+const storageLockMove = "repository-move"
+
+const (
+	// storageLeaseTTL 仓库使用租约的有效期，持有期间每 storageLeaseTTL/3 续期一次
+	storageLeaseTTL = 2 * time.Minute
+	// storageLeasePoll 等待迁移切换结束或进行中的读写结束时的轮询间隔
+	storageLeasePoll = 200 * time.Millisecond
+	// storageDrainTimeout 迁移切换前等待进行中的读写结束的最长时间
+	storageDrainTimeout = 5 * time.Minute
+	// storageSwitchTTL 独占租约的有效期，迁移实例在切换中退出时到期失效
+	storageSwitchTTL = 10 * time.Minute
+)
+
+// StorageRootInfo 存储根目录状态
+type StorageRootInfo struct {
+	Name            string `json:"name"`
+	Path            string `json:"path"`
+	Weight          int    `json:"weight"`
+	TotalBytes      uint64 `json:"total_bytes"`
+	FreeBytes       uint64 `json:"free_bytes"`
+	RepositoryCount int64  `json:"repository_count"`
+	Available       bool   `json:"available"` // 可用空间高于保留空间且权重大于0时可放置新仓库
+	Error           string `json:"error,omitempty"`
+}
+
+// ListRoots 获取所有存储根目录的容量和仓库数量
+func (s *storageService) ListRoots() ([]StorageRootInfo, error) {
+	roots := s.config.Git.Roots()
+	infos := make([]StorageRootInfo, 0, len(roots))
+	for _, root := range roots {
+		info := s.rootInfo(root)
+
+		if err := s.db.Model(&models.Repository{}).
+			Where("storage_root = ? AND deleted_at IS NULL", root.Name).
+			Count(&info.RepositoryCount).Error; err != nil {
+			return nil, fmt.Errorf("统计仓库数量失败: %w", err)
+		}
+
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// SelectRoot 为新仓库选择存储根目录：在可用空间高于保留空间的根目录中，选择 可用空间×权重 最大者
+func (s *storageService) SelectRoot() (string, error) {
+	var selected string
+	var bestScore float64
+	for _, root := range s.config.Git.Roots() {
+		info := s.rootInfo(root)
+		if !info.Available {
+			continue
+		}
+		score := float64(info.FreeBytes) * float64(root.Weight)
+		if selected == "" || score > bestScore {
+			selected, bestScore = root.Name, score
+		}
+	}
+	if selected == "" {
+		return "", fmt.Errorf("没有可用空间充足的存储根目录")
+	}
+	return selected, nil
+}
+
+// rootInfo 读取存储根目录的磁盘容量
+func (s *storageService) rootInfo(root config.StorageRootConfig) StorageRootInfo {
+	info := StorageRootInfo{
+		Name:   root.Name,
+		Path:   root.Path,
+		Weight: root.Weight,
+	}
+
+	if err := os.MkdirAll(root.Path, 0755); err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(root.Path, &stat); err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	info.TotalBytes = stat.Blocks * uint64(stat.Bsize)
+	info.FreeBytes = stat.Bavail * uint64(stat.Bsize)
+
+	// 至少保留一个最大仓库的空间
+	reserve := uint64(s.config.Git.MaxRepositorySize) * 1024 * 1024
+	info.Available = root.Weight > 0 && info.FreeBytes > reserve
+	return info
+}
+
+// Acquire 获取仓库的共享使用租约（跨副本生效），迁移仓库的最后阶段会等待所有租约释放
+// 租约在短事务内发放，持有期间不占用数据库连接；返回前刷新仓库的存储根目录，保证持有期间访问的是当前位置。
+// 同一调用链中不要重复获取，迁移切换开始后新的获取会等待，而切换在等待已持有的租约
+func (s *storageService) Acquire(repo *models.Repository) (func(), error) {
+	for {
+		lease, root, err := s.grantLease(repo.ID)
+		if err != nil {
+			return nil, err
+		}
+		if lease != nil {
+			repo.StorageRoot = root
+			return s.holdLease(lease), nil
+		}
+		time.Sleep(storageLeasePoll)
+	}
+}
+
+// grantLease 在短事务内发放共享租约并读取当前存储位置，仓库正在切换存储位置时返回nil
+func (s *storageService) grantLease(id uuid.UUID) (*models.RepositoryStorageLease, string, error) {
+	var lease *models.RepositoryStorageLease
+	var root string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 共享行锁与迁移写入独占租约时的排他行锁互斥：租约要么在迁移开始等待前已提交，要么能看到独占租约
+		var current models.Repository
+		if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).Select("id", "storage_root").
+			Where("id = ?", id).First(&current).Error; err != nil {
+			return fmt.Errorf("获取仓库存储位置失败: %w", err)
+		}
+
+		now := time.Now()
+		var switching int64
+		if err := tx.Model(&models.RepositoryStorageLease{}).
+			Where("repository_id = ? AND exclusive = ? AND expires_at > ?", id, true, now).
+			Count(&switching).Error; err != nil {
+			return fmt.Errorf("获取仓库锁失败: %w", err)
+		}
+		if switching > 0 {
+			return nil
+		}
+
+		lease = &models.RepositoryStorageLease{
+			ID:           uuid.New(),
+			RepositoryID: id,
+			ExpiresAt:    now.Add(storageLeaseTTL),
+			CreatedAt:    now,
+		}
+		if err := tx.Create(lease).Error; err != nil {
+			lease = nil
+			return fmt.Errorf("获取仓库锁失败: %w", err)
+		}
+		root = current.StorageRoot
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return lease, root, nil
+}
+
+// holdLease 在后台定期续期租约，返回的函数停止续期并删除租约，可重复调用
+func (s *storageService) holdLease(lease *models.RepositoryStorageLease) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(storageLeaseTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := s.db.Model(lease).Update("expires_at", time.Now().Add(storageLeaseTTL)).Error; err != nil {
+					fmt.Printf("续期仓库租约失败 [%s]: %v\n", lease.RepositoryID, err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			<-done
+			if err := s.db.Delete(lease).Error; err != nil {
+				fmt.Printf("释放仓库租约失败 [%s]: %v\n", lease.RepositoryID, err)
+			}
+		})
+	}
+}
+
+// beginSwitch 写入独占租约阻止新的读写，并等待进行中的读写结束；超时未结束时撤销独占租约并返回错误
+func (s *storageService) beginSwitch(id uuid.UUID) (func(), error) {
+	now := time.Now()
+	lease := &models.RepositoryStorageLease{
+		ID:           uuid.New(),
+		RepositoryID: id,
+		Exclusive:    true,
+		ExpiresAt:    now.Add(storageSwitchTTL),
+		CreatedAt:    now,
+	}
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		var current models.Repository
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+			Where("id = ?", id).First(&current).Error; err != nil {
+			return err
+		}
+		// 顺带清理已退出实例遗留的过期租约
+		if err := tx.Where("repository_id = ? AND expires_at <= ?", id, now).
+			Delete(&models.RepositoryStorageLease{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(lease).Error
+	}); err != nil {
+		return nil, fmt.Errorf("获取仓库锁失败: %w", err)
+	}
+
+	release := func() {
+		if err := s.db.Delete(lease).Error; err != nil {
+			fmt.Printf("释放仓库独占租约失败 [%s]: %v\n", id, err)
+		}
+	}
+
+	deadline := now.Add(storageDrainTimeout)
+	for {
+		var active int64
+		if err := s.db.Model(&models.RepositoryStorageLease{}).
+			Where("repository_id = ? AND exclusive = ? AND expires_at > ?", id, false, time.Now()).
+			Count(&active).Error; err != nil {
+			release()
+			return nil, fmt.Errorf("获取仓库锁失败: %w", err)
+		}
+		if active == 0 {
+			return release, nil
+		}
+		if time.Now().After(deadline) {
+			release()
+			return nil, fmt.Errorf("仓库仍有进行中的读写，迁移已取消")
+		}
+		time.Sleep(storageLeasePoll)
+	}
+}
+
+// Open 获取仓库的共享使用锁并打开磁盘上的Git仓库，使用完毕后调用返回的函数释放
+func (s *storageService) Open(repo *models.Repository) (*git.Repository, func(), error) {
+	release, err := s.Acquire(repo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gitRepo, err := git.PlainOpen(repositoryPath(s.config, repo))
+	if err != nil {
+		release()
+		return nil, nil, fmt.Errorf("打开Git仓库失败: %w", err)
+	}
+	return gitRepo, release, nil
+}
+
+// MoveRepository 在线迁移仓库到另一个存储根目录
+// 先在不阻塞读写的情况下复制整个仓库目录，再在独占锁内增量同步变化的文件并切换存储位置，
+// 写入仅在最后的增量同步期间被阻塞
+func (s *storageService) MoveRepository(repositoryID uuid.UUID, root string) (*models.Repository, error) {
+	var repo models.Repository
+	if err := s.db.Where("id = ? AND deleted_at IS NULL", repositoryID).First(&repo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("仓库不存在")
+		}
+		return nil, fmt.Errorf("获取仓库失败: %w", err)
+	}
+
+	var target *config.StorageRootConfig
+	for _, r := range s.config.Git.Roots() {
+		if r.Name == root {
+			target = &r
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("存储根目录不存在: %s", root)
+	}
+	if s.config.Git.RootPath(repo.StorageRoot) == target.Path {
+		return nil, fmt.Errorf("仓库已位于存储根目录 %s", root)
+	}
+
+	endMove, err := s.tryAdvisoryLock(storageLockMove, repo.ID)
+	if err != nil {
+		return nil, err
+	}
+	if endMove == nil {
+		return nil, fmt.Errorf("仓库正在迁移中")
+	}
+	defer endMove()
+
+	srcPath := repositoryPath(s.config, &repo)
+	dstPath := filepath.Join(target.Path, repo.ProjectID.String(), repo.Name)
+	if _, err := os.Stat(dstPath); err == nil {
+		return nil, fmt.Errorf("目标位置已存在同名仓库目录")
+	}
+
+	size, err := directorySize(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("计算仓库大小失败: %w", err)
+	}
+	if info := s.rootInfo(*target); info.Error != "" || info.FreeBytes < uint64(size) {
+		return nil, fmt.Errorf("存储根目录 %s 空间不足", root)
+	}
+
+	tmpPath := fmt.Sprintf("%s.moving-%d", dstPath, time.Now().UnixNano())
+	if err := os.MkdirAll(filepath.Dir(tmpPath), 0755); err != nil {
+		return nil, fmt.Errorf("创建目标目录失败: %w", err)
+	}
+
+	// 第一阶段：不加锁复制，期间仓库正常读写
+	if err := syncDirectory(srcPath, tmpPath); err != nil {
+		os.RemoveAll(tmpPath)
+		return nil, fmt.Errorf("复制仓库失败: %w", err)
+	}
+
+	// 第二阶段：阻塞新的读写并等待所有副本上进行中的读写结束，同步剩余变化后切换
+	unlock, err := s.beginSwitch(repo.ID)
+	if err != nil {
+		os.RemoveAll(tmpPath)
+		return nil, err
+	}
+	trashPath, err := s.switchStorage(&repo, target.Name, srcPath, tmpPath, dstPath)
+	unlock()
+	if err != nil {
+		os.RemoveAll(tmpPath)
+		return nil, err
+	}
+
+	if err := os.RemoveAll(trashPath); err != nil {
+		fmt.Printf("清理原仓库目录失败 [%s]: %v\n", trashPath, err)
+	}
+
+	repo.StorageRoot = target.Name
+	return &repo, nil
+}
+
+// switchStorage 在独占锁内完成增量同步、目录切换和存储位置更新，返回待清理的原目录
+func (s *storageService) switchStorage(repo *models.Repository, root, srcPath, tmpPath, dstPath string) (string, error) {
+	if err := syncDirectory(srcPath, tmpPath); err != nil {
+		return "", fmt.Errorf("同步仓库失败: %w", err)
+	}
+	if err := os.Rename(tmpPath, dstPath); err != nil {
+		return "", fmt.Errorf("切换仓库目录失败: %w", err)
+	}
+
+	if err := s.db.Model(repo).Updates(map[string]interface{}{
+		"storage_root": root,
+		"updated_at":   time.Now(),
+	}).Error; err != nil {
+		os.Rename(dstPath, tmpPath)
+		return "", fmt.Errorf("更新仓库存储位置失败: %w", err)
+	}
+
+	// 原目录先改名，锁外再删除，避免长时间阻塞
+	trashPath := fmt.Sprintf("%s.moved-%d", srcPath, time.Now().UnixNano())
+	if err := os.Rename(srcPath, trashPath); err != nil {
+		return srcPath, nil
+	}
+	return trashPath, nil
+}
+
+// tryAdvisoryLock 在独立的数据库连接上尝试获取仓库独占咨询锁，已被其他会话持有时返回nil
+// 咨询锁属于会话，必须在同一连接上加锁和解锁；只用于迁移这类少量的管理操作，不用于每次读写
+func (s *storageService) tryAdvisoryLock(usage string, id uuid.UUID) (func(), error) {
+	conn, err := s.lockConn()
+	if err != nil {
+		return nil, err
+	}
+
+	key := storageLockKey(usage, id)
+	var locked bool
+	if err := conn.QueryRowContext(context.Background(), "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("获取仓库锁失败: %w", err)
+	}
+	if !locked {
+		conn.Close()
+		return nil, nil
+	}
+	return func() { releaseAdvisoryLock(conn, "pg_advisory_unlock", key) }, nil
+}
+
+// lockConn 从连接池取出一个专用连接用于持有咨询锁
+func (s *storageService) lockConn() (*sql.Conn, error) {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("获取数据库连接失败: %w", err)
+	}
+	conn, err := sqlDB.Conn(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("获取数据库连接失败: %w", err)
+	}
+	return conn, nil
+}
+
+// releaseAdvisoryLock 释放咨询锁并归还连接；解锁失败时丢弃连接，会话结束后锁由数据库自动释放
+func releaseAdvisoryLock(conn *sql.Conn, unlockFn string, key int64) {
+	if _, err := conn.ExecContext(context.Background(), "SELECT "+unlockFn+"($1)", key); err != nil {
+		fmt.Printf("释放仓库锁失败: %v\n", err)
+		conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	}
+	conn.Close()
+}
+
+// storageLockKey 由锁用途和仓库ID计算咨询锁键
+func storageLockKey(usage string, id uuid.UUID) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(usage))
+	h.Write(id[:])
+	return int64(h.Sum64())
+}
+
+// directorySize 计算目录下文件总大小
+func directorySize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// syncDirectory 将src同步到dst：复制大小或修改时间不同的文件，删除src中已不存在的文件
+// Git对象文件写入后不再变化，重复同步只需复制引用、打包文件等少量变化
+func syncDirectory(src, dst string) error {
+	seen := make(map[string]bool)
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// 同步期间被删除的文件（如打包后的松散对象）直接跳过
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		seen[rel] = true
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		if existing, err := os.Stat(target); err == nil &&
+			existing.Size() == info.Size() && existing.ModTime().Equal(info.ModTime()) {
+			return nil
+		}
+		return copyFile(path, target, info)
+	})
+	if err != nil {
+		return err
+	}
+
+	var stale []string
+	err = filepath.Walk(dst, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dst, path)
+		if err != nil {
+			return err
+		}
+		if !seen[rel] {
+			stale = append(stale, path)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, path := range stale {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile 复制文件并保留权限和修改时间
+func copyFile(src, dst string, info os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".sync-tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chtimes(tmp, info.ModTime(), info.ModTime()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
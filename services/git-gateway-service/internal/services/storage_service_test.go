@@ -0,0 +1,181 @@
+package services
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"git-gateway-service/internal/models"
+)
+
+// newTestDB 创建临时SQLite数据库并执行建表语句，连接池上限为 maxConns
+func newTestDB(t *testing.T, maxConns int, schema ...string) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=5000&_journal_mode=WAL"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(maxConns)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	for _, statement := range schema {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("schema: %v", err)
+		}
+	}
+	return db
+}
+
+// storageTestDB 创建只含仓库存储位置和租约表的临时数据库
+func storageTestDB(t *testing.T, maxConns int) *gorm.DB {
+	t.Helper()
+	db := newTestDB(t, maxConns, "CREATE TABLE repositories (id TEXT PRIMARY KEY, storage_root TEXT)")
+	if err := db.AutoMigrate(&models.RepositoryStorageLease{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// acquireAll 并发获取 n 个租约，超时视为连接池耗尽
+func acquireAll(t *testing.T, s StorageService, id uuid.UUID, n int) []func() {
+	t.Helper()
+	releases := make([]func(), n)
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			release, err := s.Acquire(&models.Repository{ID: id})
+			releases[i] = release
+			errs <- err
+		}(i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("acquiring %d leases timed out", n)
+	}
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Acquire: %v", err)
+		}
+	}
+	return releases
+}
+
+func TestAcquireBeyondPoolSize(t *testing.T) {
+	db := storageTestDB(t, 2)
+	s := &storageService{db: db}
+	id := uuid.New()
+	if err := db.Exec("INSERT INTO repositories (id, storage_root) VALUES (?, ?)", id, "/data/a").Error; err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	// 持有的租约不占用连接，超过连接池上限的并发读写也能全部获取
+	releases := acquireAll(t, s, id, 10)
+
+	repo := &models.Repository{ID: id}
+	release, err := s.Acquire(repo)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if repo.StorageRoot != "/data/a" {
+		t.Fatalf("storage root = %q, want /data/a", repo.StorageRoot)
+	}
+	release()
+	release()
+	for _, release := range releases {
+		release()
+	}
+
+	var remaining int64
+	db.Model(&models.RepositoryStorageLease{}).Count(&remaining)
+	if remaining != 0 {
+		t.Fatalf("remaining leases = %d, want 0", remaining)
+	}
+}
+
+func TestBeginSwitchWaitsForLeases(t *testing.T) {
+	db := storageTestDB(t, 2)
+	s := &storageService{db: db}
+	id := uuid.New()
+	if err := db.Exec("INSERT INTO repositories (id, storage_root) VALUES (?, ?)", id, "/data/a").Error; err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	releases := acquireAll(t, s, id, 3)
+
+	switched := make(chan func(), 1)
+	go func() {
+		end, err := s.beginSwitch(id)
+		if err != nil {
+			t.Errorf("beginSwitch: %v", err)
+		}
+		switched <- end
+	}()
+
+	select {
+	case <-switched:
+		t.Fatal("beginSwitch returned while leases are held")
+	case <-time.After(3 * storageLeasePoll):
+	}
+
+	// 切换开始后新的获取需要等待切换结束
+	acquired := make(chan struct{})
+	go func() {
+		repo := &models.Repository{ID: id}
+		release, err := s.Acquire(repo)
+		if err != nil {
+			t.Errorf("Acquire: %v", err)
+			close(acquired)
+			return
+		}
+		if repo.StorageRoot != "/data/b" {
+			t.Errorf("storage root = %q, want /data/b", repo.StorageRoot)
+		}
+		release()
+		close(acquired)
+	}()
+	time.Sleep(3 * storageLeasePoll)
+
+	for _, release := range releases {
+		release()
+	}
+	var end func()
+	select {
+	case end = <-switched:
+	case <-time.After(5 * time.Second):
+		t.Fatal("beginSwitch did not return after leases were released")
+	}
+	select {
+	case <-acquired:
+		t.Fatal("Acquire returned during switch")
+	default:
+	}
+
+	db.Exec("UPDATE repositories SET storage_root = ? WHERE id = ?", "/data/b", id)
+	end()
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Acquire did not return after switch ended")
+	}
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"git-gateway-service/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// taskLinkQueueSize 待上报事件队列长度
+const taskLinkQueueSize = 256
+
+// taskKeyPattern 任务编号（项目键-任务序号，如 AX-123），仅用于筛选需要上报的事件，
+// 项目键与任务的解析由项目服务完成
+var taskKeyPattern = regexp.MustCompile(`\b[A-Z][A-Z0-9]{1,9}-[1-9][0-9]*\b`)
+
+// TaskLinkService 任务关联服务接口：从推送和合并队列中提取引用任务编号的代码事件，异步上报项目服务
+type TaskLinkService interface {
+	RecordPush(repo *models.Repository, session *GitSession, update *RefUpdate, commits []PushCommit)
+	RecordPullRequest(entry *models.MergeQueueEntry, state string)
+}
+
+type taskLinkService struct {
+	db      *gorm.DB
+	project ProjectClient
+	queue   chan *DevelopmentEventsRequest
+}
+
+// NewTaskLinkService 创建任务关联服务实例
+func NewTaskLinkService(db *gorm.DB, project ProjectClient) TaskLinkService {
+	s := &taskLinkService{
+		db:      db,
+		project: project,
+		queue:   make(chan *DevelopmentEventsRequest, taskLinkQueueSize),
+	}
+	go s.run()
+	return s
+}
+
+// RecordPush 上报推送中的分支创建/删除和引用任务编号的提交，推送到默认分支的提交视为已合并
+func (s *taskLinkService) RecordPush(repo *models.Repository, session *GitSession, update *RefUpdate, commits []PushCommit) {
+	refType, name := ParseRefName(update.RefName)
+	if refType != RefTypeBranch {
+		return
+	}
+
+	// 部署密钥/令牌推送不关联用户
+	var userID *uuid.UUID
+	if session.UserID != uuid.Nil {
+		id := session.UserID
+		userID = &id
+	}
+	var events []DevelopmentEvent
+
+	if referencesTask(strings.ToUpper(name)) {
+		switch {
+		case update.OldSHA == ZeroCommitSHA:
+			events = append(events, DevelopmentEvent{
+				Type:       DevelopmentEventBranch,
+				Reference:  name,
+				Title:      name,
+				UserID:     userID,
+				State:      DevelopmentStateOpen,
+				OccurredAt: time.Now(),
+			})
+		case update.NewSHA == ZeroCommitSHA:
+			events = append(events, DevelopmentEvent{
+				Type:       DevelopmentEventBranch,
+				Reference:  name,
+				Title:      name,
+				UserID:     userID,
+				State:      DevelopmentStateDeleted,
+				OccurredAt: time.Now(),
+			})
+		}
+	}
+
+	state := DevelopmentStateOpen
+	if name == repo.DefaultBranch {
+		state = DevelopmentStateMerged
+	}
+	for _, commit := range commits {
+		if !referencesTask(commit.Message) {
+			continue
+		}
+		branch := name
+		authorName, authorEmail := commit.Author.Name, commit.Author.Email
+		events = append(events, DevelopmentEvent{
+			Type:        DevelopmentEventCommit,
+			Reference:   commit.ID,
+			Branch:      &branch,
+			Title:       commit.Message,
+			AuthorName:  &authorName,
+			AuthorEmail: &authorEmail,
+			UserID:      userID,
+			State:       state,
+			OccurredAt:  commit.Timestamp,
+		})
+	}
+
+	s.enqueue(repo, session.UserID, events)
+}
+
+// RecordPullRequest 上报合并请求（合并队列条目）的状态变化；合并时项目服务同时处理源分支上带关闭关键字的提交
+func (s *taskLinkService) RecordPullRequest(entry *models.MergeQueueEntry, state string) {
+	var repo models.Repository
+	if err := s.db.Where("id = ?", entry.RepositoryID).First(&repo).Error; err != nil {
+		fmt.Printf("获取仓库失败，跳过任务关联上报 [%s]: %v\n", entry.RepositoryID, err)
+		return
+	}
+
+	reference := entry.ID.String()
+	if entry.PullRequestID != nil {
+		reference = entry.PullRequestID.String()
+	}
+	branch := entry.SourceBranch
+	userID := entry.UserID
+
+	s.enqueue(&repo, userID, []DevelopmentEvent{{
+		Type:       DevelopmentEventPullRequest,
+		Reference:  reference,
+		Branch:     &branch,
+		Title:      entry.Title,
+		UserID:     &userID,
+		State:      state,
+		OccurredAt: time.Now(),
+	}})
+}
+
+// enqueue 将事件加入上报队列，队列已满时丢弃，不阻塞推送
+func (s *taskLinkService) enqueue(repo *models.Repository, userID uuid.UUID, events []DevelopmentEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	var project models.Project
+	if err := s.db.Select("tenant_id").Where("id = ?", repo.ProjectID).First(&project).Error; err != nil {
+		fmt.Printf("获取项目失败，跳过任务关联上报 [%s]: %v\n", repo.ProjectID, err)
+		return
+	}
+
+	req := &DevelopmentEventsRequest{
+		ProjectID:    repo.ProjectID,
+		TenantID:     project.TenantID,
+		RepositoryID: repo.ID,
+		Repository:   repo.Name,
+		Events:       events,
+		UserID:       userID,
+	}
+	select {
+	case s.queue <- req:
+	default:
+		fmt.Printf("任务关联上报队列已满，跳过仓库 %s 的 %d 个事件\n", repo.Name, len(events))
+	}
+}
+
+// run 顺序上报队列中的事件
+func (s *taskLinkService) run() {
+	for req := range s.queue {
+		if err := s.project.RecordDevelopmentEvents(req); err != nil {
+			fmt.Printf("任务关联上报失败 [%s]: %v\n", req.Repository, err)
+		}
+	}
+}
+
+// referencesTask 文本中是否包含任务编号
+func referencesTask(text string) bool {
+	return taskKeyPattern.MatchString(text)
+}
@@ -14,7 +14,6 @@ import (
 	"git-gateway-service/internal/models"
 
 	"github.com/google/uuid"
-	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -282,7 +281,7 @@ func (s *webhookService) TriggerEvent(repositoryID uuid.UUID, eventType string,
 
 // DeliverWebhook 投递Webhook
 func (s *webhookService) DeliverWebhook(webhook *models.Webhook, eventType string, payload interface{}) error {
-	deliveryID := uuid.New().String()[:8]
+	deliveryID := uuid.New().String()
 	
 	// 创建投递记录
 	delivery := &models.WebhookDelivery{
@@ -294,7 +293,7 @@ func (s *webhookService) DeliverWebhook(webhook *models.Webhook, eventType strin
 	}
 
 	// 序列化payload
-	payloadBytes, err := json.Marshal(payload)
+	payloadBytes, err := signedPayload(payload, eventType, deliveryID)
 	if err != nil {
 		delivery.Success = false
 		s.db.Create(delivery)
@@ -486,6 +485,23 @@ func (s *webhookService) List(req *ListWebhooksRequest) ([]models.Webhook, int64
 	return webhooks, total, nil
 }
 
+// signedPayload 序列化载荷。对象载荷写入 event 和 delivery_id，
+// 使事件类型和投递ID在签名范围内，接收方据此校验请求头并拒绝重放
+func signedPayload(payload interface{}, eventType, deliveryID string) ([]byte, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payloadBytes, &fields); err != nil || fields == nil {
+		return payloadBytes, nil
+	}
+	fields["event"], _ = json.Marshal(eventType)
+	fields["delivery_id"], _ = json.Marshal(deliveryID)
+	return json.Marshal(fields)
+}
+
 // generateSignature 生成Webhook签名
 func (s *webhookService) generateSignature(payload []byte, secret string) string {
 	mac := hmac.New(sha256.New, []byte(secret))
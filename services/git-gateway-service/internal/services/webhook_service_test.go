@@ -0,0 +1,41 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSignedPayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload interface{}
+		want    string
+	}{
+		{"object gains event and delivery id", map[string]interface{}{"ref": "refs/heads/main"},
+			`{"delivery_id":"d1","event":"push","ref":"refs/heads/main"}`},
+		{"stale fields overwritten on retry", map[string]interface{}{"event": "push", "delivery_id": "old"},
+			`{"delivery_id":"d1","event":"push"}`},
+		{"struct payload", struct {
+			Action string `json:"action"`
+		}{"opened"}, `{"action":"opened","delivery_id":"d1","event":"push"}`},
+		{"raw message payload", json.RawMessage(`{"after":"abc"}`), `{"after":"abc","delivery_id":"d1","event":"push"}`},
+		{"non-object payload unchanged", []string{"a"}, `["a"]`},
+		{"null payload unchanged", nil, `null`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := signedPayload(tt.payload, "push", "d1")
+			if err != nil {
+				t.Fatalf("signedPayload: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("signedPayload = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := signedPayload(map[string]interface{}{"bad": make(chan int)}, "push", "d1"); err == nil {
+		t.Fatal("expected unmarshalable payload to fail")
+	}
+}
@@ -42,11 +42,13 @@ func main() {
 	projectService := services.NewProjectService(db, logger)
 	taskService := services.NewTaskService(db, logger)
 	sprintService := services.NewSprintService(db, logger)
+	developmentService := services.NewDevelopmentService(db, logger)
 
 	// 初始化处理器
 	projectHandler := handlers.NewProjectHandler(projectService, logger)
 	taskHandler := handlers.NewTaskHandler(taskService, logger)
 	sprintHandler := handlers.NewSprintHandler(sprintService, logger)
+	developmentHandler := handlers.NewDevelopmentHandler(developmentService, logger)
 
 	// 创建路由器
 	router := gin.New()
@@ -77,6 +79,15 @@ func main() {
 		projectHandler.RegisterRoutes(api)
 		taskHandler.RegisterRoutes(api)
 		sprintHandler.RegisterRoutes(api)
+		developmentHandler.RegisterRoutes(api)
+	}
+
+	// 服务间调用路由（仅接受服务令牌）
+	internal := router.Group("/api/v1")
+	{
+		internal.Use(middleware.ServiceAuth(cfg.JWT.Secret))
+
+		developmentHandler.RegisterServiceRoutes(internal)
 	}
 
 	// 创建HTTP服务器
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"project-service/internal/services"
+	"project-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// DevelopmentHandler 研发信息处理器
+type DevelopmentHandler struct {
+	developmentService services.DevelopmentService
+	logger             logger.Logger
+}
+
+// NewDevelopmentHandler 创建研发信息处理器
+func NewDevelopmentHandler(developmentService services.DevelopmentService, logger logger.Logger) *DevelopmentHandler {
+	return &DevelopmentHandler{
+		developmentService: developmentService,
+		logger:             logger,
+	}
+}
+
+// RegisterRoutes 注册路由
+func (h *DevelopmentHandler) RegisterRoutes(r *gin.RouterGroup) {
+	// 任务研发信息
+	r.GET("/tasks/:task_id/development", h.GetTaskDevelopment)
+}
+
+// RegisterServiceRoutes 注册服务间调用路由（需使用服务令牌认证的路由组）
+func (h *DevelopmentHandler) RegisterServiceRoutes(r *gin.RouterGroup) {
+	// Git网关上报代码事件
+	r.POST("/development/events", h.RecordEvents)
+}
+
+// RecordEvents 记录Git网关上报的提交、分支和合并请求事件
+func (h *DevelopmentHandler) RecordEvents(c *gin.Context) {
+	var req services.RecordDevelopmentEventsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body", "details": err.Error()})
+		return
+	}
+	req.UserID = c.MustGet("user_id").(uuid.UUID)
+
+	result, err := h.developmentService.RecordEvents(c.Request.Context(), &req)
+	if err != nil {
+		if err.Error() == "项目不存在" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrTenantMismatch) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("记录代码事件失败", "error", err, "repository_id", req.RepositoryID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// GetTaskDevelopment 获取任务关联的提交、分支和合并请求
+func (h *DevelopmentHandler) GetTaskDevelopment(c *gin.Context) {
+	taskID, err := uuid.Parse(c.Param("task_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task_id"})
+		return
+	}
+
+	tenantID, exists := c.Get("tenant_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant_id required"})
+		return
+	}
+
+	development, err := h.developmentService.GetTaskDevelopment(c.Request.Context(), tenantID.(uuid.UUID), taskID)
+	if err != nil {
+		if err.Error() == "任务不存在" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("获取任务研发信息失败", "error", err, "task_id", taskID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": development})
+}
@@ -3,7 +3,6 @@ package handlers
 import (
 	"net/http"
 	"strconv"
-	"time"
 
 	"project-service/internal/services"
 	"project-service/pkg/logger"
@@ -140,6 +140,28 @@ type Milestone struct {
 	Project *Project `json:"project,omitempty" gorm:"foreignKey:ProjectID"`
 }
 
+// TaskLink 任务与代码的关联（提交、分支、合并请求），由Git网关上报的事件中解析任务编号（如 AX-123）生成
+type TaskLink struct {
+	ID           uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v7()"`
+	TenantID     uuid.UUID  `json:"tenant_id" gorm:"type:uuid;not null;index"`
+	TaskID       uuid.UUID  `json:"task_id" gorm:"type:uuid;not null;index"`
+	RepositoryID uuid.UUID  `json:"repository_id" gorm:"type:uuid;not null"`
+	Repository   string     `json:"repository" gorm:"size:255;not null"`
+	LinkType     string     `json:"link_type" gorm:"size:20;not null"`  // commit, branch, pull_request
+	Reference    string     `json:"reference" gorm:"size:255;not null"` // 提交SHA、分支名或合并请求ID
+	Branch       *string    `json:"branch" gorm:"size:255"`             // 提交所在分支或合并请求的源分支
+	Title        string     `json:"title" gorm:"size:512"`
+	AuthorName   *string    `json:"author_name" gorm:"size:255"`
+	AuthorEmail  *string    `json:"author_email" gorm:"size:255"`
+	UserID       *uuid.UUID `json:"user_id" gorm:"type:uuid"`
+	State        string     `json:"state" gorm:"size:20;not null;default:open"` // open, merged, closed, deleted
+	Closes       bool       `json:"closes" gorm:"default:false"`                // 包含关闭关键字（如 fixes AX-123）
+	OccurredAt   time.Time  `json:"occurred_at" gorm:"not null"`
+	MergedAt     *time.Time `json:"merged_at"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"not null"`
+	UpdatedAt    time.Time  `json:"updated_at" gorm:"not null"`
+}
+
 // ProjectSettings 项目设置结构
 type ProjectSettings struct {
 	TaskNumberPrefix    string            `json:"task_number_prefix"`    // 任务编号前缀
@@ -181,6 +203,14 @@ func (s *Sprint) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+// BeforeCreate GORM钩子：任务关联创建前
+func (l *TaskLink) BeforeCreate(tx *gorm.DB) (err error) {
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+	return
+}
+
 // TableName 指定表名
 func (Project) TableName() string {
 	return "projects"
@@ -206,6 +236,10 @@ func (Milestone) TableName() string {
 	return "milestones"
 }
 
+func (TaskLink) TableName() string {
+	return "task_links"
+}
+
 func (ProjectMember) TableName() string {
 	return "project_members"
 }
@@ -0,0 +1,489 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"project-service/internal/models"
+	"project-service/pkg/logger"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrTenantMismatch 推送者不属于项目所在租户
+var ErrTenantMismatch = errors.New("用户不属于项目所在租户")
+
+// 任务关联类型
+const (
+	TaskLinkCommit      = "commit"
+	TaskLinkBranch      = "branch"
+	TaskLinkPullRequest = "pull_request"
+)
+
+// 任务关联状态
+const (
+	TaskLinkOpen    = "open"
+	TaskLinkMerged  = "merged"
+	TaskLinkClosed  = "closed"
+	TaskLinkDeleted = "deleted"
+)
+
+// DevelopmentService 研发信息服务接口（任务与提交、分支、合并请求的关联）
+type DevelopmentService interface {
+	RecordEvents(ctx context.Context, req *RecordDevelopmentEventsRequest) (*RecordDevelopmentEventsResult, error)
+	GetTaskDevelopment(ctx context.Context, tenantID, taskID uuid.UUID) (*TaskDevelopment, error)
+}
+
+// developmentService 研发信息服务实现
+type developmentService struct {
+	db     *gorm.DB
+	logger logger.Logger
+}
+
+// NewDevelopmentService 创建研发信息服务实例
+func NewDevelopmentService(db *gorm.DB, logger logger.Logger) DevelopmentService {
+	return &developmentService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// DevelopmentEvent Git网关上报的代码事件
+type DevelopmentEvent struct {
+	Type        string     `json:"type" binding:"required,oneof=commit branch pull_request"`
+	Reference   string     `json:"reference" binding:"required,max=255"` // 提交SHA、分支名或合并请求ID
+	Branch      *string    `json:"branch"`                               // 提交所在分支或合并请求的源分支
+	Title       string     `json:"title"`                                // 提交信息、分支名或合并请求标题
+	AuthorName  *string    `json:"author_name"`
+	AuthorEmail *string    `json:"author_email"`
+	UserID      *uuid.UUID `json:"user_id"`
+	State       string     `json:"state" binding:"required,oneof=open merged closed deleted"`
+	OccurredAt  time.Time  `json:"occurred_at"`
+}
+
+// RecordDevelopmentEventsRequest 上报代码事件请求
+type RecordDevelopmentEventsRequest struct {
+	ProjectID    uuid.UUID          `json:"project_id" binding:"required"` // 仓库所属项目，决定任务编号的解析范围（同租户）
+	TenantID     uuid.UUID          `json:"tenant_id" binding:"required"`  // 网关记录的项目所属租户，须与项目一致
+	RepositoryID uuid.UUID          `json:"repository_id" binding:"required"`
+	Repository   string             `json:"repository" binding:"required"`
+	Events       []DevelopmentEvent `json:"events" binding:"required,dive"`
+	UserID       uuid.UUID          `json:"-"` // 服务令牌中的推送者，部署密钥推送时为空
+}
+
+// RecordDevelopmentEventsResult 上报处理结果
+type RecordDevelopmentEventsResult struct {
+	Links       int         `json:"links"`        // 新增或更新的关联数
+	ClosedTasks []uuid.UUID `json:"closed_tasks"` // 因关闭关键字完成的任务
+}
+
+// TaskDevelopment 任务的研发信息
+type TaskDevelopment struct {
+	TaskID       uuid.UUID              `json:"task_id"`
+	Commits      []models.TaskLink      `json:"commits"`
+	Branches     []models.TaskLink      `json:"branches"`
+	PullRequests []models.TaskLink      `json:"pull_requests"`
+	Summary      TaskDevelopmentSummary `json:"summary"`
+}
+
+// TaskDevelopmentSummary 研发信息汇总
+type TaskDevelopmentSummary struct {
+	Commits            int `json:"commits"`
+	Branches           int `json:"branches"`
+	OpenPullRequests   int `json:"open_pull_requests"`
+	MergedPullRequests int `json:"merged_pull_requests"`
+}
+
+// TaskReference 文本中引用的任务编号
+type TaskReference struct {
+	Key    string `json:"key"`
+	Number int64  `json:"number"`
+	Closes bool   `json:"closes"`
+}
+
+// taskReferencePattern 匹配任务编号（项目键-任务序号），前面可带关闭关键字
+var taskReferencePattern = regexp.MustCompile(
+	`(?:\b(?i:close[sd]?|fix(?:e[sd])?|resolve[sd]?)\b:?\s+)?\b([A-Z][A-Z0-9]{1,9})-([1-9][0-9]*)\b`)
+
+// ParseTaskReferences 解析文本中的任务编号，同一任务多次出现时任一处带关闭关键字即视为关闭
+func ParseTaskReferences(text string) []TaskReference {
+	var refs []TaskReference
+	index := make(map[string]int)
+	for _, match := range taskReferencePattern.FindAllStringSubmatch(text, -1) {
+		number, err := strconv.ParseInt(match[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		closes := match[0] != match[1]+"-"+match[2]
+		id := match[1] + "-" + match[2]
+		if i, ok := index[id]; ok {
+			refs[i].Closes = refs[i].Closes || closes
+			continue
+		}
+		index[id] = len(refs)
+		refs = append(refs, TaskReference{Key: match[1], Number: number, Closes: closes})
+	}
+	return refs
+}
+
+// RecordEvents 解析事件中的任务编号并记录关联；合并状态的事件中带关闭关键字的任务移至完成类状态
+func (s *developmentService) RecordEvents(ctx context.Context, req *RecordDevelopmentEventsRequest) (*RecordDevelopmentEventsResult, error) {
+	var project models.Project
+	if err := s.db.WithContext(ctx).Where("id = ? AND deleted_at IS NULL", req.ProjectID).First(&project).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("项目不存在")
+		}
+		return nil, fmt.Errorf("查询项目失败: %w", err)
+	}
+	if project.TenantID != req.TenantID {
+		return nil, fmt.Errorf("项目不存在")
+	}
+
+	// 推送者必须是项目所在租户的成员，其身份用于自动完成任务时的评论
+	var author *uuid.UUID
+	if req.UserID != uuid.Nil {
+		member, err := s.isTenantMember(ctx, project.TenantID, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if !member {
+			return nil, ErrTenantMismatch
+		}
+		author = &req.UserID
+	}
+
+	resolver := &taskResolver{db: s.db.WithContext(ctx), tenantID: project.TenantID, projects: make(map[string]*uuid.UUID)}
+	result := &RecordDevelopmentEventsResult{ClosedTasks: []uuid.UUID{}}
+	closing := make(map[uuid.UUID]string)
+
+	for i := range req.Events {
+		event := &req.Events[i]
+		if event.OccurredAt.IsZero() {
+			event.OccurredAt = time.Now()
+		}
+
+		text := event.Title
+		if event.Type == TaskLinkBranch {
+			// 分支名常用小写，如 feature/ax-123-login
+			text = strings.ToUpper(event.Reference)
+		}
+
+		for _, ref := range ParseTaskReferences(text) {
+			taskID, err := resolver.resolve(ref)
+			if err != nil {
+				return nil, err
+			}
+			if taskID == nil {
+				continue
+			}
+
+			link, err := s.upsertLink(ctx, project.TenantID, *taskID, req, event, ref.Closes && event.Type != TaskLinkBranch)
+			if err != nil {
+				return nil, err
+			}
+			result.Links++
+
+			if link.Closes && event.State == TaskLinkMerged {
+				closing[*taskID] = describeLink(link)
+			}
+		}
+
+		// 合并请求合并后，源分支上带关闭关键字的提交随之合入
+		if event.Type == TaskLinkPullRequest && event.State == TaskLinkMerged && event.Branch != nil {
+			links, err := s.mergeBranchCommits(ctx, project.TenantID, req.RepositoryID, *event.Branch)
+			if err != nil {
+				return nil, err
+			}
+			for _, link := range links {
+				if _, ok := closing[link.TaskID]; !ok {
+					closing[link.TaskID] = describeLink(&link)
+				}
+			}
+		}
+	}
+
+	for taskID, source := range closing {
+		closed, err := s.completeTask(ctx, project.TenantID, taskID, source, author)
+		if err != nil {
+			s.logger.Error("自动完成任务失败", "error", err, "task_id", taskID)
+			continue
+		}
+		if closed {
+			result.ClosedTasks = append(result.ClosedTasks, taskID)
+		}
+	}
+
+	return result, nil
+}
+
+// GetTaskDevelopment 获取任务关联的提交、分支和合并请求
+func (s *developmentService) GetTaskDevelopment(ctx context.Context, tenantID, taskID uuid.UUID) (*TaskDevelopment, error) {
+	var task models.Task
+	if err := s.db.WithContext(ctx).
+		Joins("JOIN projects p ON tasks.project_id = p.id").
+		Where("tasks.id = ? AND p.tenant_id = ? AND p.deleted_at IS NULL", taskID, tenantID).
+		First(&task).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("任务不存在")
+		}
+		return nil, fmt.Errorf("查询任务失败: %w", err)
+	}
+
+	var links []models.TaskLink
+	if err := s.db.WithContext(ctx).
+		Where("task_id = ? AND tenant_id = ?", taskID, tenantID).
+		Order("occurred_at DESC").
+		Find(&links).Error; err != nil {
+		return nil, fmt.Errorf("获取任务研发信息失败: %w", err)
+	}
+
+	development := &TaskDevelopment{
+		TaskID:       taskID,
+		Commits:      []models.TaskLink{},
+		Branches:     []models.TaskLink{},
+		PullRequests: []models.TaskLink{},
+	}
+	for _, link := range links {
+		switch link.LinkType {
+		case TaskLinkCommit:
+			development.Commits = append(development.Commits, link)
+		case TaskLinkBranch:
+			if link.State != TaskLinkDeleted {
+				development.Summary.Branches++
+			}
+			development.Branches = append(development.Branches, link)
+		case TaskLinkPullRequest:
+			switch link.State {
+			case TaskLinkOpen:
+				development.Summary.OpenPullRequests++
+			case TaskLinkMerged:
+				development.Summary.MergedPullRequests++
+			}
+			development.PullRequests = append(development.PullRequests, link)
+		}
+	}
+	development.Summary.Commits = len(development.Commits)
+
+	return development, nil
+}
+
+// upsertLink 创建或更新关联，已合并的关联不会回退为未合并
+func (s *developmentService) upsertLink(ctx context.Context, tenantID, taskID uuid.UUID,
+	req *RecordDevelopmentEventsRequest, event *DevelopmentEvent, closes bool) (*models.TaskLink, error) {
+	var link models.TaskLink
+	err := s.db.WithContext(ctx).
+		Where("task_id = ? AND repository_id = ? AND link_type = ? AND reference = ?",
+			taskID, req.RepositoryID, event.Type, event.Reference).
+		First(&link).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("查询任务关联失败: %w", err)
+	}
+
+	if err == gorm.ErrRecordNotFound {
+		link = models.TaskLink{
+			TenantID:     tenantID,
+			TaskID:       taskID,
+			RepositoryID: req.RepositoryID,
+			Repository:   req.Repository,
+			LinkType:     event.Type,
+			Reference:    event.Reference,
+			Branch:       event.Branch,
+			Title:        truncate(event.Title, 512),
+			AuthorName:   event.AuthorName,
+			AuthorEmail:  event.AuthorEmail,
+			UserID:       event.UserID,
+			State:        event.State,
+			Closes:       closes,
+			OccurredAt:   event.OccurredAt,
+			CreatedAt:    time.Now(),
+			UpdatedAt:    time.Now(),
+		}
+		if event.State == TaskLinkMerged {
+			link.MergedAt = &event.OccurredAt
+		}
+		if err := s.db.WithContext(ctx).Create(&link).Error; err != nil {
+			return nil, fmt.Errorf("创建任务关联失败: %w", err)
+		}
+		return &link, nil
+	}
+
+	updates := map[string]interface{}{
+		"title":      truncate(event.Title, 512),
+		"repository": req.Repository,
+		"updated_at": time.Now(),
+	}
+	if closes && !link.Closes {
+		updates["closes"] = true
+		link.Closes = true
+	}
+	if link.State != TaskLinkMerged && event.State != link.State {
+		updates["state"] = event.State
+		link.State = event.State
+		if event.State == TaskLinkMerged {
+			updates["merged_at"] = event.OccurredAt
+			link.MergedAt = &event.OccurredAt
+		}
+	}
+	if err := s.db.WithContext(ctx).Model(&link).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("更新任务关联失败: %w", err)
+	}
+	return &link, nil
+}
+
+// mergeBranchCommits 将分支上未合并且带关闭关键字的提交关联标记为已合并
+func (s *developmentService) mergeBranchCommits(ctx context.Context, tenantID, repositoryID uuid.UUID, branch string) ([]models.TaskLink, error) {
+	var links []models.TaskLink
+	if err := s.db.WithContext(ctx).
+		Where("tenant_id = ? AND repository_id = ? AND branch = ? AND link_type = ? AND closes = ? AND state = ?",
+			tenantID, repositoryID, branch, TaskLinkCommit, true, TaskLinkOpen).
+		Find(&links).Error; err != nil {
+		return nil, fmt.Errorf("查询分支提交关联失败: %w", err)
+	}
+	if len(links) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uuid.UUID, len(links))
+	for i, link := range links {
+		ids[i] = link.ID
+	}
+	now := time.Now()
+	if err := s.db.WithContext(ctx).Model(&models.TaskLink{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+		"state":      TaskLinkMerged,
+		"merged_at":  now,
+		"updated_at": now,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("更新分支提交关联失败: %w", err)
+	}
+	return links, nil
+}
+
+// isTenantMember 检查用户是否为租户的有效成员
+func (s *developmentService) isTenantMember(ctx context.Context, tenantID, userID uuid.UUID) (bool, error) {
+	var count int64
+	if err := s.db.WithContext(ctx).Table("tenant_members").
+		Where("tenant_id = ? AND user_id = ? AND status = ?", tenantID, userID, "active").
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("查询租户成员失败: %w", err)
+	}
+	return count > 0, nil
+}
+
+// completeTask 将任务移至租户的第一个完成类状态，已处于完成类状态时跳过
+// 状态变更评论以推送者身份记录，部署密钥推送没有对应用户时不记录评论
+func (s *developmentService) completeTask(ctx context.Context, tenantID, taskID uuid.UUID, source string, author *uuid.UUID) (bool, error) {
+	var task models.Task
+	if err := s.db.WithContext(ctx).Preload("Status").Where("id = ?", taskID).First(&task).Error; err != nil {
+		return false, fmt.Errorf("查询任务失败: %w", err)
+	}
+	if task.Status != nil && task.Status.Category == "done" {
+		return false, nil
+	}
+
+	var status models.TaskStatus
+	if err := s.db.WithContext(ctx).
+		Where("tenant_id = ? AND category = ?", tenantID, "done").
+		Order("display_order ASC").
+		First(&status).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return false, fmt.Errorf("租户未配置完成类任务状态")
+		}
+		return false, fmt.Errorf("查询任务状态失败: %w", err)
+	}
+
+	if err := s.db.WithContext(ctx).Model(&task).Updates(map[string]interface{}{
+		"status_id":  status.ID,
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		return false, fmt.Errorf("更新任务状态失败: %w", err)
+	}
+
+	if author != nil {
+		comment := &models.Comment{
+			TenantID:         tenantID,
+			AuthorID:         *author,
+			Content:          fmt.Sprintf("状态变更为 %s: 由 %s 自动关闭", status.Name, source),
+			ParentEntityType: "task",
+			ParentEntityID:   taskID,
+			CreatedAt:        time.Now(),
+			UpdatedAt:        time.Now(),
+		}
+		if err := s.db.WithContext(ctx).Create(comment).Error; err != nil {
+			s.logger.Warn("创建状态变更评论失败", "error", err, "task_id", taskID)
+		}
+	}
+
+	s.logger.Info("任务已由代码合并自动完成", "task_id", taskID, "source", source)
+	return true, nil
+}
+
+// taskResolver 在租户范围内将任务编号解析为任务ID，缓存项目键
+type taskResolver struct {
+	db       *gorm.DB
+	tenantID uuid.UUID
+	projects map[string]*uuid.UUID
+}
+
+// resolve 解析任务编号，项目或任务不存在时返回nil
+func (r *taskResolver) resolve(ref TaskReference) (*uuid.UUID, error) {
+	projectID, ok := r.projects[ref.Key]
+	if !ok {
+		var project models.Project
+		err := r.db.Select("id").
+			Where("tenant_id = ? AND UPPER(key) = ? AND deleted_at IS NULL", r.tenantID, ref.Key).
+			First(&project).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("查询项目失败: %w", err)
+		}
+		if err == nil {
+			projectID = &project.ID
+		}
+		r.projects[ref.Key] = projectID
+	}
+	if projectID == nil {
+		return nil, nil
+	}
+
+	var task models.Task
+	err := r.db.Select("id").Where("project_id = ? AND task_number = ?", *projectID, ref.Number).First(&task).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询任务失败: %w", err)
+	}
+	return &task.ID, nil
+}
+
+// describeLink 关联的简短描述，用于状态变更评论
+func describeLink(link *models.TaskLink) string {
+	switch link.LinkType {
+	case TaskLinkCommit:
+		return fmt.Sprintf("提交 %s (%s)", shortSHA(link.Reference), link.Repository)
+	case TaskLinkPullRequest:
+		return fmt.Sprintf("合并请求 %q (%s)", link.Title, link.Repository)
+	}
+	return fmt.Sprintf("%s (%s)", link.Reference, link.Repository)
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// truncate 按字符截断
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
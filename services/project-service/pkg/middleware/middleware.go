@@ -1,10 +1,13 @@
 package middleware
 
 import (
+	"fmt"
+	"strings"
+
 	"project-service/pkg/logger"
-	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -100,4 +103,46 @@ func JWTAuth(jwtSecret string) gin.HandlerFunc {
 		
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+// ServiceAuth 服务间调用认证中间件，只接受其他服务签发的 role=service 令牌
+// 令牌中的 user_id 为触发调用的用户，iss 为调用方服务名
+func ServiceAuth(jwtSecret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if !strings.HasPrefix(authHeader, "Bearer ") {
+			c.JSON(401, gin.H{"error": "authorization header required"})
+			c.Abort()
+			return
+		}
+
+		token, err := jwt.Parse(strings.TrimPrefix(authHeader, "Bearer "), func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, jwt.ErrSignatureInvalid
+			}
+			return []byte(jwtSecret), nil
+		})
+		if err != nil || !token.Valid {
+			c.JSON(401, gin.H{"error": "invalid token"})
+			c.Abort()
+			return
+		}
+
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok || claims["role"] != "service" {
+			c.JSON(403, gin.H{"error": "service credential required"})
+			c.Abort()
+			return
+		}
+
+		userID, err := uuid.Parse(fmt.Sprint(claims["user_id"]))
+		if err != nil {
+			c.JSON(401, gin.H{"error": "invalid token"})
+			c.Abort()
+			return
+		}
+		c.Set("user_id", userID)
+		c.Set("service", claims["iss"])
+
+		c.Next()
+	}
+}